@@ -1,14 +1,18 @@
 module github.com/javanhut/Carrion
 
-go 1.23
+go 1.24
+
+require (
+	github.com/peterh/liner v1.2.2
+	golang.org/x/text v0.28.0
+)
 
 require (
 	github.com/google/go-cmp v0.6.0 // indirect
 	github.com/mattn/go-runewidth v0.0.3 // indirect
-	github.com/peterh/liner v1.2.2 // indirect
-	golang.org/x/mod v0.14.0 // indirect
-	golang.org/x/sync v0.6.0 // indirect
-	golang.org/x/sys v0.16.0 // indirect
-	golang.org/x/tools v0.17.0 // indirect
+	golang.org/x/mod v0.26.0 // indirect
+	golang.org/x/sync v0.16.0 // indirect
+	golang.org/x/sys v0.34.0 // indirect
+	golang.org/x/tools v0.35.0 // indirect
 	mvdan.cc/gofumpt v0.7.0 // indirect
 )
@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 
@@ -21,11 +22,17 @@ func (i *Identifier) String() string       { return i.Value }
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int // The value of a literal too large for Value, which is then 0
 }
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
-func (il *IntegerLiteral) String() string       { return strconv.FormatInt(il.Value, 10) }
+func (il *IntegerLiteral) String() string {
+	if il.Big != nil {
+		return il.Big.String()
+	}
+	return strconv.FormatInt(il.Value, 10)
+}
 
 type FloatLiteral struct {
 	Token token.Token
@@ -141,6 +148,17 @@ func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// BytesLiteral is a b"..." literal. Value holds the bytes it denotes, with
+// escapes already decoded.
+type BytesLiteral struct {
+	Token token.Token
+	Value []byte
+}
+
+func (bl *BytesLiteral) expressionNode()      {}
+func (bl *BytesLiteral) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BytesLiteral) String() string       { return fmt.Sprintf("b%q", bl.Value) }
+
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
@@ -251,6 +269,34 @@ func (de *DotExpression) String() string {
 	return fmt.Sprintf("(%s.%s)", de.Left.String(), de.Right.String())
 }
 
+// RangePattern is a case condition `start..end`, which matches values from
+// start to end inclusive.
+type RangePattern struct {
+	Token token.Token // The '..' token
+	Start Expression
+	End   Expression
+}
+
+func (rp *RangePattern) expressionNode()      {}
+func (rp *RangePattern) TokenLiteral() string { return rp.Token.Literal }
+func (rp *RangePattern) String() string {
+	return rp.Start.String() + ".." + rp.End.String()
+}
+
+// ComparisonPattern is a case condition such as `> 100`, which matches
+// values for which value Operator Value is true.
+type ComparisonPattern struct {
+	Token    token.Token // The operator token
+	Operator string
+	Value    Expression
+}
+
+func (cp *ComparisonPattern) expressionNode()      {}
+func (cp *ComparisonPattern) TokenLiteral() string { return cp.Token.Literal }
+func (cp *ComparisonPattern) String() string {
+	return cp.Operator + " " + cp.Value.String()
+}
+
 type NoneLiteral struct {
 	Token token.Token
 }
@@ -282,6 +328,7 @@ func (ft *FStringText) String() string { return ft.Value }
 // FStringExpr wraps an AST Expression for the { ... } part.
 type FStringExpr struct {
 	Expr Expression
+	Spec string // the format spec after a colon, as in {x:>10}, or ""
 }
 
 func (fe *FStringExpr) partNode() {}
@@ -0,0 +1,105 @@
+package ast
+
+import "github.com/javanhut/Carrion/src/token"
+
+// StartPosition returns where node begins in the source, or the zero
+// Position if that is not known. For an expression that starts with an
+// operand, such as `a + b` or `f(x)`, that is the position of the operand
+// rather than of the node's own token.
+func StartPosition(node Node) token.Position {
+	switch n := node.(type) {
+	case *ExpressionStatement:
+		if pos := StartPosition(n.Expression); pos.Line > 0 {
+			return pos
+		}
+		return n.Token.Position
+	case *AssignStatement:
+		if pos := StartPosition(n.Name); pos.Line > 0 {
+			return pos
+		}
+		return n.Token.Position
+	case *FunctionDefinition:
+		return n.Token.Position
+	case *GrimoireDefinition:
+		return n.Token.Position
+	case *ArcaneGrimoire:
+		return n.Token.Position
+	case *RecordDefinition:
+		return n.Token.Position
+	case *ExtendStatement:
+		return n.Token.Position
+	case *ReturnStatement:
+		return n.Token.Position
+	case *IfStatement:
+		return n.Token.Position
+	case *ForStatement:
+		return n.Token.Position
+	case *WhileStatement:
+		return n.Token.Position
+	case *ImportStatement:
+		return n.Token.Position
+	case *MatchStatement:
+		return n.Token.Position
+	case *AttemptStatement:
+		return n.Token.Position
+	case *AutocloseStatement:
+		return n.Token.Position
+	case *RaiseStatement:
+		return n.Token.Position
+	case *IgnoreStatement:
+		return n.Token.Position
+	case *StopStatement:
+		return n.Token.Position
+	case *SkipStatement:
+		return n.Token.Position
+	case *CheckStatement:
+		return n.Token.Position
+	case *ScopeStatement:
+		return n.Token.Position
+	case *PragmaStatement:
+		return n.Token.Position
+	case *ExportStatement:
+		return n.Token.Position
+
+	case *InfixExpression:
+		return StartPosition(n.Left)
+	case *PostfixExpression:
+		return StartPosition(n.Left)
+	case *CallExpression:
+		return StartPosition(n.Function)
+	case *DotExpression:
+		return StartPosition(n.Left)
+	case *RangePattern:
+		return StartPosition(n.Start)
+	case *ComparisonPattern:
+		return n.Token.Position
+	case *IndexExpression:
+		return StartPosition(n.Left)
+	case *TupleLiteral:
+		if len(n.Elements) > 0 {
+			return StartPosition(n.Elements[0])
+		}
+		return n.Token.Position
+	case *Identifier:
+		return n.Token.Position
+	case *IntegerLiteral:
+		return n.Token.Position
+	case *FloatLiteral:
+		return n.Token.Position
+	case *StringLiteral:
+		return n.Token.Position
+	case *FStringLiteral:
+		return n.Token.Position
+	case *Boolean:
+		return n.Token.Position
+	case *NoneLiteral:
+		return n.Token.Position
+	case *PrefixExpression:
+		return n.Token.Position
+	case *ArrayLiteral:
+		return n.Token.Position
+	case *HashLiteral:
+		return n.Token.Position
+	}
+	return token.Position{}
+}
@@ -14,12 +14,22 @@ type AssignStatement struct {
 	Operator string
 	TypeHint Expression
 	Value    Expression
+	Declare  bool // written `var x = ...`, declaring x in the current scope
+	Strict   bool // in a file starting with `pragma strict`
 }
 
 func (as *AssignStatement) statementNode()       {}
 func (as *AssignStatement) TokenLiteral() string { return as.Token.Literal }
 func (as *AssignStatement) String() string {
-	return fmt.Sprintf("%s %s %s", as.Name.String(), as.Operator, as.Value.String())
+	if as.Value == nil {
+		// A grimoire field declared with only a type hint.
+		return fmt.Sprintf("%s: %s", as.Name.String(), as.TypeHint.String())
+	}
+	target := as.Name.String()
+	if as.Declare {
+		target = "var " + target
+	}
+	return fmt.Sprintf("%s %s %s", target, as.Operator, as.Value.String())
 }
 
 type ReturnStatement struct {
@@ -112,8 +122,9 @@ type ForStatement struct {
 	Token       token.Token
 	Variable    Expression // Now supports identifiers, tuple literals, etc.
 	Iterable    Expression
+	Label       *Identifier // Optional name used by `stop label` / `skip label`
 	Body        *BlockStatement
-	Alternative *BlockStatement
+	Alternative *BlockStatement // Runs only when the loop ends without `stop`
 }
 
 func (fs *ForStatement) statementNode()       {}
@@ -125,6 +136,10 @@ func (fs *ForStatement) String() string {
 	out.WriteString(fs.Variable.String())
 	out.WriteString(" in ")
 	out.WriteString(fs.Iterable.String())
+	if fs.Label != nil {
+		out.WriteString(" as ")
+		out.WriteString(fs.Label.String())
+	}
 	out.WriteString(":\n")
 	out.WriteString(fs.Body.String())
 
@@ -140,12 +155,17 @@ type Parameter struct {
 	Name         *Identifier
 	TypeHint     Expression
 	DefaultValue Expression
+	Once         bool // default written `=once`, evaluated at definition time
+	Required     bool // has no default in a strict file, so calls must give it
 }
 
 func (p *Parameter) expressionNode()      {}
 func (p *Parameter) TokenLiteral() string { return "Parameter" }
 
 func (p *Parameter) String() string {
+	if p.Once {
+		return fmt.Sprintf("%s=once %s", p.Name.String(), p.DefaultValue.String())
+	}
 	if p.DefaultValue != nil {
 		return fmt.Sprintf("%s=%s", p.Name.String(), p.DefaultValue.String())
 	}
@@ -158,6 +178,7 @@ type FunctionDefinition struct {
 	Parameters []*Parameter
 	Body       *BlockStatement
 	DocString  *StringLiteral
+	Decorators []Expression // the `@decorator` lines above it, top first
 }
 
 func (fd *FunctionDefinition) statementNode()       {}
@@ -170,6 +191,9 @@ func (fd *FunctionDefinition) String() string {
 		params = append(params, p.String())
 	}
 
+	for _, d := range fd.Decorators {
+		out.WriteString("@" + d.String() + "\n")
+	}
 	out.WriteString(fd.TokenLiteral() + " ")
 	out.WriteString(fd.Name.String())
 	out.WriteString("(")
@@ -181,9 +205,11 @@ func (fd *FunctionDefinition) String() string {
 }
 
 type WhileStatement struct {
-	Token     token.Token
-	Condition Expression
-	Body      *BlockStatement
+	Token       token.Token
+	Condition   Expression
+	Label       *Identifier // Optional name used by `stop label` / `skip label`
+	Body        *BlockStatement
+	Alternative *BlockStatement // Runs only when the loop ends without `stop`
 }
 
 func (ws *WhileStatement) statementNode()       {}
@@ -192,8 +218,57 @@ func (ws *WhileStatement) String() string {
 	var out strings.Builder
 	out.WriteString("while ")
 	out.WriteString(ws.Condition.String())
+	if ws.Label != nil {
+		out.WriteString(" as ")
+		out.WriteString(ws.Label.String())
+	}
 	out.WriteString(":\n")
 	out.WriteString(ws.Body.String())
+	if ws.Alternative != nil {
+		out.WriteString("else:\n")
+		out.WriteString(ws.Alternative.String())
+	}
+	return out.String()
+}
+
+// RecordDefinition declares a lightweight data type: `record Point(x, y)`.
+type RecordDefinition struct {
+	Token  token.Token // The 'record' token
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (rd *RecordDefinition) statementNode()       {}
+func (rd *RecordDefinition) TokenLiteral() string { return rd.Token.Literal }
+func (rd *RecordDefinition) String() string {
+	fields := make([]string, len(rd.Fields))
+	for i, field := range rd.Fields {
+		fields[i] = field.String()
+	}
+	return "record " + rd.Name.String() + "(" + strings.Join(fields, ", ") + ")"
+}
+
+// ExtendStatement adds methods to a grimoire defined elsewhere:
+// `extend Name:` followed by spells.
+type ExtendStatement struct {
+	Token     token.Token // The 'extend' token
+	Name      *Identifier
+	Methods   []*FunctionDefinition
+	DocString *StringLiteral
+}
+
+func (es *ExtendStatement) statementNode()       {}
+func (es *ExtendStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExtendStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("extend ")
+	out.WriteString(es.Name.String())
+	out.WriteString(":\n")
+	for _, method := range es.Methods {
+		out.WriteString("    ")
+		out.WriteString(method.String())
+		out.WriteString("\n")
+	}
 	return out.String()
 }
 
@@ -204,16 +279,26 @@ type GrimoireDefinition struct {
 	Methods    []*FunctionDefinition
 	InitMethod *FunctionDefinition
 	DocString  *StringLiteral
+	Data       bool               // Declared with @data
+	Fields     []*AssignStatement // Field declarations in the body; Value may be nil
 }
 
 func (sb *GrimoireDefinition) statementNode()       {}
 func (sb *GrimoireDefinition) TokenLiteral() string { return sb.Token.Literal }
 func (sb *GrimoireDefinition) String() string {
 	var out bytes.Buffer
+	if sb.Data {
+		out.WriteString("@data\n")
+	}
 	out.WriteString("grim ")
 	out.WriteString(sb.Name.String())
 	out.WriteString(":\n")
 
+	for _, field := range sb.Fields {
+		out.WriteString("    ")
+		out.WriteString(field.String())
+		out.WriteString("\n")
+	}
 	if sb.InitMethod != nil {
 		out.WriteString("    ")
 		out.WriteString(sb.InitMethod.String())
@@ -232,11 +317,18 @@ type ImportStatement struct {
 	FilePath  *StringLiteral
 	ClassName *Identifier
 	Alias     *Identifier
+	Exposed   []*Identifier // names imported by `expose`; empty imports all its exports
+	Lazy      bool          // `lazy import`: load the module on first use
 }
 
 func (is *ImportStatement) statementNode()       {}
 func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
 func (is *ImportStatement) String() string {
+	if is.Lazy {
+		plain := *is
+		plain.Lazy = false
+		return "lazy " + plain.String()
+	}
 	if is.ClassName != nil {
 		if is.Alias != nil {
 			return fmt.Sprintf(
@@ -248,6 +340,13 @@ func (is *ImportStatement) String() string {
 		}
 		return fmt.Sprintf("import %s.%s", is.FilePath.Value, is.ClassName.Value)
 	}
+	if len(is.Exposed) > 0 {
+		names := make([]string, len(is.Exposed))
+		for i, name := range is.Exposed {
+			names[i] = name.Value
+		}
+		return fmt.Sprintf("import %s expose %s", is.FilePath.Value, strings.Join(names, ", "))
+	}
 	return fmt.Sprintf("import %s", is.FilePath.Value)
 }
 
@@ -291,6 +390,30 @@ func (cc *CaseClause) String() string {
 	return out.String()
 }
 
+// AutocloseStatement is `autoclose resource as name:`, which runs its body
+// between the __enter__ and __exit__ spells of resource.
+type AutocloseStatement struct {
+	Token    token.Token // The 'autoclose' token
+	Resource Expression
+	Alias    *Identifier // bound to what __enter__ gives, or nil
+	Body     *BlockStatement
+}
+
+func (as *AutocloseStatement) statementNode()       {}
+func (as *AutocloseStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AutocloseStatement) String() string {
+	var out strings.Builder
+	out.WriteString("autoclose ")
+	out.WriteString(as.Resource.String())
+	if as.Alias != nil {
+		out.WriteString(" as ")
+		out.WriteString(as.Alias.Value)
+	}
+	out.WriteString(":\n")
+	out.WriteString(as.Body.String())
+	return out.String()
+}
+
 type AttemptStatement struct {
 	Token          token.Token
 	TryBlock       *BlockStatement
@@ -337,16 +460,7 @@ func (as *AttemptStatement) String() string {
 	}
 
 	for _, e := range as.EnsnareClauses {
-		out.WriteString("ensnare")
-		if e.Condition != nil {
-			out.WriteString(" (")
-			out.WriteString(e.Condition.String())
-			out.WriteString(")")
-		}
-		out.WriteString(":\n")
-		if e.Consequence != nil {
-			out.WriteString(e.Consequence.String())
-		}
+		out.WriteString(e.String())
 	}
 
 	if as.ResolveBlock != nil {
@@ -357,14 +471,23 @@ func (as *AttemptStatement) String() string {
 	return out.String()
 }
 
+// RaiseStatement is `raise error`, optionally `from cause`, or a bare
+// `raise`, with a nil Error, that raises again the error being ensnared.
 type RaiseStatement struct {
 	Token token.Token
 	Error Expression
+	Cause Expression
 }
 
 func (rs *RaiseStatement) statementNode()       {}
 func (rs *RaiseStatement) TokenLiteral() string { return rs.Token.Literal }
 func (rs *RaiseStatement) String() string {
+	if rs.Error == nil {
+		return "raise"
+	}
+	if rs.Cause != nil {
+		return fmt.Sprintf("raise %s from %s", rs.Error.String(), rs.Cause.String())
+	}
 	return fmt.Sprintf("raise %s", rs.Error.String())
 }
 
@@ -423,19 +546,31 @@ func (is *IgnoreStatement) String() string       { return "ignore" }
 
 type StopStatement struct {
 	Token token.Token
+	Label *Identifier // Loop to stop; nil means the innermost loop
 }
 
 func (ss *StopStatement) statementNode()       {}
 func (ss *StopStatement) TokenLiteral() string { return ss.Token.Literal }
-func (ss *StopStatement) String() string       { return "stop" }
+func (ss *StopStatement) String() string {
+	if ss.Label != nil {
+		return "stop " + ss.Label.String()
+	}
+	return "stop"
+}
 
 type SkipStatement struct {
 	Token token.Token
+	Label *Identifier // Loop to continue; nil means the innermost loop
 }
 
 func (s *SkipStatement) statementNode()       {}
 func (s *SkipStatement) TokenLiteral() string { return s.Token.Literal }
-func (s *SkipStatement) String() string       { return "skip" }
+func (s *SkipStatement) String() string {
+	if s.Label != nil {
+		return "skip " + s.Label.String()
+	}
+	return "skip"
+}
 
 type CheckStatement struct {
 	Token     token.Token
@@ -456,3 +591,56 @@ func (cs *CheckStatement) String() string {
 	}
 	return out.String()
 }
+
+// ExportStatement lists the names a module offers to programs that import
+// it, in place of all its public top-level names.
+type ExportStatement struct {
+	Token token.Token // The 'export' token
+	Names []*Identifier
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) String() string {
+	names := make([]string, len(es.Names))
+	for i, name := range es.Names {
+		names[i] = name.String()
+	}
+	return "export " + strings.Join(names, ", ")
+}
+
+// PragmaStatement is a `pragma name` line at the top of a file, which
+// changes how the rest of the file is parsed and run. The parser applies
+// it, so the evaluator has nothing left to do.
+type PragmaStatement struct {
+	Token token.Token // The 'pragma' token
+	Name  *Identifier
+	Value Expression // such as the version of `pragma carrion_version "1.0"`, or nil
+}
+
+func (ps *PragmaStatement) statementNode()       {}
+func (ps *PragmaStatement) TokenLiteral() string { return ps.Token.Literal }
+func (ps *PragmaStatement) String() string {
+	if ps.Value != nil {
+		return "pragma " + ps.Name.String() + " " + ps.Value.String()
+	}
+	return "pragma " + ps.Name.String()
+}
+
+// ScopeStatement declares names that assignments in the current spell should
+// update in another scope: `global x` targets the module scope and `outer x`
+// the nearest enclosing scope that already defines x.
+type ScopeStatement struct {
+	Token token.Token // The 'global' or 'outer' token
+	Names []*Identifier
+}
+
+func (ss *ScopeStatement) statementNode()       {}
+func (ss *ScopeStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *ScopeStatement) String() string {
+	names := make([]string, len(ss.Names))
+	for i, name := range ss.Names {
+		names[i] = name.String()
+	}
+	return ss.Token.Literal + " " + strings.Join(names, ", ")
+}
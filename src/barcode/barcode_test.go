@@ -0,0 +1,201 @@
+package barcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// The codewords of "HELLO WORLD" at version 1-M and their error
+	// correction codewords, from the worked example in the standard.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	expected := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestEncodeQR(t *testing.T) {
+	tests := []struct {
+		data    string
+		level   Level
+		version int
+	}{
+		{"hello", Medium, 1},
+		{"https://example.com/events/2026/tickets?id=1234", Low, 3},
+		{"https://example.com/events/2026/tickets?id=1234", High, 6},
+		{strings.Repeat("carrion ", 40), Quartile, 16},
+		{strings.Repeat("0123456789", 200), Low, 33},
+	}
+	for _, tt := range tests {
+		q, err := EncodeQR([]byte(tt.data), tt.level)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.data, err)
+		}
+		if q.Version != tt.version || q.Size != tt.version*4+17 {
+			t.Errorf("%q: expected version %d, got %d", tt.data, tt.version, q.Version)
+		}
+		if got := readQR(t, q); got != tt.data {
+			t.Errorf("expected to read back %q, got %q", tt.data, got)
+		}
+	}
+	if _, err := EncodeQR(make([]byte, 2954), Low); err != ErrTooLong {
+		t.Errorf("expected ErrTooLong, got %v", err)
+	}
+}
+
+// readQR reads a code back: it checks the format information, removes the
+// mask, checks that every block's error correction codewords are right and
+// decodes the byte mode data.
+func readQR(t *testing.T, q *QRCode) string {
+	t.Helper()
+	var format int
+	for i := 14; i >= 9; i-- {
+		format = format<<1 | bit(q.Dark(14-i, 8))
+	}
+	format = format<<1 | bit(q.Dark(7, 8))
+	format = format<<1 | bit(q.Dark(8, 8))
+	format = format<<1 | bit(q.Dark(8, 7))
+	for i := 5; i >= 0; i-- {
+		format = format<<1 | bit(q.Dark(8, i))
+	}
+	format ^= 0x5412
+	if level, mask := format>>13, format>>10&7; level != formatBits[q.Level] || mask != q.Mask {
+		t.Fatalf("format information gives level %d and mask %d", level, mask)
+	}
+	if !q.Dark(8, q.Size-8) {
+		t.Errorf("the dark module is light")
+	}
+
+	q.applyMask(q.Mask)
+	defer q.applyMask(q.Mask)
+	codewords := make([]byte, rawModules(q.Version)/8)
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.Size; vert++ {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = q.Size - 1 - vert
+			}
+			for x := right; x >= right-1; x-- {
+				if !q.function[y][x] && i < len(codewords)*8 {
+					codewords[i/8] |= byte(bit(q.modules[y][x])) << (7 - i%8)
+					i++
+				}
+			}
+		}
+	}
+
+	numBlocks := eccBlocks[q.Level][q.Version]
+	eccLen := eccPerBlock[q.Level][q.Version]
+	numShort := numBlocks - len(codewords)%numBlocks
+	shortLen := len(codewords)/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	next := 0
+	for i := 0; i <= shortLen; i++ {
+		for j := range blocks {
+			if i < shortLen || j >= numShort {
+				blocks[j] = append(blocks[j], codewords[next])
+				next++
+			}
+		}
+	}
+	var data []byte
+	for j, block := range blocks {
+		ecc := codewords[next+j:]
+		for k := 0; k < eccLen; k++ {
+			if ecc[k*numBlocks] != rsRemainder(block, rsDivisor(eccLen))[k] {
+				t.Fatalf("block %d has the wrong error correction", j)
+			}
+		}
+		data = append(data, block...)
+	}
+
+	var bits bitBuffer
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	read := func(n int) int {
+		v := 0
+		for _, b := range bits[:n] {
+			v = v<<1 | bit(b)
+		}
+		bits = bits[n:]
+		return v
+	}
+	if mode := read(4); mode != 4 {
+		t.Fatalf("expected byte mode, got mode %d", mode)
+	}
+	text := make([]byte, read(countBits(q.Version)))
+	for i := range text {
+		text[i] = byte(read(8))
+	}
+	return string(text)
+}
+
+func bit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestFixedPatterns(t *testing.T) {
+	q, err := EncodeQR([]byte("codes from version seven on carry their version information"), High)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Version != 7 {
+		t.Fatalf("expected version 7, got %d", q.Version)
+	}
+	// Version 7's information is 000111110010010100, written least
+	// significant bit first in columns of three.
+	var bits int
+	for i := 17; i >= 0; i-- {
+		bits = bits<<1 | bit(q.Dark(q.Size-11+i%3, i/3))
+	}
+	if bits != 0x7C94 {
+		t.Errorf("expected version information 0x7C94, got %#x", bits)
+	}
+	for _, c := range [][2]int{{3, 3}, {q.Size - 4, 3}, {3, q.Size - 4}} {
+		if !q.Dark(c[0], c[1]) || q.Dark(c[0]+2, c[1]) || !q.Dark(c[0]+3, c[1]) {
+			t.Errorf("expected a finder pattern centred on %v", c)
+		}
+	}
+	for _, c := range [][2]int{{22, 6}, {6, 22}, {22, 22}, {38, 22}, {22, 38}} {
+		if !q.Dark(c[0], c[1]) || q.Dark(c[0]+1, c[1]) || !q.Dark(c[0]+2, c[1]) {
+			t.Errorf("expected an alignment pattern centred on %v", c)
+		}
+	}
+}
+
+func TestCode128(t *testing.T) {
+	for i, w := range code128Widths {
+		sum := 0
+		for _, c := range w {
+			sum += int(c - '0')
+		}
+		if i < code128Stop && sum != 11 || i == code128Stop && sum != 13 {
+			t.Errorf("symbol %d is %d modules wide", i, sum)
+		}
+	}
+
+	bars, err := EncodeCode128("Carrion-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Start, nine characters, check symbol and stop.
+	if len(bars) != 11*11+13 {
+		t.Errorf("expected %d modules, got %d", 11*11+13, len(bars))
+	}
+	if digits, _ := EncodeCode128("012345"); len(digits) != 5*11+13 {
+		t.Errorf("expected digits to be packed in pairs, got %d modules", len(digits))
+	}
+	if _, err := EncodeCode128("café"); err == nil {
+		t.Errorf("expected an error for a character outside ASCII")
+	}
+}
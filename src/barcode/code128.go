@@ -0,0 +1,79 @@
+package barcode
+
+import (
+	"fmt"
+)
+
+// code128Widths are the widths of the bars and spaces of each Code 128
+// symbol, starting with a bar. Symbols 103 to 105 start a barcode in code
+// set A, B or C, and 106 ends it.
+var code128Widths = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+)
+
+// Bars is a one-dimensional barcode as a row of modules, true for a bar.
+type Bars []bool
+
+// EncodeCode128 encodes text as a Code 128 barcode. Text of printable
+// ASCII characters is written in code set B; an even number of digits is
+// written in code set C, which packs two digits into each symbol.
+func EncodeCode128(text string) (Bars, error) {
+	if text == "" {
+		return nil, fmt.Errorf("nothing to encode")
+	}
+	var symbols []int
+	if len(text)%2 == 0 && allDigits(text) {
+		symbols = append(symbols, code128StartC)
+		for i := 0; i < len(text); i += 2 {
+			symbols = append(symbols, int(text[i]-'0')*10+int(text[i+1]-'0'))
+		}
+	} else {
+		symbols = append(symbols, code128StartB)
+		for i := 0; i < len(text); i++ {
+			if text[i] < ' ' || text[i] > '~' {
+				return nil, fmt.Errorf("Code 128 can only encode printable ASCII characters, not %q", text[i])
+			}
+			symbols = append(symbols, int(text[i]-' '))
+		}
+	}
+	check := symbols[0]
+	for i, s := range symbols[1:] {
+		check += (i + 1) * s
+	}
+	symbols = append(symbols, check%103, code128Stop)
+
+	var bars Bars
+	for _, s := range symbols {
+		for i, w := range code128Widths[s] {
+			for n := 0; n < int(w-'0'); n++ {
+				bars = append(bars, i%2 == 0)
+			}
+		}
+	}
+	return bars, nil
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package barcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Image draws the code in black on white with each module scale pixels
+// square, inside a light border the given number of modules wide. Readers
+// expect a border of at least 4.
+func (q *QRCode) Image(scale, border int) *image.Gray {
+	side := (q.Size + 2*border) * scale
+	img := blank(side, side)
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if q.modules[y][x] {
+				fill(img, (x+border)*scale, (y+border)*scale, scale, scale)
+			}
+		}
+	}
+	return img
+}
+
+// Image draws the barcode in black on white with each module scale pixels
+// wide and the bars height pixels tall, between light borders the given
+// number of modules wide. Readers expect a border of at least 10.
+func (b Bars) Image(scale, height, border int) *image.Gray {
+	img := blank((len(b)+2*border)*scale, height)
+	for x, bar := range b {
+		if bar {
+			fill(img, (x+border)*scale, 0, scale, height)
+		}
+	}
+	return img
+}
+
+// PNG encodes an image as PNG.
+func PNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func blank(w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	return img
+}
+
+func fill(img *image.Gray, x, y, w, h int) {
+	for yy := y; yy < y+h; yy++ {
+		for xx := x; xx < x+w; xx++ {
+			img.SetGray(xx, yy, color.Gray{})
+		}
+	}
+}
@@ -0,0 +1,503 @@
+// Package barcode draws QR codes and Code 128 barcodes. QR codes are
+// encoded in byte mode, which holds any text as UTF-8, at the smallest
+// version from 1 to 40 that fits it, with the mask that scores best on the
+// standard's penalty rules.
+package barcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Level is how much of a QR code can be damaged and still be read.
+type Level int
+
+const (
+	Low      Level = iota // about 7% of the code
+	Medium                // about 15%
+	Quartile              // about 25%
+	High                  // about 30%
+)
+
+// formatBits are the two bits naming each level in the format information.
+var formatBits = [...]int{Low: 1, Medium: 0, Quartile: 3, High: 2}
+
+// eccPerBlock and eccBlocks give, for each level and version, the number of
+// error correction codewords in each block and the number of blocks the
+// codewords are split into. Index 0 of each row is unused.
+var eccPerBlock = [4][41]int{
+	{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var eccBlocks = [4][41]int{
+	{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// ErrTooLong is returned for data too long for a version 40 QR code at
+// the level asked for.
+var ErrTooLong = errors.New("data too long for a QR code")
+
+// QRCode is a QR code as a square of modules.
+type QRCode struct {
+	Version int
+	Level   Level
+	Mask    int
+	Size    int // the number of modules along each side, without a border
+
+	modules  [][]bool
+	function [][]bool // modules of the finder, timing and other fixed patterns
+}
+
+// Dark reports whether the module in column x of row y is dark. Modules
+// outside the code are light.
+func (q *QRCode) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < q.Size && y < q.Size && q.modules[y][x]
+}
+
+// EncodeQR encodes data as a QR code at the given level.
+func EncodeQR(data []byte, level Level) (*QRCode, error) {
+	if level < Low || level > High {
+		return nil, fmt.Errorf("unknown error correction level %d", level)
+	}
+	version := 0
+	for v := 1; v <= 40; v++ {
+		if 4+countBits(v)+8*len(data) <= 8*dataCodewords(v, level) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	var bits bitBuffer
+	bits.append(4, 4) // byte mode
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := 8 * dataCodewords(version, level)
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	q := &QRCode{Version: version, Level: level, Size: version*4 + 17}
+	q.modules = newGrid(q.Size)
+	q.function = newGrid(q.Size)
+	q.drawFunctionPatterns()
+	q.drawCodewords(addErrorCorrection(bits.bytes(), version, level))
+
+	best := -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		if penalty := q.penalty(); best < 0 || penalty < best {
+			best, q.Mask = penalty, mask
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(q.Mask)
+	q.drawFormatBits(q.Mask)
+	return q, nil
+}
+
+func newGrid(size int) [][]bool {
+	grid := make([][]bool, size)
+	for i := range grid {
+		grid[i] = make([]bool, size)
+	}
+	return grid
+}
+
+// countBits is the width of the length field for byte mode data.
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// rawModules is the number of modules of a version left for data and error
+// correction once the fixed patterns are drawn.
+func rawModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+func dataCodewords(version int, level Level) int {
+	return rawModules(version)/8 - eccPerBlock[level][version]*eccBlocks[level][version]
+}
+
+type bitBuffer []bool
+
+// append adds the n low bits of value, most significant first.
+func (b *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, value>>i&1 != 0)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	out := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			out[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return out
+}
+
+// addErrorCorrection splits the data codewords into blocks, adds each
+// block's error correction codewords and interleaves the blocks, as they
+// are laid out in the code.
+func addErrorCorrection(data []byte, version int, level Level) []byte {
+	numBlocks := eccBlocks[level][version]
+	eccLen := eccPerBlock[level][version]
+	total := rawModules(version) / 8
+	numShort := numBlocks - total%numBlocks
+	shortLen := total/numBlocks - eccLen
+
+	divisor := rsDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	eccs := make([][]byte, numBlocks)
+	for i, start := 0, 0; i < numBlocks; i++ {
+		n := shortLen
+		if i >= numShort {
+			n++
+		}
+		blocks[i] = data[start : start+n]
+		eccs[i] = rsRemainder(blocks[i], divisor)
+		start += n
+	}
+
+	out := make([]byte, 0, total)
+	for i := 0; i <= shortLen; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for _, ecc := range eccs {
+			out = append(out, ecc[i])
+		}
+	}
+	return out
+}
+
+// rsDivisor is the Reed-Solomon generator polynomial of the given degree,
+// without its leading 1 term.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return result
+}
+
+// rsRemainder gives the error correction codewords of data.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMul(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+func (q *QRCode) setFunction(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+func (q *QRCode) drawFunctionPatterns() {
+	for i := 0; i < q.Size; i++ {
+		q.setFunction(6, i, i%2 == 0)
+		q.setFunction(i, 6, i%2 == 0)
+	}
+	q.drawFinder(3, 3)
+	q.drawFinder(q.Size-4, 3)
+	q.drawFinder(3, q.Size-4)
+
+	align := alignmentPositions(q.Version)
+	last := len(align) - 1
+	for i, x := range align {
+		for j, y := range align {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format information, which is drawn once the mask is
+	// chosen.
+	q.drawFormatBits(0)
+	q.drawVersion()
+}
+
+// drawFinder draws a finder pattern centred on (x, y) with the light
+// separator around it.
+func (q *QRCode) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx >= 0 && yy >= 0 && xx < q.Size && yy < q.Size {
+				d := max(abs(dx), abs(dy))
+				q.setFunction(xx, yy, d != 2 && d != 4)
+			}
+		}
+	}
+}
+
+// alignmentPositions are the centre coordinates of a version's alignment
+// patterns along each axis.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := 26
+	if version != 32 {
+		step = (version*4 + n*2 + 1) / (n*2 - 2) * 2
+	}
+	positions := make([]int, n)
+	positions[0] = 6
+	for i, pos := n-1, version*4+10; i > 0; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+func (q *QRCode) drawFormatBits(mask int) {
+	data := formatBits[q.Level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		q.setFunction(8, i, bit(i))
+	}
+	q.setFunction(8, 7, bit(6))
+	q.setFunction(8, 8, bit(7))
+	q.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.setFunction(q.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunction(8, q.Size-15+i, bit(i))
+	}
+	q.setFunction(8, q.Size-8, true)
+}
+
+// drawVersion draws the two copies of the version information that codes
+// from version 7 carry.
+func (q *QRCode) drawVersion() {
+	if q.Version < 7 {
+		return
+	}
+	rem := q.Version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	bits := q.Version<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := bits>>i&1 != 0
+		a, b := q.Size-11+i%3, i/3
+		q.setFunction(a, b, dark)
+		q.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords lays the codewords out in the zigzag of two-module columns
+// that runs up and down the code from the bottom right, skipping the fixed
+// patterns.
+func (q *QRCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.Size; vert++ {
+			y := vert
+			if upward {
+				y = q.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if !q.function[y][x] && i < len(codewords)*8 {
+					q.modules[y][x] = codewords[i/8]>>(7-i%8)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by a mask pattern. Applying
+// the same mask twice undoes it.
+func (q *QRCode) applyMask(mask int) {
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if !q.function[y][x] && masked(mask, x, y) {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+func masked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// penalty scores the code by the standard's four rules: long runs of one
+// colour, 2x2 blocks of one colour, patterns that look like finders, and
+// an imbalance of dark and light. The mask with the lowest score is used.
+func (q *QRCode) penalty() int {
+	score := 0
+	finder := []bool{true, false, true, true, true, false, true}
+	for _, line := range q.lines() {
+		run := 1
+		for i := 1; i <= len(line); i++ {
+			if i < len(line) && line[i] == line[i-1] {
+				run++
+				continue
+			}
+			if run >= 5 {
+				score += run - 2
+			}
+			run = 1
+		}
+		for i := 0; i+len(finder) <= len(line); i++ {
+			if matches(line[i:], finder) && (lightRun(line, i-4, i) || lightRun(line, i+7, i+11)) {
+				score += 40
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			c := q.modules[y][x]
+			if c {
+				dark++
+			}
+			if x+1 < q.Size && y+1 < q.Size && c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+				score += 3
+			}
+		}
+	}
+	total := q.Size * q.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return score + k*10
+}
+
+// lines gives every row and column of the code.
+func (q *QRCode) lines() [][]bool {
+	lines := make([][]bool, 0, 2*q.Size)
+	for y := 0; y < q.Size; y++ {
+		lines = append(lines, q.modules[y])
+	}
+	for x := 0; x < q.Size; x++ {
+		column := make([]bool, q.Size)
+		for y := range column {
+			column[y] = q.modules[y][x]
+		}
+		lines = append(lines, column)
+	}
+	return lines
+}
+
+func matches(line, pattern []bool) bool {
+	for i, p := range pattern {
+		if line[i] != p {
+			return false
+		}
+	}
+	return true
+}
+
+// lightRun reports whether line[from:to] is all light, counting modules
+// past either end as light, as the quiet zone around the code is.
+func lightRun(line []bool, from, to int) bool {
+	for i := from; i < to; i++ {
+		if i >= 0 && i < len(line) && line[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
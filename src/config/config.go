@@ -0,0 +1,279 @@
+// Package config loads the user's settings for the carrion command, which
+// supply the defaults for its command-line options. They are read from
+// config.toml in the carrion directory of the user's config directory,
+// usually ~/.config/carrion/config.toml:
+//
+//	# Settings for the carrion command.
+//	color = "never"
+//	prompt = "crow> "
+//	search_path = ["~/carrion/lib", "/opt/carrion"]
+//	strict_types = true
+//	error_format = "short"
+//
+// Only the part of TOML that these settings need is understood: one
+// `key = value` per line, where a value is a string, a boolean or an array
+// of strings, and # comments.
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PathEnv names the environment variable that points at a config file to
+// use instead of the default one.
+const PathEnv = "CARRION_CONFIG"
+
+// Color modes.
+const (
+	ColorAuto   = "auto" // color when writing to a terminal
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// Config is the user's settings.
+type Config struct {
+	Color         string   // one of the color modes
+	Prompt        string   // the REPL's prompt
+	SearchPath    []string // directories searched for imports after CARRION_PATH
+	StrictTypes   bool     // check type hints
+	CoerceStrings bool     // let + join a string and a number
+	StrictMatch   bool     // raise a MatchError when no case of a match fits
+	ErrorFormat   string   // how uncaught errors are shown; "" for pretty or plain by color
+}
+
+// Default returns the settings used when there is no config file.
+func Default() Config {
+	return Config{Color: ColorAuto, Prompt: ">>> "}
+}
+
+// Path returns where the config file is read from: $CARRION_CONFIG, or
+// carrion/config.toml in the user's config directory. It is "" if there is
+// no config directory.
+func Path() string {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "carrion", "config.toml")
+}
+
+// Load reads the config file. A missing file is not an error; it leaves the
+// defaults in place.
+func Load() (Config, error) {
+	path := Path()
+	if path == "" {
+		return Default(), nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Default(), nil
+	}
+	if err != nil {
+		return Default(), err
+	}
+	return Parse(path, data)
+}
+
+// Parse reads the settings in data, the contents of the file at path, over
+// the defaults. Relative and ~ directories in search_path are made absolute.
+func Parse(path string, data []byte) (Config, error) {
+	c := Default()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		key, raw, ok := strings.Cut(text, "=")
+		if !ok {
+			return c, fmt.Errorf("%s:%d: expected key = value", path, line)
+		}
+		if err := c.set(strings.TrimSpace(key), strings.TrimSpace(raw), filepath.Dir(path)); err != nil {
+			return c, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+	}
+	return c, scanner.Err()
+}
+
+func (c *Config) set(key, raw, dir string) error {
+	switch key {
+	case "color":
+		mode, err := parseString(raw)
+		if err != nil {
+			return err
+		}
+		return c.SetColor(mode)
+	case "prompt":
+		prompt, err := parseString(raw)
+		if err != nil {
+			return err
+		}
+		c.Prompt = prompt
+	case "search_path":
+		dirs, err := parseStrings(raw)
+		if err != nil {
+			return err
+		}
+		c.SearchPath = c.SearchPath[:0]
+		for _, d := range dirs {
+			c.SearchPath = append(c.SearchPath, expandDir(d, dir))
+		}
+	case "strict_types":
+		strict, err := parseBool(raw)
+		if err != nil {
+			return err
+		}
+		c.StrictTypes = strict
+	case "coerce_strings":
+		coerce, err := parseBool(raw)
+		if err != nil {
+			return err
+		}
+		c.CoerceStrings = coerce
+	case "strict_match":
+		strict, err := parseBool(raw)
+		if err != nil {
+			return err
+		}
+		c.StrictMatch = strict
+	case "error_format":
+		format, err := parseString(raw)
+		if err != nil {
+			return err
+		}
+		c.ErrorFormat = format
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	return nil
+}
+
+// SetColor changes the color mode, rejecting anything but the three modes.
+func (c *Config) SetColor(mode string) error {
+	switch mode {
+	case ColorAuto, ColorAlways, ColorNever:
+		c.Color = mode
+		return nil
+	}
+	return fmt.Errorf("color must be %q, %q or %q, got %q", ColorAuto, ColorAlways, ColorNever, mode)
+}
+
+// UseColor reports whether output to f should be colored.
+func (c Config) UseColor(f *os.File) bool {
+	switch c.Color {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// expandDir resolves ~ and directories relative to the config file.
+func expandDir(d, base string) string {
+	if rest, ok := strings.CutPrefix(d, "~"); ok && (rest == "" || rest[0] == '/' || rest[0] == filepath.Separator) {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if filepath.IsAbs(d) {
+		return filepath.Clean(d)
+	}
+	return filepath.Join(base, d)
+}
+
+func parseString(raw string) (string, error) {
+	value, rest, err := cutString(raw)
+	if err != nil {
+		return "", err
+	}
+	if !isComment(rest) {
+		return "", fmt.Errorf("unexpected %q after string", rest)
+	}
+	return value, nil
+}
+
+// cutString reads the string at the start of raw, returning it and what
+// follows. Basic strings take Go escapes; 'literal' strings take none.
+func cutString(raw string) (string, string, error) {
+	if raw == "" || (raw[0] != '"' && raw[0] != '\'') {
+		return "", "", fmt.Errorf("expected a quoted string, got %q", raw)
+	}
+	if raw[0] == '\'' {
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %s", raw)
+		}
+		return raw[1 : end+1], strings.TrimSpace(raw[end+2:]), nil
+	}
+	for i := 1; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err := strconv.Unquote(raw[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid string %s", raw[:i+1])
+			}
+			return value, strings.TrimSpace(raw[i+1:]), nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string %s", raw)
+}
+
+func parseStrings(raw string) ([]string, error) {
+	rest, ok := strings.CutPrefix(raw, "[")
+	if !ok {
+		return nil, fmt.Errorf("expected an array of strings, got %q", raw)
+	}
+	var values []string
+	for {
+		rest = strings.TrimSpace(rest)
+		if after, ok := strings.CutPrefix(rest, "]"); ok {
+			if after = strings.TrimSpace(after); !isComment(after) {
+				return nil, fmt.Errorf("unexpected %q after array", after)
+			}
+			return values, nil
+		}
+		value, after, err := cutString(rest)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		rest, ok = strings.CutPrefix(after, ",")
+		if !ok && !strings.HasPrefix(after, "]") {
+			return nil, fmt.Errorf("expected , or ] in array, got %q", after)
+		}
+		if !ok {
+			rest = after
+		}
+	}
+}
+
+func parseBool(raw string) (bool, error) {
+	raw, _, _ = strings.Cut(raw, "#")
+	switch strings.TrimSpace(raw) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected true or false, got %q", strings.TrimSpace(raw))
+}
+
+func isComment(rest string) bool {
+	return rest == "" || rest[0] == '#'
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	input := `# settings
+color = "never"
+prompt = 'crow> ' # literal
+search_path = ["lib", "~/shared", "/opt/carrion" ] # dirs
+strict_types = true
+coerce_strings = true
+strict_match = true
+error_format = "json"
+`
+	c, err := Parse("/etc/carrion/config.toml", []byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Config{
+		Color:         ColorNever,
+		Prompt:        "crow> ",
+		SearchPath:    []string{"/etc/carrion/lib", filepath.Join(home, "shared"), "/opt/carrion"},
+		StrictTypes:   true,
+		CoerceStrings: true,
+		StrictMatch:   true,
+		ErrorFormat:   "json",
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("expected %+v, got %+v", want, c)
+	}
+
+	if c, err := Parse("config.toml", []byte("prompt = \"\\u263e \"\nsearch_path = []\n")); err != nil || c.Prompt != "☾ " || len(c.SearchPath) != 0 || c.Color != ColorAuto {
+		t.Errorf("got %+v, %v", c, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{"color", "config.toml:1: expected key = value"},
+		{"\ncolour = \"never\"", `config.toml:2: unknown setting "colour"`},
+		{`color = "rainbow"`, `config.toml:1: color must be "auto", "always" or "never", got "rainbow"`},
+		{`prompt = crow`, `config.toml:1: expected a quoted string, got "crow"`},
+		{`prompt = "crow`, `config.toml:1: unterminated string "crow`},
+		{`prompt = "a" "b"`, `config.toml:1: unexpected "\"b\"" after string`},
+		{`strict_types = yes`, `config.toml:1: expected true or false, got "yes"`},
+		{`search_path = "lib"`, `config.toml:1: expected an array of strings, got "\"lib\""`},
+		{`search_path = ["a" "b"]`, `config.toml:1: expected , or ] in array, got "\"b\"]"`},
+	}
+	for _, tt := range tests {
+		if _, err := Parse("config.toml", []byte(tt.input)); err == nil || err.Error() != tt.message {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.message, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(PathEnv, filepath.Join(dir, "missing.toml"))
+	if c, err := Load(); err != nil || !reflect.DeepEqual(c, Default()) {
+		t.Errorf("expected the defaults for a missing file, got %+v, %v", c, err)
+	}
+
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte("strict_types = true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(PathEnv, path)
+	if c, err := Load(); err != nil || !c.StrictTypes {
+		t.Errorf("expected strict types from %s, got %+v, %v", path, c, err)
+	}
+}
@@ -0,0 +1,188 @@
+// Package daemon keeps a carrion process running in the background to run
+// scripts for a thin client, so that editor integrations and commands run
+// many times in a row do not pay for starting an interpreter each time.
+// The daemon keeps the standard library and imported modules parsed
+// between runs, while every script still runs in a fresh interpreter.
+//
+// Client and daemon talk over a Unix socket, one JSON object per line. The
+// client sends a Request; the daemon answers with Messages carrying the
+// script's output as it is written, then one with its exit status.
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Request asks the daemon to run the script File, with Dir as the working
+// directory, or to shut down when Stop is set.
+type Request struct {
+	File string `json:"file,omitempty"`
+	Dir  string `json:"dir,omitempty"`
+	Stop bool   `json:"stop,omitempty"`
+}
+
+// Message is one line of the daemon's answer: output the script wrote to
+// Stream, "stdout" or "stderr", or, last of all, its Exit status.
+type Message struct {
+	Stream string `json:"stream,omitempty"`
+	Data   string `json:"data,omitempty"`
+	Exit   *int   `json:"exit,omitempty"`
+}
+
+// RunFunc runs the script file in a fresh interpreter, writing its output
+// to stdout and stderr, and returns its exit status.
+type RunFunc func(file string, stdout, stderr io.Writer) int
+
+// DefaultSocket is where the daemon listens unless told otherwise: a
+// socket per user in the temporary directory.
+func DefaultSocket() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("carrion-%d.sock", os.Getuid()))
+}
+
+// Listen listens on the socket at path, replacing a stale socket left by a
+// daemon that did not shut down cleanly. It fails if a daemon is already
+// listening there.
+func Listen(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	os.Remove(path)
+	return net.Listen("unix", path)
+}
+
+// Serve runs scripts for clients connecting to ln with run until a client
+// asks it to stop. Scripts run one at a time, each in the working directory
+// its client was in, so the daemon changes directory for each one.
+func Serve(ln net.Listener, run RunFunc) error {
+	defer ln.Close()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		if stop := serveConn(conn, run); stop {
+			return nil
+		}
+	}
+}
+
+func serveConn(conn net.Conn, run RunFunc) (stop bool) {
+	defer conn.Close()
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return false
+	}
+	out := &messageWriter{enc: json.NewEncoder(conn)}
+	if req.Stop {
+		out.exit(0)
+		return true
+	}
+
+	status := 1
+	if wd, err := os.Getwd(); err != nil {
+		fmt.Fprintf(out.stream("stderr"), "carrion daemon: %v\n", err)
+	} else if err := os.Chdir(req.Dir); err != nil {
+		fmt.Fprintf(out.stream("stderr"), "carrion daemon: %v\n", err)
+	} else {
+		status = run(req.File, out.stream("stdout"), out.stream("stderr"))
+		os.Chdir(wd)
+	}
+	out.exit(status)
+	return false
+}
+
+// messageWriter sends output to the client as Messages, one per write.
+type messageWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (m *messageWriter) send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.enc.Encode(msg)
+}
+
+func (m *messageWriter) exit(status int) {
+	m.send(Message{Exit: &status})
+}
+
+func (m *messageWriter) stream(name string) io.Writer {
+	return streamWriter{m, name}
+}
+
+type streamWriter struct {
+	m    *messageWriter
+	name string
+}
+
+func (s streamWriter) Write(p []byte) (int, error) {
+	if err := s.m.send(Message{Stream: s.name, Data: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ErrNotRunning is returned by Run and Stop when no daemon is listening.
+var ErrNotRunning = errors.New("no carrion daemon is running")
+
+// Run has the daemon listening at socket run the script file, copying its
+// output to stdout and stderr, and returns the script's exit status.
+func Run(socket, file string, stdout, stderr io.Writer) (int, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return 0, err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return 0, err
+	}
+	return request(socket, Request{File: abs, Dir: dir}, stdout, stderr)
+}
+
+// Stop asks the daemon listening at socket to shut down.
+func Stop(socket string) error {
+	_, err := request(socket, Request{Stop: true}, io.Discard, io.Discard)
+	return err
+}
+
+func request(socket string, req Request, stdout, stderr io.Writer) (int, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return 0, ErrNotRunning
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return 0, fmt.Errorf("bad message from daemon: %v", err)
+		}
+		switch {
+		case msg.Exit != nil:
+			return *msg.Exit, nil
+		case msg.Stream == "stderr":
+			io.WriteString(stderr, msg.Data)
+		default:
+			io.WriteString(stdout, msg.Data)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("daemon closed the connection before the script finished")
+}
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := os.MkdirTemp("", "daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "d.sock")
+
+	ln, err := Listen(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(socket); err == nil {
+		t.Error("expected a second daemon on the same socket to fail")
+	}
+	served := make(chan error)
+	go func() {
+		served <- Serve(ln, func(file string, stdout, stderr io.Writer) int {
+			wd, _ := os.Getwd()
+			fmt.Fprintf(stdout, "ran %s in %s\n", filepath.Base(file), filepath.Base(wd))
+			fmt.Fprintln(stderr, "oops")
+			return 3
+		})
+	}()
+
+	wd, _ := os.Getwd()
+	var stdout, stderr bytes.Buffer
+	status, err := Run(socket, "main.crl", &stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 3 || stdout.String() != "ran main.crl in "+filepath.Base(wd)+"\n" || stderr.String() != "oops\n" {
+		t.Errorf("got status %d, stdout %q, stderr %q", status, stdout.String(), stderr.String())
+	}
+	if now, _ := os.Getwd(); now != wd {
+		t.Errorf("the daemon stayed in %s", now)
+	}
+
+	if err := Stop(socket); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+	if _, err := Run(socket, "main.crl", io.Discard, io.Discard); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("expected ErrNotRunning once stopped, got %v", err)
+	}
+}
@@ -0,0 +1,378 @@
+// Package debugger implements `carrion debug`, an interactive step debugger.
+// It pauses a running program at breakpoints and while stepping, then reads
+// commands that inspect variables, evaluate expressions and resume.
+package debugger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/evaluator"
+	"github.com/javanhut/Carrion/src/lexer"
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/parser"
+	"github.com/javanhut/Carrion/src/token"
+)
+
+// ErrQuit stops the program when the user quits the debugger.
+var ErrQuit = errors.New("debugger: program stopped")
+
+const help = `Commands:
+  s, step           run to the next statement, entering calls
+  n, next           run to the next statement in this spell, stepping over calls
+  o, out            run until the current spell returns
+  c, continue       run until a breakpoint is reached
+  b, break [LOC]    set a breakpoint at file:line or line; list breakpoints
+  clear LOC         remove the breakpoint at file:line or line
+  p, print EXPR     evaluate EXPR in the current scope
+  vars              show the variables in scope
+  bt, where         show the call stack
+  l, list           show the source around the current line
+  q, quit           stop the program
+An empty line repeats the last command.`
+
+type mode int
+
+const (
+	modeContinue mode = iota // run until a breakpoint
+	modeStep                 // pause at the next statement
+	modeNext                 // pause at the next statement no deeper than depth
+	modeOut                  // pause at the next statement shallower than depth
+)
+
+// Breakpoint is a source line to pause at.
+type Breakpoint struct {
+	File string
+	Line int
+}
+
+func (bp Breakpoint) String() string {
+	return fmt.Sprintf("%s:%d", bp.File, bp.Line)
+}
+
+// ParseBreakpoint parses `file:line`, or a bare line number in file.
+func ParseBreakpoint(spec, file string) (Breakpoint, error) {
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		file, spec = spec[:i], spec[i+1:]
+	}
+	line, err := strconv.Atoi(spec)
+	if err != nil || line < 1 {
+		return Breakpoint{}, fmt.Errorf("invalid breakpoint %q: want file:line or line", spec)
+	}
+	return Breakpoint{File: file, Line: line}, nil
+}
+
+// matches reports whether the breakpoint is set on pos. A breakpoint whose
+// file has no directory matches that file name in any directory.
+func (bp Breakpoint) matches(pos token.Position) bool {
+	if bp.Line != pos.Line {
+		return false
+	}
+	if bp.File == pos.File {
+		return true
+	}
+	if filepath.Base(bp.File) == bp.File {
+		return filepath.Base(pos.File) == bp.File
+	}
+	a, err1 := filepath.Abs(bp.File)
+	b, err2 := filepath.Abs(pos.File)
+	return err1 == nil && err2 == nil && a == b
+}
+
+// Debugger pauses the interpreter it is attached to and talks to the user
+// through in and out.
+type Debugger struct {
+	in          *bufio.Scanner
+	out         io.Writer
+	breakpoints []Breakpoint
+	mode        mode
+	depth       int
+	pos         token.Position // where the program is paused
+	last        string         // the last command, repeated by an empty line
+	quit        bool
+	evaluating  bool                // running a print command
+	hidden      map[string]bool     // globals defined before the program ran
+	sources     map[string][]string // source lines by file, read on demand
+}
+
+// New attaches a debugger to the interpreter that owns env, which should
+// already have the standard library loaded: the globals env holds now are
+// left out when showing variables. The program pauses before its first
+// statement.
+func New(env *object.Environment, in io.Reader, out io.Writer) *Debugger {
+	d := &Debugger{
+		in:      bufio.NewScanner(in),
+		out:     out,
+		mode:    modeStep,
+		hidden:  make(map[string]bool),
+		sources: make(map[string][]string),
+	}
+	for _, name := range env.GetNames() {
+		d.hidden[name] = true
+	}
+	ctx, ok := env.Context().(*evaluator.EvalContext)
+	if !ok {
+		ctx = evaluator.NewEvalContext("")
+		env.SetContext(ctx)
+	}
+	ctx.SetDebugger(d)
+	return d
+}
+
+// Break sets a breakpoint.
+func (d *Debugger) Break(bp Breakpoint) {
+	for _, existing := range d.breakpoints {
+		if existing == bp {
+			return
+		}
+	}
+	d.breakpoints = append(d.breakpoints, bp)
+}
+
+// Quit reports whether the user stopped the program.
+func (d *Debugger) Quit() bool {
+	return d.quit
+}
+
+// Step implements evaluator.Debugger.
+func (d *Debugger) Step(stmt ast.Statement, env *object.Environment, ctx *evaluator.EvalContext) error {
+	if d.quit {
+		return ErrQuit
+	}
+	if d.evaluating {
+		return nil
+	}
+	pos := ast.StartPosition(stmt)
+	depth := ctx.Depth()
+	switch {
+	case d.mode == modeStep:
+	case d.mode == modeNext && depth <= d.depth:
+	case d.mode == modeOut && depth < d.depth:
+	case d.atBreakpoint(pos):
+	default:
+		return nil
+	}
+
+	d.pos = pos
+	fmt.Fprintf(d.out, "> %s\n", pos)
+	if text, ok := d.sourceLine(pos.File, pos.Line); ok {
+		fmt.Fprintf(d.out, "%5d  %s\n", pos.Line, text)
+	} else {
+		fmt.Fprintf(d.out, "       %s\n", strings.SplitN(stmt.String(), "\n", 2)[0])
+	}
+	return d.prompt(env, ctx, depth)
+}
+
+func (d *Debugger) atBreakpoint(pos token.Position) bool {
+	for _, bp := range d.breakpoints {
+		if bp.matches(pos) {
+			return true
+		}
+	}
+	return false
+}
+
+// prompt reads commands until one of them resumes the program.
+func (d *Debugger) prompt(env *object.Environment, ctx *evaluator.EvalContext, depth int) error {
+	for {
+		fmt.Fprint(d.out, "(crow) ")
+		if !d.in.Scan() {
+			fmt.Fprintln(d.out)
+			d.quit = true
+			return ErrQuit
+		}
+		line := strings.TrimSpace(d.in.Text())
+		if line == "" {
+			line = d.last
+		}
+		d.last = line
+		cmd, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+
+		switch cmd {
+		case "":
+		case "s", "step":
+			d.mode = modeStep
+			return nil
+		case "n", "next":
+			d.mode, d.depth = modeNext, depth
+			return nil
+		case "o", "out":
+			d.mode, d.depth = modeOut, depth
+			return nil
+		case "c", "continue":
+			d.mode = modeContinue
+			return nil
+		case "b", "break":
+			if arg == "" {
+				d.listBreakpoints()
+				continue
+			}
+			bp, err := ParseBreakpoint(arg, d.pos.File)
+			if err != nil {
+				fmt.Fprintln(d.out, err)
+				continue
+			}
+			d.Break(bp)
+			fmt.Fprintf(d.out, "breakpoint set at %s\n", bp)
+		case "clear":
+			d.clear(arg)
+		case "p", "print":
+			d.print(arg, env)
+		case "vars":
+			d.vars(env)
+		case "bt", "where":
+			d.backtrace(ctx)
+		case "l", "list":
+			d.list()
+		case "h", "help":
+			fmt.Fprintln(d.out, help)
+		case "q", "quit":
+			d.quit = true
+			return ErrQuit
+		default:
+			fmt.Fprintf(d.out, "unknown command %q, type help for a list\n", cmd)
+		}
+	}
+}
+
+func (d *Debugger) listBreakpoints() {
+	if len(d.breakpoints) == 0 {
+		fmt.Fprintln(d.out, "no breakpoints")
+		return
+	}
+	for _, bp := range d.breakpoints {
+		fmt.Fprintln(d.out, bp)
+	}
+}
+
+func (d *Debugger) clear(arg string) {
+	bp, err := ParseBreakpoint(arg, d.pos.File)
+	if err != nil {
+		fmt.Fprintln(d.out, err)
+		return
+	}
+	for i, existing := range d.breakpoints {
+		if existing == bp {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			fmt.Fprintf(d.out, "breakpoint cleared at %s\n", bp)
+			return
+		}
+	}
+	fmt.Fprintf(d.out, "no breakpoint at %s\n", bp)
+}
+
+// print evaluates an expression in the paused scope. The debugger does not
+// pause inside it, even if it calls a spell with a breakpoint.
+func (d *Debugger) print(expr string, env *object.Environment) {
+	p := parser.New(lexer.New(expr, "<debug>"))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintln(d.out, msg)
+		}
+		return
+	}
+	d.evaluating = true
+	result := evaluator.SafeEval(program, env)
+	d.evaluating = false
+	if result == nil {
+		result = object.NONE
+	}
+	fmt.Fprintln(d.out, result.Inspect())
+}
+
+// vars lists the variables visible from env, innermost scope first. Names
+// hidden by an inner scope, the interpreter's own bookkeeping and the
+// globals that existed before the program started are left out.
+func (d *Debugger) vars(env *object.Environment) {
+	seen := make(map[string]bool)
+	for scope := env; scope != nil; scope = scope.GetOuter() {
+		global := scope.GetOuter() == nil
+		names := scope.GetNames()
+		sort.Strings(names)
+		for _, name := range names {
+			if seen[name] || strings.HasPrefix(name, "__") || (global && d.hidden[name]) {
+				continue
+			}
+			seen[name] = true
+			value, _ := scope.GetLocal(name)
+			fmt.Fprintf(d.out, "%s = %s\n", name, describe(value))
+		}
+	}
+}
+
+// describe shows a value on one line; spells are shown by their signature
+// rather than their whole body.
+func describe(value object.Object) string {
+	fn, ok := value.(*object.Function)
+	if !ok {
+		return value.Inspect()
+	}
+	params := make([]string, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		params[i] = param.String()
+	}
+	return "spell(" + strings.Join(params, ", ") + ")"
+}
+
+func (d *Debugger) backtrace(ctx *evaluator.EvalContext) {
+	fmt.Fprintf(d.out, "> %s\n", d.pos)
+	stack := ctx.GetCallStack()
+	for i := len(stack) - 1; i >= 0; i-- {
+		fmt.Fprintf(d.out, "  %s called at %s\n", stack[i].Function, stack[i].Position)
+	}
+}
+
+// list shows the lines around the paused one.
+func (d *Debugger) list() {
+	lines, ok := d.source(d.pos.File)
+	if !ok {
+		fmt.Fprintf(d.out, "no source for %q\n", d.pos.File)
+		return
+	}
+	from, to := max(d.pos.Line-5, 1), min(d.pos.Line+5, len(lines))
+	for n := from; n <= to; n++ {
+		marker := "  "
+		if n == d.pos.Line {
+			marker = "->"
+		}
+		fmt.Fprintf(d.out, "%s%4d  %s\n", marker, n, lines[n-1])
+	}
+}
+
+func (d *Debugger) sourceLine(file string, line int) (string, bool) {
+	lines, ok := d.source(file)
+	if !ok || line < 1 || line > len(lines) {
+		return "", false
+	}
+	return lines[line-1], true
+}
+
+func (d *Debugger) source(file string) ([]string, bool) {
+	if file == "" {
+		return nil, false
+	}
+	if lines, ok := d.sources[file]; ok {
+		return lines, lines != nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		d.sources[file] = nil
+		return nil, false
+	}
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	d.sources[file] = lines
+	return lines, true
+}
@@ -0,0 +1,122 @@
+package debugger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/Carrion/src/evaluator"
+	"github.com/javanhut/Carrion/src/lexer"
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/parser"
+	"github.com/javanhut/Carrion/src/token"
+)
+
+const program = `spell square(n):
+    result = n * n
+    return result
+
+total = 0
+for i in range(3):
+    total += square(i)
+print(total)`
+
+// debug runs program under a debugger fed with commands and returns the
+// transcript along with the debugger.
+func debug(t *testing.T, commands ...string) (string, *Debugger) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "main.crl")
+	if err := os.WriteFile(file, []byte(program), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := parser.New(lexer.New(program, file))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	ctx := evaluator.NewEvalContext(file)
+	ctx.SetStdout(&out)
+	env.SetContext(ctx)
+	if err := evaluator.LoadMuninStdlib(env); err != nil {
+		t.Fatal(err)
+	}
+	d := New(env, strings.NewReader(strings.Join(commands, "\n")+"\n"), &out)
+	evaluator.SafeEval(prog, env)
+	return strings.ReplaceAll(out.String(), file, "main.crl"), d
+}
+
+func TestBreakpointsAndInspection(t *testing.T) {
+	out, d := debug(t, "b 3", "c", "p result + 1", "vars", "bt", "clear 3", "c")
+	for _, want := range []string{
+		"> main.crl:1:1\n    1  spell square(n):\n",
+		"breakpoint set at main.crl:3",
+		"> main.crl:3:5\n    3      return result\n(crow) 1\n",
+		"n = 0\nresult = 0\ni = 0\nsquare = spell(n)\ntotal = 0\n",
+		"  square called at main.crl:7:20\n",
+		"breakpoint cleared at main.crl:3",
+		"5",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("transcript is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "print =") || d.Quit() {
+		t.Errorf("unexpected transcript:\n%s", out)
+	}
+}
+
+func TestStepping(t *testing.T) {
+	out, _ := debug(t, "n", "n", "n", "s", "n", "o", "q")
+	var stops []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "> ") || strings.HasPrefix(line, "(crow) > ") {
+			stops = append(stops, line[strings.Index(line, "> ")+2:])
+		}
+	}
+	want := []string{
+		"main.crl:1:1",
+		"main.crl:5:1", // next
+		"main.crl:6:1", // next
+		"main.crl:7:5", // next
+		"main.crl:2:5", // step into square
+		"main.crl:3:5", // next
+		"main.crl:7:5", // out, at the next iteration
+	}
+	if strings.Join(stops, " ") != strings.Join(want, " ") {
+		t.Errorf("stopped at %v, want %v\n%s", stops, want, out)
+	}
+}
+
+func TestQuit(t *testing.T) {
+	out, d := debug(t, "q")
+	if !d.Quit() {
+		t.Fatal("expected the debugger to report quitting")
+	}
+	if strings.Contains(out, "5") {
+		t.Errorf("program kept running after quit:\n%s", out)
+	}
+}
+
+func TestBreakpointMatching(t *testing.T) {
+	bp, err := ParseBreakpoint("main.crl:4", "other.crl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bp.matches(token.Position{File: filepath.Join("dir", "main.crl"), Line: 4}) {
+		t.Error("a bare file name should match in any directory")
+	}
+	if bp.matches(token.Position{File: "main.crl", Line: 5}) {
+		t.Error("breakpoint matched the wrong line")
+	}
+	if bp, _ := ParseBreakpoint("7", "other.crl"); bp != (Breakpoint{File: "other.crl", Line: 7}) {
+		t.Errorf("a bare line should use the default file, got %v", bp)
+	}
+	if _, err := ParseBreakpoint("main.crl:x", ""); err == nil {
+		t.Error("expected an error for an invalid line")
+	}
+}
@@ -0,0 +1,215 @@
+// Package errfmt renders the errors that stop a Carrion program: runtime
+// errors, which are an *object.Error, *object.CustomError or
+// *object.InternalError, and the syntax errors of a source that does not
+// parse. The carrion command chooses a Formatter by name with
+// --error-format, and programs embedding Carrion can Register their own.
+//
+// The built in formatters are "plain", the errors as Inspect shows them;
+// "pretty", the same in red for a terminal; "short", one line per error;
+// and "json", one JSON object per error for tools.
+package errfmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/parser"
+	"github.com/javanhut/Carrion/src/token"
+)
+
+// A Formatter renders errors as text, to be printed followed by a newline.
+type Formatter interface {
+	// Runtime renders an error a program raised and did not ensnare, or an
+	// internal error of the interpreter.
+	Runtime(err object.Object) string
+	// Syntax renders the syntax errors found in one source, in order.
+	Syntax(errs []parser.ParseError) string
+}
+
+var (
+	mu         sync.RWMutex
+	formatters = map[string]Formatter{
+		"plain":  Plain{},
+		"pretty": Pretty{},
+		"short":  Short{},
+		"json":   JSON{},
+	}
+)
+
+// Register makes f available as name, replacing any formatter already
+// registered under it.
+func Register(name string, f Formatter) {
+	mu.Lock()
+	defer mu.Unlock()
+	formatters[name] = f
+}
+
+// Lookup returns the formatter registered as name.
+func Lookup(name string) (Formatter, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	f, ok := formatters[name]
+	return f, ok
+}
+
+// Names returns the names of the registered formatters, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Plain renders errors as Inspect shows them, and syntax errors as
+// "Error: message", one per line.
+type Plain struct{}
+
+func (Plain) Runtime(err object.Object) string {
+	return err.Inspect()
+}
+
+func (Plain) Syntax(errs []parser.ParseError) string {
+	lines := make([]string, len(errs))
+	for i, e := range errs {
+		lines[i] = "Error: " + e.Message
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Pretty is Plain in red, for a terminal.
+type Pretty struct{}
+
+func (Pretty) Runtime(err object.Object) string {
+	return red(Plain{}.Runtime(err))
+}
+
+func (Pretty) Syntax(errs []parser.ParseError) string {
+	return red(Plain{}.Syntax(errs))
+}
+
+func red(s string) string {
+	return "\033[31m" + s + "\033[0m"
+}
+
+// Short renders each error on one line as "file:line:column: Kind:
+// message", leaving out the stack trace.
+type Short struct{}
+
+func (Short) Runtime(err object.Object) string {
+	r := Describe(err)
+	return shortLine(r.File, r.Line, r.Column, r.Kind, r.Message)
+}
+
+func (Short) Syntax(errs []parser.ParseError) string {
+	lines := make([]string, len(errs))
+	for i, e := range errs {
+		lines[i] = shortLine(e.Position.File, e.Position.Line, e.Position.Column, "SyntaxError", e.Message)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func shortLine(file string, line, column int, kind, message string) string {
+	if file == "" && line == 0 {
+		return kind + ": " + message
+	}
+	pos := token.Position{File: file, Line: line, Column: column}
+	return fmt.Sprintf("%s: %s: %s", pos, kind, message)
+}
+
+// JSON renders each error as a JSON object on its own line, with the
+// fields of Report.
+type JSON struct{}
+
+func (JSON) Runtime(err object.Object) string {
+	return encode(Describe(err))
+}
+
+func (JSON) Syntax(errs []parser.ParseError) string {
+	lines := make([]string, len(errs))
+	for i, e := range errs {
+		lines[i] = encode(Report{
+			Kind:     "SyntaxError",
+			Message:  e.Message,
+			File:     e.Position.File,
+			Line:     e.Position.Line,
+			Column:   e.Position.Column,
+			Expected: e.Expected,
+			Found:    e.Found,
+		})
+	}
+	return strings.Join(lines, "\n")
+}
+
+func encode(r Report) string {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Sprintf(`{"kind":"InternalError","message":%q}`, err.Error())
+	}
+	return string(data)
+}
+
+// Report is what an error says, whatever its kind, for formatters that do
+// not show its Inspect text.
+type Report struct {
+	Kind        string   `json:"kind"` // "Error", "InternalError", "SyntaxError" or the name of a custom error
+	Message     string   `json:"message"`
+	File        string   `json:"file,omitempty"`
+	Line        int      `json:"line,omitempty"`
+	Column      int      `json:"column,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+	StackTrace  []Frame  `json:"stack_trace,omitempty"` // most recent call last
+	Expected    string   `json:"expected,omitempty"`    // for a syntax error, the token type required
+	Found       string   `json:"found,omitempty"`       // and the one found instead
+	Cause       *Report  `json:"cause,omitempty"`       // the error this one was raised from with `raise ... from`
+}
+
+// Frame is a call in a Report's stack trace.
+type Frame struct {
+	Function string `json:"function"` // "" at the top level of a file
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+// Describe returns the Report for a runtime error.
+func Describe(err object.Object) Report {
+	var r Report
+	var stack []object.StackTraceEntry
+	var cause object.Object
+	switch err := err.(type) {
+	case *object.Error:
+		r = Report{Kind: "Error", Message: err.Message, Suggestions: err.Suggestions}
+		r.File, r.Line, r.Column = err.Position.File, err.Position.Line, err.Position.Column
+		stack, cause = err.StackTrace, err.Cause
+	case *object.CustomError:
+		r = Report{Kind: err.Name, Message: err.Message}
+		r.File, r.Line, r.Column = err.Position.File, err.Position.Line, err.Position.Column
+		stack, cause = err.StackTrace, err.Cause
+	case *object.InternalError:
+		r = Report{Kind: "InternalError", Message: err.Message}
+		stack = err.StackTrace
+	default:
+		return Report{Kind: "Error", Message: err.Inspect()}
+	}
+	for _, entry := range stack {
+		r.StackTrace = append(r.StackTrace, Frame{
+			Function: entry.Function,
+			File:     entry.Position.File,
+			Line:     entry.Position.Line,
+			Column:   entry.Position.Column,
+		})
+	}
+	if cause != nil {
+		c := Describe(cause)
+		r.Cause = &c
+	}
+	return r
+}
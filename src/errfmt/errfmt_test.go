@@ -0,0 +1,105 @@
+package errfmt
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/parser"
+	"github.com/javanhut/Carrion/src/token"
+)
+
+func TestFormatters(t *testing.T) {
+	pos := token.Position{File: "crow.crl", Line: 3, Column: 5}
+	err := object.NewError("identifier not found: countr", pos)
+	err.Suggestions = []string{"counter"}
+	err.AddStackEntry(token.Position{File: "crow.crl", Line: 7, Column: 1}, "count")
+	custom := object.NewCustomError("ValueError", "bad value", pos)
+	syntax := []parser.ParseError{
+		{Position: pos, Message: "expected next token to be ), got NEWLINE instead", Expected: ")", Found: "NEWLINE"},
+		{Position: token.Position{File: "crow.crl", Line: 9, Column: 2}, Message: "no prefix parse function for ="},
+	}
+
+	if got := (Plain{}).Runtime(err); got != err.Inspect() {
+		t.Errorf("plain should be Inspect, got %q", got)
+	}
+	if got := (Pretty{}).Runtime(custom); got != "\033[31m"+custom.Inspect()+"\033[0m" {
+		t.Errorf("pretty should be Inspect in red, got %q", got)
+	}
+	want := "Error: expected next token to be ), got NEWLINE instead\nError: no prefix parse function for ="
+	if got := (Plain{}).Syntax(syntax); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	tests := []struct {
+		err  object.Object
+		want string
+	}{
+		{err, "crow.crl:3:5: Error: identifier not found: countr"},
+		{custom, "crow.crl:3:5: ValueError: bad value"},
+		{&object.InternalError{Message: "nil map"}, "InternalError: nil map"},
+	}
+	for _, tt := range tests {
+		if got := (Short{}).Runtime(tt.err); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+	want = "crow.crl:3:5: SyntaxError: expected next token to be ), got NEWLINE instead\ncrow.crl:9:2: SyntaxError: no prefix parse function for ="
+	if got := (Short{}).Syntax(syntax); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	var report Report
+	if e := json.Unmarshal([]byte((JSON{}).Runtime(err)), &report); e != nil {
+		t.Fatal(e)
+	}
+	wantReport := Report{
+		Kind: "Error", Message: "identifier not found: countr", File: "crow.crl", Line: 3, Column: 5,
+		Suggestions: []string{"counter"},
+		StackTrace:  []Frame{{Function: "count", File: "crow.crl", Line: 7, Column: 1}},
+	}
+	if !reflect.DeepEqual(report, wantReport) {
+		t.Errorf("expected %+v, got %+v", wantReport, report)
+	}
+	if e := json.Unmarshal([]byte((JSON{}).Syntax(syntax[:1])), &report); e != nil {
+		t.Fatal(e)
+	}
+	if report.Kind != "SyntaxError" || report.Expected != ")" || report.Found != "NEWLINE" || report.Line != 3 {
+		t.Errorf("unexpected syntax report %+v", report)
+	}
+
+	chained := object.NewCustomError("ConfigError", "bad config", pos)
+	chained.Cause = custom
+	if r := Describe(chained); r.Cause == nil || r.Cause.Kind != "ValueError" || r.Cause.Message != "bad value" {
+		t.Errorf("expected the cause in the report, got %+v", r.Cause)
+	}
+	if got := chained.Inspect(); !strings.Contains(got, "\nCaused by: ValueError: bad value") {
+		t.Errorf("expected the cause after the error, got %q", got)
+	}
+}
+
+type loud struct{ Plain }
+
+func (loud) Runtime(err object.Object) string { return "!" }
+
+func TestRegister(t *testing.T) {
+	for _, name := range []string{"plain", "pretty", "short", "json"} {
+		if _, ok := Lookup(name); !ok {
+			t.Errorf("%s should be registered", name)
+		}
+	}
+	if _, ok := Lookup("loud"); ok {
+		t.Error("loud should not be registered yet")
+	}
+	Register("loud", loud{})
+	f, ok := Lookup("loud")
+	if !ok || f.Runtime(object.NewError("x")) != "!" {
+		t.Errorf("expected the registered formatter, got %v", f)
+	}
+	if !slices.Contains(Names(), "loud") || !slices.IsSorted(Names()) {
+		t.Errorf("expected sorted names including loud, got %v", Names())
+	}
+}
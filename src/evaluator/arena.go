@@ -0,0 +1,118 @@
+package evaluator
+
+import (
+	"sync/atomic"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// arena is registered from init because it calls back into the evaluator.
+func init() {
+	builtins["arena"] = &object.Builtin{EnvFn: arenaBuiltin}
+}
+
+// Arenas runs every program with arena allocation, as if its whole body
+// were passed to arena(). The carrion command sets it for --arena.
+var Arenas bool
+
+// arenasInUse counts the interpreters allocating from an arena, so that
+// when there are none the evaluator need not look up its context to find
+// out.
+var arenasInUse atomic.Int32
+
+// arenaChunk is how many objects of a kind an Arena allocates at a time.
+const arenaChunk = 256
+
+// Arena hands out the integers, floats and strings that literals,
+// arithmetic and concatenation create from chunks of arenaChunk objects,
+// instead of allocating each one on its own. A script that churns through
+// short-lived numbers then makes far fewer allocations for the garbage
+// collector to track, and a chunk is freed as a whole once nothing in it
+// is referenced. A value that outlives its scope stays valid; it only keeps
+// the rest of its chunk alive with it.
+//
+// A nil *Arena allocates each object on the heap as usual.
+type Arena struct {
+	ints      []object.Integer
+	floats    []object.Float
+	strs      []object.String
+	allocated int64 // objects handed out
+}
+
+// Integer returns a new Integer holding v.
+func (a *Arena) Integer(v int64) *object.Integer {
+	if a == nil {
+		return &object.Integer{Value: v}
+	}
+	if len(a.ints) == 0 {
+		a.ints = make([]object.Integer, arenaChunk)
+	}
+	obj := &a.ints[0]
+	a.ints = a.ints[1:]
+	obj.Value = v
+	a.allocated++
+	return obj
+}
+
+// Float returns a new Float holding v.
+func (a *Arena) Float(v float64) *object.Float {
+	if a == nil {
+		return &object.Float{Value: v}
+	}
+	if len(a.floats) == 0 {
+		a.floats = make([]object.Float, arenaChunk)
+	}
+	obj := &a.floats[0]
+	a.floats = a.floats[1:]
+	obj.Value = v
+	a.allocated++
+	return obj
+}
+
+// String returns a new String holding v.
+func (a *Arena) String(v string) *object.String {
+	if a == nil {
+		return &object.String{Value: v}
+	}
+	if len(a.strs) == 0 {
+		a.strs = make([]object.String, arenaChunk)
+	}
+	obj := &a.strs[0]
+	a.strs = a.strs[1:]
+	obj.Value = v
+	a.allocated++
+	return obj
+}
+
+// arenaFor returns the arena that code running in env allocates from, or
+// nil outside any arena scope.
+func arenaFor(env *object.Environment) *Arena {
+	if arenasInUse.Load() == 0 {
+		return nil
+	}
+	return contextFor(env).arena
+}
+
+// arenaBuiltin implements arena(spell, args...). It calls spell with args
+// in a fresh arena scope and returns its result. When the call ends the
+// interpreter drops the scope's chunks, releasing together everything
+// allocated in it that the result does not refer to, and goes back to the
+// enclosing scope's arena, if any.
+func arenaBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError("arena requires a spell to call")
+	}
+	ctx := contextFor(env)
+	outer := ctx.arena
+	ctx.arena = &Arena{}
+	if outer == nil {
+		arenasInUse.Add(1)
+	}
+	defer func() {
+		ctx.arena = outer
+		if outer == nil {
+			arenasInUse.Add(-1)
+		}
+	}()
+	return evalCallExpression(args[0], args[1:], env)
+}
@@ -0,0 +1,242 @@
+package evaluator
+
+import (
+	"slices"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// arrayMethod is a method of an array that may call back into Carrion, such
+// as map with a spell, so it is given the calling environment.
+type arrayMethod func(arr *object.Array, env *object.Environment, args []object.Object) object.Object
+
+// arrayMethods holds the methods of arrays other than the arithmetic ones in
+// vector.go. sort and reverse change the array in place and return None,
+// raising a TypeError if it is frozen; sorted and reversed return a new
+// array and leave it as it was. They are registered from init because they
+// call spells.
+var arrayMethods map[string]arrayMethod
+
+func init() {
+	arrayMethods = map[string]arrayMethod{
+		"map":      arrayMap,
+		"filter":   arrayFilter,
+		"reduce":   arrayReduce,
+		"sort":     arraySort(true),
+		"sorted":   arraySort(false),
+		"reverse":  arrayReverse(true),
+		"reversed": arrayReverse(false),
+		"index":    arrayIndex,
+		"count":    arrayCount,
+		"append":   arrayAppend,
+		"pop":      arrayPop,
+	}
+}
+
+// arrayMap implements arr.map(fn), an array of fn of each element.
+func arrayMap(arr *object.Array, env *object.Environment, args []object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("map requires 1 argument: spell")
+	}
+	result := make([]object.Object, len(arr.Elements))
+	for i, element := range arr.Elements {
+		result[i] = evalCallExpression(args[0], []object.Object{element}, env)
+		if isError(result[i]) {
+			return result[i]
+		}
+	}
+	return &object.Array{Elements: result}
+}
+
+// arrayFilter implements arr.filter(fn), the elements for which fn is
+// truthy, in order.
+func arrayFilter(arr *object.Array, env *object.Environment, args []object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("filter requires 1 argument: spell")
+	}
+	result := []object.Object{}
+	for _, element := range arr.Elements {
+		keep := evalCallExpression(args[0], []object.Object{element}, env)
+		if isError(keep) {
+			return keep
+		}
+		if isTruthy(keep) {
+			result = append(result, element)
+		}
+	}
+	return &object.Array{Elements: result}
+}
+
+// arrayReduce implements arr.reduce(fn, [initial]), which folds the
+// elements from the left with fn(total, element), starting from initial or
+// else the first element.
+func arrayReduce(arr *object.Array, env *object.Environment, args []object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("reduce requires 1 or 2 arguments: spell, [initial]")
+	}
+	elements := arr.Elements
+	var total object.Object
+	if len(args) == 2 {
+		total = args[1]
+	} else {
+		if len(elements) == 0 {
+			return newError("reduce of an empty array with no initial value")
+		}
+		total, elements = elements[0], elements[1:]
+	}
+	for _, element := range elements {
+		total = evalCallExpression(args[0], []object.Object{total, element}, env)
+		if isError(total) {
+			return total
+		}
+	}
+	return total
+}
+
+// arraySort makes sort([key], [reverse]) and sorted([key], [reverse]). The
+// sort is stable, comparing elements, or what key gives for them, with <;
+// key is called once per element. A None key compares the elements
+// themselves.
+func arraySort(inPlace bool) arrayMethod {
+	name := "sorted"
+	if inPlace {
+		name = "sort"
+	}
+	return func(arr *object.Array, env *object.Environment, args []object.Object) object.Object {
+		if len(args) > 2 {
+			return newError("%s takes at most 2 arguments: [key], [reverse]", name)
+		}
+		if inPlace && arr.Frozen {
+			return frozenError(arr, env, contextFor(env).CurrentPosition())
+		}
+		keys := arr.Elements
+		if len(args) > 0 && args[0].Type() != object.NONE_OBJ {
+			mapped := arrayMap(arr, env, args[:1])
+			if isError(mapped) {
+				return mapped
+			}
+			keys = mapped.(*object.Array).Elements
+		}
+		descending := len(args) == 2 && isTruthy(args[1])
+
+		order := make([]int, len(keys))
+		for i := range order {
+			order[i] = i
+		}
+		var errObj object.Object
+		less := func(a, b object.Object) bool {
+			result := evalInfixExpression("<", a, b, nil)
+			if isError(result) && errObj == nil {
+				errObj = result
+			}
+			return result == TRUE
+		}
+		slices.SortStableFunc(order, func(i, j int) int {
+			a, b := keys[i], keys[j]
+			if descending {
+				a, b = b, a
+			}
+			switch {
+			case less(a, b):
+				return -1
+			case less(b, a):
+				return 1
+			}
+			return 0
+		})
+		if errObj != nil {
+			return errObj
+		}
+
+		sorted := make([]object.Object, len(order))
+		for n, i := range order {
+			sorted[n] = arr.Elements[i]
+		}
+		if inPlace {
+			copy(arr.Elements, sorted)
+			return NONE
+		}
+		return &object.Array{Elements: sorted}
+	}
+}
+
+// arrayReverse makes reverse() and reversed().
+func arrayReverse(inPlace bool) arrayMethod {
+	name := "reversed"
+	if inPlace {
+		name = "reverse"
+	}
+	return func(arr *object.Array, env *object.Environment, args []object.Object) object.Object {
+		if len(args) != 0 {
+			return newTypeError("%s takes no arguments", name)
+		}
+		if inPlace && arr.Frozen {
+			return frozenError(arr, env, contextFor(env).CurrentPosition())
+		}
+		if inPlace {
+			slices.Reverse(arr.Elements)
+			return NONE
+		}
+		reversed := slices.Clone(arr.Elements)
+		slices.Reverse(reversed)
+		return &object.Array{Elements: reversed}
+	}
+}
+
+// arrayIndex implements arr.index(x), the index of the first element equal
+// to x, or -1.
+func arrayIndex(arr *object.Array, env *object.Environment, args []object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("index requires 1 argument: value")
+	}
+	for i, element := range arr.Elements {
+		if isEqual(element, args[0]) {
+			return &object.Integer{Value: int64(i)}
+		}
+	}
+	return &object.Integer{Value: -1}
+}
+
+// arrayCount implements arr.count(x), how many elements equal x.
+func arrayCount(arr *object.Array, env *object.Environment, args []object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("count requires 1 argument: value")
+	}
+	count := 0
+	for _, element := range arr.Elements {
+		if isEqual(element, args[0]) {
+			count++
+		}
+	}
+	return &object.Integer{Value: int64(count)}
+}
+
+// arrayAppend implements arr.append(x), which adds x to the end of arr.
+func arrayAppend(arr *object.Array, env *object.Environment, args []object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("append requires 1 argument: value")
+	}
+	if arr.Frozen {
+		return frozenError(arr, env, contextFor(env).CurrentPosition())
+	}
+	arr.Elements = append(arr.Elements, args[0])
+	return NONE
+}
+
+// arrayPop implements arr.pop(), which removes the last element of arr and
+// gives it, raising an IndexError if arr is empty.
+func arrayPop(arr *object.Array, env *object.Environment, args []object.Object) object.Object {
+	if len(args) != 0 {
+		return newTypeError("pop takes no arguments")
+	}
+	position := contextFor(env).CurrentPosition()
+	if arr.Frozen {
+		return frozenError(arr, env, position)
+	}
+	if len(arr.Elements) == 0 {
+		return newStdlibError("IndexError", "pop from empty array", env, position)
+	}
+	last := arr.Elements[len(arr.Elements)-1]
+	arr.Elements = arr.Elements[:len(arr.Elements)-1]
+	return last
+}
@@ -0,0 +1,145 @@
+package evaluator
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/token"
+)
+
+func init() {
+	builtins["parse_ast"] = &object.Builtin{EnvFn: parseASTBuiltin}
+}
+
+// parseASTBuiltin implements parse_ast(source, [name]), which parses source
+// and returns its syntax tree as hashes and arrays for scripts to inspect.
+// Each node is a hash with its "type", such as "InfixExpression", the
+// "line" and "column" it starts at, and its fields under snake_case names:
+// nodes, arrays of nodes, or plain values. A syntax error is raised as for
+// compile.
+func parseASTBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("parse_ast requires 1 or 2 arguments: source, [name]")
+	}
+	source, ok := args[0].(*object.String)
+	if !ok {
+		return newError("parse_ast expects the source as a STRING, got %s", args[0].Type())
+	}
+	name := "<eval>"
+	if len(args) == 2 {
+		str, ok := args[1].(*object.String)
+		if !ok {
+			return newError("parse_ast expects the name as a STRING, got %s", args[1].Type())
+		}
+		name = str.Value
+	}
+	code := compileSource(source.Value, name, env)
+	if isError(code) {
+		return code
+	}
+	return astValue(reflect.ValueOf(code.(*object.Code).Program))
+}
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+// astValue converts part of a syntax tree: a node or other struct to a
+// hash, a slice to an array, a map of expressions to an array of [key,
+// value] pairs in source order, and anything else to the matching value.
+func astValue(v reflect.Value) object.Object {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return object.NONE
+		}
+		return astValue(v.Elem())
+	case reflect.Struct:
+		return astNode(v)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return &object.Bytes{Value: append([]byte(nil), v.Bytes()...)}
+		}
+		elements := make([]object.Object, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			// The empty statements the parser leaves in blocks are left out.
+			if es, ok := v.Index(i).Interface().(*ast.ExpressionStatement); ok && es.Expression == nil {
+				continue
+			}
+			elements = append(elements, astValue(v.Index(i)))
+		}
+		return &object.Array{Elements: elements}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := astPosition(keys[i]), astPosition(keys[j])
+			return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+		})
+		elements := make([]object.Object, len(keys))
+		for i, key := range keys {
+			elements[i] = &object.Array{Elements: []object.Object{astValue(key), astValue(v.MapIndex(key))}}
+		}
+		return &object.Array{Elements: elements}
+	case reflect.String:
+		return &object.String{Value: v.String()}
+	case reflect.Bool:
+		return nativeBoolToBooleanObject(v.Bool())
+	case reflect.Int, reflect.Int64:
+		return &object.Integer{Value: v.Int()}
+	case reflect.Float64:
+		return &object.Float{Value: v.Float()}
+	}
+	return object.NONE
+}
+
+func astNode(v reflect.Value) object.Object {
+	members := map[string]object.Object{"type": &object.String{Value: v.Type().Name()}}
+	if v.CanAddr() {
+		if pos := astPosition(v.Addr()); pos.Line > 0 {
+			members["line"] = &object.Integer{Value: int64(pos.Line)}
+			members["column"] = &object.Integer{Value: int64(pos.Column)}
+		}
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() || field.Type == tokenType {
+			continue
+		}
+		members[snakeCase(field.Name)] = astValue(v.Field(i))
+	}
+	return newStringHash(members)
+}
+
+// astPosition is where the node in v starts, or the zero Position if v is
+// not a node.
+func astPosition(v reflect.Value) token.Position {
+	if !v.CanInterface() {
+		return token.Position{}
+	}
+	if node, ok := v.Interface().(ast.Node); ok && !v.IsNil() {
+		if pos := ast.StartPosition(node); pos.Line > 0 {
+			return pos
+		}
+		if tok := reflect.Indirect(v).FieldByName("Token"); tok.IsValid() && tok.Type() == tokenType {
+			return tok.Interface().(token.Token).Position
+		}
+	}
+	return token.Position{}
+}
+
+// snakeCase turns a Go field name such as ReturnValue into return_value.
+func snakeCase(name string) string {
+	var out strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				out.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
@@ -0,0 +1,53 @@
+package evaluator
+
+import (
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// evalAutocloseStatement runs `autoclose resource as name:`. It calls the
+// __enter__ spell of resource, if it has one, and binds name to what that
+// gives, or to resource itself. Then it runs the body and calls
+// __exit__(error) however the body ends, where error is what `ensnare ... as
+// e` would bind for an error from the body, or None. The error is raised on
+// once __exit__ is done, unless __exit__ returns True to swallow it.
+func evalAutocloseStatement(node *ast.AutocloseStatement, env *object.Environment) object.Object {
+	resource := Eval(node.Resource, env)
+	if isError(resource) {
+		return resource
+	}
+	instance, ok := resource.(*object.Instance)
+	if !ok || instance.Grimoire.Methods["__exit__"] == nil {
+		what := string(resource.Type())
+		if ok {
+			what = instance.Grimoire.Name
+		}
+		return newError("autoclose needs a grimoire with an __exit__ spell, got %s", what)
+	}
+
+	value := resource
+	if enter, ok := instance.Grimoire.Methods["__enter__"]; ok {
+		value = evalCallExpression(&object.BoundMethod{Instance: instance, Method: enter}, nil, env)
+		if isError(value) {
+			return value
+		}
+	}
+	if node.Alias != nil {
+		env.Set(node.Alias.Value, value)
+	}
+
+	result := forceTailCall(Eval(node.Body, env), env)
+	var raised object.Object = object.NONE
+	if err, ok := catchable(result, env); ok {
+		raised = errorValue(err, env)
+	}
+	exit := &object.BoundMethod{Instance: instance, Method: instance.Grimoire.Methods["__exit__"]}
+	exited := evalCallExpression(exit, []object.Object{raised}, env)
+	if isError(exited) {
+		return exited
+	}
+	if raised != object.NONE && isTruthy(exited) {
+		return NONE
+	}
+	return result
+}
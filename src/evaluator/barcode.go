@@ -0,0 +1,103 @@
+package evaluator
+
+import (
+	"image"
+	"os"
+
+	"github.com/javanhut/Carrion/src/barcode"
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// The qrcode and barcode modules draw codes as PNG images:
+// qrcode.generate(text, [path], [level]) and barcode.code128(text, [path]).
+// Given a path they write the image there; without one, or with none, they
+// return its BYTES.
+func init() {
+	builtinModules["qrcode"] = newBuiltinModule(map[string]object.Object{
+		"generate": &object.Builtin{Fn: qrGenerate},
+	})
+	builtinModules["barcode"] = newBuiltinModule(map[string]object.Object{
+		"code128": &object.Builtin{Fn: code128Generate},
+	})
+}
+
+var qrLevels = map[string]barcode.Level{
+	"L": barcode.Low,
+	"M": barcode.Medium,
+	"Q": barcode.Quartile,
+	"H": barcode.High,
+}
+
+// qrGenerate implements qrcode.generate. The level is how much damage the
+// code survives: "L", "M", "Q" or "H", "M" unless given.
+func qrGenerate(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError("qrcode.generate requires 1 to 3 arguments: text, [path], [level]")
+	}
+	data, errObj := codeText("qrcode.generate", args[0])
+	if errObj != nil {
+		return errObj
+	}
+	level := barcode.Medium
+	if len(args) == 3 {
+		name, ok := args[2].(*object.String)
+		if !ok {
+			return newError("qrcode.generate: level must be a STRING, got %s", args[2].Type())
+		}
+		if level, ok = qrLevels[name.Value]; !ok {
+			return newError("qrcode.generate: level must be \"L\", \"M\", \"Q\" or \"H\", got %q", name.Value)
+		}
+	}
+	q, err := barcode.EncodeQR([]byte(data), level)
+	if err != nil {
+		return newError("qrcode.generate: %s", err)
+	}
+	return writeCode("qrcode.generate", q.Image(8, 4), args, 1)
+}
+
+// code128Generate implements barcode.code128, for the printable ASCII text
+// of labels, tickets and shipping codes.
+func code128Generate(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("barcode.code128 requires 1 or 2 arguments: text, [path]")
+	}
+	text, errObj := codeText("barcode.code128", args[0])
+	if errObj != nil {
+		return errObj
+	}
+	bars, err := barcode.EncodeCode128(text)
+	if err != nil {
+		return newError("barcode.code128: %s", err)
+	}
+	return writeCode("barcode.code128", bars.Image(2, 80, 10), args, 1)
+}
+
+func codeText(fn string, arg object.Object) (string, object.Object) {
+	switch arg := arg.(type) {
+	case *object.String:
+		return arg.Value, nil
+	case *object.Bytes:
+		return string(arg.Value), nil
+	}
+	return "", newError("%s: text must be a STRING or BYTES, got %s", fn, arg.Type())
+}
+
+// writeCode encodes img as PNG and writes it to the path in args[i], or
+// returns it as BYTES if there is none.
+func writeCode(fn string, img image.Image, args []object.Object, i int) object.Object {
+	data, err := barcode.PNG(img)
+	if err != nil {
+		return newError("%s: %s", fn, err)
+	}
+	if len(args) <= i || args[i].Type() == object.NONE_OBJ {
+		return &object.Bytes{Value: data}
+	}
+	path, ok := args[i].(*object.String)
+	if !ok {
+		return newError("%s: path must be a STRING, got %s", fn, args[i].Type())
+	}
+	if err := os.WriteFile(path.Value, data, 0644); err != nil {
+		return newError("%s: failed to write '%s': %s", fn, path.Value, err)
+	}
+	return NONE
+}
@@ -0,0 +1,169 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// bigOf returns the value of an Integer or BigInteger as a big.Int, which
+// the caller must not modify.
+func bigOf(obj object.Object) (*big.Int, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value), true
+	case *object.BigInteger:
+		return obj.Value, true
+	}
+	return nil, false
+}
+
+func isInteger(obj object.Object) bool {
+	t := obj.Type()
+	return t == object.INTEGER_OBJ || t == object.BIG_INTEGER_OBJ
+}
+
+// checkedIntegerOp does +, -, *, ** or << on two int64s and reports
+// whether the result fitted; if it did not, the operation must be redone
+// with big.Int, which also reports a negative shift count. ** is only
+// checked here for exponents of 0 and up.
+func checkedIntegerOp(operator string, l, r int64) (int64, bool) {
+	switch operator {
+	case "+":
+		sum := l + r
+		return sum, (l^sum)&(r^sum) >= 0
+	case "-":
+		diff := l - r
+		return diff, (l^r)&(l^diff) >= 0
+	case "*":
+		if l == 0 || r == 0 {
+			return 0, true
+		}
+		product := l * r
+		return product, product/r == l && !(l == -1 && r == math.MinInt64) && !(r == -1 && l == math.MinInt64)
+	case "**":
+		result := int64(1)
+		for base := l; r > 0; r >>= 1 {
+			if r&1 == 1 {
+				var ok bool
+				if result, ok = checkedIntegerOp("*", result, base); !ok {
+					return 0, false
+				}
+			}
+			if r > 1 {
+				var ok bool
+				if base, ok = checkedIntegerOp("*", base, base); !ok {
+					return 0, false
+				}
+			}
+		}
+		return result, true
+	case "<<":
+		if r < 0 {
+			return 0, false
+		}
+		if r >= 63 || bits.Len64(uint64(absInt(l))) > 63-int(r) {
+			return 0, l == 0
+		}
+		return l << r, true
+	}
+	return 0, true
+}
+
+// intQuotient is l / r as a float. Dividing the int64s first keeps the
+// result exact when r divides l, even where l is too large for a float64
+// to hold exactly.
+func intQuotient(l, r int64) float64 {
+	if r != -1 && l%r == 0 {
+		return float64(l / r)
+	}
+	return float64(l) / float64(r)
+}
+
+func absInt(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// evalBigIntegerInfixExpression applies an operator to two integers when
+// either is a BigInteger or the int64 result would overflow. / gives a
+// Float, and ~/ and % round down as they do for Integers.
+func evalBigIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	l, _ := bigOf(left)
+	r, _ := bigOf(right)
+	switch operator {
+	case "+":
+		return object.NewInt(new(big.Int).Add(l, r))
+	case "-":
+		return object.NewInt(new(big.Int).Sub(l, r))
+	case "*":
+		return object.NewInt(new(big.Int).Mul(l, r))
+	case "/", "~/", "%":
+		if r.Sign() == 0 {
+			return errDivisionByZero
+		}
+		if operator == "/" {
+			quotient, _ := new(big.Rat).SetFrac(l, r).Float64()
+			return &object.Float{Value: quotient}
+		}
+		// big.Int's Div and Mod are Euclidean, which only agrees with
+		// flooring for a positive divisor.
+		quotient, remainder := new(big.Int).QuoRem(l, r, new(big.Int))
+		if remainder.Sign() != 0 && remainder.Sign() != r.Sign() {
+			quotient.Sub(quotient, big.NewInt(1))
+			remainder.Add(remainder, r)
+		}
+		if operator == "~/" {
+			return object.NewInt(quotient)
+		}
+		return object.NewInt(remainder)
+	case "**":
+		if r.Sign() < 0 {
+			lf, _ := new(big.Float).SetInt(l).Float64()
+			rf, _ := new(big.Float).SetInt(r).Float64()
+			return &object.Integer{Value: int64(math.Pow(lf, rf))}
+		}
+		// The result has about r * l.BitLen() bits; refuse the same sizes
+		// a shift would rather than spend minutes building one. Bases of
+		// -1, 0 and 1 stay small for any exponent.
+		if l.CmpAbs(big.NewInt(1)) > 0 &&
+			(!r.IsInt64() || r.Int64() > math.MaxInt32/int64(l.BitLen())) {
+			return newError("exponent %s is too large", r)
+		}
+		return object.NewInt(new(big.Int).Exp(l, r, nil))
+	case "<<", ">>":
+		if r.Sign() < 0 {
+			return newError("negative shift count %s", r)
+		}
+		if !r.IsInt64() || r.Int64() > math.MaxInt32 {
+			return newError("shift count %s is too large", r)
+		}
+		if operator == "<<" {
+			return object.NewInt(new(big.Int).Lsh(l, uint(r.Int64())))
+		}
+		return object.NewInt(new(big.Int).Rsh(l, uint(r.Int64())))
+	case "&":
+		return object.NewInt(new(big.Int).And(l, r))
+	case "|":
+		return object.NewInt(new(big.Int).Or(l, r))
+	case "^":
+		return object.NewInt(new(big.Int).Xor(l, r))
+	case "<":
+		return nativeBoolToBooleanObject(l.Cmp(r) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(l.Cmp(r) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(l.Cmp(r) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(l.Cmp(r) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(l.Cmp(r) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(l.Cmp(r) != 0)
+	}
+	return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
@@ -0,0 +1,52 @@
+package evaluator
+
+import "github.com/javanhut/Carrion/src/object"
+
+func init() {
+	builtins["bind"] = &object.Builtin{Fn: bindBuiltin}
+	builtins["unbind"] = &object.Builtin{Fn: unbindBuiltin}
+}
+
+// bindBuiltin implements bind(fn, instance), a bound method that calls fn
+// with self set to instance. fn may be a spell, which need not belong to
+// the instance's grimoire, or a bound method, which is rebound.
+func bindBuiltin(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("bind requires 2 arguments: spell, instance")
+	}
+	instance, ok := args[1].(*object.Instance)
+	if !ok {
+		return newError("bind: expected an INSTANCE to bind to, got %s", args[1].Type())
+	}
+	switch fn := args[0].(type) {
+	case *object.Function:
+		return &object.BoundMethod{Instance: instance, Method: fn}
+	case *object.BoundMethod:
+		return &object.BoundMethod{Instance: instance, Method: fn.Method}
+	}
+	return newError("bind: expected a FUNCTION or BOUND_METHOD, got %s", args[0].Type())
+}
+
+// unbindBuiltin implements unbind(m), the spell of a bound method, which
+// can be bound again with bind.
+func unbindBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("unbind requires 1 argument: bound method")
+	}
+	m, ok := args[0].(*object.BoundMethod)
+	if !ok {
+		return newError("unbind: expected a BOUND_METHOD, got %s", args[0].Type())
+	}
+	return m.Method
+}
+
+// isSpell reports whether obj is a spell or a bound method. A spell is
+// equal only to itself, and bound methods are equal if they bind the same
+// spell to the same instance.
+func isSpell(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.BoundMethod:
+		return true
+	}
+	return false
+}
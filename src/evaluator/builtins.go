@@ -2,10 +2,13 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"os"
 	"os/exec"
-	"strconv"
+	"runtime"
 	"time"
+	"unicode/utf8"
 
 	"github.com/peterh/liner"
 
@@ -23,24 +26,35 @@ var builtins = map[string]*object.Builtin{
 	"len": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
+				return newTypeError("wrong number of arguments. got=%d, want=1",
 					len(args))
 			}
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Tuple:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Bytes:
+				return &object.Integer{Value: int64(len(arg.Value))}
+			case *object.IntArray:
+				return &object.Integer{Value: int64(len(arg.Values))}
+			case *object.FloatArray:
+				return &object.Integer{Value: int64(len(arg.Values))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(len(arg.Pairs))}
 			default:
-				return newError("argument to `len` not supported, got %s",
+				return newTypeError("argument to `len` not supported, got %s",
 					args[0].Type())
 			}
 		},
 	},
 	"print": {
-		Fn: func(args ...object.Object) object.Object {
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			out := contextFor(env).Stdout()
 			for _, arg := range args {
-				fmt.Println(arg.Inspect(), " ")
+				fmt.Fprintln(out, object.Display(arg), " ")
 			}
 			return &object.None{}
 		},
@@ -77,7 +91,7 @@ var builtins = map[string]*object.Builtin{
 	"type": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newTypeError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 
 			obj := args[0]
@@ -85,73 +99,42 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"int": {
-		Fn: func(args ...object.Object) object.Object {
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-			switch arg := args[0].(type) {
-			case *object.String:
-				value, err := strconv.Atoi(arg.Value)
-				if err != nil {
-					return newError("cannot convert string to int: %s", err)
-				}
-				return &object.Integer{Value: int64(value)}
-			case *object.Float:
-				return &object.Integer{Value: int64(arg.Value)}
-			case *object.Integer:
-				return arg
-			default:
-				return newError("cannot convert %s to int", arg.Type())
+				return newTypeError("wrong number of arguments. got=%d, want=1", len(args))
 			}
+			return convertInt(args[0], env)
 		},
 	},
 	"to_int": {
-		Fn: func(args ...object.Object) object.Object {
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-			switch arg := args[0].(type) {
-			case *object.String:
-				value, err := strconv.Atoi(arg.Value)
-				if err != nil {
-					return newError("cannot convert string to int: %s", err)
-				}
-				return &object.Integer{Value: int64(value)}
-			case *object.Float:
-				return &object.Integer{Value: int64(arg.Value)}
-			case *object.Integer:
-				return arg
-			default:
-				return newError("cannot convert %s to int", arg.Type())
+				return newTypeError("wrong number of arguments. got=%d, want=1", len(args))
 			}
+			return convertInt(args[0], env)
 		},
 	},
 
 	"float": {
-		Fn: func(args ...object.Object) object.Object {
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-			switch arg := args[0].(type) {
-			case *object.String:
-				value, err := strconv.ParseFloat(arg.Value, 64)
-				if err != nil {
-					return newError("cannot convert string to float: %s", err)
-				}
-				return &object.Float{Value: value}
-			case *object.Integer:
-				return &object.Float{Value: float64(arg.Value)}
-			case *object.Float:
-				return arg
-			default:
-				return newError("cannot convert %s to float", arg.Type())
+				return newTypeError("wrong number of arguments. got=%d, want=1", len(args))
 			}
+			return convertFloat(args[0], env)
 		},
 	},
 	"str": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newTypeError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return &object.String{Value: object.Display(args[0])}
+		},
+	},
+	"repr": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newTypeError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 			return &object.String{Value: args[0].Inspect()}
 		},
@@ -159,17 +142,21 @@ var builtins = map[string]*object.Builtin{
 	"list": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newTypeError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 			switch arg := args[0].(type) {
 			case *object.String:
-				elements := make([]object.Object, len(arg.Value))
-				for i, char := range arg.Value {
-					elements[i] = &object.String{Value: string(char)}
+				elements := make([]object.Object, 0, utf8.RuneCountInString(arg.Value))
+				for _, char := range arg.Value {
+					elements = append(elements, &object.String{Value: string(char)})
 				}
 				return &object.Array{Elements: elements}
 			case *object.Tuple:
 				return &object.Array{Elements: arg.Elements}
+			case *object.Bytes:
+				return byteElements(arg)
+			case *object.IntArray, *object.FloatArray:
+				return typedElements(arg)
 			default:
 				return newError("cannot convert %s to list", arg.Type())
 			}
@@ -178,7 +165,7 @@ var builtins = map[string]*object.Builtin{
 	"tuple": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newTypeError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 			switch arg := args[0].(type) {
 			case *object.Array:
@@ -207,7 +194,7 @@ var builtins = map[string]*object.Builtin{
 				startObj, ok1 := args[0].(*object.Integer)
 				stopObj, ok2 := args[1].(*object.Integer)
 				if !ok1 || !ok2 {
-					return newError(
+					return newTypeError(
 						"arguments to `range` must be INTEGER, got=%s and %s",
 						args[0].Type(),
 						args[1].Type(),
@@ -220,7 +207,7 @@ var builtins = map[string]*object.Builtin{
 				stopObj, ok2 := args[1].(*object.Integer)
 				stepObj, ok3 := args[2].(*object.Integer)
 				if !ok1 || !ok2 || !ok3 {
-					return newError(
+					return newTypeError(
 						"arguments to `range` must be INTEGER, got=%s, %s, %s",
 						args[0].Type(),
 						args[1].Type(),
@@ -229,7 +216,7 @@ var builtins = map[string]*object.Builtin{
 				}
 				start, stop, step = startObj.Value, stopObj.Value, stepObj.Value
 			default:
-				return newError("wrong number of arguments. got=%d, want=1..3", len(args))
+				return newTypeError("wrong number of arguments. got=%d, want=1..3", len(args))
 			}
 
 			if step == 0 {
@@ -257,29 +244,19 @@ var builtins = map[string]*object.Builtin{
 				return newError("max requires at least one argument")
 			}
 
-			var nums []float64
 			for _, arg := range args {
-				switch v := arg.(type) {
-				case *object.Integer:
-					nums = append(nums, float64(v.Value))
-				case *object.Float:
-					nums = append(nums, v.Value)
-				default:
-					return newError("max: unsupported type %s", arg.Type())
+				if !isNumber(arg) {
+					return newTypeError("max: unsupported type %s", arg.Type())
 				}
 			}
 
-			maxVal := nums[0]
-			for _, n := range nums[1:] {
-				if n > maxVal {
+			maxVal := args[0]
+			for _, n := range args[1:] {
+				if evalInfixExpression(">", n, maxVal, nil) == TRUE {
 					maxVal = n
 				}
 			}
-
-			if maxVal == float64(int64(maxVal)) {
-				return &object.Integer{Value: int64(maxVal)}
-			}
-			return &object.Float{Value: maxVal}
+			return maxVal
 		},
 	},
 
@@ -290,10 +267,18 @@ var builtins = map[string]*object.Builtin{
 			}
 			switch v := args[0].(type) {
 			case *object.Integer:
+				if v.Value == math.MinInt64 {
+					return object.NewInt(new(big.Int).Neg(big.NewInt(v.Value)))
+				}
 				if v.Value < 0 {
 					return &object.Integer{Value: -v.Value}
 				}
 				return v
+			case *object.BigInteger:
+				if v.Value.Sign() < 0 {
+					return &object.BigInteger{Value: new(big.Int).Neg(v.Value)}
+				}
+				return v
 			case *object.Float:
 				if v.Value < 0 {
 					return &object.Float{Value: -v.Value}
@@ -306,7 +291,7 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"osRunCommand": {
-		Fn: func(args ...object.Object) object.Object {
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
 			var command string
 			var cmdArgs []string
 			var capture bool
@@ -341,18 +326,25 @@ var builtins = map[string]*object.Builtin{
 				}
 				capture = boolArg.Value
 			}
+			c, errObj := contextArg("osRunCommand", env, args, 3)
+			if errObj != nil {
+				return errObj
+			}
 
-			cmd := exec.Command(command, cmdArgs...)
+			cmd := exec.CommandContext(c, command, cmdArgs...)
 			var outputBytes []byte
 			var err error
 			if capture {
 				outputBytes, err = cmd.CombinedOutput()
 			} else {
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
+				cmd.Stdout = contextFor(env).Stdout()
+				cmd.Stderr = contextFor(env).Stderr()
 				err = cmd.Run()
 			}
 
+			if err != nil && c.Err() != nil {
+				return contextError("osRunCommand", c, env)
+			}
 			if err != nil {
 				return newError("error running command '%s': %s", command, err)
 			}
@@ -399,7 +391,7 @@ var builtins = map[string]*object.Builtin{
 	"osGetCwd": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 0 {
-				return newError("osGetCwd takes no arguments")
+				return newTypeError("osGetCwd takes no arguments")
 			}
 			dir, err := os.Getwd()
 			if err != nil {
@@ -427,21 +419,29 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"osSleep": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("osSleep requires 1 argument: seconds (INT or FLOAT)")
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("osSleep requires 1 or 2 arguments: seconds (INT or FLOAT), [context]")
+			}
+			c, errObj := contextArg("osSleep", env, args, 1)
+			if errObj != nil {
+				return errObj
 			}
 
+			var d time.Duration
 			switch val := args[0].(type) {
 			case *object.Integer:
-				time.Sleep(time.Duration(val.Value) * time.Second)
+				d = time.Duration(val.Value) * time.Second
 			case *object.Float:
 
 				nanos := int64(val.Value * 1_000_000_000)
-				time.Sleep(time.Duration(nanos))
+				d = time.Duration(nanos)
 			default:
 				return newError("osSleep argument must be INTEGER or FLOAT, got %s", args[0].Type())
 			}
+			if err := sleep(c, d); err != nil {
+				return contextError("osSleep", c, env)
+			}
 
 			return &object.None{}
 		},
@@ -532,15 +532,22 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"fileRead": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("fileRead requires 1 argument: path")
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("fileRead requires 1 or 2 arguments: path, [context]")
 			}
 			pathArg, ok := args[0].(*object.String)
 			if !ok {
 				return newError("fileRead: path must be a string")
 			}
-			data, err := os.ReadFile(pathArg.Value)
+			c, errObj := contextArg("fileRead", env, args, 1)
+			if errObj != nil {
+				return errObj
+			}
+			data, err := readFile(c, pathArg.Value)
+			if isDone(err) {
+				return contextError("fileRead", c, env)
+			}
 			if err != nil {
 				return newError("failed to read file '%s': %s", pathArg.Value, err)
 			}
@@ -549,17 +556,24 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"fileWrite": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("fileWrite requires 2 arguments: path, content")
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("fileWrite requires 2 or 3 arguments: path, content, [context]")
 			}
 			pathArg, ok1 := args[0].(*object.String)
 			contentArg, ok2 := args[1].(*object.String)
 			if !ok1 || !ok2 {
 				return newError("fileWrite: path/content must be STRINGs")
 			}
+			c, errObj := contextArg("fileWrite", env, args, 2)
+			if errObj != nil {
+				return errObj
+			}
 
-			err := os.WriteFile(pathArg.Value, []byte(contentArg.Value), 0644)
+			err := writeFile(c, pathArg.Value, []byte(contentArg.Value), os.O_TRUNC)
+			if isDone(err) {
+				return contextError("fileWrite", c, env)
+			}
 			if err != nil {
 				return newError("failed to write file '%s': %s", pathArg.Value, err)
 			}
@@ -568,23 +582,24 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"fileAppend": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("fileAppend requires 2 arguments: path, content")
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("fileAppend requires 2 or 3 arguments: path, content, [context]")
 			}
 			pathArg, ok1 := args[0].(*object.String)
 			contentArg, ok2 := args[1].(*object.String)
 			if !ok1 || !ok2 {
 				return newError("fileAppend: path/content must be STRINGs")
 			}
-
-			f, err := os.OpenFile(pathArg.Value, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
-			if err != nil {
-				return newError("failed to open file '%s' for append: %s", pathArg.Value, err)
+			c, errObj := contextArg("fileAppend", env, args, 2)
+			if errObj != nil {
+				return errObj
 			}
-			defer f.Close()
 
-			_, err = f.WriteString(contentArg.Value)
+			err := writeFile(c, pathArg.Value, []byte(contentArg.Value), os.O_APPEND)
+			if isDone(err) {
+				return contextError("fileAppend", c, env)
+			}
 			if err != nil {
 				return newError("failed to append to file '%s': %s", pathArg.Value, err)
 			}
@@ -639,6 +654,184 @@ var builtins = map[string]*object.Builtin{
 			}
 		},
 	},
+	"random": {
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("random takes no arguments, got=%d", len(args))
+			}
+			return &object.Float{Value: contextFor(env).Rand().Float64()}
+		},
+	},
+	"randint": {
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("randint requires 2 arguments: low, high")
+			}
+			low, ok1 := args[0].(*object.Integer)
+			high, ok2 := args[1].(*object.Integer)
+			if !ok1 || !ok2 {
+				return newTypeError("arguments to `randint` must be INTEGER, got=%s and %s",
+					args[0].Type(), args[1].Type())
+			}
+			if low.Value > high.Value {
+				return newValueError("randint: low (%d) must not exceed high (%d)", low.Value, high.Value)
+			}
+			rng := contextFor(env).Rand()
+			// A span wider than the largest int64 is counted in uint64,
+			// where from the smallest int64 to the largest it wraps to 0.
+			span := uint64(high.Value-low.Value) + 1
+			if span != 0 && span <= math.MaxInt64 {
+				return &object.Integer{Value: low.Value + rng.Int63n(int64(span))}
+			}
+			n := rng.Uint64()
+			for span != 0 && n >= span {
+				n = rng.Uint64()
+			}
+			return &object.Integer{Value: low.Value + int64(n)}
+		},
+	},
+	"choice": {
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("choice requires 1 argument: array")
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newTypeError("choice expects an array, got %s", args[0].Type())
+			}
+			if len(arr.Elements) == 0 {
+				return newValueError("choice: cannot choose from an empty array")
+			}
+			return arr.Elements[contextFor(env).Rand().Intn(len(arr.Elements))]
+		},
+	},
+	"shuffle": {
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("shuffle requires 1 argument: array")
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newTypeError("shuffle expects an array, got %s", args[0].Type())
+			}
+			if arr.Frozen {
+				return frozenError(arr, env, contextFor(env).CurrentPosition())
+			}
+			contextFor(env).Rand().Shuffle(len(arr.Elements), func(i, j int) {
+				arr.Elements[i], arr.Elements[j] = arr.Elements[j], arr.Elements[i]
+			})
+			return NONE
+		},
+	},
+	"seed": {
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("seed requires 1 argument: n")
+			}
+			n, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `seed` must be INTEGER, got=%s", args[0].Type())
+			}
+			contextFor(env).Seed(n.Value)
+			return NONE
+		},
+	},
+	"weakref": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("weakref requires 1 argument: object")
+			}
+			ref, ok := object.NewWeakRef(args[0])
+			if !ok {
+				return newError("weakref: cannot create weak reference to %s", args[0].Type())
+			}
+			return ref
+		},
+	},
+
+	"weakregistry": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("weakregistry takes no arguments")
+			}
+			return newWeakRegistry()
+		},
+	},
+
+	"gc": {
+		Fn: func(args ...object.Object) object.Object {
+			runtime.GC()
+			return NONE
+		},
+	},
+
+	"getrecursionlimit": {
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("getrecursionlimit takes no arguments")
+			}
+			return &object.Integer{Value: int64(contextFor(env).RecursionLimit())}
+		},
+	},
+
+	"setrecursionlimit": {
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("setrecursionlimit requires 1 argument: limit")
+			}
+			limit, ok := args[0].(*object.Integer)
+			if !ok || limit.Value < 1 {
+				return newError("setrecursionlimit: limit must be a positive INTEGER")
+			}
+			contextFor(env).SetRecursionLimit(int(limit.Value))
+			return NONE
+		},
+	},
+
+	"getfloatprecision": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("getfloatprecision takes no arguments")
+			}
+			if object.FloatPrecision < 0 {
+				return NONE
+			}
+			return &object.Integer{Value: int64(object.FloatPrecision)}
+		},
+	},
+
+	"setfloatprecision": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("setfloatprecision requires 1 argument: digits")
+			}
+			switch digits := args[0].(type) {
+			case *object.None:
+				object.FloatPrecision = -1
+			case *object.Integer:
+				if digits.Value < 0 || digits.Value > 17 {
+					return newError("setfloatprecision: digits must be between 0 and 17, got %d", digits.Value)
+				}
+				object.FloatPrecision = int(digits.Value)
+			default:
+				return newError("setfloatprecision: digits must be an INTEGER or None, got %s", args[0].Type())
+			}
+			return NONE
+		},
+	},
+
+	"format_number": {
+		Fn: formatNumber,
+	},
+
+	"parse": {
+		Fn: parseBuiltin,
+	},
+
+	"format_currency": {
+		Fn: formatCurrency,
+	},
+
 	"enumerate": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
@@ -0,0 +1,267 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+func init() {
+	builtins["bytes"] = &object.Builtin{Fn: bytesBuiltin}
+	builtins["decode"] = &object.Builtin{Fn: decodeBuiltin}
+	builtins["fileReadBytes"] = &object.Builtin{EnvFn: fileReadBytes}
+	builtins["fileWriteBytes"] = &object.Builtin{EnvFn: fileWriteBytes}
+}
+
+// encodings maps the names bytes() and decode() accept, after
+// normalizeEncoding, to how text is converted each way.
+var encodings = map[string]struct {
+	encode func(s string) ([]byte, error)
+	decode func(b []byte) (string, error)
+}{
+	"utf-8": {
+		encode: func(s string) ([]byte, error) { return []byte(s), nil },
+		decode: func(b []byte) (string, error) {
+			for i := 0; i < len(b); {
+				r, size := utf8.DecodeRune(b[i:])
+				if r == utf8.RuneError && size == 1 {
+					return "", fmt.Errorf("byte 0x%02x at index %d is not valid UTF-8", b[i], i)
+				}
+				i += size
+			}
+			return string(b), nil
+		},
+	},
+	"ascii": {
+		encode: func(s string) ([]byte, error) {
+			for i, r := range s {
+				if r > 0x7f {
+					return nil, fmt.Errorf("%q at index %d is not ASCII", r, i)
+				}
+			}
+			return []byte(s), nil
+		},
+		decode: func(b []byte) (string, error) {
+			for i, c := range b {
+				if c > 0x7f {
+					return "", fmt.Errorf("byte 0x%02x at index %d is not ASCII", c, i)
+				}
+			}
+			return string(b), nil
+		},
+	},
+	"latin-1": {
+		encode: func(s string) ([]byte, error) {
+			out := make([]byte, 0, len(s))
+			for i, r := range s {
+				if r > 0xff {
+					return nil, fmt.Errorf("%q at index %d is not in Latin-1", r, i)
+				}
+				out = append(out, byte(r))
+			}
+			return out, nil
+		},
+		decode: func(b []byte) (string, error) {
+			runes := make([]rune, len(b))
+			for i, c := range b {
+				runes[i] = rune(c)
+			}
+			return string(runes), nil
+		},
+	},
+	"hex": {
+		encode: func(s string) ([]byte, error) { return hex.DecodeString(s) },
+		decode: func(b []byte) (string, error) { return hex.EncodeToString(b), nil },
+	},
+	"base64": {
+		encode: func(s string) ([]byte, error) { return base64.StdEncoding.DecodeString(s) },
+		decode: func(b []byte) (string, error) { return base64.StdEncoding.EncodeToString(b), nil },
+	},
+}
+
+// normalizeEncoding lets encodings be written in any case, with _ for -,
+// and under their common aliases.
+func normalizeEncoding(name string) string {
+	name = strings.ReplaceAll(strings.ToLower(name), "_", "-")
+	switch name {
+	case "utf8":
+		return "utf-8"
+	case "latin1", "iso-8859-1":
+		return "latin-1"
+	}
+	return name
+}
+
+// encodingArg returns the encoding named by the optional argument at i of
+// fn's arguments, utf-8 by default.
+func encodingArg(fn string, args []object.Object, i int) (string, object.Object) {
+	if len(args) <= i {
+		return "utf-8", nil
+	}
+	name, ok := args[i].(*object.String)
+	if !ok {
+		return "", newTypeError("%s: encoding must be a STRING, got %s", fn, args[i].Type())
+	}
+	encoding := normalizeEncoding(name.Value)
+	if _, ok := encodings[encoding]; !ok {
+		return "", newValueError("%s: unknown encoding %q; use utf-8, ascii, latin-1, hex or base64", fn, name.Value)
+	}
+	return encoding, nil
+}
+
+// bytesBuiltin implements bytes(x, [encoding]). It encodes a string, utf-8
+// unless told otherwise; hex and base64 read the string as that notation.
+// It also builds bytes from an array of integers from 0 to 255, or n zero
+// bytes from an integer n.
+func bytesBuiltin(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("bytes requires 1 or 2 arguments: value, [encoding]")
+	}
+	if len(args) == 2 && args[0].Type() != object.STRING_OBJ {
+		return newTypeError("bytes: an encoding only applies to a STRING, got %s", args[0].Type())
+	}
+	switch arg := args[0].(type) {
+	case *object.Bytes:
+		return arg
+	case *object.String:
+		encoding, errObj := encodingArg("bytes", args, 1)
+		if errObj != nil {
+			return errObj
+		}
+		value, err := encodings[encoding].encode(arg.Value)
+		if err != nil {
+			return newValueError("bytes %s: %s", encoding, err)
+		}
+		return &object.Bytes{Value: value}
+	case *object.Array:
+		value := make([]byte, len(arg.Elements))
+		for i, element := range arg.Elements {
+			n, ok := element.(*object.Integer)
+			if !ok || n.Value < 0 || n.Value > 255 {
+				return newValueError("bytes: element %d must be an INTEGER from 0 to 255, got %s", i, element.Inspect())
+			}
+			value[i] = byte(n.Value)
+		}
+		return &object.Bytes{Value: value}
+	case *object.Integer:
+		if arg.Value < 0 {
+			return newValueError("bytes: negative length %d", arg.Value)
+		}
+		return &object.Bytes{Value: make([]byte, arg.Value)}
+	}
+	return newTypeError("bytes: cannot convert %s", args[0].Type())
+}
+
+// decodeBuiltin implements decode(b, [encoding]), which turns bytes back
+// into a string: text in utf-8 or the given encoding, or the hex or base64
+// notation of the bytes.
+func decodeBuiltin(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("decode requires 1 or 2 arguments: bytes, [encoding]")
+	}
+	b, ok := args[0].(*object.Bytes)
+	if !ok {
+		return newTypeError("decode expects BYTES, got %s", args[0].Type())
+	}
+	encoding, errObj := encodingArg("decode", args, 1)
+	if errObj != nil {
+		return errObj
+	}
+	value, err := encodings[encoding].decode(b.Value)
+	if err != nil {
+		return newValueError("decode %s: %s", encoding, err)
+	}
+	return &object.String{Value: value}
+}
+
+func evalBytesInfixExpression(operator string, left, right *object.Bytes) object.Object {
+	switch operator {
+	case "+":
+		value := make([]byte, 0, len(left.Value)+len(right.Value))
+		return &object.Bytes{Value: append(append(value, left.Value...), right.Value...)}
+	case "==":
+		return nativeBoolToBooleanObject(bytes.Equal(left.Value, right.Value))
+	case "!=":
+		return nativeBoolToBooleanObject(!bytes.Equal(left.Value, right.Value))
+	}
+	return newTypeError("unknown operator: BYTES %s BYTES", operator)
+}
+
+// evalBytesIndexExpression gives the byte at an index as an integer, or
+// None past either end as for arrays, and a slice for a range.
+func evalBytesIndexExpression(b *object.Bytes, index object.Object) object.Object {
+	switch index := index.(type) {
+	case *object.Integer:
+		if index.Value < 0 || index.Value >= int64(len(b.Value)) {
+			return NONE
+		}
+		return &object.Integer{Value: int64(b.Value[index.Value])}
+	case *object.Range:
+		start, end, errObj := sliceBounds("bytes", index, len(b.Value))
+		if errObj != nil {
+			return errObj
+		}
+		return &object.Bytes{Value: b.Value[start:end]}
+	}
+	return newTypeError("bytes index must be INTEGER or RANGE, got %s", index.Type())
+}
+
+// byteElements returns the bytes of b as an array of integers.
+func byteElements(b *object.Bytes) *object.Array {
+	elements := make([]object.Object, len(b.Value))
+	for i, c := range b.Value {
+		elements[i] = &object.Integer{Value: int64(c)}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func fileReadBytes(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("fileReadBytes requires 1 or 2 arguments: path, [context]")
+	}
+	pathArg, ok := args[0].(*object.String)
+	if !ok {
+		return newError("fileReadBytes: path must be a string")
+	}
+	c, errObj := contextArg("fileReadBytes", env, args, 1)
+	if errObj != nil {
+		return errObj
+	}
+	data, err := readFile(c, pathArg.Value)
+	if isDone(err) {
+		return contextError("fileReadBytes", c, env)
+	}
+	if err != nil {
+		return newError("failed to read file '%s': %s", pathArg.Value, err)
+	}
+	return &object.Bytes{Value: data}
+}
+
+func fileWriteBytes(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 2 || len(args) > 3 {
+		return newError("fileWriteBytes requires 2 or 3 arguments: path, data, [context]")
+	}
+	pathArg, ok1 := args[0].(*object.String)
+	data, ok2 := args[1].(*object.Bytes)
+	if !ok1 || !ok2 {
+		return newError("fileWriteBytes: path must be a STRING and data BYTES")
+	}
+	c, errObj := contextArg("fileWriteBytes", env, args, 2)
+	if errObj != nil {
+		return errObj
+	}
+	err := writeFile(c, pathArg.Value, data.Value, os.O_TRUNC)
+	if isDone(err) {
+		return contextError("fileWriteBytes", c, env)
+	}
+	if err != nil {
+		return newError("failed to write file '%s': %s", pathArg.Value, err)
+	}
+	return NONE
+}
@@ -0,0 +1,52 @@
+package evaluator
+
+import (
+	"strings"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// CoerceStrings is whether new interpreters let + join a string and a
+// number; see EvalContext.SetCoerceStrings.
+var CoerceStrings bool
+
+func init() {
+	builtins["concat"] = &object.Builtin{EnvFn: concatBuiltin}
+}
+
+// CoerceStrings reports whether + joins a string and a number.
+func (ctx *EvalContext) CoerceStrings() bool {
+	return ctx.coerceStrings
+}
+
+// SetCoerceStrings turns string coercion on or off. When it is on, a string
+// plus a number, either way round, is the string joined with the number as
+// print shows it, as in scripts from looser languages. When it is off, as
+// by default, that is a type mismatch.
+func (ctx *EvalContext) SetCoerceStrings(coerce bool) {
+	ctx.coerceStrings = coerce
+}
+
+// coerceConcat evaluates left + right for a string and a number when
+// coercion is on, reporting false for anything it does not apply to.
+func coerceConcat(operator string, left, right object.Object, env *object.Environment) (object.Object, bool) {
+	if operator != "+" || !contextFor(env).CoerceStrings() {
+		return nil, false
+	}
+	leftString := left.Type() == object.STRING_OBJ
+	rightString := right.Type() == object.STRING_OBJ
+	if leftString && isNumber(right) || isNumber(left) && rightString {
+		return arenaFor(env).String(object.Display(left) + object.Display(right)), true
+	}
+	return nil, false
+}
+
+// concatBuiltin implements concat(values...), which joins its arguments as
+// print shows them, whatever their types, with or without coercion.
+func concatBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	var out strings.Builder
+	for _, arg := range args {
+		out.WriteString(object.Display(arg))
+	}
+	return arenaFor(env).String(out.String())
+}
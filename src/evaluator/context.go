@@ -0,0 +1,239 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// The context module gives programs Go's deadlines and cancellation.
+// Builtins that wait take a context as an optional last argument, and
+// otherwise use the one context.run set. It is registered from init because
+// context.run calls a spell.
+func init() {
+	builtinModules["context"] = newBuiltinModule(map[string]object.Object{
+		"background": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("context.background takes no arguments")
+			}
+			return &object.Context{Value: context.Background()}
+		}},
+		"current": &object.Builtin{EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("context.current takes no arguments")
+			}
+			return &object.Context{Value: contextFor(env).Context()}
+		}},
+		"with_timeout": &object.Builtin{EnvFn: contextWithTimeout},
+		"with_cancel":  &object.Builtin{EnvFn: contextWithCancel},
+		"run":          &object.Builtin{EnvFn: contextRun},
+	})
+}
+
+// parentContext returns the context a new one derives from: the CONTEXT
+// argument at i if there is one, or the current context.
+func parentContext(fn string, env *object.Environment, args []object.Object, i int) (context.Context, object.Object) {
+	if len(args) <= i {
+		return contextFor(env).Context(), nil
+	}
+	parent, ok := args[i].(*object.Context)
+	if !ok {
+		return nil, newError("%s: parent must be a CONTEXT, got %s", fn, args[i].Type())
+	}
+	return parent.Value, nil
+}
+
+// contextWithTimeout implements context.with_timeout(seconds, [parent]),
+// a context that is done once seconds have passed or its parent is done.
+func contextWithTimeout(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("context.with_timeout requires 1 or 2 arguments: seconds, [parent]")
+	}
+	seconds, numErr := numberArg("context.with_timeout", args[0])
+	if numErr != nil {
+		return numErr
+	}
+	parent, errObj := parentContext("context.with_timeout", env, args, 1)
+	if errObj != nil {
+		return errObj
+	}
+	c, cancel := context.WithTimeout(parent, time.Duration(seconds*float64(time.Second)))
+	return &object.Context{Value: c, Cancel: cancel}
+}
+
+// contextWithCancel implements context.with_cancel([parent]), a context
+// that is done once its cancel() is called or its parent is done.
+func contextWithCancel(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError("context.with_cancel takes at most 1 argument: [parent]")
+	}
+	parent, errObj := parentContext("context.with_cancel", env, args, 0)
+	if errObj != nil {
+		return errObj
+	}
+	c, cancel := context.WithCancel(parent)
+	return &object.Context{Value: c, Cancel: cancel}
+}
+
+// contextRun implements context.run(ctx, fn, args...), which calls fn with
+// ctx as the current context, so that every builtin it waits in, however
+// deeply, gives up once ctx is done.
+func contextRun(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 2 {
+		return newError("context.run requires at least 2 arguments: context, spell, [args...]")
+	}
+	c, ok := args[0].(*object.Context)
+	if !ok {
+		return newError("context.run expects a CONTEXT, got %s", args[0].Type())
+	}
+	ctx := contextFor(env)
+	saved := ctx.done
+	ctx.done = c.Value
+	defer func() { ctx.done = saved }()
+	return evalCallExpression(args[1], args[2:], env)
+}
+
+// contextMember looks up a member of a context: cancel(), done(), err(),
+// which names why it is done or is None, and remaining(), the seconds left
+// before its deadline or None if it has none.
+func contextMember(c *object.Context, name string) object.Object {
+	var fn func() object.Object
+	switch name {
+	case "cancel":
+		fn = func() object.Object {
+			if c.Cancel == nil {
+				return newError("cancel: this context cannot be cancelled")
+			}
+			c.Cancel()
+			return NONE
+		}
+	case "done":
+		fn = func() object.Object {
+			return nativeBoolToBooleanObject(c.Value.Err() != nil)
+		}
+	case "err":
+		fn = func() object.Object {
+			if err := c.Value.Err(); err != nil {
+				return &object.String{Value: err.Error()}
+			}
+			return NONE
+		}
+	case "remaining":
+		fn = func() object.Object {
+			deadline, ok := c.Value.Deadline()
+			if !ok {
+				return NONE
+			}
+			return &object.Float{Value: max(time.Until(deadline).Seconds(), 0)}
+		}
+	default:
+		return newError("context has no member '%s'", name)
+	}
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		if len(args) != 0 {
+			return newTypeError("%s takes no arguments", name)
+		}
+		return fn()
+	}}
+}
+
+// contextArg returns the context a builtin waits on: the CONTEXT argument
+// at i, or the current context if that argument is None or absent.
+func contextArg(fn string, env *object.Environment, args []object.Object, i int) (context.Context, object.Object) {
+	if len(args) <= i || args[i].Type() == object.NONE_OBJ {
+		return contextFor(env).Context(), nil
+	}
+	c, ok := args[i].(*object.Context)
+	if !ok {
+		return nil, newError("%s: argument %d must be a CONTEXT, got %s", fn, i+1, args[i].Type())
+	}
+	return c.Value, nil
+}
+
+// isDone reports whether err is a builtin giving up because its context
+// is done.
+func isDone(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// contextError raises the error for fn giving up because c is done: a
+// TimeoutError once its deadline has passed, and a CancelledError if it was
+// cancelled.
+func contextError(fn string, c context.Context, env *object.Environment) object.Object {
+	name := "CancelledError"
+	if errors.Is(c.Err(), context.DeadlineExceeded) {
+		name = "TimeoutError"
+	}
+	return newStdlibError(name, fn+": "+c.Err().Error(), env, contextFor(env).CurrentPosition())
+}
+
+// sleep waits for d, returning early with the context's error once c is
+// done.
+func sleep(c context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+// ioChunk is how much file builtins read or write between checks of their
+// context.
+const ioChunk = 64 << 10
+
+type contextReader struct {
+	c context.Context
+	r io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.c.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p[:min(len(p), ioChunk)])
+}
+
+// readFile reads the file at path, giving up between chunks once c is done.
+func readFile(c context.Context, path string) ([]byte, error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(contextReader{c, f})
+}
+
+// writeFile writes data to the file at path, which is opened with flag as
+// well as for writing, giving up between chunks once c is done.
+func writeFile(c context.Context, path string, data []byte, flag int) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0644)
+	if err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		if err := c.Err(); err != nil {
+			f.Close()
+			return err
+		}
+		n, err := f.Write(data[:min(len(data), ioChunk)])
+		if err != nil {
+			f.Close()
+			return err
+		}
+		data = data[n:]
+	}
+	return f.Close()
+}
@@ -0,0 +1,58 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// convertInt implements int(x). A string that isn't a whole number raises a
+// ValueError and a value of a type that has no integer form a TypeError.
+func convertInt(arg object.Object, env *object.Environment) object.Object {
+	switch arg := arg.(type) {
+	case *object.String:
+		value, err := strconv.Atoi(arg.Value)
+		if errors.Is(err, strconv.ErrRange) {
+			if n, ok := new(big.Int).SetString(arg.Value, 10); ok {
+				return object.NewInt(n)
+			}
+		}
+		if err != nil {
+			return conversionError("ValueError", arg.Inspect(), "int", env)
+		}
+		return &object.Integer{Value: int64(value)}
+	case *object.Float:
+		return &object.Integer{Value: int64(arg.Value)}
+	case *object.Integer, *object.BigInteger:
+		return arg
+	}
+	return conversionError("TypeError", string(arg.Type()), "int", env)
+}
+
+// convertFloat implements float(x), raising errors as convertInt does.
+func convertFloat(arg object.Object, env *object.Environment) object.Object {
+	switch arg := arg.(type) {
+	case *object.String:
+		value, err := strconv.ParseFloat(arg.Value, 64)
+		if err != nil {
+			return conversionError("ValueError", arg.Inspect(), "float", env)
+		}
+		return &object.Float{Value: value}
+	case *object.Integer:
+		return &object.Float{Value: float64(arg.Value)}
+	case *object.BigInteger:
+		value, _ := new(big.Float).SetInt(arg.Value).Float64()
+		return &object.Float{Value: value}
+	case *object.Float:
+		return arg
+	}
+	return conversionError("TypeError", string(arg.Type()), "float", env)
+}
+
+func conversionError(kind, what, to string, env *object.Environment) object.Object {
+	message := fmt.Sprintf("cannot convert %s to %s", what, to)
+	return newStdlibError(kind, message, env, contextFor(env).CurrentPosition())
+}
@@ -0,0 +1,171 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// Limits that keep a crash report readable however large the program's
+// data is.
+const (
+	crashValueWidth = 200 // characters of each value shown
+	crashFrameVars  = 50  // variables shown per frame
+)
+
+// crashSnapshot is the call stack as it stood when an error was first
+// returned by a spell, kept so that a crash report can show the frames the
+// error has since unwound. locals holds, by depth, the scope of the spell
+// running in that frame.
+type crashSnapshot struct {
+	err       object.Object
+	frames    []CallFrame
+	locals    map[int]*object.Environment
+	panicking bool   // a Go panic is unwinding; SafeEval will supply err
+	goStack   string // where the panic started, if ReportGoStack is set
+}
+
+// RecordCrashes makes the interpreter remember the scopes an error unwinds
+// through, for WriteCrashReport. It costs a copy of the call stack each time
+// an error leaves a spell, so it is off unless a report was asked for.
+func (ctx *EvalContext) RecordCrashes() {
+	ctx.recordCrashes = true
+}
+
+// noteCrash records that err is leaving the spell whose scope is locals, at
+// the current depth of the call stack. The first spell to return an error
+// starts a new snapshot; the ones it then unwinds through add their scopes.
+func (ctx *EvalContext) noteCrash(err object.Object, locals *object.Environment) {
+	if !ctx.recordCrashes {
+		return
+	}
+	if ctx.crash == nil || ctx.crash.err != err {
+		ctx.snapshot(err)
+	}
+	ctx.crash.addLocals(len(ctx.callStack), locals)
+}
+
+// notePanic is deferred by spells while crashes are recorded, so that a Go
+// panic inside the interpreter also keeps the scopes it unwinds through.
+// SafeEval recovers the panic once it reaches the top. depth is the length
+// of the call stack when the spell started, since a panic skips the pops.
+func (ctx *EvalContext) notePanic(locals *object.Environment, depth int) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if ctx.crash == nil || !ctx.crash.panicking {
+		ctx.snapshot(nil)
+		ctx.crash.panicking = true
+		if ReportGoStack {
+			ctx.crash.goStack = string(debug.Stack())
+		}
+	}
+	ctx.crash.addLocals(depth, locals)
+	panic(r)
+}
+
+func (ctx *EvalContext) snapshot(err object.Object) {
+	ctx.crash = &crashSnapshot{
+		err:    err,
+		frames: append([]CallFrame(nil), ctx.callStack...),
+		locals: make(map[int]*object.Environment),
+	}
+}
+
+// addLocals keeps the innermost scope seen at each depth.
+func (s *crashSnapshot) addLocals(depth int, locals *object.Environment) {
+	if _, ok := s.locals[depth]; !ok && locals != nil {
+		s.locals[depth] = locals
+	}
+}
+
+// notePanicked gives the snapshot of a panic the error SafeEval made from
+// it, or takes a snapshot if no spell was running, and returns the Go stack
+// where the panic started, if it was kept.
+func (ctx *EvalContext) notePanicked(internal *object.InternalError) string {
+	if !ctx.recordCrashes {
+		return ""
+	}
+	if ctx.crash == nil || !ctx.crash.panicking {
+		ctx.snapshot(internal)
+		return ""
+	}
+	ctx.crash.err = internal
+	ctx.crash.panicking = false
+	return ctx.crash.goStack
+}
+
+// WriteCrashReport writes a report of err, a failure that ended the program
+// running in env, for attaching to a bug report: the error with its stack
+// trace, each frame of the call stack with the values of its local
+// variables, innermost first, then the program's globals. Values are
+// shortened to keep the report small. version identifies the interpreter.
+func WriteCrashReport(w io.Writer, err object.Object, env *object.Environment, version string) error {
+	ctx := contextFor(env)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Carrion crash report\n")
+	fmt.Fprintf(&b, "time:    %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "version: carrion %s (%s %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "file:    %s\n", ctx.fileName)
+	fmt.Fprintf(&b, "\nerror:\n%s\n", err.Inspect())
+
+	var frames []CallFrame
+	var locals map[int]*object.Environment
+	if ctx.crash != nil && ctx.crash.err == err {
+		frames, locals = ctx.crash.frames, ctx.crash.locals
+	}
+	fmt.Fprintf(&b, "\nframes (innermost first):\n")
+	for depth := len(frames); depth > 0; depth-- {
+		frame := frames[depth-1]
+		fmt.Fprintf(&b, "  #%d %s, called at %s\n", len(frames)-depth, frame.funcName, frame.position)
+		if scope, ok := locals[depth]; ok {
+			writeCrashVars(&b, scope)
+		} else {
+			fmt.Fprintf(&b, "      (no local variables recorded)\n")
+		}
+	}
+	fmt.Fprintf(&b, "  #%d <main>\n", len(frames))
+	writeCrashVars(&b, getGlobalEnv(env))
+
+	_, werr := io.WriteString(w, b.String())
+	return werr
+}
+
+// writeCrashVars lists the variables of one scope in name order, leaving
+// out the spells, grimoires and modules it defines and the interpreter's
+// own __names.
+func writeCrashVars(b *strings.Builder, scope *object.Environment) {
+	names := scope.GetNames()
+	sort.Strings(names)
+	shown := 0
+	for _, name := range names {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		value, _ := scope.GetLocal(name)
+		switch value.(type) {
+		case *object.Function, *object.Builtin, *object.Grimoire, *object.Namespace, *object.RecordType:
+			continue
+		}
+		if shown == crashFrameVars {
+			fmt.Fprintf(b, "      ...\n")
+			return
+		}
+		text := strings.ReplaceAll(value.Inspect(), "\n", `\n`)
+		if runes := []rune(text); len(runes) > crashValueWidth {
+			text = string(runes[:crashValueWidth]) + "..."
+		}
+		fmt.Fprintf(b, "      %s = %s\n", name, text)
+		shown++
+	}
+	if shown == 0 {
+		fmt.Fprintf(b, "      (none)\n")
+	}
+}
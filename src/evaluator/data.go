@@ -0,0 +1,77 @@
+package evaluator
+
+import (
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// makeDataGrimoire fills in what @data generates for a grimoire: an init
+// that takes each declared field in order, unless the grimoire writes its
+// own, and a to_string spell returning `Name(field=value, ...)`. Equality
+// and hashing by field happen wherever instances are compared or used as
+// hash keys.
+func makeDataGrimoire(grimoire *object.Grimoire, node *ast.GrimoireDefinition, env *object.Environment) object.Object {
+	params := make([]*ast.Parameter, 0, len(node.Fields))
+	body := &ast.BlockStatement{Token: node.Token}
+	seen := map[string]bool{}
+	for _, field := range node.Fields {
+		name := field.Name.(*ast.Identifier)
+		if seen[name.Value] {
+			return newError("grimoire %s declares field '%s' twice", node.Name.Value, name.Value)
+		}
+		seen[name.Value] = true
+		grimoire.Fields = append(grimoire.Fields, name.Value)
+		if field.TypeHint != nil {
+			if grimoire.FieldTypes == nil {
+				grimoire.FieldTypes = make(map[string]ast.Expression)
+			}
+			grimoire.FieldTypes[name.Value] = field.TypeHint
+		}
+
+		params = append(params, &ast.Parameter{Name: name, TypeHint: field.TypeHint, DefaultValue: field.Value})
+		body.Statements = append(body.Statements, &ast.AssignStatement{
+			Token: field.Token,
+			Name: &ast.DotExpression{
+				Token: field.Token,
+				Left:  &ast.Identifier{Token: name.Token, Value: "self"},
+				Right: name,
+			},
+			Operator: "=",
+			Value:    name,
+		})
+	}
+	grimoire.IsData = true
+
+	if grimoire.InitMethod == nil {
+		initFn, errObj := newFunction(grimoire.Name+".init", params, body, env)
+		if errObj != nil {
+			return errObj
+		}
+		grimoire.InitMethod = initFn
+	}
+
+	for _, method := range node.Methods {
+		if method.Name.Value == "to_string" {
+			return nil
+		}
+	}
+	toString := &ast.ReturnStatement{
+		Token: node.Token,
+		ReturnValue: &ast.CallExpression{
+			Token:     node.Token,
+			Function:  &ast.Identifier{Token: node.Token, Value: "str"},
+			Arguments: []ast.Expression{&ast.Identifier{Token: node.Token, Value: "self"}},
+		},
+	}
+	fn, errObj := newFunction(grimoire.Name+".to_string", nil, &ast.BlockStatement{Token: node.Token, Statements: []ast.Statement{toString}}, env)
+	if errObj != nil {
+		return errObj
+	}
+	grimoire.Methods["to_string"] = fn
+	return nil
+}
+
+func isDataInstance(obj object.Object) bool {
+	instance, ok := obj.(*object.Instance)
+	return ok && instance.Grimoire.IsData
+}
@@ -0,0 +1,40 @@
+package evaluator
+
+import (
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// Debugger is called before each statement of a program or block is
+// evaluated, with the environment the statement runs in. The statement runs
+// once Step returns; a non-nil error stops the program instead.
+type Debugger interface {
+	Step(stmt ast.Statement, env *object.Environment, ctx *EvalContext) error
+}
+
+// SetDebugger attaches d to the interpreter, or detaches the current
+// debugger if d is nil.
+func (ctx *EvalContext) SetDebugger(d Debugger) {
+	ctx.debugger = d
+}
+
+// Depth returns the number of calls currently in progress.
+func (ctx *EvalContext) Depth() int {
+	return len(ctx.callStack)
+}
+
+// debugStep hands stmt to the attached debugger, if any. The empty
+// statements the parser leaves in blocks are not reported.
+func debugStep(stmt ast.Statement, env *object.Environment) object.Object {
+	ctx, ok := env.Context().(*EvalContext)
+	if !ok || ctx.debugger == nil {
+		return nil
+	}
+	if es, ok := stmt.(*ast.ExpressionStatement); ok && es.Expression == nil {
+		return nil
+	}
+	if err := ctx.debugger.Step(stmt, env, ctx); err != nil {
+		return newError("%s", err)
+	}
+	return nil
+}
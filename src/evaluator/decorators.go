@@ -0,0 +1,113 @@
+package evaluator
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// The standard decorators in munin/decorators.crl wrap spells with these
+// builtins, since a wrapper written in Carrion could only pass on a fixed
+// number of arguments.
+func init() {
+	builtins["memoizeSpell"] = &object.Builtin{Fn: memoizeSpell}
+	builtins["timeSpell"] = &object.Builtin{Fn: timeSpell}
+	builtins["deprecateSpell"] = &object.Builtin{Fn: deprecateSpell}
+}
+
+// spellName is how the standard decorators refer to fn in messages.
+func spellName(fn object.Object) string {
+	switch fn := fn.(type) {
+	case *object.Function:
+		if fn.Name != "" {
+			return fn.Name
+		}
+	case *object.BoundMethod:
+		return fn.Method.Name
+	}
+	return "spell"
+}
+
+// memoizeSpell wraps fn so that a call with the same arguments as an
+// earlier one gives the earlier result without calling fn again. Calls with
+// an argument that cannot be a hash key, and calls that fail, are not
+// remembered.
+func memoizeSpell(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("memoize requires 1 argument: spell")
+	}
+	fn := args[0]
+	var mu sync.Mutex
+	cache := make(map[object.HashKey]object.Object)
+	return &object.Builtin{EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+		key, ok := object.HashKeyOf(&object.Tuple{Elements: args})
+		if !ok {
+			return evalCallExpression(fn, args, env)
+		}
+		mu.Lock()
+		result, found := cache[key]
+		mu.Unlock()
+		if found {
+			return result
+		}
+		result = evalCallExpression(fn, args, env)
+		if !isError(result) {
+			mu.Lock()
+			cache[key] = result
+			mu.Unlock()
+		}
+		return result
+	}}
+}
+
+// timeSpell wraps fn so that each call reports how long it took on the
+// interpreter's error output.
+func timeSpell(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("timeit requires 1 argument: spell")
+	}
+	fn := args[0]
+	name := spellName(fn)
+	return &object.Builtin{EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+		start := time.Now()
+		result := evalCallExpression(fn, args, env)
+		elapsed := time.Since(start).Round(time.Microsecond)
+		fmt.Fprintf(contextFor(env).Stderr(), "%s took %s\n", name, elapsed)
+		return result
+	}}
+}
+
+// deprecateSpell implements deprecated, used either as @deprecated or as
+// @deprecated("reason"), in which case it gives the decorator. The wrapped
+// spell warns on the interpreter's error output the first time it is
+// called.
+func deprecateSpell(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("deprecated requires 1 argument: spell or reason")
+	}
+	if reason, ok := args[0].(*object.String); ok {
+		return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("deprecated requires 1 argument: spell")
+			}
+			return deprecated(args[0], reason.Value)
+		}}
+	}
+	return deprecated(args[0], "")
+}
+
+func deprecated(fn object.Object, reason string) object.Object {
+	warning := spellName(fn) + " is deprecated"
+	if reason != "" {
+		warning += ": " + reason
+	}
+	var once sync.Once
+	return &object.Builtin{EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+		once.Do(func() {
+			fmt.Fprintf(contextFor(env).Stderr(), "warning: %s\n", warning)
+		})
+		return evalCallExpression(fn, args, env)
+	}}
+}
@@ -0,0 +1,135 @@
+package evaluator
+
+import (
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// ensnareClause finds the first clause of an attempt that catches err: a
+// bare `ensnare:`, or one naming the grimoire of err or one it inherits
+// from, either as the grimoire itself or as its name in a string. It gives
+// the clause, or an error from evaluating a condition.
+func ensnareClause(clauses []*ast.EnsnareClause, err *object.CustomError, env *object.Environment) (*ast.EnsnareClause, object.Object) {
+	for _, clause := range clauses {
+		if clause.Condition == nil {
+			return clause, nil
+		}
+		condition := Eval(clause.Condition, env)
+		if isError(condition) {
+			return nil, condition
+		}
+		if ensnares(condition, err) {
+			return clause, nil
+		}
+	}
+	return nil, nil
+}
+
+func ensnares(condition object.Object, err *object.CustomError) bool {
+	switch condition := condition.(type) {
+	case *object.Grimoire:
+		for grimoire := err.ErrorType; grimoire != nil; grimoire = grimoire.Inherits {
+			if grimoire == condition {
+				return true
+			}
+		}
+	case *object.String:
+		if err.Name == condition.Value {
+			return true
+		}
+		for grimoire := err.ErrorType; grimoire != nil; grimoire = grimoire.Inherits {
+			if grimoire.Name == condition.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// errorValue is what `ensnare ... as e` binds e to: the instance that was
+// raised, or for an error raised from a string, an Exception whose message
+// is the string, which becomes the error's instance so that `raise ... from
+// e` can find the error again.
+func errorValue(err *object.CustomError, env *object.Environment) object.Object {
+	if err.Instance != nil {
+		return err.Instance
+	}
+	message := &object.String{Value: err.Message}
+	obj, _ := getGlobalEnv(env).Get("Exception")
+	grimoire, ok := obj.(*object.Grimoire)
+	if !ok {
+		return message
+	}
+	err.Instance = object.NewInstance(grimoire)
+	err.Instance.Set("message", message)
+	return err.Instance
+}
+
+// reraise implements a bare `raise`, which raises again the error whose
+// ensnare clause is running, as it was first raised.
+func reraise(node *ast.RaiseStatement, env *object.Environment) object.Object {
+	ensnared := contextFor(env).ensnared
+	if len(ensnared) == 0 {
+		err := newError("raise with no error: a bare raise must be inside an ensnare clause")
+		err.Position = node.Token.Position
+		return err
+	}
+	return ensnared[len(ensnared)-1]
+}
+
+// errorCause gives the error that `raise ... from cause` records as the
+// cause, or nil for `from None`. An instance bound by `ensnare ... as e` is
+// the error it was raised as, with its own position and stack trace.
+func errorCause(cause object.Object, env *object.Environment) (object.Object, object.Object) {
+	if isError(cause) {
+		return nil, cause
+	}
+	switch cause := cause.(type) {
+	case *object.None:
+		return nil, nil
+	case *object.Instance:
+		ensnared := contextFor(env).ensnared
+		for i := len(ensnared) - 1; i >= 0; i-- {
+			if ensnared[i].Instance == cause {
+				return ensnared[i], nil
+			}
+		}
+		err := object.NewCustomError(cause.Grimoire.Name, "")
+		if msg, ok := cause.Get("message"); ok {
+			if msgStr, ok := msg.(*object.String); ok {
+				err.Message = msgStr.Value
+			}
+		}
+		err.ErrorType, err.Instance = cause.Grimoire, cause
+		return err, nil
+	case *object.String:
+		return object.NewCustomError("Error", cause.Value), nil
+	}
+	return nil, newError("cannot raise from non-error object: %s", cause.Type())
+}
+
+// runtimeErrorKind is the kind of error the interpreter error err is
+// raised as: the Kind it was made with, or RuntimeError.
+func runtimeErrorKind(err *object.Error) string {
+	if err.Kind != "" {
+		return err.Kind
+	}
+	return "RuntimeError"
+}
+
+// catchable gives the error an attempt can ensnare for err. An error the
+// interpreter itself gives, such as for a missing name or adding a string
+// to a number, becomes one of the standard library's kinds, keeping where
+// it happened.
+func catchable(err object.Object, env *object.Environment) (*object.CustomError, bool) {
+	switch err := err.(type) {
+	case *object.CustomError:
+		return err, true
+	case *object.Error:
+		raised := newStdlibError(runtimeErrorKind(err), err.Message, env, err.Position)
+		raised.StackTrace = err.StackTrace
+		raised.Cause = err.Cause
+		return raised, true
+	}
+	return nil, false
+}
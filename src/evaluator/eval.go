@@ -0,0 +1,111 @@
+package evaluator
+
+import (
+	"slices"
+
+	"github.com/javanhut/Carrion/src/lexer"
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/parser"
+)
+
+func init() {
+	builtins["eval"] = &object.Builtin{EnvFn: evalBuiltin}
+	builtins["compile"] = &object.Builtin{EnvFn: compileBuiltin}
+}
+
+// compileBuiltin implements compile(source, [name]), which parses source
+// into code for eval, naming it name in positions. A syntax error is raised
+// as a SyntaxError with the line and column of the first mistake.
+func compileBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("compile requires 1 or 2 arguments: source, [name]")
+	}
+	source, ok := args[0].(*object.String)
+	if !ok {
+		return newError("compile expects the source as a STRING, got %s", args[0].Type())
+	}
+	name := "<eval>"
+	if len(args) == 2 {
+		str, ok := args[1].(*object.String)
+		if !ok {
+			return newError("compile expects the name as a STRING, got %s", args[1].Type())
+		}
+		name = str.Value
+	}
+	return compileSource(source.Value, name, env)
+}
+
+func compileSource(source, name string, env *object.Environment) object.Object {
+	p := parser.New(lexer.New(source, name))
+	program := p.ParseProgram()
+	if errs := p.ParseErrors(); len(errs) > 0 {
+		err := newStdlibError("SyntaxError", errs[0].Error(), env, contextFor(env).CurrentPosition())
+		if err.Instance != nil {
+			err.Instance.Set("line", &object.Integer{Value: int64(errs[0].Position.Line)})
+			err.Instance.Set("column", &object.Integer{Value: int64(errs[0].Position.Column)})
+		}
+		return err
+	}
+	return &object.Code{Name: name, Program: program}
+}
+
+// evalBuiltin implements eval(code, [scope]), which runs code, a string of
+// source or what compile gave, and returns the value of its last statement.
+// Without scope the code runs in the caller's scope, so it sees and changes
+// the caller's variables. Given a hash, it runs in a fresh environment with
+// the standard library and a variable for each key of the hash, and the
+// variables it ends with are written back to the hash.
+func evalBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("eval requires 1 or 2 arguments: code, [scope]")
+	}
+	var code *object.Code
+	switch arg := args[0].(type) {
+	case *object.Code:
+		code = arg
+	case *object.String:
+		compiled := compileSource(arg.Value, "<eval>", env)
+		if isError(compiled) {
+			return compiled
+		}
+		code = compiled.(*object.Code)
+	default:
+		return newError("eval expects source as a STRING or CODE, got %s", args[0].Type())
+	}
+	if len(args) == 1 {
+		return Eval(code.Program, env)
+	}
+
+	scope, ok := args[1].(*object.Hash)
+	if !ok {
+		return newError("eval expects the scope as a HASH, got %s", args[1].Type())
+	}
+	if scope.Frozen {
+		return newError("eval cannot update a frozen HASH")
+	}
+	fresh := object.NewEnvironment()
+	fresh.SetContext(contextFor(env))
+	if err := LoadMuninStdlib(fresh); err != nil {
+		return newError("eval: %s", err)
+	}
+	stdlib := fresh.GetNames()
+	for _, pair := range scope.Pairs {
+		name, ok := pair.Key.(*object.String)
+		if !ok {
+			return newError("eval: scope keys must be STRINGs, got %s", pair.Key.Type())
+		}
+		fresh.Set(name.Value, pair.Value)
+	}
+
+	result := Eval(code.Program, fresh)
+	for _, name := range scopeNames(fresh) {
+		key := &object.String{Value: name}
+		_, given := scope.Pairs[key.HashKey()]
+		if !given && slices.Contains(stdlib, name) {
+			continue
+		}
+		value, _ := fresh.GetLocal(name)
+		scope.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+	return result
+}
@@ -1,22 +1,49 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"math"
+	"math/big"
+	"math/rand"
 	"os"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/javanhut/Carrion/src/ast"
-	"github.com/javanhut/Carrion/src/lexer"
 	"github.com/javanhut/Carrion/src/object"
-	"github.com/javanhut/Carrion/src/parser"
 	"github.com/javanhut/Carrion/src/token"
 )
 
+// DefaultRecursionLimit is the call depth at which a RecursionError is
+// raised unless the program sets its own limit.
+const DefaultRecursionLimit = 5000
+
 // EvalContext tracks call stack and other context information during evaluation
 type EvalContext struct {
-	callStack []CallFrame
-	fileName  string
+	callStack      []CallFrame
+	fileName       string
+	rng            *rand.Rand
+	stdout         io.Writer
+	stderr         io.Writer
+	recursionLimit int
+	debugger       Debugger
+	profiler       *Profiler
+	imports        []string                     // files imported so far, in order
+	modules        map[string]*object.Namespace // what each imported file exports by moduleKey, once it has loaded
+	importStack    []string                     // the modules being loaded, outermost first
+	strictTypes    bool
+	coerceStrings  bool
+	strictMatch    bool
+	recordCrashes  bool
+	arena          *Arena // where numbers and strings are allocated; nil for the heap
+	crash          *crashSnapshot // the last error to leave a spell, if recordCrashes is set
+	done           context.Context // what builtins that wait give up on; nil for never
+	ensnared       []*object.CustomError // the errors whose ensnare clauses are running, innermost last
+	handles        handleSet             // what builtins opened for the program and it has not closed
 }
 
 // CallFrame represents a function call in the call stack
@@ -27,25 +54,41 @@ type CallFrame struct {
 
 // NewEvalContext creates a new evaluation context
 func NewEvalContext(fileName string) *EvalContext {
-	return &EvalContext{
-		callStack: []CallFrame{},
-		fileName:  fileName,
-	}
+	ctx := &EvalContext{
+		callStack:   []CallFrame{},
+		fileName:    fileName,
+		strictTypes:   StrictTypes,
+		coerceStrings: CoerceStrings,
+		strictMatch:   StrictMatch,
+	}
+	if Arenas {
+		ctx.arena = &Arena{}
+		arenasInUse.Add(1)
+	}
+	return ctx
 }
 
 // PushCallFrame adds a new frame to the call stack
 func (ctx *EvalContext) PushCallFrame(funcName string, position token.Position) {
-	position.File = ctx.fileName // Ensure the filename is set
+	if position.File == "" {
+		position.File = ctx.fileName // Ensure the filename is set
+	}
 	ctx.callStack = append(ctx.callStack, CallFrame{
 		funcName: funcName,
 		position: position,
 	})
+	if ctx.profiler != nil {
+		ctx.profiler.enter(funcName)
+	}
 }
 
 // PopCallFrame removes the most recent frame from the call stack
 func (ctx *EvalContext) PopCallFrame() {
 	if len(ctx.callStack) > 0 {
 		ctx.callStack = ctx.callStack[:len(ctx.callStack)-1]
+		if ctx.profiler != nil {
+			ctx.profiler.exit()
+		}
 	}
 }
 
@@ -61,6 +104,93 @@ func (ctx *EvalContext) GetCallStack() []object.StackTraceEntry {
 	return entries
 }
 
+// Rand returns the interpreter's random number generator, seeding it from
+// the clock on first use.
+func (ctx *EvalContext) Rand() *rand.Rand {
+	if ctx.rng == nil {
+		ctx.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return ctx.rng
+}
+
+// Seed resets the interpreter's random number generator to a fixed seed so
+// that subsequent random values are reproducible.
+func (ctx *EvalContext) Seed(seed int64) {
+	ctx.rng = rand.New(rand.NewSource(seed))
+}
+
+// Stdout returns the writer used by print and other output builtins.
+func (ctx *EvalContext) Stdout() io.Writer {
+	if ctx.stdout == nil {
+		return os.Stdout
+	}
+	return ctx.stdout
+}
+
+// SetStdout redirects the interpreter's output, e.g. to capture it.
+func (ctx *EvalContext) SetStdout(w io.Writer) {
+	ctx.stdout = w
+}
+
+// Stderr returns the writer that commands run by the program report errors
+// to.
+func (ctx *EvalContext) Stderr() io.Writer {
+	if ctx.stderr == nil {
+		return os.Stderr
+	}
+	return ctx.stderr
+}
+
+// SetStderr redirects the interpreter's error output.
+func (ctx *EvalContext) SetStderr(w io.Writer) {
+	ctx.stderr = w
+}
+
+// Context returns the context that builtins which wait, such as osSleep
+// and osRunCommand, give up on when no other is passed to them.
+func (ctx *EvalContext) Context() context.Context {
+	if ctx.done == nil {
+		return context.Background()
+	}
+	return ctx.done
+}
+
+// SetContext makes builtins that wait give up once c is done, so that a
+// program embedding the interpreter can bound or cancel what it runs.
+func (ctx *EvalContext) SetContext(c context.Context) {
+	ctx.done = c
+}
+
+// RecursionLimit returns the maximum call depth.
+func (ctx *EvalContext) RecursionLimit() int {
+	if ctx.recursionLimit <= 0 {
+		return DefaultRecursionLimit
+	}
+	return ctx.recursionLimit
+}
+
+// SetRecursionLimit changes the maximum call depth.
+func (ctx *EvalContext) SetRecursionLimit(limit int) {
+	ctx.recursionLimit = limit
+}
+
+// ImportedFiles returns the files the program has imported, in the order
+// they were first imported.
+func (ctx *EvalContext) ImportedFiles() []string {
+	return append([]string(nil), ctx.imports...)
+}
+
+// contextFor returns the EvalContext of the interpreter that owns env,
+// attaching a fresh one to the global environment on first use.
+func contextFor(env *object.Environment) *EvalContext {
+	if ctx, ok := env.Context().(*EvalContext); ok {
+		return ctx
+	}
+	ctx := NewEvalContext("")
+	getGlobalEnv(env).SetContext(ctx)
+	return ctx
+}
+
 // CurrentPosition returns the position of the current execution point
 func (ctx *EvalContext) CurrentPosition() token.Position {
 	if len(ctx.callStack) > 0 {
@@ -70,10 +200,9 @@ func (ctx *EvalContext) CurrentPosition() token.Position {
 }
 
 var (
-	NONE          = &object.None{Value: "None"}
-	TRUE          = &object.Boolean{Value: true}
-	FALSE         = &object.Boolean{Value: false}
-	importedFiles = map[string]bool{}
+	NONE  = &object.None{Value: "None"}
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
@@ -89,8 +218,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalIfExpression(node, env)
 
 	case *ast.StopStatement:
+		if node.Label != nil {
+			return &object.Stop{Label: node.Label.Value}
+		}
 		return object.STOP
 	case *ast.SkipStatement:
+		if node.Label != nil {
+			return &object.Skip{Label: node.Label.Value}
+		}
 		return object.SKIP
 	case *ast.CheckStatement:
 		cond := Eval(node.Condition, env)
@@ -102,11 +237,13 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			if node.Message != nil {
 				m := Eval(node.Message, env)
 				if !isError(m) {
-					msg = m.Inspect()
+					msg = object.Display(m)
 				}
 			}
 
-			return object.NewCustomError("Assertion Check Failed: ", msg)
+			checkErr := object.NewCustomError("Assertion Check Failed: ", msg, node.Token.Position)
+			checkErr.AddStackEntry(node.Token.Position, env.GetFunctionName())
+			return checkErr
 		}
 		return object.NONE
 
@@ -156,21 +293,35 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(left) {
 			return left
 		}
-		result := evalInfixExpression(node.Operator, left, right)
-
+		if p, ok := left.(*object.Pipeline); ok {
+			return pipelineOperator(node.Operator, p, right, env, node.Token.Position)
+		}
+		if result, ok := coerceConcat(node.Operator, left, right, env); ok {
+			return result
+		}
+		result := evalInfixExpression(node.Operator, left, right, arenaFor(env))
+		if result == errDivisionByZero {
+			return newStdlibError("DivisionByZeroError", "division by zero", env, node.Token.Position)
+		}
 		return result
 	case *ast.PostfixExpression:
 		return evalPostfixIncrementDecrement(node.Operator, node, env)
 
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			return &object.BigInteger{Value: node.Big}
+		}
+		return arenaFor(env).Integer(node.Value)
 	case *ast.FloatLiteral:
-		return &object.Float{Value: node.Value}
+		return arenaFor(env).Float(node.Value)
 	case *ast.FStringLiteral:
 		return evalFStringLiteral(node, env)
 	case *ast.NoneLiteral:
 		return object.NONE
 	case *ast.ReturnStatement:
+		if call, ok := node.ReturnValue.(*ast.CallExpression); ok && inSpellBody(env) {
+			return evalTailCall(call, env)
+		}
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
@@ -180,8 +331,24 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.AssignStatement:
 		return evalAssignStatement(node, env)
+	case *ast.ScopeStatement:
+		return evalScopeStatement(node, env)
+	case *ast.PragmaStatement:
+		return NONE
+	case *ast.ExportStatement:
+		if inSpellBody(env) {
+			return newError("export: can only be used at the top level of a module")
+		}
+		for _, name := range node.Names {
+			env.Export(name.Value)
+		}
+		return NONE
+	case *ast.RecordDefinition:
+		return evalRecordDefinition(node, env)
 	case *ast.WhileStatement:
 		return evalWhileStatement(node, env)
+	case *ast.AutocloseStatement:
+		return evalAutocloseStatement(node, env)
 	case *ast.ForStatement:
 		return evalForStatement(node, env)
 	case *ast.ImportStatement:
@@ -203,18 +370,23 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.BytesLiteral:
+		return &object.Bytes{Value: node.Value}
 	case *ast.TupleLiteral:
 		return evalTupleLiteral(node, env)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 	case *ast.FunctionDefinition:
-		fnObj := &object.Function{
-			Parameters: node.Parameters,
-			Body:       node.Body,
-			Env:        env,
+		fnObj, errObj := newFunction(node.Name.Value, node.Parameters, node.Body, env)
+		if errObj != nil {
+			return errObj
 		}
-		env.Set(node.Name.Value, fnObj)
-		return fnObj
+		decorated := decorate(fnObj, node.Decorators, env)
+		if isError(decorated) {
+			return decorated
+		}
+		env.Set(node.Name.Value, decorated)
+		return decorated
 	case *ast.DotExpression:
 		return evalDotExpression(node, env)
 	case *ast.IndexExpression:
@@ -262,12 +434,17 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 	case *ast.GrimoireDefinition:
 		return evalGrimoireDefinition(node, env)
+	case *ast.ExtendStatement:
+		return evalExtendStatement(node, env)
 	case *ast.AttemptStatement:
 		return evalAttemptStatement(node, env)
 	case *ast.IgnoreStatement:
 		return object.NONE
 	case *ast.CallExpression:
-		return evalCallExpression(Eval(node.Function, env), evalExpressions(node.Arguments, env), env)
+		if dot, ok := node.Function.(*ast.DotExpression); ok {
+			return evalMethodCall(node, dot, env)
+		}
+		return evalCall(node, Eval(node.Function, env), env)
 
 	}
 	return NONE
@@ -285,7 +462,15 @@ func evalFStringLiteral(fslit *ast.FStringLiteral, env *object.Environment) obje
 			if isError(val) {
 				return val
 			}
-			sb.WriteString(val.Inspect())
+			if p.Spec == "" {
+				sb.WriteString(object.Display(val))
+				continue
+			}
+			text, err := applyFormatSpec(val, p.Spec)
+			if err != nil {
+				return newError("f-string: %s", err)
+			}
+			sb.WriteString(text)
 		}
 	}
 
@@ -296,11 +481,11 @@ func evalArcaneGrimoire(node *ast.ArcaneGrimoire, env *object.Environment) objec
 	methods := make(map[string]*object.Function)
 
 	for _, method := range node.Methods {
-		methods[method.Name.Value] = &object.Function{
-			Parameters: method.Parameters,
-			Body:       method.Body,
-			Env:        env,
+		fn, errObj := newFunction(node.Name.Value+"."+method.Name.Value, method.Parameters, method.Body, env)
+		if errObj != nil {
+			return errObj
 		}
+		methods[method.Name.Value] = fn
 	}
 
 	grimoire := &object.Grimoire{
@@ -314,11 +499,91 @@ func evalArcaneGrimoire(node *ast.ArcaneGrimoire, env *object.Environment) objec
 	return grimoire
 }
 
+func evalScopeStatement(node *ast.ScopeStatement, env *object.Environment) object.Object {
+	if env.GetOuter() == nil {
+		// Already at module scope, where every name is global.
+		if node.Token.Type == token.GLOBAL {
+			return NONE
+		}
+		return newError("outer: can only be used inside a spell")
+	}
+
+	for _, name := range node.Names {
+		if _, ok := env.GetLocal(name.Value); ok {
+			return newError("%s: '%s' is already a local variable", node.Token.Literal, name.Value)
+		}
+		var target *object.Environment
+		if node.Token.Type == token.GLOBAL {
+			target = getGlobalEnv(env)
+		} else {
+			target = env.GetOuter().Owner(name.Value)
+			if target == nil {
+				return newError("outer: no binding for '%s' in an enclosing scope", name.Value)
+			}
+		}
+		env.Bind(name.Value, target)
+	}
+	return NONE
+}
+
+// checkDeclared checks an assignment to name. `var` declares name in the
+// current scope, which a strict file may only do once. A strict file may
+// otherwise only assign to names declared in the current scope, by `var`,
+// as a parameter or loop variable, or with `global` or `outer`.
+func checkDeclared(node *ast.AssignStatement, name *ast.Identifier, env *object.Environment) object.Object {
+	if !node.Strict {
+		return nil
+	}
+	declared := env.Declared(name.Value)
+	switch {
+	case node.Declare && declared:
+		message := fmt.Sprintf("'%s' is already declared in this scope", name.Value)
+		return newStdlibError("NameError", message, env, node.Token.Position)
+	case !node.Declare && !declared:
+		message := fmt.Sprintf("assignment to undeclared variable '%s'; declare it with var", name.Value)
+		return newStdlibError("NameError", message, env, node.Token.Position)
+	}
+	return nil
+}
+
+// newRecursionError builds the RecursionError raised when a call would go
+// past the recursion limit.
+func newRecursionError(ctx *EvalContext, env *object.Environment, position token.Position) *object.CustomError {
+	message := fmt.Sprintf("maximum recursion depth of %d exceeded", ctx.RecursionLimit())
+	return newStdlibError("RecursionError", message, env, position)
+}
+
+// newStdlibError builds an error that the interpreter raises itself, such as
+// RecursionError. It is tied to the stdlib grimoire of the same name when
+// loaded so that `ensnare (RecursionError)` catches it.
+func newStdlibError(name, message string, env *object.Environment, position token.Position) *object.CustomError {
+	err := object.NewCustomError(name, message, position)
+	if obj, ok := getGlobalEnv(env).Get(name); ok {
+		if grimoire, ok := obj.(*object.Grimoire); ok {
+			err.ErrorType = grimoire
+			err.Instance = object.NewInstance(grimoire)
+			err.Instance.Set("message", &object.String{Value: message})
+		}
+	}
+	err.AddStackEntry(position, env.GetFunctionName())
+	return err
+}
+
 func evalRaiseStatement(node *ast.RaiseStatement, env *object.Environment) object.Object {
+	if node.Error == nil {
+		return reraise(node, env)
+	}
 	errObj := Eval(node.Error, env)
 	if isError(errObj) {
 		return errObj
 	}
+	var cause object.Object
+	if node.Cause != nil {
+		var causeErr object.Object
+		if cause, causeErr = errorCause(Eval(node.Cause, env), env); causeErr != nil {
+			return causeErr
+		}
+	}
 
 	// Get position information from the token
 	position := node.Token.Position
@@ -332,7 +597,7 @@ func evalRaiseStatement(node *ast.RaiseStatement, env *object.Environment) objec
 
 	if instance, ok := errObj.(*object.Instance); ok {
 		message := ""
-		if msg, ok := instance.Env.Get("message"); ok {
+		if msg, ok := instance.Get("message"); ok {
 			if msgStr, ok := msg.(*object.String); ok {
 				message = msgStr.Value
 			}
@@ -345,6 +610,7 @@ func evalRaiseStatement(node *ast.RaiseStatement, env *object.Environment) objec
 			Instance:  instance,
 			Position:  position,
 			StackTrace: []object.StackTraceEntry{},
+			Cause:     cause,
 		}
 		
 		// Add current position to stack trace with function context
@@ -356,6 +622,7 @@ func evalRaiseStatement(node *ast.RaiseStatement, env *object.Environment) objec
 	if str, ok := errObj.(*object.String); ok {
 		customErr := object.NewCustomError("Error", str.Value, position)
 		customErr.AddStackEntry(position, functionName)
+		customErr.Cause = cause
 		return customErr
 	}
 
@@ -365,32 +632,29 @@ func evalRaiseStatement(node *ast.RaiseStatement, env *object.Environment) objec
 	return err
 }
 
+// evalAttemptStatement runs an attempt and the first of its ensnare clauses
+// that catches the error it raises, if any. The resolve block runs last
+// however the rest finishes, by falling off the end, a return, stop or skip,
+// or an error whether ensnared or not.
 func evalAttemptStatement(node *ast.AttemptStatement, env *object.Environment) object.Object {
 	var result object.Object
 
-	tryResult := Eval(node.TryBlock, env)
+	tryResult := forceTailCall(Eval(node.TryBlock, env), env)
 
 	if isError(tryResult) {
-		if customErr, ok := tryResult.(*object.CustomError); ok {
-			for _, ensnare := range node.EnsnareClauses {
-
-				condition := Eval(ensnare.Condition, env)
-				if isError(condition) {
-					result = condition
-					break
-				}
-
-				if grimoire, ok := condition.(*object.Grimoire); ok {
-					if customErr.ErrorType == grimoire {
-						result = Eval(ensnare.Consequence, env)
-						break
-					}
-				} else if str, ok := condition.(*object.String); ok {
-					if customErr.Name == str.Value {
-						result = Eval(ensnare.Consequence, env)
-						break
-					}
+		if customErr, ok := catchable(tryResult, env); ok {
+			clause, errObj := ensnareClause(node.EnsnareClauses, customErr, env)
+			switch {
+			case errObj != nil:
+				result = errObj
+			case clause != nil:
+				if clause.Alias != nil {
+					env.Set(clause.Alias.Value, errorValue(customErr, env))
 				}
+				ctx := contextFor(env)
+				ctx.ensnared = append(ctx.ensnared, customErr)
+				result = Eval(clause.Consequence, env)
+				ctx.ensnared = ctx.ensnared[:len(ctx.ensnared)-1]
 			}
 		}
 
@@ -402,8 +666,11 @@ func evalAttemptStatement(node *ast.AttemptStatement, env *object.Environment) o
 	}
 
 	if node.ResolveBlock != nil {
-		resolveResult := Eval(node.ResolveBlock, env)
-		if isError(resolveResult) {
+		result = forceTailCall(result, env)
+		// A return, stop, skip or error in the resolve block replaces
+		// whatever the attempt was going to give, even an uncaught error.
+		resolveResult := forceTailCall(Eval(node.ResolveBlock, env), env)
+		if isControlSignal(resolveResult) {
 			return resolveResult
 		}
 	}
@@ -418,12 +685,11 @@ func evalMatchStatement(ms *ast.MatchStatement, env *object.Environment) object.
 	}
 
 	for _, caseClause := range ms.Cases {
-		caseCondition := Eval(caseClause.Condition, env)
-		if isError(caseCondition) {
-			return caseCondition
+		matched, errObj := matchesCase(caseClause.Condition, matchValue, env)
+		if errObj != nil {
+			return errObj
 		}
-
-		if isEqual(matchValue, caseCondition) {
+		if matched {
 			return Eval(caseClause.Body, env)
 		}
 	}
@@ -432,10 +698,13 @@ func evalMatchStatement(ms *ast.MatchStatement, env *object.Environment) object.
 		return Eval(ms.Default.Body, env)
 	}
 
-	return NONE
+	return unmatched(ms, matchValue, env)
 }
 
 func isEqual(obj1, obj2 object.Object) bool {
+	if (obj1.Type() == object.FLOAT_OBJ || obj2.Type() == object.FLOAT_OBJ) && isNumber(obj1) && isNumber(obj2) {
+		return toFloat(obj1) == toFloat(obj2)
+	}
 	switch obj1 := obj1.(type) {
 	case *object.Integer:
 		if obj2, ok := obj2.(*object.Integer); ok {
@@ -445,6 +714,79 @@ func isEqual(obj1, obj2 object.Object) bool {
 		if obj2, ok := obj2.(*object.String); ok {
 			return obj1.Value == obj2.Value
 		}
+	case *object.BigInteger:
+		if obj2, ok := obj2.(*object.BigInteger); ok {
+			return obj1.Value.Cmp(obj2.Value) == 0
+		}
+	case *object.Bytes:
+		if obj2, ok := obj2.(*object.Bytes); ok {
+			return bytes.Equal(obj1.Value, obj2.Value)
+		}
+	case *object.Boolean:
+		if obj2, ok := obj2.(*object.Boolean); ok {
+			return obj1.Value == obj2.Value
+		}
+	case *object.Float:
+		if obj2, ok := obj2.(*object.Float); ok {
+			return obj1.Value == obj2.Value
+		}
+	case *object.None:
+		_, ok := obj2.(*object.None)
+		return ok
+	case *object.Record:
+		obj2, ok := obj2.(*object.Record)
+		if !ok || obj1.RecordType != obj2.RecordType {
+			return false
+		}
+		for i := range obj1.Values {
+			if !isEqual(obj1.Values[i], obj2.Values[i]) {
+				return false
+			}
+		}
+		return true
+	case *object.Array:
+		obj2, ok := obj2.(*object.Array)
+		return ok && elementsEqual(obj1.Elements, obj2.Elements)
+	case *object.Tuple:
+		obj2, ok := obj2.(*object.Tuple)
+		return ok && elementsEqual(obj1.Elements, obj2.Elements)
+	case *object.IntArray, *object.FloatArray:
+		return typedArraysEqual(obj1, obj2)
+	case *object.Hash:
+		obj2, ok := obj2.(*object.Hash)
+		if !ok || len(obj1.Pairs) != len(obj2.Pairs) {
+			return false
+		}
+		for key, pair := range obj1.Pairs {
+			other, ok := obj2.Pairs[key]
+			if !ok || !isEqual(pair.Value, other.Value) {
+				return false
+			}
+		}
+		return true
+	case *object.Instance:
+		obj2, ok := obj2.(*object.Instance)
+		if !ok {
+			return false
+		}
+		if obj1 == obj2 {
+			return true
+		}
+		if !obj1.Grimoire.IsData || obj1.Grimoire != obj2.Grimoire {
+			return false
+		}
+		values1, values2 := obj1.FieldValues(), obj2.FieldValues()
+		for i := range values1 {
+			if !isEqual(values1[i], values2[i]) {
+				return false
+			}
+		}
+		return true
+	case *object.Function:
+		return obj1 == obj2
+	case *object.BoundMethod:
+		obj2, ok := obj2.(*object.BoundMethod)
+		return ok && obj1.Instance == obj2.Instance && obj1.Method == obj2.Method
 
 	default:
 		return false
@@ -452,14 +794,35 @@ func isEqual(obj1, obj2 object.Object) bool {
 	return false
 }
 
+// elementsEqual reports whether two arrays or tuples hold equal elements in
+// the same order. Elements that are the same object are not compared
+// further.
+func elementsEqual(elements1, elements2 []object.Object) bool {
+	if len(elements1) != len(elements2) {
+		return false
+	}
+	for i := range elements1 {
+		if elements1[i] != elements2[i] && !isEqual(elements1[i], elements2[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 func evalAssignStatement(node *ast.AssignStatement, env *object.Environment) object.Object {
 	switch target := node.Name.(type) {
 
 	case *ast.Identifier:
+		if errObj := checkDeclared(node, target, env); errObj != nil {
+			return errObj
+		}
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
+		if errObj := checkHint(val, node.TypeHint, "variable "+target.Value, env, env, node.Token.Position); errObj != nil {
+			return errObj
+		}
 
 		env.Set(target.Value, val)
 		return val
@@ -477,7 +840,25 @@ func evalAssignStatement(node *ast.AssignStatement, env *object.Environment) obj
 		if isError(val) {
 			return val
 		}
-		instance.Env.Set(target.Right.Value, val)
+		instance.Set(target.Right.Value, val)
+		return val
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if errObj := assignIndex(left, index, val, env, node.Token.Position); errObj != nil {
+			return errObj
+		}
 		return val
 
 	case *ast.TupleLiteral:
@@ -498,7 +879,7 @@ func evalAssignStatement(node *ast.AssignStatement, env *object.Environment) obj
 		}
 
 		if len(target.Elements) != len(values) {
-			return newError("unpacking mismatch: expected %d values, got %d", len(target.Elements), len(values))
+			return newValueError("unpacking mismatch: expected %d values, got %d", len(target.Elements), len(values))
 		}
 
 		for i, expr := range target.Elements {
@@ -506,6 +887,9 @@ func evalAssignStatement(node *ast.AssignStatement, env *object.Environment) obj
 			if !ok {
 				return newError("invalid assignment target in tuple assignment")
 			}
+			if errObj := checkDeclared(node, ident, env); errObj != nil {
+				return errObj
+			}
 			env.Set(ident.Value, values[i])
 		}
 		return val
@@ -539,6 +923,21 @@ func getGlobalEnv(env *object.Environment) *object.Environment {
 	return env
 }
 
+func evalRecordDefinition(node *ast.RecordDefinition, env *object.Environment) object.Object {
+	recordType := &object.RecordType{Name: node.Name.Value}
+	seen := make(map[string]bool, len(node.Fields))
+	for _, field := range node.Fields {
+		if seen[field.Value] {
+			return newError("record %s: duplicate field '%s'", node.Name.Value, field.Value)
+		}
+		seen[field.Value] = true
+		recordType.Fields = append(recordType.Fields, field.Value)
+	}
+
+	env.Set(node.Name.Value, recordType)
+	return recordType
+}
+
 func evalGrimoireDefinition(node *ast.GrimoireDefinition, env *object.Environment) object.Object {
 	methods := map[string]*object.Function{}
 
@@ -559,19 +958,9 @@ func evalGrimoireDefinition(node *ast.GrimoireDefinition, env *object.Environmen
 	}
 
 	for _, method := range node.Methods {
-		fn := &object.Function{
-			Parameters: method.Parameters,
-			Body:       method.Body,
-			Env:        env,
-		}
-		if strings.HasPrefix(method.Name.Value, "__") {
-			fn.IsPrivate = true
-		} else if strings.HasPrefix(method.Name.Value, "_") {
-			fn.IsProtected = true
-		}
-
-		if method.Token.Type == token.ARCANESPELL {
-			fn.IsAbstract = true
+		fn, errObj := newMethod(node.Name.Value, method, env)
+		if errObj != nil {
+			return errObj
 		}
 		methods[method.Name.Value] = fn
 	}
@@ -602,74 +991,299 @@ func evalGrimoireDefinition(node *ast.GrimoireDefinition, env *object.Environmen
 		grimoire.IsArcane = true
 	}
 	if node.InitMethod != nil {
-		initFn := &object.Function{
-			Parameters: node.InitMethod.Parameters,
-			Body:       node.InitMethod.Body,
-			Env:        env,
+		initFn, errObj := newFunction(node.Name.Value+".init", node.InitMethod.Parameters, node.InitMethod.Body, env)
+		if errObj != nil {
+			return errObj
 		}
 		grimoire.InitMethod = initFn
 	}
 
+	if node.Data {
+		if errObj := makeDataGrimoire(grimoire, node, env); errObj != nil {
+			return errObj
+		}
+	}
+	if grimoire.InitMethod != nil {
+		grimoire.InitMethod.Grimoire = grimoire
+	} else if parentGrimoire != nil {
+		grimoire.InitMethod = parentGrimoire.InitMethod
+	}
+	for _, method := range node.Methods {
+		methods[method.Name.Value].Grimoire = grimoire
+	}
+	grimoire.Slots = fieldSlots(node, parentGrimoire)
+
 	env.Set(node.Name.Value, grimoire)
 	return grimoire
 }
 
+// inSpellBody reports whether env is the call scope of a spell or method, as
+// opposed to module scope, so that a return there ends a call.
+func inSpellBody(env *object.Environment) bool {
+	_, ok := env.GetLocal("__function_name")
+	return ok
+}
+
+// evalTailCall evaluates `return f(args)` by handing the call back to the
+// caller's evalCallExpression loop instead of recursing into it.
+func evalTailCall(call *ast.CallExpression, env *object.Environment) object.Object {
+	fn := Eval(call.Function, env)
+	if isError(fn) {
+		return fn
+	}
+	args := evalExpressions(call.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	switch fn.(type) {
+	case *object.Function, *object.BoundMethod:
+		return &object.ReturnValue{Value: &object.TailCall{Fn: fn, Args: args}}
+	}
+
+	ctx := contextFor(env)
+	if len(ctx.callStack) >= ctx.RecursionLimit() {
+		return newRecursionError(ctx, env, call.Token.Position)
+	}
+	ctx.PushCallFrame(call.Function.String(), call.Token.Position)
+	result := evalCallExpression(fn, args, env)
+	ctx.PopCallFrame()
+	if isError(result) {
+		return result
+	}
+	return &object.ReturnValue{Value: result}
+}
+
+// forceTailCall runs a pending tail call right away. It is used where the
+// outcome of the call has to be seen before the return leaves the spell,
+// such as inside an attempt block.
+func forceTailCall(obj object.Object, env *object.Environment) object.Object {
+	rv, ok := obj.(*object.ReturnValue)
+	if !ok {
+		return obj
+	}
+	tail, ok := rv.Value.(*object.TailCall)
+	if !ok {
+		return obj
+	}
+	result := evalCallExpression(tail.Fn, tail.Args, env)
+	if isError(result) {
+		return result
+	}
+	return &object.ReturnValue{Value: result}
+}
+
+// evalCallExpression calls fn, then keeps running whatever tail call the
+// body hands back, so tail recursion loops here instead of growing the stack.
 func evalCallExpression(
 	fn object.Object,
 	args []object.Object,
 	env *object.Environment,
 ) object.Object {
-	if len(args) == 1 {
-		if tup, ok := args[0].(*object.Tuple); ok {
-			args = tup.Elements
+	for {
+		result := applyCall(fn, args, env)
+		tail, ok := result.(*object.TailCall)
+		if !ok {
+			return result
 		}
+		fn, args = tail.Fn, tail.Args
 	}
+}
+
+func applyCall(
+	fn object.Object,
+	args []object.Object,
+	env *object.Environment,
+) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		globalEnv := getGlobalEnv(fn.Env)
 		functionName := "function"
-		extendedEnv := extendFunctionEnv(fn, args, globalEnv, functionName)
+		extendedEnv, errObj := extendFunctionEnv(fn, args, functionName)
+		if errObj != nil {
+			return errObj
+		}
+		if ctx := contextFor(env); ctx.recordCrashes {
+			defer ctx.notePanic(extendedEnv, len(ctx.callStack))
+		}
 		evaluated := Eval(fn.Body, extendedEnv)
+		if isError(evaluated) {
+			contextFor(env).noteCrash(evaluated, extendedEnv)
+		}
 		return unwrapReturnValue(evaluated)
 	case *object.BoundMethod:
-		globalEnv := getGlobalEnv(fn.Method.Env)
-		functionName := fn.Instance.Grimoire.Name + "." + "method"
-		extendedEnv := extendFunctionEnv(fn.Method, args, globalEnv, functionName)
-		extendedEnv.Set("self", fn.Instance)
-		if fn.Method.IsAbstract {
-			return newError("Cannot call abstract method")
-		}
-		evaluated := Eval(fn.Method.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		return applyMethod(fn.Instance, fn.Method, args, env)
 	case *object.Grimoire:
 		if fn.IsArcane {
 			return newError("cannot instantiate arcane grimoire: %s", fn.Name)
 		}
-		instance := &object.Instance{
-			Grimoire: fn,
-			Env:      object.NewEnclosedEnvironment(fn.Env),
-		}
+		instance := object.NewInstance(fn)
 		if fn.InitMethod != nil {
-			globalEnv := getGlobalEnv(fn.Env)
 			functionName := fn.Name + ".init"
-			extendedEnv := extendFunctionEnv(fn.InitMethod, args, globalEnv, functionName)
-			extendedEnv.Set("self", instance)
-			Eval(fn.InitMethod.Body, extendedEnv)
+			extendedEnv, errObj := extendFunctionEnv(fn.InitMethod, args, functionName)
+			if errObj != nil {
+				return errObj
+			}
+			setSelf(extendedEnv, instance, fn.InitMethod)
+			if result := forceTailCall(Eval(fn.InitMethod.Body, extendedEnv), env); isError(result) {
+				return result
+			}
+		}
+		if validate, ok := fn.Methods["__validate__"]; ok {
+			if errObj := runValidateHook(instance, validate, env); errObj != nil {
+				return errObj
+			}
 		}
 		return instance
 	case *object.Builtin:
+		if fn.EnvFn != nil {
+			return fn.EnvFn(env, args...)
+		}
 		return fn.Fn(args...)
+	case *object.RecordType:
+		if len(args) != len(fn.Fields) {
+			return newError("%s expects %d arguments, got %d", fn.Name, len(fn.Fields), len(args))
+		}
+		values := make([]object.Object, len(args))
+		copy(values, args)
+		return &object.Record{RecordType: fn, Values: values}
+	case *object.WeakRef:
+		if len(args) != 0 {
+			return newTypeError("weakref call takes no arguments")
+		}
+		if obj := fn.Get(); obj != nil {
+			return obj
+		}
+		return NONE
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newTypeError("not a function: %s", fn.Type())
+	}
+}
+
+// applyMethod calls method on instance, as applyCall does a BoundMethod.
+func applyMethod(instance *object.Instance, method *object.Function, args []object.Object, env *object.Environment) object.Object {
+	functionName := instance.Grimoire.Name + "." + "method"
+	extendedEnv, errObj := extendFunctionEnv(method, args, functionName)
+	if errObj != nil {
+		return errObj
+	}
+	if ctx := contextFor(env); ctx.recordCrashes {
+		defer ctx.notePanic(extendedEnv, len(ctx.callStack))
+	}
+	setSelf(extendedEnv, instance, method)
+	if method.IsAbstract {
+		return newError("Cannot call abstract method")
+	}
+	evaluated := Eval(method.Body, extendedEnv)
+	if isError(evaluated) {
+		contextFor(env).noteCrash(evaluated, extendedEnv)
+	}
+	return unwrapReturnValue(evaluated)
+}
+
+// setSelf binds self to instance in env, the scope of a call of method,
+// along with the grimoire declaring method, which super looks up from.
+func setSelf(env *object.Environment, instance *object.Instance, method *object.Function) {
+	env.Set("self", instance)
+	if method.Grimoire != nil {
+		env.Set("__grimoire", method.Grimoire)
+	}
+}
+
+// evalMethodCall evaluates a call of the form obj.name(args). When obj is
+// an instance and name one of its methods, the method is called directly
+// instead of through a BoundMethod made only to be called.
+func evalMethodCall(node *ast.CallExpression, dot *ast.DotExpression, env *object.Environment) object.Object {
+	if dot.Left.String() == "super" {
+		member := evalMember(dot, nil, env)
+		if isError(member) {
+			return member
+		}
+		return evalCall(node, member, env)
+	}
+	left := Eval(dot.Left, env)
+	if isError(left) {
+		return evalCall(node, left, env)
+	}
+	instance, ok := left.(*object.Instance)
+	if !ok {
+		return evalCall(node, evalMember(dot, left, env), env)
+	}
+	fn, method := instanceMember(instance, dot.Right.Value, env)
+	if method == nil {
+		return evalCall(node, fn, env)
+	}
+
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	ctx := contextFor(env)
+	if len(ctx.callStack) >= ctx.RecursionLimit() {
+		return newRecursionError(ctx, env, node.Token.Position)
+	}
+	ctx.PushCallFrame(node.Function.String(), node.Token.Position)
+	result := applyMethod(instance, method, args, env)
+	if tail, ok := result.(*object.TailCall); ok {
+		result = evalCallExpression(tail.Fn, tail.Args, env)
+	}
+	ctx.PopCallFrame()
+	return result
+}
+
+// evalCall calls fn, the evaluated function of node, with its arguments.
+func evalCall(node *ast.CallExpression, fn object.Object, env *object.Environment) object.Object {
+	if isError(fn) {
+		return fn
+	}
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	ctx := contextFor(env)
+	if len(ctx.callStack) >= ctx.RecursionLimit() {
+		return newRecursionError(ctx, env, node.Token.Position)
+	}
+	ctx.PushCallFrame(node.Function.String(), node.Token.Position)
+	result := evalCallExpression(fn, args, env)
+	ctx.PopCallFrame()
+	return result
+}
+
+// runValidateHook calls a grimoire's __validate__ method on a freshly built
+// instance. An error raised there is returned with the instantiation site
+// added to its stack trace.
+func runValidateHook(instance *object.Instance, validate *object.Function, env *object.Environment) object.Object {
+	result := evalCallExpression(&object.BoundMethod{Instance: instance, Method: validate}, nil, env)
+	position := contextFor(env).CurrentPosition()
+	site := instance.Grimoire.Name + "()"
+	switch err := result.(type) {
+	case *object.CustomError:
+		err.AddStackEntry(position, site)
+		return err
+	case *object.Error:
+		err.AddStackEntry(position, site)
+		return err
+	}
+	if isError(result) {
+		return result
 	}
+	return nil
 }
 
 func evalDotExpression(node *ast.DotExpression, env *object.Environment) object.Object {
+	// super is not a value, only the start of a lookup in the parent.
+	if node.Left.String() == "super" {
+		return evalMember(node, nil, env)
+	}
 	leftObj := Eval(node.Left, env)
 	if isError(leftObj) {
 		return leftObj
 	}
+	return evalMember(node, leftObj, env)
+}
 
+// evalMember evaluates node, a dot expression, given its left side.
+func evalMember(node *ast.DotExpression, leftObj object.Object, env *object.Environment) object.Object {
 	if node.Left.String() == "super" {
 		instance, ok := env.Get("self")
 		if !ok || instance == nil {
@@ -681,13 +1295,20 @@ func evalDotExpression(node *ast.DotExpression, env *object.Environment) object.
 			return newError("'super' must be used in an instance of a grimoire")
 		}
 
-		if inst.Grimoire == nil || inst.Grimoire.Inherits == nil {
+		grimoire := inst.Grimoire
+		if owner, ok := env.Get("__grimoire"); ok {
+			grimoire = owner.(*object.Grimoire)
+		}
+		if grimoire == nil || grimoire.Inherits == nil {
 			return newError("no parent class found for 'super'")
 		}
 
-		parentMethod, ok := inst.Grimoire.Inherits.Methods[node.Right.Value]
+		parentMethod, ok := grimoire.Inherits.Methods[node.Right.Value]
+		if node.Right.Value == "init" {
+			parentMethod, ok = grimoire.Inherits.InitMethod, grimoire.Inherits.InitMethod != nil
+		}
 		if !ok {
-			return newError("no method '%s' found in parent class", node.Right.Value)
+			return newAttributeError("no method '%s' found in parent class %s", node.Right.Value, grimoire.Inherits.Name)
 		}
 		return &object.BoundMethod{
 			Instance: inst,
@@ -695,36 +1316,88 @@ func evalDotExpression(node *ast.DotExpression, env *object.Environment) object.
 		}
 	}
 
+	if namespace, ok := leftObj.(*object.Namespace); ok {
+		if errObj := loadNamespace(namespace); errObj != nil {
+			return errObj
+		}
+		if member, found := namespace.Env.GetLocal(node.Right.Value); found {
+			return member
+		}
+		return newAttributeError("undefined namespace member: %s", node.Right.Value)
+	}
+
+	if c, ok := leftObj.(*object.Context); ok {
+		return contextMember(c, node.Right.Value)
+	}
+	if p, ok := leftObj.(*object.Pipeline); ok {
+		return pipelineMember(p, node.Right.Value)
+	}
+	switch sketch := leftObj.(type) {
+	case *object.BloomFilter:
+		return bloomFilterMember(sketch, node.Right.Value)
+	case *object.HyperLogLog:
+		return hyperLogLogMember(sketch, node.Right.Value)
+	}
+
+	switch leftObj.(type) {
+	case *object.String, *object.Hash, *object.Integer, *object.BigInteger, *object.Float:
+		return primitiveMethod(leftObj, node.Right.Value, env)
+	}
+
+	if arr, ok := leftObj.(*object.Array); ok {
+		return arrayMember(arr, node.Right.Value, env)
+	}
+	if _, ok := vectorLen(leftObj); ok {
+		return typedArrayMember(leftObj, node.Right.Value)
+	}
+
+	if record, ok := leftObj.(*object.Record); ok {
+		if value, found := record.Get(node.Right.Value); found {
+			return value
+		}
+		return newAttributeError("record %s has no field '%s'", record.RecordType.Name, node.Right.Value)
+	}
+
 	instance, ok := leftObj.(*object.Instance)
 	if !ok {
-		return newError("type error: %s is not an instance", leftObj.Type())
+		return newTypeError("type error: %s is not an instance", leftObj.Type())
 	}
 
-	fieldOrMethodName := node.Right.Value
-
-	if val, found := instance.Env.Get(fieldOrMethodName); found {
+	val, method := instanceMember(instance, node.Right.Value, env)
+	if method == nil {
 		return val
 	}
+	return &object.BoundMethod{
+		Instance: instance,
+		Method:   method,
+	}
+}
+
+// instanceMember looks up a member of an instance: a field, which is
+// returned as the value, or else a method the caller may use, or an error.
+func instanceMember(instance *object.Instance, fieldOrMethodName string, env *object.Environment) (object.Object, *object.Function) {
+	if val, found := instance.Get(fieldOrMethodName); found {
+		return val, nil
+	}
 
 	method, ok := instance.Grimoire.Methods[fieldOrMethodName]
 	if !ok {
-		return newError("undefined property or method: %s", fieldOrMethodName)
+		method, ok = inheritedMethod(instance.Grimoire, fieldOrMethodName)
+	}
+	if !ok {
+		return undefinedMember(instance, fieldOrMethodName), nil
 	}
 
 	if method.IsPrivate && !sameClass(env, instance.Grimoire) {
 		return newError(
 			"private method '%s' not accessible outside its defining class",
 			fieldOrMethodName,
-		)
+		), nil
 	}
 	if method.IsProtected && !sameOrSubclass(env, instance.Grimoire) {
-		return newError("protected method '%s' not accessible here", fieldOrMethodName)
-	}
-
-	return &object.BoundMethod{
-		Instance: instance,
-		Method:   method,
+		return newError("protected method '%s' not accessible here", fieldOrMethodName), nil
 	}
+	return nil, method
 }
 
 func sameClass(env *object.Environment, target *object.Grimoire) bool {
@@ -769,16 +1442,15 @@ func evalHashLiteral(
 		if isError(key) {
 			return key
 		}
-		hashKey, ok := key.(object.Hashable)
+		hashed, ok := object.HashKeyOf(key)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newTypeError("unusable as hash key: %s", key.Type())
 		}
 		value := Eval(valueNode, env)
 		if isError(value) {
 			return value
 		}
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		pairs[hashed] = object.HashPair{Key: ownedKey(key), Value: value}
 	}
 	return &object.Hash{Pairs: pairs}
 }
@@ -802,10 +1474,16 @@ func evalIndexExpression(left, index object.Object) object.Object {
 		} else if index.Type() == object.RANGE_OBJ {
 			return evalArraySliceExpression(left, index)
 		} else {
-			return newError("array index must be INTEGER or RANGE, got %s", index.Type())
+			return newTypeError("array index must be INTEGER or RANGE, got %s", index.Type())
 		}
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.BYTES_OBJ:
+		return evalBytesIndexExpression(left.(*object.Bytes), index)
+	case left.Type() == object.STRING_OBJ:
+		return evalStringIndexExpression(left.(*object.String), index)
+	case left.Type() == object.INT_ARRAY_OBJ, left.Type() == object.FLOAT_ARRAY_OBJ:
+		return evalTypedArrayIndexExpression(left, index)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
@@ -820,13 +1498,43 @@ func evalTupleIndexExpression(tuple, index object.Object) object.Object {
 	return tupleObj.Elements[idx]
 }
 
+// assignIndex sets the element of an array or the entry of a hash at index
+// to val. Arrays only replace existing elements, so an index past either
+// end raises an IndexError, and frozen ones raise a TypeError.
+func assignIndex(left, index, val object.Object, env *object.Environment, position token.Position) object.Object {
+	if isFrozen(left) {
+		return frozenError(left, env, position)
+	}
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newTypeError("array index must be INTEGER, got %s", index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			message := fmt.Sprintf("array index %d out of range for length %d", i.Value, len(left.Elements))
+			return newStdlibError("IndexError", message, env, position)
+		}
+		left.Elements[i.Value] = val
+	case *object.Hash:
+		key, ok := object.HashKeyOf(index)
+		if !ok {
+			return newTypeError("unusable as hash key: %s", index.Type())
+		}
+		left.Pairs[key] = object.HashPair{Key: ownedKey(index), Value: val}
+	default:
+		return newError("%s does not support index assignment", left.Type())
+	}
+	return nil
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
-	key, ok := index.(object.Hashable)
+	key, ok := object.HashKeyOf(index)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newTypeError("unusable as hash key: %s", index.Type())
 	}
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Pairs[key]
 	if !ok {
 		return NONE
 	}
@@ -845,53 +1553,62 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 
 func evalArraySliceExpression(array, rangeObj object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	rangeVal := rangeObj.(*object.Range)
-	
-	// Get start and end values
+	startIdx, endIdx, errObj := sliceBounds("array", rangeObj.(*object.Range), len(arrayObject.Elements))
+	if errObj != nil {
+		return errObj
+	}
+
+	// Create new array with elements from start to end
+	newElements := make([]object.Object, 0, endIdx-startIdx)
+	for i := startIdx; i < endIdx; i++ {
+		newElements = append(newElements, arrayObject.Elements[i])
+	}
+
+	return &object.Array{Elements: newElements}
+}
+
+// sliceBounds resolves the range of a slice of a kind of sequence with
+// length items to start and end indexes within it. A missing bound means
+// that end of the sequence, a negative one counts from the end, and bounds
+// past either end are clamped, so an empty slice has start == end.
+func sliceBounds(kind string, rangeVal *object.Range, length int) (int, int, object.Object) {
 	var startIdx, endIdx int64
-	
+
 	// Handle start index
 	if rangeVal.Start == nil || rangeVal.Start.Type() == object.NONE_OBJ {
 		startIdx = 0
 	} else if rangeVal.Start.Type() == object.INTEGER_OBJ {
 		startIdx = rangeVal.Start.(*object.Integer).Value
 		if startIdx < 0 {
-			startIdx = int64(len(arrayObject.Elements)) + startIdx
+			startIdx = int64(length) + startIdx
 		}
 	} else {
-		return newError("array slice start index must be INTEGER, got %s", rangeVal.Start.Type())
+		return 0, 0, newTypeError("%s slice start index must be INTEGER, got %s", kind, rangeVal.Start.Type())
 	}
-	
+
 	// Handle end index
 	if rangeVal.End == nil || rangeVal.End.Type() == object.NONE_OBJ {
-		endIdx = int64(len(arrayObject.Elements))
+		endIdx = int64(length)
 	} else if rangeVal.End.Type() == object.INTEGER_OBJ {
 		endIdx = rangeVal.End.(*object.Integer).Value
 		if endIdx < 0 {
-			endIdx = int64(len(arrayObject.Elements)) + endIdx
+			endIdx = int64(length) + endIdx
 		}
 	} else {
-		return newError("array slice end index must be INTEGER, got %s", rangeVal.End.Type())
+		return 0, 0, newTypeError("%s slice end index must be INTEGER, got %s", kind, rangeVal.End.Type())
 	}
-	
+
 	// Adjust indices if out of bounds
 	if startIdx < 0 {
 		startIdx = 0
 	}
-	if endIdx > int64(len(arrayObject.Elements)) {
-		endIdx = int64(len(arrayObject.Elements))
-	}
-	if startIdx >= int64(len(arrayObject.Elements)) || endIdx <= 0 || startIdx >= endIdx {
-		return &object.Array{Elements: []object.Object{}}
+	if endIdx > int64(length) {
+		endIdx = int64(length)
 	}
-	
-	// Create new array with elements from start to end
-	newElements := make([]object.Object, 0, endIdx-startIdx)
-	for i := startIdx; i < endIdx; i++ {
-		newElements = append(newElements, arrayObject.Elements[i])
+	if startIdx >= int64(length) || endIdx <= 0 || startIdx >= endIdx {
+		return 0, 0, nil
 	}
-	
-	return &object.Array{Elements: newElements}
+	return int(startIdx), int(endIdx), nil
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
@@ -908,37 +1625,117 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
+// extendFunctionEnv builds the call scope for fn. A missing argument takes
+// its parameter's default, which is evaluated afresh in the scope the spell
+// was defined in on every call, unless it was marked `=once` and so already
+// evaluated when the spell was defined.
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
-	global *object.Environment,
 	functionName string,
-) *object.Environment {
+) (*object.Environment, object.Object) {
 	env := object.NewEnclosedEnvironment(fn.Env)
-	
+
 	// Set function name for stack traces
 	env.Set("__function_name", &object.String{Value: functionName})
 
+	if len(args) > len(fn.Parameters) {
+		return nil, tooManyArguments(fn, len(args), env)
+	}
+
 	for i, param := range fn.Parameters {
-		if i < len(args) {
+		switch {
+		case i < len(args):
 			env.Set(param.Name.Value, args[i])
-		} else if param.DefaultValue != nil {
-			if ident, ok := param.DefaultValue.(*ast.Identifier); ok {
-				if val, ok := global.Get(ident.Value); ok {
-					env.Set(param.Name.Value, val)
-				} else {
-					env.Set(param.Name.Value, newError("identifier not found: "+ident.Value))
-				}
-			} else {
-				defaultVal := Eval(param.DefaultValue, fn.Env)
-				env.Set(param.Name.Value, defaultVal)
+		case param.Once:
+			env.Set(param.Name.Value, fn.OnceDefaults[param.Name.Value])
+		case param.DefaultValue != nil:
+			defaultVal := Eval(param.DefaultValue, fn.Env)
+			if isError(defaultVal) {
+				return nil, defaultVal
 			}
-		} else {
+			env.Set(param.Name.Value, defaultVal)
+		case param.Required:
+			name := fn.Name
+			if name == "" {
+				name = "spell"
+			}
+			message := fmt.Sprintf("%s missing required argument '%s'", name, param.Name.Value)
+			return nil, newStdlibError("TypeError", message, env, contextFor(env).CurrentPosition())
+		default:
 			env.Set(param.Name.Value, NONE)
 		}
+		if param.TypeHint == nil {
+			continue
+		}
+		value, _ := env.Get(param.Name.Value)
+		if errObj := checkHint(value, param.TypeHint, "parameter "+param.Name.Value, fn.Env, env, contextFor(env).CurrentPosition()); errObj != nil {
+			return nil, errObj
+		}
 	}
 
-	return env
+	return env, nil
+}
+
+// decorate passes fn to each of decorators, from the one nearest the spell
+// outwards, and gives what the last returns, which the spell's name is
+// bound to instead of fn.
+func decorate(fn object.Object, decorators []ast.Expression, env *object.Environment) object.Object {
+	for i := len(decorators) - 1; i >= 0; i-- {
+		decorator := Eval(decorators[i], env)
+		if isError(decorator) {
+			return decorator
+		}
+		fn = evalCallExpression(decorator, []object.Object{fn}, env)
+		if isError(fn) {
+			return fn
+		}
+	}
+	return fn
+}
+
+// newMethod creates the method a grimoire called grimoire declares with
+// method. Names starting with __ are private and those starting with _
+// protected.
+func newMethod(grimoire string, method *ast.FunctionDefinition, env *object.Environment) (*object.Function, object.Object) {
+	fn, errObj := newFunction(grimoire+"."+method.Name.Value, method.Parameters, method.Body, env)
+	if errObj != nil {
+		return nil, errObj
+	}
+	if strings.HasPrefix(method.Name.Value, "__") {
+		fn.IsPrivate = true
+	} else if strings.HasPrefix(method.Name.Value, "_") {
+		fn.IsProtected = true
+	}
+	if method.Token.Type == token.ARCANESPELL {
+		fn.IsAbstract = true
+	}
+	return fn, nil
+}
+
+// newFunction creates a spell called name defined in env, evaluating its
+// `=once` defaults there and then.
+func newFunction(name string, parameters []*ast.Parameter, body *ast.BlockStatement, env *object.Environment) (*object.Function, object.Object) {
+	fn := &object.Function{
+		Name:       name,
+		Parameters: parameters,
+		Body:       body,
+		Env:        env,
+	}
+	for _, param := range parameters {
+		if !param.Once {
+			continue
+		}
+		val := Eval(param.DefaultValue, env)
+		if isError(val) {
+			return nil, val
+		}
+		if fn.OnceDefaults == nil {
+			fn.OnceDefaults = make(map[string]object.Object)
+		}
+		fn.OnceDefaults[param.Name.Value] = val
+	}
+	return fn, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -960,13 +1757,22 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	if node.Value == "None" {
 		return object.NONE
 	}
-	return newError("identifier not found: " + node.Value)
+	if module, ok := builtinModules[node.Value]; ok {
+		return module
+	}
+	return identifierNotFound(node.Value, env)
 }
 
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
+		if errObj := checkSignals(env); errObj != nil {
+			return errObj
+		}
+		if errObj := debugStep(statement, env); errObj != nil {
+			return errObj
+		}
 		result = Eval(statement, env)
 
 		switch result.(type) {
@@ -983,6 +1789,12 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	var result object.Object
 
 	for _, statement := range block.Statements {
+		if errObj := checkSignals(env); errObj != nil {
+			return errObj
+		}
+		if errObj := debugStep(statement, env); errObj != nil {
+			return errObj
+		}
 		result = Eval(statement, env)
 		if result != nil {
 			rt := result.Type()
@@ -1029,7 +1841,7 @@ func evalPrefixExpression(
 		}
 		intOperand, ok := right.(*object.Integer)
 		if !ok {
-			return newError("unsupported operand type for ~: %s", right.Type())
+			return newTypeError("unsupported operand type for ~: %s", right.Type())
 		}
 
 		return &object.Integer{Value: ^intOperand.Value}
@@ -1038,23 +1850,40 @@ func evalPrefixExpression(
 		right := Eval(node.Right, env)
 		return evalMinusPrefixOperatorExpression(right, env)
 	default:
-		return newError("unknown operator: %s%s", operator, Eval(node.Right, env).Type())
+		return newTypeError("unknown operator: %s%s", operator, Eval(node.Right, env).Type())
 	}
 }
 
 func evalInfixExpression(
 	operator string,
 	left, right object.Object,
+	arena *Arena,
 ) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right)
+		return evalIntegerInfixExpression(operator, left, right, arena)
+	case isInteger(left) && isInteger(right):
+		return evalBigIntegerInfixExpression(operator, left, right)
 	case left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ:
 		return evalBooleanInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right)
+		return evalStringInfixExpression(operator, left, right, arena)
+	case left.Type() == object.BYTES_OBJ && right.Type() == object.BYTES_OBJ:
+		return evalBytesInfixExpression(operator, left.(*object.Bytes), right.(*object.Bytes))
 	case left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ:
 		return evalArrayInfixExpression(operator, left, right)
+	case left.Type() == object.RECORD_OBJ && right.Type() == object.RECORD_OBJ,
+		left.Type() == object.TUPLE_OBJ && right.Type() == object.TUPLE_OBJ,
+		left.Type() == object.HASH_OBJ && right.Type() == object.HASH_OBJ,
+		typedFloats(left) != nil && typedFloats(right) != nil,
+		isDataInstance(left) || isDataInstance(right),
+		isSpell(left) && isSpell(right):
+		switch operator {
+		case "==":
+			return nativeBoolToBooleanObject(isEqual(left, right))
+		case "!=":
+			return nativeBoolToBooleanObject(!isEqual(left, right))
+		}
 	case left == object.NONE && right == object.NONE:
 		return nativeBoolToBooleanObject(operator == "==")
 	case left == object.NONE || right == object.NONE:
@@ -1063,28 +1892,14 @@ func evalInfixExpression(
 		} else if operator == "!=" {
 			return nativeBoolToBooleanObject(true)
 		}
+	case (left.Type() == object.FLOAT_OBJ || right.Type() == object.FLOAT_OBJ) &&
+		isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right, arena)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
-	case left.Type() == object.FLOAT_OBJ || right.Type() == object.FLOAT_OBJ:
-		leftVal := toFloat(left)
-		rightVal := toFloat(right)
-		switch operator {
-		case "+":
-			return &object.Float{Value: leftVal + rightVal}
-		case "-":
-			return &object.Float{Value: leftVal - rightVal}
-		case "*":
-			return &object.Float{Value: leftVal * rightVal}
-		case "/":
-			return &object.Float{Value: leftVal / rightVal}
-		case "**":
-			return &object.Float{Value: math.Pow(leftVal, rightVal)}
-		default:
-			return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
-		}
+		return newTypeError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	}
 
-	return newError(
+	return newTypeError(
 		"unknown operator or type mismatch: %s %s %s",
 		left.Type(),
 		operator,
@@ -1092,10 +1907,73 @@ func evalInfixExpression(
 	)
 }
 
+// errDivisionByZero is what arithmetic returns for a zero divisor. The
+// helpers have no scope to raise an error in, so the expression that did
+// the division raises a DivisionByZeroError at its position instead.
+var errDivisionByZero = &object.Error{Message: "division by zero"}
+
+func isNumber(obj object.Object) bool {
+	return isInteger(obj) || obj.Type() == object.FLOAT_OBJ
+}
+
+// evalFloatInfixExpression applies an operator to two numbers of which at
+// least one is a float, converting the other to a float.
+func evalFloatInfixExpression(operator string, left, right object.Object, arena *Arena) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+	switch operator {
+	case "+":
+		return arena.Float(leftVal + rightVal)
+	case "-":
+		return arena.Float(leftVal - rightVal)
+	case "*":
+		return arena.Float(leftVal * rightVal)
+	case "/", "~/", "%":
+		if rightVal == 0 {
+			return errDivisionByZero
+		}
+		switch operator {
+		case "/":
+			return arena.Float(leftVal / rightVal)
+		case "~/":
+			return arena.Float(math.Floor(leftVal / rightVal))
+		}
+		return arena.Float(flooredMod(leftVal, rightVal))
+	case "**":
+		return arena.Float(math.Pow(leftVal, rightVal))
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	}
+	return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+// flooredMod is the remainder of dividing l by r rounded down, which takes
+// the sign of r, as % does for every kind of number.
+func flooredMod(l, r float64) float64 {
+	m := math.Mod(l, r)
+	if m != 0 && (m < 0) != (r < 0) {
+		m += r
+	}
+	return m
+}
+
 func toFloat(obj object.Object) float64 {
 	switch obj := obj.(type) {
 	case *object.Integer:
 		return float64(obj.Value)
+	case *object.BigInteger:
+		value, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return value
 	case *object.Float:
 		return obj.Value
 	default:
@@ -1106,33 +1984,51 @@ func toFloat(obj object.Object) float64 {
 func evalStringInfixExpression(
 	operator string,
 	left, right object.Object,
+	arena *Arena,
 ) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s",
-			left.Type(), operator, right.Type())
-	}
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + rightVal}
+	switch operator {
+	case "+":
+		return arena.String(leftVal + rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	}
+	return newTypeError("unknown operator: %s %s %s",
+		left.Type(), operator, right.Type())
 }
 
 func evalArrayInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s",
-			left.Type(), operator, right.Type())
-	}
 	leftVal := left.(*object.Array)
 	rightVal := right.(*object.Array)
-	
-	// Create a new array with the combined elements
-	newElements := make([]object.Object, len(leftVal.Elements)+len(rightVal.Elements))
-	copy(newElements, leftVal.Elements)
-	copy(newElements[len(leftVal.Elements):], rightVal.Elements)
-	
-	return &object.Array{Elements: newElements}
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObject(isEqual(leftVal, rightVal))
+	case "!=":
+		return nativeBoolToBooleanObject(!isEqual(leftVal, rightVal))
+	case "+":
+		// Create a new array with the combined elements
+		newElements := make([]object.Object, len(leftVal.Elements)+len(rightVal.Elements))
+		copy(newElements, leftVal.Elements)
+		copy(newElements[len(leftVal.Elements):], rightVal.Elements)
+
+		return &object.Array{Elements: newElements}
+	}
+	return newTypeError("unknown operator: %s %s %s",
+		left.Type(), operator, right.Type())
 }
 
 func evalBooleanInfixExpression(operator string, left, right object.Object) object.Object {
@@ -1144,7 +2040,7 @@ func evalBooleanInfixExpression(operator string, left, right object.Object) obje
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -1157,7 +2053,7 @@ func evalPrefixIncrementDecrement(
 	case *ast.Identifier:
 		obj, ok := env.Get(operand.Value)
 		if !ok {
-			return newError("undefined variable '%s'", operand.Value)
+			return newNameError("undefined variable '%s'", operand.Value)
 		}
 
 		intObj, ok := obj.(*object.Integer)
@@ -1189,7 +2085,7 @@ func evalPostfixIncrementDecrement(
 
 		obj, ok := env.Get(operand.Value)
 		if !ok {
-			return newError("undefined variable '%s'", operand.Value)
+			return newNameError("undefined variable '%s'", operand.Value)
 		}
 
 		intObj, ok := obj.(*object.Integer)
@@ -1225,7 +2121,7 @@ func evalPostfixExpression(
 	case "++", "--":
 		return evalPostfixIncrementDecrement(operator, node, env)
 	default:
-		return newError("unknown operator: %s", operator)
+		return newTypeError("unknown operator: %s", operator)
 	}
 }
 
@@ -1243,12 +2139,17 @@ func evalBangOperatorExpression(right object.Object, env *object.Environment) ob
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object, env *object.Environment) object.Object {
-	if right.Type() != object.INTEGER_OBJ && right.Type() != object.FLOAT_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+	if !isInteger(right) && right.Type() != object.FLOAT_OBJ {
+		return newTypeError("unknown operator: -%s", right.Type())
 	}
 	switch right := right.(type) {
 	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return object.NewInt(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
 		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return object.NewInt(new(big.Int).Neg(right.Value))
 	case *object.Float:
 		return &object.Float{Value: -right.Value}
 	default:
@@ -1275,26 +2176,51 @@ func evalDecrementOperatorExpression(side object.Object) object.Object {
 func evalIntegerInfixExpression(
 	operator string,
 	left, right object.Object,
+	arena *Arena,
 ) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 	switch operator {
-	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
-	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
-	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
-	case "%":
-		return &object.Integer{Value: leftVal % rightVal}
+	case "+", "-", "*", "<<":
+		result, ok := checkedIntegerOp(operator, leftVal, rightVal)
+		if !ok {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return arena.Integer(result)
+	case "/", "~/", "%":
+		if rightVal == 0 {
+			return errDivisionByZero
+		}
+		switch {
+		case operator == "/":
+			return arena.Float(intQuotient(leftVal, rightVal))
+		case leftVal == math.MinInt64 && rightVal == -1:
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		quotient, remainder := leftVal/rightVal, leftVal%rightVal
+		// Round the quotient down rather than toward zero, so that the
+		// remainder takes the sign of the divisor.
+		if remainder != 0 && (remainder < 0) != (rightVal < 0) {
+			quotient--
+			remainder += rightVal
+		}
+		if operator == "~/" {
+			return arena.Integer(quotient)
+		}
+		return arena.Integer(remainder)
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
 	case "**":
-		return &object.Integer{Value: int64(math.Pow(float64(leftVal), float64(rightVal)))}
+		if rightVal < 0 {
+			return arena.Integer(int64(math.Pow(float64(leftVal), float64(rightVal))))
+		}
+		result, ok := checkedIntegerOp(operator, leftVal, rightVal)
+		if !ok {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return arena.Integer(result)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -1304,19 +2230,20 @@ func evalIntegerInfixExpression(
 	case "<=":
 		return nativeBoolToBooleanObject(leftVal <= rightVal)
 
-	case "<<":
-		return &object.Integer{Value: leftVal << uint(rightVal)}
 	case ">>":
-		return &object.Integer{Value: leftVal >> uint(rightVal)}
+		if rightVal < 0 {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return arena.Integer(leftVal >> uint(rightVal))
 	case "&":
-		return &object.Integer{Value: leftVal & rightVal}
+		return arena.Integer(leftVal & rightVal)
 	case "^":
-		return &object.Integer{Value: leftVal ^ rightVal}
+		return arena.Integer(leftVal ^ rightVal)
 	case "|":
-		return &object.Integer{Value: leftVal | rightVal}
+		return arena.Integer(leftVal | rightVal)
 
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -1331,10 +2258,13 @@ func evalCompoundAssignment(node *ast.InfixExpression, env *object.Environment)
 
 		currVal, ok := env.Get(leftNode.Value)
 		if !ok {
-			return newError("undefined variable: %s", leftNode.Value)
+			return newNameError("undefined variable: %s", leftNode.Value)
 		}
 
-		newVal := applyCompoundOperator(node.Operator, currVal, rightVal)
+		newVal := applyCompoundOperator(node.Operator, currVal, rightVal, arenaFor(env))
+		if newVal == errDivisionByZero {
+			return newStdlibError("DivisionByZeroError", "division by zero", env, node.Token.Position)
+		}
 		if isError(newVal) {
 			return newVal
 		}
@@ -1347,52 +2277,21 @@ func evalCompoundAssignment(node *ast.InfixExpression, env *object.Environment)
 	}
 }
 
-func applyCompoundOperator(operator string, leftVal, rightVal object.Object) object.Object {
+func applyCompoundOperator(operator string, leftVal, rightVal object.Object, arena *Arena) object.Object {
 	switch l := leftVal.(type) {
-	case *object.Integer:
-		rInt, ok := rightVal.(*object.Integer)
-		if !ok {
-			return newError("type mismatch: expected INTEGER, got %s", rightVal.Type())
+	case *object.Integer, *object.BigInteger, *object.Float:
+		if !isNumber(rightVal) {
+			return newTypeError("type mismatch: expected a number, got %s", rightVal.Type())
 		}
 		switch operator {
-		case "+=":
-			return &object.Integer{Value: l.Value + rInt.Value}
-		case "-=":
-			return &object.Integer{Value: l.Value - rInt.Value}
-		case "*=":
-			return &object.Integer{Value: l.Value * rInt.Value}
-		case "/=":
-			if rInt.Value == 0 {
-				return newError("division by zero")
-			}
-			return &object.Integer{Value: l.Value / rInt.Value}
+		case "+=", "-=", "*=", "/=":
+			return evalInfixExpression(operator[:1], l, rightVal, arena)
 		default:
-			return newError("unknown operator: %s", operator)
-		}
-
-	case *object.Float:
-		rFloat, ok := rightVal.(*object.Float)
-		if !ok {
-			return newError("type mismatch: expected FLOAT, got %s", rightVal.Type())
-		}
-		switch operator {
-		case "+=":
-			return &object.Float{Value: l.Value + rFloat.Value}
-		case "-=":
-			return &object.Float{Value: l.Value - rFloat.Value}
-		case "*=":
-			return &object.Float{Value: l.Value * rFloat.Value}
-		case "/=":
-			if rFloat.Value == 0 {
-				return newError("division by zero")
-			}
-			return &object.Float{Value: l.Value / rFloat.Value}
-		default:
-			return newError("unknown operator: %s", operator)
+			return newTypeError("unknown operator: %s", operator)
 		}
 
 	default:
-		return newError("unsupported type for compound assignment: %s", leftVal.Type())
+		return newTypeError("unsupported type for compound assignment: %s", leftVal.Type())
 	}
 }
 
@@ -1423,11 +2322,41 @@ func newError(format string, a ...interface{}) *object.Error {
 	return object.NewError(fmt.Sprintf(format, a...))
 }
 
+// newKindError makes an error that an attempt ensnares as the standard
+// error kind, such as "ValueError". newTypeError and the rest below are
+// shorthands for the kinds the interpreter gives.
+func newKindError(kind, format string, a ...interface{}) *object.Error {
+	err := newError(format, a...)
+	err.Kind = kind
+	return err
+}
+
+func newTypeError(format string, a ...interface{}) *object.Error {
+	return newKindError("TypeError", format, a...)
+}
+
+func newValueError(format string, a ...interface{}) *object.Error {
+	return newKindError("ValueError", format, a...)
+}
+
+func newIndexError(format string, a ...interface{}) *object.Error {
+	return newKindError("IndexError", format, a...)
+}
+
+func newNameError(format string, a ...interface{}) *object.Error {
+	return newKindError("NameError", format, a...)
+}
+
+func newAttributeError(format string, a ...interface{}) *object.Error {
+	return newKindError("AttributeError", format, a...)
+}
+
 func isError(obj object.Object) bool {
 	if obj == nil {
 		return false
 	}
-	return obj.Type() == object.ERROR_OBJ || obj.Type() == object.CUSTOM_ERROR_OBJ
+	return obj.Type() == object.ERROR_OBJ || obj.Type() == object.CUSTOM_ERROR_OBJ ||
+		obj.Type() == object.INTERNAL_ERROR_OBJ
 }
 
 func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
@@ -1447,24 +2376,35 @@ func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) objec
 		for i := 0; i < n-1; i++ {
 			res := Eval(node.Body.Statements[i], env)
 
-			rt := res.Type()
-			if rt == object.STOP.Type() || rt == object.SKIP.Type() ||
-				rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.CUSTOM_ERROR_OBJ {
+			if isControlSignal(res) {
 				controlSignal = res
 				break
 			}
 		}
 
-		if n > 0 {
-			_ = Eval(node.Body.Statements[n-1], env)
+		// The last statement still runs after a plain skip or stop so that
+		// trailing counter updates take effect, but not when the signal is
+		// leaving this loop for a labeled outer one.
+		if n > 0 && (controlSignal == nil || targetsLoop(controlSignal, node.Label)) {
+			last := Eval(node.Body.Statements[n-1], env)
+			if controlSignal == nil && isControlSignal(last) {
+				controlSignal = last
+			}
 		}
 
 		if controlSignal != nil {
 			rt := controlSignal.Type()
 			if rt == object.STOP.Type() {
-				break
+				if !targetsLoop(controlSignal, node.Label) {
+					return controlSignal
+				}
+				// A stopped loop did not complete, so its else block is skipped.
+				return NONE
 			}
 			if rt == object.SKIP.Type() {
+				if !targetsLoop(controlSignal, node.Label) {
+					return controlSignal
+				}
 				continue
 			}
 			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ ||
@@ -1473,19 +2413,56 @@ func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) objec
 			}
 		}
 	}
+
+	if node.Alternative != nil {
+		return Eval(node.Alternative, env)
+	}
 	return NONE
 }
 
+// isControlSignal reports whether a statement result interrupts the normal
+// flow of a loop body.
+func isControlSignal(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	rt := obj.Type()
+	return rt == object.STOP.Type() || rt == object.SKIP.Type() ||
+		rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.CUSTOM_ERROR_OBJ
+}
+
+// targetsLoop reports whether a stop or skip signal applies to the loop with
+// the given label, as opposed to an enclosing loop it should propagate to.
+// A return or an error applies to no loop.
+func targetsLoop(signal object.Object, label *ast.Identifier) bool {
+	var target string
+	switch signal := signal.(type) {
+	case *object.Stop:
+		target = signal.Label
+	case *object.Skip:
+		target = signal.Label
+	default:
+		return false
+	}
+	return target == "" || (label != nil && label.Value == target)
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj := obj.(type) {
 	case *object.Boolean:
 		return obj.Value
 	case *object.String:
 		return len(obj.Value) > 0
+	case *object.Bytes:
+		return len(obj.Value) > 0
 	case *object.Array:
 		return len(obj.Elements) > 0
 	case *object.Tuple:
 		return len(obj.Elements) > 0
+	case *object.IntArray:
+		return len(obj.Values) > 0
+	case *object.FloatArray:
+		return len(obj.Values) > 0
 	case *object.Hash:
 		return len(obj.Pairs) > 0
 	case *object.None:
@@ -1503,6 +2480,14 @@ func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Obje
 
 	var result object.Object = NONE
 
+	// Looping over bytes visits each one as an integer.
+	if b, ok := iterable.(*object.Bytes); ok {
+		iterable = byteElements(b)
+	}
+	if typed := typedElements(iterable); typed != nil {
+		iterable = typed
+	}
+
 	switch iter := iterable.(type) {
 	case *object.Array:
 		for _, elem := range iter.Elements {
@@ -1522,7 +2507,7 @@ func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Obje
 					return newError("cannot unpack non-iterable element: %s", elem.Type())
 				}
 				if len(varExpr.Elements) != len(items) {
-					return newError("unpacking mismatch: expected %d values, got %d", len(varExpr.Elements), len(items))
+					return newValueError("unpacking mismatch: expected %d values, got %d", len(varExpr.Elements), len(items))
 				}
 				for i, target := range varExpr.Elements {
 
@@ -1538,12 +2523,28 @@ func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Obje
 			}
 
 			for _, stmt := range fs.Body.Statements {
+				if errObj := checkSignals(env); errObj != nil {
+					return errObj
+				}
+				if errObj := debugStep(stmt, env); errObj != nil {
+					return errObj
+				}
 				result = Eval(stmt, env)
 				rt := result.Type()
 				if rt == object.STOP.Type() {
+					if !targetsLoop(result, fs.Label) {
+						return result
+					}
+					// A stopped loop did not complete, so its else block is skipped.
 					return NONE
 				}
 				if rt == object.SKIP.Type() {
+					if !targetsLoop(result, fs.Label) {
+						return result
+					}
+					// The skip is used up here and must not end the loop's
+					// caller too if this was the last element.
+					result = NONE
 					break
 				}
 				if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.CUSTOM_ERROR_OBJ {
@@ -1552,7 +2553,7 @@ func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Obje
 			}
 		}
 	default:
-		return newError("unsupported iterable type: %s", iterable.Type())
+		return newTypeError("unsupported iterable type: %s", iterable.Type())
 	}
 
 	if fs.Alternative != nil {
@@ -1562,42 +2563,77 @@ func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Obje
 }
 
 func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
-	filePath := node.FilePath.Value + ".crl"
-
-	if importedFiles[filePath] {
-		return object.NONE
+	if node.Lazy {
+		return lazyImport(node, env)
 	}
-	importedFiles[filePath] = true
-
-	fileContent, err := os.ReadFile(filePath)
-	if err != nil {
-		return newError("could not import file: %s", err)
+	module, native, errObj := importModule(node, env)
+	if errObj != nil {
+		return errObj
 	}
-
-	// Create lexer and parser with filename for better error reporting
-	l := lexer.New(string(fileContent), filePath)
-	p := parser.New(l)
-	program := p.ParseProgram()
-
-	if len(p.Errors()) > 0 {
-		return newError("parsing errors in imported file: %v", p.Errors())
+	if native && node.Alias == nil && len(node.Exposed) == 0 {
+		// A Go module has only functions to import, so by default it is
+		// bound under the last part of its name.
+		env.Set(path.Base(node.FilePath.Value), module)
+		return object.NONE
 	}
+	return bindImport(node, module, env)
+}
 
-	importEnv := object.NewEnclosedEnvironment(env)
-	Eval(program, importEnv)
+// bindImport makes what an import statement asks for from module visible
+// in env.
+func bindImport(node *ast.ImportStatement, module *object.Namespace, env *object.Environment) object.Object {
+	switch {
+	case node.Alias != nil:
+		env.Set(node.Alias.Value, module)
+	case len(node.Exposed) > 0:
+		for _, name := range node.Exposed {
+			member, found := module.Env.GetLocal(name.Value)
+			if !found {
+				message := fmt.Sprintf("module %q has no exported name %q", node.FilePath.Value, name.Value)
+				if isPrivateName(name.Value) {
+					message = fmt.Sprintf("%q is private to module %q", name.Value, node.FilePath.Value)
+				}
+				return newStdlibError("ImportError", message, env, name.Token.Position)
+			}
+			env.Set(name.Value, member)
+		}
+	default:
+		for _, name := range module.Env.GetNames() {
+			val, _ := module.Env.GetLocal(name)
+			env.Set(name, val)
+		}
+	}
 
-	namespace := &object.Namespace{Env: importEnv}
+	return object.NONE
+}
 
-	if node.Alias != nil {
-		env.Set(node.Alias.Value, namespace)
-	} else {
-		for _, name := range importEnv.GetNames() {
-			val, _ := importEnv.Get(name)
-			if val.Type() == object.GRIMOIRE_OBJ {
-				env.Set(name, val)
+// exportedNamespace collects the top-level names a module defined in env:
+// those its export statements list, or else all but the private ones. It
+// returns the first listed name the module never defined, if any.
+func exportedNamespace(env *object.Environment) (*object.Namespace, string) {
+	exported := object.NewEnvironment()
+	if names, ok := env.Exports(); ok {
+		for _, name := range names {
+			value, found := env.GetLocal(name)
+			if !found {
+				return nil, name
 			}
+			exported.Set(name, value)
 		}
+		return &object.Namespace{Env: exported}, ""
 	}
+	for _, name := range env.GetNames() {
+		if isPrivateName(name) {
+			continue
+		}
+		value, _ := env.GetLocal(name)
+		exported.Set(name, value)
+	}
+	return &object.Namespace{Env: exported}, ""
+}
 
-	return object.NONE
+// isPrivateName reports whether a module's top-level name is kept from
+// importers: those starting with an underscore are.
+func isPrivateName(name string) bool {
+	return strings.HasPrefix(name, "_")
 }
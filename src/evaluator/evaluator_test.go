@@ -1,11 +1,26 @@
 package evaluator
 
 import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/ext"
 	"github.com/javanhut/Carrion/src/lexer"
+	"github.com/javanhut/Carrion/src/mod"
 	"github.com/javanhut/Carrion/src/object"
 	"github.com/javanhut/Carrion/src/parser"
+	"github.com/javanhut/Carrion/src/token"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -29,11 +44,11 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"5 * 2 + 10", 20},
 		{"5 + 2 * 10", 25},
 		{"20 + 2 * -10", 0},
-		{"50 / 2 * 2 + 10", 60},
+		{"50 ~/ 2 * 2 + 10", 60},
 		{"2 * (5 + 10)", 30},
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
-		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"(5 + 10 * 2 + 15 ~/ 3) * 2 + -10", 50},
 		{"10 % 3", 1},
 	}
 	for _, tt := range tests {
@@ -272,6 +287,76 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"café \U0001F426"`, "café 🐦"},
+		{`"\x41\x7e\xe9"`, "A~é"},
+		{`"a\0b\a\b\f\v"`, "a\x00b\a\b\f\v"},
+		{`"\"'\\" + '\'\"'`, `"'\'"`},
+		{`f"{1}é\x7b{2}\x7d\t"`, "1é{2}\t"},
+		{"\"\"\"line\\u0021\n\\x41\"\"\"", "line!\nA"},
+		{`b"\x00\xff\0\\"`, "\x00\xff\x00\\"},
+	}
+	for _, tt := range tests {
+		var got string
+		switch result := testEval(tt.input).(type) {
+		case *object.String:
+			got = result.Value
+		case *object.Bytes:
+			got = string(result.Value)
+		default:
+			t.Errorf("%s: expected a string, got %s", tt.input, result.Inspect())
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+		column  int
+	}{
+		{`x = "ab\qcd"`, `invalid escape sequence \q`, 8},
+		{`x = "\u12" + "\q"`, `\u must be followed by 4 hex digits`, 6},
+		{`x = "\xg1"`, `\x must be followed by 2 hex digits`, 6},
+		{`x = "\UFFFFFFFF"`, `\UFFFFFFFF is not a valid character`, 6},
+		{`x = "\ud800"`, `\ud800 is not a valid character`, 6},
+		{`x = b"\u00e9"`, `invalid escape sequence \u`, 7},
+		{`x = f"é\é{1}"`, `invalid escape sequence \é`, 8},
+		{`x = 1 ¤ 2`, `unexpected character "¤"`, 7},
+	}
+	for _, tt := range errors {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+		errs := p.ParseErrors()
+		if len(errs) == 0 || errs[0].Message != tt.message || errs[0].Position.Column != tt.column {
+			t.Errorf("%s: expected %q in column %d, got %+v", tt.input, tt.message, tt.column, errs)
+		}
+	}
+}
+
+func TestIndentation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// Closing two blocks at once.
+		{"x = 0\nfor i in [1, 2]:\n    for j in [1, 2]:\n        if j == 2:\n            x += 1\n    x += 10\nx += 100\nx", 122},
+		{"spell g():\n    for i in [1]:\n        if True:\n            y = 1\n    return 7\ng()", 7},
+		// Blank and comment-only lines, at any indentation, end no block.
+		{"x = 0\nfor i in [1, 2]:\n    x += 1\n\n// between\n    x += 10\nx", 22},
+		{"spell f(a):\n    if a:\n        a += 1\n  // odd comment\n\n    return a\nf(1)", 2},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 	evaluated := testEval(input)
@@ -390,7 +475,7 @@ func TestHashLiterals(t *testing.T) {
 {
 "one": 10 - 9,
 two: 1 + 1,
-"thr" + "ee": 6 / 2,
+"thr" + "ee": 6 ~/ 2,
 4: 4,
 True: 5,
 False: 6
@@ -464,3 +549,3278 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a = [1, 2, 3]\na[1] = 5\na", "[1, 5, 3]"},
+		{"a = [1, 2]\nb = a\nb[0] = 9\na", "[9, 2]"},
+		{"a = [[1], [2]]\na[1][0] = 7\na", "[[1], [7]]"},
+		{`h = {"a": 1}` + "\n" + `h["a"] = 2` + "\n" + `h["b"] = 3` + "\n" + `[h["a"], h["b"], len(h)]`, "[2, 3, 2]"},
+		{"h = {}\nh[1] = True\nh[1]", "true"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{`a = [1]` + "\n" + `a["x"] = 2`, "array index must be INTEGER, got STRING"},
+		{"h = {}\nh[[1]] = 2", "unusable as hash key: ARRAY"},
+		{`s = "ab"` + "\n" + `s[0] = "c"`, "STRING does not support index assignment"},
+	}
+	for _, tt := range errors {
+		if err, ok := testEval(tt.input).(*object.Error); !ok || err.Message != tt.message {
+			t.Errorf("%q: expected %q, got %+v", tt.input, tt.message, testEval(tt.input))
+		}
+	}
+	for _, input := range []string{"a = [1]\na[1] = 2", "a = [1]\na[-1] = 2"} {
+		if err, ok := testEval(input).(*object.CustomError); !ok || err.Name != "IndexError" {
+			t.Errorf("%q: expected an IndexError, got %+v", input, testEval(input))
+		}
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"freeze([1, 2])", "[1, 2]"},
+		{"a = [1, [2]]\nb = freeze(a)\na[1][0] = 5\nb", "[1, [2]]"},
+		{"h = {freeze([1, 2]): \"a\"}\nh[freeze([1, 2])]", `"a"`},
+		{`h = {freeze({"x": 1, "y": 2}): 3}` + "\n" + `h[freeze({"y": 2, "x": 1})]`, "3"},
+		{"freeze([1, 2]) == [1, 2]", "true"},
+		{"freeze([3, 1, 2]).sorted()", "[1, 2, 3]"},
+		{"freeze(5)", "5"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"a = freeze([1])\na[0] = 2", "cannot modify a frozen array"},
+		{"a = freeze([[1]])\na[0][0] = 2", "cannot modify a frozen array"},
+		{"h = freeze({})\nh[1] = 2", "cannot modify a frozen hash"},
+		{"freeze([2, 1]).sort()", "cannot modify a frozen array"},
+		{"freeze([2, 1]).reverse()", "cannot modify a frozen array"},
+		{"shuffle(freeze([2, 1]))", "cannot modify a frozen array"},
+	}
+	for _, tt := range errors {
+		err, ok := testEval(tt.input).(*object.CustomError)
+		if !ok || err.Name != "TypeError" || err.Message != tt.message {
+			t.Errorf("%q: expected TypeError %q, got %+v", tt.input, tt.message, testEval(tt.input))
+		}
+	}
+	if err, ok := testEval("h = {}\nh[[1]] = 2").(*object.Error); !ok || err.Message != "unusable as hash key: ARRAY" {
+		t.Errorf("an array that is not frozen should not be a hash key, got %+v", err)
+	}
+}
+
+func TestTupleHashKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"grid = {}\ngrid[(1, 2)] = \"a\"\ngrid[(1, 2)]", `"a"`},
+		{"grid = {(0, 0): 1}\ngrid[(0, 0)] = 2\ngrid", "{(0, 0): 2}"},
+		{"grid = {(1, 2): 1}\ngrid[(2, 1)]", "None"},
+		{`h = {(1, ("a", True)): 5}` + "\n" + `h[(1, ("a", True))]`, "5"},
+		{"h = {(freeze([1]), 2): 3}\nh[(freeze([1]), 2)]", "3"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+	if err, ok := testEval("h = {}\nh[([1], 2)] = 1").(*object.Error); !ok || err.Message != "unusable as hash key: TUPLE" {
+		t.Errorf("a tuple holding an array should not be a hash key, got %+v", err)
+	}
+}
+
+func TestMultilineLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3,]", "[1, 2, 3]"},
+		{"a = [\n    1,\n    2,\n]\na", "[1, 2]"},
+		{"h = {\n    \"a\": [\n        1,\n\n        // one more\n        2,\n    ],\n}\nh[\"a\"]", "[1, 2]"},
+		{"(1, 2,)", "(1, 2)"},
+		{"t = (1,)\n[len(t), type(t), t[0]]", `[1, "TUPLE", 1]`},
+		{"[type((1, 2,)), len((1, 2,))]", `["TUPLE", 2]`},
+		{"spell first(t):\n    return t[0]\nfirst((4, 5))", "4"},
+		{"grim Box:\n    spell size(t):\n        return len(t)\nBox().size((7,))", "1"},
+		{"spell add(\n    a,\n    b,\n):\n    return a + b\nadd(\n    1,\n    2,\n)", "3"},
+		{"x = (1 +\n    2)\nif x == 3:\n    x = 4\nx", "4"},
+		{"x = 1 + \\\n    2\nx", "3"},
+		{"x = 0\nif 1 == 1 and \\  \r\n        2 == 2:\n    x = 1 + \\\n2 + \\\n\t3\nx", "6"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	p := parser.New(lexer.New("x = 1 \\ 2"))
+	p.ParseProgram()
+	message := "a \\ that continues a line must be the last character on it"
+	if errs := p.ParseErrors(); len(errs) == 0 || errs[0].Message != message || errs[0].Position.Column != 7 {
+		t.Errorf("expected %q in column 7, got %+v", message, errs)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	grim := "grim Point:\n    init(x, y=0):\n        self.x = x\n    spell move(dx, dy=once 1):\n        return dx\n"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"spell f(a, b=2):\n    return a\nsignature(f)", `Signature(name="f", parameters=["a", "b"], defaults={"b": 2}, varargs=false)`},
+		{"spell f():\n    return 1\nsignature(f).parameters", "[]"},
+		{grim + "signature(Point)", `Signature(name="Point", parameters=["x", "y"], defaults={"y": 0}, varargs=false)`},
+		{grim + "signature(Point(1).move)", `Signature(name="Point.move", parameters=["dx", "dy"], defaults={"dy": 1}, varargs=false)`},
+		{"signature(len).varargs", "true"},
+		{"record Pair(a, b)\nsignature(Pair).parameters", `["a", "b"]`},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"spell f(a):\n    return a\nf(1, 2)", "f takes 1 argument, got 2"},
+		{"spell f(a, b=2):\n    return a\nf(1, 2, 3)", "f takes at most 2 arguments, got 3"},
+		{grim + "Point(1, 2, 3)", "Point.init takes at most 2 arguments, got 3"},
+		{grim + "Point(1).move(1, 2, 3)", "Point.move takes at most 2 arguments, got 3"},
+	}
+	for _, tt := range errors {
+		err, ok := testEval(tt.input).(*object.CustomError)
+		if !ok || err.Name != "TypeError" || err.Message != tt.message {
+			t.Errorf("%q: expected TypeError %q, got %+v", tt.input, tt.message, testEval(tt.input))
+		}
+	}
+	if result := testEval("spell f(a, b):\n    return b\nf(1)"); result != NONE {
+		t.Errorf("a missing argument should still be None, got %s", result.Inspect())
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	grim := "grim Animal:\n    init(name):\n        self.name = name\n    spell speak():\n        return 1\n" +
+		"grim Dog(Animal):\n    spell fetch():\n        return 2\nd = Dog(\"rex\")\nd.age = 3\n"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{grim + "dir(d)", `["age", "fetch", "name", "speak"]`},
+		{grim + "dir(Dog)", `["fetch", "speak"]`},
+		{grim + "v = vars(d)\n[v[\"age\"], v[\"name\"], len(v)]", `[3, "rex", 2]`},
+		{"spell f(a):\n    b = a * 2\n    return vars()\nv = f(4)\n[v[\"a\"], v[\"b\"], len(v)]", "[4, 8, 2]"},
+		{"spell f(a):\n    b = a * 2\n    return dir()\nf(4)", `["a", "b"]`},
+		{"record Pair(b, a)\n[dir(Pair(1, 2)), vars(Pair(1, 2))[\"a\"]]", `[["a", "b"], 2]`},
+		{"dir(sketch)", `["bloom_filter", "hyperloglog"]`},
+		{grim + "arcane grim Shape:\n    spell area():\n        ignore\n" +
+			"[callable(d.speak), callable(Dog), callable(Shape), callable(len), callable(d), callable(5)]", "[true, true, false, true, false, false]"},
+		{"type(1.5)", `"FLOAT"`},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	if err, ok := testEval("dir(5)").(*object.Error); !ok || err.Message != "dir: cannot list the members of INTEGER" {
+		t.Errorf("expected an error for dir(5), got %+v", testEval("dir(5)"))
+	}
+}
+
+func TestEvalBuiltin(t *testing.T) {
+	run := newStdlibRunner(t, object.NewEnvironment())
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 10\neval(\"x * 2\")", "20"},
+		{"eval(\"made = x + 1\")\nmade", "11"},
+		{"spell local():\n    n = 3\n    return eval(\"n * n\")\nlocal()", "9"},
+		{"config = {\"base\": 8000}\neval(\"port = base + 80\\nspell f():\\n    return 1\", config)\n[config[\"port\"], len(config)]", "[8080, 3]"},
+		{"eval(\"\\\"up\\\".upper()\", {})", `"UP"`},
+		{"code = compile(\"n * 2\", \"double.crl\")\n[code, eval(code, {\"n\": 4}), eval(code, {\"n\": 5})]", "[<code double.crl>, 8, 10]"},
+	}
+	for _, tt := range tests {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	// A fresh scope does not see the caller's variables, and errors in the
+	// evaluated code, raised ones written as "Name: message", reach the caller.
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"eval(\"1 +\")", `SyntaxError: <eval>:1:4: no right-hand expression for infix operator "+"`},
+		{"eval(\"x\", {})", "identifier not found: x"},
+		{"eval(\"1 / 0\", {})", "DivisionByZeroError: division by zero"},
+	}
+	for _, tt := range errors {
+		var got string
+		switch err := run(tt.input).(type) {
+		case *object.Error:
+			got = err.Message
+		case *object.CustomError:
+			got = err.Name + ": " + err.Message
+		default:
+			got = err.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	if err, ok := run("eval(\"1 +\")").(*object.CustomError); !ok {
+		t.Errorf("expected a SyntaxError, got %+v", err)
+	} else if line, _ := err.Instance.Get("line"); line.Inspect() != "1" {
+		t.Errorf("expected the SyntaxError on line 1, got %s", line.Inspect())
+	}
+}
+
+func TestParseAST(t *testing.T) {
+	source := "x = 1 + 2\\nspell f(a, b=2):\\n    return {\\\"k\\\": a, \\\"j\\\": b}\\n"
+	prefix := "tree = parse_ast(\"" + source + "\")\n"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{prefix + `[tree["type"], len(tree["statements"])]`, `["Program", 2]`},
+		{prefix + `s = tree["statements"][0]` + "\n" + `[s["type"], s["line"], s["column"], s["name"]["value"], s["value"]["operator"], s["value"]["right"]["value"]]`,
+			`["AssignStatement", 1, 1, "x", "+", 2]`},
+		{prefix + `p = tree["statements"][1]["parameters"][1]` + "\n" + `[p["type"], p["name"]["value"], p["default_value"]["value"], p["once"]]`,
+			`["Parameter", "b", 2, false]`},
+		{prefix + `body = tree["statements"][1]["body"]["statements"]` + "\n" + `r = body[0]["return_value"]` + "\n" + `[len(body), r["type"], r["pairs"][0][0]["value"], r["pairs"][1][1]["value"]]`,
+			`[1, "HashLiteral", "k", "b"]`},
+		{`parse_ast("f()", "lint.crl")["statements"][0]["expression"]["function"]["line"]`, "1"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+	if err, ok := testEval(`parse_ast("1 +")`).(*object.CustomError); !ok || err.Name != "SyntaxError" {
+		t.Errorf("expected a SyntaxError, got %+v", testEval(`parse_ast("1 +")`))
+	}
+}
+
+func TestErrorSuggestions(t *testing.T) {
+	grim := "grim Counter:\n    init(start):\n        self.count = start\n    spell increment():\n        self.count += 1\n"
+	tests := []struct {
+		input       string
+		message     string
+		suggestions []string
+	}{
+		{"counter = 1\ncountr", "identifier not found: countr", []string{"counter"}},
+		{"x = prnt", "identifier not found: prnt", []string{"print"}},
+		{"xyzzy", "identifier not found: xyzzy", nil},
+		{grim + "Counter(1).incremnt", "undefined property or method: incremnt", []string{"increment"}},
+		{grim + "Counter(1).cout", "undefined property or method: cout", []string{"count"}},
+	}
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok || err.Message != tt.message {
+			t.Errorf("%q: expected %q, got %+v", tt.input, tt.message, testEval(tt.input))
+			continue
+		}
+		if !slices.Equal(err.Suggestions, tt.suggestions) {
+			t.Errorf("%q: expected suggestions %v, got %v", tt.input, tt.suggestions, err.Suggestions)
+		}
+	}
+
+	err := testEval("counter = 1\ncountr").(*object.Error)
+	if !strings.Contains(err.Inspect(), "Did you mean: counter?") {
+		t.Errorf("Inspect should show the suggestions, got %q", err.Inspect())
+	}
+}
+
+func TestSeededRandom(t *testing.T) {
+	input := `seed(7)
+a = [randint(1, 1000), randint(1, 1000), randint(1, 1000)]
+seed(7)
+b = [randint(1, 1000), randint(1, 1000), randint(1, 1000)]
+a[0] == b[0] and a[1] == b[1] and a[2] == b[2]`
+	testBooleanObject(t, testEval(input), true)
+
+	if _, ok := testEval("random()").(*object.Float); !ok {
+		t.Errorf("random() did not return a Float")
+	}
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"seed(1) x = randint(3, 5) x >= 3 and x <= 5", true},
+		{"seed(1) c = choice([1, 2]) c == 1 or c == 2", true},
+		{"seed(3) a = [1, 2, 3, 4] shuffle(a) len(a) == 4", true},
+		{"seed(2) x = randint(-9223372036854775808, 9223372036854775807) type(x) == \"INTEGER\"", true},
+		{"seed(2) x = randint(-2, 9223372036854775807) x >= -2", true},
+		{"seed(2) x = randint(-9223372036854775808, 0) x <= 0", true},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+
+	errObj, ok := testEval("choice([])").(*object.Error)
+	if !ok || errObj.Message != "choice: cannot choose from an empty array" {
+		t.Errorf("expected empty choice error, got=%+v", errObj)
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, wanted=%g", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestMathModule(t *testing.T) {
+	floats := []struct {
+		input    string
+		expected float64
+	}{
+		{"math.sqrt(16)", 4},
+		{"math.pow(2, 10)", 1024},
+		{"math.log(8, 2)", 3},
+		{"math.round(2.675, 2)", 2.68},
+		{"math.cos(0)", 1},
+		{`math.round(2.5, 0, "half_up")`, 3},
+		{`math.round(-2.5, 0, "half_down")`, -2},
+	}
+	for _, tt := range floats {
+		testFloatObject(t, testEval(tt.input), tt.expected)
+	}
+
+	ints := []struct {
+		input    string
+		expected int64
+	}{
+		{"math.floor(2.7)", 2},
+		{"math.ceil(2.1)", 3},
+		{"math.floor(-2.5)", -3},
+		{"math.gcd(12, 18)", 6},
+		{"math.gcd(-4, 6)", 2},
+		{"math.round(2.5)", 2},
+		{"math.abs(-4)", 4},
+		{"math = 5 math", 5},
+	}
+	for _, tt := range ints {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	bigs := []struct {
+		input    string
+		expected string
+	}{
+		{"math.floor(1e20)", "100000000000000000000"},
+		{"math.ceil(-1e20)", "-100000000000000000000"},
+		{"type(math.round(1e300))", `"BIG_INTEGER"`},
+		{"math.round(1e300) > 10 ** 299", "true"},
+		{"math.trunc(2 ** 70)", "1180591620717411303424"},
+	}
+	for _, tt := range bigs {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := map[string]string{
+		"math.sqrt(-1)":                  "math.sqrt: math domain error",
+		`math.round(1.5, 0, "up")`:       "math.round: unknown rounding mode 'up'",
+		"math.floor(math.inf)":           "math.floor: cannot convert infinity to an integer",
+		"math.round(-math.inf)":          "math.round: cannot convert infinity to an integer",
+		"math.ceil(math.inf - math.inf)": "math.ceil: cannot convert NaN to an integer",
+		"math.nope":                      "undefined namespace member: nope",
+	}
+	for input, expected := range errors {
+		errObj, ok := testEval(input).(*object.Error)
+		if !ok || errObj.Message != expected {
+			t.Errorf("expected error %q for %s, got=%+v", expected, input, errObj)
+		}
+	}
+}
+
+func TestLabeledLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`total = 0
+for i in range(3) as outer:
+    for j in range(3):
+        if j == 2:
+            stop outer
+        total += 1
+total`, 2},
+		{`total = 0
+for i in range(3) as rows:
+    for j in range(3):
+        if j == 1:
+            skip rows
+        total += 1
+total`, 3},
+		{`total = 0
+i = 0
+while i < 3 as rows:
+    i++
+    j = 0
+    while j < 3:
+        j++
+        if j == 2:
+            skip rows
+        total += 1
+total`, 3},
+		{`total = 0
+for i in range(4):
+    for j in range(4):
+        if j == 1:
+            stop
+        total += 1
+total`, 4},
+		{`total = 0
+for i in range(3) as outer:
+    spell count():
+        for j in range(3) as outer:
+            stop outer
+        return 1
+    total += count()
+    stop outer
+total`, 1},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	// A spell defined inside a labeled loop cannot leave that loop.
+	errors := []string{
+		"for i in range(3) as outer:\n    spell f():\n        stop outer\n    f()",
+		"for i in range(3) as outer:\n    spell f():\n        for j in range(3):\n            skip outer\n    f()",
+		"spell g():\n    for i in range(3) as rows:\n        spell f():\n            stop rows\n        f()\ng()",
+	}
+	for _, input := range errors {
+		p := parser.New(lexer.New(input))
+		p.ParseProgram()
+		errs := p.ParseErrors()
+		if len(errs) == 0 || !strings.Contains(errs[0].Message, "no enclosing loop labeled") {
+			t.Errorf("%q: expected a parse error for the outer label, got %+v", input, errs)
+		}
+	}
+}
+
+func TestSafeEvalRecoversPanic(t *testing.T) {
+	env := object.NewEnvironment()
+	// A dot expression without operands trips a nil dereference.
+	env.Set("broken", &object.Function{
+		Body: &ast.BlockStatement{Statements: []ast.Statement{
+			&ast.ExpressionStatement{Expression: &ast.DotExpression{}},
+		}},
+		Env: env,
+	})
+	program := &ast.CallExpression{Function: &ast.Identifier{Value: "broken"}}
+
+	result := SafeEval(program, env)
+	internal, ok := result.(*object.InternalError)
+	if !ok {
+		t.Fatalf("expected InternalError, got=%T (%+v)", result, result)
+	}
+	if len(internal.StackTrace) != 1 || internal.StackTrace[0].Function != "broken" {
+		t.Errorf("wrong Carrion stack trace: %+v", internal.StackTrace)
+	}
+	if internal.GoStack != "" {
+		t.Errorf("Go stack captured without ReportGoStack")
+	}
+	if len(contextFor(env).callStack) != 0 {
+		t.Errorf("call stack not unwound after recovery")
+	}
+
+	ReportGoStack = true
+	defer func() { ReportGoStack = false }()
+	internal, ok = SafeEval(program, env).(*object.InternalError)
+	if !ok || !strings.Contains(internal.GoStack, "goroutine") {
+		t.Errorf("expected Go stack in internal error, got=%+v", internal)
+	}
+}
+
+func TestLoopElse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`x = 0
+for i in [1, 2, 3]:
+    x += i
+else:
+    x = x * 10
+x`, 60},
+		{`x = 0
+for i in [1, 2, 3]:
+    if i == 2:
+        stop
+    x += i
+else:
+    x = 100
+x`, 1},
+		{`i = 0
+while i < 3:
+    i++
+else:
+    i = 10
+i`, 10},
+		{`i = 0
+while i < 3:
+    i++
+    if i == 2:
+        stop
+else:
+    i = 10
+i`, 2},
+		{`x = 0
+for i in [1, 2] as outer:
+    for j in [1, 2]:
+        stop outer
+    else:
+        x = 100
+else:
+    x = 200
+x`, 0},
+		{`spell f():
+    seen = []
+    while True:
+        if len(seen) == 3:
+            return seen
+        seen.append(1)
+len(f())`, 3},
+		{`spell last_skipped():
+    n = 0
+    for i in [1, 2, 3]:
+        if i > 1:
+            skip
+        n += i
+    return n + 10
+total = 0
+for k in [1, 2]:
+    total += last_skipped()
+total`, 22},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDecorators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"spell twice(fn):\n    spell wrapper(x):\n        return fn(fn(x))\n    return wrapper\n@twice\nspell inc(n):\n    return n + 1\ninc(1)", "3"},
+		{"spell add(k):\n    spell decorator(fn):\n        spell wrapper(x):\n            return fn(x) + k\n        return wrapper\n    return decorator\n" +
+			"spell double(fn):\n    spell wrapper(x):\n        return fn(x) * 2\n    return wrapper\n@double\n@add(1)\nspell id(n):\n    return n\nid(5)", "12"},
+		{"calls = []\nspell logged(fn):\n    spell wrapper(x):\n        calls.append(x)\n        return fn(x)\n    return wrapper\n@logged\nspell sq(n):\n    return n * n\n[sq(3), sq(4), calls]", "[9, 16, [3, 4]]"},
+		{"spell replace(fn):\n    return 42\n@replace\nspell f():\n    return 1\nf", "42"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	if err, ok := testEval("@missing\nspell f():\n    return 1").(*object.Error); !ok || err.Message != "identifier not found: missing" {
+		t.Errorf("expected an error for an unknown decorator, got %+v", testEval("@missing\nspell f():\n    return 1"))
+	}
+	p := parser.New(lexer.New("grim A:\n    @log\n    spell f():\n        return 1"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Error("a decorator on a grimoire spell should be a parse error")
+	}
+}
+
+func TestStandardDecorators(t *testing.T) {
+	var stderr bytes.Buffer
+	env := object.NewEnvironment()
+	ctx := NewEvalContext("")
+	ctx.SetStderr(&stderr)
+	env.SetContext(ctx)
+	run := newStdlibRunner(t, env)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fib_calls = 0\n@memoize\nspell fib(n):\n    global fib_calls\n    fib_calls += 1\n    if n < 2:\n        return n\n    return fib(n - 1) + fib(n - 2)\n[fib(50), fib_calls]", "[12586269025, 51]"},
+		{"sum_calls = 0\n@memoize\nspell total(a, b):\n    global sum_calls\n    sum_calls += 1\n    return a + b\n[total(1, 2), total(1, 2), total(2, 1), sum_calls]", "[3, 3, 3, 2]"},
+		{"len_calls = 0\n@memoize\nspell size(xs):\n    global len_calls\n    len_calls += 1\n    return len(xs)\n[size([1]), size([1]), len_calls]", "[1, 1, 2]"},
+		{"@timeit\nspell timed(n):\n    return n * 2\ntimed(4)", "8"},
+		{"@deprecated(\"use area instead\")\nspell square(n):\n    return n * n\n[square(2), square(3)]", "[4, 9]"},
+		{"@deprecated\nspell legacy():\n    return 1\nlegacy()", "1"},
+	}
+	for _, tt := range tests {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "timed took ") ||
+		lines[1] != "warning: square is deprecated: use area instead" || lines[2] != "warning: legacy is deprecated" {
+		t.Errorf("unexpected decorator output %q", stderr.String())
+	}
+}
+
+func TestScopeStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`count = 0
+spell bump():
+    global count
+    count += 1
+bump()
+bump()
+count`, 2},
+		{`spell make_counter():
+    n = 0
+    spell inc():
+        outer n
+        n += 1
+        return n
+    return inc
+c = make_counter()
+c()
+c()
+c()`, 3},
+		{`x = 1
+spell shadow():
+    x = 5
+    return x
+shadow()
+x`, 1},
+		{`spell setup():
+    global fresh
+    fresh = 7
+setup()
+fresh`, 7},
+		{"outer = 5\nglobal = outer + 1\nglobal", 6},
+		{`spell shift(outer):
+    global = outer * 2
+    return global
+shift(4)`, 8},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	errors := map[string]string{
+		"outer x":                                  "outer: can only be used inside a spell",
+		"spell f():\n    outer missing\nf()":       "outer: no binding for 'missing' in an enclosing scope",
+		"spell g():\n    y = 1\n    global y\ng()": "global: 'y' is already a local variable",
+	}
+	for input, expected := range errors {
+		errObj, ok := testEval(input).(*object.Error)
+		if !ok || errObj.Message != expected {
+			t.Errorf("expected error %q for %q, got=%+v", expected, input, errObj)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`spell count(n, acc):
+    if n == 0:
+        return acc
+    return count(n - 1, acc + 1)
+count(200000, 0)`, 200000},
+		{`spell is_even(n):
+    if n == 0:
+        return 1
+    return is_odd(n - 1)
+spell is_odd(n):
+    if n == 0:
+        return 0
+    return is_even(n - 1)
+is_even(100001)`, 0},
+		{`spell sum(n):
+    if n == 0:
+        return 0
+    return n + sum(n - 1)
+sum(100)`, 5050},
+		{`grim ValueError:
+    spell init(message):
+        self.message = message
+spell risky(n):
+    raise ValueError("boom")
+spell safe(n):
+    attempt:
+        return risky(n)
+    ensnare (ValueError):
+        return 42
+safe(1)`, 42},
+		{`grim Counter:
+    spell count(n, acc):
+        if n == 0:
+            return acc
+        return self.count(n - 1, acc + 1)
+Counter().count(200000, 0)`, 200000},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestWeakRefs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"a = [1, 2]\nw = weakref(a)\nlen(w())", 2},
+		{"a = [1, 2]\nw = weakref(a)\na = None\ngc()\nw()", nil},
+		{`reg = weakregistry()
+keep = [1]
+reg.set("keep", keep)
+reg.set("drop", [2])
+gc()
+reg.size()`, 1},
+		{`reg = weakregistry()
+keep = [1, 2, 3]
+reg.set(1, keep)
+len(reg.get(1))`, 3},
+		{`reg = weakregistry()
+reg.set("x", [1])
+gc()
+reg.get("x")`, nil},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		default:
+			if evaluated.Type() != object.NONE_OBJ {
+				t.Errorf("expected None for %q, got=%s", tt.input, evaluated.Inspect())
+			}
+		}
+	}
+
+	errObj, ok := testEval("weakref(5)").(*object.Error)
+	if !ok || errObj.Message != "weakref: cannot create weak reference to INTEGER" {
+		t.Errorf("wrong error for weakref(5): %+v", errObj)
+	}
+}
+
+func TestRecursionLimit(t *testing.T) {
+	input := `setrecursionlimit(50)
+spell deep(n):
+    return 1 + deep(n + 1)
+caught = 0
+attempt:
+    deep(0)
+ensnare ("RecursionError"):
+    caught = 1
+caught`
+	testIntegerObject(t, testEval(input), 1)
+
+	input = `setrecursionlimit(50)
+spell count(n):
+    if n == 0:
+        return 0
+    return count(n - 1)
+count(1000)`
+	testIntegerObject(t, testEval(input), 0)
+
+	input = `setrecursionlimit(50)
+spell deep(n):
+    return 1 + deep(n + 1)
+deep(0)`
+	errObj, ok := testEval(input).(*object.CustomError)
+	if !ok || errObj.Name != "RecursionError" || errObj.Message != "maximum recursion depth of 50 exceeded" {
+		t.Errorf("expected RecursionError, got=%+v", errObj)
+	}
+}
+
+func TestBuiltinErrors(t *testing.T) {
+	run := newStdlibRunner(t, object.NewEnvironment())
+	catch := func(body, kind string) string {
+		return "caught = \"no\"\nattempt:\n    " + body + "\nensnare (" + kind + "):\n    caught = \"yes\"\ncaught"
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{catch(`raise ValueError("bad")`, "ValueError"), `"yes"`},
+		{catch(`int("abc")`, "ValueError"), `"yes"`},
+		{catch(`float("x1")`, "ValueError"), `"yes"`},
+		{catch(`int([1])`, "TypeError"), `"yes"`},
+		{"a = [1, 2]\n" + catch(`a[5] = 0`, "IndexError"), `"yes"`},
+		{catch(`raise KeyError("k")`, "KeyError"), `"yes"`},
+		{`ValueError("x").Type()`, `"ValueError"`},
+		{`NameError("x").message`, `"x"`},
+		{`AttributeError("x").Type()`, `"AttributeError"`},
+		{`int("12")`, "12"},
+	}
+	for _, tt := range tests {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	parents := map[string]string{
+		"IndexError": "LookupError", "KeyError": "LookupError", "DivisionByZeroError": "ArithmeticError",
+		"ValueError": "Exception", "NameError": "Exception", "AttributeError": "Exception",
+	}
+	for name, parent := range parents {
+		obj := run(name)
+		grimoire, ok := obj.(*object.Grimoire)
+		if !ok || grimoire.Inherits == nil || grimoire.Inherits.Name != parent {
+			t.Errorf("expected %s to inherit from %s, got %+v", name, parent, obj)
+		}
+	}
+	err, ok := run(`int("abc")`).(*object.CustomError)
+	if !ok || err.Name != "ValueError" || err.Message != `cannot convert "abc" to int` {
+		t.Errorf("expected a ValueError, got %+v", err)
+	}
+}
+
+func TestEnsnare(t *testing.T) {
+	run := newStdlibRunner(t, object.NewEnvironment())
+	catch := func(body, clauses string) string {
+		return "caught = \"no\"\nattempt:\n    " + body + "\n" + clauses + "caught"
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{catch(`raise KeyError("k")`, "ensnare (LookupError):\n    caught = \"yes\"\n"), `"yes"`},
+		{catch("x = 1 ~/ 0", "ensnare (\"ArithmeticError\"):\n    caught = \"yes\"\n"), `"yes"`},
+		{catch(`raise ValueError("v")`, "ensnare (Exception):\n    caught = \"yes\"\n"), `"yes"`},
+		{catch(`raise ValueError("v")`, "ensnare (TypeError):\n    caught = \"type\"\nensnare:\n    caught = \"any\"\n"), `"any"`},
+		{catch(`raise ValueError("v")`, "ensnare (ValueError) as e:\n    caught = e.message\n"), `"v"`},
+		{catch(`raise "plain"`, "ensnare as e:\n    caught = e.message\n"), `"plain"`},
+		{"grim Oops(ValueError):\n    init(message):\n        self.message = message\n" +
+			catch(`raise Oops("o")`, "ensnare (ValueError) as e:\n    caught = e.Type()\n"), `"ValueError"`},
+	}
+	for _, tt := range tests {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	input := "attempt:\n    raise IndexError(\"i\")\nensnare (KeyError):\n    x = 1"
+	if err, ok := run(input).(*object.CustomError); !ok || err.Name != "IndexError" {
+		t.Errorf("a sibling error should not be ensnared, got %+v", run(input))
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"log = []\nspell f():\n    attempt:\n        return 1\n    resolve:\n        log.append(\"r\")\n[f(), log]", `[1, ["r"]]`},
+		{"log = []\nfor i in [1, 2, 3]:\n    attempt:\n        if i == 2:\n            stop\n    resolve:\n        log.append(i)\nlog", "[1, 2]"},
+		{"log = []\nfor i in [1, 2]:\n    attempt:\n        skip\n    resolve:\n        log.append(i)\nlog", "[1, 2]"},
+		{"spell f():\n    attempt:\n        return 1\n    resolve:\n        return 2\nf()", "2"},
+		{"spell f():\n    attempt:\n        raise \"bad\"\n    resolve:\n        return \"kept\"\nf()", `"kept"`},
+		{"n = 0\nwhile n < 5:\n    n++\n    attempt:\n        raise \"bad\"\n    resolve:\n        stop\nn", "1"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	input := "log = []\nspell f():\n    attempt:\n        raise \"bad\"\n    ensnare:\n        raise \"worse\"\n    resolve:\n        log.append(1)\nf()"
+	if err, ok := testEval(input).(*object.CustomError); !ok || err.Message != "worse" {
+		t.Errorf("expected the error raised by the ensnare clause, got %+v", testEval(input))
+	}
+}
+
+func TestAutoclose(t *testing.T) {
+	run := newStdlibRunner(t, object.NewEnvironment())
+	run("log = []\ngrim Door:\n    init(name, quiet=False):\n        self.name = name\n        self.quiet = quiet\n" +
+		"    spell __enter__():\n        log.append(\"open \" + self.name)\n        return self.name\n" +
+		"    spell __exit__(err):\n        if err == None:\n            log.append(\"close \" + self.name)\n" +
+		"        else:\n            log.append(\"close \" + self.name + \" after \" + err.message)\n        return self.quiet\n")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"log = []\nautoclose Door(\"a\") as name:\n    log.append(name)\nlog", `["open a", "a", "close a"]`},
+		{"log = []\nspell f():\n    autoclose Door(\"a\"):\n        return 1\n[f(), log]", `[1, ["open a", "close a"]]`},
+		{"log = []\nfor i in [1, 2]:\n    autoclose Door(str(i)):\n        stop\nlog", `["open 1", "close 1"]`},
+		{"log = []\nattempt:\n    autoclose Door(\"a\"):\n        raise ValueError(\"stuck\")\nensnare (ValueError):\n    log.append(\"caught\")\nlog", `["open a", "close a after stuck", "caught"]`},
+		{"log = []\nautoclose Door(\"a\", True):\n    missing\nlog", `["open a", "close a after identifier not found: missing"]`},
+	}
+	for _, tt := range tests {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	if err, ok := run("autoclose 5:\n    ignore").(*object.Error); !ok || err.Message != "autoclose needs a grimoire with an __exit__ spell, got INTEGER" {
+		t.Errorf("expected an error for a value without __exit__, got %+v", run("autoclose 5:\n    ignore"))
+	}
+}
+
+func TestReraise(t *testing.T) {
+	run := newStdlibRunner(t, object.NewEnvironment())
+	load := "spell load(k):\n    attempt:\n        return {}.remove(k)\n    ensnare (KeyError) as e:\n        raise ValueError(\"bad config\") from e\n"
+
+	// Each error in a chain is written as "Name: message", followed by
+	// the error that caused it.
+	chains := []struct {
+		input    string
+		expected string
+	}{
+		{load + "load(\"port\")", `ValueError: bad config <- KeyError: key not found: "port"`},
+		{"attempt:\n    raise \"first\"\nensnare as e:\n    raise \"second\" from e", "Error: second <- Error: first"},
+		{"attempt:\n    raise \"first\"\nensnare:\n    raise \"second\" from None", "Error: second"},
+	}
+	for _, tt := range chains {
+		var chain []string
+		err, ok := run(tt.input).(*object.CustomError)
+		for ok {
+			chain = append(chain, err.Name+": "+err.Message)
+			err, ok = err.Cause.(*object.CustomError)
+		}
+		if got := strings.Join(chain, " <- "); got != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"attempt:\n    attempt:\n        raise TypeError(\"inner\")\n    ensnare:\n        raise\nensnare (TypeError) as e:\n    caught = e.message\ncaught", `"inner"`},
+		{"from = 3\nfrom", "3"},
+	}
+	for _, tt := range tests {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	if result := run(load + "load(\"port\")"); !strings.Contains(result.Inspect(), "Caused by: KeyError") {
+		t.Errorf("expected the cause in %q", result.Inspect())
+	}
+	if err, ok := run("raise").(*object.Error); !ok || !strings.HasPrefix(err.Message, "raise with no error") {
+		t.Errorf("a bare raise outside ensnare should fail, got %+v", run("raise"))
+	}
+}
+
+func TestCatchableRuntimeErrors(t *testing.T) {
+	run := newStdlibRunner(t, object.NewEnvironment())
+	catch := func(body, kind string) string {
+		return "caught = \"no\"\nattempt:\n    " + body + "\nensnare (" + kind + ") as e:\n    caught = e.Type() + \": \" + e.message\ncaught"
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{catch("print(missing)", "NameError"), `"NameError: identifier not found: missing"`},
+		{catch(`x = 1 + "a"`, "TypeError"), `"TypeError: type mismatch: INTEGER + STRING"`},
+		{catch(`"crow".fly()`, "AttributeError"), `"AttributeError: string has no method 'fly'"`},
+		{catch("a = [1]\n    a[3] = 0", "LookupError"), `"IndexError: array index 3 out of range for length 1"`},
+		{catch("x = 5\n    x()", "Exception"), `"TypeError: not a function: INTEGER"`},
+		{catch("range(1, 5, 0)", "RuntimeError"), "\"RuntimeError: step argument to `range` cannot be zero\""},
+		{catch("math.sqrt(-1)", "ValueError"), `"ValueError: math.sqrt: math domain error"`},
+		{catch("choice([])", "ValueError"), `"ValueError: choice: cannot choose from an empty array"`},
+		{catch("randint(5, 1)", "ValueError"), `"ValueError: randint: low (5) must not exceed high (1)"`},
+		{catch("bytes([300])", "ValueError"), `"ValueError: bytes: element 0 must be an INTEGER from 0 to 255, got 300"`},
+		{catch(`format_number(1, "zz")`, "ValueError"), `"ValueError: format_number: unknown locale 'zz'"`},
+		{catch(`format_number("1")`, "TypeError"), `"TypeError: format_number: expected a number, got STRING"`},
+		{"attempt:\n    missing\nensnare:\n    caught = \"any\"\ncaught", `"any"`},
+	}
+	for _, tt := range tests {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	// An error no clause catches is left as it was, and an error in an
+	// argument stops the call.
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"attempt:\n    missing\nensnare (TypeError):\n    x = 1", "identifier not found: missing"},
+		{"print(missing)\n1", "identifier not found: missing"},
+	}
+	for _, tt := range errors {
+		if err, ok := run(tt.input).(*object.Error); !ok || err.Message != tt.message {
+			t.Errorf("%q: expected %q, got %+v", tt.input, tt.message, run(tt.input))
+		}
+	}
+}
+
+func TestStrictPragma(t *testing.T) {
+	run := newStdlibRunner(t, object.NewEnvironment())
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"pragma strict\nvar total = 0\nspell add(n):\n    global total\n    var twice = n * 2\n    total += twice\nadd(2)\nfor i in [1, 2]:\n    total = total + i\ntotal", "7"},
+		{"pragma strict\ncount = 1", "NameError: assignment to undeclared variable 'count'; declare it with var"},
+		{"pragma strict\nvar n = 1\nvar n = 2", "NameError: 'n' is already declared in this scope"},
+		{"pragma strict\nvar m = 1\nspell reset():\n    m = 2\nreset()", "NameError: assignment to undeclared variable 'm'; declare it with var"},
+		{"pragma strict\nspell area(w, h=1):\n    return w * h\narea()", "TypeError: area missing required argument 'w'"},
+		{"pragma strict\nspell area(w, h=1):\n    return w * h\narea(3)", "3"},
+		{"loose = 1\nvar loose = 2\nspell first(a):\n    return a\ntype(first())", `"NONE"`},
+	}
+	for _, tt := range tests {
+		result := run(tt.input)
+		got := result.Inspect()
+		if err, ok := result.(*object.CustomError); ok {
+			got = err.Name + ": " + err.Message
+		}
+		if got != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	// Spells keep the checks of the file that defined them.
+	run("pragma strict\nspell strict_spell(x):\n    return x")
+	if err, ok := run("strict_spell()").(*object.CustomError); !ok || err.Name != "TypeError" {
+		t.Errorf("calling a strict spell without its argument should raise a TypeError, got %+v", run("strict_spell()"))
+	}
+
+	program := parser.New(lexer.New("x = 1\npragma strict"))
+	program.ParseProgram()
+	if errs := program.Errors(); len(errs) != 1 || errs[0] != "pragma strict must come before any other statement" {
+		t.Errorf("a late pragma should be a parse error, got %v", errs)
+	}
+}
+
+func TestMarkdownModule(t *testing.T) {
+	prefix := `text = "# Guide\n\nRead [the API](api.md \"API\").\n\n## Install *now*\n- one"` + "\n"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`markdown.to_html("**hi** <you>")`, `"<p><strong>hi</strong> &lt;you&gt;</p>\n"`},
+		{prefix + `h = markdown.headings(text)[1]` + "\n" + `[h["level"], h["text"], h["id"], h["line"]]`, `[2, "Install now", "install-now", 5]`},
+		{prefix + `l = markdown.links(text)[0]` + "\n" + `[l["text"], l["url"], l["title"], l["line"]]`, `["the API", "api.md", "API", 3]`},
+		{prefix + `tree = markdown.parse(text)` + "\n" + `items = tree["children"][3]` + "\n" + `[tree["type"], items["type"], items["ordered"], items["children"][0]["children"][0]["type"]]`,
+			`["document", "list", false, "paragraph"]`},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+	if err, ok := testEval("markdown.to_html(1)").(*object.Error); !ok || err.Message != "markdown.to_html requires 1 argument: a STRING of Markdown" {
+		t.Errorf("expected an error for a non-string, got %+v", testEval("markdown.to_html(1)"))
+	}
+}
+
+func TestQRCodeAndBarcode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "code.png")
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`png = qrcode.generate("https://example.com")` + "\n" + `[type(png), png[0:8] == b"\x89PNG\r\n\x1a\n"]`, `["BYTES", true]`},
+		{`qrcode.generate(b"raw", None, "H")[1:4]`, `b"PNG"`},
+		{`qrcode.generate("ticket 42", "` + path + `")` + "\n" + `len(fileReadBytes("` + path + `")) > 100`, "true"},
+		{`barcode.code128("SHIP-0042")[1:4]`, `b"PNG"`},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+	for input, message := range map[string]string{
+		`qrcode.generate("x", None, "Z")`: `qrcode.generate: level must be "L", "M", "Q" or "H", got "Z"`,
+		`barcode.code128("")`:             "barcode.code128: nothing to encode",
+		`qrcode.generate(1)`:              "qrcode.generate: text must be a STRING or BYTES, got INTEGER",
+	} {
+		if err, ok := testEval(input).(*object.Error); !ok || err.Message != message {
+			t.Errorf("%s: expected the error %q, got %s", input, message, testEval(input).Inspect())
+		}
+	}
+	img, err := png.Decode(bytes.NewReader(testEval(`qrcode.generate("hello")`).(*object.Bytes).Value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Version 1 is 21 modules square, with a border of 4 on each side and
+	// 8 pixels to a module.
+	if b := img.Bounds(); b.Dx() != 29*8 || b.Dy() != 29*8 {
+		t.Errorf("expected a 232x232 image, got %v", b)
+	}
+}
+
+func TestNetModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[net.parse_ip(" 10.0.0.1"), net.parse_ip("2001:DB8:0:0:0:0:0:1"), net.parse_ip("::ffff:192.168.1.9")]`, `["10.0.0.1", "2001:db8::1", "192.168.1.9"]`},
+		{`[net.is_ip("10.0.0.256"), net.is_ip("fe80::1")]`, "[false, true]"},
+		{`i = net.ip_info("192.168.0.4")` + "\n" + `[i["version"], i["private"], i["loopback"], i["global"]]`, "[4, true, false, false]"},
+		{`i = net.ip_info("2606:4700::1111")` + "\n" + `[i["version"], i["private"], i["global"]]`, "[6, false, true]"},
+		{`c = net.parse_cidr("10.1.2.3/22")` + "\n" + `[c["cidr"], c["network"], c["prefix"], c["first"], c["last"], c["size"]]`,
+			`["10.1.0.0/22", "10.1.0.0", 22, "10.1.0.0", "10.1.3.255", 1024]`},
+		{`net.parse_cidr("2001:db8::/32")["size"]`, "79228162514264337593543950336"},
+		{`[net.cidr_contains("10.0.0.0/8", "10.200.3.4"), net.cidr_contains("10.0.0.0/8", "11.0.0.1"), net.cidr_contains("10.0.0.0/8", "::ffff:10.0.0.1")]`, "[true, false, true]"},
+		{`[net.cidr_contains("10.0.0.0/8", "10.4.0.0/16"), net.cidr_contains("10.4.0.0/16", "10.0.0.0/8"), net.cidr_contains("2001:db8::/32", "10.0.0.1")]`, "[true, false, false]"},
+		{`net.hosts("192.168.1.0/30")`, `["192.168.1.1", "192.168.1.2"]`},
+		{`[net.hosts("10.0.0.0/31"), net.hosts("10.0.0.7/32"), len(net.hosts("10.0.0.0/16"))]`, `[["10.0.0.0", "10.0.0.1"], ["10.0.0.7"], 65534]`},
+		{`net.hosts("2001:db8::/127")`, `["2001:db8::", "2001:db8::1"]`},
+		{`net.subnets("10.0.0.0/22", 24)`, `["10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24", "10.0.3.0/24"]`},
+		{`[net.subnets("255.255.255.0/24", 25), net.subnets("10.0.0.0/24", 24)]`, `[["255.255.255.0/25", "255.255.255.128/25"], ["10.0.0.0/24"]]`},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+	for input, message := range map[string]string{
+		`net.parse_ip("10.0.0")`:         `net.parse_ip: invalid IP address "10.0.0"`,
+		`net.parse_cidr("10.0.0.0/33")`:  `net.parse_cidr: invalid CIDR range "10.0.0.0/33"`,
+		`net.cidr_contains("10/8", "1")`: `net.cidr_contains: invalid CIDR range "10/8"`,
+		`net.hosts("10.0.0.0/8")`:        "net.hosts: 10.0.0.0/8 has more than 65536 addresses; use net.subnets to split it first",
+		`net.subnets("10.0.0.0/24", 16)`: "net.subnets: prefix must be an INTEGER from 24 to 32, got 16",
+		`net.subnets("10.0.0.0/8", 25)`:  "net.subnets: splitting 10.0.0.0/8 into /25 gives more than 65536 subnets",
+	} {
+		if err, ok := testEval(input).(*object.Error); !ok || err.Message != message {
+			t.Errorf("%s: expected the error %q, got %s", input, message, testEval(input).Inspect())
+		}
+	}
+}
+
+func TestServiceHelpers(t *testing.T) {
+	pidfile := filepath.Join(t.TempDir(), "service.pid")
+	quoted := fmt.Sprintf("%q", pidfile)
+	pid := fmt.Sprint(os.Getpid())
+	input := "osWritePidfile(" + quoted + ")\n[osReadPidfile(" + quoted + "), osPid(), osRemovePidfile(" + quoted + "), osReadPidfile(" + quoted + ")]"
+	if result := testEval(input); result.Inspect() != "["+pid+", "+pid+", true, None]" {
+		t.Errorf("expected the pidfile to name this process, got %s", result.Inspect())
+	}
+
+	// A pidfile left by a process that has died is replaced, but one naming
+	// a running process is not.
+	os.WriteFile(pidfile, []byte("999999\n"), 0644)
+	if result := testEval("osWritePidfile(" + quoted + ")\nosReadPidfile(" + quoted + ")"); result.Inspect() != pid {
+		t.Errorf("expected the stale pidfile to be replaced, got %s", result.Inspect())
+	}
+	os.WriteFile(pidfile, []byte(fmt.Sprintf("%d\n", os.Getppid())), 0644)
+	message := fmt.Sprintf("osWritePidfile: '%s' names process %d, which is still running", pidfile, os.Getppid())
+	if err, ok := testEval("osWritePidfile(" + quoted + ")").(*object.Error); !ok || err.Message != message {
+		t.Errorf("expected the error %q, got %v", message, err)
+	}
+	if result := testEval("osRemovePidfile(" + quoted + ")"); result != FALSE {
+		t.Errorf("expected another process's pidfile to be left, got %s", result.Inspect())
+	}
+
+	// A handler runs at the statement after its signal arrives.
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New("got = []\nspell on_hup(name):\n    got.append(name)\nosOnSignal(\"sighup\", on_hup)")).ParseProgram(), env)
+	defer testEval(`osOnSignal("HUP", None)`)
+	self, _ := os.FindProcess(os.Getpid())
+	if err := self.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); !signalsPending.Load() && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if result := Eval(parser.New(lexer.New("x = 1\ngot")).ParseProgram(), env); result.Inspect() != `["HUP"]` {
+		t.Errorf("expected the handler to have run, got %s", result.Inspect())
+	}
+
+	for input, message := range map[string]string{
+		`osOnSignal("BOGUS", None)`: `osOnSignal: unknown signal "BOGUS"`,
+		`osOnSignal("TERM", 1)`:     "osOnSignal: handler must be a spell, got INTEGER",
+	} {
+		if err, ok := testEval(input).(*object.Error); !ok || err.Message != message {
+			t.Errorf("%s: expected the error %q, got %s", input, message, testEval(input).Inspect())
+		}
+	}
+	if result := testEval("osShutdownContext()"); result.Type() != object.CONTEXT_OBJ {
+		t.Errorf("expected a CONTEXT, got %s", result.Type())
+	}
+}
+
+func TestSketches(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"b = sketch.bloom_filter(100)\n[b.add(\"raven\"), b.add(\"raven\"), b.contains(\"raven\"), b.contains(\"crow\"), b.count()]", "[true, false, true, false, 1]"},
+		{"a = sketch.bloom_filter(10)\nb = sketch.bloom_filter(10)\na.add(1)\nb.add(\"two\")\na.merge(b)\n[a.contains(1), a.contains(\"two\"), a.count()]", "[true, true, 2]"},
+		{"h = sketch.hyperloglog()\nfor w in [\"a\", \"b\", \"a\", \"c\", \"b\"]:\n    h.add(w)\nh.count()", "3"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	// A filter at capacity claims about its error rate of values it never saw.
+	input := "b = sketch.bloom_filter(1000, 0.05)\nfor i in range(1000):\n    b.add(i)\nwrong = 0\n" +
+		"for i in range(1000, 11000):\n    if b.contains(i):\n        wrong += 1\nwrong"
+	if wrong, ok := testEval(input).(*object.Integer); !ok || wrong.Value < 300 || wrong.Value > 700 {
+		t.Errorf("expected about 500 false positives in 10000, got %v", testEval(input))
+	}
+	input = "a = sketch.hyperloglog(12)\nb = sketch.hyperloglog(12)\nfor i in range(20000):\n    a.add(i)\n" +
+		"    b.add(i + 10000)\na.merge(b)\na.count()"
+	if count, ok := testEval(input).(*object.Integer); !ok || count.Value < 28500 || count.Value > 31500 {
+		t.Errorf("expected about 30000 distinct values, got %v", testEval(input))
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"sketch.bloom_filter(0)", "sketch.bloom_filter: capacity must be a positive INTEGER, got 0"},
+		{"sketch.hyperloglog(30)", "sketch.hyperloglog: precision must be an INTEGER from 4 to 18, got 30"},
+		{"sketch.bloom_filter(10).add([1])", "add: unusable as hash key: ARRAY"},
+		{"sketch.hyperloglog(4).merge(sketch.hyperloglog(5))", "merge: hyperloglogs must have the same precision to merge"},
+	}
+	for _, tt := range errors {
+		if err, ok := testEval(tt.input).(*object.Error); !ok || err.Message != tt.expected {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, testEval(tt.input))
+		}
+	}
+}
+
+func TestLocaleFormatting(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`format_number(1234567.891)`, "1,234,567.891"},
+		{`format_number(1234567.891, "de")`, "1.234.567,891"},
+		{`format_number(1234567, "en-IN")`, "12,34,567"},
+		{`format_number(2.5, "en", 2)`, "2.50"},
+		{`format_number(1234.5678, "de", 1)`, "1.234,6"},
+		{`format_currency(1234.5, "EUR", "de")`, "€ 1.234,50"},
+		{`format_currency(1234.5, "JPY")`, "¥ 1,235"},
+	}
+	for _, tt := range tests {
+		str, ok := testEval(tt.input).(*object.String)
+		if !ok || str.Value != tt.expected {
+			t.Errorf("%s: expected %q, got=%+v", tt.input, tt.expected, str)
+		}
+	}
+
+	errors := map[string]string{
+		`format_number(1, "zz")`:     "format_number: unknown locale 'zz'",
+		`format_currency(1, "ABCD")`: "format_currency: unknown currency 'ABCD'",
+		`format_number("1")`:         "format_number: expected a number, got STRING",
+	}
+	for input, expected := range errors {
+		errObj, ok := testEval(input).(*object.Error)
+		if !ok || errObj.Message != expected {
+			t.Errorf("expected error %q for %s, got=%+v", expected, input, errObj)
+		}
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`m = parse("{user}@{domain}", "ann@mail.example.com")` + "\n" + `m["user"] + " " + m["domain"]`, "ann mail.example.com"},
+		{`m = parse("{a} {b}", "x y z")` + "\n" + `m["a"] + "|" + m["b"]`, "x|y z"},
+		{`m = parse("[{level:word}] {} took {ms:int}ms", "[WARN] GET /a took 42ms")` + "\n" + `str(m["ms"] + 1) + m["level"]`, "43WARN"},
+		{`m = parse("ratio={r:float}", "ratio=-2.5e1")` + "\n" + `str(m["r"])`, "-25.0"},
+		{`m = parse("{{{key}}}", "{port}")` + "\n" + `m["key"]`, "port"},
+		{`str(parse("{a}-{b}", "no dash") == None)`, "true"},
+		{`str(parse("{n:int}", "12x") == None)`, "true"},
+	}
+	for _, tt := range tests {
+		str, ok := testEval(tt.input).(*object.String)
+		if !ok || str.Value != tt.expected {
+			t.Errorf("%s: expected %q, got=%+v", tt.input, tt.expected, str)
+		}
+	}
+
+	errors := map[string]string{
+		`parse("{a", "x")`:       `parse: unclosed '{' in template "{a"`,
+		`parse("a}", "x")`:       `parse: unmatched '}' in template "a}"`,
+		`parse("{a:date}", "x")`: `parse: unknown field type "date" in template "{a:date}"`,
+		`parse("{a}{a}", "x")`:   `parse: field "a" appears twice in template "{a}{a}"`,
+		`parse("{1a}", "x")`:     `parse: invalid field name "1a" in template "{1a}"`,
+		`parse("{a}", 1)`:        "parse: expected a STRING to parse, got INTEGER",
+		`parse("{n:int}", "999999999999999999999999999999")`: `parse: field "n": "999999999999999999999999999999" is out of range for an integer`,
+	}
+	for input, expected := range errors {
+		errObj, ok := testEval(input).(*object.Error)
+		if !ok || errObj.Message != expected {
+			t.Errorf("expected error %q for %s, got=%+v", expected, input, errObj)
+		}
+	}
+}
+
+func TestValidateHook(t *testing.T) {
+	grim := `grim Span:
+    init(lo, hi):
+        self.lo = lo
+        self.hi = hi
+    spell __validate__():
+        if self.lo > self.hi:
+            raise "lo must not exceed hi"
+`
+	testIntegerObject(t, testEval(grim+"s = Span(1, 3)\ns.hi"), 3)
+
+	errObj, ok := testEval(grim + "s = Span(5, 1)").(*object.CustomError)
+	if !ok {
+		t.Fatalf("expected CustomError from __validate__")
+	}
+	if errObj.Message != "lo must not exceed hi" {
+		t.Errorf("wrong message: %q", errObj.Message)
+	}
+	site := errObj.StackTrace[len(errObj.StackTrace)-1]
+	if site.Function != "Span()" || site.Position.Line != 8 {
+		t.Errorf("instantiation site missing from stack trace: %+v", errObj.StackTrace)
+	}
+}
+
+func TestDefaultArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`base = 10
+spell f(x = base * 2):
+    return x
+base = 1
+f()`, 2},
+		{`spell f(a, b = len([1, 2, 3])):
+    return a + b
+f(1)`, 4},
+		{`spell f(a, b = 5):
+    return a + b
+f(1, 1)`, 2},
+		{`calls = 0
+spell tick():
+    global calls
+    calls += 1
+    return calls
+spell f(v =once tick()):
+    return v
+f()
+f()
+f()`, 1},
+		{`once = 7
+spell f(x = once):
+    return x
+f()`, 7},
+		{`grim Box:
+    init(size = 3 + 4):
+        self.size = size
+Box().size`, 7},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	errObj, ok := testEval("spell f(x = missing):\n    return x\nf()").(*object.Error)
+	if !ok || errObj.Message != "identifier not found: missing" {
+		t.Errorf("expected default evaluation error, got=%+v", errObj)
+	}
+	errObj, ok = testEval("spell f(x =once missing):\n    return x").(*object.Error)
+	if !ok || errObj.Message != "identifier not found: missing" {
+		t.Errorf("expected once default to fail at definition, got=%+v", errObj)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"record Point(x, y)\np = Point(3, 4)\np.x * p.y", 12},
+		{"record Point(x, y)\nPoint(1, 2) == Point(1, 2)", true},
+		{"record Point(x, y)\nPoint(1, 2) == Point(2, 1)", false},
+		{"record Point(x, y)\nrecord Pair(x, y)\nPoint(1, 2) == Pair(1, 2)", false},
+		{"record Point(x, y)\nPoint(1, None) != Point(1, None)", false},
+		{"record Point(x, y)\nh = {Point(1, 2): 5}\nh[Point(1, 2)]", 5},
+		{"record Empty()\nEmpty() == Empty()", true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+
+	if got := testEval("record Point(x, y)\nPoint(1, \"a\")").Inspect(); got != `Point(x=1, y="a")` {
+		t.Errorf("wrong record Inspect: %q", got)
+	}
+
+	errors := map[string]string{
+		"record Point(x, y)\nPoint(1)":      "Point expects 2 arguments, got 1",
+		"record Point(x, y)\nPoint(1, 2).z": "record Point has no field 'z'",
+		"record Point(x, x)":                "record Point: duplicate field 'x'",
+	}
+	for input, expected := range errors {
+		errObj, ok := testEval(input).(*object.Error)
+		if !ok || errObj.Message != expected {
+			t.Errorf("expected error %q for %q, got=%+v", expected, input, errObj)
+		}
+	}
+}
+
+func TestDataGrimoires(t *testing.T) {
+	point := "@data\ngrim Point:\n    x: int\n    y: int = 0\n    spell norm():\n        return self.x * self.x + self.y * self.y\n"
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{point + "Point(3, 4).norm()", 25},
+		{point + "Point(3).y", 0},
+		{point + "Point(1, 2) == Point(1, 2)", true},
+		{point + "Point(1) == Point(1, 0)", true},
+		{point + "Point(1, 2) != Point(2, 1)", true},
+		{point + "Point(1, 2) == None", false},
+		{point + "@data\ngrim Other:\n    x: int\n    y: int\nPoint(1, 2) == Other(1, 2)", false},
+		{point + "h = {Point(1, 2): 5}\nh[Point(1, 2)]", 5},
+		{point + "p = Point(1, 2)\np.x = 7\np == Point(7, 2)", true},
+		{"@data\ngrim Pair:\n    a = 1\n    b = 2\n    init(a):\n        self.a = a\n        self.b = a * 10\nPair(3).b", 30},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+
+	if got := testEval(point + "Point(1, 2)").Inspect(); got != "Point(x=1, y=2)" {
+		t.Errorf("wrong data instance Inspect: %q", got)
+	}
+	str, ok := testEval(point + "Point(5).to_string()").(*object.String)
+	if !ok || str.Value != "Point(x=5, y=0)" {
+		t.Errorf("wrong to_string result: %+v", str)
+	}
+	custom := "@data\ngrim Tag:\n    name: str\n    spell to_string():\n        return \"#\" + self.name\nTag(\"go\").to_string()"
+	if str, ok := testEval(custom).(*object.String); !ok || str.Value != "#go" {
+		t.Errorf("a written to_string should be kept, got %+v", str)
+	}
+
+	errObj, ok := testEval("@data\ngrim Point:\n    x: int\n    x: int").(*object.Error)
+	if !ok || errObj.Message != "grimoire Point declares field 'x' twice" {
+		t.Errorf("expected duplicate field error, got=%+v", errObj)
+	}
+	errObj, ok = testEval("grim Plain:\n    init():\n        ignore\nh = {Plain(): 1}").(*object.Error)
+	if !ok || errObj.Message != "unusable as hash key: INSTANCE" {
+		t.Errorf("plain instances should stay unhashable, got=%+v", errObj)
+	}
+}
+
+func TestInstanceFieldSlots(t *testing.T) {
+	grim := "grim P:\n    init(a):\n        self.a = a\n        if a > 1:\n            self.b = a * 2\n    spell extra():\n        self.c = 3\n        return self.c\n"
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{grim + "P(5).b", 10},
+		{grim + "p = P(5)\np.a = 7\np.a", 7},
+		{grim + "P(1).extra()", 3},
+		{grim + "p = P(1)\np.extra()\np.c + p.a", 4},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	child, ok := testEval(grim + "grim C(P):\n    x = 0\n    init():\n        self.d = 1\n        self.a = 2\nC").(*object.Grimoire)
+	if !ok || !maps.Equal(child.Slots, map[string]int{"a": 0, "b": 1, "x": 2, "d": 3}) {
+		t.Errorf("wrong slots: %+v", child)
+	}
+	p, ok := testEval(grim + "P(5)").(*object.Instance)
+	if !ok || p.Env != nil || len(p.Slots) != 2 {
+		t.Errorf("slotted fields should need no environment, got %+v", p)
+	}
+	p, ok = testEval(grim + "p = P(5)\np.extra()\np").(*object.Instance)
+	if !ok || p.Env == nil {
+		t.Errorf("other fields should go in an environment, got %+v", p)
+	}
+	errObj, ok := testEval(grim + "P(1).b").(*object.Error)
+	if !ok || errObj.Message != "undefined property or method: b" {
+		t.Errorf("an unset slot should be undefined, got %+v", errObj)
+	}
+}
+
+func TestMethodCalls(t *testing.T) {
+	grim := "grim Box:\n    init(f):\n        self.f = f\n    spell twice(x):\n        return self.f(self.f(x))\n    spell __secret():\n        return 1\nspell inc(x):\n    return x + 1\nb = Box(inc)\n"
+	testIntegerObject(t, testEval(grim+"b.f(1)"), 2)
+	testIntegerObject(t, testEval(grim+"b.twice(1)"), 3)
+	testIntegerObject(t, testEval(grim+"b.twice((5))"), 7)
+
+	errObj, ok := testEval(grim + "b.__secret()").(*object.Error)
+	if !ok || errObj.Message != "private method '__secret' not accessible outside its defining class" {
+		t.Errorf("a private method should not be callable from outside, got %+v", errObj)
+	}
+
+	env := object.NewEnvironment()
+	ctx := NewEvalContext("main.crl")
+	env.SetContext(ctx)
+	Eval(parser.New(lexer.New(grim+"b.twice(1)\nb.missing()")).ParseProgram(), env)
+	if len(ctx.callStack) != 0 {
+		t.Errorf("method calls should pop their frames, %d left", len(ctx.callStack))
+	}
+}
+
+func TestBoundMethods(t *testing.T) {
+	grim := "grim Counter:\n    init(start):\n        self.count = start\n    spell add(n):\n        self.count = self.count + n\n        return self.count\n" +
+		"a = Counter(1)\nb = Counter(10)\n"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{grim + "m = a.add\nm(2)\na.count", "3"},
+		{grim + "[1, 2].map(a.add)", "[2, 4]"},
+		{grim + "handlers = {\"add\": b.add}\nhandlers[\"add\"](5)", "15"},
+		{grim + "a.add", "<bound method Counter.add>"},
+		{grim + "a.add == a.add", "true"},
+		{grim + "a.add == b.add", "false"},
+		{grim + "m = bind(unbind(a.add), b)\nm(1)\n(a.count, b.count)", "(1, 11)"},
+		{grim + "bind(a.add, b)(2)", "12"},
+		{grim + "unbind(a.add) == unbind(b.add)", "true"},
+		{grim + "spell reset():\n    self.count = 0\nbind(reset, a)()\na.count", "0"},
+		{grim + "[a.add, b.add].index(b.add)", "1"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{grim + "bind(a.add, 1)", "bind: expected an INSTANCE to bind to, got INTEGER"},
+		{grim + "bind(len, a)", "bind: expected a FUNCTION or BOUND_METHOD, got BUILTIN"},
+		{"unbind(len)", "unbind: expected a BOUND_METHOD, got BUILTIN"},
+	}
+	for _, tt := range errors {
+		if err, ok := testEval(tt.input).(*object.Error); !ok || err.Message != tt.message {
+			t.Errorf("%q: expected %q, got %+v", tt.input, tt.message, testEval(tt.input))
+		}
+	}
+}
+
+func TestExtend(t *testing.T) {
+	grim := "grim Point:\n    init(x, y):\n        self.x = x\n        self.y = y\n" +
+		"grim Point3(Point):\n    init(x, y, z):\n        self.x = x\n        self.y = y\n        self.z = z\n" +
+		"extend Point:\n    spell total():\n        return self.x + self.y\n"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{grim + "Point(1, 2).total()", "3"},
+		{grim + "Point3(3, 4, 5).total()", "7"},
+		{grim + "extend Point3:\n    spell total():\n        return self.x + self.y + self.z\nPoint3(3, 4, 5).total()", "12"},
+		{grim + "extend Point:\n    spell total():\n        return 0\nPoint(1, 2).total()", "0"},
+		{grim + "signature(Point(1, 2).total).name", `"Point.total"`},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"x = 1\nextend x:\n    spell f():\n        return 1\n", "cannot extend x: not a grimoire"},
+		{grim + "extend Point:\n    spell __hidden():\n        return 1\nPoint(1, 2).__hidden", "private method '__hidden' not accessible outside its defining class"},
+	}
+	for _, tt := range errors {
+		if err, ok := testEval(tt.input).(*object.Error); !ok || err.Message != tt.message {
+			t.Errorf("%q: expected %q, got %+v", tt.input, tt.message, testEval(tt.input))
+		}
+	}
+
+	run := newStdlibRunner(t, object.NewEnvironment())
+	run("extend String:\n    spell shout():\n        return self.value.upper() + \"!\"\n" +
+		"extend Array:\n    spell second():\n        return self.elements[1]\n")
+	stdlib := []struct {
+		input    string
+		expected string
+	}{
+		{`"crow".shout()`, `"CROW!"`},
+		{`[1, 2, 3].second()`, "2"},
+		{`[1, 2, 3].sum()`, "6"},
+		{`"crow".upper()`, `"CROW"`},
+	}
+	for _, tt := range stdlib {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+	if err, ok := run(`[1].third`).(*object.Error); !ok || err.Message != "array has no method 'third'" {
+		t.Errorf("expected a missing method error, got %+v", err)
+	}
+}
+
+func TestSuper(t *testing.T) {
+	grims := "grim Animal:\n    init(name):\n        self.name = name\n    spell speak():\n        return \"...\"\n" +
+		"grim Dog(Animal):\n    init(name, breed):\n        super.init(name)\n        self.breed = breed\n    spell speak():\n        return \"Woof \" + super.speak()\n" +
+		"grim Puppy(Dog):\n    init(name):\n        super.init(name, \"mutt\")\n    spell speak():\n        return \"Yip \" + super.speak()\n" +
+		"grim Cat(Animal):\n    spell purr():\n        return self.name\n"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{grims + "d = Dog(\"Rex\", \"corgi\")\n(d.name, d.breed)", `("Rex", "corgi")`},
+		{grims + "p = Puppy(\"Bit\")\n(p.name, p.breed)", `("Bit", "mutt")`},
+		{grims + "Puppy(\"Bit\").speak()", `"Yip Woof ..."`},
+		{grims + "Cat(\"Tom\").purr()", `"Tom"`},
+		{grims + "signature(Cat).parameters", `["name"]`},
+		{grims + "grim Kitten(Cat):\n    init():\n        super.init(\"Kit\")\nKitten().purr()", `"Kit"`},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"grim A:\n    spell f():\n        return 1\ngrim B(A):\n    init():\n        super.init()\nB()", "no method 'init' found in parent class A"},
+		{"grim A:\n    init():\n        x = missing\nA()", "identifier not found: missing"},
+	}
+	for _, tt := range errors {
+		if err, ok := testEval(tt.input).(*object.Error); !ok || err.Message != tt.message {
+			t.Errorf("%q: expected %q, got %+v", tt.input, tt.message, testEval(tt.input))
+		}
+	}
+}
+
+func TestJSONModule(t *testing.T) {
+	defs := "@data\ngrim Address:\n    city: str\n    zip = \"\"\n" +
+		"@data\ngrim User:\n    name: str\n    score: float\n    home: Address\n    past: [Address]\n    tags = []\n"
+	user := `u = json.into(User, '{"name": "Ada", "score": 9, "home": {"city": "Oslo"}, "past": [{"city": "Rome", "zip": "001"}], "extra": 1}')` + "\n"
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{defs + user + "u.home.city", "Oslo"},
+		{defs + user + "u.home == Address(\"Oslo\")", true},
+		{defs + user + "u.past[0] == Address(\"Rome\", \"001\")", true},
+		{defs + user + "u.score", "9.0"},
+		{defs + user + "json.from(u)", `{"name": "Ada", "score": 9.0, "home": {"city": "Oslo", "zip": ""}, "past": [{"city": "Rome", "zip": "001"}], "tags": []}`},
+		{defs + user + "json.into(User, json.from(u)).past[0] == u.past[0]", true},
+		{`json.from([1, "a\"b<", None, True, (1, 2), {"b": 2, "a": {"c": 1.5}}])`, `[1, "a\"b<", null, true, [1, 2], {"a": {"c": 1.5}, "b": 2}]`},
+		{"record P(x, y)\njson.from(P(1, 2))", `{"x": 1, "y": 2}`},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if object.Display(evaluated) != expected {
+				t.Errorf("wrong result for %q: got %s, want %s", tt.input, object.Display(evaluated), expected)
+			}
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{defs + `json.into(User, '{"name": "x"}')`, "json.into: User is missing field 'score'"},
+		{defs + `json.into(User, '{"name": "x", "score": 1, "home": [], "past": []}')`, "json.into: User.home must be an object, got an array"},
+		{defs + `json.into(User, '{"name": "x", "score": 1, "home": {"city": "a"}, "past": [5]}')`, "json.into: User.past[0] must be an object, got a number"},
+		{defs + `json.into(User, '{"name": ')`, "json.into: invalid JSON: unexpected EOF"},
+		{"grim Plain:\n    init():\n        ignore\njson.into(Plain, '{}')", "json.into expects a @data grimoire, got <grimoire Plain>"},
+		{"grim Plain:\n    init():\n        ignore\njson.from([Plain()])", "json.from: value[0] is an instance of Plain, which is not a @data grimoire"},
+		{"json.from({1: 2})", "json.from: value has a non-string key 1"},
+	}
+	for _, tt := range errors {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.message {
+			t.Errorf("expected error %q, got=%+v", tt.message, errObj)
+		}
+	}
+}
+
+// writeModule writes a file under dir for an import test and returns its
+// path.
+func writeModule(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testEvalFile evaluates input as though it were the contents of file.
+func testEvalFile(file, input string) object.Object {
+	p := parser.New(lexer.New(input, file))
+	env := object.NewEnvironment()
+	env.SetContext(NewEvalContext(file))
+	return Eval(p.ParseProgram(), env)
+}
+
+// newStdlibRunner loads the munin standard library into env and returns a
+// func that evaluates input in it as the file main.crl, so that what one
+// input defines the next can use.
+func newStdlibRunner(t *testing.T, env *object.Environment) func(string) object.Object {
+	t.Helper()
+	if env.Context() == nil {
+		env.SetContext(NewEvalContext("main.crl"))
+	}
+	if err := LoadMuninStdlib(env); err != nil {
+		t.Fatal(err)
+	}
+	return func(input string) object.Object {
+		return Eval(parser.New(lexer.New(input, "main.crl")).ParseProgram(), env)
+	}
+}
+
+func TestImportSearchPath(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "app/helper.crl", "grim Helper:\n    spell name():\n        return \"helper\"\n")
+	writeModule(t, dir, "shared/shapes/index.crl", "grim Circle:\n    init(r):\n        self.r = r\n")
+	writeModule(t, dir, "shared/helper.crl", "grim Helper:\n    spell name():\n        return \"shadowed\"\n")
+	main := writeModule(t, dir, "app/main.crl", "")
+	t.Setenv(ImportPathEnv, filepath.Join(dir, "shared"))
+
+	run := func(input string) object.Object { return testEvalFile(main, input) }
+	if got := run("import \"helper\"\nHelper().name()"); object.Display(got) != "helper" {
+		t.Errorf("the script's own directory should come first, got %s", got.Inspect())
+	}
+	testIntegerObject(t, run("import \"shapes\" as s\ns.Circle(3).r"), 3)
+
+	errObj, ok := run("import \"nowhere\"").(*object.CustomError)
+	if !ok || errObj.Name != "ImportError" {
+		t.Fatalf("expected an ImportError, got %v", errObj)
+	}
+	for _, want := range []string{
+		`cannot find module "nowhere"; tried:`,
+		filepath.Join(dir, "app", "nowhere.crl"),
+		filepath.Join(dir, "shared", "nowhere", "index.crl"),
+	} {
+		if !strings.Contains(errObj.Message, want) {
+			t.Errorf("message is missing %q:\n%s", want, errObj.Message)
+		}
+	}
+}
+
+func TestCarrionVersion(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "old/carrion.mod", "carrion_version 1.0\n")
+	writeModule(t, dir, "old/ledger.crl", "record = 4\nspell total(n):\n    global = record + n\n    return global\n")
+	main := filepath.Join(dir, "main.crl")
+
+	testIntegerObject(t, testEval("pragma carrion_version \"1.0\"\nrecord = 2\nexport = record * 3\nexport"), 6)
+	testIntegerObject(t, testEval("pragma carrion_version \"1.0\"\nexpose = 1\nexpose + 1"), 2)
+	testIntegerObject(t, testEvalFile(main, "import \"old/ledger\" as ledger\nledger.total(1)"), 5)
+
+	p := parser.New(lexer.New("record = 2"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Error("record should be a keyword in the current version")
+	}
+	p = parser.New(lexer.New("expose = 1"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Error("expose should be a keyword in the current version")
+	}
+	p = parser.New(lexer.New("pragma carrion_version \"0.1\""))
+	p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 1 || errs[0] != `unknown carrion_version "0.1"; known versions are 1.0, 1.1, 1.2` {
+		t.Errorf("expected an unknown version error, got %v", errs)
+	}
+}
+
+func TestImportExpose(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "shapes.crl", "_scale = 2\nspell _double(x):\n    return x * _scale\n"+
+		"spell area(r):\n    return _double(r) * 3\ngrim Circle:\n    init(r):\n        self.r = r\n"+
+		"grim _Cache:\n    init():\n        ignore\n")
+	main := filepath.Join(dir, "main.crl")
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"import \"shapes\" expose area, Circle\narea(5) + Circle(4).r", 34},
+		{"import \"shapes\" as s\ns.area(1)", 6},
+		{"import \"shapes\" expose area\nimport \"shapes\" expose Circle\nCircle(area(1)).r", 6},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEvalFile(main, tt.input), tt.expected)
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"import \"shapes\" expose area\nCircle", "identifier not found: Circle"},
+		{"import \"shapes\"\n_Cache", "identifier not found: _Cache"},
+		{"import \"shapes\" as s\ns._scale", "undefined namespace member: _scale"},
+		{"import \"shapes\" expose _double", `"_double" is private to module "shapes"`},
+		{"import \"shapes\" expose volume", `module "shapes" has no exported name "volume"`},
+	}
+	for _, tt := range errors {
+		var message string
+		switch err := testEvalFile(main, tt.input).(type) {
+		case *object.Error:
+			message = err.Message
+		case *object.CustomError:
+			message = err.Message
+		}
+		if message != tt.message {
+			t.Errorf("%q: expected error %q, got %q", tt.input, tt.message, message)
+		}
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "consts.crl", "limit = 10\nspell twice(x):\n    return x * 2\n")
+	writeModule(t, dir, "api.crl", "export handle, VERSION\nVERSION = 3\nhelper = 100\n"+
+		"spell handle(x):\n    return x + helper\n")
+	writeModule(t, dir, "broken.crl", "export missing\nx = 1\n")
+	main := filepath.Join(dir, "main.crl")
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"import \"consts\"\ntwice(limit)", 20},
+		{"import \"api\"\nhandle(1) + VERSION", 104},
+		{"import \"api\" as a\na.VERSION", 3},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEvalFile(main, tt.input), tt.expected)
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"import \"api\"\nhelper", "identifier not found: helper"},
+		{"import \"api\" expose helper", `module "api" has no exported name "helper"`},
+		{"import \"broken\"", `module "` + filepath.Join(dir, "broken.crl") + `" exports "missing" but does not define it`},
+		{"spell f():\n    export f\nf()", "export: can only be used at the top level of a module"},
+	}
+	for _, tt := range errors {
+		var message string
+		switch err := testEvalFile(main, tt.input).(type) {
+		case *object.Error:
+			message = err.Message
+		case *object.CustomError:
+			message = err.Message
+		}
+		if message != tt.message {
+			t.Errorf("%q: expected error %q, got %q", tt.input, tt.message, message)
+		}
+	}
+}
+
+func TestLazyImport(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "tools.crl", "spell twice(x):\n    return x * 2\n")
+	writeModule(t, dir, "broken.crl", "spell (\n")
+	main := filepath.Join(dir, "main.crl")
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"lazy import \"tools\"\ntools.twice(4)", 8},
+		{"lazy import \"tools\" as t\nt.twice(5)", 10},
+		{"lazy import \"broken\"\n1", 1},
+		{"spell f(n):\n    if n > 0:\n        import \"tools\" expose twice\n        return twice(n)\n    return 0\nf(0) + f(3)", 6},
+		{"lazy = 7\nlazy", 7},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEvalFile(main, tt.input), tt.expected)
+	}
+
+	if _, ok := testEvalFile(main, "lazy import \"missing\"\nmissing.x").(*object.CustomError); !ok {
+		t.Errorf("expected an ImportError when a missing lazy module is first used")
+	}
+}
+
+func TestCacheParses(t *testing.T) {
+	CacheParses = true
+	defer func() { CacheParses = false }()
+	dir := t.TempDir()
+	module := writeModule(t, dir, "value.crl", "n = 1\n")
+	main := filepath.Join(dir, "main.crl")
+
+	testIntegerObject(t, testEvalFile(main, "import \"value\" as v\nv.n"), 1)
+	parseCache.Lock()
+	_, cached := parseCache.files[module]
+	parseCache.Unlock()
+	if !cached {
+		t.Fatal("expected the module's parse to be cached")
+	}
+	testIntegerObject(t, testEvalFile(main, "import \"value\" as v\nv.n"), 1)
+
+	// A changed file is parsed again.
+	writeModule(t, dir, "value.crl", "n = 22\n")
+	testIntegerObject(t, testEvalFile(main, "import \"value\" as v\nv.n"), 22)
+}
+
+func TestImportCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "a.crl", "import \"b\"\ngrim A:\n    init():\n        ignore\n")
+	writeModule(t, dir, "b.crl", "import \"a\"\n")
+	writeModule(t, dir, "back.crl", "import \"main\"\n")
+	writeModule(t, dir, "broken.crl", "x = 1 + \"one\"\n")
+	main := writeModule(t, dir, "main.crl", "")
+
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{"import \"a\"", "import cycle: " + filepath.Join(dir, "a.crl") + " -> " + filepath.Join(dir, "b.crl") + " -> " + filepath.Join(dir, "a.crl")},
+		{"import \"back\"", "import cycle: " + main + " -> " + filepath.Join(dir, "back.crl") + " -> " + main},
+		{"import \"broken\"", "type mismatch: INTEGER + STRING"},
+	}
+	for _, tt := range tests {
+		var message string
+		switch err := testEvalFile(main, tt.input).(type) {
+		case *object.Error:
+			message = err.Message
+		case *object.CustomError:
+			message = err.Message
+		}
+		if message != tt.message {
+			t.Errorf("%q: expected error %q, got %q", tt.input, tt.message, message)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeModule(t, dir, "lib.crl", "version = 1\n")
+	writeModule(t, dir, "other.crl", "version = 1\n")
+
+	env := object.NewEnvironment()
+	ctx := NewEvalContext("main.crl")
+	env.SetContext(ctx)
+	run := func(input string) object.Object {
+		return Eval(parser.New(lexer.New(input, "main.crl")).ParseProgram(), env)
+	}
+
+	run("import \"lib\" as lib\nimport \"" + filepath.Join(dir, "lib") + "\" as same")
+	if len(ctx.imports) != 1 {
+		t.Fatalf("expected lib.crl to be loaded once, got imports %v", ctx.imports)
+	}
+	writeModule(t, dir, "lib.crl", "version = 2\n")
+	testIntegerObject(t, run("lib.version"), 1)
+	run("reload(\"lib\")")
+	testIntegerObject(t, run("lib.version + same.version"), 4)
+	writeModule(t, dir, "lib.crl", "version = 3\n")
+	run("reload(lib)")
+	testIntegerObject(t, run("lib.version"), 3)
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{`reload("other")`, `module "other" has not been imported`},
+		{`reload(1)`, "reload expects a module name or namespace, got INTEGER"},
+	}
+	for _, tt := range errors {
+		var message string
+		switch err := run(tt.input).(type) {
+		case *object.Error:
+			message = err.Message
+		case *object.CustomError:
+			message = err.Message
+		}
+		if message != tt.message {
+			t.Errorf("%q: expected error %q, got %q", tt.input, tt.message, message)
+		}
+	}
+}
+
+func TestImportPackage(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(mod.CacheEnv, filepath.Join(dir, "cache"))
+	writeModule(t, dir, "cache/shapes@v1/index.crl", "grim Square:\n    init(side):\n        self.side = side\n")
+	writeModule(t, dir, "cache/shapes@v1/circle.crl", "grim Circle:\n    init(r):\n        self.r = r\n")
+	writeModule(t, dir, "app/shapes.crl", "grim Square:\n    init(side):\n        self.side = 0\n")
+	writeModule(t, dir, "app/"+mod.ManifestFile, "shapes v1 https://example.com/shapes.git\n")
+	main := writeModule(t, dir, "app/main.crl", "")
+
+	testIntegerObject(t, testEvalFile(main, "import \"shapes\"\nSquare(3).side"), 3)
+	testIntegerObject(t, testEvalFile(main, "import \"shapes/circle\"\nCircle(2).r"), 2)
+
+	writeModule(t, dir, "app/"+mod.ManifestFile, "shapes v2 https://example.com/shapes.git\n")
+	testIntegerObject(t, testEvalFile(main, "import \"shapes\"\nSquare(3).side"), 0)
+}
+
+func TestStrictTypes(t *testing.T) {
+	grims := "grim Shape:\n    init(name):\n        self.name = name\ngrim Box(Shape):\n    init():\n        self.name = \"box\"\n"
+	unchecked := "x: int = \"five\"\nspell f(n: int):\n    return n\nf(\"six\")"
+	if str, ok := testEval(unchecked).(*object.String); !ok || str.Value != "six" {
+		t.Fatalf("expected hints to be ignored by default, got %v", str)
+	}
+
+	t.Cleanup(func() { StrictTypes = false })
+	StrictTypes = true
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"x: int = 5\nx", 5},
+		{"spell f(x: float):\n    return 1\nf(2)", 1},
+		{"spell f(n: int = None):\n    return 2\nf()", 2},
+		{grims + "spell f(s: Shape, items: [int]):\n    return len(items)\nf(Box(), [1, 2, 3])", 3},
+		{"spell f(x: Unknown):\n    return 4\nf(\"anything\")", 4},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"x: str = 5", "variable x expects str, got INTEGER"},
+		{"spell f(n: int):\n    return n\nf(1.5)", "parameter n expects int, got FLOAT"},
+		{grims + "spell f(s: Box):\n    return 1\nf(Shape(\"s\"))", "parameter s expects Box, got INSTANCE"},
+		{"spell f(items: [str]):\n    return 1\nf([\"a\", 2])", "parameter items expects [str], got ARRAY"},
+	}
+	for _, tt := range errors {
+		errObj, ok := testEval(tt.input).(*object.CustomError)
+		if !ok || errObj.Name != "TypeError" || errObj.Message != tt.message {
+			t.Errorf("%q: expected TypeError %q, got %+v", tt.input, tt.message, errObj)
+		}
+	}
+}
+
+func TestCoerceStrings(t *testing.T) {
+	if err, ok := testEval(`"x" + 5`).(*object.Error); !ok || err.Message != "type mismatch: STRING + INTEGER" {
+		t.Errorf("expected a type mismatch without coercion, got %+v", err)
+	}
+	if got := testEval(`concat("x", 5, " ", 2.5, [1])`); object.Display(got) != "x5 2.5[1]" {
+		t.Errorf("wrong concat result: %s", got.Inspect())
+	}
+
+	run := func(input string) object.Object {
+		env := object.NewEnvironment()
+		ctx := NewEvalContext("main.crl")
+		ctx.SetCoerceStrings(true)
+		env.SetContext(ctx)
+		return Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"x" + 5`, `"x5"`},
+		{`1.5 + "x"`, `"1.5x"`},
+		{`"n=" + 2 ** 70`, `"n=1180591620717411303424"`},
+		{`1 + 2 + "x"`, `"3x"`},
+		{`"x" + 1 + 2`, `"x12"`},
+		{`"a" + "b"`, `"ab"`},
+	}
+	for _, tt := range tests {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+	for _, input := range []string{`"x" - 5`, `"x" + None`, `"x" + [1]`} {
+		if _, ok := run(input).(*object.Error); !ok {
+			t.Errorf("%q: expected an error even with coercion", input)
+		}
+	}
+}
+
+func TestStrictMatch(t *testing.T) {
+	match := "match x:\n    case 1:\n        \"one\"\n    case 2:\n        \"two\"\n"
+	if result := testEval("x = 3\n" + match); result != NONE {
+		t.Errorf("expected None from an unmatched match by default, got %s", result.Inspect())
+	}
+
+	run := func(input string) object.Object {
+		env := object.NewEnvironment()
+		ctx := NewEvalContext("main.crl")
+		ctx.SetStrictMatch(true)
+		env.SetContext(ctx)
+		return Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	}
+	if result := run("x = 2\n" + match); object.Display(result) != "two" {
+		t.Errorf("expected the matching case, got %s", result.Inspect())
+	}
+	if result := run("x = 3\n" + match + "    _:\n        \"other\""); object.Display(result) != "other" {
+		t.Errorf("expected the default, got %s", result.Inspect())
+	}
+	err, ok := run(`x = "three"` + "\n" + match).(*object.CustomError)
+	if !ok || err.Name != "MatchError" || err.Message != `no case matches "three"` {
+		t.Errorf("expected a MatchError, got %+v", err)
+	}
+}
+
+func TestMatchPatterns(t *testing.T) {
+	grade := "spell grade(n):\n    match n:\n        case > 100:\n            return \"over\"\n        case 90..100:\n            return \"A\"\n" +
+		"        case 80..89.5:\n            return \"B\"\n        case < 0:\n            return \"negative\"\n        case 0:\n            return \"zero\"\n" +
+		"        case != 50:\n            return \"other\"\n        _:\n            return \"fifty\"\n"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{grade + "grade(101)", `"over"`},
+		{grade + "grade(100)", `"A"`},
+		{grade + "grade(90)", `"A"`},
+		{grade + "grade(89.5)", `"B"`},
+		{grade + "grade(89.7)", `"other"`},
+		{grade + "grade(-1)", `"negative"`},
+		{grade + "grade(0)", `"zero"`},
+		{grade + "grade(50)", `"fifty"`},
+		{grade + "grade(\"x\")", `"other"`},
+		{"lo = 1\nhi = 3\nmatch 2:\n    case lo..hi:\n        \"in\"\n", `"in"`},
+		{"match \"m\":\n    case \"a\"..\"z\":\n        \"lower\"\n", `"lower"`},
+		{"[1, 2, 3][1:2]", "[2]"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+	if err, ok := testEval("match 1:\n    case 0..nope:\n        1\n").(*object.Error); !ok || err.Message != "identifier not found: nope" {
+		t.Errorf("expected an error from the range bound, got %+v", err)
+	}
+}
+
+func TestDisplayAndRepr(t *testing.T) {
+	t.Cleanup(func() { object.FloatPrecision = -1 })
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`str("a")`, `a`},
+		{`repr("a")`, `"a"`},
+		{`repr("say \"hi\"")`, `"say \"hi\""`},
+		{`str(["a", 1])`, `["a", 1]`},
+		{`x = "b"` + "\n" + `f"{x} {[x]} {(x, 2.5)}"`, `b ["b"] ("b", 2.5)`},
+		{`str(0.1 + 0.2)`, `0.30000000000000004`},
+		{`str(6.0 / 2.0)`, `3.0`},
+		{`str(math.inf)`, `+Inf`},
+		{"setfloatprecision(2)\nstr(2.0 / 3.0)", `0.67`},
+		{"setfloatprecision(0)\nstr(2.5)", `2`},
+		{"setfloatprecision(2)\nsetfloatprecision(None)\nstr(2.0 / 3.0)", `0.6666666666666666`},
+		{"setfloatprecision(3)\nstr(getfloatprecision())", `3`},
+		{"str(getfloatprecision())", `None`},
+	}
+	for _, tt := range tests {
+		object.FloatPrecision = -1
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok || str.Value != tt.expected {
+			t.Errorf("%q: got %s, want %s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+
+	object.FloatPrecision = -1
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	ctx := NewEvalContext("")
+	ctx.SetStdout(&out)
+	env.SetContext(ctx)
+	Eval(parser.New(lexer.New(`print("a", ["b"], 1.5)`)).ParseProgram(), env)
+	if out.String() != "a  \n[\"b\"]  \n1.5  \n" {
+		t.Errorf("print wrote %q", out.String())
+	}
+
+	errObj, ok := testEval("setfloatprecision(-1)").(*object.Error)
+	if !ok || errObj.Message != "setfloatprecision: digits must be between 0 and 17, got -1" {
+		t.Errorf("expected a range error, got=%+v", errObj)
+	}
+}
+
+func TestFStringFormatSpecs(t *testing.T) {
+	prefix := "value = 3.14159\nx = \"hi\"\nn = 42\n"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`f"{value:.2f}|{x:>6}|{n:04d}|{x:*^8}"`, `3.14|    hi|0042|***hi***`},
+		{`f"{-n:=+6}|{n:+}|{n: }|{-value:08.3f}"`, `-   42|+42| 42|-003.142`},
+		{`f"{n:x}|{255:X}|{n:b}|{n:o}|{65:c}|{65535:_x}"`, `2a|FF|101010|52|A|ffff`},
+		{`f"{1234567:,}|{1234.5:,.2f}|{0.256:.1%}|{n:e}|{value:.3}"`, `1,234,567|1,234.50|25.6%|4.200000e+01|3.14`},
+		{`f"{x:.1}|{[1, 2]:>8}|{[1, 2, 3][1:]}|{n:d}"`, `h|  [1, 2]|[2, 3]|42`},
+		{`f"{2 ** 70:,}"`, `1,180,591,620,717,411,303,424`},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(prefix + tt.input)
+		if str, ok := evaluated.(*object.String); !ok || str.Value != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := map[string]string{
+		`f"{'hi':d}"`: "f-string: format type 'd' needs a number, got STRING",
+		`f"{1.5:x}"`:  "f-string: format type 'x' needs an INTEGER, got FLOAT",
+		`f"{1:>5q}"`:  `f-string: invalid format spec ">5q"`,
+		`f"{'a':+}"`:  `f-string: format spec "+" is only for numbers, got STRING`,
+		`f"{1.0:.f}"`: `f-string: invalid format spec ".f": missing precision after '.'`,
+	}
+	for input, message := range errors {
+		if err, ok := testEval(input).(*object.Error); !ok || err.Message != message {
+			t.Errorf("%s: expected error %q, got %+v", input, message, testEval(input))
+		}
+	}
+}
+
+func TestFStringFields(t *testing.T) {
+	prefix := "names = {\"ada\": \"Lovelace\"}\nxs = [1, 2, 3]\n"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`f"{names["ada"]} {names['ada']}"`, `Lovelace Lovelace`},
+		{`f"{{literal}} {{{len(xs)}}} }}"`, `{literal} {3} }`},
+		{`f"{ {"k": 2.5}["k"]:>6.2f}|{xs[1:]}|{"a:b"}"`, `  2.50|[2, 3]|a:b`},
+		{`f'{"it\'s"} {len(names["ada"])}'`, `it's 8`},
+		{"f\"\"\"{names[\n    \"ada\"]}\n\"\"\"", "Lovelace\n"},
+		{`f"tab\t{"x\"y"}"`, "tab\tx\"y"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(prefix + tt.input)
+		if str, ok := evaluated.(*object.String); !ok || str.Value != tt.expected {
+			t.Errorf("%s: expected %q, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	p := parser.New(lexer.New(`f"{1 +}"`))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 1 || errors[0] != "invalid expression in f-string: {1 +}" {
+		t.Errorf("expected an error for the field, got %q", errors)
+	}
+}
+
+func TestRuntimeStats(t *testing.T) {
+	prefix := "grim Point:\n    init(x):\n        self.x = x\npoints = [Point(1), Point(2), Point(3)]\n" +
+		"spell inner():\n    return runtime.stats()\n"
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{prefix + `runtime.stats()["objects"]["INSTANCE"]`, 3},
+		{prefix + `runtime.stats()["env_depth"]`, 0},
+		{prefix + `inner()["env_depth"]`, 1},
+		{prefix + `inner()["call_depth"]`, 1},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	testBooleanObject(t, testEval(`runtime.stats()["memory"]["heap_alloc"] > 0`), true)
+	if errObj, ok := testEval("runtime.stats(1)").(*object.Error); !ok || errObj.Message != "runtime.stats takes no arguments, got=1" {
+		t.Errorf("expected an arity error, got=%+v", errObj)
+	}
+}
+
+func TestProfiler(t *testing.T) {
+	input := "spell fib(n):\n    if n < 2:\n        return n\n    return fib(n - 1) + fib(n - 2)\nspell run():\n    total = fib(10)\n    return total\nx = run()"
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	ctx := NewEvalContext("")
+	env.SetContext(ctx)
+	profiler := NewProfiler()
+	ctx.SetProfiler(profiler)
+	Eval(program, env)
+
+	var out bytes.Buffer
+	profiler.WriteReport(&out)
+	entries := map[string]ProfileEntry{}
+	for _, entry := range profiler.Entries() {
+		entries[entry.Name] = entry
+	}
+	if got := entries["fib"].Calls; got != 177 {
+		t.Errorf("fib was called %d times, want 177", got)
+	}
+	if got := entries["run"].Calls; got != 1 {
+		t.Errorf("run was called %d times, want 1", got)
+	}
+	root := entries[ProfileRoot]
+	if root.Total < entries["run"].Total || entries["run"].Total < entries["fib"].Total {
+		t.Errorf("totals should nest: root %v, run %v, fib %v", root.Total, entries["run"].Total, entries["fib"].Total)
+	}
+	if entries["fib"].Self > entries["fib"].Total {
+		t.Errorf("fib self time %v exceeds its total %v", entries["fib"].Self, entries["fib"].Total)
+	}
+	if !strings.Contains(out.String(), "    177 ") || !strings.HasPrefix(out.String(), "profile: ") {
+		t.Errorf("unexpected report:\n%s", out.String())
+	}
+}
+
+func TestRPC(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "rpc.sock")
+	point := "@data\ngrim Point:\n    x: int\n    y: int\n"
+
+	serve := newStdlibRunner(t, object.NewEnvironment())
+	serve(point + "spell shift(p, d):\n    return Point(p.x + d, p.y + d)\nspell fail():\n    raise ValueError(\"bad\")\nspell pair(a, b):\n    return (b, [a, 1.5, None, {\"k\": True}])\n")
+	if result := serve(`server = rpc.listen("` + socket + `", {"shift": shift, "fail": fail, "pair": pair})`); isError(result) {
+		t.Fatalf("listen failed: %s", result.Inspect())
+	}
+	done := make(chan object.Object)
+	go func() { done <- serve("server.accept()") }()
+
+	call := newStdlibRunner(t, object.NewEnvironment())
+	call(point + `remote = rpc.connect("` + socket + `")`)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"remote.shift(Point(1, 2), 10) == Point(11, 12)", "true"},
+		{"remote.pair(2, \"b\")", `("b", [2, 1.5, , {"k": true}])`},
+	}
+	for _, tt := range tests {
+		if result := call(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"remote.fail()", "fail: ValueError: bad"},
+		{"remote.shift(remote.shift, 1)", "shift: argument 1: cannot send BUILTIN"},
+	}
+	for _, tt := range errors {
+		err, ok := call(tt.input).(*object.CustomError)
+		if !ok || err.Name != "RPCError" || err.Message != tt.message {
+			t.Errorf("%s: expected RPCError %q, got %+v", tt.input, tt.message, err)
+		}
+	}
+
+	call("remote.close()")
+	if result := <-done; isError(result) {
+		t.Errorf("accept failed: %s", result.Inspect())
+	}
+	serve("server.close()")
+}
+
+// TestMain lets the test binary stand in for the interpreter as a worker of
+// workers.pool, run with the file to serve in CARRION_TEST_WORKER.
+func TestMain(m *testing.M) {
+	if file := os.Getenv("CARRION_TEST_WORKER"); file != "" {
+		program, _, err := parseModule(file)
+		if err != nil || program == nil {
+			os.Exit(1)
+		}
+		env := object.NewEnvironment()
+		env.SetContext(NewEvalContext(file))
+		if err := LoadMuninStdlib(env); err != nil || isError(Eval(program, env)) {
+			os.Exit(1)
+		}
+		ServeWorker(env)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestWorkers(t *testing.T) {
+	defer func(command func(string) (*exec.Cmd, error)) { WorkerCommand = command }(WorkerCommand)
+	WorkerCommand = func(file string) (*exec.Cmd, error) {
+		cmd := exec.Command(os.Args[0], "-test.run=^$")
+		cmd.Env = append(os.Environ(), "CARRION_TEST_WORKER="+file)
+		return cmd, nil
+	}
+	dir := t.TempDir()
+	jobs := writeModule(t, dir, "jobs.crl", "spell square(n):\n    return n * n\n"+
+		"spell pair(a, b):\n    return (b, a)\nspell fail(x):\n    raise ValueError(\"bad \" + str(x))\n")
+	bad := writeModule(t, dir, "bad.crl", "raise ValueError(\"no\")\n")
+
+	run := newStdlibRunner(t, object.NewEnvironment())
+	if result := run(`pool = workers.pool("` + jobs + `", 2)`); isError(result) {
+		t.Fatalf("pool failed: %s", result.Inspect())
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"pool.size", "2"},
+		{`pool.map("square", [1, 2, 3, 4, 5])`, "[1, 4, 9, 16, 25]"},
+		{`pool.map("square", [])`, "[]"},
+		{`pool.call("pair", 1, "b")`, `("b", 1)`},
+	}
+	for _, tt := range tests {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{`pool.map("fail", [1, 2])`, "fail: ValueError: bad 1"},
+		{`pool.call("missing")`, `missing: no spell "missing"`},
+		{`pool.call("square", square)`, "square: argument 1: cannot send FUNCTION"},
+		{`workers.pool("` + bad + `", 1)`, bad + ": worker exited before it was ready"},
+		{"pool.close()\n" + `pool.call("square", 2)`, "square: the pool for " + jobs + " is closed"},
+	}
+	run("spell square(n):\n    return n")
+	for _, tt := range errors {
+		err, ok := run(tt.input).(*object.CustomError)
+		if !ok || err.Name != "WorkerError" || err.Message != tt.message {
+			t.Errorf("%s: expected WorkerError %q, got %+v", tt.input, tt.message, err)
+		}
+	}
+}
+
+func TestShellPipeline(t *testing.T) {
+	dir := t.TempDir()
+	words := writeModule(t, dir, "words.txt", "pear\napple\nfig\napple\n")
+	out := filepath.Join(dir, "out.txt")
+	run := newStdlibRunner(t, object.NewEnvironment())
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sh("cat", "` + words + `") | "sort -u"`, `<pipeline: cat ` + words + ` | sort -u>`},
+		{`(sh("cat", "` + words + `") | sh("sort") | "uniq").lines()`, `["apple", "fig", "pear"]`},
+		{`sh("sort -r").read_from("` + words + `").pipe("head -n 1").output()`, `"pear\n"`},
+		{`sh("tr a-z A-Z").input("shout").output()`, `"SHOUT"`},
+		{`sh("printf '%s|' a 'b c' \"d\\\"e\"").output()`, `"a|b c|d\"e|"`},
+		{`(sh("yes") | "head -n 2").lines()`, `["y", "y"]`},
+		{`sh("true").lines()`, "[]"},
+		{`sh("sort") < "` + words + `" > "` + out + `"` + "\n" + `sh("echo", "end") >> "` + out + `"` + "\n" + `sh("cat", "` + out + `").lines()`,
+			`["apple", "apple", "fig", "pear", "end"]`},
+		{"if True:\n    (sh(\"echo\", \"grouped\") | \"cat\").output()", `"grouped\n"`},
+	}
+	for _, tt := range tests {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{`(sh("cat", "` + filepath.Join(dir, "missing") + `") | "sort").output()`,
+			"cat " + filepath.Join(dir, "missing") + ": exit status 1: cat: " + filepath.Join(dir, "missing") + ": No such file or directory"},
+		{`(sh("echo hi") | "false").output()`, "false: exit status 1"},
+		{`sh("no-such-command-here").run()`, `no-such-command-here: exec: "no-such-command-here": executable file not found in $PATH`},
+	}
+	for _, tt := range errors {
+		err, ok := run(tt.input).(*object.CustomError)
+		if !ok || err.Name != "CommandError" || err.Message != tt.message {
+			t.Errorf("%s: expected CommandError %q, got %+v", tt.input, tt.message, err)
+		}
+	}
+	if err, ok := run(`sh("echo 'open")`).(*object.Error); !ok || err.Message != `sh: unterminated ' quote in "echo 'open"` {
+		t.Errorf("expected an error for an unterminated quote, got %+v", err)
+	}
+}
+
+func TestTUI(t *testing.T) {
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	run := newStdlibRunner(t, env)
+	env.Set("screen", screenModule(newScreen(strings.NewReader("j\x1b[B\x1b[5~\r\x01\x7f"), &out, 12, 3)))
+
+	keys := run(`[screen.read_key(), screen.read_key(), screen.read_key(), screen.read_key(), screen.read_key(), screen.read_key(), screen.read_key(0.01)]`)
+	if expected := `["j", "down", "pgup", "enter", "ctrl+a", "backspace", None]`; keys.Inspect() != expected {
+		t.Errorf("expected keys %s, got %s", expected, keys.Inspect())
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"view = ListView([\"a\", \"b\", \"c\"], 2)\nview.handle(\"down\")\nview.handle(\"down\")\n[view.current(), view.top, view.handle(\"x\")]", `["c", 1, false]`},
+		{"table = TableView([\"name\", \"n\"], [[\"fig\", 10], [\"apple\", 2]])\n[table.line(0), table.line(1)]", `["fig    10  ", "apple  2   "]`},
+		{"field = TextInput(\"> \", \"ab\")\nfield.handle(\"left\")\nfield.handle(\"x\")\nfield.handle(\"delete\")\n[field.value, field.cursor]", `["ax", 2]`},
+	}
+	for _, tt := range tests {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	out.Reset()
+	run("view.draw(screen, 0, 0, 12)\nscreen.write(10, 2, \"xyz\", \"bold red\")\nscreen.render()")
+	if expected := "\x1b[1;1Hb           \x1b[2;1H\x1b[0m\x1b[7mc           \x1b[0m\x1b[3;1H          \x1b[0m\x1b[1;31mxy\x1b[0m"; out.String() != expected {
+		t.Errorf("expected render %q, got %q", expected, out.String())
+	}
+	out.Reset()
+	run("screen.write(0, 0, \"b\")\nscreen.render()")
+	if out.Len() != 0 {
+		t.Errorf("expected an unchanged screen to render nothing, got %q", out.String())
+	}
+	if err, ok := run(`screen.write(0, 0, "a", "sparkly")`).(*object.Error); !ok || err.Message != `write: unknown style "sparkly"` {
+		t.Errorf("expected an error for an unknown style, got %+v", err)
+	}
+}
+func TestOpenHandles(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "leak.sock")
+	env := object.NewEnvironment()
+	ctx := NewEvalContext("main.crl")
+	env.SetContext(ctx)
+	run := func(input string) object.Object {
+		return Eval(parser.New(lexer.New(input, "main.crl")).ParseProgram(), env)
+	}
+
+	if result := run("spell start():\n    return rpc.listen(\"" + socket + "\", {})\nserver = start()"); isError(result) {
+		t.Fatalf("listen failed: %s", result.Inspect())
+	}
+	handles := ctx.OpenHandles()
+	if len(handles) != 1 || handles[0].Kind != "listener" || handles[0].Name != socket {
+		t.Fatalf("expected the listener to be open, got %v", handles)
+	}
+	if stack := handles[0].Stack; len(stack) == 0 || stack[0].Function != "start" {
+		t.Errorf("expected the stack that opened it, got %+v", stack)
+	}
+	if result := run("runtime.open_handles()[0].kind"); result.Inspect() != `"listener"` {
+		t.Errorf("expected the handle from runtime.open_handles, got %s", result.Inspect())
+	}
+
+	var report strings.Builder
+	WriteLeakReport(&report, handles)
+	if !strings.HasPrefix(report.String(), "warning: listener "+socket+" was never closed") {
+		t.Errorf("unexpected leak report %q", report.String())
+	}
+
+	run("server.close()\nserver.close()")
+	if handles := ctx.OpenHandles(); len(handles) != 0 {
+		t.Errorf("expected no open handles after close, got %v", handles)
+	}
+}
+
+func TestCrashReport(t *testing.T) {
+	tests := []struct {
+		body  string
+		error string
+	}{
+		{"return total + missing", "identifier not found: missing"},
+		{"return total / 0", "division by zero"},
+	}
+	for _, tt := range tests {
+		input := "names = [\"a\", \"b\"]\nspell scale(n, label):\n    total = n * 2\n    " + tt.body + "\nspell run(x):\n    y = x + 1\n    return scale(y, \"" + strings.Repeat("x", 300) + "\") + 0\nrun(3)"
+		env := object.NewEnvironment()
+		ctx := NewEvalContext("main.crl")
+		env.SetContext(ctx)
+		ctx.RecordCrashes()
+		result := SafeEval(parser.New(lexer.New(input, "main.crl")).ParseProgram(), env)
+		if !isError(result) {
+			t.Fatalf("%s: expected an error, got %s", tt.body, result.Inspect())
+		}
+
+		var out bytes.Buffer
+		if err := WriteCrashReport(&out, result, env, "v1.2.3"); err != nil {
+			t.Fatal(err)
+		}
+		report := out.String()
+		for _, want := range []string{
+			"version: carrion v1.2.3",
+			tt.error,
+			"  #0 scale, called at main.crl:7:17\n      label = \"" + strings.Repeat("x", 199) + "...\n      n = 4\n      total = 8\n",
+			"  #1 run, called at main.crl:8:4\n      x = 3\n      y = 4\n",
+			"  #2 <main>\n      names = [\"a\", \"b\"]\n",
+		} {
+			if !strings.Contains(report, want) {
+				t.Errorf("%s: expected the report to contain %q, got:\n%s", tt.body, want, report)
+			}
+		}
+	}
+}
+
+func TestImportNative(t *testing.T) {
+	ext.Register("native/strs", map[string]any{
+		"upper": strings.ToUpper,
+		"split": func(s, sep string) ([]string, error) {
+			if sep == "" {
+				return nil, fmt.Errorf("empty separator")
+			}
+			return strings.Split(s, sep), nil
+		},
+	})
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"import \"native/strs\"\nstrs.upper(\"caw\")", `"CAW"`},
+		{"import \"native/strs\" as s\ns.split(\"a,b\", \",\")", `["a", "b"]`},
+		{"import \"native/strs\" expose upper\nupper(\"x\")", `"X"`},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+	if err, ok := testEval("import \"native/strs\" as s\ns.split(\"a\", \"\")").(*object.Error); !ok || err.Message != "native/strs.split: empty separator" {
+		t.Errorf("expected the Go error to be raised, got %+v", err)
+	}
+}
+
+func TestArena(t *testing.T) {
+	input := "spell churn(n):\n    total = 0\n    for i in range(n):\n        total += i * 3 + 1\n    return total\n"
+	testIntegerObject(t, testEval(input+"arena(churn, 100)"), 14950)
+	testIntegerObject(t, testEval(input+"spell both():\n    return churn(10) + arena(churn, 10)\narena(both)"), 290)
+	if err, ok := testEval("arena()").(*object.Error); !ok || err.Message != "arena requires a spell to call" {
+		t.Errorf("expected an error for arena(), got %+v", err)
+	}
+
+	program := parser.New(lexer.New(input+"churn(1000)", "main.crl")).ParseProgram()
+	allocs := func(arena bool) float64 {
+		env := object.NewEnvironment()
+		ctx := NewEvalContext("main.crl")
+		env.SetContext(ctx)
+		if arena {
+			ctx.arena = &Arena{}
+			arenasInUse.Add(1)
+			defer arenasInUse.Add(-1)
+		}
+		return testing.AllocsPerRun(5, func() { Eval(program, env) })
+	}
+	heap, arena := allocs(false), allocs(true)
+	if arena > heap-2000 {
+		t.Errorf("expected the arena to save an allocation per number, got %.0f allocations against %.0f", arena, heap)
+	}
+	if arenasInUse.Load() != 0 {
+		t.Errorf("arena scopes left %d arenas in use", arenasInUse.Load())
+	}
+}
+
+func TestBytes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.bin")
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`b"caw\x00\xff"`, `b"caw\x00\xff"`},
+		{`b"ab" + b"\n"`, `b"ab\n"`},
+		{`b"\x7f\x80"[1]`, "128"},
+		{`b"abc"[5]`, "None"},
+		{`b"abcdef"[1:-2]`, `b"bcd"`},
+		{`len(b"\x00\x01")`, "2"},
+		{`list(b"AB")`, "[65, 66]"},
+		{"total = 0\nfor c in b\"\\x01\\x02\":\n    total += c\ntotal", "3"},
+		{`b"x" == b"x"`, "true"},
+		{`bytes("é")`, `b"\xc3\xa9"`},
+		{`bytes("é", "Latin_1")`, `b"\xe9"`},
+		{`bytes([104, 105])`, `b"hi"`},
+		{`bytes(2)`, `b"\x00\x00"`},
+		{`decode(b"\xc3\xa9")`, `"é"`},
+		{`decode(b"\xe9", "iso-8859-1")`, `"é"`},
+		{`decode(b"\x01\xfe", "hex")`, `"01fe"`},
+		{`decode(bytes("aGk=", "base64"))`, `"hi"`},
+		{`fileWriteBytes("` + path + `", b"\x00\xff")` + "\nfileReadBytes(\"" + path + `")`, `b"\x00\xff"`},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := map[string]string{
+		`decode(b"\xff")`:      "decode utf-8: byte 0xff at index 0 is not valid UTF-8",
+		`bytes("é", "ascii")`:  `bytes ascii: 'é' at index 0 is not ASCII`,
+		`bytes([256])`:         "bytes: element 0 must be an INTEGER from 0 to 255, got 256",
+		`bytes("x", "ebcdic")`: `bytes: unknown encoding "ebcdic"; use utf-8, ascii, latin-1, hex or base64`,
+		`b"a" + "b"`:           "type mismatch: BYTES + STRING",
+		`b"abc"["x"]`:          "bytes index must be INTEGER or RANGE, got STRING",
+	}
+	for input, expected := range errors {
+		if err, ok := testEval(input).(*object.Error); !ok || err.Message != expected {
+			t.Errorf("%q: expected error %q, got %+v", input, expected, err)
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 10", "-9223372036854775817"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"2 ** 100", "1267650600228229401496703205376"},
+		{"3 ** 39", "4052555153018976267"},
+		{"1 << 64", "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(2 ** 64) ~/ (2 ** 60)", "16"},
+		{"(2 ** 64 + 5) % (2 ** 32)", "5"},
+		{"(2 ** 70) >> 68", "4"},
+		{"2 ** 64 > 2 ** 63", "true"},
+		{"2 ** 64 == 2 ** 64", "true"},
+		{"n = 9223372036854775807\nn += 1\nn", "9223372036854775808"},
+		{"n = 1\nfor i in range(1, 26):\n    n *= i\nn", "15511210043330985984000000"},
+		{`int("123456789012345678901234567890")`, "123456789012345678901234567890"},
+		{"{2 ** 80: 1}[2 ** 80]", "1"},
+		{"9223372036854775808", "9223372036854775808"},
+		{"[0x8000000000000000, 0b1" + strings.Repeat("0", 64) + ", 1_000_000_000_000_000_000_000]", "[9223372036854775808, 18446744073709551616, 1000000000000000000000]"},
+		{"x = -9223372036854775808\n[x, type(x), x - 1]", `[-9223372036854775808, "INTEGER", -9223372036854775809]`},
+		{"[abs(-9223372036854775808), abs(-(2 ** 70)), abs(2 ** 70)]", "[9223372036854775808, 1180591620717411303424, 1180591620717411303424]"},
+		{"format_number(2 ** 80)", `"1,208,925,819,614,629,174,706,176"`},
+		{`format_number(-(2 ** 64), "de", 2)`, `"-18.446.744.073.709.551.616,00"`},
+		{"[1 ** 100000000000, (-1) ** 100000000001, (2 ** 64) ** 0]", "[1, -1, 1]"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+	if result := testEval("(2 ** 64) - (2 ** 64 - 1)"); result.Type() != object.INTEGER_OBJ {
+		t.Errorf("expected a result that fits int64 to be an INTEGER, got %s", result.Type())
+	}
+	if err, ok := testEval("(2 ** 64) / 0").(*object.CustomError); !ok || err.Name != "DivisionByZeroError" {
+		t.Errorf("expected division by zero, got %+v", err)
+	}
+
+	errors := map[string]string{
+		"2 ** 100000000000":      "exponent 100000000000 is too large",
+		"(2 ** 64) ** 100000000": "exponent 100000000 is too large",
+		"1 << -1":                "negative shift count -1",
+		"1 >> -1":                "negative shift count -1",
+		"(2 ** 64) << -3":        "negative shift count -3",
+	}
+	for input, expected := range errors {
+		errObj, ok := testEval(input).(*object.Error)
+		if !ok || errObj.Message != expected {
+			t.Errorf("expected error %q for %s, got=%+v", expected, input, testEval(input))
+		}
+	}
+}
+
+func TestNumericLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[0xFF, 0Xff, 0o755, 0b1010, 0x_dead_beef]", "[255, 255, 493, 10, 3735928559]"},
+		{"[1_000_000, 1_000.5, 1e3, 2.5e-3, 1E+2, 6_0e-1]", "[1000000, 1000.5, 1000.0, 0.0025, 100.0, 6.0]"},
+		{"0xF0 | 0b1111", "255"},
+		{"e = 2\n[3e2, 3 * e]", "[300.0, 6]"},
+		{"[1, 2, 3][1:]", "[2, 3]"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+	for input, message := range map[string]string{
+		"1__000": `could not parse "1__000" as integer`,
+		"1_":     `could not parse "1_" as integer`,
+		"0b102":  `could not parse "0b102" as integer`,
+		"1_e5":   `could not parse "1_e5" as float`,
+	} {
+		p := parser.New(lexer.New(input))
+		p.ParseProgram()
+		if errs := p.Errors(); len(errs) == 0 || errs[0] != message {
+			t.Errorf("%s: expected the error %q, got %q", input, message, errs)
+		}
+	}
+}
+
+func TestDivision(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"7 / 2", "3.5"},
+		{"6 / 3", "2.0"},
+		{"7 ~/ 2", "3"},
+		{"-7 ~/ 2", "-4"},
+		{"7 ~/ -2", "-4"},
+		{"7 % 3", "1"},
+		{"-7 % 3", "2"},
+		{"7 % -3", "-2"},
+		{"-7 % -3", "-1"},
+		{"7.5 ~/ 2", "3.0"},
+		{"-7.5 % 2", "0.5"},
+		{"1 + 0.5", "1.5"},
+		{"2 * 1.5 == 3", "true"},
+		{"1 < 1.5", "true"},
+		{"n = 3\nn /= 2\nn", "1.5"},
+		{"n = 1.5\nn += 1\nn", "2.5"},
+		{"(-9223372036854775807 - 1) ~/ -1", "9223372036854775808"},
+		{"-(2 ** 64) ~/ 3", "-6148914691236517206"},
+		{"-(2 ** 64) % 3", "2"},
+		{"(2 ** 64) / (2 ** 60)", "16.0"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	for _, input := range []string{"1 / 0", "1 ~/ 0", "1 % 0", "1.5 / 0", "n = 1\nn /= 0"} {
+		if err, ok := testEval(input).(*object.CustomError); !ok || err.Name != "DivisionByZeroError" {
+			t.Errorf("%q: expected a DivisionByZeroError, got %+v", input, testEval(input))
+		}
+	}
+	input := "attempt:\n    x = 1 ~/ 0\nensnare (\"DivisionByZeroError\"):\n    x = \"caught\"\nx"
+	if result := testEval(input); result.Inspect() != `"caught"` {
+		t.Errorf("expected the error to be caught, got %s", result.Inspect())
+	}
+}
+
+func TestMixedArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2.5", "3.5"},
+		{"2.5 - 1", "1.5"},
+		{"3 * 0.5", "1.5"},
+		{"2 ** 0.5 > 1.41", "true"},
+		{"0.5 ** 2", "0.25"},
+		{"7 % 2.5", "2.0"},
+		{"1 < 1.5", "true"},
+		{"2.0 <= 2", "true"},
+		{"3 > 2.5", "true"},
+		{"2 >= 2.5", "false"},
+		{"1 == 1.0", "true"},
+		{"1 != 1.0", "false"},
+		{"(2 ** 64) * 0.5", "9.223372036854776e+18"},
+		{"2 ** 64 > 1.5", "true"},
+		{"n = 1\nn += 0.5\nn", "1.5"},
+		{"n = 2.0\nn *= 3\nn", "6.0"},
+		{"max(1, 2.5, 2)", "2.5"},
+		{"max(3, 2.5)", "3"},
+		{"max(2.0, 1)", "2.0"},
+		{"max(1, 2 ** 64)", "18446744073709551616"},
+		{"match 1.0:\n    case 1:\n        x = \"one\"\n    _:\n        x = \"other\"\nx", `"one"`},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"crow" == "crow"`, true},
+		{`"crow" != "raven"`, true},
+		{`"apple" < "banana"`, true},
+		{`"b" > "abc"`, true},
+		{`"ab" <= "ab"`, true},
+		{`"Z" >= "a"`, false},
+		{"1.5 < 2.5", true},
+		{"2.5 >= 2.5", true},
+		{"1.5 == 1.5", true},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] == [1, 2, 3]", false},
+		{"[1, 2] != [2, 1]", true},
+		{"[1, 2.0] == [1.0, 2]", true},
+		{"(1, \"a\") == (1, \"a\")", true},
+		{"(1, 2) != (1, 3)", true},
+		{`{"a": [1, 2], "b": 2} == {"b": 2, "a": [1, 2]}`, true},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{`{"a": 1} != {"a": 2}`, true},
+		{"match [1, 2]:\n    case [1, 2]:\n        x = True\n    _:\n        x = False\nx", true},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStringSlicing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"crow"[0]`, `"c"`},
+		{`"crow"[3]`, `"w"`},
+		{`"crow"[4]`, "None"},
+		{`"carrion"[1:4]`, `"arr"`},
+		{`"carrion"[-3:]`, `"ion"`},
+		{`"carrion"[:3]`, `"car"`},
+		{`"carrion"[5:2]`, `""`},
+		{`split("  GET /index.html\t200 ")`, `["GET", "/index.html", "200"]`},
+		{`split("a,b,,c", ",")`, `["a", "b", "", "c"]`},
+		{`split("", ",")`, `[""]`},
+		{`{"key=1"[0:3]: 1}["key"]`, "1"},
+		{`s = "héllo wörld ✓"` + "\n" + `[len(s), s[1], s[7], s[12], s[13], s[0:5], s[-7:-2]]`, `[13, "é", "ö", "✓", None, "héllo", "wörld"]`},
+		{`[list("añb"), len("名前")]`, `[["a", "ñ", "b"], 2]`},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+	if err, ok := testEval(`"crow"["a"]`).(*object.Error); !ok || err.Message != "string index must be INTEGER or RANGE, got STRING" {
+		t.Errorf("expected an index type error, got %+v", err)
+	}
+}
+
+func TestUnicodeSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"café = 3\ncafé + 1", "4"},
+		{"名前 = \"カラス\"\n[名前, len(名前)]", `["カラス", 3]`},
+		{"spell grüße(wer):\n    return f\"hallo {wer}\"\ngrüße(\"ö\")", `"hallo ö"`},
+		{"_ñ1 = 1\n_ñ1", "1"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	// Columns count characters, so positions after non-ASCII text match
+	// what an editor shows.
+	l := lexer.New(`"ü✓" + ¤`)
+	var tok token.Token
+	for tok = l.NextToken(); tok.Type != token.ILLEGAL && tok.Type != token.EOF; tok = l.NextToken() {
+	}
+	if tok.Type != token.ILLEGAL || tok.Literal != "¤" || tok.Position.Column != 8 {
+		t.Errorf("expected an ILLEGAL ¤ in column 8, got %+v", tok)
+	}
+}
+
+func TestStringMethods(t *testing.T) {
+	run := newStdlibRunner(t, object.NewEnvironment())
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Crow".upper()`, `"CROW"`},
+		{`"Crow".lower()`, `"crow"`},
+		{`"  crow\n".strip()`, `"crow"`},
+		{`"--crow-".strip("-")`, `"crow"`},
+		{`"a b  c".split()`, `["a", "b", "c"]`},
+		{`"a,b".split(",")`, `["a", "b"]`},
+		{`", ".join(["a", "b"])`, `"a, b"`},
+		{`"aaa".replace("a", "b")`, `"bbb"`},
+		{`"aaa".replace("a", "b", 1)`, `"baa"`},
+		{`"crow".find("o")`, "2"},
+		{`"crow".find("x")`, "-1"},
+		{`"banana".count("an")`, "2"},
+		{`"crow".startswith("cr")`, "true"},
+		{`"crow".endswith("cr")`, "false"},
+		{`"{} has {} wings".format(["crow", 2])`, `"crow has 2 wings"`},
+		{`"{1}{0}".format(["a", "b"])`, `"ba"`},
+		{`"{name} {{x}}".format({"name": "odin"})`, `"odin {x}"`},
+		{`"hé".encode()`, `b"h\xc3\xa9"`},
+		{`"café crème".find("crème")`, "5"},
+		{`s = "café"` + "\n" + `[len(s), len(s.bytes()), s.bytes()[3:5]]`, `[4, 5, b"\xc3\xa9"]`},
+		{"s = \"crow\"\ns.upper()\ns", `"crow"`},
+	}
+	for _, tt := range tests {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{`"crow".reverse`, "string has no method 'reverse'"},
+		{`", ".join(["a", 1])`, "strJoin: item 1 is INTEGER, not a STRING"},
+		{`"{} {}".format(["a"])`, "strFormat: no value for {}, 1 given"},
+		{`"{x}".format({})`, "strFormat: no value for {x}"},
+	}
+	for _, tt := range errors {
+		if err, ok := run(tt.input).(*object.Error); !ok || err.Message != tt.message {
+			t.Errorf("%q: expected %q, got %+v", tt.input, tt.message, run(tt.input))
+		}
+	}
+}
+
+func TestPrimitiveMethods(t *testing.T) {
+	run := newStdlibRunner(t, object.NewEnvironment())
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 2, "a": 1}.keys()`, `["a", "b"]`},
+		{`{"b": 2, "a": 1}.values()`, "[1, 2]"},
+		{`{"a": 1}.items()`, `[("a", 1)]`},
+		{`{"a": 1}.get("a")`, "1"},
+		{`{"a": 1}.get("b") == None`, "true"},
+		{`{"a": 1}.get("b", 0)`, "0"},
+		{`{"a": 1}.contains("a")`, "true"},
+		{`{"a": 1, "b": 2}.len()`, "2"},
+		{"h = {\"a\": 1, \"b\": 2}\nh.remove(\"a\")\nh", `{"b": 2}`},
+		{"(-3).abs()", "3"},
+		{"(4).is_even()", "true"},
+		{"n = 7\nn.to_string()", `"7"`},
+		{"(2 ** 64).is_odd()", "false"},
+		{"(-2.5).abs()", "2.5"},
+		{"3.7.to_int()", "3"},
+		{"[1, 2, 3].len()", "3"},
+		{"a = [1]\na.append(2)\na", "[1, 2]"},
+		{"extend Integer:\n    spell double():\n        return self.value * 2\n(21).double()", "42"},
+		{"extend Hash:\n    spell first():\n        return self.keys()[0]\n{\"z\": 1, \"y\": 2}.first()", `"y"`},
+	}
+	for _, tt := range tests {
+		if result := run(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"(5).nope", "integer has no method 'nope'"},
+		{"{}.nope", "hash has no method 'nope'"},
+		{"(2 ** 64).nope", "integer has no method 'nope'"},
+		{"True.nope", "type error: BOOLEAN is not an instance"},
+	}
+	for _, tt := range errors {
+		if err, ok := run(tt.input).(*object.Error); !ok || err.Message != tt.message {
+			t.Errorf("%q: expected %q, got %+v", tt.input, tt.message, run(tt.input))
+		}
+	}
+	if err, ok := run(`{}.remove("a")`).(*object.CustomError); !ok || err.Name != "KeyError" {
+		t.Errorf("expected a KeyError, got %+v", run(`{}.remove("a")`))
+	}
+}
+
+func TestArrayVectorOps(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3].sum()", "6"},
+		{"[1, 2.5].sum()", "3.5"},
+		{"[].sum()", "0"},
+		{"[9223372036854775807, 1].sum()", "9223372036854775808"},
+		{"[2 ** 64, 1].sum()", "18446744073709551617"},
+		{"[1, 2, 3].add_scalar(10)", "[11, 12, 13]"},
+		{"[1, 2, 3].sub_scalar(1)", "[0, 1, 2]"},
+		{"[1, 2, 3].mul_scalar(1.5)", "[1.5, 3.0, 4.5]"},
+		{"[1, 2].div_scalar(4)", "[0.25, 0.5]"},
+		{"[1, 2, 3].add([10, 20, 30])", "[11, 22, 33]"},
+		{"[5, 5].sub([1, 2.5])", "[4, 2.5]"},
+		{"[5, 5].sub([1.0, 2.5])", "[4.0, 2.5]"},
+		{"[2, 3].mul([4, 5])", "[8, 15]"},
+		{"[1, 3].div([2, 4])", "[0.5, 0.75]"},
+		{"[9223372036854775807, 1].add_scalar(1)", "[9223372036854775808, 2]"},
+		{"a = range(5)\nb = a.mul_scalar(2)\na", "[0, 1, 2, 3, 4]"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"[1, 2].add([1])", "add: arrays have different lengths, 2 and 1"},
+		{"[1, 2].add(1)", "add expects an ARRAY, got INTEGER"},
+		{`[1, 2].add_scalar("a")`, "add_scalar expects a number, got STRING"},
+		{"[1].nope", "array has no method 'nope'"},
+		{`[1, "a"].sum()`, "sum: element is STRING, not a number"},
+	}
+	for _, tt := range errors {
+		if err, ok := testEval(tt.input).(*object.Error); !ok || err.Message != tt.message {
+			t.Errorf("%q: expected %q, got %+v", tt.input, tt.message, testEval(tt.input))
+		}
+	}
+	if err, ok := testEval("[1, 2].div([1, 0])").(*object.CustomError); !ok || err.Name != "DivisionByZeroError" {
+		t.Errorf("expected a DivisionByZeroError, got %+v", err)
+	}
+}
+
+func TestArrayMethods(t *testing.T) {
+	spells := "spell double(x):\n    return x * 2\nspell odd(x):\n    return x % 2 == 1\nspell add(a, b):\n    return a + b\nspell size(w):\n    return len(w)\n"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3].map(double)", "[2, 4, 6]"},
+		{"[1, 2, 3].filter(odd)", "[1, 3]"},
+		{"[1, 2, 3].reduce(add)", "6"},
+		{"[].reduce(add, 10)", "10"},
+		{"[3, 1, 2].sorted()", "[1, 2, 3]"},
+		{"[3, 1, 2].sorted(None, True)", "[3, 2, 1]"},
+		{`["ccc", "b", "aa", "d"].sorted(size)`, `["b", "d", "aa", "ccc"]`},
+		{`["ccc", "b", "aa", "d"].sorted(size, True)`, `["ccc", "aa", "b", "d"]`},
+		{"a = [3, 1, 2]\nb = a.sorted()\na", "[3, 1, 2]"},
+		{"a = [3, 1, 2]\na.sort()\na", "[1, 2, 3]"},
+		{"a = [1, 2, 3]\na.reverse()\na", "[3, 2, 1]"},
+		{"a = [1, 2, 3]\nb = a.reversed()\n[a, b]", "[[1, 2, 3], [3, 2, 1]]"},
+		{`["a", "b", "a"].index("a")`, "0"},
+		{"[1, 2].index(5)", "-1"},
+		{"[1, 2, 1.0].count(1)", "2"},
+		{"a = [1]\na.append(2)\na", "[1, 2]"},
+		{"a = [1, 2]\n[a.pop(), a]", "[2, [1]]"},
+	}
+	for _, tt := range tests {
+		if result := testEval(spells + tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"[].reduce(add)", "reduce of an empty array with no initial value"},
+		{`[1, "a"].sort()`, "type mismatch: STRING < INTEGER"},
+		{"[1].map()", "map requires 1 argument: spell"},
+	}
+	for _, tt := range errors {
+		if err, ok := testEval(spells + tt.input).(*object.Error); !ok || err.Message != tt.message {
+			t.Errorf("%q: expected %q, got %+v", tt.input, tt.message, testEval(spells+tt.input))
+		}
+	}
+
+	raised := []struct {
+		input string
+		name  string
+	}{
+		{"[].pop()", "IndexError"},
+		{"freeze([1]).append(2)", "TypeError"},
+	}
+	for _, tt := range raised {
+		if err, ok := testEval(tt.input).(*object.CustomError); !ok || err.Name != tt.name {
+			t.Errorf("%q: expected %s, got %+v", tt.input, tt.name, testEval(tt.input))
+		}
+	}
+}
+
+func TestTypedArrays(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"intarray([1, 2, 3])", "intarray([1, 2, 3])"},
+		{"intarray(3)", "intarray([0, 0, 0])"},
+		{"floatarray([1, 2.5])", "floatarray([1.0, 2.5])"},
+		{"floatarray(intarray([1, 2]))", "floatarray([1.0, 2.0])"},
+		{"list(intarray([1, 2]))", "[1, 2]"},
+		{"list(floatarray([0.5]))", "[0.5]"},
+		{"len(floatarray(4))", "4"},
+		{"intarray([4, 5, 6])[1]", "5"},
+		{"intarray([4, 5, 6])[3]", "None"},
+		{"floatarray([4, 5, 6])[1:]", "floatarray([5.0, 6.0])"},
+		{"intarray([1, 2, 3]).sum()", "6"},
+		{"intarray([9223372036854775807, 1]).sum()", "9223372036854775808"},
+		{"floatarray([0.5, 0.25]).sum()", "0.75"},
+		{"intarray([1, 2]).add(intarray([10, 20]))", "intarray([11, 22])"},
+		{"intarray([1, 2]).add([10, 20])", "intarray([11, 22])"},
+		{"intarray([1, 2]).mul_scalar(1.5)", "floatarray([1.5, 3.0])"},
+		{"intarray([1, 2]).div_scalar(2)", "floatarray([0.5, 1.0])"},
+		{"floatarray([1, 2]).sub(intarray([1, 1]))", "floatarray([0.0, 1.0])"},
+		{"[1, 2].add(intarray([1, 1]))", "[2, 3]"},
+		{"intarray([1, 2]) == floatarray([1, 2])", "true"},
+		{"intarray([1, 2]) == intarray([1, 3])", "false"},
+		{"total = 0\nfor x in intarray([1, 2, 3]):\n    total += x\ntotal", "6"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"intarray([1, 2.5])", "intarray: element 1 must be an INTEGER that fits in 64 bits, got FLOAT"},
+		{"intarray([9223372036854775807]).add_scalar(1)", "add_scalar: a result does not fit in an INT_ARRAY"},
+		{"intarray([1]).add(intarray([1, 2]))", "add: arrays have different lengths, 1 and 2"},
+	}
+	for _, tt := range errors {
+		if err, ok := testEval(tt.input).(*object.Error); !ok || err.Message != tt.message {
+			t.Errorf("%q: expected %q, got %+v", tt.input, tt.message, testEval(tt.input))
+		}
+	}
+	if err, ok := testEval("floatarray([1]).div_scalar(0)").(*object.CustomError); !ok || err.Name != "DivisionByZeroError" {
+		t.Errorf("expected a DivisionByZeroError, got %+v", err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"context.background().remaining()", "None"},
+		{"c = context.with_cancel()\nc.cancel()\nc.err()", `"context canceled"`},
+		{"c = context.with_cancel()\nd = context.with_timeout(60, c)\nc.cancel()\nd.done()", "true"},
+		{"c = context.with_timeout(60)\nc.remaining() > 59", "true"},
+		{"spell f():\n    return context.current().remaining() > 59\ncontext.run(context.with_timeout(60), f)", "true"},
+		{"context.current().remaining()", "None"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input string
+		name  string
+	}{
+		{"osSleep(10, context.with_timeout(0.01))", "TimeoutError"},
+		{"spell nap():\n    osSleep(10)\ncontext.run(context.with_timeout(0.01), nap)", "TimeoutError"},
+		{"c = context.with_cancel()\nc.cancel()\nosSleep(10, c)", "CancelledError"},
+		{`osRunCommand("sleep", ["10"], False, context.with_timeout(0.01))`, "TimeoutError"},
+		{"c = context.with_cancel()\nc.cancel()\nfileRead(\"context_test.crl\", c)", "CancelledError"},
+	}
+	for _, tt := range errors {
+		start := time.Now()
+		err, ok := testEval(tt.input).(*object.CustomError)
+		if !ok || err.Name != tt.name {
+			t.Errorf("%q: expected a %s, got %+v", tt.input, tt.name, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%q: took %s to give up", tt.input, elapsed)
+		}
+	}
+	if err, ok := testEval("osSleep(0, 5)").(*object.Error); !ok || err.Message != "osSleep: argument 2 must be a CONTEXT, got INTEGER" {
+		t.Errorf("expected a CONTEXT type error, got %+v", err)
+	}
+}
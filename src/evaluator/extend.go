@@ -0,0 +1,45 @@
+package evaluator
+
+import (
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// evalExtendStatement adds the spells of an `extend Name:` block to the
+// grimoire Name, replacing any it already has. Grimoires inheriting from it
+// see them too, and those added to the grimoires of plain values, such as
+// Array and String, are methods of every such value; see primitiveMember.
+func evalExtendStatement(node *ast.ExtendStatement, env *object.Environment) object.Object {
+	obj, ok := env.Get(node.Name.Value)
+	if !ok {
+		return identifierNotFound(node.Name.Value, env)
+	}
+	grimoire, ok := obj.(*object.Grimoire)
+	if !ok {
+		return newError("cannot extend %s: not a grimoire", node.Name.Value)
+	}
+	for _, method := range node.Methods {
+		fn, errObj := newMethod(grimoire.Name, method, env)
+		if errObj != nil {
+			return errObj
+		}
+		if grimoire.Extensions == nil {
+			grimoire.Extensions = make(map[string]*object.Function)
+		}
+		fn.Grimoire = grimoire
+		grimoire.Methods[method.Name.Value] = fn
+		grimoire.Extensions[method.Name.Value] = fn
+	}
+	return NONE
+}
+
+// inheritedMethod looks up a spell along the grimoires grimoire inherits
+// from, for those added by `extend` after grimoire copied its parent's.
+func inheritedMethod(grimoire *object.Grimoire, name string) (*object.Function, bool) {
+	for parent := grimoire.Inherits; parent != nil; parent = parent.Inherits {
+		if method, ok := parent.Methods[name]; ok {
+			return method, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,238 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// formatSpec is a parsed format spec, the part after the colon in an
+// f-string field such as {price:>10.2f}. Its layout is that of Python:
+//
+//	[[fill]align][sign][0][width][,|_][.precision][type]
+type formatSpec struct {
+	fill      rune
+	align     byte // '<', '>', '^' or '=', or 0 for the default
+	sign      byte // '+', '-' or ' '
+	width     int
+	grouping  byte // ',' or '_', or 0 for none
+	precision int  // -1 if not given
+	verb      byte // the type, or 0 if not given
+}
+
+func parseFormatSpec(spec string) (formatSpec, error) {
+	f := formatSpec{fill: ' ', precision: -1}
+	rest := spec
+	if r, size := utf8.DecodeRuneInString(rest); size > 0 && len(rest) > size && strings.IndexByte("<>^=", rest[size]) >= 0 {
+		f.fill, f.align = r, rest[size]
+		rest = rest[size+1:]
+	} else if rest != "" && strings.IndexByte("<>^=", rest[0]) >= 0 {
+		f.align = rest[0]
+		rest = rest[1:]
+	}
+	if rest != "" && strings.IndexByte("+- ", rest[0]) >= 0 {
+		f.sign = rest[0]
+		rest = rest[1:]
+	}
+	if rest != "" && rest[0] == '0' {
+		if f.align == 0 {
+			f.fill, f.align = '0', '='
+		}
+		rest = rest[1:]
+	}
+	digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+	if digits > 0 {
+		f.width, _ = strconv.Atoi(rest[:digits])
+		rest = rest[digits:]
+	}
+	if rest != "" && (rest[0] == ',' || rest[0] == '_') {
+		f.grouping = rest[0]
+		rest = rest[1:]
+	}
+	if rest != "" && rest[0] == '.' {
+		rest = rest[1:]
+		digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+		if digits == 0 {
+			return f, fmt.Errorf("invalid format spec %q: missing precision after '.'", spec)
+		}
+		f.precision, _ = strconv.Atoi(rest[:digits])
+		rest = rest[digits:]
+	}
+	if len(rest) == 1 && strings.IndexByte("bcdeEfFgGosxX%", rest[0]) >= 0 {
+		f.verb = rest[0]
+		rest = ""
+	}
+	if rest != "" {
+		return f, fmt.Errorf("invalid format spec %q", spec)
+	}
+	return f, nil
+}
+
+// applyFormatSpec formats value as spec asks. Numbers take any of the
+// types; d, b, o, x, X and c need an integer. Other values are shown as
+// print shows them, cut to the precision if one is given, and take only
+// the s type.
+func applyFormatSpec(value object.Object, spec string) (string, error) {
+	f, err := parseFormatSpec(spec)
+	if err != nil {
+		return "", err
+	}
+	var n *big.Int
+	var x float64
+	isFloat := false
+	switch v := value.(type) {
+	case *object.Integer:
+		n = big.NewInt(v.Value)
+	case *object.BigInteger:
+		n = v.Value
+	case *object.Float:
+		x, isFloat = v.Value, true
+	default:
+		if f.verb != 0 && f.verb != 's' {
+			return "", fmt.Errorf("format type '%c' needs a number, got %s", f.verb, value.Type())
+		}
+		if f.sign != 0 || f.grouping != 0 || f.align == '=' {
+			return "", fmt.Errorf("format spec %q is only for numbers, got %s", spec, value.Type())
+		}
+		text := object.Display(value)
+		if f.precision >= 0 && utf8.RuneCountInString(text) > f.precision {
+			text = string([]rune(text)[:f.precision])
+		}
+		return f.pad("", text, '<'), nil
+	}
+
+	if f.verb == 's' {
+		return "", fmt.Errorf("format type 's' needs a string, got %s", value.Type())
+	}
+	negative := false
+	var digits string
+	if isFloat {
+		switch f.verb {
+		case 'b', 'c', 'd', 'o', 'x', 'X':
+			return "", fmt.Errorf("format type '%c' needs an INTEGER, got FLOAT", f.verb)
+		}
+		negative = math.Signbit(x) && !math.IsNaN(x)
+		digits = f.formatFloat(math.Abs(x))
+	} else {
+		negative = n.Sign() < 0
+		abs := new(big.Int).Abs(n)
+		switch f.verb {
+		case 'b':
+			digits = abs.Text(2)
+		case 'o':
+			digits = abs.Text(8)
+		case 'x':
+			digits = abs.Text(16)
+		case 'X':
+			digits = strings.ToUpper(abs.Text(16))
+		case 'c':
+			if !n.IsInt64() || n.Int64() < 0 || n.Int64() > utf8.MaxRune {
+				return "", fmt.Errorf("format type 'c' needs a character code, got %s", n)
+			}
+			return f.pad("", string(rune(n.Int64())), '<'), nil
+		case 0, 'd':
+			digits = abs.String()
+		default:
+			x, _ := new(big.Float).SetInt(abs).Float64()
+			digits = f.formatFloat(x)
+		}
+	}
+	if f.grouping != 0 {
+		digits = f.group(digits)
+	}
+
+	prefix := ""
+	switch {
+	case negative:
+		prefix = "-"
+	case f.sign == '+':
+		prefix = "+"
+	case f.sign == ' ':
+		prefix = " "
+	}
+	return f.pad(prefix, digits, '>'), nil
+}
+
+// formatFloat writes a non-negative x in the spec's type and precision.
+// Without a type it is written as print writes floats, or with precision
+// significant digits if there is one.
+func (f formatSpec) formatFloat(x float64) string {
+	switch {
+	case math.IsInf(x, 0):
+		return "inf"
+	case math.IsNaN(x):
+		return "nan"
+	}
+	precision := f.precision
+	verb := f.verb
+	switch verb {
+	case 0:
+		if precision < 0 {
+			return object.FormatFloat(x)
+		}
+		verb = 'g'
+	case '%':
+		x *= 100
+		verb = 'f'
+	}
+	if precision < 0 && verb != 'g' && verb != 'G' {
+		precision = 6
+	}
+	text := strconv.FormatFloat(x, verb, precision, 64)
+	if f.verb == '%' {
+		text += "%"
+	}
+	return text
+}
+
+// group puts the grouping separator between each group of digits in the
+// whole part of a number: groups of three for decimals, four for binary,
+// octal and hex.
+func (f formatSpec) group(digits string) string {
+	size := 3
+	switch f.verb {
+	case 'b', 'o', 'x', 'X':
+		size = 4
+	}
+	whole := digits
+	rest := ""
+	if i := strings.IndexAny(digits, ".eE%"); i >= 0 {
+		whole, rest = digits[:i], digits[i:]
+	}
+	var out strings.Builder
+	for i, ch := range whole {
+		if i > 0 && (len(whole)-i)%size == 0 {
+			out.WriteByte(f.grouping)
+		}
+		out.WriteRune(ch)
+	}
+	return out.String() + rest
+}
+
+// pad fills text out to the spec's width, aligned to the left, right or
+// centre, or with '=' between the sign in prefix and the digits.
+func (f formatSpec) pad(prefix, text string, defaultAlign byte) string {
+	gap := f.width - utf8.RuneCountInString(prefix) - utf8.RuneCountInString(text)
+	if gap <= 0 {
+		return prefix + text
+	}
+	fill := func(n int) string { return strings.Repeat(string(f.fill), n) }
+	align := f.align
+	if align == 0 {
+		align = defaultAlign
+	}
+	switch align {
+	case '<':
+		return prefix + text + fill(gap)
+	case '^':
+		return fill(gap/2) + prefix + text + fill(gap-gap/2)
+	case '=':
+		return prefix + fill(gap) + text
+	}
+	return fill(gap) + prefix + text
+}
@@ -0,0 +1,65 @@
+package evaluator
+
+import (
+	"strings"
+
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/token"
+)
+
+func init() {
+	builtins["freeze"] = &object.Builtin{Fn: freezeBuiltin}
+}
+
+// freezeBuiltin implements freeze(x), which returns a frozen copy of an
+// array or hash, and of the arrays and hashes in it, that raises a
+// TypeError when changed and can be used as a hash key. Other values are
+// returned as they are.
+func freezeBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("freeze requires 1 argument: value")
+	}
+	return freeze(args[0])
+}
+
+func freeze(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Array:
+		if obj.Frozen {
+			return obj
+		}
+		elements := make([]object.Object, len(obj.Elements))
+		for i, element := range obj.Elements {
+			elements[i] = freeze(element)
+		}
+		return &object.Array{Elements: elements, Frozen: true}
+	case *object.Hash:
+		if obj.Frozen {
+			return obj
+		}
+		pairs := make(map[object.HashKey]object.HashPair, len(obj.Pairs))
+		for key, pair := range obj.Pairs {
+			pairs[key] = object.HashPair{Key: pair.Key, Value: freeze(pair.Value)}
+		}
+		return &object.Hash{Pairs: pairs, Frozen: true}
+	}
+	return obj
+}
+
+// isFrozen reports whether obj is a frozen array or hash.
+func isFrozen(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return obj.Frozen
+	case *object.Hash:
+		return obj.Frozen
+	}
+	return false
+}
+
+// frozenError is the TypeError raised by an attempt to change a frozen
+// array or hash.
+func frozenError(obj object.Object, env *object.Environment, position token.Position) object.Object {
+	message := "cannot modify a frozen " + strings.ToLower(string(obj.Type()))
+	return newStdlibError("TypeError", message, env, position)
+}
@@ -0,0 +1,108 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// Handle is a resource a builtin opened for the program, such as a socket,
+// which stays open until the program closes it.
+type Handle struct {
+	Kind  string                   // what was opened: "socket", "listener", "screen" or "worker pool"
+	Name  string                   // what it is open on, such as a socket path
+	Stack []object.StackTraceEntry // the calls that opened it, outermost first
+}
+
+func (h *Handle) String() string {
+	return fmt.Sprintf("%s %s", h.Kind, h.Name)
+}
+
+// handleSet holds the handles of an interpreter that are still open, in
+// the order they were opened. Tasks share it, so it has its own lock.
+type handleSet struct {
+	mu   sync.Mutex
+	open []*Handle
+}
+
+// openHandle records that the builtin being called opened a handle, which
+// the program should close.
+func (ctx *EvalContext) openHandle(kind, name string) *Handle {
+	h := &Handle{Kind: kind, Name: name, Stack: ctx.GetCallStack()}
+	ctx.handles.mu.Lock()
+	defer ctx.handles.mu.Unlock()
+	ctx.handles.open = append(ctx.handles.open, h)
+	return h
+}
+
+// closeHandle records that h was closed. Closing it again does nothing.
+func (ctx *EvalContext) closeHandle(h *Handle) {
+	ctx.handles.mu.Lock()
+	defer ctx.handles.mu.Unlock()
+	ctx.handles.open = slices.DeleteFunc(ctx.handles.open, func(open *Handle) bool {
+		return open == h
+	})
+}
+
+// OpenHandles returns the handles the program opened and has not closed,
+// oldest first. Any left when a script ends have leaked.
+func (ctx *EvalContext) OpenHandles() []*Handle {
+	ctx.handles.mu.Lock()
+	defer ctx.handles.mu.Unlock()
+	return slices.Clone(ctx.handles.open)
+}
+
+// WriteLeakReport describes each of handles that a script left open, with
+// the calls that opened it, most recent first as in a stack trace.
+func WriteLeakReport(w io.Writer, handles []*Handle) {
+	for _, h := range handles {
+		fmt.Fprintf(w, "warning: %s was never closed; opened\n", h)
+		for i := len(h.Stack) - 1; i >= 0; i-- {
+			entry := h.Stack[i]
+			funcName := entry.Function
+			if funcName == "" {
+				funcName = "<module>"
+			}
+			fmt.Fprintf(w, "  at %s in %s\n", funcName, entry.Position.String())
+		}
+	}
+}
+
+// handleType is the record runtime.open_handles() gives for each handle.
+var handleType = &object.RecordType{
+	Name:   "Handle",
+	Fields: []string{"kind", "name", "stack"},
+}
+
+// runtimeOpenHandles implements runtime.open_handles(), the handles the
+// program has opened and not yet closed. The stack of each is an array of
+// "function at position" strings, outermost call first.
+func runtimeOpenHandles(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newTypeError("runtime.open_handles takes no arguments, got=%d", len(args))
+	}
+	handles := contextFor(env).OpenHandles()
+	elements := make([]object.Object, len(handles))
+	for i, h := range handles {
+		stack := make([]object.Object, len(h.Stack))
+		for j, entry := range h.Stack {
+			funcName := entry.Function
+			if funcName == "" {
+				funcName = "<module>"
+			}
+			stack[j] = &object.String{Value: funcName + " at " + entry.Position.String()}
+		}
+		elements[i] = &object.Record{
+			RecordType: handleType,
+			Values: []object.Object{
+				&object.String{Value: h.Kind},
+				&object.String{Value: h.Name},
+				&object.Array{Elements: stack},
+			},
+		}
+	}
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,121 @@
+package evaluator
+
+import (
+	"sort"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+func init() {
+	builtins["hashKeys"] = &object.Builtin{Fn: hashPairs("hashKeys", func(pair object.HashPair) object.Object {
+		return pair.Key
+	})}
+	builtins["hashValues"] = &object.Builtin{Fn: hashPairs("hashValues", func(pair object.HashPair) object.Object {
+		return pair.Value
+	})}
+	builtins["hashItems"] = &object.Builtin{Fn: hashPairs("hashItems", func(pair object.HashPair) object.Object {
+		return &object.Tuple{Elements: []object.Object{pair.Key, pair.Value}}
+	})}
+	builtins["hashGet"] = &object.Builtin{Fn: hashGet}
+	builtins["hashHas"] = &object.Builtin{Fn: hashHas}
+	builtins["hashRemove"] = &object.Builtin{EnvFn: hashRemove}
+}
+
+// sortedPairs returns the pairs of h ordered by how their keys print, so
+// that listing a hash gives the same order every time.
+func sortedPairs(h *object.Hash) []object.HashPair {
+	pairs := make([]object.HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+	})
+	return pairs
+}
+
+// hashPairs makes a builtin that gives an array of part of each pair of a
+// hash, in the order of sortedPairs.
+func hashPairs(name string, part func(object.HashPair) object.Object) func(args ...object.Object) object.Object {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("%s requires 1 argument: hash", name)
+		}
+		h, ok := args[0].(*object.Hash)
+		if !ok {
+			return newError("%s: expected a HASH, got %s", name, args[0].Type())
+		}
+		pairs := sortedPairs(h)
+		elements := make([]object.Object, len(pairs))
+		for i, pair := range pairs {
+			elements[i] = part(pair)
+		}
+		return &object.Array{Elements: elements}
+	}
+}
+
+// hashKey checks the arguments of a builtin taking a hash and a key.
+func hashKey(name string, args []object.Object) (*object.Hash, object.HashKey, object.Object) {
+	h, ok := args[0].(*object.Hash)
+	if !ok {
+		return nil, object.HashKey{}, newError("%s: expected a HASH, got %s", name, args[0].Type())
+	}
+	key, ok := object.HashKeyOf(args[1])
+	if !ok {
+		return nil, object.HashKey{}, newTypeError("unusable as hash key: %s", args[1].Type())
+	}
+	return h, key, nil
+}
+
+// hashGet implements hashGet(h, key, [default]), the value of key in h or
+// default, None if not given, when h has no such key.
+func hashGet(args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("hashGet requires 2 or 3 arguments: hash, key, [default]")
+	}
+	h, key, errObj := hashKey("hashGet", args)
+	if errObj != nil {
+		return errObj
+	}
+	if pair, ok := h.Pairs[key]; ok {
+		return pair.Value
+	}
+	if len(args) == 3 {
+		return args[2]
+	}
+	return NONE
+}
+
+func hashHas(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("hashHas requires 2 arguments: hash, key")
+	}
+	h, key, errObj := hashKey("hashHas", args)
+	if errObj != nil {
+		return errObj
+	}
+	_, ok := h.Pairs[key]
+	return nativeBoolToBooleanObject(ok)
+}
+
+// hashRemove implements hashRemove(h, key), which deletes key from h and
+// gives its value, raising a KeyError if h has no such key.
+func hashRemove(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("hashRemove requires 2 arguments: hash, key")
+	}
+	h, key, errObj := hashKey("hashRemove", args)
+	if errObj != nil {
+		return errObj
+	}
+	position := contextFor(env).CurrentPosition()
+	if h.Frozen {
+		return frozenError(h, env, position)
+	}
+	pair, ok := h.Pairs[key]
+	if !ok {
+		return newStdlibError("KeyError", "key not found: "+args[1].Inspect(), env, position)
+	}
+	delete(h.Pairs, key)
+	return pair.Value
+}
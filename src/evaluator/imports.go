@@ -0,0 +1,282 @@
+package evaluator
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/ext"
+	"github.com/javanhut/Carrion/src/mod"
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/token"
+)
+
+// reload is registered from init because it evaluates the module, and the
+// evaluator reads builtins.
+func init() {
+	builtins["reload"] = &object.Builtin{EnvFn: reloadModule}
+}
+
+// ImportPathEnv names the environment variable listing extra directories to
+// search for imported modules, separated like PATH.
+const ImportPathEnv = "CARRION_PATH"
+
+// PackageIndex is the file that is imported when a module names a directory.
+const PackageIndex = "index.crl"
+
+// SearchPath lists directories that are searched for imported modules after
+// those in CARRION_PATH. The carrion command fills it from the user's config.
+var SearchPath []string
+
+// GlobalLibDir is the last directory searched for imported modules, for
+// libraries shared by every script on the machine. It is ~/.carrion/lib, or
+// empty if there is no home directory.
+func GlobalLibDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".carrion", "lib")
+}
+
+// ImportSearchPath returns the directories that an import written in file
+// is resolved against, in order: the directory of file, the working
+// directory, the directories in CARRION_PATH and SearchPath, and
+// GlobalLibDir.
+func ImportSearchPath(file string) []string {
+	var dirs []string
+	if file != "" {
+		dirs = append(dirs, filepath.Dir(file))
+	}
+	dirs = append(dirs, ".")
+	dirs = append(dirs, filepath.SplitList(os.Getenv(ImportPathEnv))...)
+	dirs = append(dirs, SearchPath...)
+	if dir := GlobalLibDir(); dir != "" {
+		dirs = append(dirs, dir)
+	}
+
+	var path []string
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		dir = filepath.Clean(dir)
+		if !slices.Contains(path, dir) {
+			path = append(path, dir)
+		}
+	}
+	return path
+}
+
+// resolveImport finds the file that `import "name"` in file refers to:
+// name.crl, name/index.crl for a package directory, or a Go plugin
+// name.so. A package required by
+// the project's carrion.mod is looked for in the package cache first, then
+// the name is looked for in each directory of the search path. An absolute
+// name is not searched for. It returns the file, or "" along with every path
+// tried.
+func resolveImport(name, file string) (string, []string) {
+	var bases []string
+	if filepath.IsAbs(name) {
+		bases = []string{name}
+	} else {
+		if base, ok := mod.Resolve(name, file); ok {
+			bases = append(bases, base)
+		}
+		for _, dir := range ImportSearchPath(file) {
+			bases = append(bases, filepath.Join(dir, name))
+		}
+	}
+	var tried []string
+	for _, base := range bases {
+		for _, candidate := range []string{base + ".crl", filepath.Join(base, PackageIndex), base + ext.PluginSuffix} {
+			tried = append(tried, candidate)
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate, nil
+			}
+		}
+	}
+	return "", tried
+}
+
+// moduleKey identifies a module file however the path to it was written.
+func moduleKey(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return filepath.Clean(file)
+}
+
+// importCycle reports the chain of imports that leads back to file if it is
+// the program itself or a module that is still loading, or nil if importing
+// it now is not a cycle.
+func (ctx *EvalContext) importCycle(file string) []string {
+	chain := ctx.importStack
+	if ctx.fileName != "" {
+		chain = append([]string{ctx.fileName}, chain...)
+	}
+	key := moduleKey(file)
+	for i, loading := range chain {
+		if moduleKey(loading) == key {
+			return append(slices.Clone(chain[i:]), file)
+		}
+	}
+	return nil
+}
+
+// loadModule runs the module in filePath in a scope enclosed by env and
+// caches what it exports, replacing any earlier load. It returns the module,
+// or the error that importing it raised.
+func loadModule(filePath string, env *object.Environment, position token.Position) (*object.Namespace, object.Object) {
+	ctx := contextFor(env)
+	if cycle := ctx.importCycle(filePath); cycle != nil {
+		message := fmt.Sprintf("import cycle: %s", strings.Join(cycle, " -> "))
+		return nil, newStdlibError("ImportError", message, env, position)
+	}
+	key := moduleKey(filePath)
+	if !slices.ContainsFunc(ctx.imports, func(f string) bool { return moduleKey(f) == key }) {
+		ctx.imports = append(ctx.imports, filePath)
+	}
+
+	program, parseErrors, err := parseModule(filePath)
+	if err != nil {
+		return nil, newStdlibError("ImportError", fmt.Sprintf("could not import file: %s", err), env, position)
+	}
+	if len(parseErrors) > 0 {
+		return nil, newError("parsing errors in imported file: %v", parseErrors)
+	}
+
+	importEnv := object.NewEnclosedEnvironment(env)
+	ctx.importStack = append(ctx.importStack, filePath)
+	result := Eval(program, importEnv)
+	ctx.importStack = ctx.importStack[:len(ctx.importStack)-1]
+	if isError(result) {
+		return nil, result
+	}
+
+	module, missing := exportedNamespace(importEnv)
+	if missing != "" {
+		message := fmt.Sprintf("module %q exports %q but does not define it", filePath, missing)
+		return nil, newStdlibError("ImportError", message, env, position)
+	}
+	if ctx.modules == nil {
+		ctx.modules = make(map[string]*object.Namespace)
+	}
+	ctx.modules[key] = module
+	return module, nil
+}
+
+// reloadModule implements reload(module), which runs an imported module's
+// file again, for picking up edits from the REPL. The module is named as in
+// its import statement, or given as its namespace. The namespace is updated
+// in place, so `import ... as` aliases see the new definitions; names copied
+// by a plain import or `expose` keep the old ones until imported again.
+func reloadModule(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("reload requires 1 argument: module")
+	}
+	ctx := contextFor(env)
+	position := ctx.CurrentPosition()
+
+	var filePath string
+	switch arg := args[0].(type) {
+	case *object.String:
+		var tried []string
+		filePath, tried = resolveImport(arg.Value, position.File)
+		if filePath == "" {
+			message := fmt.Sprintf("cannot find module %q; tried:\n  %s", arg.Value, strings.Join(tried, "\n  "))
+			return newStdlibError("ImportError", message, env, position)
+		}
+		if _, ok := ctx.modules[moduleKey(filePath)]; !ok {
+			return newStdlibError("ImportError", fmt.Sprintf("module %q has not been imported", arg.Value), env, position)
+		}
+	case *object.Namespace:
+		for _, file := range ctx.imports {
+			if module := ctx.modules[moduleKey(file)]; module == arg || module.Env == arg.Env {
+				filePath = file
+			}
+		}
+		if filePath == "" {
+			return newError("reload: the namespace is not an imported module")
+		}
+	default:
+		return newError("reload expects a module name or namespace, got %s", args[0].Type())
+	}
+
+	module := ctx.modules[moduleKey(filePath)]
+	fresh, errObj := loadModule(filePath, getGlobalEnv(env), position)
+	if errObj != nil {
+		return errObj
+	}
+	module.Env = fresh.Env
+	ctx.modules[moduleKey(filePath)] = module
+	return module
+}
+
+// importModule finds and loads the module node imports, unless it has been
+// loaded already, and reports whether it is written in Go.
+func importModule(node *ast.ImportStatement, env *object.Environment) (*object.Namespace, bool, object.Object) {
+	if module, ok := ext.Module(node.FilePath.Value); ok {
+		return module, true, nil
+	}
+	filePath, tried := resolveImport(node.FilePath.Value, node.Token.Position.File)
+	if filePath == "" {
+		message := fmt.Sprintf("cannot find module %q; tried:\n  %s", node.FilePath.Value, strings.Join(tried, "\n  "))
+		return nil, false, newStdlibError("ImportError", message, env, node.Token.Position)
+	}
+	if strings.HasSuffix(filePath, ext.PluginSuffix) {
+		module, err := ext.Open(filePath)
+		if err != nil {
+			message := fmt.Sprintf("cannot load plugin %s: %s", filePath, err)
+			return nil, true, newStdlibError("ImportError", message, env, node.Token.Position)
+		}
+		return module, true, nil
+	}
+
+	if module, loaded := contextFor(env).modules[moduleKey(filePath)]; loaded {
+		return module, false, nil
+	}
+	module, errObj := loadModule(filePath, env, node.Token.Position)
+	return module, false, errObj
+}
+
+// lazyImport implements `lazy import`. It binds the module under its alias,
+// or the last part of its name, as a namespace that finds and loads the
+// module only when a member is first looked up, so that a program does not
+// pay for modules it ends up not using.
+func lazyImport(node *ast.ImportStatement, env *object.Environment) object.Object {
+	module := &object.Namespace{Env: object.NewEnvironment()}
+	module.Load = func() object.Object {
+		loaded, _, errObj := importModule(node, env)
+		if errObj != nil {
+			return errObj
+		}
+		module.Env = loaded.Env
+		return nil
+	}
+	name := path.Base(node.FilePath.Value)
+	if node.Alias != nil {
+		name = node.Alias.Value
+	}
+	env.Set(name, module)
+	return object.NONE
+}
+
+// loadNamespace runs the Load of a lazily imported module the first time
+// it is needed. A failed load is tried again on the next lookup.
+func loadNamespace(namespace *object.Namespace) object.Object {
+	load := namespace.Load
+	if load == nil {
+		return nil
+	}
+	namespace.Load = nil
+	if errObj := load(); errObj != nil {
+		namespace.Load = load
+		return errObj
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package evaluator
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+func init() {
+	builtins["dir"] = &object.Builtin{EnvFn: dirBuiltin}
+	builtins["vars"] = &object.Builtin{EnvFn: varsBuiltin}
+	builtins["callable"] = &object.Builtin{Fn: callableBuiltin}
+}
+
+// dirBuiltin implements dir([obj]), the sorted names of obj's members: the
+// fields and spells of an instance, the spells of a grimoire, the members of
+// a module and the fields of a record. Without an argument it gives the
+// names defined in the calling scope.
+func dirBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError("dir takes at most 1 argument: [obj]")
+	}
+	if len(args) == 0 {
+		return sortedNames(scopeNames(env))
+	}
+	switch obj := args[0].(type) {
+	case *object.Instance:
+		return sortedNames(append(obj.FieldNames(), methodNames(obj.Grimoire)...))
+	case *object.Grimoire:
+		return sortedNames(methodNames(obj))
+	case *object.Namespace:
+		if errObj := loadNamespace(obj); errObj != nil {
+			return errObj
+		}
+		return sortedNames(obj.Env.GetNames())
+	case *object.Record:
+		return sortedNames(slices.Clone(obj.RecordType.Fields))
+	case *object.RecordType:
+		return sortedNames(slices.Clone(obj.Fields))
+	}
+	return newError("dir: cannot list the members of %s", args[0].Type())
+}
+
+// varsBuiltin implements vars([obj]), a hash from the name of each field of
+// an instance or record, or member of a module, to its value. Without an
+// argument it gives the variables of the calling scope.
+func varsBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError("vars takes at most 1 argument: [obj]")
+	}
+	members := map[string]object.Object{}
+	if len(args) == 0 {
+		for _, name := range scopeNames(env) {
+			members[name], _ = env.GetLocal(name)
+		}
+		return newStringHash(members)
+	}
+	switch obj := args[0].(type) {
+	case *object.Instance:
+		for _, name := range obj.FieldNames() {
+			members[name], _ = obj.Field(name)
+		}
+	case *object.Namespace:
+		if errObj := loadNamespace(obj); errObj != nil {
+			return errObj
+		}
+		for _, name := range obj.Env.GetNames() {
+			members[name], _ = obj.Env.GetLocal(name)
+		}
+	case *object.Record:
+		for i, field := range obj.RecordType.Fields {
+			members[field] = obj.Values[i]
+		}
+	default:
+		return newAttributeError("vars: %s has no fields", args[0].Type())
+	}
+	return newStringHash(members)
+}
+
+// callableBuiltin implements callable(obj), whether obj can be called: a
+// spell, bound method, builtin, record type, or grimoire that is not
+// arcane.
+func callableBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("callable requires 1 argument: obj")
+	}
+	switch obj := args[0].(type) {
+	case *object.Function, *object.BoundMethod, *object.Builtin, *object.RecordType:
+		return TRUE
+	case *object.Grimoire:
+		return nativeBoolToBooleanObject(!obj.IsArcane)
+	}
+	return FALSE
+}
+
+// scopeNames returns the names defined in env, leaving out those the
+// interpreter keeps there for itself, such as __function_name.
+func scopeNames(env *object.Environment) []string {
+	return slices.DeleteFunc(env.GetNames(), func(name string) bool {
+		return strings.HasPrefix(name, "__")
+	})
+}
+
+// methodNames returns the names of the spells of grimoire and the grimoires
+// it inherits from, each once.
+func methodNames(grimoire *object.Grimoire) []string {
+	var names []string
+	for g := grimoire; g != nil; g = g.Inherits {
+		for name := range g.Methods {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// sortedNames sorts names into an array of strings, dropping repeats.
+func sortedNames(names []string) *object.Array {
+	slices.Sort(names)
+	names = slices.Compact(names)
+	elements := make([]object.Object, len(names))
+	for i, name := range names {
+		elements[i] = &object.String{Value: name}
+	}
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,269 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// The json module is registered from init rather than in the builtinModules
+// literal because json.into calls back into the evaluator, which itself
+// reads builtinModules.
+func init() {
+	builtinModules["json"] = newBuiltinModule(map[string]object.Object{
+		"into": &object.Builtin{EnvFn: jsonInto},
+		"from": &object.Builtin{Fn: jsonFrom},
+	})
+}
+
+// jsonInto implements json.into(Grimoire, text). It decodes a JSON object and
+// builds an instance of a @data grimoire by calling it with the value of
+// each declared field in order. Keys that name no field are ignored, and a
+// missing key takes the field's default. A field hinted with another @data
+// grimoire, or `[Name]` for an array of them, is built from the nested
+// object in the same way.
+func jsonInto(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("json.into requires 2 arguments: grimoire, text")
+	}
+	grimoire, ok := args[0].(*object.Grimoire)
+	if !ok || !grimoire.IsData {
+		return newError("json.into expects a @data grimoire, got %s", args[0].Inspect())
+	}
+	text, ok := args[1].(*object.String)
+	if !ok {
+		return newError("json.into expects the JSON text as a STRING, got %s", args[1].Type())
+	}
+
+	dec := json.NewDecoder(strings.NewReader(text.Value))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return newError("json.into: invalid JSON: %s", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return newError("json.into: invalid JSON: unexpected data after the top-level value")
+	}
+	return decodeInstance(grimoire, value, grimoire.Name, env)
+}
+
+func decodeInstance(grimoire *object.Grimoire, value any, path string, env *object.Environment) object.Object {
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return newError("json.into: %s must be an object, got %s", path, jsonKind(value))
+	}
+
+	args := make([]object.Object, len(grimoire.Fields))
+	for i, name := range grimoire.Fields {
+		raw, present := fields[name]
+		if !present {
+			def, ok := fieldDefault(grimoire, name)
+			if !ok {
+				return newError("json.into: %s is missing field '%s'", path, name)
+			}
+			if isError(def) {
+				return def
+			}
+			args[i] = def
+			continue
+		}
+		val := decodeValue(raw, grimoire.FieldTypes[name], grimoire.Env, path+"."+name, env)
+		if isError(val) {
+			return val
+		}
+		args[i] = val
+	}
+	return evalCallExpression(grimoire, args, env)
+}
+
+// decodeValue converts a decoded JSON value, using hint to build nested
+// @data instances. Hints are looked up in scope, the environment the
+// enclosing grimoire was defined in.
+func decodeValue(raw any, hint ast.Expression, scope *object.Environment, path string, env *object.Environment) object.Object {
+	if raw == nil {
+		return NONE
+	}
+	switch hint := hint.(type) {
+	case *ast.Identifier:
+		if obj, ok := scope.Get(hint.Value); ok {
+			if nested, ok := obj.(*object.Grimoire); ok && nested.IsData {
+				return decodeInstance(nested, raw, path, env)
+			}
+		}
+		if n, ok := raw.(json.Number); ok && hint.Value == "float" {
+			f, err := n.Float64()
+			if err != nil {
+				return newError("json.into: %s", err)
+			}
+			return &object.Float{Value: f}
+		}
+	case *ast.ArrayLiteral:
+		items, ok := raw.([]any)
+		if !ok {
+			return newError("json.into: %s must be an array, got %s", path, jsonKind(raw))
+		}
+		elements := make([]object.Object, len(items))
+		for i, item := range items {
+			val := decodeValue(item, hint.Elements[0], scope, fmt.Sprintf("%s[%d]", path, i), env)
+			if isError(val) {
+				return val
+			}
+			elements[i] = val
+		}
+		return &object.Array{Elements: elements}
+	}
+	obj, err := object.FromGo(raw)
+	if err != nil {
+		return newError("json.into: %s", err)
+	}
+	return obj
+}
+
+// fieldDefault evaluates the default of the init parameter named after
+// field, reporting false if there is none.
+func fieldDefault(grimoire *object.Grimoire, field string) (object.Object, bool) {
+	init := grimoire.InitMethod
+	if init == nil {
+		return nil, false
+	}
+	for _, param := range init.Parameters {
+		if param.Name.Value != field {
+			continue
+		}
+		if param.Once {
+			return init.OnceDefaults[field], true
+		}
+		if param.DefaultValue != nil {
+			return Eval(param.DefaultValue, init.Env), true
+		}
+	}
+	return nil, false
+}
+
+func jsonKind(raw any) string {
+	switch raw.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case json.Number:
+		return "a number"
+	case string:
+		return "a string"
+	case []any:
+		return "an array"
+	}
+	return "an object"
+}
+
+// jsonFrom implements json.from(value), encoding an instance of a @data
+// grimoire as a JSON object with its fields in declaration order. Nested
+// instances, records, arrays, tuples, hashes with string keys, strings,
+// numbers, booleans and None are encoded too.
+func jsonFrom(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("json.from requires 1 argument: value")
+	}
+	var out bytes.Buffer
+	if errObj := encodeJSON(&out, args[0], "value"); errObj != nil {
+		return errObj
+	}
+	return &object.String{Value: out.String()}
+}
+
+func encodeJSON(out *bytes.Buffer, obj object.Object, path string) *object.Error {
+	switch obj := obj.(type) {
+	case *object.None:
+		out.WriteString("null")
+	case *object.Boolean:
+		out.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Integer:
+		out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.BigInteger:
+		out.WriteString(obj.Value.String())
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return newError("json.from: %s is %s, which JSON cannot represent", path, obj.Inspect())
+		}
+		s := strconv.FormatFloat(obj.Value, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		out.WriteString(s)
+	case *object.String:
+		writeJSONString(out, obj.Value)
+	case *object.Array:
+		return encodeJSONArray(out, obj.Elements, path)
+	case *object.Tuple:
+		return encodeJSONArray(out, obj.Elements, path)
+	case *object.Hash:
+		keys := make([]string, 0, len(obj.Pairs))
+		values := make(map[string]object.Object, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return newError("json.from: %s has a non-string key %s", path, pair.Key.Inspect())
+			}
+			keys = append(keys, key.Value)
+			values[key.Value] = pair.Value
+		}
+		sort.Strings(keys)
+		return encodeJSONObject(out, keys, func(i int) object.Object { return values[keys[i]] }, path)
+	case *object.Record:
+		return encodeJSONObject(out, obj.RecordType.Fields, func(i int) object.Object { return obj.Values[i] }, path)
+	case *object.Instance:
+		if !obj.Grimoire.IsData {
+			return newError("json.from: %s is an instance of %s, which is not a @data grimoire", path, obj.Grimoire.Name)
+		}
+		values := obj.FieldValues()
+		return encodeJSONObject(out, obj.Grimoire.Fields, func(i int) object.Object { return values[i] }, path)
+	default:
+		return newError("json.from: cannot encode %s at %s", obj.Type(), path)
+	}
+	return nil
+}
+
+func encodeJSONArray(out *bytes.Buffer, elements []object.Object, path string) *object.Error {
+	out.WriteString("[")
+	for i, element := range elements {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		if errObj := encodeJSON(out, element, fmt.Sprintf("%s[%d]", path, i)); errObj != nil {
+			return errObj
+		}
+	}
+	out.WriteString("]")
+	return nil
+}
+
+func encodeJSONObject(out *bytes.Buffer, keys []string, value func(int) object.Object, path string) *object.Error {
+	out.WriteString("{")
+	for i, key := range keys {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		writeJSONString(out, key)
+		out.WriteString(": ")
+		if errObj := encodeJSON(out, value(i), path+"."+key); errObj != nil {
+			return errObj
+		}
+	}
+	out.WriteString("}")
+	return nil
+}
+
+func writeJSONString(out *bytes.Buffer, s string) {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	out.Truncate(out.Len() - 1) // Encode ends with a newline
+}
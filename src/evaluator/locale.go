@@ -0,0 +1,156 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// defaultFractionDigits caps the decimals shown for a float when the caller
+// does not ask for a fixed number.
+const defaultFractionDigits = 6
+
+// localePrinter returns a printer for a locale tag such as "de" or "en-IN".
+func localePrinter(name string, arg object.Object) (*message.Printer, *object.Error) {
+	str, ok := arg.(*object.String)
+	if !ok {
+		return nil, newTypeError("%s: locale must be a STRING, got %s", name, arg.Type())
+	}
+	tag, err := language.Parse(str.Value)
+	if err != nil {
+		return nil, newValueError("%s: unknown locale '%s'", name, str.Value)
+	}
+	return message.NewPrinter(tag), nil
+}
+
+// formatNumber implements format_number(x, [locale], [decimals]).
+func formatNumber(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError("format_number requires 1 to 3 arguments: number, [locale], [decimals]")
+	}
+
+	var value interface{}
+	var opts []number.Option
+	switch x := args[0].(type) {
+	case *object.Integer:
+		value = x.Value
+	case *object.BigInteger:
+		value = x.Value
+	case *object.Float:
+		value = x.Value
+		opts = append(opts, number.MaxFractionDigits(defaultFractionDigits))
+	default:
+		return newTypeError("format_number: expected a number, got %s", args[0].Type())
+	}
+
+	printer := message.NewPrinter(language.English)
+	if len(args) >= 2 {
+		var errObj *object.Error
+		if printer, errObj = localePrinter("format_number", args[1]); errObj != nil {
+			return errObj
+		}
+	}
+	if len(args) == 3 {
+		decimals, ok := args[2].(*object.Integer)
+		if !ok || decimals.Value < 0 {
+			return newValueError("format_number: decimals must be a non-negative INTEGER")
+		}
+		opts = []number.Option{
+			number.MinFractionDigits(int(decimals.Value)),
+			number.MaxFractionDigits(int(decimals.Value)),
+		}
+	}
+
+	if n, ok := value.(*big.Int); ok {
+		formatted, ok := formatBigInteger(printer, n, opts)
+		if !ok {
+			return newValueError("format_number: %d-digit integers are too long to format", len(n.String()))
+		}
+		return &object.String{Value: formatted}
+	}
+	return &object.String{Value: printer.Sprint(number.Decimal(value, opts...))}
+}
+
+// formatBigInteger formats an integer too large for the number package,
+// which only takes machine numbers. A float with as many digits shows where
+// the locale puts its sign and separators, and its digits are then replaced
+// by n's, in the locale's digits. It fails for integers too long for a
+// float.
+func formatBigInteger(printer *message.Printer, n *big.Int, opts []number.Option) (string, bool) {
+	digits := new(big.Int).Abs(n).String()
+	shape, _ := strconv.ParseFloat(strings.Repeat("1", len(digits)), 64)
+	if math.IsInf(shape, 0) {
+		return "", false
+	}
+	if n.Sign() < 0 {
+		shape = -shape
+	}
+	if len(opts) == 0 {
+		opts = []number.Option{number.MaxFractionDigits(0)}
+	}
+
+	localDigits := make([]string, 10)
+	isDigit := map[rune]bool{}
+	for d := range localDigits {
+		localDigits[d] = printer.Sprint(number.Decimal(d))
+		for _, r := range localDigits[d] {
+			isDigit[r] = true
+		}
+	}
+	var sb strings.Builder
+	next := 0
+	for _, r := range printer.Sprint(number.Decimal(shape, opts...)) {
+		if next < len(digits) && isDigit[r] {
+			sb.WriteString(localDigits[digits[next]-'0'])
+			next++
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String(), true
+}
+
+// formatCurrency implements format_currency(x, code, [locale]). The amount
+// is rounded to the currency's usual number of decimals.
+func formatCurrency(args ...object.Object) object.Object {
+	if len(args) < 2 || len(args) > 3 {
+		return newError("format_currency requires 2 or 3 arguments: amount, currency, [locale]")
+	}
+
+	var amount interface{}
+	switch x := args[0].(type) {
+	case *object.Integer:
+		amount = x.Value
+	case *object.Float:
+		amount = x.Value
+	default:
+		return newTypeError("format_currency: expected a number, got %s", args[0].Type())
+	}
+
+	code, ok := args[1].(*object.String)
+	if !ok {
+		return newTypeError("format_currency: currency must be a STRING, got %s", args[1].Type())
+	}
+	unit, err := currency.ParseISO(code.Value)
+	if err != nil {
+		return newValueError("format_currency: unknown currency '%s'", code.Value)
+	}
+
+	printer := message.NewPrinter(language.English)
+	if len(args) == 3 {
+		var errObj *object.Error
+		if printer, errObj = localePrinter("format_currency", args[2]); errObj != nil {
+			return errObj
+		}
+	}
+
+	return &object.String{Value: printer.Sprint(currency.Symbol(unit.Amount(amount)))}
+}
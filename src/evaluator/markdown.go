@@ -0,0 +1,103 @@
+package evaluator
+
+import (
+	"github.com/javanhut/Carrion/src/markdown"
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// The markdown module renders Markdown as HTML and gives scripts its
+// structure: to_html(text), parse(text) for the whole tree, and
+// headings(text) and links(text) for the parts doc tooling most often
+// wants.
+func init() {
+	builtinModules["markdown"] = newBuiltinModule(map[string]object.Object{
+		"to_html":  &object.Builtin{Fn: markdownFn("to_html", markdownToHTML)},
+		"parse":    &object.Builtin{Fn: markdownFn("parse", markdownTree)},
+		"headings": &object.Builtin{Fn: markdownFn("headings", markdownHeadings)},
+		"links":    &object.Builtin{Fn: markdownFn("links", markdownLinks)},
+	})
+}
+
+// markdownFn makes a builtin that parses its one argument, a string of
+// Markdown, and passes the document to fn.
+func markdownFn(name string, fn func(*markdown.Node) object.Object) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		text, ok := singleArg[*object.String](args)
+		if !ok {
+			return newError("markdown.%s requires 1 argument: a STRING of Markdown", name)
+		}
+		return fn(markdown.Parse(text.Value))
+	}
+}
+
+func markdownToHTML(doc *markdown.Node) object.Object {
+	return &object.String{Value: markdown.HTML(doc)}
+}
+
+// markdownTree converts a node to a hash with its "type", such as
+// "heading" or "link", its "line", the fields that matter for its type and
+// its "children".
+func markdownTree(n *markdown.Node) object.Object {
+	members := map[string]object.Object{
+		"type": &object.String{Value: string(n.Kind)},
+		"line": &object.Integer{Value: int64(n.Line)},
+	}
+	switch n.Kind {
+	case markdown.Heading:
+		members["level"] = &object.Integer{Value: int64(n.Level)}
+		members["id"] = &object.String{Value: n.ID}
+	case markdown.Text, markdown.Code:
+		members["text"] = &object.String{Value: n.Text}
+	case markdown.CodeBlock:
+		members["text"] = &object.String{Value: n.Text}
+		members["info"] = &object.String{Value: n.Info}
+	case markdown.List:
+		members["ordered"] = nativeBoolToBooleanObject(n.Ordered)
+		members["start"] = &object.Integer{Value: int64(n.Start)}
+		members["tight"] = nativeBoolToBooleanObject(n.Tight)
+	case markdown.Link, markdown.Image:
+		members["url"] = &object.String{Value: n.URL}
+		members["title"] = &object.String{Value: n.Title}
+	}
+	children := make([]object.Object, len(n.Children))
+	for i, child := range n.Children {
+		children[i] = markdownTree(child)
+	}
+	members["children"] = &object.Array{Elements: children}
+	return newStringHash(members)
+}
+
+// markdownHeadings lists the headings of a document, each as a hash of its
+// "level", "text", "id" and "line", which is enough for a table of
+// contents.
+func markdownHeadings(doc *markdown.Node) object.Object {
+	headings := []object.Object{}
+	doc.Walk(func(n *markdown.Node) {
+		if n.Kind == markdown.Heading {
+			headings = append(headings, newStringHash(map[string]object.Object{
+				"level": &object.Integer{Value: int64(n.Level)},
+				"text":  &object.String{Value: n.PlainText()},
+				"id":    &object.String{Value: n.ID},
+				"line":  &object.Integer{Value: int64(n.Line)},
+			}))
+		}
+	})
+	return &object.Array{Elements: headings}
+}
+
+// markdownLinks lists the links of a document, autolinks included, each
+// as a hash of its "text", "url", "title" and "line".
+func markdownLinks(doc *markdown.Node) object.Object {
+	links := []object.Object{}
+	doc.Walk(func(n *markdown.Node) {
+		if n.Kind == markdown.Link {
+			links = append(links, newStringHash(map[string]object.Object{
+				"text":  &object.String{Value: n.PlainText()},
+				"url":   &object.String{Value: n.URL},
+				"title": &object.String{Value: n.Title},
+				"line":  &object.Integer{Value: int64(n.Line)},
+			}))
+		}
+	})
+	return &object.Array{Elements: links}
+}
@@ -0,0 +1,69 @@
+package evaluator
+
+import (
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// StrictMatch is whether new interpreters reject match statements that no
+// case fits; see EvalContext.SetStrictMatch.
+var StrictMatch bool
+
+// StrictMatch reports whether match statements must be exhaustive.
+func (ctx *EvalContext) StrictMatch() bool {
+	return ctx.strictMatch
+}
+
+// SetStrictMatch turns exhaustive matching on or off. When it is on, a
+// match statement without a default whose value fits none of its cases
+// raises a MatchError, instead of doing nothing and giving None, so a value
+// the cases forgot about cannot slip through unnoticed.
+func (ctx *EvalContext) SetStrictMatch(strict bool) {
+	ctx.strictMatch = strict
+}
+
+// unmatched is what a match statement without a default gives when no case
+// fits value.
+func unmatched(ms *ast.MatchStatement, value object.Object, env *object.Environment) object.Object {
+	if !contextFor(env).StrictMatch() {
+		return NONE
+	}
+	return newStdlibError("MatchError", "no case matches "+value.Inspect(), env, ms.Token.Position)
+}
+
+// matchesCase reports whether value fits condition, the condition of a
+// case: a range or comparison pattern, or else a value it must equal. A
+// value that cannot be ordered against a pattern's bounds, such as a string
+// against numbers, does not fit it.
+func matchesCase(condition ast.Expression, value object.Object, env *object.Environment) (bool, object.Object) {
+	switch condition := condition.(type) {
+	case *ast.RangePattern:
+		start := Eval(condition.Start, env)
+		if isError(start) {
+			return false, start
+		}
+		end := Eval(condition.End, env)
+		if isError(end) {
+			return false, end
+		}
+		return evalInfixExpression(">=", value, start, nil) == TRUE &&
+			evalInfixExpression("<=", value, end, nil) == TRUE, nil
+	case *ast.ComparisonPattern:
+		bound := Eval(condition.Value, env)
+		if isError(bound) {
+			return false, bound
+		}
+		switch condition.Operator {
+		case "==":
+			return isEqual(value, bound), nil
+		case "!=":
+			return !isEqual(value, bound), nil
+		}
+		return evalInfixExpression(condition.Operator, value, bound, nil) == TRUE, nil
+	}
+	expected := Eval(condition, env)
+	if isError(expected) {
+		return false, expected
+	}
+	return isEqual(value, expected), nil
+}
@@ -0,0 +1,221 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+var mathModule = map[string]object.Object{
+	"pi":  &object.Float{Value: math.Pi},
+	"e":   &object.Float{Value: math.E},
+	"tau": &object.Float{Value: 2 * math.Pi},
+	"inf": &object.Float{Value: math.Inf(1)},
+
+	"sqrt":  mathUnary("sqrt", math.Sqrt),
+	"sin":   mathUnary("sin", math.Sin),
+	"cos":   mathUnary("cos", math.Cos),
+	"tan":   mathUnary("tan", math.Tan),
+	"asin":  mathUnary("asin", math.Asin),
+	"acos":  mathUnary("acos", math.Acos),
+	"atan":  mathUnary("atan", math.Atan),
+	"exp":   mathUnary("exp", math.Exp),
+	"log2":  mathUnary("log2", math.Log2),
+	"log10": mathUnary("log10", math.Log10),
+
+	"log": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("math.log requires 1 or 2 arguments: x, [base]")
+			}
+			x, err := numberArg("math.log", args[0])
+			if err != nil {
+				return err
+			}
+			if x <= 0 {
+				return newValueError("math.log: math domain error")
+			}
+			if len(args) == 1 {
+				return &object.Float{Value: math.Log(x)}
+			}
+			base, err := numberArg("math.log", args[1])
+			if err != nil {
+				return err
+			}
+			if base <= 0 || base == 1 {
+				return newError("math.log: invalid base %g", base)
+			}
+			return &object.Float{Value: math.Log(x) / math.Log(base)}
+		},
+	},
+	"pow": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("math.pow requires 2 arguments: x, y")
+			}
+			x, err := numberArg("math.pow", args[0])
+			if err != nil {
+				return err
+			}
+			y, err := numberArg("math.pow", args[1])
+			if err != nil {
+				return err
+			}
+			return &object.Float{Value: math.Pow(x, y)}
+		},
+	},
+	"floor": mathRounding("floor", math.Floor),
+	"ceil":  mathRounding("ceil", math.Ceil),
+	"trunc": mathRounding("trunc", math.Trunc),
+	"abs":   builtins["abs"],
+	"round": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError("math.round requires 1 to 3 arguments: x, [ndigits], [mode]")
+			}
+			x, err := numberArg("math.round", args[0])
+			if err != nil {
+				return err
+			}
+			var ndigits int64
+			if len(args) > 1 {
+				n, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("math.round: ndigits must be INTEGER, got %s", args[1].Type())
+				}
+				ndigits = n.Value
+			}
+			mode := "half_even"
+			if len(args) > 2 {
+				m, ok := args[2].(*object.String)
+				if !ok {
+					return newError("math.round: mode must be STRING, got %s", args[2].Type())
+				}
+				mode = m.Value
+			}
+			roundFn, ok := roundingModes[mode]
+			if !ok {
+				return newError("math.round: unknown rounding mode '%s'", mode)
+			}
+
+			scale := math.Pow(10, float64(ndigits))
+			rounded := roundFn(x*scale) / scale
+			if len(args) == 1 {
+				return floatToInt("math.round", rounded)
+			}
+			return &object.Float{Value: rounded}
+		},
+	},
+	"gcd": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("math.gcd requires 2 arguments: a, b")
+			}
+			a, ok1 := args[0].(*object.Integer)
+			b, ok2 := args[1].(*object.Integer)
+			if !ok1 || !ok2 {
+				return newError("arguments to `math.gcd` must be INTEGER, got=%s and %s",
+					args[0].Type(), args[1].Type())
+			}
+			x, y := a.Value, b.Value
+			if x < 0 {
+				x = -x
+			}
+			if y < 0 {
+				y = -y
+			}
+			for y != 0 {
+				x, y = y, x%y
+			}
+			return &object.Integer{Value: x}
+		},
+	},
+}
+
+// roundingModes maps the mode names accepted by math.round to their
+// implementations.
+var roundingModes = map[string]func(float64) float64{
+	"half_even": math.RoundToEven,
+	"half_up":   math.Round,
+	"half_down": func(x float64) float64 {
+		if math.Abs(x-math.Trunc(x)) == 0.5 {
+			return math.Trunc(x)
+		}
+		return math.Round(x)
+	},
+	"floor": math.Floor,
+	"ceil":  math.Ceil,
+	"trunc": math.Trunc,
+}
+
+// mathUnary adapts a float64 function into a builtin taking one number.
+func mathUnary(name string, fn func(float64) float64) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("math.%s requires exactly one argument, got %d", name, len(args))
+			}
+			x, err := numberArg("math."+name, args[0])
+			if err != nil {
+				return err
+			}
+			result := fn(x)
+			if math.IsNaN(result) {
+				return newValueError("math.%s: math domain error", name)
+			}
+			return &object.Float{Value: result}
+		},
+	}
+}
+
+// mathRounding adapts a rounding function into a builtin returning an
+// INTEGER.
+func mathRounding(name string, fn func(float64) float64) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("math.%s requires exactly one argument, got %d", name, len(args))
+			}
+			if isInteger(args[0]) {
+				return args[0]
+			}
+			x, err := numberArg("math."+name, args[0])
+			if err != nil {
+				return err
+			}
+			return floatToInt("math."+name, fn(x))
+		},
+	}
+}
+
+// floatToInt converts a whole float to an INTEGER, or to a BIG_INTEGER
+// when it does not fit in 64 bits. Infinity and NaN have no integer value.
+func floatToInt(name string, x float64) object.Object {
+	if math.IsNaN(x) {
+		return newValueError("%s: cannot convert NaN to an integer", name)
+	}
+	if math.IsInf(x, 0) {
+		return newValueError("%s: cannot convert infinity to an integer", name)
+	}
+	if x >= math.MinInt64 && x < math.MaxInt64 {
+		return &object.Integer{Value: int64(x)}
+	}
+	n, _ := big.NewFloat(x).Int(nil)
+	return object.NewInt(n)
+}
+
+// numberArg converts an INTEGER or FLOAT argument to float64.
+func numberArg(name string, arg object.Object) (float64, *object.Error) {
+	switch arg := arg.(type) {
+	case *object.Integer:
+		return float64(arg.Value), nil
+	case *object.BigInteger:
+		value, _ := new(big.Float).SetInt(arg.Value).Float64()
+		return value, nil
+	case *object.Float:
+		return arg.Value, nil
+	default:
+		return 0, newError("%s: expected INTEGER or FLOAT, got %s", name, arg.Type())
+	}
+}
@@ -0,0 +1,21 @@
+package evaluator
+
+import (
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// builtinModules holds namespaces implemented in Go, such as math. They are
+// resolved after the environment so that user code may shadow them.
+var builtinModules = map[string]*object.Namespace{
+	"math":    newBuiltinModule(mathModule),
+	"runtime": newBuiltinModule(runtimeModule),
+}
+
+// newBuiltinModule wraps a set of members in a Namespace.
+func newBuiltinModule(members map[string]object.Object) *object.Namespace {
+	env := object.NewEnvironment()
+	for name, member := range members {
+		env.Set(name, member)
+	}
+	return &object.Namespace{Env: env}
+}
@@ -0,0 +1,220 @@
+package evaluator
+
+import (
+	"math/big"
+	"net/netip"
+	"strings"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// maxNetList is the most addresses or subnets net.hosts and net.subnets
+// will list, since a large IPv6 prefix holds more than could ever fit in
+// memory.
+const maxNetList = 1 << 16
+
+// The net module parses and compares IP addresses and CIDR ranges, for
+// scripts that audit firewall rules, inventories and logs. Addresses are
+// passed and returned as strings, IPv4 in dotted form and IPv6 in the
+// shortest form.
+func init() {
+	builtinModules["net"] = newBuiltinModule(map[string]object.Object{
+		"parse_ip":      &object.Builtin{Fn: netParseIP},
+		"is_ip":         &object.Builtin{Fn: netIsIP},
+		"ip_info":       &object.Builtin{Fn: netIPInfo},
+		"parse_cidr":    &object.Builtin{Fn: netParseCIDR},
+		"cidr_contains": &object.Builtin{Fn: netCIDRContains},
+		"hosts":         &object.Builtin{Fn: netHosts},
+		"subnets":       &object.Builtin{Fn: netSubnets},
+	})
+}
+
+// parseIP parses an address, giving IPv4 addresses written as IPv6, such as
+// ::ffff:10.0.0.1, as plain IPv4.
+func parseIP(fn string, arg object.Object) (netip.Addr, object.Object) {
+	s, ok := arg.(*object.String)
+	if !ok {
+		return netip.Addr{}, newError("%s: address must be a STRING, got %s", fn, arg.Type())
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(s.Value))
+	if err != nil {
+		return netip.Addr{}, newError("%s: invalid IP address %q", fn, s.Value)
+	}
+	return addr.Unmap(), nil
+}
+
+// parseCIDR parses a range such as 10.0.0.0/8, dropping any host bits, so
+// that 10.1.2.3/8 is the same range. A bare address is a range of one.
+func parseCIDR(fn string, arg object.Object) (netip.Prefix, object.Object) {
+	s, ok := arg.(*object.String)
+	if !ok {
+		return netip.Prefix{}, newError("%s: range must be a STRING, got %s", fn, arg.Type())
+	}
+	text := strings.TrimSpace(s.Value)
+	if !strings.Contains(text, "/") {
+		addr, errObj := parseIP(fn, arg)
+		if errObj != nil {
+			return netip.Prefix{}, errObj
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(text)
+	if err != nil {
+		return netip.Prefix{}, newError("%s: invalid CIDR range %q", fn, s.Value)
+	}
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	return prefix.Masked(), nil
+}
+
+// netParseIP implements net.parse_ip(text), which gives the address in its
+// normal form, so that addresses written differently compare equal.
+func netParseIP(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("net.parse_ip requires 1 argument: text")
+	}
+	addr, errObj := parseIP("net.parse_ip", args[0])
+	if errObj != nil {
+		return errObj
+	}
+	return &object.String{Value: addr.String()}
+}
+
+func netIsIP(args ...object.Object) object.Object {
+	s, ok := singleArg[*object.String](args)
+	if !ok {
+		return newError("net.is_ip requires 1 argument: a STRING")
+	}
+	_, err := netip.ParseAddr(strings.TrimSpace(s.Value))
+	return nativeBoolToBooleanObject(err == nil)
+}
+
+// netIPInfo implements net.ip_info(ip), a hash of the address's "ip" in
+// normal form, its "version" and what kind of address it is.
+func netIPInfo(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("net.ip_info requires 1 argument: ip")
+	}
+	addr, errObj := parseIP("net.ip_info", args[0])
+	if errObj != nil {
+		return errObj
+	}
+	return newStringHash(map[string]object.Object{
+		"ip":         &object.String{Value: addr.String()},
+		"version":    &object.Integer{Value: ipVersion(addr)},
+		"private":    nativeBoolToBooleanObject(addr.IsPrivate()),
+		"loopback":   nativeBoolToBooleanObject(addr.IsLoopback()),
+		"link_local": nativeBoolToBooleanObject(addr.IsLinkLocalUnicast()),
+		"multicast":  nativeBoolToBooleanObject(addr.IsMulticast()),
+		"global":     nativeBoolToBooleanObject(addr.IsGlobalUnicast() && !addr.IsPrivate()),
+	})
+}
+
+func ipVersion(addr netip.Addr) int64 {
+	if addr.Is4() {
+		return 4
+	}
+	return 6
+}
+
+// netParseCIDR implements net.parse_cidr(cidr), a hash of the range in
+// normal form as "cidr", its "network" and "prefix" length, its "version",
+// its "first" and "last" addresses and its "size" in addresses.
+func netParseCIDR(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("net.parse_cidr requires 1 argument: cidr")
+	}
+	prefix, errObj := parseCIDR("net.parse_cidr", args[0])
+	if errObj != nil {
+		return errObj
+	}
+	size := new(big.Int).Lsh(big.NewInt(1), uint(prefix.Addr().BitLen()-prefix.Bits()))
+	return newStringHash(map[string]object.Object{
+		"cidr":    &object.String{Value: prefix.String()},
+		"network": &object.String{Value: prefix.Addr().String()},
+		"prefix":  &object.Integer{Value: int64(prefix.Bits())},
+		"version": &object.Integer{Value: ipVersion(prefix.Addr())},
+		"first":   &object.String{Value: prefix.Addr().String()},
+		"last":    &object.String{Value: lastAddr(prefix).String()},
+		"size":    object.NewInt(size),
+	})
+}
+
+// lastAddr is the highest address in a range.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(bytes)*8; i++ {
+		bytes[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
+
+// netCIDRContains implements net.cidr_contains(cidr, ip), which also takes
+// a second range and tells whether it lies wholly inside the first.
+func netCIDRContains(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("net.cidr_contains requires 2 arguments: cidr, ip")
+	}
+	outer, errObj := parseCIDR("net.cidr_contains", args[0])
+	if errObj != nil {
+		return errObj
+	}
+	inner, errObj := parseCIDR("net.cidr_contains", args[1])
+	if errObj != nil {
+		return errObj
+	}
+	return nativeBoolToBooleanObject(inner.Bits() >= outer.Bits() && outer.Contains(inner.Addr()))
+}
+
+// netHosts implements net.hosts(cidr), the addresses of a range that can
+// be given to hosts. For IPv4 ranges larger than /31 that leaves out the
+// network and broadcast addresses.
+func netHosts(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("net.hosts requires 1 argument: cidr")
+	}
+	prefix, errObj := parseCIDR("net.hosts", args[0])
+	if errObj != nil {
+		return errObj
+	}
+	if prefix.Addr().BitLen()-prefix.Bits() > 16 {
+		return newError("net.hosts: %s has more than %d addresses; use net.subnets to split it first", prefix, maxNetList)
+	}
+	first, last := prefix.Addr(), lastAddr(prefix)
+	if prefix.Addr().Is4() && prefix.Bits() < 31 {
+		first, last = first.Next(), last.Prev()
+	}
+	hosts := []object.Object{}
+	for addr := first; addr.IsValid() && addr.Compare(last) <= 0; addr = addr.Next() {
+		hosts = append(hosts, &object.String{Value: addr.String()})
+	}
+	return &object.Array{Elements: hosts}
+}
+
+// netSubnets implements net.subnets(cidr, prefix), which splits a range
+// into the ranges of a longer prefix that cover it, in order.
+func netSubnets(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("net.subnets requires 2 arguments: cidr, prefix")
+	}
+	prefix, errObj := parseCIDR("net.subnets", args[0])
+	if errObj != nil {
+		return errObj
+	}
+	bits, ok := args[1].(*object.Integer)
+	if !ok || bits.Value < int64(prefix.Bits()) || bits.Value > int64(prefix.Addr().BitLen()) {
+		return newError("net.subnets: prefix must be an INTEGER from %d to %d, got %s", prefix.Bits(), prefix.Addr().BitLen(), args[1].Inspect())
+	}
+	if bits.Value-int64(prefix.Bits()) > 16 {
+		return newError("net.subnets: splitting %s into /%d gives more than %d subnets", prefix, bits.Value, maxNetList)
+	}
+	subnets := []object.Object{}
+	for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); {
+		subnet := netip.PrefixFrom(addr, int(bits.Value))
+		subnets = append(subnets, &object.String{Value: subnet.String()})
+		addr = lastAddr(subnet).Next()
+	}
+	return &object.Array{Elements: subnets}
+}
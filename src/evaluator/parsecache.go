@@ -0,0 +1,68 @@
+package evaluator
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/lexer"
+	"github.com/javanhut/Carrion/src/mod"
+	"github.com/javanhut/Carrion/src/parser"
+)
+
+// CacheParses keeps the parsed form of each module file imported in the
+// process, so that importing the file again, in this interpreter or a later
+// one, skips reading and parsing it until the file changes. The carrion
+// daemon sets it, since the programs it runs mostly import the same
+// modules; modules still run afresh in every interpreter.
+var CacheParses bool
+
+type parsedModule struct {
+	modTime time.Time
+	size    int64
+	program *ast.Program
+}
+
+var parseCache = struct {
+	sync.Mutex
+	files map[string]parsedModule
+}{files: make(map[string]parsedModule)}
+
+// parseModule reads and parses the module in filePath, from the cache if
+// CacheParses is set and the file is unchanged. It returns the parse errors
+// if there are any.
+func parseModule(filePath string) (*ast.Program, []string, error) {
+	var info os.FileInfo
+	if CacheParses {
+		var err error
+		if info, err = os.Stat(filePath); err != nil {
+			return nil, nil, err
+		}
+		parseCache.Lock()
+		cached, ok := parseCache.files[moduleKey(filePath)]
+		parseCache.Unlock()
+		if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+			return cached.program, nil, nil
+		}
+	}
+
+	fileContent, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Create lexer and parser with filename for better error reporting
+	p := parser.New(lexer.New(string(fileContent), filePath))
+	p.SetVersion(mod.LanguageVersion(filePath))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, p.Errors(), nil
+	}
+
+	if CacheParses {
+		parseCache.Lock()
+		parseCache.files[moduleKey(filePath)] = parsedModule{info.ModTime(), info.Size(), program}
+		parseCache.Unlock()
+	}
+	return program, nil, nil
+}
@@ -0,0 +1,317 @@
+package evaluator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/token"
+)
+
+func init() {
+	builtins["sh"] = &object.Builtin{Fn: shBuiltin}
+}
+
+// shBuiltin implements sh(command) and sh(program, args...). A single
+// string is split into words as a shell would, honouring quotes and
+// backslashes but nothing else: no variables, globs or redirections. With
+// several strings each is one word as given, which is safer for arguments
+// that come from elsewhere.
+func shBuiltin(args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError("sh requires at least 1 argument: command, [args...]")
+	}
+	words := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return newError("sh: argument %d must be a STRING, got %s", i+1, arg.Type())
+		}
+		words[i] = str.Value
+	}
+	if len(words) == 1 {
+		var err error
+		if words, err = splitCommand(words[0]); err != nil {
+			return newError("sh: %s", err)
+		}
+	}
+	if len(words) == 0 || words[0] == "" {
+		return newError("sh: empty command")
+	}
+	return &object.Pipeline{Stages: [][]string{words}}
+}
+
+// splitCommand splits a command line into words at unquoted whitespace.
+// Inside single quotes every character is literal; inside double quotes and
+// outside quotes a backslash makes the next character literal.
+func splitCommand(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in %q", quote, line)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash in %q", line)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// pipelineOperator applies an operator with a pipeline on its left: | adds
+// a command given as a pipeline or a string for sh, < reads the first
+// command's input from a file, and > and >> run the pipeline, writing its
+// output to a file, replaced or added to.
+func pipelineOperator(operator string, p *object.Pipeline, right object.Object, env *object.Environment, position token.Position) object.Object {
+	if operator == "|" {
+		next, errObj := pipelineArg(operator, right)
+		if errObj != nil {
+			return errObj
+		}
+		return p.Then(next)
+	}
+	path, ok := right.(*object.String)
+	if !ok {
+		return newTypeError("unknown operator: PIPELINE %s %s", operator, right.Type())
+	}
+	switch operator {
+	case "<":
+		return p.WithInputFile(path.Value)
+	case ">":
+		return runPipelineToFile(p, path.Value, os.O_TRUNC, env, position)
+	case ">>":
+		return runPipelineToFile(p, path.Value, os.O_APPEND, env, position)
+	}
+	return newTypeError("unknown operator: PIPELINE %s STRING", operator)
+}
+
+// pipelineArg takes the command added to a pipeline, either a pipeline or
+// a string for sh.
+func pipelineArg(name string, arg object.Object) (*object.Pipeline, object.Object) {
+	switch arg := arg.(type) {
+	case *object.Pipeline:
+		return arg, nil
+	case *object.String:
+		next := shBuiltin(arg)
+		if isError(next) {
+			return nil, next
+		}
+		return next.(*object.Pipeline), nil
+	}
+	return nil, newError("%s expects a PIPELINE or a command STRING, got %s", name, arg.Type())
+}
+
+// pipelineMember looks up a member of a pipeline. pipe(command), input(text)
+// and read_from(path) give a new pipeline, as |, and < do. run() runs it with
+// its output going to stdout, output() gives its output as a string and
+// lines() as an array of lines, and write_to(path) and append_to(path) are
+// > and >>.
+func pipelineMember(p *object.Pipeline, name string) object.Object {
+	var fn func(env *object.Environment, args ...object.Object) object.Object
+	switch name {
+	case "pipe":
+		fn = func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("pipe requires 1 argument: command")
+			}
+			next, errObj := pipelineArg("pipe", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			return p.Then(next)
+		}
+	case "input", "read_from":
+		fn = func(env *object.Environment, args ...object.Object) object.Object {
+			text, ok := singleArg[*object.String](args)
+			if !ok {
+				return newError("%s requires 1 argument: a STRING", name)
+			}
+			if name == "input" {
+				return p.WithInput(text.Value)
+			}
+			return p.WithInputFile(text.Value)
+		}
+	case "run":
+		fn = func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("run takes no arguments")
+			}
+			return runPipeline(p, contextFor(env).Stdout(), env, contextFor(env).CurrentPosition())
+		}
+	case "output", "lines":
+		fn = func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("%s takes no arguments", name)
+			}
+			var out bytes.Buffer
+			if result := runPipeline(p, &out, env, contextFor(env).CurrentPosition()); isError(result) {
+				return result
+			}
+			if name == "output" {
+				return &object.String{Value: out.String()}
+			}
+			elements := []object.Object{}
+			if out.Len() > 0 {
+				for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+					elements = append(elements, &object.String{Value: line})
+				}
+			}
+			return &object.Array{Elements: elements}
+		}
+	case "write_to", "append_to":
+		fn = func(env *object.Environment, args ...object.Object) object.Object {
+			path, ok := singleArg[*object.String](args)
+			if !ok {
+				return newError("%s requires 1 argument: path", name)
+			}
+			flag := os.O_TRUNC
+			if name == "append_to" {
+				flag = os.O_APPEND
+			}
+			return runPipelineToFile(p, path.Value, flag, env, contextFor(env).CurrentPosition())
+		}
+	default:
+		return newError("pipeline has no member '%s'", name)
+	}
+	return &object.Builtin{EnvFn: fn}
+}
+
+func runPipelineToFile(p *object.Pipeline, path string, flag int, env *object.Environment, position token.Position) object.Object {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0644)
+	if err != nil {
+		return newStdlibError("CommandError", err.Error(), env, position)
+	}
+	defer file.Close()
+	return runPipeline(p, file, env, position)
+}
+
+// runPipeline runs every command of p at once, each reading what the one
+// before writes, with the last writing to stdout. It waits for them all and
+// raises a CommandError for the first that could not start or failed, with
+// what it wrote to stderr. A command other than the last ending because
+// the next stopped reading, as `head` does, has not failed. The standard
+// error of commands that succeed is passed on.
+func runPipeline(p *object.Pipeline, stdout io.Writer, env *object.Environment, position token.Position) object.Object {
+	c := contextFor(env).Context()
+	cmds := make([]*exec.Cmd, len(p.Stages))
+	stderrs := make([]bytes.Buffer, len(p.Stages))
+	var parentEnds []*os.File
+	defer func() {
+		for _, f := range parentEnds {
+			f.Close()
+		}
+	}()
+
+	for i, stage := range p.Stages {
+		cmds[i] = exec.CommandContext(c, stage[0], stage[1:]...)
+		cmds[i].Stderr = &stderrs[i]
+	}
+	switch {
+	case p.InputFile != "":
+		file, err := os.Open(p.InputFile)
+		if err != nil {
+			return newStdlibError("CommandError", err.Error(), env, position)
+		}
+		parentEnds = append(parentEnds, file)
+		cmds[0].Stdin = file
+	case p.Input != nil:
+		cmds[0].Stdin = strings.NewReader(*p.Input)
+	}
+	for i := 0; i+1 < len(cmds); i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return newStdlibError("CommandError", err.Error(), env, position)
+		}
+		parentEnds = append(parentEnds, r, w)
+		cmds[i].Stdout = w
+		cmds[i+1].Stdin = r
+	}
+	cmds[len(cmds)-1].Stdout = stdout
+
+	errs := make([]error, len(cmds))
+	for i, cmd := range cmds {
+		errs[i] = cmd.Start()
+	}
+	// The commands hold their own ends of the pipes now; the next command
+	// only sees the end of its input once these copies are closed too.
+	for _, f := range parentEnds {
+		f.Close()
+	}
+	parentEnds = nil
+	for i, cmd := range cmds {
+		if errs[i] == nil {
+			errs[i] = cmd.Wait()
+		}
+	}
+
+	if c.Err() != nil {
+		return contextError("sh", c, env)
+	}
+	for i, err := range errs {
+		if err == nil || (i < len(cmds)-1 && brokenPipe(err)) {
+			continue
+		}
+		message := object.StageString(p.Stages[i]) + ": " + err.Error()
+		if detail := strings.TrimSpace(stderrs[i].String()); detail != "" {
+			message += ": " + detail
+		}
+		return newStdlibError("CommandError", message, env, position)
+	}
+	for i := range stderrs {
+		stderrs[i].WriteTo(contextFor(env).Stderr())
+	}
+	return NONE
+}
+
+// brokenPipe reports whether err is a command ending from writing to a
+// pipe nothing reads any more.
+func brokenPipe(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == syscall.SIGPIPE
+}
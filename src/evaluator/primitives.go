@@ -0,0 +1,61 @@
+package evaluator
+
+import (
+	"strings"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// primitiveGrimoires names the standard library grimoire whose spells are
+// the methods of each kind of plain value.
+var primitiveGrimoires = map[object.ObjectType]string{
+	object.STRING_OBJ:      "String",
+	object.ARRAY_OBJ:       "Array",
+	object.HASH_OBJ:        "Hash",
+	object.INTEGER_OBJ:     "Integer",
+	object.BIG_INTEGER_OBJ: "Integer",
+	object.FLOAT_OBJ:       "Float",
+}
+
+// primitiveMember looks up a public spell of the grimoire of value in the
+// standard library, bound to an instance of it wrapping value, so that
+// strings, numbers, arrays and hashes have methods without being instances
+// themselves. The wrapper shares value, so spells changing an array or a
+// hash change the original. It reports false if there is no such spell.
+func primitiveMember(value object.Object, name string, env *object.Environment) (object.Object, bool) {
+	grimoireName, ok := primitiveGrimoires[value.Type()]
+	if !ok {
+		return nil, false
+	}
+	obj, _ := getGlobalEnv(env).Get(grimoireName)
+	grimoire, ok := obj.(*object.Grimoire)
+	if !ok {
+		return nil, false
+	}
+	method, ok := grimoire.Methods[name]
+	if !ok {
+		method, ok = inheritedMethod(grimoire, name)
+	}
+	if !ok || method.IsPrivate || method.IsProtected {
+		return nil, false
+	}
+	wrapper := evalCallExpression(grimoire, []object.Object{value}, env)
+	instance, ok := wrapper.(*object.Instance)
+	if !ok {
+		return wrapper, true
+	}
+	return &object.BoundMethod{Instance: instance, Method: method}, true
+}
+
+// primitiveMethod is primitiveMember for a value with no methods of its
+// own, an error if its grimoire has no such spell.
+func primitiveMethod(value object.Object, name string, env *object.Environment) object.Object {
+	if method, ok := primitiveMember(value, name, env); ok {
+		return method
+	}
+	kind := strings.ToLower(string(value.Type()))
+	if value.Type() == object.BIG_INTEGER_OBJ {
+		kind = "integer"
+	}
+	return newAttributeError("%s has no method '%s'", kind, name)
+}
@@ -0,0 +1,174 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime/metrics"
+	"runtime/pprof"
+	"sort"
+	"time"
+)
+
+// ProfileRoot is the name time spent outside of any call is reported under.
+const ProfileRoot = "<main>"
+
+// ProfileEntry is what a Profiler measured for one spell or builtin.
+type ProfileEntry struct {
+	Name       string
+	Calls      int
+	Total      time.Duration // time from call to return, counted once for recursive calls
+	Self       time.Duration // Total less the time spent in the calls it made
+	SelfAllocs uint64        // bytes allocated while it, rather than a callee, was running
+}
+
+// Profiler measures where a program spends its time by timing the
+// interpreter's call frames: each PushCallFrame starts a measurement that the
+// matching PopCallFrame ends. Attach one with EvalContext.SetProfiler.
+type Profiler struct {
+	// Labels makes Go's own CPU profiler tag its samples with the name of the
+	// running spell, so a pprof profile of the interpreter can be broken down
+	// by Carrion spell with -tagfocus or -tags.
+	Labels bool
+
+	entries map[string]*ProfileEntry
+	active  map[string]int // how many calls of each name are in progress
+	stack   []profileFrame
+	sample  []metrics.Sample
+}
+
+type profileFrame struct {
+	entry       *ProfileEntry
+	start       time.Time
+	allocs      uint64
+	childTime   time.Duration
+	childAllocs uint64
+}
+
+// NewProfiler returns a profiler whose measurement of ProfileRoot starts
+// now.
+func NewProfiler() *Profiler {
+	p := &Profiler{
+		entries: make(map[string]*ProfileEntry),
+		active:  make(map[string]int),
+		sample:  []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}},
+	}
+	p.enter(ProfileRoot)
+	return p
+}
+
+// SetProfiler attaches p to the interpreter, or detaches the current
+// profiler if p is nil.
+func (ctx *EvalContext) SetProfiler(p *Profiler) {
+	ctx.profiler = p
+}
+
+func (p *Profiler) allocated() uint64 {
+	metrics.Read(p.sample)
+	if p.sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return p.sample[0].Value.Uint64()
+}
+
+func (p *Profiler) enter(name string) {
+	entry, ok := p.entries[name]
+	if !ok {
+		entry = &ProfileEntry{Name: name}
+		p.entries[name] = entry
+	}
+	entry.Calls++
+	p.active[name]++
+	p.stack = append(p.stack, profileFrame{entry: entry, start: time.Now(), allocs: p.allocated()})
+	if p.Labels {
+		pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("spell", name)))
+	}
+}
+
+func (p *Profiler) exit() {
+	if len(p.stack) == 0 {
+		return
+	}
+	frame := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+	elapsed := time.Since(frame.start)
+	allocs := p.allocated() - frame.allocs
+
+	entry := frame.entry
+	entry.Self += elapsed - frame.childTime
+	entry.SelfAllocs += allocs - frame.childAllocs
+	p.active[entry.Name]--
+	if p.active[entry.Name] == 0 {
+		entry.Total += elapsed
+	}
+	if len(p.stack) > 0 {
+		parent := &p.stack[len(p.stack)-1]
+		parent.childTime += elapsed
+		parent.childAllocs += allocs
+		if p.Labels {
+			pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("spell", parent.entry.Name)))
+		}
+	} else if p.Labels {
+		pprof.SetGoroutineLabels(context.Background())
+	}
+}
+
+// Stop ends every measurement still in progress, including ProfileRoot.
+func (p *Profiler) Stop() {
+	for len(p.stack) > 0 {
+		p.exit()
+	}
+}
+
+// Entries returns the measurements, most self time first.
+func (p *Profiler) Entries() []ProfileEntry {
+	entries := make([]ProfileEntry, 0, len(p.entries))
+	for _, entry := range p.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Self != entries[j].Self {
+			return entries[i].Self > entries[j].Self
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// WriteReport stops the profiler and writes its measurements to w as a
+// table, most self time first.
+func (p *Profiler) WriteReport(w io.Writer) {
+	p.Stop()
+	var total time.Duration
+	if root, ok := p.entries[ProfileRoot]; ok {
+		total = root.Total
+	}
+
+	fmt.Fprintf(w, "profile: %s total\n", roundDuration(total))
+	fmt.Fprintf(w, "%10s %7s %10s %9s %10s  %s\n", "self", "self%", "total", "calls", "alloc", "name")
+	for _, entry := range p.Entries() {
+		percent := 0.0
+		if total > 0 {
+			percent = 100 * float64(entry.Self) / float64(total)
+		}
+		fmt.Fprintf(w, "%10s %6.1f%% %10s %9d %10s  %s\n",
+			roundDuration(entry.Self), percent, roundDuration(entry.Total),
+			entry.Calls, formatBytes(entry.SelfAllocs), entry.Name)
+	}
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+func formatBytes(n uint64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
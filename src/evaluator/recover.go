@@ -0,0 +1,45 @@
+package evaluator
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// ReportGoStack makes recovered panics carry the Go stack as well as the
+// Carrion one, which is what a bug report against the interpreter needs.
+var ReportGoStack bool
+
+// SafeEval evaluates node like Eval, but converts a Go panic raised by the
+// interpreter into an *object.InternalError instead of crashing the host.
+func SafeEval(node ast.Node, env *object.Environment) (result object.Object) {
+	ctx := contextFor(env)
+	depth := len(ctx.callStack)
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		internal := &object.InternalError{
+			Message:    fmt.Sprint(r),
+			StackTrace: ctx.GetCallStack(),
+		}
+		goStack := ctx.notePanicked(internal)
+		if ReportGoStack {
+			if goStack == "" {
+				goStack = string(debug.Stack())
+			}
+			internal.GoStack = goStack
+		}
+		// Frames pushed by the aborted calls were never popped.
+		for len(ctx.callStack) > depth {
+			ctx.PopCallFrame()
+		}
+		result = internal
+	}()
+
+	return Eval(node, env)
+}
@@ -0,0 +1,415 @@
+package evaluator
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// The rpc module lets one Carrion process call spells exposed by another
+// over a unix socket. It is registered from init because serving a call
+// runs the exposed spell through the evaluator.
+func init() {
+	builtinModules["rpc"] = newBuiltinModule(map[string]object.Object{
+		"listen":  &object.Builtin{EnvFn: rpcListen},
+		"connect": &object.Builtin{EnvFn: rpcConnect},
+	})
+}
+
+// wireValue is how a value travels between processes: one JSON object per
+// value, tagged with its kind so that integers, floats and tuples survive
+// the trip. Items holds the elements of a list or tuple, the keys and values
+// of a hash alternately, or the field values of a @data instance, whose
+// grimoire is named by Name.
+type wireValue struct {
+	Kind  string      `json:"kind"`
+	Bool  bool        `json:"bool,omitempty"`
+	Int   int64       `json:"int,omitempty"`
+	Float float64     `json:"float,omitempty"`
+	Str   string      `json:"str,omitempty"`
+	Bytes []byte      `json:"bytes,omitempty"`
+	Name  string      `json:"name,omitempty"`
+	Items []wireValue `json:"items,omitempty"`
+}
+
+// rpcRequest asks for the names of the exposed spells when Spell is empty,
+// and calls Spell otherwise.
+type rpcRequest struct {
+	Spell string      `json:"spell,omitempty"`
+	Args  []wireValue `json:"args,omitempty"`
+}
+
+type rpcResponse struct {
+	Names  []string   `json:"names,omitempty"`
+	Result *wireValue `json:"result,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// encodeWire converts a value for sending. Spells, grimoires, ordinary
+// instances and other values that only make sense inside one process are
+// refused.
+func encodeWire(obj object.Object) (wireValue, error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return wireValue{Kind: "int", Int: obj.Value}, nil
+	case *object.BigInteger:
+		return wireValue{Kind: "bigint", Str: obj.Value.String()}, nil
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return wireValue{}, fmt.Errorf("cannot send %v", obj.Value)
+		}
+		return wireValue{Kind: "float", Float: obj.Value}, nil
+	case *object.String:
+		return wireValue{Kind: "str", Str: obj.Value}, nil
+	case *object.Bytes:
+		return wireValue{Kind: "bytes", Bytes: obj.Value}, nil
+	case *object.Boolean:
+		return wireValue{Kind: "bool", Bool: obj.Value}, nil
+	case *object.Array:
+		return encodeWireItems("list", "", obj.Elements)
+	case *object.Tuple:
+		return encodeWireItems("tuple", "", obj.Elements)
+	case *object.Hash:
+		keys := make([]object.HashKey, 0, len(obj.Pairs))
+		for key := range obj.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return obj.Pairs[keys[i]].Key.Inspect() < obj.Pairs[keys[j]].Key.Inspect()
+		})
+		items := make([]object.Object, 0, 2*len(keys))
+		for _, key := range keys {
+			items = append(items, obj.Pairs[key].Key, obj.Pairs[key].Value)
+		}
+		return encodeWireItems("hash", "", items)
+	case *object.Instance:
+		if obj.Grimoire.IsData {
+			return encodeWireItems("data", obj.Grimoire.Name, obj.FieldValues())
+		}
+	}
+	if obj == nil || obj.Type() == object.NONE_OBJ {
+		return wireValue{Kind: "none"}, nil
+	}
+	return wireValue{}, fmt.Errorf("cannot send %s", obj.Type())
+}
+
+func encodeWireItems(kind, name string, elements []object.Object) (wireValue, error) {
+	items := make([]wireValue, len(elements))
+	for i, element := range elements {
+		item, err := encodeWire(element)
+		if err != nil {
+			return wireValue{}, err
+		}
+		items[i] = item
+	}
+	return wireValue{Kind: kind, Name: name, Items: items}, nil
+}
+
+// decodeWire rebuilds a received value. A @data instance is rebuilt by
+// calling the grimoire of the same name in the receiving program, and
+// becomes a hash of its fields if there is none.
+func decodeWire(w wireValue, env *object.Environment) object.Object {
+	switch w.Kind {
+	case "none":
+		return object.NONE
+	case "int":
+		return &object.Integer{Value: w.Int}
+	case "bigint":
+		n, ok := new(big.Int).SetString(w.Str, 10)
+		if !ok {
+			return newError("rpc: bad integer %q", w.Str)
+		}
+		return object.NewInt(n)
+	case "float":
+		return &object.Float{Value: w.Float}
+	case "str":
+		return &object.String{Value: w.Str}
+	case "bytes":
+		return &object.Bytes{Value: w.Bytes}
+	case "bool":
+		return nativeBoolToBooleanObject(w.Bool)
+	}
+
+	items := make([]object.Object, len(w.Items))
+	for i, item := range w.Items {
+		items[i] = decodeWire(item, env)
+		if isError(items[i]) {
+			return items[i]
+		}
+	}
+	switch w.Kind {
+	case "list":
+		return &object.Array{Elements: items}
+	case "tuple":
+		return &object.Tuple{Elements: items}
+	case "hash":
+		return wireHash(items)
+	case "data":
+		if obj, ok := getGlobalEnv(env).Get(w.Name); ok {
+			if grimoire, ok := obj.(*object.Grimoire); ok && grimoire.IsData && len(grimoire.Fields) == len(items) {
+				return evalCallExpression(grimoire, items, env)
+			}
+		}
+		return wireHash(items)
+	}
+	return newError("rpc: unknown value kind %q", w.Kind)
+}
+
+func wireHash(items []object.Object) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		hashable, ok := items[i].(object.Hashable)
+		if !ok {
+			return newTypeError("rpc: unusable as hash key: %s", items[i].Type())
+		}
+		pairs[hashable.HashKey()] = object.HashPair{Key: items[i], Value: items[i+1]}
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
+// rpcListen implements rpc.listen(path, spells). It binds a unix socket at
+// path, replacing a stale socket file left by an earlier server, and
+// returns a server whose serve() answers calls to the spells, a hash of
+// names to anything callable.
+func rpcListen(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("rpc.listen requires 2 arguments: path, spells")
+	}
+	path, ok := args[0].(*object.String)
+	if !ok {
+		return newError("rpc.listen expects the socket path as a STRING, got %s", args[0].Type())
+	}
+	table, ok := args[1].(*object.Hash)
+	if !ok {
+		return newError("rpc.listen expects the spells as a HASH of names, got %s", args[1].Type())
+	}
+	spells := make(map[string]object.Object, len(table.Pairs))
+	for _, pair := range table.Pairs {
+		name, ok := pair.Key.(*object.String)
+		if !ok {
+			return newError("rpc.listen: spell names must be strings, got %s", pair.Key.Type())
+		}
+		spells[name.Value] = pair.Value
+	}
+
+	if info, err := os.Stat(path.Value); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path.Value); err == nil {
+			conn.Close()
+			return newError("rpc.listen: %s is in use", path.Value)
+		}
+		os.Remove(path.Value)
+	}
+	listener, err := net.Listen("unix", path.Value)
+	if err != nil {
+		return newError("rpc.listen: %s", err)
+	}
+	server := &rpcServer{listener: listener, spells: spells}
+	ctx := contextFor(env)
+	handle := ctx.openHandle("listener", path.Value)
+
+	return newBuiltinModule(map[string]object.Object{
+		"serve": &object.Builtin{EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("serve takes no arguments")
+			}
+			return server.serve(env, false)
+		}},
+		"accept": &object.Builtin{EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("accept takes no arguments")
+			}
+			return server.serve(env, true)
+		}},
+		"close": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			listener.Close()
+			ctx.closeHandle(handle)
+			return NONE
+		}},
+	})
+}
+
+type rpcServer struct {
+	listener net.Listener
+	spells   map[string]object.Object
+}
+
+// serve answers clients one at a time, so exposed spells never run
+// concurrently with each other or with the rest of the program. It returns
+// after the first client disconnects if once is set, and otherwise when the
+// server is closed.
+func (s *rpcServer) serve(env *object.Environment, once bool) object.Object {
+	for {
+		conn, err := s.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return NONE
+		}
+		if err != nil {
+			return newError("rpc: %s", err)
+		}
+		s.handle(conn, env)
+		if once {
+			return NONE
+		}
+	}
+}
+
+func (s *rpcServer) handle(conn io.ReadWriteCloser, env *object.Environment) {
+	defer conn.Close()
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	enc := json.NewEncoder(conn)
+	for {
+		var req rpcRequest
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		if err := enc.Encode(s.call(req, env)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *rpcServer) call(req rpcRequest, env *object.Environment) rpcResponse {
+	if req.Spell == "" {
+		names := make([]string, 0, len(s.spells))
+		for name := range s.spells {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return rpcResponse{Names: names}
+	}
+	fn, ok := s.spells[req.Spell]
+	if !ok {
+		return rpcResponse{Error: fmt.Sprintf("no spell %q", req.Spell)}
+	}
+	args := make([]object.Object, len(req.Args))
+	for i, arg := range req.Args {
+		args[i] = decodeWire(arg, env)
+		if isError(args[i]) {
+			return rpcResponse{Error: errorMessage(args[i])}
+		}
+	}
+	result := evalCallExpression(fn, args, env)
+	if isError(result) {
+		return rpcResponse{Error: errorMessage(result)}
+	}
+	w, err := encodeWire(result)
+	if err != nil {
+		return rpcResponse{Error: fmt.Sprintf("%s returned a value that %s", req.Spell, err)}
+	}
+	return rpcResponse{Result: &w}
+}
+
+// errorMessage is the text sent to the client for an error raised by an
+// exposed spell.
+func errorMessage(obj object.Object) string {
+	switch err := obj.(type) {
+	case *object.Error:
+		return err.Message
+	case *object.CustomError:
+		return err.Name + ": " + err.Message
+	}
+	return obj.Inspect()
+}
+
+// rpcConnect implements rpc.connect(path). It returns a namespace with a
+// member for each spell the server exposes, which sends its arguments to
+// the server and returns the result, and close() to hang up. An error in
+// the remote spell, or in reaching the server, is raised as an RPCError; a
+// call still waiting when the current context is done raises its error.
+func rpcConnect(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("rpc.connect requires 1 argument: path")
+	}
+	path, ok := args[0].(*object.String)
+	if !ok {
+		return newError("rpc.connect expects the socket path as a STRING, got %s", args[0].Type())
+	}
+	position := contextFor(env).CurrentPosition()
+	conn, err := net.Dial("unix", path.Value)
+	if err != nil {
+		return newStdlibError("RPCError", fmt.Sprintf("cannot connect to %s: %s", path.Value, err), env, position)
+	}
+	client := &rpcClient{conn: conn, dec: json.NewDecoder(bufio.NewReader(conn)), enc: json.NewEncoder(conn)}
+
+	resp, err := client.roundTrip(rpcRequest{})
+	if err != nil {
+		conn.Close()
+		return newStdlibError("RPCError", fmt.Sprintf("%s: %s", path.Value, err), env, position)
+	}
+	members := make(map[string]object.Object, len(resp.Names)+1)
+	for _, name := range resp.Names {
+		members[name] = &object.Builtin{EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			return client.call(name, args, env)
+		}}
+	}
+	ctx := contextFor(env)
+	handle := ctx.openHandle("socket", path.Value)
+	members["close"] = &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		conn.Close()
+		ctx.closeHandle(handle)
+		return NONE
+	}}
+	return newBuiltinModule(members)
+}
+
+type rpcClient struct {
+	conn io.Closer
+	dec  *json.Decoder
+	enc  *json.Encoder
+}
+
+func (c *rpcClient) roundTrip(req rpcRequest) (rpcResponse, error) {
+	var resp rpcResponse
+	if err := c.enc.Encode(req); err != nil {
+		return resp, err
+	}
+	if err := c.dec.Decode(&resp); err != nil {
+		return resp, fmt.Errorf("connection lost: %w", err)
+	}
+	return resp, nil
+}
+
+func (c *rpcClient) call(name string, args []object.Object, env *object.Environment) object.Object {
+	position := contextFor(env).CurrentPosition()
+	req := rpcRequest{Spell: name, Args: make([]wireValue, len(args))}
+	for i, arg := range args {
+		w, err := encodeWire(arg)
+		if err != nil {
+			return newStdlibError("RPCError", fmt.Sprintf("%s: argument %d: %s", name, i+1, err), env, position)
+		}
+		req.Args[i] = w
+	}
+
+	// A call still waiting when the current context is done hangs up,
+	// since the reply could no longer be matched to its request.
+	done := contextFor(env).Context()
+	if done.Err() != nil {
+		return contextError(name, done, env)
+	}
+	stop := context.AfterFunc(done, func() { c.conn.Close() })
+	resp, err := c.roundTrip(req)
+	stop()
+	if err != nil && done.Err() != nil {
+		return contextError(name, done, env)
+	}
+	if err != nil {
+		return newStdlibError("RPCError", fmt.Sprintf("%s: %s", name, err), env, position)
+	}
+	if resp.Error != "" {
+		return newStdlibError("RPCError", fmt.Sprintf("%s: %s", name, resp.Error), env, position)
+	}
+	if resp.Result == nil {
+		return NONE
+	}
+	return decodeWire(*resp.Result, env)
+}
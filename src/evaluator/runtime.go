@@ -0,0 +1,174 @@
+package evaluator
+
+import (
+	"runtime"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+var runtimeModule = map[string]object.Object{
+	"stats": &object.Builtin{
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("runtime.stats takes no arguments, got=%d", len(args))
+			}
+			return runtimeStats(env)
+		},
+	},
+	"open_handles": &object.Builtin{EnvFn: runtimeOpenHandles},
+}
+
+// runtimeStats reports what the interpreter is holding on to: the Carrion
+// objects and scopes reachable from env, which includes every global, how
+// deeply env is nested and what the Go runtime has allocated.
+func runtimeStats(env *object.Environment) *object.Hash {
+	w := &heapWalker{
+		objects: make(map[object.Object]bool),
+		envs:    make(map[*object.Environment]bool),
+		counts:  make(map[string]int64),
+	}
+	w.env(env)
+
+	objects := make(map[string]object.Object, len(w.counts))
+	var total int64
+	for typ, n := range w.counts {
+		objects[typ] = &object.Integer{Value: n}
+		total += n
+	}
+
+	depth := 0
+	for scope := env; scope.GetOuter() != nil; scope = scope.GetOuter() {
+		depth++
+	}
+
+	// The call to runtime.stats is on the stack too; leave it out.
+	calls := max(contextFor(env).Depth()-1, 0)
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return newStringHash(map[string]object.Object{
+		"objects":       newStringHash(objects),
+		"total_objects": &object.Integer{Value: total},
+		"environments":  &object.Integer{Value: int64(len(w.envs))},
+		"env_depth":     &object.Integer{Value: int64(depth)},
+		"call_depth":    &object.Integer{Value: int64(calls)},
+		"memory": newStringHash(map[string]object.Object{
+			"heap_alloc":   &object.Integer{Value: int64(mem.HeapAlloc)},
+			"heap_objects": &object.Integer{Value: int64(mem.HeapObjects)},
+			"total_alloc":  &object.Integer{Value: int64(mem.TotalAlloc)},
+			"sys":          &object.Integer{Value: int64(mem.Sys)},
+			"num_gc":       &object.Integer{Value: int64(mem.NumGC)},
+			"goroutines":   &object.Integer{Value: int64(runtime.NumGoroutine())},
+		}),
+	})
+}
+
+// heapWalker counts the distinct objects and environments reachable from a
+// scope. Weak references are not followed, since they do not keep their
+// target alive.
+type heapWalker struct {
+	objects map[object.Object]bool
+	envs    map[*object.Environment]bool
+	counts  map[string]int64
+}
+
+func (w *heapWalker) env(env *object.Environment) {
+	for ; env != nil && !w.envs[env]; env = env.GetOuter() {
+		w.envs[env] = true
+		for _, name := range env.GetNames() {
+			value, _ := env.GetLocal(name)
+			w.object(value)
+		}
+	}
+}
+
+func (w *heapWalker) object(obj object.Object) {
+	if obj == nil || w.objects[obj] {
+		return
+	}
+	w.objects[obj] = true
+	w.counts[string(obj.Type())]++
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		for _, element := range obj.Elements {
+			w.object(element)
+		}
+	case *object.Tuple:
+		for _, element := range obj.Elements {
+			w.object(element)
+		}
+	case *object.Hash:
+		for _, pair := range obj.Pairs {
+			w.object(pair.Key)
+			w.object(pair.Value)
+		}
+	case *object.Record:
+		for _, value := range obj.Values {
+			w.object(value)
+		}
+	case *object.Range:
+		w.object(obj.Start)
+		w.object(obj.End)
+	case *object.Function:
+		for _, value := range obj.OnceDefaults {
+			w.object(value)
+		}
+		w.env(obj.Env)
+	case *object.BoundMethod:
+		w.instance(obj.Instance)
+		w.function(obj.Method)
+	case *object.Instance:
+		w.grimoire(obj.Grimoire)
+		for _, value := range obj.Slots {
+			w.object(value)
+		}
+		w.env(obj.Env)
+	case *object.Grimoire:
+		for _, method := range obj.Methods {
+			w.function(method)
+		}
+		w.function(obj.InitMethod)
+		w.grimoire(obj.Inherits)
+		w.env(obj.Env)
+	case *object.Namespace:
+		w.env(obj.Env)
+	case *object.CustomError:
+		for _, value := range obj.Details {
+			w.object(value)
+		}
+		w.grimoire(obj.ErrorType)
+		w.instance(obj.Instance)
+	}
+}
+
+// The helpers below keep nil pointers from reaching object as non-nil
+// interfaces.
+
+func (w *heapWalker) function(fn *object.Function) {
+	if fn != nil {
+		w.object(fn)
+	}
+}
+
+func (w *heapWalker) grimoire(g *object.Grimoire) {
+	if g != nil {
+		w.object(g)
+	}
+}
+
+func (w *heapWalker) instance(i *object.Instance) {
+	if i != nil {
+		w.object(i)
+	}
+}
+
+// newStringHash builds a hash with string keys.
+func newStringHash(members map[string]object.Object) *object.Hash {
+	pairs := make(map[object.HashKey]object.HashPair, len(members))
+	for name, value := range members {
+		key := &object.String{Value: name}
+		pairs[key.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+	return &object.Hash{Pairs: pairs}
+}
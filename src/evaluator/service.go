@@ -0,0 +1,333 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// Helpers for running a script as a Unix service: handlers for signals,
+// a context that is cancelled when the service is asked to stop, pidfiles
+// and detaching from the terminal. The OS grimoire wraps them as
+// on_signal, shutdown_context, shutting_down, write_pidfile, read_pidfile,
+// remove_pidfile, pid and daemonize.
+func init() {
+	builtins["osOnSignal"] = &object.Builtin{EnvFn: osOnSignal}
+	builtins["osShutdownContext"] = &object.Builtin{Fn: osShutdownContext}
+	builtins["osShuttingDown"] = &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return nativeBoolToBooleanObject(shutdownStarted.Load())
+	}}
+	builtins["osWritePidfile"] = &object.Builtin{Fn: osWritePidfile}
+	builtins["osReadPidfile"] = &object.Builtin{Fn: osReadPidfile}
+	builtins["osRemovePidfile"] = &object.Builtin{Fn: osRemovePidfile}
+	builtins["osPid"] = &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return &object.Integer{Value: int64(os.Getpid())}
+	}}
+	builtins["osDaemonize"] = &object.Builtin{Fn: osDaemonize}
+}
+
+// A spell given to on_signal cannot run when the signal arrives, since the
+// interpreter may be in the middle of anything. The signal is queued
+// instead, and the handler runs between two statements of the interpreter
+// that registered it, as Python runs its signal handlers.
+var (
+	signalMu       sync.Mutex
+	signalHandlers = map[os.Signal]signalHandler{}
+	signalQueue    []os.Signal
+	signalsPending atomic.Bool // whether signalQueue may hold anything
+	signalCh       chan os.Signal
+
+	shutdownCtx     context.Context
+	shutdownCancel  context.CancelFunc
+	shutdownStarted atomic.Bool
+)
+
+type signalHandler struct {
+	spell object.Object
+	env   *object.Environment
+	ctx   *EvalContext
+}
+
+// shutdownSignals are the signals that ask a service to stop.
+var shutdownSignals = []os.Signal{os.Interrupt, sigTERM}
+
+// parseSignal looks a signal up by name, with or without SIG and in any
+// case, so "TERM", "sigterm" and "SIGTERM" are all SIGTERM.
+func parseSignal(fn string, arg object.Object) (os.Signal, object.Object) {
+	name, ok := arg.(*object.String)
+	if !ok {
+		return nil, newError("%s: signal must be a STRING such as \"TERM\", got %s", fn, arg.Type())
+	}
+	sig, ok := signalNames[strings.TrimPrefix(strings.ToUpper(name.Value), "SIG")]
+	if !ok {
+		return nil, newError("%s: unknown signal %q", fn, name.Value)
+	}
+	return sig, nil
+}
+
+// watchSignal starts delivering sig to dispatchSignals.
+func watchSignal(sig os.Signal) {
+	if signalCh == nil {
+		signalCh = make(chan os.Signal, 16)
+		go dispatchSignals()
+	}
+	signal.Notify(signalCh, sig)
+}
+
+func dispatchSignals() {
+	for sig := range signalCh {
+		signalMu.Lock()
+		_, handled := signalHandlers[sig]
+		if handled {
+			signalQueue = append(signalQueue, sig)
+			signalsPending.Store(true)
+		}
+		stopping := shutdownCtx != nil && isShutdownSignal(sig)
+		signalMu.Unlock()
+
+		if stopping {
+			// A second request to stop, with nothing else to handle
+			// it, ends the program at once, for a service stuck
+			// shutting down.
+			if shutdownStarted.Swap(true) && !handled {
+				os.Exit(128 + signalNumber(sig))
+			}
+			shutdownCancel()
+		}
+	}
+}
+
+func isShutdownSignal(sig os.Signal) bool {
+	for _, s := range shutdownSignals {
+		if sig == s {
+			return true
+		}
+	}
+	return false
+}
+
+// osOnSignal implements osOnSignal(signal, handler). The handler is called
+// with the signal's name each time it arrives; a handler of None restores
+// what the signal does by default.
+func osOnSignal(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("osOnSignal requires 2 arguments: signal, handler")
+	}
+	sig, errObj := parseSignal("osOnSignal", args[0])
+	if errObj != nil {
+		return errObj
+	}
+	signalMu.Lock()
+	defer signalMu.Unlock()
+	if args[1].Type() == object.NONE_OBJ {
+		delete(signalHandlers, sig)
+		if shutdownCtx == nil || !isShutdownSignal(sig) {
+			signal.Reset(sig)
+		}
+		return NONE
+	}
+	if callableBuiltin(args[1]) != TRUE {
+		return newError("osOnSignal: handler must be a spell, got %s", args[1].Type())
+	}
+	signalHandlers[sig] = signalHandler{spell: args[1], env: env, ctx: contextFor(env)}
+	watchSignal(sig)
+	return NONE
+}
+
+// checkSignals is called before each statement, and runs the handlers of
+// any signals that have arrived since the last.
+func checkSignals(env *object.Environment) object.Object {
+	if !signalsPending.Load() {
+		return nil
+	}
+	return runSignalHandlers(env)
+}
+
+// runSignalHandlers calls the handlers of the signals that have arrived,
+// if they belong to the interpreter running env. An error from a handler
+// is raised where the interpreter had got to.
+func runSignalHandlers(env *object.Environment) object.Object {
+	ctx := contextFor(env)
+	signalMu.Lock()
+	var due []signalHandler
+	var names []string
+	rest := signalQueue[:0]
+	for _, sig := range signalQueue {
+		h, ok := signalHandlers[sig]
+		switch {
+		case !ok:
+		case h.ctx != ctx:
+			rest = append(rest, sig)
+		default:
+			due = append(due, h)
+			names = append(names, signalName(sig))
+		}
+	}
+	signalQueue = rest
+	signalsPending.Store(len(rest) > 0)
+	signalMu.Unlock()
+
+	for i, h := range due {
+		result := evalCallExpression(h.spell, []object.Object{&object.String{Value: names[i]}}, h.env)
+		if isError(result) {
+			return result
+		}
+	}
+	return nil
+}
+
+// signalName is the name a handler is given, such as "TERM".
+func signalName(sig os.Signal) string {
+	for name, s := range signalNames {
+		if s == sig {
+			return name
+		}
+	}
+	return sig.String()
+}
+
+// osShutdownContext implements osShutdownContext(), a CONTEXT that is
+// cancelled when the program is sent SIGTERM or interrupted. Passing it to
+// sleeps, commands and servers lets a service stop what it is waiting on
+// and shut down cleanly.
+func osShutdownContext(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newTypeError("osShutdownContext takes no arguments")
+	}
+	signalMu.Lock()
+	defer signalMu.Unlock()
+	if shutdownCtx == nil {
+		shutdownCtx, shutdownCancel = context.WithCancel(context.Background())
+		for _, sig := range shutdownSignals {
+			watchSignal(sig)
+		}
+	}
+	return &object.Context{Value: shutdownCtx}
+}
+
+// osWritePidfile implements osWritePidfile(path), which records this
+// process's ID at path. It fails if the file names another process that is
+// still running, so two copies of a service cannot start; a file left by a
+// process that has died is replaced.
+func osWritePidfile(args ...object.Object) object.Object {
+	values, errObj := stringArgs("osWritePidfile", args, 1)
+	if errObj != nil {
+		return errObj
+	}
+	path := values[0]
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return newError("osWritePidfile: failed to write '%s': %s", path, err)
+			}
+			return NONE
+		}
+		if !errors.Is(err, os.ErrExist) || attempt > 0 {
+			return newError("osWritePidfile: failed to write '%s': %s", path, err)
+		}
+		if pid, ok := readPid(path); ok && pid != os.Getpid() && processAlive(pid) {
+			return newError("osWritePidfile: '%s' names process %d, which is still running", path, pid)
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return newError("osWritePidfile: failed to replace '%s': %s", path, err)
+		}
+	}
+}
+
+// osReadPidfile implements osReadPidfile(path), the ID of the running
+// process a pidfile names, or None if there is no such file or the
+// process has died.
+func osReadPidfile(args ...object.Object) object.Object {
+	values, errObj := stringArgs("osReadPidfile", args, 1)
+	if errObj != nil {
+		return errObj
+	}
+	if pid, ok := readPid(values[0]); ok && processAlive(pid) {
+		return &object.Integer{Value: int64(pid)}
+	}
+	return NONE
+}
+
+// osRemovePidfile implements osRemovePidfile(path), which removes a
+// pidfile if it names this process and reports whether it did.
+func osRemovePidfile(args ...object.Object) object.Object {
+	values, errObj := stringArgs("osRemovePidfile", args, 1)
+	if errObj != nil {
+		return errObj
+	}
+	if pid, ok := readPid(values[0]); !ok || pid != os.Getpid() {
+		return FALSE
+	}
+	if err := os.Remove(values[0]); err != nil {
+		return newError("osRemovePidfile: failed to remove '%s': %s", values[0], err)
+	}
+	return TRUE
+}
+
+func readPid(path string) (int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid, err == nil && pid > 0
+}
+
+// daemonEnv marks the copy of a program that osDaemonize starts, so that
+// its own call to osDaemonize carries on instead of starting another.
+const daemonEnv = "CARRION_DAEMON"
+
+// osDaemonize implements osDaemonize([log]). It starts the program again
+// in the background, in a session of its own and with its output going to
+// log, or nowhere, and ends this copy. In the background copy it returns
+// None, so the program goes on from there; as the program runs again from
+// the start, it should be called before the program does anything else.
+func osDaemonize(args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError("osDaemonize takes at most 1 argument: [log]")
+	}
+	if os.Getenv(daemonEnv) == "1" {
+		return NONE
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return newError("osDaemonize: %s", err)
+	}
+	cmd := exec.Command(self, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), daemonEnv+"=1")
+	cmd.SysProcAttr = detachedProcess()
+	if cmd.SysProcAttr == nil {
+		return newError("osDaemonize is not supported on this platform")
+	}
+	if len(args) == 1 && args[0].Type() != object.NONE_OBJ {
+		path, ok := args[0].(*object.String)
+		if !ok {
+			return newError("osDaemonize: log must be a STRING, got %s", args[0].Type())
+		}
+		log, err := os.OpenFile(path.Value, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return newError("osDaemonize: failed to open '%s': %s", path.Value, err)
+		}
+		defer log.Close()
+		cmd.Stdout, cmd.Stderr = log, log
+	}
+	if err := cmd.Start(); err != nil {
+		return newError("osDaemonize: %s", err)
+	}
+	os.Exit(0)
+	return NONE
+}
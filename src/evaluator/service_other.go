@@ -0,0 +1,36 @@
+//go:build !unix
+
+package evaluator
+
+import (
+	"os"
+	"syscall"
+)
+
+const sigTERM = syscall.SIGTERM
+
+var signalNames = map[string]os.Signal{
+	"INT":  os.Interrupt,
+	"TERM": syscall.SIGTERM,
+}
+
+func signalNumber(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return int(s)
+	}
+	return 0
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// detachedProcess is nil where there are no sessions to detach into.
+func detachedProcess() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,44 @@
+//go:build unix
+
+package evaluator
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+const sigTERM = syscall.SIGTERM
+
+var signalNames = map[string]os.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"TERM":  syscall.SIGTERM,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"ALRM":  syscall.SIGALRM,
+	"CHLD":  syscall.SIGCHLD,
+	"PIPE":  syscall.SIGPIPE,
+	"WINCH": syscall.SIGWINCH,
+}
+
+func signalNumber(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return int(s)
+	}
+	return 0
+}
+
+// processAlive reports whether a process with the given ID exists. A
+// process owned by another user still counts.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// detachedProcess starts a process in a new session, with no controlling
+// terminal.
+func detachedProcess() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
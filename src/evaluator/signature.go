@@ -0,0 +1,119 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+func init() {
+	builtins["signature"] = &object.Builtin{Fn: signatureBuiltin}
+}
+
+// signatureType is the record signature() returns.
+var signatureType = &object.RecordType{
+	Name:   "Signature",
+	Fields: []string{"name", "parameters", "defaults", "varargs"},
+}
+
+// signatureBuiltin implements signature(fn), which describes how fn is
+// called: its name, the names of its parameters in order, a hash of the
+// default values of those that have one, and whether it takes any number
+// of arguments, as builtins do. A grimoire is described by its init and a
+// bound method without self.
+func signatureBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("signature requires 1 argument: spell")
+	}
+	switch fn := args[0].(type) {
+	case *object.Function:
+		return functionSignature(fn.Name, fn)
+	case *object.BoundMethod:
+		return functionSignature(fn.Method.Name, fn.Method)
+	case *object.Grimoire:
+		if fn.InitMethod == nil {
+			return newSignature(fn.Name, nil, nil, false)
+		}
+		return functionSignature(fn.Name, fn.InitMethod)
+	case *object.RecordType:
+		return newSignature(fn.Name, fn.Fields, nil, false)
+	case *object.Builtin:
+		return newSignature("", nil, nil, true)
+	}
+	return newTypeError("signature: %s is not callable", args[0].Type())
+}
+
+func functionSignature(name string, fn *object.Function) object.Object {
+	params := make([]string, len(fn.Parameters))
+	defaults := map[string]object.Object{}
+	var order []string
+	for i, param := range fn.Parameters {
+		params[i] = param.Name.Value
+		switch {
+		case param.Once:
+			defaults[param.Name.Value] = fn.OnceDefaults[param.Name.Value]
+		case param.DefaultValue != nil:
+			value := Eval(param.DefaultValue, fn.Env)
+			if isError(value) {
+				return value
+			}
+			defaults[param.Name.Value] = value
+		default:
+			continue
+		}
+		order = append(order, param.Name.Value)
+	}
+	return newSignature(name, params, signatureDefaults(order, defaults), false)
+}
+
+func signatureDefaults(order []string, defaults map[string]object.Object) *object.Hash {
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair, len(order))}
+	for _, name := range order {
+		key := &object.String{Value: name}
+		hash.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: defaults[name]}
+	}
+	return hash
+}
+
+func newSignature(name string, params []string, defaults *object.Hash, varargs bool) *object.Record {
+	elements := make([]object.Object, len(params))
+	for i, param := range params {
+		elements[i] = &object.String{Value: param}
+	}
+	if defaults == nil {
+		defaults = &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	}
+	var nameObj object.Object = NONE
+	if name != "" {
+		nameObj = &object.String{Value: name}
+	}
+	return &object.Record{
+		RecordType: signatureType,
+		Values:     []object.Object{nameObj, &object.Array{Elements: elements}, defaults, nativeBoolToBooleanObject(varargs)},
+	}
+}
+
+// tooManyArguments is the TypeError for a call of fn with more arguments
+// than it has parameters.
+func tooManyArguments(fn *object.Function, got int, env *object.Environment) object.Object {
+	name := fn.Name
+	if name == "" {
+		name = "spell"
+	}
+	required := 0
+	for _, param := range fn.Parameters {
+		if param.DefaultValue == nil {
+			required++
+		}
+	}
+	limit := "at most "
+	if required == len(fn.Parameters) {
+		limit = ""
+	}
+	noun := "arguments"
+	if len(fn.Parameters) == 1 {
+		noun = "argument"
+	}
+	message := fmt.Sprintf("%s takes %s%d %s, got %d", name, limit, len(fn.Parameters), noun, got)
+	return newStdlibError("TypeError", message, env, contextFor(env).CurrentPosition())
+}
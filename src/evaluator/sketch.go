@@ -0,0 +1,163 @@
+package evaluator
+
+import (
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// The sketch module holds probabilistic structures for large streams of
+// values: a Bloom filter, which tells whether a value may have been seen
+// before, and a HyperLogLog, which estimates how many distinct values there
+// were. Both use a small, fixed amount of memory however many are added.
+func init() {
+	builtinModules["sketch"] = newBuiltinModule(map[string]object.Object{
+		"bloom_filter": &object.Builtin{Fn: newBloomFilter},
+		"hyperloglog":  &object.Builtin{Fn: newHyperLogLog},
+	})
+}
+
+// newBloomFilter implements sketch.bloom_filter(capacity, [error_rate]),
+// sized for capacity values with an error rate of 1% unless given.
+func newBloomFilter(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("sketch.bloom_filter requires 1 or 2 arguments: capacity, [error_rate]")
+	}
+	capacity, ok := args[0].(*object.Integer)
+	if !ok || capacity.Value < 1 {
+		return newError("sketch.bloom_filter: capacity must be a positive INTEGER, got %s", args[0].Inspect())
+	}
+	errorRate := 0.01
+	if len(args) == 2 {
+		rate, errObj := numberArg("sketch.bloom_filter", args[1])
+		if errObj != nil {
+			return errObj
+		}
+		if rate <= 0 || rate >= 1 {
+			return newError("sketch.bloom_filter: error_rate must be between 0 and 1, got %s", args[1].Inspect())
+		}
+		errorRate = rate
+	}
+	return object.NewBloomFilter(int(capacity.Value), errorRate)
+}
+
+// newHyperLogLog implements sketch.hyperloglog([precision]), with
+// 2^precision registers: 14 unless given, for an error of about 0.8%.
+func newHyperLogLog(args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError("sketch.hyperloglog takes at most 1 argument: [precision]")
+	}
+	precision := int64(14)
+	if len(args) == 1 {
+		p, ok := args[0].(*object.Integer)
+		if !ok || p.Value < 4 || p.Value > 18 {
+			return newError("sketch.hyperloglog: precision must be an INTEGER from 4 to 18, got %s", args[0].Inspect())
+		}
+		precision = p.Value
+	}
+	return object.NewHyperLogLog(int(precision))
+}
+
+// sketchHash hashes the argument of a sketch's add or contains.
+func sketchHash(name string, args []object.Object) (uint64, object.Object) {
+	if len(args) != 1 {
+		return 0, newError("%s requires 1 argument: value", name)
+	}
+	h, ok := object.SketchHash(args[0])
+	if !ok {
+		return 0, newTypeError("%s: unusable as hash key: %s", name, args[0].Type())
+	}
+	return h, nil
+}
+
+// bloomFilterMember looks up a member of a Bloom filter: add(value), true
+// if the filter did not already seem to hold value, contains(value),
+// count(), the number of values added, and merge(other).
+func bloomFilterMember(b *object.BloomFilter, name string) object.Object {
+	var fn func(args ...object.Object) object.Object
+	switch name {
+	case "add":
+		fn = func(args ...object.Object) object.Object {
+			h, errObj := sketchHash("add", args)
+			if errObj != nil {
+				return errObj
+			}
+			return nativeBoolToBooleanObject(b.Add(h))
+		}
+	case "contains":
+		fn = func(args ...object.Object) object.Object {
+			h, errObj := sketchHash("contains", args)
+			if errObj != nil {
+				return errObj
+			}
+			return nativeBoolToBooleanObject(b.MayContain(h))
+		}
+	case "count":
+		fn = func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("count takes no arguments")
+			}
+			return &object.Integer{Value: int64(b.Count)}
+		}
+	case "merge":
+		fn = func(args ...object.Object) object.Object {
+			other, ok := singleArg[*object.BloomFilter](args)
+			if !ok {
+				return newError("merge requires 1 argument: a BLOOM_FILTER")
+			}
+			if err := b.Merge(other); err != nil {
+				return newError("merge: %s", err)
+			}
+			return NONE
+		}
+	default:
+		return newError("bloom filter has no member '%s'", name)
+	}
+	return &object.Builtin{Fn: fn}
+}
+
+// hyperLogLogMember looks up a member of a HyperLogLog: add(value),
+// count(), the estimated number of distinct values added, and merge(other).
+func hyperLogLogMember(h *object.HyperLogLog, name string) object.Object {
+	var fn func(args ...object.Object) object.Object
+	switch name {
+	case "add":
+		fn = func(args ...object.Object) object.Object {
+			x, errObj := sketchHash("add", args)
+			if errObj != nil {
+				return errObj
+			}
+			h.Add(x)
+			return NONE
+		}
+	case "count":
+		fn = func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("count takes no arguments")
+			}
+			return &object.Integer{Value: int64(h.Count())}
+		}
+	case "merge":
+		fn = func(args ...object.Object) object.Object {
+			other, ok := singleArg[*object.HyperLogLog](args)
+			if !ok {
+				return newError("merge requires 1 argument: a HYPERLOGLOG")
+			}
+			if err := h.Merge(other); err != nil {
+				return newError("merge: %s", err)
+			}
+			return NONE
+		}
+	default:
+		return newError("hyperloglog has no member '%s'", name)
+	}
+	return &object.Builtin{Fn: fn}
+}
+
+// singleArg gives the only argument in args if it has type T.
+func singleArg[T object.Object](args []object.Object) (T, bool) {
+	if len(args) != 1 {
+		var zero T
+		return zero, false
+	}
+	arg, ok := args[0].(T)
+	return arg, ok
+}
@@ -0,0 +1,81 @@
+package evaluator
+
+import (
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// fieldSlots numbers the fields a grimoire's instances are known to have:
+// its parent's, then those declared in its body, then those its init
+// assigns through self, in the order they appear. Instances keep these in
+// fixed slots; see object.Instance.
+func fieldSlots(node *ast.GrimoireDefinition, parent *object.Grimoire) map[string]int {
+	slots := map[string]int{}
+	add := func(name string) {
+		if _, ok := slots[name]; !ok {
+			slots[name] = len(slots)
+		}
+	}
+	if parent != nil {
+		names := make([]string, len(parent.Slots))
+		for name, n := range parent.Slots {
+			names[n] = name
+		}
+		for _, name := range names {
+			add(name)
+		}
+	}
+	for _, field := range node.Fields {
+		add(field.Name.(*ast.Identifier).Value)
+	}
+	if node.InitMethod != nil {
+		selfAssignments(node.InitMethod.Body, add)
+	}
+	return slots
+}
+
+// selfAssignments calls add with the field of each `self.field = value` in
+// block, including in the blocks of its conditionals, loops, matches and
+// attempts.
+func selfAssignments(block *ast.BlockStatement, add func(string)) {
+	if block == nil {
+		return
+	}
+	for _, stmt := range block.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.AssignStatement:
+			dot, ok := stmt.Name.(*ast.DotExpression)
+			if !ok || stmt.Operator != "=" {
+				continue
+			}
+			if self, ok := dot.Left.(*ast.Identifier); ok && self.Value == "self" {
+				add(dot.Right.Value)
+			}
+		case *ast.IfStatement:
+			selfAssignments(stmt.Consequence, add)
+			for _, branch := range stmt.OtherwiseBranches {
+				selfAssignments(branch.Consequence, add)
+			}
+			selfAssignments(stmt.Alternative, add)
+		case *ast.ForStatement:
+			selfAssignments(stmt.Body, add)
+			selfAssignments(stmt.Alternative, add)
+		case *ast.WhileStatement:
+			selfAssignments(stmt.Body, add)
+			selfAssignments(stmt.Alternative, add)
+		case *ast.MatchStatement:
+			for _, c := range stmt.Cases {
+				selfAssignments(c.Body, add)
+			}
+			if stmt.Default != nil {
+				selfAssignments(stmt.Default.Body, add)
+			}
+		case *ast.AttemptStatement:
+			selfAssignments(stmt.TryBlock, add)
+			for _, clause := range stmt.EnsnareClauses {
+				selfAssignments(clause.Consequence, add)
+			}
+			selfAssignments(stmt.ResolveBlock, add)
+		}
+	}
+}
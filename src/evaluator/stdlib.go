@@ -3,28 +3,45 @@ package evaluator
 import (
 	"fmt"
 	"path/filepath"
+	"sync"
 
+	"github.com/javanhut/Carrion/src/ast"
 	"github.com/javanhut/Carrion/src/lexer"
 	"github.com/javanhut/Carrion/src/munin"
 	"github.com/javanhut/Carrion/src/object"
 	"github.com/javanhut/Carrion/src/parser"
 )
 
-func LoadMuninStdlib(env *object.Environment) error {
-	// 1. List embedded files in the current directory (".")
-	//    if you used //go:embed *.crl with no subdirectory
-	entries, err := munin.MuninFs.ReadDir(".")
-	if err != nil {
-		return fmt.Errorf("failed to read embedded stdlib: %w", err)
-	}
+// muninPrograms holds the parsed standard library. It cannot change while
+// the process runs, so it is parsed once however many interpreters load it.
+var muninPrograms struct {
+	once     sync.Once
+	names    []string
+	programs []*ast.Program
+	err      error
+}
 
-	// 2. Load each .crl file
-	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".crl" {
+func parseMunin() ([]string, []*ast.Program, error) {
+	m := &muninPrograms
+	m.once.Do(func() {
+		// 1. List embedded files in the current directory (".")
+		//    if you used //go:embed *.crl with no subdirectory
+		entries, err := munin.MuninFs.ReadDir(".")
+		if err != nil {
+			m.err = fmt.Errorf("failed to read embedded stdlib: %w", err)
+			return
+		}
+
+		// 2. Parse each .crl file
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".crl" {
+				continue
+			}
 			// 3. Read the file’s content
 			content, err := munin.MuninFs.ReadFile(entry.Name())
 			if err != nil {
-				return fmt.Errorf("failed to read file %s: %w", entry.Name(), err)
+				m.err = fmt.Errorf("failed to read file %s: %w", entry.Name(), err)
+				return
 			}
 
 			// 4. Lex & parse the content
@@ -34,14 +51,27 @@ func LoadMuninStdlib(env *object.Environment) error {
 
 			// 5. Check for parse errors
 			if len(p.Errors()) > 0 {
-				return fmt.Errorf("parse errors in %s: %v", entry.Name(), p.Errors())
+				m.err = fmt.Errorf("parse errors in %s: %v", entry.Name(), p.Errors())
+				return
 			}
+			m.names = append(m.names, entry.Name())
+			m.programs = append(m.programs, program)
+		}
+	})
+	return m.names, m.programs, m.err
+}
 
-			// 6. Evaluate in the global environment
-			result := Eval(program, env)
-			if isError(result) {
-				return fmt.Errorf("runtime error in %s: %s", entry.Name(), result.Inspect())
-			}
+func LoadMuninStdlib(env *object.Environment) error {
+	names, programs, err := parseMunin()
+	if err != nil {
+		return err
+	}
+
+	// 6. Evaluate each file in the global environment
+	for i, program := range programs {
+		result := Eval(program, env)
+		if isError(result) {
+			return fmt.Errorf("runtime error in %s: %s", names[i], result.Inspect())
 		}
 	}
 
@@ -0,0 +1,302 @@
+package evaluator
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+func init() {
+	builtins["split"] = &object.Builtin{EnvFn: splitBuiltin}
+	builtins["strUpper"] = &object.Builtin{Fn: stringMap("strUpper", strings.ToUpper)}
+	builtins["strLower"] = &object.Builtin{Fn: stringMap("strLower", strings.ToLower)}
+	builtins["strStrip"] = &object.Builtin{Fn: strStrip}
+	builtins["strJoin"] = &object.Builtin{Fn: strJoin}
+	builtins["strReplace"] = &object.Builtin{Fn: strReplace}
+	builtins["strFind"] = &object.Builtin{Fn: stringSearch("strFind", func(s, sub string) object.Object {
+		i := strings.Index(s, sub)
+		if i > 0 {
+			i = utf8.RuneCountInString(s[:i])
+		}
+		return &object.Integer{Value: int64(i)}
+	})}
+	builtins["strCount"] = &object.Builtin{Fn: stringSearch("strCount", func(s, sub string) object.Object {
+		return &object.Integer{Value: int64(strings.Count(s, sub))}
+	})}
+	builtins["strStartsWith"] = &object.Builtin{Fn: stringSearch("strStartsWith", func(s, prefix string) object.Object {
+		return nativeBoolToBooleanObject(strings.HasPrefix(s, prefix))
+	})}
+	builtins["strEndsWith"] = &object.Builtin{Fn: stringSearch("strEndsWith", func(s, suffix string) object.Object {
+		return nativeBoolToBooleanObject(strings.HasSuffix(s, suffix))
+	})}
+	builtins["strFormat"] = &object.Builtin{Fn: strFormat}
+}
+
+// Slicing and splitting a string give views of it rather than copies: the
+// pieces share the original's memory, so cutting up a large text allocates
+// only the new String objects. A piece keeps the whole of its original
+// alive, so hash keys, which often outlive the text they were cut from, are
+// copied when a hash is built; see ownedKey.
+
+// evalStringIndexExpression gives the character at an index as a string,
+// or None past either end as for arrays, and a slice for a range. Strings
+// index by character, not byte, as len counts them, so non-ASCII text is
+// never cut in the middle of a character.
+func evalStringIndexExpression(s *object.String, index object.Object) object.Object {
+	switch index := index.(type) {
+	case *object.Integer:
+		if index.Value < 0 || index.Value >= int64(len(s.Value)) {
+			return NONE
+		}
+		offset := runeOffset(s.Value, int(index.Value))
+		if offset >= len(s.Value) {
+			return NONE
+		}
+		_, size := utf8.DecodeRuneInString(s.Value[offset:])
+		return &object.String{Value: s.Value[offset : offset+size]}
+	case *object.Range:
+		start, end, errObj := sliceBounds("string", index, utf8.RuneCountInString(s.Value))
+		if errObj != nil {
+			return errObj
+		}
+		from := runeOffset(s.Value, start)
+		return &object.String{Value: s.Value[from : from+runeOffset(s.Value[from:], end-start)]}
+	}
+	return newTypeError("string index must be INTEGER or RANGE, got %s", index.Type())
+}
+
+// runeOffset gives the byte offset in s of the character at index i, or
+// len(s) if s has no more than i characters.
+func runeOffset(s string, i int) int {
+	offset := 0
+	for ; i > 0 && offset < len(s); i-- {
+		if s[offset] < utf8.RuneSelf {
+			offset++
+		} else {
+			_, size := utf8.DecodeRuneInString(s[offset:])
+			offset += size
+		}
+	}
+	return offset
+}
+
+// splitBuiltin implements split(s, [sep]). Without a separator it splits
+// around runs of whitespace and drops empty pieces, as for splitting a line
+// into fields; with one it splits at every occurrence.
+func splitBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("split requires 1 or 2 arguments: string, [separator]")
+	}
+	s, ok := args[0].(*object.String)
+	if !ok {
+		return newError("split expects a STRING, got %s", args[0].Type())
+	}
+	var pieces []string
+	if len(args) == 1 {
+		pieces = strings.Fields(s.Value)
+	} else {
+		sep, ok := args[1].(*object.String)
+		if !ok {
+			return newError("split: separator must be a STRING, got %s", args[1].Type())
+		}
+		pieces = strings.Split(s.Value, sep.Value)
+	}
+
+	arena := arenaFor(env)
+	elements := make([]object.Object, len(pieces))
+	for i, piece := range pieces {
+		elements[i] = arena.String(piece)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// ownedKey returns a string hash key as a copy that no longer shares
+// memory with a larger string it may have been cut from.
+func ownedKey(key object.Object) object.Object {
+	if s, ok := key.(*object.String); ok {
+		return &object.String{Value: strings.Clone(s.Value)}
+	}
+	return key
+}
+
+// stringArgs returns args as strings, checking that there are n of them.
+func stringArgs(fn string, args []object.Object, n int) ([]string, object.Object) {
+	if len(args) != n {
+		return nil, newError("%s: expected %d arguments, got %d", fn, n, len(args))
+	}
+	values := make([]string, n)
+	for i, arg := range args {
+		s, ok := arg.(*object.String)
+		if !ok {
+			return nil, newError("%s: argument %d must be a STRING, got %s", fn, i+1, arg.Type())
+		}
+		values[i] = s.Value
+	}
+	return values, nil
+}
+
+// stringMap makes a builtin of one string argument that returns f of it.
+func stringMap(fn string, f func(string) string) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		values, errObj := stringArgs(fn, args, 1)
+		if errObj != nil {
+			return errObj
+		}
+		return &object.String{Value: f(values[0])}
+	}
+}
+
+// stringSearch makes a builtin that looks for its second string argument
+// in its first.
+func stringSearch(fn string, f func(s, sub string) object.Object) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		values, errObj := stringArgs(fn, args, 2)
+		if errObj != nil {
+			return errObj
+		}
+		return f(values[0], values[1])
+	}
+}
+
+// strStrip implements strStrip(s, [chars]), which trims whitespace, or any
+// of chars, from both ends of s.
+func strStrip(args ...object.Object) object.Object {
+	if len(args) == 2 && args[1].Type() == object.NONE_OBJ {
+		args = args[:1]
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return newError("strStrip requires 1 or 2 arguments: string, [chars]")
+	}
+	values, errObj := stringArgs("strStrip", args, len(args))
+	if errObj != nil {
+		return errObj
+	}
+	if len(values) == 1 {
+		return &object.String{Value: strings.TrimSpace(values[0])}
+	}
+	return &object.String{Value: strings.Trim(values[0], values[1])}
+}
+
+// strJoin implements strJoin(sep, items), the strings of an array or tuple
+// joined with sep between them.
+func strJoin(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("strJoin requires 2 arguments: separator, items")
+	}
+	sep, ok := args[0].(*object.String)
+	if !ok {
+		return newError("strJoin: separator must be a STRING, got %s", args[0].Type())
+	}
+	var elements []object.Object
+	switch items := args[1].(type) {
+	case *object.Array:
+		elements = items.Elements
+	case *object.Tuple:
+		elements = items.Elements
+	default:
+		return newError("strJoin: items must be an ARRAY or TUPLE, got %s", args[1].Type())
+	}
+	parts := make([]string, len(elements))
+	for i, element := range elements {
+		s, ok := element.(*object.String)
+		if !ok {
+			return newError("strJoin: item %d is %s, not a STRING", i, element.Type())
+		}
+		parts[i] = s.Value
+	}
+	return &object.String{Value: strings.Join(parts, sep.Value)}
+}
+
+// strReplace implements strReplace(s, old, new, [count]), which replaces
+// the first count occurrences of old, or all of them.
+func strReplace(args ...object.Object) object.Object {
+	count := -1
+	if len(args) == 4 {
+		if args[3].Type() != object.NONE_OBJ {
+			n, ok := args[3].(*object.Integer)
+			if !ok {
+				return newError("strReplace: count must be an INTEGER, got %s", args[3].Type())
+			}
+			count = int(n.Value)
+		}
+		args = args[:3]
+	}
+	values, errObj := stringArgs("strReplace", args, 3)
+	if errObj != nil {
+		return errObj
+	}
+	return &object.String{Value: strings.Replace(values[0], values[1], values[2], count)}
+}
+
+// strFormat implements strFormat(template, values). Each {} in the
+// template takes the next of an array or tuple of values, {n} the one at
+// index n, and {name} the value of that key in a hash; {{ and }} are
+// literal braces.
+func strFormat(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("strFormat requires 2 arguments: template, values")
+	}
+	template, ok := args[0].(*object.String)
+	if !ok {
+		return newError("strFormat: template must be a STRING, got %s", args[0].Type())
+	}
+	var elements []object.Object
+	var hash *object.Hash
+	switch values := args[1].(type) {
+	case *object.Array:
+		elements = values.Elements
+	case *object.Tuple:
+		elements = values.Elements
+	case *object.Hash:
+		hash = values
+	default:
+		elements = []object.Object{values}
+	}
+
+	var out strings.Builder
+	next := 0
+	t := template.Value
+	for i := 0; i < len(t); i++ {
+		switch {
+		case strings.HasPrefix(t[i:], "{{"), strings.HasPrefix(t[i:], "}}"):
+			out.WriteByte(t[i])
+			i++
+		case t[i] == '{':
+			end := strings.IndexByte(t[i:], '}')
+			if end < 0 {
+				return newError("strFormat: unclosed { at index %d", i)
+			}
+			field := t[i+1 : i+end]
+			i += end
+			var value object.Object
+			if hash != nil {
+				key := &object.String{Value: field}
+				pair, ok := hash.Pairs[key.HashKey()]
+				if !ok {
+					return newError("strFormat: no value for {%s}", field)
+				}
+				value = pair.Value
+			} else {
+				n := next
+				if field != "" {
+					var err error
+					if n, err = strconv.Atoi(field); err != nil {
+						return newError("strFormat: {%s} needs a hash of values", field)
+					}
+				} else {
+					next++
+				}
+				if n < 0 || n >= len(elements) {
+					return newError("strFormat: no value for {%s}, %d given", field, len(elements))
+				}
+				value = elements[n]
+			}
+			out.WriteString(object.Display(value))
+		default:
+			out.WriteByte(t[i])
+		}
+	}
+	return &object.String{Value: out.String()}
+}
@@ -0,0 +1,103 @@
+package evaluator
+
+import (
+	"slices"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// maxSuggestions is how many similar names an error suggests at most.
+const maxSuggestions = 3
+
+// suggest returns the candidates close enough to name to be a likely typo
+// of it, at an edit distance of at most a third of its length and at least
+// 1, closest first and then alphabetically.
+func suggest(name string, candidates []string) []string {
+	limit := max(1, len([]rune(name))/3)
+	distances := map[string]int{}
+	for _, candidate := range candidates {
+		if _, seen := distances[candidate]; seen || candidate == name {
+			continue
+		}
+		if d := editDistance(name, candidate); d <= limit {
+			distances[candidate] = d
+		}
+	}
+	names := make([]string, 0, len(distances))
+	for candidate := range distances {
+		names = append(names, candidate)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		if distances[a] != distances[b] {
+			return distances[a] - distances[b]
+		}
+		if a < b {
+			return -1
+		}
+		return 1
+	})
+	if len(names) > maxSuggestions {
+		names = names[:maxSuggestions]
+	}
+	return names
+}
+
+// editDistance is the Levenshtein distance between a and b, counting runes.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(t)]
+}
+
+// identifierNotFound is the error for an unknown name, suggesting the names
+// visible from env, the builtins and the builtin modules it is close to.
+func identifierNotFound(name string, env *object.Environment) *object.Error {
+	var candidates []string
+	for e := env; e != nil; e = e.GetOuter() {
+		candidates = append(candidates, e.GetNames()...)
+	}
+	for builtin := range builtins {
+		candidates = append(candidates, builtin)
+	}
+	for module := range builtinModules {
+		candidates = append(candidates, module)
+	}
+	err := newNameError("identifier not found: %s", name)
+	err.Suggestions = suggest(name, candidates)
+	return err
+}
+
+// undefinedMember is the error for an unknown member of an instance,
+// suggesting the fields and methods it has that name is close to.
+func undefinedMember(instance *object.Instance, name string) *object.Error {
+	var candidates []string
+	for field := range instance.Grimoire.Slots {
+		candidates = append(candidates, field)
+	}
+	if instance.Env != nil {
+		candidates = append(candidates, instance.Env.GetNames()...)
+	}
+	for g := instance.Grimoire; g != nil; g = g.Inherits {
+		for method := range g.Methods {
+			candidates = append(candidates, method)
+		}
+	}
+	err := newAttributeError("undefined property or method: %s", name)
+	err.Suggestions = suggest(name, candidates)
+	return err
+}
@@ -0,0 +1,156 @@
+package evaluator
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// templateTypes are the field types a parse template accepts, as `{name:type}`,
+// and the text each one matches.
+var templateTypes = map[string]string{
+	"str":   `.*?`,
+	"word":  `\w+`,
+	"int":   `[-+]?\d+`,
+	"float": `[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`,
+}
+
+var templateName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseTemplate is a compiled template: a regular expression with one group
+// per named field, and the type of each of those fields.
+type parseTemplate struct {
+	re    *regexp.Regexp
+	names []string
+	kinds []string
+}
+
+// templates caches compiled templates, since parse is typically called
+// with the same template for every line of some input.
+var templates sync.Map
+
+// compileTemplate turns a template such as "{user}@{host}" into a
+// parseTemplate. Text outside braces must match exactly, `{{` and `}}` stand
+// for literal braces and `{}` matches text that is not kept.
+func compileTemplate(source string) (*parseTemplate, error) {
+	if t, ok := templates.Load(source); ok {
+		return t.(*parseTemplate), nil
+	}
+
+	t := &parseTemplate{}
+	var pattern strings.Builder
+	pattern.WriteString(`^`)
+	for rest := source; rest != ""; {
+		switch {
+		case strings.HasPrefix(rest, "{{"):
+			pattern.WriteString(`\{`)
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "}}"):
+			pattern.WriteString(`\}`)
+			rest = rest[2:]
+		case rest[0] == '}':
+			return nil, fmt.Errorf("unmatched '}' in template %q", source)
+		case rest[0] == '{':
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed '{' in template %q", source)
+			}
+			name, kind, typed := strings.Cut(rest[1:end], ":")
+			if !typed {
+				kind = "str"
+			}
+			re, ok := templateTypes[kind]
+			if !ok {
+				return nil, fmt.Errorf("unknown field type %q in template %q", kind, source)
+			}
+			switch {
+			case name == "":
+				pattern.WriteString(`(?:` + re + `)`)
+			case !templateName.MatchString(name):
+				return nil, fmt.Errorf("invalid field name %q in template %q", name, source)
+			case slices.Contains(t.names, name):
+				return nil, fmt.Errorf("field %q appears twice in template %q", name, source)
+			default:
+				pattern.WriteString(`(` + re + `)`)
+				t.names = append(t.names, name)
+				t.kinds = append(t.kinds, kind)
+			}
+			rest = rest[end+1:]
+		default:
+			end := strings.IndexAny(rest, "{}")
+			if end < 0 {
+				end = len(rest)
+			}
+			pattern.WriteString(regexp.QuoteMeta(rest[:end]))
+			rest = rest[end:]
+		}
+	}
+	pattern.WriteString(`$`)
+
+	re, err := regexp.Compile("(?s)" + pattern.String())
+	if err != nil {
+		return nil, err
+	}
+	t.re = re
+	templates.Store(source, t)
+	return t, nil
+}
+
+// match returns the fields of s, or nil if s does not fit the template.
+func (t *parseTemplate) match(s string) (map[string]object.Object, error) {
+	groups := t.re.FindStringSubmatch(s)
+	if groups == nil {
+		return nil, nil
+	}
+	fields := make(map[string]object.Object, len(t.names))
+	for i, name := range t.names {
+		text := groups[i+1]
+		switch t.kinds[i] {
+		case "int":
+			n, err := strconv.ParseInt(text, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %q is out of range for an integer", name, text)
+			}
+			fields[name] = &object.Integer{Value: n}
+		case "float":
+			f, _ := strconv.ParseFloat(text, 64)
+			fields[name] = &object.Float{Value: f}
+		default:
+			fields[name] = &object.String{Value: text}
+		}
+	}
+	return fields, nil
+}
+
+// parseBuiltin implements parse(template, s), which pulls named fields out
+// of s and returns them as a hash, or None if s does not fit the template.
+func parseBuiltin(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("parse requires 2 arguments: template, string")
+	}
+	source, ok := args[0].(*object.String)
+	if !ok {
+		return newError("parse: template must be a STRING, got %s", args[0].Type())
+	}
+	s, ok := args[1].(*object.String)
+	if !ok {
+		return newError("parse: expected a STRING to parse, got %s", args[1].Type())
+	}
+	t, err := compileTemplate(source.Value)
+	if err != nil {
+		return newError("parse: %s", err)
+	}
+	fields, err := t.match(s.Value)
+	if err != nil {
+		return newError("parse: %s", err)
+	}
+	if fields == nil {
+		return object.NONE
+	}
+	return newStringHash(fields)
+}
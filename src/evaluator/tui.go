@@ -0,0 +1,464 @@
+package evaluator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/peterh/liner"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// The tui module draws full-screen terminal programs. tui.screen() takes
+// over the terminal and gives a screen to write text into, which render()
+// then shows, and whose read_key() waits for a key press. The widgets in
+// munin/tui.crl draw lists, tables and text inputs on a screen.
+func init() {
+	builtinModules["tui"] = newBuiltinModule(map[string]object.Object{
+		"screen": &object.Builtin{EnvFn: tuiScreen},
+	})
+}
+
+// escapeWait is how long a lone escape byte waits for the rest of a key
+// sequence before it counts as the escape key itself.
+const escapeWait = 30 * time.Millisecond
+
+type cell struct {
+	ch    rune
+	style string // SGR parameters, "" for plain text
+}
+
+// screen is a terminal drawn from a buffer of cells. Writes go to the
+// buffer, and render sends the rows that changed since the last render.
+type screen struct {
+	out           io.Writer
+	keys          *keyReader
+	width, height int
+	cells, shown  [][]cell
+	restore       func()
+}
+
+func newScreen(in io.Reader, out io.Writer, width, height int) *screen {
+	s := &screen{out: out, keys: newKeyReader(in), width: width, height: height}
+	s.cells = blankCells(width, height)
+	s.shown = blankCells(width, height)
+	return s
+}
+
+func blankCells(width, height int) [][]cell {
+	rows := make([][]cell, height)
+	for y := range rows {
+		rows[y] = make([]cell, width)
+		for x := range rows[y] {
+			rows[y][x] = cell{ch: ' '}
+		}
+	}
+	return rows
+}
+
+// tuiScreen implements tui.screen(). It puts the terminal into raw mode and
+// switches to its alternate screen until close(), which every program
+// should call, with autoclose or in a final block, to give the terminal
+// back as it was.
+func tuiScreen(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newTypeError("tui.screen takes no arguments")
+	}
+	ctx := contextFor(env)
+	_, modeErr := liner.TerminalMode()
+	terminal := modeErr == nil
+	var state *liner.State
+	if terminal {
+		state = liner.NewLiner()
+	}
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+
+	s := newScreen(os.Stdin, ctx.Stdout(), 80, 24)
+	s.keys.interrupts = interrupts
+	fmt.Fprint(s.out, "\x1b[?1049h\x1b[?25l\x1b[2J")
+	if w, h, ok := s.querySize(terminal); ok {
+		s.width, s.height = w, h
+		s.cells, s.shown = blankCells(w, h), blankCells(w, h)
+	}
+	handle := ctx.openHandle("screen", "tui")
+	s.restore = func() {
+		fmt.Fprint(s.out, "\x1b[0m\x1b[?25h\x1b[?1049l")
+		signal.Stop(interrupts)
+		if state != nil {
+			state.Close()
+		}
+		ctx.closeHandle(handle)
+	}
+	return screenModule(s)
+}
+
+// querySize finds the size of the terminal by moving the cursor as far as
+// it goes and asking where it ended up. Without a terminal to ask it uses
+// the COLUMNS and LINES variables.
+func (s *screen) querySize(terminal bool) (int, int, bool) {
+	if terminal {
+		fmt.Fprint(s.out, "\x1b[999;999H\x1b[6n")
+		if report, ok := s.keys.cursorReport(200 * time.Millisecond); ok {
+			var row, col int
+			if _, err := fmt.Sscanf(report, "%d;%d", &row, &col); err == nil && row > 0 && col > 0 {
+				return col, row, true
+			}
+		}
+	}
+	w, errW := strconv.Atoi(os.Getenv("COLUMNS"))
+	h, errH := strconv.Atoi(os.Getenv("LINES"))
+	if errW == nil && errH == nil && w > 0 && h > 0 {
+		return w, h, true
+	}
+	return 0, 0, false
+}
+
+// screenModule gives the members of a screen: size(), clear(),
+// write(x, y, text, [style], [width]), fill(x, y, width, height, [style]),
+// render(), read_key([timeout]) and close().
+func screenModule(s *screen) *object.Namespace {
+	closed := false
+	return newBuiltinModule(map[string]object.Object{
+		"size": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			return &object.Tuple{Elements: []object.Object{
+				&object.Integer{Value: int64(s.width)}, &object.Integer{Value: int64(s.height)},
+			}}
+		}},
+		"clear": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			s.cells = blankCells(s.width, s.height)
+			return NONE
+		}},
+		"write": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			if len(args) < 3 || len(args) > 5 {
+				return newError("write requires 3 to 5 arguments: x, y, text, [style], [width]")
+			}
+			x, y, errObj := screenPoint("write", args[0], args[1])
+			if errObj != nil {
+				return errObj
+			}
+			style, errObj := screenStyle("write", args, 3)
+			if errObj != nil {
+				return errObj
+			}
+			text := []rune(object.Display(args[2]))
+			if len(args) == 5 {
+				width, ok := args[4].(*object.Integer)
+				if !ok {
+					return newError("write: width must be an INTEGER, got %s", args[4].Type())
+				}
+				text = fitRunes(text, int(width.Value))
+			}
+			s.put(x, y, text, style)
+			return NONE
+		}},
+		"fill": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			if len(args) < 4 || len(args) > 5 {
+				return newError("fill requires 4 or 5 arguments: x, y, width, height, [style]")
+			}
+			x, y, errObj := screenPoint("fill", args[0], args[1])
+			if errObj != nil {
+				return errObj
+			}
+			w, h, errObj := screenPoint("fill", args[2], args[3])
+			if errObj != nil {
+				return errObj
+			}
+			style, errObj := screenStyle("fill", args, 4)
+			if errObj != nil {
+				return errObj
+			}
+			for row := y; row < y+h; row++ {
+				s.put(x, row, []rune(strings.Repeat(" ", max(w, 0))), style)
+			}
+			return NONE
+		}},
+		"render": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			s.render()
+			return NONE
+		}},
+		"read_key": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("read_key takes at most 1 argument: [timeout]")
+			}
+			var timeout time.Duration
+			if len(args) == 1 {
+				seconds, errObj := numberArg("read_key", args[0])
+				if errObj != nil {
+					return errObj
+				}
+				timeout = time.Duration(seconds * float64(time.Second))
+			}
+			key, ok := s.keys.next(timeout)
+			if !ok {
+				return NONE
+			}
+			return &object.String{Value: key}
+		}},
+		"close": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			if !closed && s.restore != nil {
+				s.restore()
+			}
+			closed = true
+			return NONE
+		}},
+	})
+}
+
+func screenPoint(name string, a, b object.Object) (int, int, object.Object) {
+	x, okX := a.(*object.Integer)
+	y, okY := b.(*object.Integer)
+	if !okX || !okY {
+		return 0, 0, newError("%s: positions and sizes must be INTEGERs, got %s and %s", name, a.Type(), b.Type())
+	}
+	return int(x.Value), int(y.Value), nil
+}
+
+// sgrCodes are the styles a screen knows, as SGR parameters.
+var sgrCodes = map[string]string{
+	"bold": "1", "dim": "2", "italic": "3", "underline": "4", "reverse": "7",
+	"black": "30", "red": "31", "green": "32", "yellow": "33",
+	"blue": "34", "magenta": "35", "cyan": "36", "white": "37",
+	"on_black": "40", "on_red": "41", "on_green": "42", "on_yellow": "43",
+	"on_blue": "44", "on_magenta": "45", "on_cyan": "46", "on_white": "47",
+}
+
+// screenStyle reads an optional style argument, words from sgrCodes
+// separated by spaces, such as "bold red", or None for plain text.
+func screenStyle(name string, args []object.Object, i int) (string, object.Object) {
+	if len(args) <= i || args[i].Type() == object.NONE_OBJ {
+		return "", nil
+	}
+	str, ok := args[i].(*object.String)
+	if !ok {
+		return "", newError("%s: style must be a STRING, got %s", name, args[i].Type())
+	}
+	var codes []string
+	for _, word := range strings.Fields(str.Value) {
+		code, ok := sgrCodes[word]
+		if !ok {
+			return "", newError("%s: unknown style %q", name, word)
+		}
+		codes = append(codes, code)
+	}
+	return strings.Join(codes, ";"), nil
+}
+
+// fitRunes cuts text to width, or pads it with spaces to width.
+func fitRunes(text []rune, width int) []rune {
+	if len(text) >= width {
+		return text[:max(width, 0)]
+	}
+	return append(text, []rune(strings.Repeat(" ", width-len(text)))...)
+}
+
+// put writes text into the buffer at x, y, dropping what falls outside the
+// screen.
+func (s *screen) put(x, y int, text []rune, style string) {
+	if y < 0 || y >= s.height {
+		return
+	}
+	for i, r := range text {
+		if r == '\n' || r == '\t' {
+			r = ' '
+		}
+		if col := x + i; col >= 0 && col < s.width {
+			s.cells[y][col] = cell{ch: r, style: style}
+		}
+	}
+}
+
+// render sends each row that differs from what the terminal shows.
+func (s *screen) render() {
+	var out strings.Builder
+	for y, row := range s.cells {
+		if rowsEqual(row, s.shown[y]) {
+			continue
+		}
+		fmt.Fprintf(&out, "\x1b[%d;1H", y+1)
+		style := ""
+		for _, c := range row {
+			if c.style != style {
+				out.WriteString("\x1b[0m")
+				if c.style != "" {
+					fmt.Fprintf(&out, "\x1b[%sm", c.style)
+				}
+				style = c.style
+			}
+			out.WriteRune(c.ch)
+		}
+		if style != "" {
+			out.WriteString("\x1b[0m")
+		}
+		s.shown[y] = append([]cell(nil), row...)
+	}
+	io.WriteString(s.out, out.String())
+}
+
+func rowsEqual(a, b []cell) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+// keyReader turns the bytes a terminal sends into key names: a printable
+// character as itself, and otherwise "enter", "tab", "backspace", "esc",
+// "up", "down", "left", "right", "home", "end", "pgup", "pgdn", "delete"
+// or "ctrl+a" to "ctrl+z". An interrupt signal reads as "ctrl+c".
+type keyReader struct {
+	runes      chan rune
+	interrupts chan os.Signal
+	reports    []string // cursor position reports, which are not keys
+}
+
+func newKeyReader(in io.Reader) *keyReader {
+	k := &keyReader{runes: make(chan rune, 64)}
+	go func() {
+		r := bufio.NewReader(in)
+		for {
+			ch, _, err := r.ReadRune()
+			if err != nil {
+				close(k.runes)
+				return
+			}
+			k.runes <- ch
+		}
+	}()
+	return k
+}
+
+// next waits up to timeout for a key, or for ever if timeout is 0. It
+// reports false if none came or the input has ended.
+func (k *keyReader) next(timeout time.Duration) (string, bool) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		select {
+		case r, ok := <-k.runes:
+			if !ok {
+				return "", false
+			}
+			if key, isKey := k.decode(r); isKey {
+				return key, true
+			}
+		case <-k.interrupts:
+			return "ctrl+c", true
+		case <-expired:
+			return "", false
+		}
+	}
+}
+
+// cursorReport waits up to timeout for the terminal to report where the
+// cursor is, giving its "row;column". Keys read meanwhile are lost.
+func (k *keyReader) cursorReport(timeout time.Duration) (string, bool) {
+	deadline := time.Now().Add(timeout)
+	for len(k.reports) == 0 {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return "", false
+		}
+		if _, ok := k.next(left); !ok && len(k.reports) == 0 {
+			return "", false
+		}
+	}
+	report := k.reports[0]
+	k.reports = k.reports[1:]
+	return report, true
+}
+
+// decode names the key that starts with r, reading the rest of an escape
+// sequence if there is one. It reports false for input that is not a key.
+func (k *keyReader) decode(r rune) (string, bool) {
+	switch {
+	case r == '\r' || r == '\n':
+		return "enter", true
+	case r == '\t':
+		return "tab", true
+	case r == 127 || r == 8:
+		return "backspace", true
+	case r == 27:
+		return k.escape()
+	case r >= 1 && r <= 26:
+		return "ctrl+" + string('a'+r-1), true
+	case r < 32:
+		return "", false
+	}
+	return string(r), true
+}
+
+func (k *keyReader) escape() (string, bool) {
+	following := func() (rune, bool) {
+		select {
+		case r, ok := <-k.runes:
+			return r, ok
+		case <-time.After(escapeWait):
+			return 0, false
+		}
+	}
+	intro, ok := following()
+	if !ok {
+		return "esc", true
+	}
+	if intro != '[' && intro != 'O' {
+		return k.decode(intro)
+	}
+	var params strings.Builder
+	for {
+		r, ok := following()
+		if !ok {
+			return "esc", true
+		}
+		if r >= 0x40 && r <= 0x7e {
+			return k.sequence(params.String(), r)
+		}
+		params.WriteRune(r)
+	}
+}
+
+func (k *keyReader) sequence(params string, final rune) (string, bool) {
+	switch final {
+	case 'A':
+		return "up", true
+	case 'B':
+		return "down", true
+	case 'C':
+		return "right", true
+	case 'D':
+		return "left", true
+	case 'H':
+		return "home", true
+	case 'F':
+		return "end", true
+	case 'R':
+		k.reports = append(k.reports, params)
+		return "", false
+	case '~':
+		switch params {
+		case "1", "7":
+			return "home", true
+		case "4", "8":
+			return "end", true
+		case "3":
+			return "delete", true
+		case "5":
+			return "pgup", true
+		case "6":
+			return "pgdn", true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,221 @@
+package evaluator
+
+import (
+	"math/big"
+	"slices"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+func init() {
+	builtins["intarray"] = &object.Builtin{Fn: intArrayBuiltin}
+	builtins["floatarray"] = &object.Builtin{Fn: floatArrayBuiltin}
+}
+
+// intArrayBuiltin implements intarray(x), which makes an IntArray from an
+// array of integers or another typed array of them, or n zeros from an
+// integer n.
+func intArrayBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("intarray requires 1 argument: elements or length")
+	}
+	switch arg := args[0].(type) {
+	case *object.IntArray:
+		return arg
+	case *object.Integer:
+		if arg.Value < 0 {
+			return newError("intarray: negative length %d", arg.Value)
+		}
+		return &object.IntArray{Values: make([]int64, arg.Value)}
+	case *object.Array:
+		values := make([]int64, len(arg.Elements))
+		for i, element := range arg.Elements {
+			n, ok := element.(*object.Integer)
+			if !ok {
+				return newError("intarray: element %d must be an INTEGER that fits in 64 bits, got %s", i, element.Type())
+			}
+			values[i] = n.Value
+		}
+		return &object.IntArray{Values: values}
+	}
+	return newError("intarray: cannot convert %s", args[0].Type())
+}
+
+// floatArrayBuiltin implements floatarray(x), which makes a FloatArray from
+// an array of numbers or a typed array, or n zeros from an integer n.
+func floatArrayBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("floatarray requires 1 argument: elements or length")
+	}
+	switch arg := args[0].(type) {
+	case *object.FloatArray:
+		return arg
+	case *object.IntArray:
+		return &object.FloatArray{Values: intsToFloats(arg.Values)}
+	case *object.Integer:
+		if arg.Value < 0 {
+			return newError("floatarray: negative length %d", arg.Value)
+		}
+		return &object.FloatArray{Values: make([]float64, arg.Value)}
+	case *object.Array:
+		values := make([]float64, len(arg.Elements))
+		for i, element := range arg.Elements {
+			if !isNumber(element) {
+				return newError("floatarray: element %d must be a number, got %s", i, element.Type())
+			}
+			values[i] = toFloat(element)
+		}
+		return &object.FloatArray{Values: values}
+	}
+	return newError("floatarray: cannot convert %s", args[0].Type())
+}
+
+func intsToFloats(ints []int64) []float64 {
+	floats := make([]float64, len(ints))
+	for i, v := range ints {
+		floats[i] = float64(v)
+	}
+	return floats
+}
+
+// typedElements returns the elements of a typed array as a plain array.
+func typedElements(obj object.Object) *object.Array {
+	switch obj := obj.(type) {
+	case *object.IntArray:
+		elements := make([]object.Object, len(obj.Values))
+		integers := make([]object.Integer, len(obj.Values))
+		for i, v := range obj.Values {
+			integers[i].Value = v
+			elements[i] = &integers[i]
+		}
+		return &object.Array{Elements: elements}
+	case *object.FloatArray:
+		elements := make([]object.Object, len(obj.Values))
+		floats := make([]object.Float, len(obj.Values))
+		for i, v := range obj.Values {
+			floats[i].Value = v
+			elements[i] = &floats[i]
+		}
+		return &object.Array{Elements: elements}
+	}
+	return nil
+}
+
+// evalTypedArrayIndexExpression gives the element at an index, or None
+// past either end as for arrays, and a typed array sharing the values for a
+// range.
+func evalTypedArrayIndexExpression(arr, index object.Object) object.Object {
+	length, _ := vectorLen(arr)
+	switch index := index.(type) {
+	case *object.Integer:
+		if index.Value < 0 || index.Value >= int64(length) {
+			return NONE
+		}
+		if ints, ok := arr.(*object.IntArray); ok {
+			return &object.Integer{Value: ints.Values[index.Value]}
+		}
+		return &object.Float{Value: arr.(*object.FloatArray).Values[index.Value]}
+	case *object.Range:
+		start, end, errObj := sliceBounds("array", index, length)
+		if errObj != nil {
+			return errObj
+		}
+		if ints, ok := arr.(*object.IntArray); ok {
+			return &object.IntArray{Values: ints.Values[start:end:end]}
+		}
+		floats := arr.(*object.FloatArray).Values
+		return &object.FloatArray{Values: floats[start:end:end]}
+	}
+	return newTypeError("array index must be INTEGER or RANGE, got %s", index.Type())
+}
+
+// typedArraysEqual reports whether two typed arrays hold the same numbers,
+// an IntArray and FloatArray being equal if their values are.
+func typedArraysEqual(a, b object.Object) bool {
+	ints1, ok1 := a.(*object.IntArray)
+	ints2, ok2 := b.(*object.IntArray)
+	if ok1 && ok2 {
+		return slices.Equal(ints1.Values, ints2.Values)
+	}
+	floats1, floats2 := typedFloats(a), typedFloats(b)
+	return floats1 != nil && floats2 != nil && slices.Equal(floats1, floats2)
+}
+
+// typedFloats returns the values of a typed array as floats, or nil.
+func typedFloats(obj object.Object) []float64 {
+	switch obj := obj.(type) {
+	case *object.IntArray:
+		return intsToFloats(obj.Values)
+	case *object.FloatArray:
+		return obj.Values
+	}
+	return nil
+}
+
+// typedOperand unwraps the right operand of a typed array method: a number,
+// as a single value, or the elements of a plain or typed array. ints is set
+// if they are all integers, and floats otherwise.
+func typedOperand(obj object.Object) (ints []int64, floats []float64, ok bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return []int64{obj.Value}, nil, true
+	case *object.BigInteger:
+		return nil, []float64{toFloat(obj)}, true
+	case *object.Float:
+		return nil, []float64{obj.Value}, true
+	case *object.IntArray:
+		return obj.Values, nil, true
+	case *object.FloatArray:
+		return nil, obj.Values, true
+	case *object.Array:
+		if ints, ok := intElements(obj.Elements); ok {
+			return ints, nil, true
+		}
+		floats, ok := floatElements(obj.Elements)
+		return nil, floats, ok
+	}
+	return nil, nil, false
+}
+
+// typedArrayMember looks up a method of a typed array. The result of an
+// operation between integers is an IntArray, failing if a value overflows,
+// and any other result is a FloatArray.
+func typedArrayMember(arr object.Object, name string) object.Object {
+	length, _ := vectorLen(arr)
+	ints, isInts := arr.(*object.IntArray)
+	return vectorMethod(name, length,
+		func() object.Object {
+			if !isInts {
+				return &object.Float{Value: floatSum(arr.(*object.FloatArray).Values)}
+			}
+			if total, ok := intSum(ints.Values); ok {
+				return &object.Integer{Value: total}
+			}
+			total := new(big.Int)
+			for _, v := range ints.Values {
+				total.Add(total, big.NewInt(v))
+			}
+			return object.NewInt(total)
+		},
+		func(operator string, right object.Object) object.Object {
+			rightInts, rightFloats, ok := typedOperand(right)
+			if !ok {
+				return newError("%s: elements must be numbers that fit in 64 bits", name)
+			}
+			if isInts && rightInts != nil && operator != "/" {
+				values, ok := intVector(operator, ints.Values, rightInts)
+				if !ok {
+					return newError("%s: a result does not fit in an INT_ARRAY", name)
+				}
+				return &object.IntArray{Values: values}
+			}
+			if rightFloats == nil {
+				rightFloats = intsToFloats(rightInts)
+			}
+			values, errObj := floatVector(operator, typedFloats(arr), rightFloats)
+			if errObj != nil {
+				return errObj
+			}
+			return &object.FloatArray{Values: values}
+		})
+}
@@ -0,0 +1,101 @@
+package evaluator
+
+import (
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/token"
+)
+
+// StrictTypes is whether new interpreters check type hints; see
+// EvalContext.SetStrictTypes.
+var StrictTypes bool
+
+// hintTypes maps the builtin type names used in hints to the type of value
+// each one accepts.
+var hintTypes = map[string]object.ObjectType{
+	"int":   object.INTEGER_OBJ,
+	"float": object.FLOAT_OBJ,
+	"str":   object.STRING_OBJ,
+	"bool":  object.BOOLEAN_OBJ,
+	"list":  object.ARRAY_OBJ,
+	"hash":  object.HASH_OBJ,
+	"tuple": object.TUPLE_OBJ,
+	"bytes": object.BYTES_OBJ,
+
+	"intarray":   object.INT_ARRAY_OBJ,
+	"floatarray": object.FLOAT_ARRAY_OBJ,
+}
+
+// StrictTypes reports whether type hints are checked.
+func (ctx *EvalContext) StrictTypes() bool {
+	return ctx.strictTypes
+}
+
+// SetStrictTypes turns checking of type hints on or off. When it is on,
+// assigning a variable or passing an argument whose value does not fit the
+// hint raises a TypeError.
+func (ctx *EvalContext) SetStrictTypes(strict bool) {
+	ctx.strictTypes = strict
+}
+
+// checkHint returns a TypeError if strict types are on and value does not
+// fit hint, and nil otherwise. Grimoire names in the hint are looked up in
+// scope. None fits every hint, since it stands for a missing value.
+func checkHint(value object.Object, hint ast.Expression, what string, scope, env *object.Environment, position token.Position) object.Object {
+	if hint == nil || !contextFor(env).StrictTypes() || fitsHint(value, hint, scope) {
+		return nil
+	}
+	message := what + " expects " + hint.String() + ", got " + string(value.Type())
+	return newStdlibError("TypeError", message, env, position)
+}
+
+func fitsHint(value object.Object, hint ast.Expression, scope *object.Environment) bool {
+	if value.Type() == object.NONE_OBJ {
+		return true
+	}
+	switch hint := hint.(type) {
+	case *ast.Identifier:
+		if typ, ok := hintTypes[hint.Value]; ok {
+			// An integer is accepted where a float is expected, as it is by
+			// arithmetic, and a big integer wherever an integer is.
+			if typ == object.INTEGER_OBJ || typ == object.FLOAT_OBJ {
+				return value.Type() == typ || isInteger(value)
+			}
+			return value.Type() == typ
+		}
+		obj, ok := scope.Get(hint.Value)
+		if !ok {
+			return true
+		}
+		grimoire, ok := obj.(*object.Grimoire)
+		if !ok {
+			return true
+		}
+		instance, ok := value.(*object.Instance)
+		if !ok {
+			return false
+		}
+		for g := instance.Grimoire; g != nil; g = g.Inherits {
+			if g == grimoire {
+				return true
+			}
+		}
+		return false
+	case *ast.ArrayLiteral:
+		array, ok := value.(*object.Array)
+		if !ok {
+			return false
+		}
+		if len(hint.Elements) != 1 {
+			return true
+		}
+		for _, element := range array.Elements {
+			if !fitsHint(element, hint.Elements[0], scope) {
+				return false
+			}
+		}
+		return true
+	}
+	// Other hints, such as attribute lookups, are not checked.
+	return true
+}
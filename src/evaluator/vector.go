@@ -0,0 +1,277 @@
+package evaluator
+
+import (
+	"strings"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// Arrays of numbers have methods that do arithmetic on every element in one
+// Go loop, instead of one evaluator step per element: sum(), the
+// element-wise add, sub, mul and div with another array of the same length,
+// and add_scalar, sub_scalar, mul_scalar and div_scalar with a number. They
+// follow the same rules as the operators, so integers that overflow become
+// big integers and / gives floats. Typed arrays have the same methods; see
+// typedarray.go.
+
+var vectorOperators = map[string]string{
+	"add": "+", "sub": "-", "mul": "*", "div": "/",
+	"add_scalar": "+", "sub_scalar": "-", "mul_scalar": "*", "div_scalar": "/",
+}
+
+// vectorMethod looks up the method name of an array of length n, which
+// sum adds up and op combines, by operator, with a number or another array
+// of the same length, plain or typed.
+func vectorMethod(name string, n int, sum func() object.Object, op func(operator string, right object.Object) object.Object) object.Object {
+	if name == "sum" {
+		return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newTypeError("sum takes no arguments")
+			}
+			return sum()
+		}}
+	}
+	operator, ok := vectorOperators[name]
+	if !ok {
+		return newAttributeError("array has no method '%s'", name)
+	}
+	scalar := strings.HasSuffix(name, "_scalar")
+	return &object.Builtin{EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("%s requires 1 argument", name)
+		}
+		length, isArray := vectorLen(args[0])
+		switch {
+		case scalar && !isNumber(args[0]):
+			return newError("%s expects a number, got %s", name, args[0].Type())
+		case !scalar && !isArray:
+			return newError("%s expects an ARRAY, got %s", name, args[0].Type())
+		case !scalar && length != n:
+			return newError("%s: arrays have different lengths, %d and %d", name, n, length)
+		}
+		result := op(operator, args[0])
+		if result == errDivisionByZero {
+			return newStdlibError("DivisionByZeroError", "division by zero", env, contextFor(env).CurrentPosition())
+		}
+		return result
+	}}
+}
+
+// vectorLen returns the length of a plain or typed array.
+func vectorLen(obj object.Object) (int, bool) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return len(obj.Elements), true
+	case *object.IntArray:
+		return len(obj.Values), true
+	case *object.FloatArray:
+		return len(obj.Values), true
+	}
+	return 0, false
+}
+
+// arrayMember looks up a method of an array: one of arrayMethods, a spell
+// of the Array grimoire, or an arithmetic one.
+func arrayMember(arr *object.Array, name string, env *object.Environment) object.Object {
+	if method, ok := arrayMethods[name]; ok {
+		return &object.Builtin{EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			return method(arr, env, args)
+		}}
+	}
+	if method, ok := primitiveMember(arr, name, env); ok {
+		return method
+	}
+	return vectorMethod(name, len(arr.Elements),
+		func() object.Object { return vectorSum(arr) },
+		func(operator string, right object.Object) object.Object {
+			switch right := right.(type) {
+			case *object.Array:
+				return vectorOp(operator, arr.Elements, right.Elements)
+			case *object.IntArray, *object.FloatArray:
+				return vectorOp(operator, arr.Elements, typedElements(right).Elements)
+			}
+			return vectorOp(operator, arr.Elements, []object.Object{right})
+		})
+}
+
+// intElements returns the values of elements if they are all Integers.
+func intElements(elements []object.Object) ([]int64, bool) {
+	values := make([]int64, len(elements))
+	for i, element := range elements {
+		n, ok := element.(*object.Integer)
+		if !ok {
+			return nil, false
+		}
+		values[i] = n.Value
+	}
+	return values, true
+}
+
+func allFloats(elements []object.Object) bool {
+	for _, element := range elements {
+		if element.Type() != object.FLOAT_OBJ {
+			return false
+		}
+	}
+	return true
+}
+
+// floatElements returns the values of elements as floats if they are all
+// Integers or Floats.
+func floatElements(elements []object.Object) ([]float64, bool) {
+	values := make([]float64, len(elements))
+	for i, element := range elements {
+		switch n := element.(type) {
+		case *object.Integer:
+			values[i] = float64(n.Value)
+		case *object.Float:
+			values[i] = n.Value
+		default:
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// vectorOp applies operator between each of left and the matching element
+// of right, or its only element when right holds a single scalar. It works
+// on unwrapped int64s, or float64s when one side is all floats, where it
+// can, and otherwise, as when an integer result overflows, element by
+// element as the operator would.
+func vectorOp(operator string, left, right []object.Object) object.Object {
+	if l, ok := intElements(left); ok && operator != "/" {
+		if r, ok := intElements(right); ok {
+			values, ok := intVector(operator, l, r)
+			if !ok {
+				return elementwise(operator, left, right)
+			}
+			result := make([]object.Object, len(values))
+			integers := make([]object.Integer, len(values))
+			for i, v := range values {
+				integers[i].Value = v
+				result[i] = &integers[i]
+			}
+			return &object.Array{Elements: result}
+		}
+	}
+	if operator == "/" || allFloats(left) || allFloats(right) {
+		l, lok := floatElements(left)
+		r, rok := floatElements(right)
+		if lok && rok {
+			values, errObj := floatVector(operator, l, r)
+			if errObj != nil {
+				return errObj
+			}
+			result := make([]object.Object, len(values))
+			floats := make([]object.Float, len(values))
+			for i, v := range values {
+				floats[i].Value = v
+				result[i] = &floats[i]
+			}
+			return &object.Array{Elements: result}
+		}
+	}
+	return elementwise(operator, left, right)
+}
+
+// broadcast is the index into right, of length m, of the operand for
+// element i of left, of length n: i itself, or 0 for a single scalar.
+func broadcast(i, n, m int) int {
+	if n != m {
+		return 0
+	}
+	return i
+}
+
+// intVector does +, - or * between l and r as vectorOp does, reporting
+// false if a result overflows int64.
+func intVector(operator string, l, r []int64) ([]int64, bool) {
+	values := make([]int64, len(l))
+	for i := range l {
+		var ok bool
+		if values[i], ok = checkedIntegerOp(operator, l[i], r[broadcast(i, len(l), len(r))]); !ok {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// floatVector does +, -, * or / between l and r as vectorOp does.
+func floatVector(operator string, l, r []float64) ([]float64, object.Object) {
+	values := make([]float64, len(l))
+	for i := range l {
+		rv := r[broadcast(i, len(l), len(r))]
+		switch operator {
+		case "+":
+			values[i] = l[i] + rv
+		case "-":
+			values[i] = l[i] - rv
+		case "*":
+			values[i] = l[i] * rv
+		case "/":
+			if rv == 0 {
+				return nil, errDivisionByZero
+			}
+			values[i] = l[i] / rv
+		}
+	}
+	return values, nil
+}
+
+// elementwise is vectorOp for elements it cannot unwrap, one at a time.
+func elementwise(operator string, left, right []object.Object) object.Object {
+	result := make([]object.Object, len(left))
+	for i := range left {
+		result[i] = evalInfixExpression(operator, left[i], right[broadcast(i, len(left), len(right))], nil)
+		if isError(result[i]) {
+			return result[i]
+		}
+	}
+	return &object.Array{Elements: result}
+}
+
+// vectorSum adds up the elements of an array of numbers, 0 for an empty
+// one.
+func vectorSum(arr *object.Array) object.Object {
+	if values, ok := intElements(arr.Elements); ok {
+		if total, ok := intSum(values); ok {
+			return &object.Integer{Value: total}
+		}
+		return sumElements(arr.Elements)
+	}
+	if values, ok := floatElements(arr.Elements); ok {
+		return &object.Float{Value: floatSum(values)}
+	}
+	return sumElements(arr.Elements)
+}
+
+// intSum adds up values, reporting false if the total overflows int64.
+func intSum(values []int64) (int64, bool) {
+	total := int64(0)
+	for _, v := range values {
+		var ok bool
+		if total, ok = checkedIntegerOp("+", total, v); !ok {
+			return 0, false
+		}
+	}
+	return total, true
+}
+
+func floatSum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+func sumElements(elements []object.Object) object.Object {
+	var total object.Object = &object.Integer{Value: 0}
+	for _, element := range elements {
+		if !isNumber(element) {
+			return newError("sum: element is %s, not a number", element.Type())
+		}
+		total = evalInfixExpression("+", total, element, nil)
+	}
+	return total
+}
@@ -0,0 +1,112 @@
+package evaluator
+
+import (
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// weakRegistry maps keys to weakly held objects. Entries whose object has
+// been collected are dropped the next time the registry is read, so a cache
+// or observer list built on it never keeps its values alive by itself.
+type weakRegistry struct {
+	keys map[object.HashKey]object.Object
+	refs map[object.HashKey]*object.WeakRef
+}
+
+// newWeakRegistry builds the namespace returned by weakregistry(), whose
+// members share one registry.
+func newWeakRegistry() *object.Namespace {
+	reg := &weakRegistry{
+		keys: make(map[object.HashKey]object.Object),
+		refs: make(map[object.HashKey]*object.WeakRef),
+	}
+
+	return newBuiltinModule(map[string]object.Object{
+		"set": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("set requires 2 arguments: key, value")
+				}
+				key, errObj := registryKey("set", args[0])
+				if errObj != nil {
+					return errObj
+				}
+				ref, ok := object.NewWeakRef(args[1])
+				if !ok {
+					return newError("set: cannot hold %s weakly", args[1].Type())
+				}
+				reg.keys[key] = args[0]
+				reg.refs[key] = ref
+				return NONE
+			},
+		},
+		"get": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("get requires 1 argument: key")
+				}
+				key, errObj := registryKey("get", args[0])
+				if errObj != nil {
+					return errObj
+				}
+				if ref, ok := reg.refs[key]; ok {
+					if obj := ref.Get(); obj != nil {
+						return obj
+					}
+					reg.remove(key)
+				}
+				return NONE
+			},
+		},
+		"remove": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("remove requires 1 argument: key")
+				}
+				key, errObj := registryKey("remove", args[0])
+				if errObj != nil {
+					return errObj
+				}
+				reg.remove(key)
+				return NONE
+			},
+		},
+		"keys": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				reg.prune()
+				keys := make([]object.Object, 0, len(reg.keys))
+				for _, key := range reg.keys {
+					keys = append(keys, key)
+				}
+				return &object.Array{Elements: keys}
+			},
+		},
+		"size": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				reg.prune()
+				return &object.Integer{Value: int64(len(reg.refs))}
+			},
+		},
+	})
+}
+
+func registryKey(method string, obj object.Object) (object.HashKey, *object.Error) {
+	key, ok := object.HashKeyOf(obj)
+	if !ok {
+		return object.HashKey{}, newError("%s: unusable as registry key: %s", method, obj.Type())
+	}
+	return key, nil
+}
+
+func (r *weakRegistry) remove(key object.HashKey) {
+	delete(r.keys, key)
+	delete(r.refs, key)
+}
+
+// prune drops the entries whose objects have been collected.
+func (r *weakRegistry) prune() {
+	for key, ref := range r.refs {
+		if ref.Get() == nil {
+			r.remove(key)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package evaluator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// The workers module runs spells in a pool of separate interpreter
+// processes, so that work can use every core without the processes sharing
+// anything. Calls travel to the workers as rpc calls do, over a pair of
+// pipes each.
+func init() {
+	builtinModules["workers"] = newBuiltinModule(map[string]object.Object{
+		"pool": &object.Builtin{EnvFn: workersPool},
+	})
+}
+
+// WorkerCommand makes the command for one worker of a pool serving the
+// spells of file: this interpreter's worker subcommand. The pool adds the
+// pipes the worker is called over.
+var WorkerCommand = func(file string) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command(exe, "worker", file), nil
+}
+
+// ServeWorker answers the calls of the pool that started this process,
+// given the environment its file was run in, until the pool closes. Calls
+// arrive on file descriptor 3 and replies go out on 4, leaving stdout and
+// stderr to the spells.
+func ServeWorker(env *object.Environment) {
+	spells := map[string]object.Object{}
+	for _, name := range env.GetNames() {
+		if fn, ok := env.GetLocal(name); ok && fn.Type() == object.FUNCTION_OBJ {
+			spells[name] = fn
+		}
+	}
+	server := &rpcServer{spells: spells}
+	server.handle(workerPipe{os.NewFile(3, "requests"), os.NewFile(4, "replies")}, env)
+}
+
+// workerPipe joins the two pipes between a pool and one of its workers into
+// a connection. Closing it closes the end written to, which the other side
+// sees as the end of the calls.
+type workerPipe struct {
+	io.Reader
+	io.WriteCloser
+}
+
+type worker struct {
+	cmd    *exec.Cmd
+	client *rpcClient
+}
+
+type workerPool struct {
+	file    string
+	workers []*worker
+	idle    chan *worker
+}
+
+// workersPool implements workers.pool(file, [size]). It starts size
+// workers, one for each CPU unless given, each of which runs file and then
+// serves calls to the spells it defines, and returns the pool.
+func workersPool(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("workers.pool requires 1 or 2 arguments: file, [size]")
+	}
+	file, ok := args[0].(*object.String)
+	if !ok {
+		return newError("workers.pool expects the file as a STRING, got %s", args[0].Type())
+	}
+	size := runtime.NumCPU()
+	if len(args) == 2 {
+		n, ok := args[1].(*object.Integer)
+		if !ok || n.Value < 1 {
+			return newError("workers.pool: size must be a positive INTEGER, got %s", args[1].Inspect())
+		}
+		size = int(n.Value)
+	}
+
+	ctx := contextFor(env)
+	pool := &workerPool{file: file.Value, idle: make(chan *worker, size)}
+	for range size {
+		w, err := startWorker(file.Value, ctx)
+		if err != nil {
+			pool.close()
+			return newStdlibError("WorkerError", fmt.Sprintf("%s: %s", file.Value, err), env, ctx.CurrentPosition())
+		}
+		pool.workers = append(pool.workers, w)
+		pool.idle <- w
+	}
+
+	handle := ctx.openHandle("worker pool", file.Value)
+	return newBuiltinModule(map[string]object.Object{
+		"size": &object.Integer{Value: int64(size)},
+		"call": &object.Builtin{EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("call requires at least 1 argument: spell, args...")
+			}
+			name, ok := args[0].(*object.String)
+			if !ok {
+				return newError("call expects the spell name as a STRING, got %s", args[0].Type())
+			}
+			return pool.run(name.Value, [][]object.Object{args[1:]}, env)[0]
+		}},
+		"map": &object.Builtin{EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("map requires 2 arguments: spell, items")
+			}
+			name, ok := args[0].(*object.String)
+			if !ok {
+				return newError("map expects the spell name as a STRING, got %s", args[0].Type())
+			}
+			items, ok := args[1].(*object.Array)
+			if !ok {
+				return newError("map expects the items as an ARRAY, got %s", args[1].Type())
+			}
+			calls := make([][]object.Object, len(items.Elements))
+			for i, item := range items.Elements {
+				calls[i] = []object.Object{item}
+			}
+			results := pool.run(name.Value, calls, env)
+			for _, result := range results {
+				if isError(result) {
+					return result
+				}
+			}
+			return &object.Array{Elements: results}
+		}},
+		"close": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			pool.close()
+			ctx.closeHandle(handle)
+			return NONE
+		}},
+	})
+}
+
+// startWorker starts a worker running file and waits until it is ready for
+// calls.
+func startWorker(file string, ctx *EvalContext) (*worker, error) {
+	cmd, err := WorkerCommand(file)
+	if err != nil {
+		return nil, err
+	}
+	requestsOut, requestsIn, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	repliesOut, repliesIn, err := os.Pipe()
+	if err != nil {
+		requestsOut.Close()
+		requestsIn.Close()
+		return nil, err
+	}
+	cmd.Stdout = ctx.Stdout()
+	cmd.Stderr = ctx.Stderr()
+	cmd.ExtraFiles = []*os.File{requestsOut, repliesIn}
+	err = cmd.Start()
+	requestsOut.Close()
+	repliesIn.Close()
+	if err != nil {
+		requestsIn.Close()
+		repliesOut.Close()
+		return nil, err
+	}
+
+	conn := workerPipe{repliesOut, requestsIn}
+	w := &worker{cmd: cmd, client: &rpcClient{conn: conn, dec: json.NewDecoder(bufio.NewReader(repliesOut)), enc: json.NewEncoder(requestsIn)}}
+	// Asking for the spells' names waits until the file has run.
+	if _, err := w.client.roundTrip(rpcRequest{}); err != nil {
+		w.stop()
+		return nil, fmt.Errorf("worker exited before it was ready")
+	}
+	return w, nil
+}
+
+// stop hangs up on the worker, which then exits, and waits for it.
+func (w *worker) stop() {
+	w.client.conn.Close()
+	w.cmd.Wait()
+}
+
+// run calls the spell once for each set of arguments in calls, on whichever
+// workers are free, and returns the results in the same order. Arguments
+// are encoded and results decoded here, so that only the waiting for
+// workers happens off the calling goroutine.
+func (p *workerPool) run(name string, calls [][]object.Object, env *object.Environment) []object.Object {
+	position := contextFor(env).CurrentPosition()
+	if p.workers == nil {
+		err := newStdlibError("WorkerError", fmt.Sprintf("%s: the pool for %s is closed", name, p.file), env, position)
+		return []object.Object{err}
+	}
+	results := make([]object.Object, len(calls))
+	requests := make([]rpcRequest, len(calls))
+	for i, args := range calls {
+		requests[i] = rpcRequest{Spell: name, Args: make([]wireValue, len(args))}
+		for j, arg := range args {
+			w, err := encodeWire(arg)
+			if err != nil {
+				results[i] = newStdlibError("WorkerError", fmt.Sprintf("%s: argument %d: %s", name, j+1, err), env, position)
+				return results[i : i+1]
+			}
+			requests[i].Args[j] = w
+		}
+	}
+
+	responses := make([]rpcResponse, len(calls))
+	errs := make([]error, len(calls))
+	var wg sync.WaitGroup
+	for i := range requests {
+		w := <-p.idle
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i], errs[i] = w.client.roundTrip(requests[i])
+			p.idle <- w
+		}()
+	}
+	wg.Wait()
+
+	for i, resp := range responses {
+		switch {
+		case errs[i] != nil:
+			results[i] = newStdlibError("WorkerError", fmt.Sprintf("%s: %s", name, errs[i]), env, position)
+		case resp.Error != "":
+			results[i] = newStdlibError("WorkerError", fmt.Sprintf("%s: %s", name, resp.Error), env, position)
+		case resp.Result == nil:
+			results[i] = NONE
+		default:
+			results[i] = decodeWire(*resp.Result, env)
+		}
+	}
+	return results
+}
+
+// close stops every worker. Calls made after it fail.
+func (p *workerPool) close() {
+	for _, w := range p.workers {
+		w.stop()
+	}
+	p.workers = nil
+}
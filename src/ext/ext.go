@@ -0,0 +1,93 @@
+// Package ext adds modules written in Go to Carrion, for code that needs
+// Go's speed or its libraries. A module is a set of Go functions registered
+// under a name, usually from an init function:
+//
+//	func init() {
+//		ext.Register("fastmath", map[string]any{
+//			"dot": func(a, b []float64) float64 { ... },
+//		})
+//	}
+//
+// A program imports it like a Carrion module, with `import "fastmath"`.
+// The module can be compiled into a carrion binary, or built on its own
+// with `go build -buildmode=plugin -o fastmath.so` and placed where a
+// fastmath.crl would be found; importing it then loads the plugin, whose
+// init runs Register.
+package ext
+
+import (
+	"fmt"
+	"path/filepath"
+	"plugin"
+	"strings"
+	"sync"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// PluginSuffix is the file extension of a Go plugin that import loads.
+const PluginSuffix = ".so"
+
+var (
+	mu      sync.Mutex
+	modules = make(map[string]map[string]*object.Builtin)
+)
+
+// Register adds the module name, whose members are the Go functions in
+// funcs. Arguments and results are converted as by object.NewGoFunc. Since
+// it is meant to be called from init, Register panics if name is already
+// registered or a member is not a function Carrion can call.
+func Register(name string, funcs map[string]any) {
+	members := make(map[string]*object.Builtin, len(funcs))
+	for member, fn := range funcs {
+		builtin, err := object.NewGoFunc(name+"."+member, fn)
+		if err != nil {
+			panic(fmt.Sprintf("ext.Register %s.%s: %v", name, member, err))
+		}
+		members[member] = builtin
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := modules[name]; ok {
+		panic(fmt.Sprintf("ext.Register: module %q registered twice", name))
+	}
+	modules[name] = members
+}
+
+// Module returns a namespace holding the members of the module registered
+// as name, and whether there is one.
+func Module(name string) (*object.Namespace, bool) {
+	mu.Lock()
+	members, ok := modules[name]
+	mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	env := object.NewEnvironment()
+	for member, builtin := range members {
+		env.Set(member, builtin)
+	}
+	return &object.Namespace{Env: env}, true
+}
+
+// ModuleName is the name that the plugin at path must register its module
+// under: the file's name without PluginSuffix.
+func ModuleName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), PluginSuffix)
+}
+
+// Open loads the Go plugin at path and returns the module it registers. A
+// plugin is loaded at most once per process, so opening it again returns
+// the same module.
+func Open(path string) (*object.Namespace, error) {
+	if _, err := plugin.Open(path); err != nil {
+		return nil, err
+	}
+	name := ModuleName(path)
+	module, ok := Module(name)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s did not register a module named %q", path, name)
+	}
+	return module, nil
+}
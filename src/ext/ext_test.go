@@ -0,0 +1,63 @@
+package ext
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+func call(t *testing.T, module *object.Namespace, name string, args ...object.Object) object.Object {
+	t.Helper()
+	member, ok := module.Env.Get(name)
+	if !ok {
+		t.Fatalf("module has no member %s", name)
+	}
+	return member.(*object.Builtin).Fn(args...)
+}
+
+func TestRegister(t *testing.T) {
+	Register("vec", map[string]any{
+		"dot": func(a, b []float64) float64 {
+			sum := 0.0
+			for i := range a {
+				sum += a[i] * b[i]
+			}
+			return sum
+		},
+	})
+	module, ok := Module("vec")
+	if !ok {
+		t.Fatal("vec was not registered")
+	}
+	a := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.Float{Value: 2.5}}}
+	if result := call(t, module, "dot", a, a); result.Inspect() != "7.25" {
+		t.Errorf("expected 7.25, got %s", result.Inspect())
+	}
+	if result, ok := call(t, module, "dot", a).(*object.Error); !ok || result.Message != "vec.dot takes 2 arguments, got 1" {
+		t.Errorf("expected an argument count error, got %+v", result)
+	}
+	if _, ok := Module("missing"); ok {
+		t.Error("expected no module named missing")
+	}
+
+	for _, funcs := range []map[string]any{{"dot": 1}, {}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected Register to panic for %v", funcs)
+				}
+			}()
+			Register("vec", funcs)
+		}()
+	}
+}
+
+func TestOpenMissing(t *testing.T) {
+	// Loading a real plugin is tried by hand with a carrion binary: a test
+	// binary's copy of this package never matches the one a plugin built
+	// from testdata/shout links against.
+	if _, err := Open(filepath.Join(t.TempDir(), "none"+PluginSuffix)); err == nil {
+		t.Error("expected an error opening a missing plugin")
+	}
+}
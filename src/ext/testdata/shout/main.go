@@ -0,0 +1,19 @@
+// Command shout is a Carrion plugin used by the ext tests.
+package main
+
+import (
+	"strings"
+
+	"github.com/javanhut/Carrion/src/ext"
+)
+
+func init() {
+	ext.Register("shout", map[string]any{
+		"upper": strings.ToUpper,
+		"repeat": func(s string, n int) string {
+			return strings.Repeat(s, n)
+		},
+	})
+}
+
+func main() {}
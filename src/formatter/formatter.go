@@ -0,0 +1,671 @@
+// Package formatter re-prints Carrion source in a canonical layout: four
+// space indentation, single spaces around binary operators, every block on
+// its own lines and at most one blank line between statements. Comments are
+// carried over from the original source.
+package formatter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/lexer"
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/parser"
+	"github.com/javanhut/Carrion/src/token"
+)
+
+const indentUnit = "    "
+
+// atom is the binding strength of literals and names, which never need
+// parentheses.
+const atom = parser.INDEX + 1
+
+// Source formats a complete Carrion file. It fails if src does not parse,
+// or if the formatted result would not parse back to the same program.
+func Source(src []byte) ([]byte, error) {
+	program, err := parse(string(src))
+	if err != nil {
+		return nil, err
+	}
+	out := render(program, scanSource(string(src)))
+
+	reparsed, err := parse(out)
+	if err != nil {
+		return nil, fmt.Errorf("formatted source does not parse: %v", err)
+	}
+	if render(reparsed, nil) != render(program, nil) {
+		return nil, errors.New("formatting would change the meaning of the program")
+	}
+	return []byte(out), nil
+}
+
+func parse(src string) (*ast.Program, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return program, nil
+}
+
+// render prints program. With a nil src the output carries no comments or
+// blank lines, which is how two programs are compared.
+func render(program *ast.Program, src *sourceInfo) string {
+	p := &printer{src: src, blockStart: true}
+	p.statements(program.Statements)
+	p.flushComments(1 << 30)
+	return p.buf.String()
+}
+
+type printer struct {
+	buf        strings.Builder
+	src        *sourceInfo
+	depth      int
+	next       int  // index of the next comment to print
+	blockStart bool // nothing has been printed in the current block yet
+	skipUntil  int  // statements before this line were copied verbatim
+}
+
+// writeLine prints one line of output for source line n, followed by any
+// comment that trailed that line in the original.
+func (p *printer) writeLine(n int, text string) {
+	p.buf.WriteString(strings.Repeat(indentUnit, p.depth))
+	p.buf.WriteString(text)
+	if p.src != nil && n > 0 {
+		for p.next < len(p.src.comments) && p.src.comments[p.next].line == n && p.src.comments[p.next].trailing {
+			p.buf.WriteString(" " + p.src.comments[p.next].text)
+			p.next++
+		}
+	}
+	p.buf.WriteByte('\n')
+	p.blockStart = false
+}
+
+// gap keeps a single blank line before source line n if the original had
+// one there.
+func (p *printer) gap(n int) {
+	if p.src != nil && !p.blockStart && n > 1 && n < len(p.src.blank) && p.src.blank[n-1] {
+		p.buf.WriteByte('\n')
+	}
+}
+
+// flushComments prints, at the current depth, every pending comment that
+// starts before line n.
+func (p *printer) flushComments(n int) {
+	if p.src == nil {
+		return
+	}
+	for p.next < len(p.src.comments) && p.src.comments[p.next].line < n {
+		c := p.src.comments[p.next]
+		p.next++
+		p.gap(c.line)
+		p.writeLine(0, c.text)
+	}
+}
+
+// closeBlock prints the comments that sit at the end of a block, after its
+// last statement at line last but before the code that follows the block.
+func (p *printer) closeBlock(first, last int) {
+	if p.src == nil || first <= 0 {
+		return
+	}
+	indent := p.src.indent[first]
+	for p.next < len(p.src.comments) {
+		c := p.src.comments[p.next]
+		if c.trailing || c.indent < indent || p.src.shallowerCodeBetween(last, c.line, indent) {
+			return
+		}
+		p.next++
+		p.gap(c.line)
+		p.writeLine(0, c.text)
+	}
+}
+
+func (p *printer) statements(stmts []ast.Statement) {
+	first, last := 0, 0
+	for _, s := range stmts {
+		n := p.startLine(s)
+		if s == nil || n < p.skipUntil {
+			continue
+		}
+		if es, ok := s.(*ast.ExpressionStatement); ok && es.Expression == nil {
+			continue
+		}
+		p.flushComments(n)
+		p.gap(n)
+		p.statement(s, n)
+		if first == 0 {
+			first = n
+		}
+		last = n
+	}
+	p.closeBlock(first, last)
+}
+
+// body prints an indented block with an optional docstring.
+func (p *printer) body(doc *ast.StringLiteral, stmts []ast.Statement) {
+	p.depth++
+	p.blockStart = true
+	if doc != nil {
+		p.flushComments(doc.Token.Position.Line)
+		p.writeLine(doc.Token.Position.Line, docString(doc.Value))
+	}
+	p.statements(stmts)
+	p.depth--
+}
+
+func (p *printer) block(b *ast.BlockStatement) {
+	if b == nil {
+		p.body(nil, nil)
+		return
+	}
+	p.body(nil, b.Statements)
+}
+
+func (p *printer) statement(s ast.Statement, n int) {
+	switch s := s.(type) {
+	case *ast.ExpressionStatement:
+		p.writeLine(n, p.expr(s.Expression))
+	case *ast.AssignStatement:
+		target := p.expr(s.Name)
+		if s.TypeHint != nil {
+			target += ": " + p.expr(s.TypeHint)
+		}
+		if s.Declare {
+			target = "var " + target
+		}
+		if s.Value == nil {
+			p.writeLine(n, target)
+		} else {
+			p.writeLine(n, target+" "+s.Operator+" "+p.expr(s.Value))
+		}
+	case *ast.ReturnStatement:
+		if s.ReturnValue == nil {
+			p.writeLine(n, "return")
+		} else {
+			p.writeLine(n, "return "+p.expr(s.ReturnValue))
+		}
+	case *ast.IfStatement:
+		p.writeLine(n, "if "+p.expr(s.Condition)+":")
+		p.block(s.Consequence)
+		for _, branch := range s.OtherwiseBranches {
+			line := branch.Token.Position.Line
+			p.flushComments(line)
+			p.writeLine(line, "otherwise "+p.expr(branch.Condition)+":")
+			p.block(branch.Consequence)
+		}
+		p.clause(n, "else:", s.Alternative)
+	case *ast.ForStatement:
+		header := "for " + p.loopVariable(s.Variable) + " in " + p.expr(s.Iterable)
+		if s.Label != nil {
+			header += " as " + s.Label.Value
+		}
+		p.writeLine(n, header+":")
+		p.block(s.Body)
+		p.clause(n, "else:", s.Alternative)
+	case *ast.AutocloseStatement:
+		header := "autoclose " + p.expr(s.Resource)
+		if s.Alias != nil {
+			header += " as " + s.Alias.Value
+		}
+		p.writeLine(n, header+":")
+		p.block(s.Body)
+	case *ast.WhileStatement:
+		header := "while " + p.expr(s.Condition)
+		if s.Label != nil {
+			header += " as " + s.Label.Value
+		}
+		p.writeLine(n, header+":")
+		p.block(s.Body)
+		p.clause(n, "else:", s.Alternative)
+	case *ast.FunctionDefinition:
+		for _, d := range s.Decorators {
+			p.writeLine(ast.StartPosition(d).Line, "@"+p.expr(d))
+		}
+		n = s.Token.Position.Line
+		p.writeLine(n, p.spellHeader(s.Name, s.Parameters)+":")
+		var stmts []ast.Statement
+		if s.Body != nil {
+			stmts = s.Body.Statements
+		}
+		p.body(s.DocString, stmts)
+	case *ast.GrimoireDefinition:
+		header := "grim " + s.Name.Value
+		if s.Inherits != nil {
+			header += "(" + s.Inherits.Value + ")"
+		}
+		if s.Data {
+			p.writeLine(n, "@data")
+			n = s.Token.Position.Line
+		}
+		p.writeLine(n, header+":")
+		var methods []ast.Statement
+		for _, f := range s.Fields {
+			methods = append(methods, f)
+		}
+		if s.InitMethod != nil {
+			methods = append(methods, s.InitMethod)
+		}
+		for _, m := range s.Methods {
+			methods = append(methods, m)
+		}
+		sort.SliceStable(methods, func(i, j int) bool {
+			return ast.StartPosition(methods[i]).Line < ast.StartPosition(methods[j]).Line
+		})
+		p.body(s.DocString, methods)
+	case *ast.ExtendStatement:
+		p.writeLine(n, "extend "+s.Name.Value+":")
+		methods := make([]ast.Statement, len(s.Methods))
+		for i, m := range s.Methods {
+			methods[i] = m
+		}
+		p.body(s.DocString, methods)
+	case *ast.ArcaneGrimoire:
+		p.verbatim(s, n)
+	case *ast.RecordDefinition:
+		p.writeLine(n, "record "+s.Name.Value+"("+identifiers(s.Fields)+")")
+	case *ast.ImportStatement:
+		line := "import " + quote(s.FilePath.Value)
+		if s.Lazy {
+			line = "lazy " + line
+		}
+		if s.Alias != nil {
+			line += " as " + s.Alias.Value
+		}
+		if len(s.Exposed) > 0 {
+			line += " expose " + identifiers(s.Exposed)
+		}
+		p.writeLine(n, line)
+	case *ast.MatchStatement:
+		p.writeLine(n, "match "+p.expr(s.MatchValue)+":")
+		p.depth++
+		for _, c := range s.Cases {
+			line := c.Token.Position.Line
+			p.flushComments(line)
+			p.writeLine(line, "case "+p.expr(c.Condition)+":")
+			p.block(c.Body)
+		}
+		if s.Default != nil {
+			line := s.Default.Token.Position.Line
+			p.flushComments(line)
+			p.writeLine(line, "_:")
+			p.block(s.Default.Body)
+		}
+		p.depth--
+	case *ast.AttemptStatement:
+		p.writeLine(n, "attempt:")
+		p.block(s.TryBlock)
+		for _, clause := range s.EnsnareClauses {
+			line := clause.Token.Position.Line
+			header := "ensnare"
+			if clause.Condition != nil {
+				header += " (" + p.expr(clause.Condition) + ")"
+			}
+			if clause.Alias != nil {
+				header += " as " + clause.Alias.Value
+			}
+			p.flushComments(line)
+			p.writeLine(line, header+":")
+			p.block(clause.Consequence)
+		}
+		p.clause(n, "resolve:", s.ResolveBlock)
+	case *ast.RaiseStatement:
+		switch {
+		case s.Error == nil:
+			p.writeLine(n, "raise")
+		case s.Cause != nil:
+			p.writeLine(n, "raise "+p.expr(s.Error)+" from "+p.expr(s.Cause))
+		default:
+			p.writeLine(n, "raise "+p.expr(s.Error))
+		}
+	case *ast.IgnoreStatement:
+		p.writeLine(n, "ignore")
+	case *ast.StopStatement:
+		p.writeLine(n, withLabel("stop", s.Label))
+	case *ast.SkipStatement:
+		p.writeLine(n, withLabel("skip", s.Label))
+	case *ast.CheckStatement:
+		args := p.expr(s.Condition)
+		if s.Message != nil {
+			args += ", " + p.expr(s.Message)
+		}
+		p.writeLine(n, "check("+args+")")
+	case *ast.PragmaStatement:
+		line := "pragma " + s.Name.Value
+		if s.Value != nil {
+			line += " " + p.expr(s.Value)
+		}
+		p.writeLine(n, line)
+	case *ast.ScopeStatement:
+		p.writeLine(n, s.Token.Literal+" "+identifiers(s.Names))
+	case *ast.ExportStatement:
+		p.writeLine(n, "export "+identifiers(s.Names))
+	default:
+		p.writeLine(n, s.String())
+	}
+}
+
+// clause prints an optional `else:` or `resolve:` block of the statement
+// starting at line n. The AST does not record where the keyword was, so it
+// is taken to be the last line before the block's body that is indented no
+// deeper than the statement itself.
+func (p *printer) clause(n int, header string, b *ast.BlockStatement) {
+	if b == nil {
+		return
+	}
+	line := 0
+	if p.src != nil && len(b.Statements) > 0 {
+		for i := ast.StartPosition(b.Statements[0]).Line; i > n; i-- {
+			if p.src.code[i] && p.src.indent[i] <= p.src.indent[n] {
+				line = i
+				break
+			}
+		}
+	}
+	p.flushComments(line)
+	p.writeLine(line, header)
+	p.block(b)
+}
+
+// verbatim copies the construct starting at line n from the original
+// source, re-indented to the current depth. It is used for arcane
+// grimoires, whose abstract method bodies the parser does not keep.
+func (p *printer) verbatim(s ast.Statement, n int) {
+	if p.src == nil {
+		p.writeLine(n, s.String())
+		return
+	}
+	end := p.src.nextCodeLine(n, p.src.indent[n])
+	for end-1 > n && p.src.blank[end-1] {
+		end--
+	}
+	lines := p.src.lines
+	for i := n; i < end && i <= len(lines); i++ {
+		line := lines[i-1]
+		if strings.TrimSpace(line) == "" {
+			p.buf.WriteByte('\n')
+			continue
+		}
+		strip := p.src.indent[n]
+		if p.src.indent[i] < strip {
+			strip = p.src.indent[i]
+		}
+		p.buf.WriteString(strings.Repeat(indentUnit, p.depth))
+		p.buf.WriteString(strings.TrimRight(dropIndent(line, strip), " \t\r"))
+		p.buf.WriteByte('\n')
+	}
+	for p.next < len(p.src.comments) && p.src.comments[p.next].line < end {
+		p.next++
+	}
+	p.skipUntil = end
+	p.blockStart = false
+}
+
+func (p *printer) spellHeader(name *ast.Identifier, params []*ast.Parameter) string {
+	list := make([]string, len(params))
+	for i, param := range params {
+		if param == nil {
+			continue
+		}
+		s := param.Name.Value
+		if param.TypeHint != nil {
+			s += ": " + p.expr(param.TypeHint)
+		}
+		if param.DefaultValue != nil {
+			// Hinted parameters space out their default like an assignment.
+			if param.TypeHint != nil {
+				s += " = "
+			} else {
+				s += "="
+			}
+			if param.Once {
+				s += "once "
+			}
+			s += p.expr(param.DefaultValue)
+		}
+		list[i] = s
+	}
+	if name.Value == "init" {
+		return "init(" + strings.Join(list, ", ") + ")"
+	}
+	return "spell " + name.Value + "(" + strings.Join(list, ", ") + ")"
+}
+
+func (p *printer) loopVariable(v ast.Expression) string {
+	if tuple, ok := v.(*ast.TupleLiteral); ok {
+		return p.list(tuple.Elements)
+	}
+	return p.expr(v)
+}
+
+func (p *printer) list(exprs []ast.Expression) string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = p.expr(e)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (p *printer) expr(e ast.Expression) string {
+	switch e := e.(type) {
+	case nil:
+		return ""
+	case *ast.Identifier:
+		return e.Value
+	case *ast.IntegerLiteral:
+		return e.Token.Literal
+	case *ast.FloatLiteral:
+		return e.Token.Literal
+	case *ast.Boolean:
+		if e.Value {
+			return "True"
+		}
+		return "False"
+	case *ast.NoneLiteral:
+		return "None"
+	case *ast.StringLiteral:
+		if e.Token.Type == token.DOCSTRING {
+			return docString(e.Value)
+		}
+		return quote(e.Value)
+	case *ast.FStringLiteral:
+		return p.fstring(e)
+	case *ast.BytesLiteral:
+		return (&object.Bytes{Value: e.Value}).Inspect()
+	case *ast.PrefixExpression:
+		right := p.operand(e.Right, parser.PREFIX)
+		if e.Operator == "not" {
+			return "not " + right
+		}
+		if strings.HasPrefix(right, "-") || strings.HasPrefix(right, "+") {
+			right = "(" + right + ")"
+		}
+		return e.Operator + right
+	case *ast.InfixExpression:
+		prec := parser.Precedence(e.Token.Type)
+		return p.operand(e.Left, prec) + " " + e.Operator + " " + p.operand(e.Right, prec+1)
+	case *ast.PostfixExpression:
+		return p.operand(e.Left, parser.CALL) + e.Operator
+	case *ast.CallExpression:
+		return p.operand(e.Function, parser.CALL) + "(" + p.list(e.Arguments) + ")"
+	case *ast.DotExpression:
+		left := p.operand(e.Left, parser.CALL)
+		switch e.Left.(type) {
+		case *ast.IntegerLiteral, *ast.FloatLiteral:
+			left = "(" + left + ")"
+		}
+		return left + "." + e.Right.Value
+	case *ast.IndexExpression:
+		return p.operand(e.Left, parser.CALL) + "[" + p.expr(e.Index) + "]"
+	case *ast.RangeExpression:
+		return p.expr(e.Start) + ":" + p.expr(e.End)
+	case *ast.RangePattern:
+		return p.expr(e.Start) + ".." + p.expr(e.End)
+	case *ast.ComparisonPattern:
+		return e.Operator + " " + p.expr(e.Value)
+	case *ast.ArrayLiteral:
+		return "[" + p.list(e.Elements) + "]"
+	case *ast.TupleLiteral:
+		return "(" + p.list(e.Elements) + ")"
+	case *ast.HashLiteral:
+		keys := make([]ast.Expression, 0, len(e.Pairs))
+		for k := range e.Pairs {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := ast.StartPosition(keys[i]), ast.StartPosition(keys[j])
+			if a.Line != b.Line {
+				return a.Line < b.Line
+			}
+			return a.Column < b.Column
+		})
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			pairs[i] = p.expr(k) + ": " + p.expr(e.Pairs[k])
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	}
+	return e.String()
+}
+
+// operand prints e, parenthesised if it binds more loosely than min.
+func (p *printer) operand(e ast.Expression, min int) string {
+	if precedence(e) < min {
+		return "(" + p.expr(e) + ")"
+	}
+	return p.expr(e)
+}
+
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.PostfixExpression:
+		return parser.POSTFIX
+	case *ast.CallExpression, *ast.DotExpression, *ast.IndexExpression:
+		return parser.CALL
+	}
+	return atom
+}
+
+// startLine returns the source line a statement starts on, including any
+// decorator line above it.
+func (p *printer) startLine(s ast.Statement) int {
+	n := ast.StartPosition(s).Line
+	if f, ok := s.(*ast.FunctionDefinition); ok && len(f.Decorators) > 0 {
+		return ast.StartPosition(f.Decorators[0]).Line
+	}
+	if g, ok := s.(*ast.GrimoireDefinition); ok && g.Data && p.src != nil {
+		for i := n - 1; i > 0; i-- {
+			if p.src.code[i] {
+				return i
+			}
+		}
+	}
+	return n
+}
+
+func withLabel(keyword string, label *ast.Identifier) string {
+	if label == nil {
+		return keyword
+	}
+	return keyword + " " + label.Value
+}
+
+func identifiers(ids []*ast.Identifier) string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = id.Value
+	}
+	return strings.Join(names, ", ")
+}
+
+// quote writes s as a string literal, preferring double quotes.
+func quote(s string) string {
+	q := byte('"')
+	if strings.IndexByte(s, '"') >= 0 && strings.IndexByte(s, '\'') < 0 {
+		q = '\''
+	}
+	return quoteWith(s, q)
+}
+
+// quoteWith writes s as a string literal in the quote q.
+func quoteWith(s string, q byte) string {
+	var b strings.Builder
+	b.WriteByte(q)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case q:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case 0:
+			b.WriteString(`\0`)
+		default:
+			if c < ' ' || c == 0x7f {
+				fmt.Fprintf(&b, `\x%02x`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte(q)
+	return b.String()
+}
+
+var braces = strings.NewReplacer("{", "{{", "}", "}}")
+
+// fstring writes an f-string, escaping its text as quote does, with braces
+// doubled, and writing each field's expression as any other.
+func (p *printer) fstring(e *ast.FStringLiteral) string {
+	var text strings.Builder
+	for _, part := range e.Parts {
+		if t, ok := part.(*ast.FStringText); ok {
+			text.WriteString(t.Value)
+		}
+	}
+	q := quote(text.String())[0]
+	var b strings.Builder
+	b.WriteString("f")
+	b.WriteByte(q)
+	for _, part := range e.Parts {
+		switch part := part.(type) {
+		case *ast.FStringText:
+			quoted := quoteWith(part.Value, q)
+			b.WriteString(braces.Replace(quoted[1 : len(quoted)-1]))
+		case *ast.FStringExpr:
+			field := p.expr(part.Expr)
+			if strings.HasPrefix(field, "{") {
+				field = " " + field + " "
+			}
+			b.WriteString("{" + field)
+			if part.Spec != "" {
+				b.WriteString(":" + part.Spec)
+			}
+			b.WriteString("}")
+		}
+	}
+	b.WriteByte(q)
+	return b.String()
+}
+
+// docString writes s as a triple-quoted string, keeping its line breaks.
+func docString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	if strings.Contains(s, `"""`) || strings.HasSuffix(s, `"`) {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return `"""` + s + `"""`
+}
@@ -0,0 +1,155 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x=1+2*3", "x = 1 + 2 * 3\n"},
+		{"x = (1 + 2) * 3", "x = (1 + 2) * 3\n"},
+		{"x = 1 - (2 - 3)", "x = 1 - (2 - 3)\n"},
+		{"x = ((1 - 2)) - 3", "x = 1 - 2 - 3\n"},
+		{"y = not (a and b)", "y = not (a and b)\n"},
+		{"y = -(-x)", "y = -(-x)\n"},
+		{"y = (-x).abs()", "y = (-x).abs()\n"},
+		{"x += 1", "x += 1\n"},
+		{"x: int = 5", "x: int = 5\n"},
+		{"print('it\\'s', \"a \\\"b\\\"\")", "print(\"it's\", 'a \"b\"')\n"},
+		{"s = f'{name}!\\n'", "s = f\"{name}!\\n\"\n"},
+		{"s = \"\\u00e9\\x07\\0\\a\"", "s = \"é\\x07\\0\\x07\"\n"},
+		{"s = f\"{{{d[\"k\"]:>4}}} { {1: 2}[1]}\"", "s = f\"{{{d[\"k\"]:>4}}} { {1: 2}[1] }\"\n"},
+		{"d = {\"b\":1,\"a\":[1,2]}", "d = {\"b\": 1, \"a\": [1, 2]}\n"},
+		{"s = [a[1:], a[:2], a[i]]", "s = [a[1:], a[:2], a[i]]\n"},
+		{"t = (1,2)", "t = (1, 2)\n"},
+		{"if (x>1): print(x)", "if x > 1:\n    print(x)\n"},
+		{
+			"if x:\n  a()\notherwise y:\n  b()\nelse:\n  c()",
+			"if x:\n    a()\notherwise y:\n    b()\nelse:\n    c()\n",
+		},
+		{
+			"for k,v in pairs as rows:\n        skip rows\nelse:\n        stop",
+			"for k, v in pairs as rows:\n    skip rows\nelse:\n    stop\n",
+		},
+		{
+			"spell add(a,b=2,c:int=3,d=once f()):\n  \"\"\"Adds.\"\"\"\n  return a+b",
+			"spell add(a, b=2, c: int = 3, d=once f()):\n    \"\"\"Adds.\"\"\"\n    return a + b\n",
+		},
+		{
+			"grim Dog(Animal):\n  spell bark():\n    return 1\n  init(name):\n    self.name=name",
+			"grim Dog(Animal):\n    spell bark():\n        return 1\n    init(name):\n        self.name = name\n",
+		},
+		{
+			"attempt:\n  risky()\nensnare ValueError as e:\n  print(e)\nresolve:\n  done()",
+			"attempt:\n    risky()\nensnare (ValueError) as e:\n    print(e)\nresolve:\n    done()\n",
+		},
+		{
+			"attempt:\n  risky()\nensnare as e:\n  raise Failed(e.message) from e\nensnare:\n  raise",
+			"attempt:\n    risky()\nensnare as e:\n    raise Failed(e.message) from e\nensnare:\n    raise\n",
+		},
+		{
+			"match x:\n  case 1:\n    a()\n  _:\n    b()",
+			"match x:\n    case 1:\n        a()\n    _:\n        b()\n",
+		},
+		{
+			"match n:\n  case >100:\n    a()\n  case 1 .. 10:\n    b()",
+			"match n:\n    case > 100:\n        a()\n    case 1..10:\n        b()\n",
+		},
+		{"record Point(x,y)", "record Point(x, y)\n"},
+		{
+			"extend String:\n  \"\"\"Extras.\"\"\"\n  spell shout():\n    return self.value+'!'",
+			"extend String:\n    \"\"\"Extras.\"\"\"\n    spell shout():\n        return self.value + \"!\"\n",
+		},
+		{
+			"@data\ngrim Point:\n  x:int\n  y=0\n  spell norm():\n    return x",
+			"@data\ngrim Point:\n    x: int\n    y = 0\n    spell norm():\n        return x\n",
+		},
+		{"@data\ngrim User:\n  past:[Address]", "@data\ngrim User:\n    past: [Address]\n"},
+		{"@log\n@retry( 3 )\nspell f():\n  return 1", "@log\n@retry(3)\nspell f():\n    return 1\n"},
+		{"import \"lib.crl\" as lib", "import \"lib.crl\" as lib\n"},
+		{"import \"shapes\" expose  area,Circle", "import \"shapes\" expose area, Circle\n"},
+		{"check (x==1, \"bad\")", "check(x == 1, \"bad\")\n"},
+		{"autoclose  open_db( ) as db:\n  db.query()", "autoclose open_db() as db:\n    db.query()\n"},
+		{"pragma strict\nvar n:int=1\nn+=1", "pragma strict\nvar n: int = 1\nn += 1\n"},
+	}
+
+	for _, tt := range tests {
+		out, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("Source(%q) =\n%s\nwant\n%s", tt.input, out, tt.expected)
+		}
+	}
+}
+
+func TestSourceKeepsCommentsAndBlankLines(t *testing.T) {
+	input := `// Greeting helpers.
+import "lib.crl"   // shared code
+
+
+
+/* Say hello
+   politely. */
+spell greet(name):
+  // build the message
+  msg = "hi " + name  // no punctuation
+  if name == "":
+      return None
+  // fall back to the plain greeting
+  else:
+      print(msg)
+      // printed above
+  return msg
+// end of file`
+
+	expected := `// Greeting helpers.
+import "lib.crl" // shared code
+
+/* Say hello
+   politely. */
+spell greet(name):
+    // build the message
+    msg = "hi " + name // no punctuation
+    if name == "":
+        return None
+    // fall back to the plain greeting
+    else:
+        print(msg)
+        // printed above
+    return msg
+// end of file
+`
+
+	out, err := Source([]byte(input))
+	if err != nil {
+		t.Fatalf("Source returned error: %v", err)
+	}
+	if string(out) != expected {
+		t.Fatalf("Source =\n%s\nwant\n%s", out, expected)
+	}
+
+	again, err := Source(out)
+	if err != nil {
+		t.Fatalf("formatting the output returned error: %v", err)
+	}
+	if string(again) != string(out) {
+		t.Errorf("formatting is not idempotent:\n%s", again)
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	_, err := Source([]byte("x = (1 +"))
+	if err == nil {
+		t.Fatal("expected an error for invalid source")
+	}
+	if !strings.Contains(err.Error(), "no right-hand expression") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package formatter
+
+import "strings"
+
+// comment is a `//` or `/* */` comment found in the original source. The
+// parser drops comments, so they are recovered here and woven back in by
+// line number.
+type comment struct {
+	line     int    // 1-based line the comment starts on
+	indent   int    // indentation of that line
+	text     string // comment text including its delimiters
+	trailing bool   // the comment follows code on the same line
+}
+
+// sourceInfo holds the layout facts about the original source that the AST
+// does not keep.
+type sourceInfo struct {
+	lines    []string
+	comments []comment
+	blank    []bool // blank[n] reports whether line n is empty
+	code     []bool // code[n] reports whether line n holds any code
+	indent   []int  // indentation of each line
+}
+
+// scanSource collects the comments, blank lines and code lines of src,
+// skipping over string literals the same way the lexer does.
+func scanSource(src string) *sourceInfo {
+	lines := strings.Split(src, "\n")
+	info := &sourceInfo{
+		lines:  lines,
+		blank:  make([]bool, len(lines)+2),
+		code:   make([]bool, len(lines)+2),
+		indent: make([]int, len(lines)+2),
+	}
+
+	var quote byte
+	triple := false
+	var block *comment
+	for i, line := range lines {
+		n := i + 1
+		info.indent[n] = measureIndent(line)
+		if quote == 0 && block == nil && strings.TrimSpace(line) == "" {
+			info.blank[n] = true
+			continue
+		}
+
+		for j := 0; j < len(line); j++ {
+			c := line[j]
+			switch {
+			case block != nil:
+				end := strings.Index(line[j:], "*/")
+				if end < 0 {
+					block.text += strings.TrimRight(line[j:], " \t\r") + "\n"
+					j = len(line)
+					continue
+				}
+				block.text += line[j : j+end+2]
+				info.comments = append(info.comments, *block)
+				block = nil
+				j += end + 1
+			case quote != 0:
+				info.code[n] = true
+				if c == '\\' {
+					j++
+				} else if triple && strings.HasPrefix(line[j:], strings.Repeat(string(quote), 3)) {
+					quote = 0
+					j += 2
+				} else if !triple && c == quote {
+					quote = 0
+				}
+			case c == ' ' || c == '\t' || c == '\r':
+			case strings.HasPrefix(line[j:], "//"):
+				info.comments = append(info.comments, comment{
+					line:     n,
+					indent:   info.indent[n],
+					text:     strings.TrimRight(line[j:], " \t\r"),
+					trailing: info.code[n],
+				})
+				j = len(line)
+			case strings.HasPrefix(line[j:], "/*"):
+				block = &comment{line: n, indent: info.indent[n], text: "/*", trailing: info.code[n]}
+				j++
+			case c == '"' || c == '\'':
+				info.code[n] = true
+				quote = c
+				triple = strings.HasPrefix(line[j:], strings.Repeat(string(c), 3))
+				if triple {
+					j += 2
+				}
+			default:
+				info.code[n] = true
+			}
+		}
+		// Single-quoted strings never continue onto the next line.
+		if !triple {
+			quote = 0
+		}
+	}
+	if block != nil {
+		info.comments = append(info.comments, *block)
+	}
+	return info
+}
+
+// shallowerCodeBetween reports whether any code line in (from, to] is
+// indented less than indent, i.e. whether the block starting at indent has
+// already been closed by line to.
+func (s *sourceInfo) shallowerCodeBetween(from, to, indent int) bool {
+	for n := from + 1; n <= to && n < len(s.code); n++ {
+		if s.code[n] && s.indent[n] < indent {
+			return true
+		}
+	}
+	return false
+}
+
+// nextCodeLine returns the first line after from that holds code and is
+// indented no deeper than indent, or a line past the end of the source.
+func (s *sourceInfo) nextCodeLine(from, indent int) int {
+	for n := from + 1; n < len(s.code); n++ {
+		if s.code[n] && s.indent[n] <= indent {
+			return n
+		}
+	}
+	return len(s.code)
+}
+
+// dropIndent removes width columns of indentation from the start of line.
+func dropIndent(line string, width int) string {
+	i, w := 0, 0
+	for i < len(line) && w < width && (line[i] == ' ' || line[i] == '\t') {
+		if line[i] == '\t' {
+			w += 4
+		} else {
+			w++
+		}
+		i++
+	}
+	return line[i:]
+}
+
+func measureIndent(line string) int {
+	count := 0
+	for _, ch := range line {
+		if ch == ' ' {
+			count++
+		} else if ch == '\t' {
+			count += 4
+		} else {
+			break
+		}
+	}
+	return count
+}
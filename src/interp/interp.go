@@ -0,0 +1,239 @@
+// Package interp embeds the Carrion interpreter in a Go program, for using
+// Carrion as a scripting layer. Values cross between Go and Carrion as plain
+// Go values, converted with object.ToGo and object.FromGo, or as typed ones
+// with GetInto and RegisterFunc, which use object.Unmarshal:
+//
+//	in, err := interp.New()
+//	if err != nil {
+//		return err
+//	}
+//	in.Set("limit", 3)
+//	in.RegisterBuiltin("greet", func(args ...any) (any, error) {
+//		return fmt.Sprintf("hello, %v", args[0]), nil
+//	})
+//	value, err := in.Eval(`greet("crow") + " x" + str(limit)`)
+package interp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/javanhut/Carrion/src/errfmt"
+	"github.com/javanhut/Carrion/src/evaluator"
+	"github.com/javanhut/Carrion/src/lexer"
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/parser"
+)
+
+// SourceName is the file name that errors report for source given to Eval.
+const SourceName = "<eval>"
+
+// Interpreter is one Carrion interpreter. Its globals persist from one call
+// to Eval to the next. An Interpreter must not be used from more than one
+// goroutine at a time, but separate Interpreters are independent.
+type Interpreter struct {
+	env    *object.Environment
+	ctx    *evaluator.EvalContext
+	format errfmt.Formatter
+}
+
+// New returns an interpreter with the standard library loaded, writing to
+// os.Stdout and os.Stderr.
+func New() (*Interpreter, error) {
+	env := object.NewEnvironment()
+	ctx := evaluator.NewEvalContext(SourceName)
+	env.SetContext(ctx)
+	if err := evaluator.LoadMuninStdlib(env); err != nil {
+		return nil, fmt.Errorf("failed to load stdlib: %w", err)
+	}
+	return &Interpreter{env: env, ctx: ctx}, nil
+}
+
+// SetStdout sends what the program prints to w.
+func (in *Interpreter) SetStdout(w io.Writer) {
+	in.ctx.SetStdout(w)
+}
+
+// SetStderr sends the error output of commands the program runs to w.
+func (in *Interpreter) SetStderr(w io.Writer) {
+	in.ctx.SetStderr(w)
+}
+
+// SetContext makes builtins the program waits in, such as osSleep and
+// osRunCommand, give up once c is done, raising a TimeoutError or
+// CancelledError in the program.
+func (in *Interpreter) SetContext(c context.Context) {
+	in.ctx.SetContext(c)
+}
+
+// SetCoerceStrings makes + join a string and a number in this interpreter,
+// instead of raising a type mismatch.
+func (in *Interpreter) SetCoerceStrings(coerce bool) {
+	in.ctx.SetCoerceStrings(coerce)
+}
+
+// SetStrictMatch makes a match statement without a default raise a
+// MatchError in this interpreter when no case fits.
+func (in *Interpreter) SetStrictMatch(strict bool) {
+	in.ctx.SetStrictMatch(strict)
+}
+
+// SetErrorFormat makes the Error and ParseError that Eval returns render
+// their messages with f, such as errfmt.JSON{}, instead of as the errors'
+// Inspect text.
+func (in *Interpreter) SetErrorFormat(f errfmt.Formatter) {
+	in.format = f
+}
+
+// Error is a Carrion error that a program raised and did not ensnare, or an
+// internal error of the interpreter.
+type Error struct {
+	Object object.Object // an *object.Error, *object.CustomError or *object.InternalError
+	format errfmt.Formatter
+}
+
+func (e *Error) Error() string {
+	if e.format != nil {
+		return e.format.Runtime(e.Object)
+	}
+	return e.Object.Inspect()
+}
+
+// ParseError reports source that is not valid Carrion.
+type ParseError struct {
+	Errors      []string
+	Diagnostics []parser.ParseError // the same errors with where they were found
+	format      errfmt.Formatter
+}
+
+func (e *ParseError) Error() string {
+	if e.format != nil {
+		return e.format.Syntax(e.Diagnostics)
+	}
+	return strings.Join(e.Errors, "\n")
+}
+
+// Eval runs source and returns the value of its last statement as a Go
+// value. A value that has no Go equivalent, such as a spell or an instance
+// of an ordinary grimoire, is returned as its object.Object, which can be
+// passed back to Set. A program that fails returns an *Error, and one that
+// does not parse a *ParseError.
+func (in *Interpreter) Eval(source string) (any, error) {
+	return in.eval(source, SourceName)
+}
+
+// EvalFile runs the Carrion file at path like Eval. Its imports are resolved
+// relative to the file.
+func (in *Interpreter) EvalFile(path string) (any, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return in.eval(string(source), path)
+}
+
+func (in *Interpreter) eval(source, file string) (any, error) {
+	p := parser.New(lexer.New(source, file))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, &ParseError{Errors: p.Errors(), Diagnostics: p.ParseErrors(), format: in.format}
+	}
+	result := evaluator.SafeEval(program, in.env)
+	if isFailure(result) {
+		return nil, &Error{Object: result, format: in.format}
+	}
+	return toGo(result), nil
+}
+
+// Set assigns a global variable, converting value with object.FromGo.
+func (in *Interpreter) Set(name string, value any) error {
+	obj, err := object.FromGo(value)
+	if err != nil {
+		return fmt.Errorf("setting %s: %w", name, err)
+	}
+	in.env.Set(name, obj)
+	return nil
+}
+
+// Get returns the value of a global variable, converted as for Eval, and
+// whether it is defined.
+func (in *Interpreter) Get(name string) (any, bool) {
+	obj, ok := in.env.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return toGo(obj), true
+}
+
+// RegisterBuiltin makes fn callable from Carrion as name. Its arguments are
+// converted to Go values as for Eval, and what it returns with object.FromGo;
+// a non-nil error is raised in the program as an error with its message.
+func (in *Interpreter) RegisterBuiltin(name string, fn func(args ...any) (any, error)) {
+	in.env.Set(name, &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			values := make([]any, len(args))
+			for i, arg := range args {
+				values[i] = toGo(arg)
+			}
+			result, err := fn(values...)
+			if err != nil {
+				return &object.Error{Message: fmt.Sprintf("%s: %s", name, err)}
+			}
+			obj, err := object.FromGo(result)
+			if err != nil {
+				return &object.Error{Message: fmt.Sprintf("%s returned a value Carrion cannot use: %s", name, err)}
+			}
+			return obj
+		},
+	})
+}
+
+// GetInto stores the value of a global variable in the Go value v points
+// to, converting it with object.Unmarshal.
+func (in *Interpreter) GetInto(name string, v any) error {
+	obj, ok := in.env.Get(name)
+	if !ok {
+		return fmt.Errorf("%s is not defined", name)
+	}
+	if err := object.Unmarshal(obj, v); err != nil {
+		return fmt.Errorf("getting %s: %w", name, err)
+	}
+	return nil
+}
+
+// RegisterFunc makes fn, which must be a Go function, callable from Carrion
+// as name. Each argument is converted to the type of the matching parameter
+// with object.Unmarshal, and a variadic final parameter takes the remaining
+// arguments. fn may return nothing, a value, an error, or a value and an
+// error; the value is converted with object.FromGo and the error is raised
+// in the program as for RegisterBuiltin.
+func (in *Interpreter) RegisterFunc(name string, fn any) error {
+	builtin, err := object.NewGoFunc(name, fn)
+	if err != nil {
+		return fmt.Errorf("RegisterFunc %s: %w", name, err)
+	}
+	in.env.Set(name, builtin)
+	return nil
+}
+
+func toGo(obj object.Object) any {
+	value, err := object.ToGo(obj)
+	if err != nil {
+		return obj
+	}
+	return value
+}
+
+func isFailure(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.CUSTOM_ERROR_OBJ, object.INTERNAL_ERROR_OBJ:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package interp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/Carrion/src/errfmt"
+	"github.com/javanhut/Carrion/src/object"
+)
+
+func newInterpreter(t *testing.T) *Interpreter {
+	t.Helper()
+	in, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return in
+}
+
+func TestEvalAndGlobals(t *testing.T) {
+	in := newInterpreter(t)
+	if err := in.Set("config", map[string]any{"name": "crow", "sizes": []int{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Eval(`total = len(config["sizes"])`); err != nil {
+		t.Fatal(err)
+	}
+	value, err := in.Eval(`config["name"] + "!"`)
+	if err != nil || value != "crow!" {
+		t.Errorf("expected crow!, got %v, %v", value, err)
+	}
+	if total, ok := in.Get("total"); !ok || total != int64(3) {
+		t.Errorf("expected total to be 3, got %v, %v", total, ok)
+	}
+	if _, ok := in.Get("missing"); ok {
+		t.Error("expected missing to be undefined")
+	}
+
+	value, err = in.Eval("spell double(x):\n    return x * 2\ndouble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := value.(*object.Function); !ok {
+		t.Errorf("expected a spell to come back as an object, got %T", value)
+	}
+
+	if err := in.Set("bad", make(chan int)); err == nil {
+		t.Error("expected an error setting a channel")
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	in := newInterpreter(t)
+	_, err := in.Eval(`1 + "one"`)
+	var evalErr *Error
+	if !errors.As(err, &evalErr) || !strings.Contains(err.Error(), "type mismatch: INTEGER + STRING") {
+		t.Errorf("expected a type mismatch error, got %v", err)
+	}
+
+	_, err = in.Eval("x = )")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || len(parseErr.Errors) == 0 {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+
+	// A failed evaluation leaves the interpreter usable.
+	if value, err := in.Eval("1 + 1"); err != nil || value != int64(2) {
+		t.Errorf("expected 2, got %v, %v", value, err)
+	}
+
+	in.SetErrorFormat(errfmt.Short{})
+	if _, err := in.Eval(`1 + "one"`); err == nil || err.Error() != "Error: type mismatch: INTEGER + STRING" {
+		t.Errorf("expected the short format, got %v", err)
+	}
+	if _, err := in.Eval("x = )"); err == nil || !strings.HasPrefix(err.Error(), "<eval>:1:") {
+		t.Errorf("expected the short format with a position, got %v", err)
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	in := newInterpreter(t)
+	var received []any
+	in.RegisterBuiltin("lookup", func(args ...any) (any, error) {
+		received = args
+		if args[0] == "missing" {
+			return nil, fmt.Errorf("no such key")
+		}
+		return map[string]int{"hits": 7}, nil
+	})
+
+	value, err := in.Eval(`lookup("page", [1, 2.5], True)["hits"] + 1`)
+	if err != nil || value != int64(8) {
+		t.Fatalf("expected 8, got %v, %v", value, err)
+	}
+	if want := []any{"page", []any{int64(1), 2.5}, true}; !reflect.DeepEqual(received, want) {
+		t.Errorf("expected arguments %v, got %v", want, received)
+	}
+
+	_, err = in.Eval(`lookup("missing")`)
+	if err == nil || !strings.Contains(err.Error(), "lookup: no such key") {
+		t.Errorf("expected the builtin's error, got %v", err)
+	}
+}
+
+type order struct {
+	Item     string   `carrion:"item"`
+	Quantity int      `carrion:"quantity"`
+	Notes    []string `carrion:"notes"`
+}
+
+func TestTypedValues(t *testing.T) {
+	in := newInterpreter(t)
+	if err := in.Set("base", order{Item: "nails", Quantity: 10}); err != nil {
+		t.Fatal(err)
+	}
+	err := in.RegisterFunc("restock", func(o order, extra ...int) (order, error) {
+		if o.Quantity < 0 {
+			return order{}, fmt.Errorf("negative quantity")
+		}
+		for _, n := range extra {
+			o.Quantity += n
+		}
+		o.Notes = append(o.Notes, "restocked")
+		return o, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in.RegisterFunc("log", func(message string) {})
+
+	if _, err := in.Eval(`log("go")` + "\n" + `result = restock(base, 1, 2)`); err != nil {
+		t.Fatal(err)
+	}
+	var got order
+	if err := in.GetInto("result", &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (order{"nails", 13, []string{"restocked"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	errors := map[string]string{
+		`restock()`:                     "restock takes at least 1 arguments, got 0",
+		`restock({"quantity": -1})`:     "restock: negative quantity",
+		`restock({"quantity": "lots"})`: "restock: argument 1: value.quantity: cannot unmarshal STRING into Go int",
+		`log("a", "b")`:                 "log takes 1 arguments, got 2",
+	}
+	for input, message := range errors {
+		if _, err := in.Eval(input); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("%s: expected error %q, got %v", input, message, err)
+		}
+	}
+	if err := in.RegisterFunc("bad", 3); err == nil {
+		t.Error("expected an error registering a non-function")
+	}
+	if err := in.GetInto("missing", &got); err == nil || err.Error() != "missing is not defined" {
+		t.Errorf("expected an undefined variable error, got %v", err)
+	}
+}
+
+func TestOutputAndFiles(t *testing.T) {
+	in := newInterpreter(t)
+	var out bytes.Buffer
+	in.SetStdout(&out)
+	if _, err := in.Eval(`print("hi")`); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "hi  \n" {
+		t.Errorf("expected print to write to the configured stdout, got %q", out.String())
+	}
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"lib.crl":  "spell square(x):\n    return x * x\n",
+		"main.crl": "import \"lib\" expose square\nsquare(4)\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if value, err := in.EvalFile(filepath.Join(dir, "main.crl")); err != nil || value != int64(16) {
+		t.Errorf("expected 16, got %v, %v", value, err)
+	}
+}
+
+func TestInterpretersAreIndependent(t *testing.T) {
+	a, b := newInterpreter(t), newInterpreter(t)
+	a.Set("x", 1)
+	if _, ok := b.Get("x"); ok {
+		t.Error("a global set in one interpreter is visible in another")
+	}
+
+	a.SetCoerceStrings(true)
+	if got, err := a.Eval(`"x" + 1`); err != nil || got != "x1" {
+		t.Errorf("expected coercion in a, got %v, %v", got, err)
+	}
+	if _, err := b.Eval(`"x" + 1`); err == nil {
+		t.Error("coercion in one interpreter applies to another")
+	}
+}
@@ -0,0 +1,165 @@
+// Package kernel exposes a persistent Carrion session over a simple
+// request/response protocol so that editors and notebook frontends can drive
+// the interpreter. Requests and responses are JSON objects, one per line.
+package kernel
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/javanhut/Carrion/src/evaluator"
+	"github.com/javanhut/Carrion/src/lexer"
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/parser"
+)
+
+const (
+	OpExecute  = "execute"
+	OpReset    = "reset"
+	OpShutdown = "shutdown"
+
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// Request is a single message sent by a frontend.
+type Request struct {
+	ID   json.RawMessage `json:"id,omitempty"`
+	Op   string          `json:"op,omitempty"` // defaults to "execute"
+	Code string          `json:"code,omitempty"`
+}
+
+// Response is the kernel's reply to a Request.
+type Response struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Status string          `json:"status"`
+	Result string          `json:"result,omitempty"`
+	Output string          `json:"output,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Session is a persistent interpreter whose global environment survives
+// across executions.
+type Session struct {
+	env *object.Environment
+	out bytes.Buffer
+}
+
+// NewSession creates a session with the Munin standard library loaded.
+func NewSession() (*Session, error) {
+	s := &Session{}
+	if err := s.Reset(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reset discards all state and starts over with a fresh environment.
+func (s *Session) Reset() error {
+	env := object.NewEnvironment()
+	ctx := evaluator.NewEvalContext("<kernel>")
+	ctx.SetStdout(&s.out)
+	env.SetContext(ctx)
+	if err := evaluator.LoadMuninStdlib(env); err != nil {
+		return fmt.Errorf("failed to load stdlib: %w", err)
+	}
+	s.env = env
+	return nil
+}
+
+// Execute evaluates code in the session and reports its result along with
+// anything it printed.
+func (s *Session) Execute(code string) Response {
+	s.out.Reset()
+	defer s.out.Reset()
+
+	l := lexer.New(code, "<kernel>")
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return Response{Status: StatusError, Error: strings.Join(p.Errors(), "\n")}
+	}
+
+	result := evaluator.SafeEval(program, s.env)
+	resp := Response{Status: StatusOK, Output: s.out.String()}
+	if result == nil {
+		return resp
+	}
+	if result.Type() == object.ERROR_OBJ || result.Type() == object.CUSTOM_ERROR_OBJ ||
+		result.Type() == object.INTERNAL_ERROR_OBJ {
+		resp.Status = StatusError
+		resp.Error = result.Inspect()
+		return resp
+	}
+	if result.Type() != object.NONE_OBJ {
+		resp.Result = result.Inspect()
+	}
+	return resp
+}
+
+// Serve runs a single session, reading requests from r and writing
+// responses to w until r is exhausted or a shutdown request arrives.
+func Serve(r io.Reader, w io.Writer) error {
+	session, err := NewSession()
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(r)
+	enc := json.NewEncoder(w)
+	for {
+		var req Request
+		if err := dec.Decode(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return enc.Encode(Response{Status: StatusError, Error: "malformed request: " + err.Error()})
+		}
+
+		var resp Response
+		switch req.Op {
+		case "", OpExecute:
+			resp = session.Execute(req.Code)
+		case OpReset:
+			resp = Response{Status: StatusOK}
+			if err := session.Reset(); err != nil {
+				resp = Response{Status: StatusError, Error: err.Error()}
+			}
+		case OpShutdown:
+			return enc.Encode(Response{ID: req.ID, Status: StatusOK})
+		default:
+			resp = Response{Status: StatusError, Error: "unknown op: " + req.Op}
+		}
+		resp.ID = req.ID
+
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// ListenAndServe accepts connections on a local unix socket, giving each
+// connection its own session.
+func ListenAndServe(socketPath string) error {
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			Serve(conn, conn)
+		}()
+	}
+}
@@ -0,0 +1,51 @@
+package kernel
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	input := strings.Join([]string{
+		`{"id": 1, "code": "x = 20"}`,
+		`{"id": 2, "code": "print(x + 1)\nx * 2"}`,
+		`{"id": 3, "code": "y +"}`,
+		`{"id": 4, "op": "reset"}`,
+		`{"id": 5, "code": "x"}`,
+		`{"id": 6, "op": "shutdown"}`,
+		`{"id": 7, "code": "x"}`,
+	}, "\n")
+
+	var out bytes.Buffer
+	if err := Serve(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Serve returned error: %v", err)
+	}
+
+	var responses []Response
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var resp Response
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("bad response: %v", err)
+		}
+		responses = append(responses, resp)
+	}
+
+	if len(responses) != 6 {
+		t.Fatalf("expected 6 responses, got=%d", len(responses))
+	}
+	if responses[1].Result != "40" || !strings.Contains(responses[1].Output, "21") {
+		t.Errorf("unexpected execute response: %+v", responses[1])
+	}
+	if responses[2].Status != StatusError {
+		t.Errorf("expected parse error, got=%+v", responses[2])
+	}
+	if responses[4].Status != StatusError || !strings.Contains(responses[4].Error, "identifier not found: x") {
+		t.Errorf("expected reset to clear x, got=%+v", responses[4])
+	}
+	if string(responses[5].ID) != "6" {
+		t.Errorf("expected shutdown reply for id 6, got=%s", responses[5].ID)
+	}
+}
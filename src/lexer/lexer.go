@@ -1,8 +1,11 @@
 package lexer
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/javanhut/Carrion/src/token"
 )
@@ -17,6 +20,10 @@ type Lexer struct {
 	fileName    string
 
 	indentResolved bool
+	// depth counts the brackets open at the current position. Inside them
+	// line breaks and indentation are ignored, so literals and argument
+	// lists can span lines.
+	depth int
 }
 
 func New(input string, fileName ...string) *Lexer {
@@ -47,25 +54,39 @@ func (l *Lexer) NextToken() token.Token {
 			Literal: "", 
 			Position: token.Position{
 				Line: l.lineIndex + 1, 
-				Column: l.charIndex + 1,
+				Column: l.column(),
 				File: l.fileName,
 			},
 		}
 	}
 
+	if l.charIndex == 0 && !l.indentResolved && l.depth > 0 {
+		l.indentResolved = true
+	}
+
 	if l.charIndex == 0 && !l.indentResolved {
+		if isBlankLine(l.currLine) {
+			// Blank and comment-only lines never open or close blocks.
+			l.advanceLine()
+			return l.NextToken()
+		}
 		l.indentResolved = true
 		newIndent := measureIndent(l.currLine)
 		return l.handleIndentChange(newIndent)
 	}
 
+	if l.charIndex >= len(l.currLine) && l.depth > 0 {
+		l.advanceLine()
+		return l.NextToken()
+	}
+
 	if l.charIndex >= len(l.currLine) {
 		tok := token.Token{
 			Type: token.NEWLINE, 
 			Literal: "\\n",
 			Position: token.Position{
 				Line: l.lineIndex + 1, 
-				Column: l.charIndex + 1,
+				Column: l.column(),
 				File: l.fileName,
 			},
 		}
@@ -89,9 +110,17 @@ func (l *Lexer) NextToken() token.Token {
 		return l.readIdentifier()
 	}
 
+	if ch == 'b' {
+		next := l.peekChar()
+		if next == '"' || next == '\'' {
+			return l.readBytes()
+		}
+		return l.readIdentifier()
+	}
+
 	position := token.Position{
 		Line:   l.lineIndex + 1,
-		Column: l.charIndex + 1,
+		Column: l.column(),
 		File:   l.fileName,
 	}
 
@@ -279,6 +308,14 @@ func (l *Lexer) NextToken() token.Token {
 		}
 
 	case '~':
+		if l.peekChar() == '/' {
+			l.charIndex += 2
+			return token.Token{
+				Type:     token.FLOORDIV,
+				Literal:  "~/",
+				Position: position,
+			}
+		}
 		l.charIndex++
 		return token.Token{
 			Type:     token.TILDE,
@@ -327,6 +364,7 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	case '(':
 		l.charIndex++
+		l.depth++
 		return token.Token{
 			Type:     token.LPAREN,
 			Literal:  "(",
@@ -335,6 +373,9 @@ func (l *Lexer) NextToken() token.Token {
 
 	case ')':
 		l.charIndex++
+		if l.depth > 0 {
+			l.depth--
+		}
 		return token.Token{
 			Type:     token.RPAREN,
 			Literal:  ")",
@@ -343,6 +384,7 @@ func (l *Lexer) NextToken() token.Token {
 
 	case '[':
 		l.charIndex++
+		l.depth++
 		return token.Token{
 			Type:     token.LBRACK,
 			Literal:  "[",
@@ -351,6 +393,9 @@ func (l *Lexer) NextToken() token.Token {
 
 	case ']':
 		l.charIndex++
+		if l.depth > 0 {
+			l.depth--
+		}
 		return token.Token{
 			Type:     token.RBRACK,
 			Literal:  "]",
@@ -359,6 +404,7 @@ func (l *Lexer) NextToken() token.Token {
 
 	case '{':
 		l.charIndex++
+		l.depth++
 		return token.Token{
 			Type:     token.LBRACE,
 			Literal:  "{",
@@ -367,6 +413,9 @@ func (l *Lexer) NextToken() token.Token {
 
 	case '}':
 		l.charIndex++
+		if l.depth > 0 {
+			l.depth--
+		}
 		return token.Token{
 			Type:     token.RBRACE,
 			Literal:  "}",
@@ -374,6 +423,14 @@ func (l *Lexer) NextToken() token.Token {
 		}
 
 	case '.':
+		if l.peekChar() == '.' {
+			l.charIndex += 2
+			return token.Token{
+				Type:     token.DOTDOT,
+				Literal:  "..",
+				Position: position,
+			}
+		}
 		l.charIndex++
 		return token.Token{
 			Type:     token.DOT,
@@ -418,26 +475,48 @@ func (l *Lexer) NextToken() token.Token {
 	case '\'':
 		return l.readString()
 
+	case '\\':
+		// A backslash at the end of a line joins the next line to it, and
+		// that line's indentation is just a space.
+		if strings.TrimRight(l.currLine[l.charIndex+1:], " \t\r") != "" {
+			l.charIndex++
+			return token.Token{
+				Type:     token.ILLEGAL,
+				Literal:  "a \\ that continues a line must be the last character on it",
+				Position: position,
+			}
+		}
+		l.advanceLine()
+		l.indentResolved = true
+		return l.NextToken()
+
 	default:
-		if isLetter(ch) {
+		r, size := utf8.DecodeRuneInString(l.currLine[l.charIndex:])
+		if isLetter(r) {
 			return l.readIdentifier()
 		} else if isDigit(ch) {
 			return l.readNumber()
 		} else {
-			l.charIndex++
+			l.charIndex += size
 			return token.Token{
 				Type:     token.ILLEGAL,
-				Literal:  string(ch),
+				Literal:  string(r),
 				Position: position,
 			}
 		}
 	}
 }
 
+// readFString reads an f-string. Its text has escapes replaced as in other
+// strings, except that {{ and }} are kept for the parser to tell apart from
+// the braces around fields. The source of each {field} is kept as written:
+// brackets, braces and strings inside it, even ones in the f-string's own
+// quotes, are read through to the brace that closes the field, so
+// f"{names["ada"]}" and f"{ {"a": 1}["a"] }" are whole.
 func (l *Lexer) readFString() token.Token {
 	position := token.Position{
 		Line:   l.lineIndex + 1,
-		Column: l.charIndex,
+		Column: l.column() - 1,
 		File:   l.fileName,
 	}
 
@@ -458,90 +537,89 @@ func (l *Lexer) readFString() token.Token {
 		isTriple = true
 		l.charIndex += 2
 	}
+	closes := func() bool {
+		if !isTriple {
+			return l.currLine[l.charIndex] == openingQuote
+		}
+		return l.charIndex+2 < len(l.currLine) &&
+			l.currLine[l.charIndex] == openingQuote &&
+			l.currLine[l.charIndex+1] == openingQuote &&
+			l.currLine[l.charIndex+2] == openingQuote
+	}
 
 	var sb strings.Builder
-
-	if isTriple {
-		for {
-			if l.charIndex >= len(l.currLine) {
-				sb.WriteByte('\n')
-				l.advanceLine()
-				if l.finished {
-					break
-				}
-				continue
-			}
-
-			if l.charIndex+2 < len(l.currLine) &&
-				l.currLine[l.charIndex] == openingQuote &&
-				l.currLine[l.charIndex+1] == openingQuote &&
-				l.currLine[l.charIndex+2] == openingQuote {
-				l.charIndex += 3
+	var bad escapeError
+	depth := 0     // brackets open in the current field, counting its {
+	var inner byte // the quote of a string inside the field, if in one
+	for {
+		if l.charIndex >= len(l.currLine) {
+			if !isTriple {
 				break
 			}
-			ch := l.currLine[l.charIndex]
-
-			if ch == '\\' {
-				l.charIndex++
-				if l.charIndex < len(l.currLine) {
-					esc := l.currLine[l.charIndex]
-					switch esc {
-					case 'n':
-						sb.WriteByte('\n')
-					case 't':
-						sb.WriteByte('\t')
-					case 'r':
-						sb.WriteByte('\r')
-					case '\\':
-						sb.WriteByte('\\')
-					case openingQuote:
-						sb.WriteByte(openingQuote)
-					default:
-						sb.WriteByte(esc)
-					}
-				}
+			if depth > 0 {
+				sb.WriteByte(' ')
 			} else {
-				sb.WriteByte(ch)
+				sb.WriteByte('\n')
 			}
-			l.charIndex++
-		}
-	} else {
-		for {
-			if l.charIndex >= len(l.currLine) {
+			l.advanceLine()
+			if l.finished {
 				break
 			}
-			ch := l.currLine[l.charIndex]
+			continue
+		}
+		ch := l.currLine[l.charIndex]
 
-			if ch == openingQuote {
+		switch {
+		case inner != 0:
+			// A string inside a field is copied as written, escapes
+			// and all, for the parser to read.
+			sb.WriteByte(ch)
+			if ch == '\\' && l.charIndex+1 < len(l.currLine) {
 				l.charIndex++
-				break
+				sb.WriteByte(l.currLine[l.charIndex])
+			} else if ch == inner {
+				inner = 0
 			}
-			if ch == '\\' {
-				l.charIndex++
-				if l.charIndex < len(l.currLine) {
-					esc := l.currLine[l.charIndex]
-					switch esc {
-					case 'n':
-						sb.WriteByte('\n')
-					case 't':
-						sb.WriteByte('\t')
-					case 'r':
-						sb.WriteByte('\r')
-					case '\\':
-						sb.WriteByte('\\')
-					case openingQuote:
-						sb.WriteByte(openingQuote)
-					default:
-						sb.WriteByte(esc)
-					}
-				}
-			} else {
-				sb.WriteByte(ch)
+		case depth > 0:
+			switch ch {
+			case '"', '\'':
+				inner = ch
+			case '{', '[', '(':
+				depth++
+			case '}', ']', ')':
+				depth--
+			}
+			sb.WriteByte(ch)
+		case closes():
+			if isTriple {
+				l.charIndex += 2
 			}
 			l.charIndex++
+			if bad.message != "" {
+				return bad.token()
+			}
+			return token.Token{Type: token.FSTRING, Literal: sb.String(), Position: position}
+		case ch == '{' && l.peekChar() == '{', ch == '}' && l.peekChar() == '}':
+			sb.WriteString(l.currLine[l.charIndex : l.charIndex+2])
+			l.charIndex++
+		case ch == '{':
+			depth = 1
+			sb.WriteByte(ch)
+		case ch == '\\':
+			// A brace written as an escape is literal text, so it is
+			// doubled like {{ and }}.
+			var esc strings.Builder
+			bad.note(l.readEscape(&esc, false))
+			sb.WriteString(strings.NewReplacer("{", "{{", "}", "}}").Replace(esc.String()))
+		default:
+			sb.WriteByte(ch)
 		}
+		l.charIndex++
 	}
 
+	if bad.message != "" {
+		return bad.token()
+	}
 	return token.Token{
 		Type:     token.FSTRING,
 		Literal:  sb.String(),
@@ -550,12 +628,11 @@ func (l *Lexer) readFString() token.Token {
 }
 
 func (l *Lexer) peekCharIsLetterOrDigitOrUnderscore() bool {
-	nxt := l.peekChar()
-
-	if nxt == 0 {
+	if l.charIndex+1 >= len(l.currLine) {
 		return false
 	}
-	return isLetterOrDigit(nxt) || nxt == '_'
+	r, _ := utf8.DecodeRuneInString(l.currLine[l.charIndex+1:])
+	return isLetterOrDigit(r)
 }
 
 func (l *Lexer) skipLineComment() {
@@ -586,7 +663,7 @@ func (l *Lexer) skipBlockComment() {
 func (l *Lexer) handleIndentChange(newIndent int) token.Token {
 	position := token.Position{
 		Line:   l.lineIndex + 1,
-		Column: l.charIndex + 1,
+		Column: l.column(),
 		File:   l.fileName,
 	}
 	
@@ -612,6 +689,10 @@ func (l *Lexer) handleIndentChange(newIndent int) token.Token {
 	}
 
 	l.indentStack = l.indentStack[:len(l.indentStack)-1]
+	if newIndent < l.indentStack[len(l.indentStack)-1] {
+		// Closing several blocks at once: emit one DEDENT per level.
+		l.indentResolved = false
+	}
 	return token.Token{
 		Type:     token.DEDENT,
 		Literal:  "",
@@ -631,6 +712,13 @@ func (l *Lexer) advanceLine() {
 	l.currLine = l.lines[l.lineIndex]
 }
 
+// column is the column of the current position for token positions,
+// counting characters rather than bytes, as editors do.
+func (l *Lexer) column() int {
+	n := min(l.charIndex, len(l.currLine))
+	return utf8.RuneCountInString(l.currLine[:n]) + l.charIndex - n + 1
+}
+
 func (l *Lexer) peekChar() byte {
 	if l.charIndex+1 >= len(l.currLine) {
 		return 0
@@ -638,6 +726,13 @@ func (l *Lexer) peekChar() byte {
 	return l.currLine[l.charIndex+1]
 }
 
+// isBlankLine reports whether a line holds nothing but whitespace or a
+// line comment.
+func isBlankLine(line string) bool {
+	trimmed := strings.TrimLeft(line, " \t\r")
+	return trimmed == "" || strings.HasPrefix(trimmed, "//")
+}
+
 func measureIndent(line string) int {
 	count := 0
 	for _, ch := range line {
@@ -655,7 +750,7 @@ func measureIndent(line string) int {
 func (l *Lexer) readString() token.Token {
 	position := token.Position{
 		Line:   l.lineIndex + 1,
-		Column: l.charIndex + 1,
+		Column: l.column(),
 		File:   l.fileName,
 	}
 	
@@ -671,6 +766,7 @@ func (l *Lexer) readString() token.Token {
 	}
 
 	var sb strings.Builder
+	var bad escapeError
 
 	if isTriple {
 		for {
@@ -692,29 +788,15 @@ func (l *Lexer) readString() token.Token {
 			}
 			ch := l.currLine[l.charIndex]
 			if ch == '\\' {
-				l.charIndex++
-				if l.charIndex < len(l.currLine) {
-					esc := l.currLine[l.charIndex]
-					switch esc {
-					case 'n':
-						sb.WriteByte('\n')
-					case 't':
-						sb.WriteByte('\t')
-					case 'r':
-						sb.WriteByte('\r')
-					case '\\':
-						sb.WriteByte('\\')
-					case byte(quoteChar):
-						sb.WriteByte(quoteChar)
-					default:
-						sb.WriteByte(esc)
-					}
-				}
+				bad.note(l.readEscape(&sb, false))
 			} else {
 				sb.WriteByte(ch)
 			}
 			l.charIndex++
 		}
+		if bad.message != "" {
+			return bad.token()
+		}
 		return token.Token{
 			Type:     token.DOCSTRING,
 			Literal:  sb.String(),
@@ -731,29 +813,15 @@ func (l *Lexer) readString() token.Token {
 				break
 			}
 			if ch == '\\' {
-				l.charIndex++
-				if l.charIndex < len(l.currLine) {
-					esc := l.currLine[l.charIndex]
-					switch esc {
-					case 'n':
-						sb.WriteByte('\n')
-					case 't':
-						sb.WriteByte('\t')
-					case 'r':
-						sb.WriteByte('\r')
-					case '\\':
-						sb.WriteByte('\\')
-					case byte(quoteChar):
-						sb.WriteByte(quoteChar)
-					default:
-						sb.WriteByte(esc)
-					}
-				}
+				bad.note(l.readEscape(&sb, false))
 			} else {
 				sb.WriteByte(ch)
 			}
 			l.charIndex++
 		}
+		if bad.message != "" {
+			return bad.token()
+		}
 		return token.Token{
 			Type:     token.STRING,
 			Literal:  sb.String(),
@@ -762,16 +830,128 @@ func (l *Lexer) readString() token.Token {
 	}
 }
 
+// readBytes reads a b"..." literal on one line. It takes the escapes of a
+// string, except that \xHH is any byte and there are no \u or \U escapes;
+// other characters stand for their UTF-8 encoding. The token's literal is
+// the bytes themselves.
+func (l *Lexer) readBytes() token.Token {
+	position := token.Position{
+		Line:   l.lineIndex + 1,
+		Column: l.column(),
+		File:   l.fileName,
+	}
+	l.charIndex++ // the b
+	quoteChar := l.currLine[l.charIndex]
+	l.charIndex++
+
+	var sb strings.Builder
+	var bad escapeError
+	for {
+		if l.charIndex >= len(l.currLine) {
+			return token.Token{
+				Type:     token.ILLEGAL,
+				Literal:  "unterminated bytes literal",
+				Position: position,
+			}
+		}
+		ch := l.currLine[l.charIndex]
+		if ch == quoteChar {
+			l.charIndex++
+			break
+		}
+		if ch == '\\' {
+			bad.note(l.readEscape(&sb, true))
+		} else {
+			sb.WriteByte(ch)
+		}
+		l.charIndex++
+	}
+	if bad.message != "" {
+		return bad.token()
+	}
+	return token.Token{
+		Type:     token.BYTES,
+		Literal:  sb.String(),
+		Position: position,
+	}
+}
+
+// escapeError is the first invalid escape in a literal. The rest of the
+// literal is still read, so that lexing carries on after it, and then an
+// ILLEGAL token reports the escape.
+type escapeError struct {
+	message  string
+	position token.Position
+}
+
+func (e *escapeError) note(err *escapeError) {
+	if err != nil && e.message == "" {
+		*e = *err
+	}
+}
+
+func (e *escapeError) token() token.Token {
+	return token.Token{Type: token.ILLEGAL, Literal: e.message, Position: e.position}
+}
+
+// escapes are the escapes of a single character.
+var escapes = map[byte]byte{
+	'n': '\n', 't': '\t', 'r': '\r', '0': 0, 'a': '\a', 'b': '\b', 'f': '\f', 'v': '\v',
+	'\\': '\\', '\'': '\'', '"': '"',
+}
+
+// readEscape writes what the escape whose backslash is at l.charIndex
+// stands for to sb, leaving l.charIndex on its last character. \xHH, \uHHHH
+// and \UHHHHHHHH give the character with that code, except that in bytes
+// literals \xHH is a single byte and \u and \U are not allowed. A backslash
+// at the end of a line stands for nothing.
+func (l *Lexer) readEscape(sb *strings.Builder, inBytes bool) *escapeError {
+	position := token.Position{Line: l.lineIndex + 1, Column: l.column(), File: l.fileName}
+	l.charIndex++
+	if l.charIndex >= len(l.currLine) {
+		return nil
+	}
+	esc := l.currLine[l.charIndex]
+	if b, ok := escapes[esc]; ok {
+		sb.WriteByte(b)
+		return nil
+	}
+	digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[esc]
+	if digits == 0 || inBytes && esc != 'x' {
+		r, _ := utf8.DecodeRuneInString(l.currLine[l.charIndex:])
+		return &escapeError{fmt.Sprintf("invalid escape sequence \\%c", r), position}
+	}
+	hex := l.currLine[l.charIndex+1 : min(l.charIndex+1+digits, len(l.currLine))]
+	code, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) < digits || err != nil {
+		return &escapeError{fmt.Sprintf("\\%c must be followed by %d hex digits", esc, digits), position}
+	}
+	l.charIndex += digits
+	switch {
+	case inBytes:
+		sb.WriteByte(byte(code))
+	case code > unicode.MaxRune || code >= 0xD800 && code < 0xE000:
+		return &escapeError{fmt.Sprintf("\\%c%s is not a valid character", esc, hex), position}
+	default:
+		sb.WriteRune(rune(code))
+	}
+	return nil
+}
+
 func (l *Lexer) readIdentifier() token.Token {
 	position := token.Position{
 		Line:   l.lineIndex + 1,
-		Column: l.charIndex + 1,
+		Column: l.column(),
 		File:   l.fileName,
 	}
 	
 	start := l.charIndex
-	for l.charIndex < len(l.currLine) && isLetterOrDigit(l.currLine[l.charIndex]) {
-		l.charIndex++
+	for l.charIndex < len(l.currLine) {
+		r, size := utf8.DecodeRuneInString(l.currLine[l.charIndex:])
+		if !isLetterOrDigit(r) {
+			break
+		}
+		l.charIndex += size
 	}
 	literal := l.currLine[start:l.charIndex]
 	tokType := token.LookupIdent(literal)
@@ -782,27 +962,57 @@ func (l *Lexer) readIdentifier() token.Token {
 	}
 }
 
-func isLetterOrDigit(ch byte) bool {
-	return isLetter(ch) || unicode.IsDigit(rune(ch))
+// isLetterOrDigit reports whether r may continue an identifier: a letter,
+// digit or underscore, or a combining mark such as the accent of a
+// decomposed é.
+func isLetterOrDigit(r rune) bool {
+	return isLetter(r) || unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc)
 }
 
+// readNumber reads an integer or float literal. Integers may be written in
+// hex, octal or binary as 0xFF, 0o755 or 0b1010, and floats with an
+// exponent as 1e9 or 2.5e-3. Underscores may separate digits, as in
+// 1_000_000; the parser rejects any that do not sit between two digits.
 func (l *Lexer) readNumber() token.Token {
 	position := token.Position{
 		Line:   l.lineIndex + 1,
-		Column: l.charIndex + 1,
+		Column: l.column(),
 		File:   l.fileName,
 	}
-	
+
 	start := l.charIndex
+	if l.currLine[l.charIndex] == '0' && strings.IndexByte("xXoObB", l.peekChar()) >= 0 {
+		l.charIndex += 2
+		for l.charIndex < len(l.currLine) && (isHexDigit(l.currLine[l.charIndex]) || l.currLine[l.charIndex] == '_') {
+			l.charIndex++
+		}
+		return token.Token{
+			Type:     token.INT,
+			Literal:  l.currLine[start:l.charIndex],
+			Position: position,
+		}
+	}
+
 	isFloat := false
 	for l.charIndex < len(l.currLine) {
 		ch := l.currLine[l.charIndex]
 		if ch == '.' {
-			if isFloat {
+			// A second dot starts a range such as 1..10.
+			if isFloat || (l.charIndex+1 < len(l.currLine) && l.currLine[l.charIndex+1] == '.') {
 				break
 			}
 			isFloat = true
-		} else if !isDigit(ch) {
+		} else if (ch == 'e' || ch == 'E') && l.exponentFollows() {
+			isFloat = true
+			l.charIndex++
+			if sign := l.currLine[l.charIndex]; sign == '+' || sign == '-' {
+				l.charIndex++
+			}
+			for l.charIndex < len(l.currLine) && (isDigit(l.currLine[l.charIndex]) || l.currLine[l.charIndex] == '_') {
+				l.charIndex++
+			}
+			break
+		} else if !isDigit(ch) && ch != '_' {
 			break
 		}
 		l.charIndex++
@@ -822,8 +1032,25 @@ func (l *Lexer) readNumber() token.Token {
 	}
 }
 
-func isLetter(ch byte) bool {
-	return unicode.IsLetter(rune(ch)) || ch == '_'
+// exponentFollows reports whether the e at the current position starts
+// the exponent of a number, being followed by digits with an optional
+// sign, rather than a name such as a method.
+func (l *Lexer) exponentFollows() bool {
+	i := l.charIndex + 1
+	if i < len(l.currLine) && (l.currLine[i] == '+' || l.currLine[i] == '-') {
+		i++
+	}
+	return i < len(l.currLine) && isDigit(l.currLine[i])
+}
+
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
+// isLetter reports whether r may start an identifier. Identifiers may be
+// written in any script, so café and 名前 are both names.
+func isLetter(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
 }
 
 func isDigit(ch byte) bool {
@@ -1,14 +1,36 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"runtime/debug"
+	"runtime/pprof"
+	"strings"
+	"time"
 
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/config"
+	"github.com/javanhut/Carrion/src/daemon"
+	"github.com/javanhut/Carrion/src/debugger"
+	"github.com/javanhut/Carrion/src/errfmt"
 	"github.com/javanhut/Carrion/src/evaluator"
+	"github.com/javanhut/Carrion/src/formatter"
+	"github.com/javanhut/Carrion/src/kernel"
 	"github.com/javanhut/Carrion/src/lexer"
+	"github.com/javanhut/Carrion/src/mod"
 	"github.com/javanhut/Carrion/src/object"
 	"github.com/javanhut/Carrion/src/parser"
 	"github.com/javanhut/Carrion/src/repl"
+	"github.com/javanhut/Carrion/src/selfbench"
+	"github.com/javanhut/Carrion/src/testrunner"
+	"github.com/javanhut/Carrion/src/watcher"
 )
 
 const CROW_IMAGE = `
@@ -30,6 +52,91 @@ const CROW_IMAGE = `
   `
 
 func main() {
+	args := os.Args[1:]
+	settings := loadConfig(args)
+	// Leading options apply to whatever runs next. --go-stack attaches the
+	// Go stack to internal errors for bug reports and --crash-report writes
+	// a report when a script fails; --profile and --pprof profile the
+	// script being run and --arena allocates its numbers and strings from
+	// arenas. The rest override the user's config.
+options:
+	for len(args) > 0 {
+		switch {
+		case args[0] == "--go-stack":
+			evaluator.ReportGoStack = true
+		case args[0] == "--arena":
+			evaluator.Arenas = true
+		case strings.HasPrefix(args[0], "--crash-report="):
+			crashDir = strings.TrimPrefix(args[0], "--crash-report=")
+		case args[0] == "--profile":
+			profileTable = true
+		case strings.HasPrefix(args[0], "--pprof="):
+			pprofFile = strings.TrimPrefix(args[0], "--pprof=")
+		case args[0] == "--no-config":
+			// Handled by loadConfig.
+		case strings.HasPrefix(args[0], "--color="):
+			if err := settings.SetColor(strings.TrimPrefix(args[0], "--color=")); err != nil {
+				fmt.Fprintf(os.Stderr, "carrion: %v\n", err)
+				os.Exit(2)
+			}
+		case strings.HasPrefix(args[0], "--prompt="):
+			settings.Prompt = strings.TrimPrefix(args[0], "--prompt=")
+		case strings.HasPrefix(args[0], "--search-path="):
+			settings.SearchPath = filepath.SplitList(strings.TrimPrefix(args[0], "--search-path="))
+		case args[0] == "--strict-types":
+			settings.StrictTypes = true
+		case args[0] == "--no-strict-types":
+			settings.StrictTypes = false
+		case args[0] == "--coerce-strings":
+			settings.CoerceStrings = true
+		case args[0] == "--no-coerce-strings":
+			settings.CoerceStrings = false
+		case args[0] == "--strict-match":
+			settings.StrictMatch = true
+		case args[0] == "--no-strict-match":
+			settings.StrictMatch = false
+		case strings.HasPrefix(args[0], "--error-format="):
+			settings.ErrorFormat = strings.TrimPrefix(args[0], "--error-format=")
+		default:
+			break options
+		}
+		args = args[1:]
+	}
+	applyConfig(settings)
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "kernel":
+			runKernel(args[1:])
+			return
+		case "fmt":
+			os.Exit(runFmt(args[1:]))
+		case "test":
+			os.Exit(runTests(args[1:]))
+		case "debug":
+			os.Exit(runDebug(args[1:]))
+		case "run":
+			os.Exit(runScript(args[1:]))
+		case "replay":
+			os.Exit(runReplay(args[1:]))
+		case "check":
+			os.Exit(runCheck(args[1:]))
+		case "selfbench":
+			os.Exit(runSelfbench(args[1:]))
+		case "mod":
+			os.Exit(runMod(args[1:]))
+		case "daemon":
+			os.Exit(runDaemon(args[1:]))
+		case "worker":
+			os.Exit(runWorker(args[1:]))
+		}
+	}
+
+	if len(args) > 0 {
+		status, _ := runFile(args[0])
+		os.Exit(status)
+	}
+
 	// Create a global environment
 	env := object.NewEnvironment()
 
@@ -40,39 +147,717 @@ func main() {
 		os.Exit(1)
 	}
 
-	if len(os.Args) > 1 {
-		// Get the filename from command line args
-		filename := os.Args[1]
-		
-		// Read file content
-		content, err := os.ReadFile(filename)
+	fmt.Printf("%s\n", CROW_IMAGE)
+	repl.Start(os.Stdin, os.Stdout, env)
+}
+
+var (
+	profileTable bool   // print where the script spent its time
+	pprofFile    string // write a Go CPU profile labelled by spell here
+	crashDir     string // write a crash report here when a script fails
+)
+
+// errorFormat renders the errors that stop a script.
+var errorFormat errfmt.Formatter = errfmt.Plain{}
+
+// loadConfig reads the user's config file, unless the leading options
+// include --no-config. A config file that cannot be read is reported and
+// ignored.
+func loadConfig(args []string) config.Config {
+	for _, arg := range args {
+		if arg == "--no-config" {
+			return config.Default()
+		}
+		if !strings.HasPrefix(arg, "--") {
+			break
+		}
+	}
+	settings, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "carrion: ignoring config: %v\n", err)
+		return config.Default()
+	}
+	return settings
+}
+
+// applyConfig puts settings into effect for the rest of the run.
+func applyConfig(settings config.Config) {
+	evaluator.StrictTypes = settings.StrictTypes
+	evaluator.CoerceStrings = settings.CoerceStrings
+	evaluator.StrictMatch = settings.StrictMatch
+	evaluator.SearchPath = settings.SearchPath
+	repl.Prompt = settings.Prompt
+	repl.Color = settings.UseColor(os.Stdout)
+	errorFormat = errfmt.Plain{}
+	if settings.UseColor(os.Stderr) {
+		errorFormat = errfmt.Pretty{}
+	}
+	if settings.ErrorFormat != "" {
+		format, ok := errfmt.Lookup(settings.ErrorFormat)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "carrion: unknown error format %q, expected one of %s\n",
+				settings.ErrorFormat, strings.Join(errfmt.Names(), ", "))
+			os.Exit(2)
+		}
+		errorFormat = format
+		repl.ErrorFormat = format
+	}
+}
+
+// startProfile attaches a profiler to ctx if profiling was asked for. The
+// returned function detaches it and writes the results.
+func startProfile(ctx *evaluator.EvalContext) (func(), error) {
+	if !profileTable && pprofFile == "" {
+		return func() {}, nil
+	}
+	profiler := evaluator.NewProfiler()
+	var out *os.File
+	if pprofFile != "" {
+		var err error
+		if out, err = os.Create(pprofFile); err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(out); err != nil {
+			out.Close()
+			return nil, err
+		}
+		profiler.Labels = true
+	}
+	ctx.SetProfiler(profiler)
+
+	return func() {
+		ctx.SetProfiler(nil)
+		profiler.Stop()
+		if out != nil {
+			pprof.StopCPUProfile()
+			out.Close()
+		}
+		if profileTable {
+			profiler.WriteReport(os.Stderr)
+		}
+	}, nil
+}
+
+// parseFile reads and parses a Carrion file, reporting any problem on
+// stderr. It returns nil if the file could not be read or parsed.
+func parseFile(filename string) *ast.Program {
+	return parseFileTo(filename, os.Stderr)
+}
+
+func parseFileTo(filename string, stderr io.Writer) *ast.Program {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading file: %v\n", err)
+		return nil
+	}
+	p := parser.New(lexer.New(string(content), filename))
+	p.SetVersion(mod.LanguageVersion(filename))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		fmt.Fprintf(stderr, "%s\n", errorFormat.Syntax(p.ParseErrors()))
+		return nil
+	}
+	return program
+}
+
+// runFile runs a Carrion file in a fresh interpreter with the standard
+// library loaded. It returns the exit status and the files the program
+// imported.
+func runFile(filename string) (int, []string) {
+	return runFileTo(filename, os.Stdout, os.Stderr)
+}
+
+// runFileTo is runFile with the program's output, and any error ending it,
+// written to stdout and stderr.
+func runFileTo(filename string, stdout, stderr io.Writer) (int, []string) {
+	program := parseFileTo(filename, stderr)
+	if program == nil {
+		return 1, nil
+	}
+
+	env := object.NewEnvironment()
+	ctx := evaluator.NewEvalContext(filename)
+	ctx.SetStdout(stdout)
+	ctx.SetStderr(stderr)
+	env.SetContext(ctx)
+	if err := evaluator.LoadMuninStdlib(env); err != nil {
+		fmt.Fprintf(stderr, "Failed to load stdlib: %v\n", err)
+		return 1, nil
+	}
+
+	stopProfile, err := startProfile(ctx)
+	if err != nil {
+		fmt.Fprintf(stderr, "profile: %v\n", err)
+		return 1, nil
+	}
+	if crashDir != "" {
+		ctx.RecordCrashes()
+	}
+	result := evaluator.SafeEval(program, env)
+	stopProfile()
+	// Handles still open have leaked; report them after any error.
+	defer evaluator.WriteLeakReport(stderr, ctx.OpenHandles())
+	if isFailure(result) {
+		fmt.Fprintf(stderr, "%s\n", errorFormat.Runtime(result))
+		if crashDir != "" {
+			if path, err := writeCrashReport(result, env); err != nil {
+				fmt.Fprintf(stderr, "crash report: %v\n", err)
+			} else {
+				fmt.Fprintf(stderr, "crash report written to %s\n", path)
+			}
+		}
+		return 1, ctx.ImportedFiles()
+	}
+	return 0, ctx.ImportedFiles()
+}
+
+// writeCrashReport saves a report of the error that ended a script in a new
+// file in crashDir and returns its path.
+func writeCrashReport(result object.Object, env *object.Environment) (string, error) {
+	if err := os.MkdirAll(crashDir, 0755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("carrion-crash-%s-%d.txt", time.Now().Format("20060102-150405"), os.Getpid())
+	path := filepath.Join(crashDir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := evaluator.WriteCrashReport(f, result, env, version()); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// version is the module version the binary was built from, or "(devel)"
+// for a build from a source checkout.
+func version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+func isFailure(result object.Object) bool {
+	return result != nil && (result.Type() == object.ERROR_OBJ || result.Type() == object.CUSTOM_ERROR_OBJ ||
+		result.Type() == object.INTERNAL_ERROR_OBJ)
+}
+
+// runScript implements `carrion run`. With -watch the script runs again,
+// in a fresh interpreter, each time it or a file it imports changes, until
+// the process is interrupted. It returns the exit status.
+func runScript(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	watch := flags.Bool("watch", false, "run again whenever the script or its imports change")
+	flags.BoolVar(&profileTable, "profile", profileTable, "print where the script spent its time")
+	flags.StringVar(&pprofFile, "pprof", pprofFile, "write a Go CPU profile labelled by spell to this file")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: carrion run [--watch] [--profile] [--pprof=file] file.crl")
+		return 2
+	}
+
+	filename := flags.Arg(0)
+	if !*watch {
+		status, _ := runFile(filename)
+		return status
+	}
+	for {
+		// The script is stamped before it runs so that saving it while it
+		// is still running is not missed; imports are only known afterwards.
+		before := watcher.Take([]string{filename})
+		status, imports := runFile(filename)
+		snapshot := watcher.Take(imports)
+		snapshot[filename] = before[filename]
+
+		fmt.Fprintf(os.Stderr, "[exit status %d; watching %d files for changes]\n", status, len(snapshot))
+		changed := snapshot.Wait(watcher.DefaultInterval)
+		fmt.Fprintf(os.Stderr, "[%s changed; running again]\n", strings.Join(changed, ", "))
+	}
+}
+
+// runKernel serves a persistent session over stdio, or over a unix socket
+// when --socket is given.
+func runKernel(args []string) {
+	fs := flag.NewFlagSet("kernel", flag.ExitOnError)
+	socket := fs.String("socket", "", "listen on a unix socket instead of stdio")
+	fs.Parse(args)
+
+	var err error
+	if *socket != "" {
+		err = kernel.ListenAndServe(*socket)
+	} else {
+		err = kernel.Serve(os.Stdin, os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kernel: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runFmt formats Carrion source. With no paths it filters stdin to stdout;
+// directories are searched for .crl files. -w rewrites files in place and
+// -check only lists the files that are not formatted. It returns the exit
+// status.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result back to each file")
+	check := flags.Bool("check", false, "list unformatted files and exit non-zero if there are any")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fmt: %v\n", err)
+			return 2
+		}
+		out, err := formatter.Source(src)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
-			os.Exit(1)
-		}
-		
-		// Create lexer and parser with filename
-		l := lexer.New(string(content), filename)
-		p := parser.New(l)
-		program := p.ParseProgram()
-		
-		if len(p.Errors()) > 0 {
-			for _, msg := range p.Errors() {
-				fmt.Fprintf(os.Stderr, "Error: %s\n", msg)
+			fmt.Fprintf(os.Stderr, "fmt: <stdin>: %v\n", err)
+			return 2
+		}
+		if *check {
+			if !bytes.Equal(src, out) {
+				fmt.Println("<stdin>")
+				return 1
 			}
-			os.Exit(1)
+			return 0
 		}
-		
-		// Evaluate program
-		result := evaluator.Eval(program, env)
-		
-		// Check for errors
-		if result != nil && result.Type() == object.ERROR_OBJ || result.Type() == object.CUSTOM_ERROR_OBJ {
-			fmt.Fprintf(os.Stderr, "%s\n", result.Inspect())
-			os.Exit(1)
+		os.Stdout.Write(out)
+		return 0
+	}
+
+	files, err := crlFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fmt: %v\n", err)
+		return 2
+	}
+
+	status := 0
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fmt: %v\n", err)
+			status = 2
+			continue
 		}
-	} else {
-		fmt.Printf("%s\n", CROW_IMAGE)
-		repl.Start(os.Stdin, os.Stdout, env)
+		out, err := formatter.Source(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fmt: %s: %v\n", name, err)
+			status = 2
+			continue
+		}
+		switch {
+		case *check:
+			if !bytes.Equal(src, out) {
+				fmt.Println(name)
+				if status == 0 {
+					status = 1
+				}
+			}
+		case *write:
+			if !bytes.Equal(src, out) {
+				if err := os.WriteFile(name, out, 0644); err != nil {
+					fmt.Fprintf(os.Stderr, "fmt: %v\n", err)
+					status = 2
+				}
+			}
+		default:
+			os.Stdout.Write(out)
+		}
+	}
+	return status
+}
+
+// crlFiles returns the .crl files under paths. Files named directly are
+// included whatever their extension.
+func crlFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && (name == path || filepath.Ext(name) == ".crl") {
+				files = append(files, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// diagnostic is how `carrion check` reports a parse error.
+type diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Message  string `json:"message"`
+	Expected string `json:"expected,omitempty"`
+	Found    string `json:"found,omitempty"`
+}
+
+// runCheck parses the given files, or the .crl files under the given
+// directories, without running them and writes every parse error to stdout
+// as a JSON array. It returns 1 if there were any errors and 2 if a file
+// could not be read.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.Parse(args)
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := crlFiles(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check: %v\n", err)
+		return 2
+	}
+	status := 0
+	diagnostics := []diagnostic{}
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "check: %v\n", err)
+			status = 2
+			continue
+		}
+		p := parser.New(lexer.New(string(src), name))
+		p.SetVersion(mod.LanguageVersion(name))
+		p.ParseProgram()
+		for _, e := range p.ParseErrors() {
+			diagnostics = append(diagnostics, diagnostic{
+				File:     name,
+				Line:     e.Position.Line,
+				Column:   e.Position.Column,
+				Message:  e.Message,
+				Expected: e.Expected,
+				Found:    e.Found,
+			})
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(diagnostics)
+	if status == 0 && len(diagnostics) > 0 {
+		status = 1
+	}
+	return status
+}
+
+// runSelfbench times the interpreter on the benchmark suite and prints the
+// results as JSON. Given a baseline from an earlier run, it fails if any
+// benchmark got slower by more than the threshold. It returns the exit
+// status.
+func runSelfbench(args []string) int {
+	flags := flag.NewFlagSet("selfbench", flag.ExitOnError)
+	runs := flags.Int("runs", selfbench.DefaultRuns, "how many times to run each benchmark")
+	baseline := flags.String("baseline", "", "compare against the JSON output of an earlier run")
+	threshold := flags.Float64("threshold", 10, "percent slowdown against the baseline that fails the run")
+	list := flags.Bool("list", false, "list the benchmarks and exit")
+	flags.Parse(args)
+
+	if *list {
+		for _, b := range selfbench.Benchmarks() {
+			fmt.Println(b.Name)
+		}
+		return 0
+	}
+
+	var previous selfbench.Report
+	if *baseline != "" {
+		data, err := os.ReadFile(*baseline)
+		if err == nil {
+			err = json.Unmarshal(data, &previous)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "selfbench: reading baseline: %v\n", err)
+			return 2
+		}
+	}
+
+	report, err := selfbench.Run(flags.Args(), *runs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "selfbench: %v\n", err)
+		return 2
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+
+	if *baseline == "" {
+		return 0
+	}
+	regressions := selfbench.Compare(previous, report, *threshold)
+	for _, r := range regressions {
+		fmt.Fprintf(os.Stderr, "regression: %s\n", r)
+	}
+	if len(regressions) > 0 {
+		return 1
+	}
+	return 0
+}
+
+const daemonUsage = `usage:
+  carrion daemon [--socket=path]                 serve scripts until stopped
+  carrion daemon [--socket=path] run file.crl    run a script in the daemon
+  carrion daemon [--socket=path] stop            stop the daemon`
+
+// runDaemon implements `carrion daemon`, which keeps an interpreter process
+// running to serve scripts, and the client commands that use it. `run`
+// runs the script in this process instead if no daemon is running. It
+// returns the exit status.
+func runDaemon(args []string) int {
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	socket := flags.String("socket", daemon.DefaultSocket(), "the daemon's Unix socket")
+	flags.Parse(args)
+
+	switch {
+	case flags.NArg() == 0:
+		ln, err := daemon.Listen(*socket)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "daemon: %v\n", err)
+			return 1
+		}
+		evaluator.CacheParses = true
+		fmt.Fprintf(os.Stderr, "carrion daemon listening on %s\n", *socket)
+		err = daemon.Serve(ln, func(file string, stdout, stderr io.Writer) int {
+			status, _ := runFileTo(file, stdout, stderr)
+			return status
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "daemon: %v\n", err)
+			return 1
+		}
+		return 0
+	case flags.Arg(0) == "run" && flags.NArg() == 2:
+		status, err := daemon.Run(*socket, flags.Arg(1), os.Stdout, os.Stderr)
+		if errors.Is(err, daemon.ErrNotRunning) {
+			status, _ = runFile(flags.Arg(1))
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "daemon: %v\n", err)
+			return 1
+		}
+		return status
+	case flags.Arg(0) == "stop" && flags.NArg() == 1:
+		if err := daemon.Stop(*socket); err != nil {
+			fmt.Fprintf(os.Stderr, "daemon: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, daemonUsage)
+	return 2
+}
+
+// runWorker runs a file as one worker of a pool made by workers.pool, then
+// serves the pool's calls to its spells. It returns the exit status.
+func runWorker(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: carrion worker file")
+		return 2
+	}
+	program := parseFile(args[0])
+	if program == nil {
+		return 1
+	}
+	env := object.NewEnvironment()
+	env.SetContext(evaluator.NewEvalContext(args[0]))
+	if err := evaluator.LoadMuninStdlib(env); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load stdlib: %v\n", err)
+		return 1
+	}
+	if result := evaluator.SafeEval(program, env); isFailure(result) {
+		fmt.Fprintf(os.Stderr, "%s\n", errorFormat.Runtime(result))
+		return 1
+	}
+	evaluator.ServeWorker(env)
+	return 0
+}
+
+const modUsage = `usage:
+  carrion mod init                   create an empty carrion.mod here
+  carrion mod get                    fetch every package carrion.mod requires
+  carrion mod get name version url   require a package, then fetch it
+  carrion mod list                   show the required packages`
+
+// runMod manages the packages listed in carrion.mod. It returns the exit
+// status.
+func runMod(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, modUsage)
+		return 2
+	}
+	if args[0] == "init" {
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, modUsage)
+			return 2
+		}
+		if _, err := os.Stat(mod.ManifestFile); err == nil {
+			fmt.Fprintf(os.Stderr, "mod: %s already exists\n", mod.ManifestFile)
+			return 1
+		}
+		if err := (&mod.Manifest{Path: mod.ManifestFile}).Write(); err != nil {
+			fmt.Fprintf(os.Stderr, "mod: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	path := mod.FindManifest(".")
+	if path == "" {
+		fmt.Fprintf(os.Stderr, "mod: no %s here or in any parent directory; run carrion mod init\n", mod.ManifestFile)
+		return 1
+	}
+	manifest, err := mod.ReadManifest(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mod: %v\n", err)
+		return 1
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		for _, r := range manifest.Requirements {
+			state := "missing"
+			if r.Cached() {
+				state = r.Dir()
+			}
+			fmt.Printf("%s %s %s\n", r.Name, r.Version, state)
+		}
+		return 0
+	case args[0] == "get" && (len(args) == 1 || len(args) == 4):
+		if len(args) == 4 {
+			if err := manifest.Require(mod.Requirement{Name: args[1], Version: args[2], URL: args[3]}); err != nil {
+				fmt.Fprintf(os.Stderr, "mod: %v\n", err)
+				return 1
+			}
+		}
+		if err := mod.Get(manifest, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "mod: %v\n", err)
+			return 1
+		}
+		// The manifest is only changed once the package has been fetched.
+		if len(args) == 4 {
+			if err := manifest.Write(); err != nil {
+				fmt.Fprintf(os.Stderr, "mod: %v\n", err)
+				return 1
+			}
+		}
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, modUsage)
+	return 2
+}
+
+// runTests runs the test spells in every test_*.crl file under the given
+// paths, or under the current directory, and returns the exit status.
+func runTests(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	summary, err := testrunner.Run(paths, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "test: %v\n", err)
+		return 2
+	}
+	if summary.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runReplay re-runs a script saved from the REPL with :save, showing each
+// input and its value as the REPL did. It returns the exit status.
+func runReplay(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: carrion replay session.crl")
+		return 2
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	env := object.NewEnvironment()
+	env.SetContext(evaluator.NewEvalContext(args[0]))
+	if err := evaluator.LoadMuninStdlib(env); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load stdlib: %v\n", err)
+		return 1
+	}
+	failed, err := repl.Replay(f, os.Stdout, env)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		return 1
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// breakpointFlags collects the values of a repeated -b flag.
+type breakpointFlags []string
+
+func (b *breakpointFlags) String() string { return strings.Join(*b, ",") }
+
+func (b *breakpointFlags) Set(value string) error {
+	*b = append(*b, value)
+	return nil
+}
+
+// runDebug runs a file under the step debugger, which pauses before the
+// first statement; -b sets breakpoints up front. It returns the exit status.
+func runDebug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	var breaks breakpointFlags
+	flags.Var(&breaks, "b", "set a breakpoint at file:line or line (repeatable)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: carrion debug [-b file:line]... file.crl")
+		return 2
+	}
+
+	filename := flags.Arg(0)
+	program := parseFile(filename)
+	if program == nil {
+		return 1
+	}
+
+	env := object.NewEnvironment()
+	env.SetContext(evaluator.NewEvalContext(filename))
+	if err := evaluator.LoadMuninStdlib(env); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load stdlib: %v\n", err)
+		return 1
+	}
+	dbg := debugger.New(env, os.Stdin, os.Stdout)
+	for _, spec := range breaks {
+		bp, err := debugger.ParseBreakpoint(spec, filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "debug: %v\n", err)
+			return 2
+		}
+		dbg.Break(bp)
+	}
+
+	result := evaluator.SafeEval(program, env)
+	if dbg.Quit() {
+		return 0
+	}
+	if isFailure(result) {
+		fmt.Fprintf(os.Stderr, "%s\n", errorFormat.Runtime(result))
+		return 1
 	}
+	return 0
 }
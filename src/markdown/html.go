@@ -0,0 +1,133 @@
+package markdown
+
+import (
+	"html"
+	"strconv"
+	"strings"
+)
+
+// ToHTML renders a Markdown document as HTML.
+func ToHTML(src string) string {
+	return HTML(Parse(src))
+}
+
+// HTML renders a parsed document, or any node of one, as HTML. Headings
+// get their ID as an id attribute, so that links to #anchors work.
+func HTML(n *Node) string {
+	var b strings.Builder
+	writeBlock(&b, n)
+	return b.String()
+}
+
+func writeBlock(b *strings.Builder, n *Node) {
+	switch n.Kind {
+	case Document:
+		for _, child := range n.Children {
+			writeBlock(b, child)
+		}
+	case Heading:
+		tag := "h" + strconv.Itoa(n.Level)
+		b.WriteString("<" + tag)
+		if n.ID != "" {
+			b.WriteString(` id="` + html.EscapeString(n.ID) + `"`)
+		}
+		b.WriteString(">")
+		writeInlines(b, n.Children)
+		b.WriteString("</" + tag + ">\n")
+	case Paragraph:
+		b.WriteString("<p>")
+		writeInlines(b, n.Children)
+		b.WriteString("</p>\n")
+	case CodeBlock:
+		b.WriteString("<pre><code")
+		if n.Info != "" {
+			b.WriteString(` class="language-` + html.EscapeString(n.Info) + `"`)
+		}
+		b.WriteString(">" + html.EscapeString(n.Text) + "</code></pre>\n")
+	case Blockquote:
+		b.WriteString("<blockquote>\n")
+		for _, child := range n.Children {
+			writeBlock(b, child)
+		}
+		b.WriteString("</blockquote>\n")
+	case List:
+		tag := "ul"
+		if n.Ordered {
+			tag = "ol"
+		}
+		b.WriteString("<" + tag)
+		if n.Ordered && n.Start != 1 {
+			b.WriteString(` start="` + strconv.Itoa(n.Start) + `"`)
+		}
+		b.WriteString(">\n")
+		for _, item := range n.Children {
+			writeItem(b, item, n.Tight)
+		}
+		b.WriteString("</" + tag + ">\n")
+	case Item:
+		writeItem(b, n, false)
+	case Rule:
+		b.WriteString("<hr />\n")
+	default:
+		writeInlines(b, []*Node{n})
+	}
+}
+
+// writeItem writes a list item. The paragraphs of a tight list's items are
+// written without <p> tags.
+func writeItem(b *strings.Builder, n *Node, tight bool) {
+	b.WriteString("<li>")
+	afterText := false
+	for i, child := range n.Children {
+		if tight && child.Kind == Paragraph {
+			writeInlines(b, child.Children)
+			afterText = true
+			continue
+		}
+		if i == 0 || afterText {
+			b.WriteByte('\n')
+		}
+		writeBlock(b, child)
+		afterText = false
+	}
+	b.WriteString("</li>\n")
+}
+
+func writeInlines(b *strings.Builder, nodes []*Node) {
+	for _, n := range nodes {
+		switch n.Kind {
+		case Text:
+			b.WriteString(html.EscapeString(n.Text))
+		case SoftBreak:
+			b.WriteByte('\n')
+		case LineBreak:
+			b.WriteString("<br />\n")
+		case Code:
+			b.WriteString("<code>" + html.EscapeString(n.Text) + "</code>")
+		case Emphasis:
+			b.WriteString("<em>")
+			writeInlines(b, n.Children)
+			b.WriteString("</em>")
+		case Strong:
+			b.WriteString("<strong>")
+			writeInlines(b, n.Children)
+			b.WriteString("</strong>")
+		case Link:
+			b.WriteString(`<a href="` + html.EscapeString(n.URL) + `"`)
+			if n.Title != "" {
+				b.WriteString(` title="` + html.EscapeString(n.Title) + `"`)
+			}
+			b.WriteString(">")
+			writeInlines(b, n.Children)
+			b.WriteString("</a>")
+		case Image:
+			b.WriteString(`<img src="` + html.EscapeString(n.URL) + `" alt="` + html.EscapeString(n.PlainText()) + `"`)
+			if n.Title != "" {
+				b.WriteString(` title="` + html.EscapeString(n.Title) + `"`)
+			}
+			b.WriteString(" />")
+		default:
+			writeBlock(b, n)
+		}
+	}
+}
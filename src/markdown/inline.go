@@ -0,0 +1,279 @@
+package markdown
+
+import (
+	"strings"
+)
+
+// parseInline parses the text of a paragraph or heading, which starts on
+// line first.
+func parseInline(s string, first int) []*Node {
+	p := &inlineParser{line: first}
+	return p.parse(s)
+}
+
+type inlineParser struct {
+	line int // the line of the text being read
+}
+
+func (p *inlineParser) parse(s string) []*Node {
+	var nodes []*Node
+	var text strings.Builder
+	textLine := p.line
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, &Node{Kind: Text, Line: textLine, Text: text.String()})
+			text.Reset()
+		}
+		textLine = p.line
+	}
+	add := func(n *Node) {
+		flush()
+		nodes = append(nodes, n)
+		textLine = p.line
+	}
+
+	for i := 0; i < len(s); {
+		ch := s[i]
+		switch {
+		case ch == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			add(&Node{Kind: LineBreak, Line: p.line})
+			p.line++
+			i += 2
+			continue
+		case ch == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+		case ch == '\n':
+			// Two spaces at the end of a line make a hard break.
+			trimmed := strings.TrimRight(text.String(), " ")
+			kind := SoftBreak
+			if text.Len()-len(trimmed) >= 2 {
+				kind = LineBreak
+			}
+			text.Reset()
+			text.WriteString(trimmed)
+			add(&Node{Kind: kind, Line: p.line})
+			p.line++
+			i++
+			continue
+		case ch == '`':
+			if code, end, ok := codeSpan(s, i); ok {
+				add(&Node{Kind: Code, Line: p.line, Text: code})
+				p.line += strings.Count(s[i:end], "\n")
+				i = end
+				continue
+			}
+			run := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			text.WriteString(s[i : i+run])
+			i += run
+			continue
+		case ch == '!' && i+1 < len(s) && s[i+1] == '[':
+			if n, end, ok := p.link(s, i+1, Image); ok {
+				add(n)
+				i = end
+				continue
+			}
+		case ch == '[':
+			if n, end, ok := p.link(s, i, Link); ok {
+				add(n)
+				i = end
+				continue
+			}
+		case ch == '<':
+			if n, end, ok := p.autolink(s, i); ok {
+				add(n)
+				i = end
+				continue
+			}
+		case ch == '*' || ch == '_':
+			if n, end, ok := p.emphasis(s, i); ok {
+				add(n)
+				i = end
+				continue
+			}
+			run := len(s[i:]) - len(strings.TrimLeft(s[i:], s[i:i+1]))
+			text.WriteString(s[i : i+run])
+			i += run
+			continue
+		}
+		text.WriteByte(ch)
+		i++
+	}
+	flush()
+	return nodes
+}
+
+func isPunct(ch byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", ch) >= 0
+}
+
+// codeSpan reads the code span opening at s[i], giving its code and the
+// index after it. The span closes at the next run of as many backticks.
+func codeSpan(s string, i int) (string, int, bool) {
+	run := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+	fence := s[i : i+run]
+	for j := i + run; j < len(s); {
+		k := strings.Index(s[j:], fence)
+		if k < 0 {
+			return "", 0, false
+		}
+		k += j
+		end := k + run
+		if end < len(s) && s[end] == '`' || s[k-1] == '`' {
+			j = end + len(s[end:]) - len(strings.TrimLeft(s[end:], "`"))
+			continue
+		}
+		code := strings.ReplaceAll(s[i+run:k], "\n", " ")
+		if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+			code = code[1 : len(code)-1]
+		}
+		return code, end, true
+	}
+	return "", 0, false
+}
+
+// skipSpan gives the index after the code span or escape at s[i], or i+1
+// for any other character, so that searches for closing markup pass over
+// them.
+func skipSpan(s string, i int) int {
+	switch s[i] {
+	case '\\':
+		if i+1 < len(s) {
+			return i + 2
+		}
+	case '`':
+		if _, end, ok := codeSpan(s, i); ok {
+			return end
+		}
+		return i + len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+	}
+	return i + 1
+}
+
+// link reads a link or image whose [ is at s[i]: [text](url "title").
+func (p *inlineParser) link(s string, i int, kind Kind) (*Node, int, bool) {
+	depth := 0
+	close := -1
+	for j := i; j < len(s) && close < 0; {
+		switch s[j] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				close = j
+			}
+		}
+		j = skipSpan(s, j)
+	}
+	if close < 0 || close+1 >= len(s) || s[close+1] != '(' {
+		return nil, 0, false
+	}
+	end := strings.IndexByte(s[close+2:], ')')
+	if end < 0 {
+		return nil, 0, false
+	}
+	end += close + 2
+	dest := strings.TrimSpace(s[close+2 : end])
+	var url, title string
+	if space := strings.IndexAny(dest, " \n"); space >= 0 {
+		url, title = dest[:space], strings.TrimSpace(dest[space:])
+		if len(title) < 2 || !(title[0] == '"' && title[len(title)-1] == '"' || title[0] == '\'' && title[len(title)-1] == '\'') {
+			return nil, 0, false
+		}
+		title = title[1 : len(title)-1]
+	} else {
+		url = dest
+	}
+	url = strings.TrimSuffix(strings.TrimPrefix(url, "<"), ">")
+
+	n := &Node{Kind: kind, Line: p.line, URL: url, Title: title}
+	n.Children = p.parse(s[i+1 : close])
+	p.line += strings.Count(s[close:end], "\n")
+	return n, end + 1, true
+}
+
+// autolink reads a link written as <https://example.com> or
+// <someone@example.com>.
+func (p *inlineParser) autolink(s string, i int) (*Node, int, bool) {
+	end := strings.IndexByte(s[i:], '>')
+	if end < 0 {
+		return nil, 0, false
+	}
+	end += i
+	target := s[i+1 : end]
+	if target == "" || strings.ContainsAny(target, " <\n") {
+		return nil, 0, false
+	}
+	url := target
+	colon := strings.IndexByte(target, ':')
+	if colon < 2 || !isScheme(target[:colon]) {
+		at := strings.IndexByte(target, '@')
+		if at < 1 || !strings.Contains(target[at:], ".") {
+			return nil, 0, false
+		}
+		url = "mailto:" + target
+	}
+	return &Node{Kind: Link, Line: p.line, URL: url, Children: []*Node{{Kind: Text, Line: p.line, Text: target}}}, end + 1, true
+}
+
+func isScheme(s string) bool {
+	for i, r := range s {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		if !letter && (i == 0 || !(r >= '0' && r <= '9' || r == '+' || r == '.' || r == '-')) {
+			return false
+		}
+	}
+	return true
+}
+
+// emphasis reads emphasis opened by the run of * or _ at s[i]: one for
+// emphasis, two for strong and three for both. It closes at the next run
+// of as many of the same that follows text, and an _ only at the edges of
+// a word.
+func (p *inlineParser) emphasis(s string, i int) (*Node, int, bool) {
+	delim := s[i]
+	run := len(s[i:]) - len(strings.TrimLeft(s[i:], string(delim)))
+	if run > 3 {
+		return nil, 0, false
+	}
+	open := i + run
+	if open >= len(s) || s[open] == ' ' || s[open] == '\n' {
+		return nil, 0, false
+	}
+	if delim == '_' && i > 0 && isWordByte(s[i-1]) {
+		return nil, 0, false
+	}
+	for j := open; j < len(s); {
+		if s[j] != delim {
+			j = skipSpan(s, j)
+			continue
+		}
+		closeRun := len(s[j:]) - len(strings.TrimLeft(s[j:], string(delim)))
+		after := j + closeRun
+		leftOK := s[j-1] != ' ' && s[j-1] != '\n'
+		rightOK := delim != '_' || after >= len(s) || !isWordByte(s[after])
+		if closeRun == run && leftOK && rightOK {
+			inner := s[open:j]
+			outer := &Node{Kind: Emphasis, Line: p.line}
+			switch run {
+			case 2:
+				outer.Kind = Strong
+				outer.Children = p.parse(inner)
+			case 3:
+				outer.Kind = Strong
+				outer.Children = []*Node{{Kind: Emphasis, Line: p.line, Children: p.parse(inner)}}
+			default:
+				outer.Children = p.parse(inner)
+			}
+			return outer, j + run, true
+		}
+		j = after
+	}
+	return nil, 0, false
+}
+
+func isWordByte(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch >= 0x80
+}
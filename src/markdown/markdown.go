@@ -0,0 +1,464 @@
+// Package markdown parses the common core of Markdown into a tree and
+// renders it as HTML. It covers ATX and setext headings, paragraphs, block
+// quotes, bulleted and numbered lists, fenced and indented code, thematic
+// breaks, and inline emphasis, code, links, images, autolinks and hard line
+// breaks. Raw HTML is not passed through: it is escaped like other text.
+package markdown
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Kind is the type of a Node.
+type Kind string
+
+const (
+	Document   Kind = "document"
+	Heading    Kind = "heading"
+	Paragraph  Kind = "paragraph"
+	CodeBlock  Kind = "code_block"
+	Blockquote Kind = "blockquote"
+	List       Kind = "list"
+	Item       Kind = "item"
+	Rule       Kind = "rule"
+	Text       Kind = "text"
+	Emphasis   Kind = "emphasis"
+	Strong     Kind = "strong"
+	Code       Kind = "code"
+	Link       Kind = "link"
+	Image      Kind = "image"
+	LineBreak  Kind = "line_break"
+	SoftBreak  Kind = "soft_break"
+)
+
+// Node is one element of a parsed document. Only the fields that matter
+// for its Kind are set.
+type Node struct {
+	Kind     Kind
+	Line     int    // the line the element starts on, counting from 1
+	Level    int    // a heading's level, 1 to 6
+	ID       string // a heading's anchor, unique within the document
+	Text     string // the text of a text, code or code block node
+	Info     string // the info string of a fenced code block, as in ```go
+	URL      string // a link's destination or an image's source
+	Title    string // a link's or image's title, if given
+	Ordered  bool   // whether a list is numbered
+	Start    int    // the first number of a numbered list
+	Tight    bool   // whether a list has no blank lines between its items
+	Children []*Node
+}
+
+// PlainText is the text of n and everything in it without any markup, as
+// a heading's text would be read out.
+func (n *Node) PlainText() string {
+	var b strings.Builder
+	n.plainText(&b)
+	return b.String()
+}
+
+func (n *Node) plainText(b *strings.Builder) {
+	switch n.Kind {
+	case Text, Code, CodeBlock:
+		b.WriteString(n.Text)
+	case SoftBreak, LineBreak:
+		b.WriteByte(' ')
+	}
+	for _, child := range n.Children {
+		child.plainText(b)
+	}
+}
+
+// Walk calls fn for n and every node inside it, parents before their
+// children, in document order.
+func (n *Node) Walk(fn func(*Node)) {
+	fn(n)
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+}
+
+// Parse parses a Markdown document.
+func Parse(src string) *Node {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+	doc := &Node{Kind: Document, Line: 1, Children: parseBlocks(lines, 1)}
+	assignIDs(doc)
+	return doc
+}
+
+// expandTabs replaces the tabs in a line's indentation with spaces to the
+// next multiple of four columns.
+func expandTabs(line string) string {
+	var b strings.Builder
+	for i, r := range line {
+		switch r {
+		case '\t':
+			b.WriteString(strings.Repeat(" ", 4-b.Len()%4))
+		case ' ':
+			b.WriteByte(' ')
+		default:
+			return b.String() + line[i:]
+		}
+	}
+	return b.String()
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// parseBlocks parses lines, the first of which is line first of the
+// document, into a sequence of blocks.
+func parseBlocks(lines []string, first int) []*Node {
+	var blocks []*Node
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if isBlank(line) {
+			i++
+			continue
+		}
+		lineNo := first + i
+		if fence, info, ok := openingFence(line); ok {
+			block, n := fencedCode(lines[i+1:], fence, info, indentOf(line))
+			block.Line = lineNo
+			blocks = append(blocks, block)
+			i += 1 + n
+			continue
+		}
+		if indentOf(line) >= 4 {
+			var code []string
+			n := 0
+			for i+n < len(lines) && (indentOf(lines[i+n]) >= 4 || isBlank(lines[i+n])) {
+				code = append(code, strings.TrimPrefix(lines[i+n], "    "))
+				n++
+			}
+			for len(code) > 0 && isBlank(code[len(code)-1]) {
+				code = code[:len(code)-1]
+			}
+			blocks = append(blocks, &Node{Kind: CodeBlock, Line: lineNo, Text: strings.Join(code, "\n") + "\n"})
+			i += n
+			continue
+		}
+		if level, text, ok := atxHeading(line); ok {
+			blocks = append(blocks, &Node{Kind: Heading, Line: lineNo, Level: level, Children: parseInline(text, lineNo)})
+			i++
+			continue
+		}
+		if isRule(line) {
+			blocks = append(blocks, &Node{Kind: Rule, Line: lineNo})
+			i++
+			continue
+		}
+		if _, ok := quoteContent(line); ok {
+			var inner []string
+			n := 0
+			for i+n < len(lines) {
+				content, ok := quoteContent(lines[i+n])
+				if !ok {
+					break
+				}
+				inner = append(inner, content)
+				n++
+			}
+			blocks = append(blocks, &Node{Kind: Blockquote, Line: lineNo, Children: parseBlocks(inner, lineNo)})
+			i += n
+			continue
+		}
+		if _, ok := listMarker(line); ok {
+			list, n := parseList(lines[i:], lineNo)
+			blocks = append(blocks, list)
+			i += n
+			continue
+		}
+
+		// A paragraph runs until a blank line or the start of another
+		// block, or becomes a heading if underlined with = or -.
+		text := []string{strings.TrimLeft(line, " ")}
+		n := 1
+		level := 0
+		for ; i+n < len(lines); n++ {
+			next := lines[i+n]
+			if isBlank(next) || interrupts(next) {
+				break
+			}
+			if l := setextLevel(next); l > 0 {
+				level = l
+				n++
+				break
+			}
+			text = append(text, strings.TrimLeft(next, " "))
+		}
+		inline := parseInline(strings.TrimRight(strings.Join(text, "\n"), " "), lineNo)
+		if level > 0 {
+			blocks = append(blocks, &Node{Kind: Heading, Line: lineNo, Level: level, Children: inline})
+		} else {
+			blocks = append(blocks, &Node{Kind: Paragraph, Line: lineNo, Children: inline})
+		}
+		i += n
+	}
+	return blocks
+}
+
+// interrupts reports whether line starts a block that ends a paragraph.
+func interrupts(line string) bool {
+	if _, _, ok := openingFence(line); ok {
+		return true
+	}
+	if _, _, ok := atxHeading(line); ok {
+		return true
+	}
+	if _, ok := quoteContent(line); ok {
+		return true
+	}
+	if _, ok := listMarker(line); ok {
+		return true
+	}
+	return isRule(line) && setextLevel(line) == 0
+}
+
+// openingFence recognises the start of a fenced code block, giving its
+// fence, such as ``` or ~~~~, and its info string.
+func openingFence(line string) (string, string, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return "", "", false
+	}
+	n := len(trimmed) - len(strings.TrimLeft(trimmed, trimmed[:1]))
+	if n < 3 {
+		return "", "", false
+	}
+	info := strings.TrimSpace(trimmed[n:])
+	if trimmed[0] == '`' && strings.Contains(info, "`") {
+		return "", "", false
+	}
+	return trimmed[:n], info, true
+}
+
+// fencedCode reads the lines of a fenced code block up to its closing
+// fence, returning the block and the number of lines it used, the closing
+// fence included. Lines lose as much indentation as the opening fence had.
+func fencedCode(lines []string, fence, info string, indent int) (*Node, int) {
+	var code strings.Builder
+	n := 0
+	for ; n < len(lines); n++ {
+		trimmed := strings.TrimSpace(lines[n])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" && indentOf(lines[n]) <= 3 {
+			n++
+			break
+		}
+		line := lines[n]
+		line = line[min(indent, indentOf(line)):]
+		code.WriteString(line)
+		code.WriteByte('\n')
+	}
+	if words := strings.Fields(info); len(words) > 0 {
+		info = words[0]
+	}
+	return &Node{Kind: CodeBlock, Text: code.String(), Info: info}, n
+}
+
+// atxHeading recognises a heading such as "## Usage", giving its level and
+// its text without the closing #s some writers add.
+func atxHeading(line string) (int, string, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return 0, "", false
+	}
+	level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+	if level < 1 || level > 6 {
+		return 0, "", false
+	}
+	rest := trimmed[level:]
+	if rest != "" && rest[0] != ' ' {
+		return 0, "", false
+	}
+	rest = strings.TrimSpace(rest)
+	if closing := strings.TrimRight(rest, "#"); closing == "" || strings.HasSuffix(closing, " ") {
+		rest = strings.TrimSpace(closing)
+	}
+	return level, rest, true
+}
+
+// isRule recognises a thematic break: three or more of the same -, * or _,
+// with nothing else but spaces.
+func isRule(line string) bool {
+	if indentOf(line) > 3 {
+		return false
+	}
+	stripped := strings.ReplaceAll(strings.TrimSpace(line), " ", "")
+	if len(stripped) < 3 || strings.IndexByte("-*_", stripped[0]) < 0 {
+		return false
+	}
+	return strings.Trim(stripped, stripped[:1]) == ""
+}
+
+// setextLevel is 1 for a line of =s and 2 for a line of -s that underline
+// a heading, and 0 for any other line.
+func setextLevel(line string) int {
+	if indentOf(line) > 3 {
+		return 0
+	}
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == "":
+		return 0
+	case strings.Trim(trimmed, "=") == "":
+		return 1
+	case strings.Trim(trimmed, "-") == "":
+		return 2
+	}
+	return 0
+}
+
+// quoteContent gives what follows the > of a block quote line.
+func quoteContent(line string) (string, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || !strings.HasPrefix(trimmed, ">") {
+		return "", false
+	}
+	content := trimmed[1:]
+	return strings.TrimPrefix(content, " "), true
+}
+
+type marker struct {
+	bullet  byte // '-', '*' or '+', or the '.' or ')' after a number
+	ordered bool
+	number  int
+	content int // the column the item's content starts at
+}
+
+// listMarker recognises the start of a list item.
+func listMarker(line string) (marker, bool) {
+	indent := indentOf(line)
+	if indent > 3 || isRule(line) {
+		return marker{}, false
+	}
+	rest := line[indent:]
+	var m marker
+	width := 0
+	switch {
+	case rest != "" && strings.IndexByte("-*+", rest[0]) >= 0:
+		m.bullet, width = rest[0], 1
+	default:
+		digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+		if digits == 0 || digits > 9 || digits == len(rest) || (rest[digits] != '.' && rest[digits] != ')') {
+			return marker{}, false
+		}
+		m.ordered, m.bullet, width = true, rest[digits], digits+1
+		m.number, _ = strconv.Atoi(rest[:digits])
+	}
+	after := rest[width:]
+	if after != "" && after[0] != ' ' {
+		return marker{}, false
+	}
+	spaces := indentOf(after)
+	if spaces == 0 || spaces > 4 || isBlank(after) {
+		spaces = 1
+	}
+	m.content = indent + width + spaces
+	return m, true
+}
+
+// parseList reads a list from lines, which start with its first item,
+// returning it and the number of lines it used.
+func parseList(lines []string, first int) (*Node, int) {
+	start, _ := listMarker(lines[0])
+	list := &Node{Kind: List, Line: first, Ordered: start.ordered, Start: start.number, Tight: true}
+	i := 0
+	blankBetween := false
+	for i < len(lines) {
+		m, ok := listMarker(lines[i])
+		if !ok || m.ordered != start.ordered || m.bullet != start.bullet {
+			break
+		}
+		if blankBetween {
+			list.Tight = false
+		}
+		// The item holds its first line and every line after it that is
+		// indented to its content, blank, or a paragraph's continuation.
+		content := []string{lines[i][min(m.content, len(lines[i])):]}
+		n := 1
+		lazy := !isBlank(content[0])
+		for ; i+n < len(lines); n++ {
+			line := lines[i+n]
+			switch {
+			case isBlank(line):
+				content = append(content, "")
+				lazy = false
+				continue
+			case indentOf(line) >= m.content:
+				content = append(content, line[m.content:])
+				lazy = true
+				continue
+			case lazy && !interrupts(line):
+				content = append(content, strings.TrimLeft(line, " "))
+				continue
+			}
+			break
+		}
+		trailing := 0
+		for len(content) > 0 && content[len(content)-1] == "" {
+			content = content[:len(content)-1]
+			trailing++
+		}
+		for _, line := range content[1:] {
+			if line == "" {
+				list.Tight = false
+				break
+			}
+		}
+		item := &Node{Kind: Item, Line: first + i, Children: parseBlocks(content, first+i)}
+		list.Children = append(list.Children, item)
+		i += n
+		blankBetween = trailing > 0
+		if trailing > 0 && i < len(lines) {
+			if next, ok := listMarker(lines[i]); !ok || next.bullet != start.bullet || next.ordered != start.ordered {
+				// The blank lines end the list instead.
+				i -= trailing
+				break
+			}
+		}
+	}
+	return list, i
+}
+
+// assignIDs gives each heading an anchor made from its text, as GitHub
+// does: lower case, with spaces turned into hyphens and punctuation left
+// out, and a number added to repeats.
+func assignIDs(doc *Node) {
+	seen := map[string]int{}
+	doc.Walk(func(n *Node) {
+		if n.Kind != Heading {
+			return
+		}
+		id := Slug(n.PlainText())
+		if count := seen[id]; count > 0 {
+			n.ID = id + "-" + strconv.Itoa(count)
+		} else {
+			n.ID = id
+		}
+		seen[id]++
+	})
+}
+
+// Slug makes an anchor name from heading text.
+func Slug(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToHTML(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"# Title", "<h1 id=\"title\">Title</h1>\n"},
+		{"## Usage ##\ntext", "<h2 id=\"usage\">Usage</h2>\n<p>text</p>\n"},
+		{"Setup\n=====\nSub\n---", "<h1 id=\"setup\">Setup</h1>\n<h2 id=\"sub\">Sub</h2>\n"},
+		{"one\ntwo\n\nthree", "<p>one\ntwo</p>\n<p>three</p>\n"},
+		{"a *b* **c** ***d*** _e_ snake_case_name", "<p>a <em>b</em> <strong>c</strong> <strong><em>d</em></strong> <em>e</em> snake_case_name</p>\n"},
+		{"*a **b** c*", "<p><em>a <strong>b</strong> c</em></p>\n"},
+		{"`x < y` and ``a ` b``", "<p><code>x &lt; y</code> and <code>a ` b</code></p>\n"},
+		{"`*not em*` \\*plain\\*", "<p><code>*not em*</code> *plain*</p>\n"},
+		{"[docs](https://x.io/d \"Docs\") ![logo](l.png)", "<p><a href=\"https://x.io/d\" title=\"Docs\">docs</a> <img src=\"l.png\" alt=\"logo\" /></p>\n"},
+		{"[**bold** link](/a) <https://x.io> <me@x.io>", "<p><a href=\"/a\"><strong>bold</strong> link</a> <a href=\"https://x.io\">https://x.io</a> <a href=\"mailto:me@x.io\">me@x.io</a></p>\n"},
+		{"line  \nbreak", "<p>line<br />\nbreak</p>\n"},
+		{"<b>raw</b> & co", "<p>&lt;b&gt;raw&lt;/b&gt; &amp; co</p>\n"},
+		{"```go\nfmt.Println(\"<hi>\")\n```", "<pre><code class=\"language-go\">fmt.Println(&#34;&lt;hi&gt;&#34;)\n</code></pre>\n"},
+		{"text\n\n    indented\n    code", "<p>text</p>\n<pre><code>indented\ncode\n</code></pre>\n"},
+		{"> quoted\n> # head", "<blockquote>\n<p>quoted</p>\n<h1 id=\"head\">head</h1>\n</blockquote>\n"},
+		{"- a\n- b\n  - c\n- d", "<ul>\n<li>a</li>\n<li>b\n<ul>\n<li>c</li>\n</ul>\n</li>\n<li>d</li>\n</ul>\n"},
+		{"3. three\n4. four", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+		{"* loose\n\n* list", "<ul>\n<li>\n<p>loose</p>\n</li>\n<li>\n<p>list</p>\n</li>\n</ul>\n"},
+		{"- item\n\nafter", "<ul>\n<li>item</li>\n</ul>\n<p>after</p>\n"},
+		{"- lazy\ncontinued", "<ul>\n<li>lazy\ncontinued</li>\n</ul>\n"},
+		{"---\n***", "<hr />\n<hr />\n"},
+		{"# Intro\n# Intro", "<h1 id=\"intro\">Intro</h1>\n<h1 id=\"intro-1\">Intro</h1>\n"},
+	}
+	for _, tt := range tests {
+		if got := ToHTML(tt.input); got != tt.expected {
+			t.Errorf("ToHTML(%q) =\n%s\nwant\n%s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	doc := Parse("# Getting *started*\n\nSee [the guide](guide.md)\nand [API](api.md).\n\n## Next")
+	var found []string
+	doc.Walk(func(n *Node) {
+		switch n.Kind {
+		case Heading:
+			found = append(found, n.ID+" "+n.PlainText())
+		case Link:
+			found = append(found, n.URL+" "+n.PlainText())
+		}
+		if n.Kind == Link || n.Kind == Heading {
+			found[len(found)-1] += " line " + string(rune('0'+n.Line))
+		}
+	})
+	expected := []string{
+		"getting-started Getting started line 1",
+		"guide.md the guide line 3",
+		"api.md API line 4",
+		"next Next line 6",
+	}
+	if strings.Join(found, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(found, "\n"))
+	}
+}
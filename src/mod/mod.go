@@ -0,0 +1,325 @@
+// Package mod implements Carrion packages. A project lists the packages it
+// depends on in a carrion.mod manifest; `carrion mod get` clones each one
+// with git into a cache shared by every project, and imports of a listed
+// package are resolved from that cache.
+package mod
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/javanhut/Carrion/src/token"
+)
+
+// ManifestFile is the name of the manifest. It applies to every file in its
+// directory and the directories below it.
+const ManifestFile = "carrion.mod"
+
+// CacheEnv names the environment variable that overrides the cache
+// directory.
+const CacheEnv = "CARRION_CACHE"
+
+// Requirement is one package a project depends on: the name it is imported
+// by, the git tag or branch to fetch and the repository to fetch it from.
+type Requirement struct {
+	Name    string
+	Version string
+	URL     string
+}
+
+func (r Requirement) String() string {
+	return r.Name + " " + r.Version + " " + r.URL
+}
+
+// Dir is where the package is cached.
+func (r Requirement) Dir() string {
+	return filepath.Join(CacheDir(), r.Name+"@"+r.Version)
+}
+
+// Cached reports whether the package has been fetched.
+func (r Requirement) Cached() bool {
+	info, err := os.Stat(r.Dir())
+	return err == nil && info.IsDir()
+}
+
+func (r Requirement) validate() error {
+	if r.Name == "" || strings.ContainsAny(r.Name, `/\@`) || r.Name == "." || r.Name == ".." {
+		return fmt.Errorf("invalid package name %q", r.Name)
+	}
+	if r.Version == "" || strings.ContainsAny(r.Version, `/\`) || strings.HasPrefix(r.Version, "-") {
+		return fmt.Errorf("invalid version %q for package %s", r.Version, r.Name)
+	}
+	if r.URL == "" || strings.HasPrefix(r.URL, "-") {
+		return fmt.Errorf("invalid URL %q for package %s", r.URL, r.Name)
+	}
+	return nil
+}
+
+// Manifest is a parsed carrion.mod. Each line names a package, its version
+// and its URL, separated by spaces; blank lines and // comments are ignored.
+// A carrion_version line gives the language version its files are written
+// for:
+//
+//	carrion_version 1.0
+//	// shapes for the drawing demo
+//	shapes v1.2.0 https://github.com/example/shapes.git
+type Manifest struct {
+	Path           string
+	CarrionVersion string // "" for the current version
+	Requirements   []Requirement
+}
+
+// ParseManifest parses the contents of the manifest at path.
+func ParseManifest(path string, data []byte) (*Manifest, error) {
+	m := &Manifest{Path: path}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if i := slices.IndexFunc(fields, func(f string) bool { return strings.HasPrefix(f, "//") }); i >= 0 {
+			fields = fields[:i]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == versionField {
+			if len(fields) != 2 || !token.KnownVersion(fields[1]) {
+				return nil, fmt.Errorf("%s:%d: expected a carrion_version of %s, got %q",
+					path, line, strings.Join(token.Versions, ", "), strings.Join(fields[1:], " "))
+			}
+			m.CarrionVersion = fields[1]
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected name, version and URL, got %q", path, line, strings.Join(fields, " "))
+		}
+		r := Requirement{Name: fields[0], Version: fields[1], URL: fields[2]}
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if m.Lookup(r.Name) != nil {
+			return nil, fmt.Errorf("%s:%d: package %s is listed twice", path, line, r.Name)
+		}
+		m.Requirements = append(m.Requirements, r)
+	}
+	return m, scanner.Err()
+}
+
+// versionField starts the line of a manifest giving the language version.
+const versionField = "carrion_version"
+
+// ReadManifest reads and parses the manifest at path.
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(path, data)
+}
+
+// FindManifest returns the manifest that applies to files in dir: the
+// nearest carrion.mod in dir or one of its parents, or "" if there is none.
+func FindManifest(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ManifestFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Lookup returns the requirement for the named package, or nil.
+func (m *Manifest) Lookup(name string) *Requirement {
+	for i := range m.Requirements {
+		if m.Requirements[i].Name == name {
+			return &m.Requirements[i]
+		}
+	}
+	return nil
+}
+
+// Require adds r to the manifest, replacing any requirement with its name.
+func (m *Manifest) Require(r Requirement) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+	if existing := m.Lookup(r.Name); existing != nil {
+		*existing = r
+		return nil
+	}
+	m.Requirements = append(m.Requirements, r)
+	return nil
+}
+
+// Write saves the manifest to its path. Comments and blank lines already in
+// the file are kept, and requirements that are new are added at the end.
+func (m *Manifest) Write() error {
+	data, err := os.ReadFile(m.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var b strings.Builder
+	written := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == versionField {
+			if m.CarrionVersion == "" || written[versionField] {
+				continue
+			}
+			written[versionField] = true
+			line = versionField + " " + m.CarrionVersion
+		} else if len(fields) > 0 && !strings.HasPrefix(fields[0], "//") {
+			r := m.Lookup(fields[0])
+			if r == nil || written[r.Name] {
+				continue
+			}
+			written[r.Name] = true
+			line = r.String()
+		}
+		fmt.Fprintln(&b, line)
+	}
+	if m.CarrionVersion != "" && !written[versionField] {
+		fmt.Fprintln(&b, versionField+" "+m.CarrionVersion)
+	}
+	for _, r := range m.Requirements {
+		if !written[r.Name] {
+			fmt.Fprintln(&b, r)
+		}
+	}
+	return os.WriteFile(m.Path, []byte(b.String()), 0644)
+}
+
+// CacheDir is where packages are fetched to: $CARRION_CACHE, or
+// ~/.carrion/pkg.
+func CacheDir() string {
+	if dir := os.Getenv(CacheEnv); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "carrion-pkg")
+	}
+	return filepath.Join(home, ".carrion", "pkg")
+}
+
+// LanguageVersion gives the language version the manifest that applies to
+// file asks for, or "" for the current one.
+func LanguageVersion(file string) string {
+	dir := "."
+	if file != "" {
+		dir = filepath.Dir(file)
+	}
+	path := FindManifest(dir)
+	if path == "" {
+		return ""
+	}
+	m, err := ReadManifest(path)
+	if err != nil {
+		return ""
+	}
+	return m.CarrionVersion
+}
+
+// Resolve maps an import written in file to the cache when its first path
+// element is a package required by the manifest that applies to file. It
+// returns the path to import without the .crl extension, so "shapes/circle"
+// becomes the circle module of the cached shapes package, and reports
+// whether the import named a required package.
+func Resolve(name, file string) (string, bool) {
+	if filepath.IsAbs(name) {
+		return "", false
+	}
+	dir := "."
+	if file != "" {
+		dir = filepath.Dir(file)
+	}
+	path := FindManifest(dir)
+	if path == "" {
+		return "", false
+	}
+	m, err := ReadManifest(path)
+	if err != nil {
+		return "", false
+	}
+	pkg, rest, _ := strings.Cut(filepath.ToSlash(name), "/")
+	r := m.Lookup(pkg)
+	if r == nil {
+		return "", false
+	}
+	return filepath.Join(r.Dir(), filepath.FromSlash(rest)), true
+}
+
+// Get fetches every requirement of m that is not cached yet, along with the
+// requirements of the packages it fetches, reporting progress to out. A
+// package that is already cached is not fetched again, since a version
+// names a fixed tag.
+func Get(m *Manifest, out io.Writer) error {
+	seen := make(map[string]bool)
+	pending := slices.Clone(m.Requirements)
+	for len(pending) > 0 {
+		r := pending[0]
+		pending = pending[1:]
+		if seen[r.Name+"@"+r.Version] {
+			continue
+		}
+		seen[r.Name+"@"+r.Version] = true
+
+		if !r.Cached() {
+			fmt.Fprintf(out, "fetching %s %s from %s\n", r.Name, r.Version, r.URL)
+			if err := fetch(r); err != nil {
+				return err
+			}
+		}
+		manifest := filepath.Join(r.Dir(), ManifestFile)
+		if _, err := os.Stat(manifest); err != nil {
+			continue
+		}
+		deps, err := ReadManifest(manifest)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, deps.Requirements...)
+	}
+	return nil
+}
+
+// fetch clones r into a temporary directory next to its place in the cache
+// and moves it there once the clone has succeeded, so that an interrupted
+// fetch never leaves a partial package behind.
+func fetch(r Requirement) error {
+	if err := os.MkdirAll(CacheDir(), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(CacheDir(), ".fetch-"+r.Name+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	cmd := exec.Command("git", "clone", "--quiet", "--depth", "1", "--branch", r.Version, "--", r.URL, tmp)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("fetching %s %s: %v: %s", r.Name, r.Version, err, strings.TrimSpace(stderr.String()))
+	}
+	if err := os.RemoveAll(filepath.Join(tmp, ".git")); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.Dir())
+}
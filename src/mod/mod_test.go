@@ -0,0 +1,183 @@
+package mod
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	m, err := ParseManifest("carrion.mod", []byte("// drawing\n\nshapes v1.2.0 https://example.com/shapes.git // pinned\nutils main ../utils\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Requirement{
+		{"shapes", "v1.2.0", "https://example.com/shapes.git"},
+		{"utils", "main", "../utils"},
+	}
+	if len(m.Requirements) != len(want) {
+		t.Fatalf("got %v", m.Requirements)
+	}
+	for i, r := range want {
+		if m.Requirements[i] != r {
+			t.Errorf("requirement %d: expected %v, got %v", i, r, m.Requirements[i])
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"shapes v1\n", `carrion.mod:1: expected name, version and URL, got "shapes v1"`},
+		{"\nann/shapes v1 url\n", `carrion.mod:2: invalid package name "ann/shapes"`},
+		{"shapes ../v1 url\n", `carrion.mod:1: invalid version "../v1" for package shapes`},
+		{"shapes v1 a\nshapes v2 b\n", "carrion.mod:2: package shapes is listed twice"},
+		{"carrion_version 0.9\n", `carrion.mod:1: expected a carrion_version of 1.0, 1.1, 1.2, got "0.9"`},
+	}
+	for _, tt := range errors {
+		if _, err := ParseManifest("carrion.mod", []byte(tt.input)); err == nil || err.Error() != tt.message {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.message, err)
+		}
+	}
+}
+
+func TestWriteKeepsComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ManifestFile)
+	writeFile(t, path, "// drawing\nshapes v1 a\n\n// helpers\nutils v1 b\n")
+	m, err := ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Require(Requirement{"shapes", "v2", "a"})
+	m.Require(Requirement{"colors", "v1", "c"})
+	if err := m.Write(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if got, want := string(data), "// drawing\nshapes v2 a\n\n// helpers\nutils v1 b\ncolors v1 c\n"; got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(CacheEnv, filepath.Join(dir, "cache"))
+	writeFile(t, filepath.Join(dir, "app", ManifestFile), "shapes v1 https://example.com/shapes.git\n")
+	main := filepath.Join(dir, "app", "src", "main.crl")
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"shapes", filepath.Join(dir, "cache", "shapes@v1")},
+		{"shapes/circle", filepath.Join(dir, "cache", "shapes@v1", "circle")},
+		{"helpers", ""},
+		{"shapesx", ""},
+	}
+	for _, tt := range tests {
+		got, ok := Resolve(tt.name, main)
+		if got != tt.expected || ok != (tt.expected != "") {
+			t.Errorf("Resolve(%q): expected %q, got %q, %v", tt.name, tt.expected, got, ok)
+		}
+	}
+	if _, ok := Resolve("shapes", filepath.Join(dir, "main.crl")); ok {
+		t.Error("the manifest should not apply outside its directory")
+	}
+}
+
+func TestLanguageVersion(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app", ManifestFile)
+	writeFile(t, path, "// old scripts\ncarrion_version 1.0\nshapes v1 a\n")
+	if got := LanguageVersion(filepath.Join(dir, "app", "src", "main.crl")); got != "1.0" {
+		t.Errorf("expected the manifest's version 1.0, got %q", got)
+	}
+	if got := LanguageVersion(filepath.Join(dir, "main.crl")); got != "" {
+		t.Errorf("expected no version outside the manifest's directory, got %q", got)
+	}
+
+	m, err := ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.CarrionVersion = "1.1"
+	if err := m.Write(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if got, want := string(data), "// old scripts\ncarrion_version 1.1\nshapes v1 a\n"; got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestGet(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	t.Setenv(CacheEnv, filepath.Join(dir, "cache"))
+	repo := func(name string, files map[string]string) string {
+		t.Helper()
+		path := filepath.Join(dir, "repos", name)
+		for file, content := range files {
+			writeFile(t, filepath.Join(path, file), content)
+		}
+		for _, args := range [][]string{
+			{"init", "--quiet"},
+			{"add", "."},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "release"},
+			{"tag", "v1"},
+		} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = path
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+			}
+		}
+		return path
+	}
+	colors := repo("colors", map[string]string{"index.crl": "red = 1\n"})
+	shapes := repo("shapes", map[string]string{
+		"index.crl":  "import \"colors\"\n",
+		ManifestFile: "colors v1 " + colors + "\n",
+	})
+
+	m := &Manifest{Requirements: []Requirement{{"shapes", "v1", shapes}}}
+	var out strings.Builder
+	if err := Get(m, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"shapes@v1/index.crl", "colors@v1/index.crl"} {
+		if _, err := os.Stat(filepath.Join(dir, "cache", file)); err != nil {
+			t.Errorf("expected %s to be fetched: %v", file, err)
+		}
+	}
+	if strings.Count(out.String(), "fetching") != 2 {
+		t.Errorf("unexpected progress output %q", out.String())
+	}
+
+	out.Reset()
+	if err := Get(m, &out); err != nil || out.Len() != 0 {
+		t.Errorf("expected cached packages not to be fetched again, got %q, %v", out.String(), err)
+	}
+
+	missing := &Manifest{Requirements: []Requirement{{"shapes", "v9", shapes}}}
+	if err := Get(missing, &out); err == nil || !strings.HasPrefix(err.Error(), "fetching shapes v9: ") {
+		t.Errorf("expected a failed fetch, got %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "cache")); len(entries) != 2 {
+		t.Errorf("a failed fetch left files in the cache: %v", entries)
+	}
+}
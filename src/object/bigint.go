@@ -0,0 +1,36 @@
+package object
+
+import (
+	"hash/fnv"
+	"math/big"
+)
+
+const BIG_INTEGER_OBJ = "BIG_INTEGER"
+
+// BigInteger is an integer too large for an Integer. Integer arithmetic
+// that overflows int64 gives a BigInteger instead of wrapping around, and
+// arithmetic on BigIntegers gives an Integer again once the result fits, so
+// a BigInteger never holds a value an Integer could. Its Value must not be
+// modified.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (b *BigInteger) Type() ObjectType { return BIG_INTEGER_OBJ }
+func (b *BigInteger) Inspect() string  { return b.Value.String() }
+
+func (b *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte{byte(b.Value.Sign() + 1)})
+	h.Write(b.Value.Bytes())
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+// NewInt returns v as an Integer if it fits in one, and as a BigInteger
+// otherwise. v must not be modified afterwards.
+func NewInt(v *big.Int) Object {
+	if v.IsInt64() {
+		return &Integer{Value: v.Int64()}
+	}
+	return &BigInteger{Value: v}
+}
@@ -1,14 +1,21 @@
 package object
 
+const BOUND_METHOD_OBJ = "BOUND_METHOD"
+
+// BoundMethod is a method taken from an instance, as by `m = obj.method`,
+// which calls Method with self set to Instance.
 type BoundMethod struct {
 	Instance *Instance
 	Method   *Function
 }
 
 func (bm *BoundMethod) Type() ObjectType {
-	return "BOUND_METHOD"
+	return BOUND_METHOD_OBJ
 }
 
 func (bm *BoundMethod) Inspect() string {
-	return "<bound method>"
+	if bm.Method.Name == "" {
+		return "<bound method>"
+	}
+	return "<bound method " + bm.Method.Name + ">"
 }
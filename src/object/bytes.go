@@ -0,0 +1,50 @@
+package object
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+const BYTES_OBJ = "BYTES"
+
+// Bytes is an immutable string of bytes, for binary data that is not text.
+type Bytes struct {
+	Value []byte
+}
+
+func (b *Bytes) Type() ObjectType { return BYTES_OBJ }
+
+// Inspect writes b as a bytes literal: printable ASCII as itself and other
+// bytes as escapes, so that it reads back as the same value.
+func (b *Bytes) Inspect() string {
+	const hexDigits = "0123456789abcdef"
+	var out strings.Builder
+	out.WriteString(`b"`)
+	for _, c := range b.Value {
+		switch {
+		case c == '"' || c == '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case c == '\n':
+			out.WriteString(`\n`)
+		case c == '\t':
+			out.WriteString(`\t`)
+		case c == '\r':
+			out.WriteString(`\r`)
+		case c >= 0x20 && c < 0x7f:
+			out.WriteByte(c)
+		default:
+			out.WriteString(`\x`)
+			out.WriteByte(hexDigits[c>>4])
+			out.WriteByte(hexDigits[c&0xf])
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+func (b *Bytes) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(b.Value)
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
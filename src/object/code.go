@@ -0,0 +1,15 @@
+package object
+
+import "github.com/javanhut/Carrion/src/ast"
+
+const CODE_OBJ = "CODE"
+
+// Code is source parsed by compile(), which eval() can run any number of
+// times without parsing it again.
+type Code struct {
+	Name    string // file name used in positions, "<eval>" unless given
+	Program *ast.Program
+}
+
+func (c *Code) Type() ObjectType { return CODE_OBJ }
+func (c *Code) Inspect() string  { return "<code " + c.Name + ">" }
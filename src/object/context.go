@@ -0,0 +1,28 @@
+package object
+
+import (
+	"context"
+	"time"
+)
+
+const CONTEXT_OBJ = "CONTEXT"
+
+// Context carries a deadline and a cancellation signal, as a Go
+// context.Context does. Builtins that wait, such as sleeping, running a
+// command, file access and rpc calls, give up once it is done.
+type Context struct {
+	Value  context.Context
+	Cancel context.CancelFunc // nil for a context that cannot be cancelled
+}
+
+func (c *Context) Type() ObjectType { return CONTEXT_OBJ }
+
+func (c *Context) Inspect() string {
+	if err := c.Value.Err(); err != nil {
+		return "<context " + err.Error() + ">"
+	}
+	if deadline, ok := c.Value.Deadline(); ok {
+		return "<context deadline " + deadline.Format(time.RFC3339Nano) + ">"
+	}
+	return "<context>"
+}
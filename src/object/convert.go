@@ -0,0 +1,277 @@
+package object
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// TRUE and FALSE are the only Boolean objects the interpreter creates, so
+// booleans may be compared by identity.
+var (
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
+// NativeBool returns TRUE or FALSE.
+func NativeBool(b bool) *Boolean {
+	if b {
+		return TRUE
+	}
+	return FALSE
+}
+
+// ToGo converts obj to a plain Go value:
+//
+//	None                   nil
+//	Boolean                bool
+//	Integer                int64
+//	BigInteger             *big.Int
+//	Float                  float64
+//	String                 string
+//	Bytes                  []byte
+//	IntArray               []int64
+//	FloatArray             []float64
+//	Array, Tuple           []any
+//	Hash                   map[string]any; every key must be a String
+//	Record, @data instance map[string]any keyed by field name
+//
+// Anything else, such as a spell or an instance of an ordinary grimoire, is
+// an error.
+func ToGo(obj Object) (any, error) {
+	return toGo(obj, "value")
+}
+
+func toGo(obj Object, path string) (any, error) {
+	switch obj := obj.(type) {
+	case nil, *None:
+		return nil, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *Integer:
+		return obj.Value, nil
+	case *BigInteger:
+		return new(big.Int).Set(obj.Value), nil
+	case *Float:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Bytes:
+		return append([]byte(nil), obj.Value...), nil
+	case *IntArray:
+		return append([]int64(nil), obj.Values...), nil
+	case *FloatArray:
+		return append([]float64(nil), obj.Values...), nil
+	case *Array:
+		return toGoSlice(obj.Elements, path)
+	case *Tuple:
+		return toGoSlice(obj.Elements, path)
+	case *Hash:
+		m := make(map[string]any, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, ok := pair.Key.(*String)
+			if !ok {
+				return nil, fmt.Errorf("%s has a non-string key %s", path, pair.Key.Inspect())
+			}
+			value, err := toGo(pair.Value, path+"."+key.Value)
+			if err != nil {
+				return nil, err
+			}
+			m[key.Value] = value
+		}
+		return m, nil
+	case *Record:
+		return toGoFields(obj.RecordType.Fields, obj.Values, path)
+	case *Instance:
+		if !obj.Grimoire.IsData {
+			return nil, fmt.Errorf("%s is an instance of %s, which is not a @data grimoire", path, obj.Grimoire.Name)
+		}
+		return toGoFields(obj.Grimoire.Fields, obj.FieldValues(), path)
+	}
+	return nil, fmt.Errorf("%s: cannot convert %s to a Go value", path, obj.Type())
+}
+
+func toGoSlice(elements []Object, path string) ([]any, error) {
+	s := make([]any, len(elements))
+	for i, element := range elements {
+		value, err := toGo(element, fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			return nil, err
+		}
+		s[i] = value
+	}
+	return s, nil
+}
+
+func toGoFields(fields []string, values []Object, path string) (map[string]any, error) {
+	m := make(map[string]any, len(fields))
+	for i, field := range fields {
+		value, err := toGo(values[i], path+"."+field)
+		if err != nil {
+			return nil, err
+		}
+		m[field] = value
+	}
+	return m, nil
+}
+
+// FromGo converts a Go value to an object. It accepts everything ToGo
+// returns, and also:
+//
+//   - any signed or unsigned integer, float32, []byte and json.Number
+//   - time.Time, as a String in RFC 3339 format, and time.Duration, as a
+//     Float number of seconds
+//   - slices and arrays of any element type, as an Array
+//   - maps whose keys convert to a String, Integer or Boolean, as a Hash
+//   - structs, as a Hash keyed by field name; see below
+//   - pointers, as what they point to; nil pointers are None
+//   - Objects, which are returned as they are
+//
+// Other types, such as functions and channels, are an error.
+//
+// A struct's exported fields become its keys, including those promoted from
+// embedded structs. A `carrion:"name"` tag gives the key a different name,
+// and `carrion:"-"` leaves the field out. Unmarshal reads the same keys back.
+func FromGo(v any) (Object, error) {
+	return fromGo(v, "value")
+}
+
+func fromGo(v any, path string) (Object, error) {
+	switch v := v.(type) {
+	case nil:
+		return NONE, nil
+	case Object:
+		return v, nil
+	case bool:
+		return NativeBool(v), nil
+	case int64:
+		return &Integer{Value: v}, nil
+	case *big.Int:
+		return NewInt(new(big.Int).Set(v)), nil
+	case float64:
+		return &Float{Value: v}, nil
+	case string:
+		return &String{Value: v}, nil
+	case []byte:
+		return &String{Value: string(v)}, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return &Integer{Value: i}, nil
+		}
+		if i, ok := new(big.Int).SetString(string(v), 10); ok {
+			return NewInt(i), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return &Float{Value: f}, nil
+	case time.Time:
+		return &String{Value: v.Format(time.RFC3339Nano)}, nil
+	case time.Duration:
+		return &Float{Value: v.Seconds()}, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: rv.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return NewInt(new(big.Int).SetUint64(rv.Uint())), nil
+		}
+		return &Integer{Value: int64(rv.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: rv.Float()}, nil
+	case reflect.Bool:
+		return NativeBool(rv.Bool()), nil
+	case reflect.String:
+		return &String{Value: rv.String()}, nil
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return NONE, nil
+		}
+		return fromGo(rv.Elem().Interface(), path)
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return &Array{Elements: []Object{}}, nil
+		}
+		elements := make([]Object, rv.Len())
+		for i := range elements {
+			element, err := fromGo(rv.Index(i).Interface(), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &Array{Elements: elements}, nil
+	case reflect.Struct:
+		fields := structFields(rv.Type())
+		pairs := make(map[HashKey]HashPair, len(fields))
+		for _, field := range fields {
+			value, err := fromGo(rv.FieldByIndex(field.index).Interface(), path+"."+field.name)
+			if err != nil {
+				return nil, err
+			}
+			key := &String{Value: field.name}
+			pairs[key.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		return &Hash{Pairs: pairs}, nil
+	case reflect.Map:
+		pairs := make(map[HashKey]HashPair, rv.Len())
+		keys := rv.MapKeys()
+		// Sorted so that an error is always reported for the same key.
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			key, err := fromGo(k.Interface(), path)
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(Hashable)
+			if !ok {
+				return nil, fmt.Errorf("%s has a key of type %s, which cannot be a hash key", path, key.Type())
+			}
+			value, err := fromGo(rv.MapIndex(k).Interface(), fmt.Sprintf("%s[%s]", path, key.Inspect()))
+			if err != nil {
+				return nil, err
+			}
+			pairs[hashable.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		return &Hash{Pairs: pairs}, nil
+	}
+	return nil, fmt.Errorf("%s: cannot convert Go %T to an object", path, v)
+}
+
+// structField is a struct field as it appears in a Hash.
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields lists the fields of a struct type that FromGo and Unmarshal
+// use, in declaration order.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || (f.Anonymous && f.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("carrion"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, structField{name: name, index: f.Index})
+	}
+	return fields
+}
@@ -0,0 +1,69 @@
+package object
+
+import "bytes"
+
+// FieldValues returns the values of a @data instance's fields in
+// declaration order, with None for any field that was never set.
+func (i *Instance) FieldValues() []Object {
+	values := make([]Object, len(i.Grimoire.Fields))
+	for n, field := range i.Grimoire.Fields {
+		value, ok := i.Field(field)
+		if !ok {
+			value = NONE
+		}
+		values[n] = value
+	}
+	return values
+}
+
+func (i *Instance) dataString() string {
+	var out bytes.Buffer
+	out.WriteString(i.Grimoire.Name)
+	out.WriteString("(")
+	for n, value := range i.FieldValues() {
+		if n > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(i.Grimoire.Fields[n])
+		out.WriteString("=")
+		out.WriteString(value.Inspect())
+	}
+	out.WriteString(")")
+	return out.String()
+}
+
+// HashKeyOf returns the hash key of obj, reporting false if obj cannot be
+// used as a hash key. Instances of @data grimoires hash by their fields,
+// tuples of hashable elements and frozen arrays and hashes by their
+// contents.
+func HashKeyOf(obj Object) (HashKey, bool) {
+	switch obj := obj.(type) {
+	case *Tuple:
+		for _, element := range obj.Elements {
+			if _, ok := HashKeyOf(element); !ok {
+				return HashKey{}, false
+			}
+		}
+		return obj.HashKey(), true
+	case Hashable:
+		return obj.HashKey(), true
+	case *Instance:
+		if obj.Grimoire.IsData {
+			return HashKey{Type: obj.Type(), Value: hashFields(obj.Grimoire.Name, obj.FieldValues())}, true
+		}
+	case *Array:
+		if obj.Frozen {
+			return HashKey{Type: obj.Type(), Value: hashFields("", obj.Elements)}, true
+		}
+	case *Hash:
+		if obj.Frozen {
+			// Summed so that the order of the pairs does not matter.
+			var sum uint64
+			for _, pair := range obj.Pairs {
+				sum += hashFields("", []Object{pair.Key, pair.Value})
+			}
+			return HashKey{Type: obj.Type(), Value: sum}, true
+		}
+	}
+	return HashKey{}, false
+}
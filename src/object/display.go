@@ -0,0 +1,35 @@
+package object
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FloatPrecision is the number of digits after the decimal point that floats
+// are shown with. When it is negative, the default, a float is shown with the
+// fewest digits that read back as the same value, and always with a decimal
+// point or exponent so that it cannot be mistaken for an integer.
+var FloatPrecision = -1
+
+// FormatFloat formats f as Inspect and Display show floats.
+func FormatFloat(f float64) string {
+	if FloatPrecision >= 0 {
+		return strconv.FormatFloat(f, 'f', FloatPrecision, 64)
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !math.IsInf(f, 0) && !math.IsNaN(f) && !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// Display returns obj as print and f-strings show it. That is the same as
+// Inspect except that a string is its text, unquoted; strings inside arrays,
+// hashes and other values are still quoted.
+func Display(obj Object) string {
+	if s, ok := obj.(*String); ok {
+		return s.Value
+	}
+	return obj.Inspect()
+}
@@ -1,9 +1,14 @@
 package object
 
+import "slices"
+
 // environment.go
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store    map[string]Object
+	outer    *Environment
+	context  interface{}
+	bindings map[string]*Environment // names declared `global` or `outer` here
+	exports  []string                // names listed by `export` statements here
 }
 
 func NewEnvironment() *Environment {
@@ -18,6 +23,9 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
+	if target, ok := e.bindings[name]; ok {
+		return target.Get(name)
+	}
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
@@ -25,11 +33,68 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
+// GetLocal looks a name up in this environment only, ignoring outer scopes.
+func (e *Environment) GetLocal(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	return obj, ok
+}
+
 func (e *Environment) Set(name string, val Object) Object {
+	if target, ok := e.bindings[name]; ok {
+		return target.Set(name, val)
+	}
 	e.store[name] = val
 	return val
 }
 
+// Declared reports whether name belongs to this scope, either defined here
+// or bound to another scope by Bind.
+func (e *Environment) Declared(name string) bool {
+	if _, ok := e.bindings[name]; ok {
+		return true
+	}
+	_, ok := e.store[name]
+	return ok
+}
+
+// Bind makes name in this scope refer to the same name in target, so that
+// reads and assignments here go straight to target instead of creating a
+// local binding.
+func (e *Environment) Bind(name string, target *Environment) {
+	if e.bindings == nil {
+		e.bindings = make(map[string]*Environment)
+	}
+	e.bindings[name] = target
+}
+
+// Export adds names to those this scope, a module's, lists as its exports.
+func (e *Environment) Export(names ...string) {
+	for _, name := range names {
+		if !slices.Contains(e.exports, name) {
+			e.exports = append(e.exports, name)
+		}
+	}
+}
+
+// Exports returns the names listed by Export, and whether there are any.
+func (e *Environment) Exports() ([]string, bool) {
+	return e.exports, len(e.exports) > 0
+}
+
+// Owner returns the nearest environment, starting with e, that defines name,
+// or nil if no scope does.
+func (e *Environment) Owner(name string) *Environment {
+	for env := e; env != nil; env = env.outer {
+		if target, ok := env.bindings[name]; ok {
+			return target.Owner(name)
+		}
+		if _, ok := env.store[name]; ok {
+			return env
+		}
+	}
+	return nil
+}
+
 func (e *Environment) GetNames() []string {
 	names := make([]string, 0)
 	for name := range e.store {
@@ -42,6 +107,24 @@ func (e *Environment) GetOuter() *Environment {
 	return e.outer
 }
 
+// Context returns the interpreter context attached to this environment or
+// the nearest enclosing one, or nil if none has been attached.
+func (e *Environment) Context() interface{} {
+	for env := e; env != nil; env = env.outer {
+		if env.context != nil {
+			return env.context
+		}
+	}
+	return nil
+}
+
+// SetContext attaches an interpreter context to the environment. It is
+// normally set once on the global environment and shared by every scope
+// enclosed by it.
+func (e *Environment) SetContext(ctx interface{}) {
+	e.context = ctx
+}
+
 // GetFunctionName tries to determine the current function name
 // Returns empty string if not in a function/method
 func (e *Environment) GetFunctionName() string {
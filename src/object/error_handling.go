@@ -9,7 +9,8 @@ import (
 )
 
 const (
-	CUSTOM_ERROR_OBJ = "USER DEFINED ERROR"
+	CUSTOM_ERROR_OBJ   = "USER DEFINED ERROR"
+	INTERNAL_ERROR_OBJ = "INTERNAL ERROR"
 )
 
 // StackTraceEntry represents a single entry in the stack trace
@@ -20,9 +21,12 @@ type StackTraceEntry struct {
 
 // Error represents a built-in error in the language with stack trace
 type Error struct {
-	Message    string
-	StackTrace []StackTraceEntry
-	Position   token.Position
+	Message     string
+	StackTrace  []StackTraceEntry
+	Position    token.Position
+	Suggestions []string // Similar names the user may have meant, closest first
+	Cause       Object   // The error this one was raised from, if any
+	Kind        string   // The standard error, such as "TypeError", an attempt ensnares it as; RuntimeError if empty
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
@@ -36,6 +40,9 @@ func (e *Error) Inspect() string {
 	} else {
 		sb.WriteString(fmt.Sprintf("Error: %s\n", e.Message))
 	}
+	if len(e.Suggestions) > 0 {
+		sb.WriteString(fmt.Sprintf("Did you mean: %s?\n", strings.Join(e.Suggestions, ", ")))
+	}
 
 	// Add stack trace if available
 	if len(e.StackTrace) > 0 {
@@ -49,10 +56,24 @@ func (e *Error) Inspect() string {
 			sb.WriteString(fmt.Sprintf("  at %s in %s\n", funcName, entry.Position.String()))
 		}
 	}
+	writeCause(&sb, e.Cause)
 
 	return sb.String()
 }
 
+// writeCause adds the error an error was raised from, and so on back to the
+// first, to its Inspect text.
+func writeCause(sb *strings.Builder, cause Object) {
+	if cause == nil {
+		return
+	}
+	if !strings.HasSuffix(sb.String(), "\n") {
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nCaused by: ")
+	sb.WriteString(cause.Inspect())
+}
+
 // NewError creates a new Error object with optional position information
 // If no position is provided, it uses a default empty position.
 func NewError(message string, positionOpt ...token.Position) *Error {
@@ -85,6 +106,7 @@ type CustomError struct {
 	Instance   *Instance          // Instance of the error (if applicable)
 	Position   token.Position     // Position where the error occurred
 	StackTrace []StackTraceEntry  // Stack trace for the error
+	Cause      Object             // The error this one was raised from, if any
 }
 
 // Type returns the type of the object (implements the Object interface).
@@ -123,6 +145,7 @@ func (ce *CustomError) Inspect() string {
 			sb.WriteString(fmt.Sprintf("  at %s in %s\n", funcName, entry.Position.String()))
 		}
 	}
+	writeCause(&sb, ce.Cause)
 	
 	return sb.String()
 }
@@ -156,3 +179,38 @@ func (ce *CustomError) AddStackEntry(position token.Position, function string) {
 		Function: function,
 	})
 }
+
+// InternalError represents a Go panic raised inside the interpreter itself.
+// It is recovered at the top of evaluation so that an interpreter bug is
+// reported instead of killing the host process.
+type InternalError struct {
+	Message    string
+	StackTrace []StackTraceEntry
+	GoStack    string // Go stack at the panic, only captured when requested
+}
+
+func (ie *InternalError) Type() ObjectType { return INTERNAL_ERROR_OBJ }
+
+func (ie *InternalError) Inspect() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Internal error: %s\n", ie.Message))
+
+	if len(ie.StackTrace) > 0 {
+		sb.WriteString("\nStack trace (most recent call last):\n")
+		for i := len(ie.StackTrace) - 1; i >= 0; i-- {
+			entry := ie.StackTrace[i]
+			funcName := entry.Function
+			if funcName == "" {
+				funcName = "<module>"
+			}
+			sb.WriteString(fmt.Sprintf("  at %s in %s\n", funcName, entry.Position.String()))
+		}
+	}
+
+	if ie.GoStack != "" {
+		sb.WriteString("\nGo stack:\n")
+		sb.WriteString(ie.GoStack)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,69 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// NewGoFunc wraps fn, which must be a Go function, as a builtin that
+// Carrion calls as name. Each argument is converted to the type of the
+// matching parameter with Unmarshal, and a variadic final parameter takes
+// the remaining arguments. fn may return nothing, a value, an error, or a
+// value and an error; the value is converted with FromGo and a non-nil
+// error is raised in the program as an error with its message.
+func NewGoFunc(name string, fn any) (*Builtin, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%T is not a function", fn)
+	}
+	ft := fv.Type()
+	if ft.NumOut() > 2 || (ft.NumOut() == 2 && ft.Out(1) != errorType) {
+		return nil, fmt.Errorf("%s must return at most a value and an error", ft)
+	}
+
+	return &Builtin{
+		Fn: func(args ...Object) Object {
+			fixed := ft.NumIn()
+			if ft.IsVariadic() {
+				fixed--
+			}
+			switch {
+			case ft.IsVariadic() && len(args) < fixed:
+				return &Error{Message: fmt.Sprintf("%s takes at least %d arguments, got %d", name, fixed, len(args))}
+			case !ft.IsVariadic() && len(args) != fixed:
+				return &Error{Message: fmt.Sprintf("%s takes %d arguments, got %d", name, fixed, len(args))}
+			}
+			values := make([]reflect.Value, len(args))
+			for i, arg := range args {
+				var typ reflect.Type
+				if i < fixed {
+					typ = ft.In(i)
+				} else {
+					typ = ft.In(fixed).Elem()
+				}
+				values[i] = reflect.New(typ).Elem()
+				if err := Unmarshal(arg, values[i].Addr().Interface()); err != nil {
+					return &Error{Message: fmt.Sprintf("%s: argument %d: %s", name, i+1, err)}
+				}
+			}
+
+			var result any
+			for _, out := range fv.Call(values) {
+				if out.Type() == errorType {
+					if !out.IsNil() {
+						return &Error{Message: fmt.Sprintf("%s: %s", name, out.Interface())}
+					}
+					continue
+				}
+				result = out.Interface()
+			}
+			obj, err := FromGo(result)
+			if err != nil {
+				return &Error{Message: fmt.Sprintf("%s returned a value Carrion cannot use: %s", name, err)}
+			}
+			return obj
+		},
+	}, nil
+}
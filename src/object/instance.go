@@ -0,0 +1,74 @@
+package object
+
+import "slices"
+
+// An instance keeps the fields its grimoire knows about, those declared in
+// the grimoire's body or assigned through self in its init, in a slice
+// indexed by Grimoire.Slots rather than a map of its own. Fields added any
+// other way go in Env, which is only created for them, so most instances
+// cost one small slice instead of an environment.
+
+// NewInstance returns an instance of g with no fields set.
+func NewInstance(g *Grimoire) *Instance {
+	instance := &Instance{Grimoire: g}
+	if len(g.Slots) > 0 {
+		instance.Slots = make([]Object, len(g.Slots))
+	}
+	return instance
+}
+
+// Field returns the value of one of the instance's own fields.
+func (i *Instance) Field(name string) (Object, bool) {
+	if n, ok := i.Grimoire.Slots[name]; ok && n < len(i.Slots) && i.Slots[n] != nil {
+		return i.Slots[n], true
+	}
+	if i.Env != nil {
+		return i.Env.GetLocal(name)
+	}
+	return nil, false
+}
+
+// Get looks name up as a field of the instance, and failing that in the
+// scope its grimoire was defined in.
+func (i *Instance) Get(name string) (Object, bool) {
+	if n, ok := i.Grimoire.Slots[name]; ok && n < len(i.Slots) && i.Slots[n] != nil {
+		return i.Slots[n], true
+	}
+	if i.Env != nil {
+		return i.Env.Get(name)
+	}
+	if i.Grimoire.Env != nil {
+		return i.Grimoire.Env.Get(name)
+	}
+	return nil, false
+}
+
+// Set sets a field of the instance.
+func (i *Instance) Set(name string, val Object) Object {
+	if n, ok := i.Grimoire.Slots[name]; ok {
+		if len(i.Slots) != len(i.Grimoire.Slots) {
+			i.Slots = append(i.Slots, make([]Object, len(i.Grimoire.Slots)-len(i.Slots))...)
+		}
+		i.Slots[n] = val
+		return val
+	}
+	if i.Env == nil {
+		i.Env = NewEnclosedEnvironment(i.Grimoire.Env)
+	}
+	return i.Env.Set(name, val)
+}
+
+// FieldNames returns the names of the fields set on the instance, sorted.
+func (i *Instance) FieldNames() []string {
+	var names []string
+	for name, n := range i.Grimoire.Slots {
+		if n < len(i.Slots) && i.Slots[n] != nil {
+			names = append(names, name)
+		}
+	}
+	if i.Env != nil {
+		names = append(names, i.Env.GetNames()...)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"strconv"
 	"strings"
 
 	"github.com/javanhut/Carrion/src/ast"
@@ -13,6 +14,8 @@ type ObjectType string
 
 type Object interface {
 	Type() ObjectType
+	// Inspect returns the value's debugging form, as the REPL shows it:
+	// strings are quoted. Use Display for what print shows.
 	Inspect() string
 }
 
@@ -33,6 +36,7 @@ const (
 	INSTANCE_OBJ     = "INSTANCE"
 	NAMESPACE_OBJ    = "NAMESPACE"
 	RANGE_OBJ        = "RANGE"
+	TAIL_CALL_OBJ    = "TAIL_CALL"
 )
 
 var NONE = &None{}
@@ -49,7 +53,7 @@ type Float struct {
 }
 
 func (f *Float) Type() ObjectType { return FLOAT_OBJ }
-func (f *Float) Inspect() string  { return fmt.Sprintf("%f", f.Value) }
+func (f *Float) Inspect() string  { return FormatFloat(f.Value) }
 
 type Boolean struct {
 	Value bool
@@ -72,15 +76,29 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// TailCall is a call in tail position that has been evaluated up to its
+// arguments but not yet run. The enclosing call runs it in place of
+// recursing, so tail-recursive spells use constant Go stack.
+type TailCall struct {
+	Fn   Object
+	Args []Object
+}
+
+func (tc *TailCall) Type() ObjectType { return TAIL_CALL_OBJ }
+func (tc *TailCall) Inspect() string  { return "tail call" }
+
 // Error type is now defined in error_handling.go
 
 type Function struct {
-	Parameters  []*ast.Parameter
-	Body        *ast.BlockStatement
-	Env         *Environment
-	IsAbstract  bool
-	IsPrivate   bool
-	IsProtected bool
+	Name         string // as declared, such as "area" or "Circle.area"
+	Parameters   []*ast.Parameter
+	Body         *ast.BlockStatement
+	Env          *Environment
+	IsAbstract   bool
+	IsPrivate    bool
+	IsProtected  bool
+	OnceDefaults map[string]Object // `=once` defaults, evaluated at definition
+	Grimoire     *Grimoire         // The grimoire declaring it, for a method
 }
 
 func (f *Function) Inspect() string {
@@ -105,12 +123,17 @@ type String struct {
 }
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
-func (s *String) Inspect() string  { return s.Value }
+func (s *String) Inspect() string  { return strconv.Quote(s.Value) }
 
 type BuiltinFunction func(args ...Object) Object
 
+// EnvBuiltinFunction is a builtin that also receives the calling environment,
+// for builtins that depend on interpreter state.
+type EnvBuiltinFunction func(env *Environment, args ...Object) Object
+
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn    BuiltinFunction
+	EnvFn EnvBuiltinFunction
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -118,6 +141,7 @@ func (b *Builtin) Inspect() string  { return "builtin function" }
 
 type Array struct {
 	Elements []Object
+	Frozen   bool // made by freeze(): it cannot be changed and can be a hash key
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
@@ -163,7 +187,8 @@ type HashPair struct {
 	Value Object
 }
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	Pairs  map[HashKey]HashPair
+	Frozen bool // made by freeze(), like a frozen Array
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
@@ -201,6 +226,13 @@ func (t *Tuple) Inspect() string {
 	return out.String()
 }
 
+// HashKey combines the hash keys of the elements, so tuples with equal
+// elements hash alike. HashKeyOf only uses it when every element is itself
+// hashable.
+func (t *Tuple) HashKey() HashKey {
+	return HashKey{Type: t.Type(), Value: hashFields("", t.Elements)}
+}
+
 // Update Object (object.go)
 type Grimoire struct {
 	Name       string
@@ -209,6 +241,11 @@ type Grimoire struct {
 	Inherits   *Grimoire
 	Env        *Environment // Add environment to store the grimoire's scope
 	IsArcane   bool
+	IsData     bool                      // Declared with @data: compared, hashed and printed by Fields
+	Fields     []string                  // Fields declared in a @data grimoire, in order
+	FieldTypes map[string]ast.Expression // Type hints of those fields, where given
+	Slots      map[string]int            // Fixed slot of each field its instances are known to have
+	Extensions map[string]*Function      // Methods added later by `extend`, also in Methods
 }
 
 func (s *Grimoire) Type() ObjectType { return GRIMOIRE_OBJ }
@@ -219,27 +256,45 @@ func (s *Grimoire) Inspect() string {
 // Ensure Instance type implements Object
 type Instance struct {
 	Grimoire *Grimoire
-	Env      *Environment
+	Slots    []Object     // Values of the grimoire's slotted fields, nil while unset
+	Env      *Environment // Any other fields, created when the first is set
 }
 
 func (i *Instance) Type() ObjectType { return INSTANCE_OBJ }
-func (i *Instance) Inspect() string  { return fmt.Sprintf("<instance of %s>", i.Grimoire.Name) }
+func (i *Instance) Inspect() string {
+	if i.Grimoire.IsData {
+		return i.dataString()
+	}
+	return fmt.Sprintf("<instance of %s>", i.Grimoire.Name)
+}
 
 // object/object.go
 
 type Namespace struct {
 	Env *Environment // Holds all exported members of the imported module
+
+	// Load, if set, fills Env the first time a member is looked up, for a
+	// module imported lazily. It returns an error object if that fails.
+	Load func() Object
 }
 
 func (n *Namespace) Type() ObjectType { return "NAMESPACE" }
 func (n *Namespace) Inspect() string  { return "<namespace>" }
 
-type Stop struct{}
+// Stop is the control signal produced by `stop`. A non-empty Label targets
+// the enclosing loop with that name instead of the innermost one.
+type Stop struct {
+	Label string
+}
 
 func (s *Stop) Type() ObjectType { return "STOP" }
 func (s *Stop) Inspect() string  { return "stop" }
 
-type Skip struct{}
+// Skip is the control signal produced by `skip`, with the same Label
+// semantics as Stop.
+type Skip struct {
+	Label string
+}
 
 func (s *Skip) Type() ObjectType { return "SKIP" }
 func (s *Skip) Inspect() string  { return "skip" }
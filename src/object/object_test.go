@@ -1,6 +1,14 @@
 package object
 
-import "testing"
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -17,3 +25,212 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestWeakRef(t *testing.T) {
+	arr := &Array{Elements: []Object{&Integer{Value: 1}}}
+	ref, ok := NewWeakRef(arr)
+	if !ok {
+		t.Fatalf("expected arrays to be weakly referenceable")
+	}
+	if ref.Get() != arr {
+		t.Errorf("weakref does not return its live referent")
+	}
+
+	runtime.KeepAlive(arr)
+	arr = nil
+	runtime.GC()
+	if ref.Get() != nil {
+		t.Errorf("weakref kept its referent alive")
+	}
+
+	if _, ok := NewWeakRef(&Integer{Value: 1}); ok {
+		t.Errorf("integers should not be weakly referenceable")
+	}
+}
+
+func TestFromGo(t *testing.T) {
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{nil, ""},
+		{true, "true"},
+		{int8(-3), "-3"},
+		{uint32(7), "7"},
+		{float32(0.5), "0.5"},
+		{json.Number("12"), "12"},
+		{json.Number("1.5"), "1.5"},
+		{json.Number("123456789012345678901"), "123456789012345678901"},
+		{uint64(1 << 63), "9223372036854775808"},
+		{big.NewInt(7), "7"},
+		{[]byte("raw"), `"raw"`},
+		{[]string{"a", "b"}, `["a", "b"]`},
+		{[2]int{1, 2}, "[1, 2]"},
+		{map[string]int{"n": 1}, `{"n": 1}`},
+		{map[int]bool{2: false}, "{2: false}"},
+		{time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), `"2024-05-01T12:00:00Z"`},
+		{1500 * time.Millisecond, "1.5"},
+		{new(int), "0"},
+		{(*int)(nil), ""},
+		{&String{Value: "kept"}, `"kept"`},
+		{struct{}{}, "{}"},
+		{struct {
+			Name   string `carrion:"name"`
+			Secret string `carrion:"-"`
+			hidden int
+		}{"crow", "s", 1}, `{"name": "crow"}`},
+	}
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Errorf("FromGo(%#v): %v", tt.input, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("FromGo(%#v) = %s, want %s", tt.input, obj.Inspect(), tt.expected)
+		}
+	}
+	if obj, _ := FromGo(true); obj != TRUE {
+		t.Error("FromGo(true) should return TRUE")
+	}
+
+	for _, input := range []any{
+		func() {},
+		map[string]any{"ok": []any{1, make(chan int)}},
+		map[float64]int{1.5: 1},
+	} {
+		if _, err := FromGo(input); err == nil {
+			t.Errorf("FromGo(%#v): expected an error", input)
+		}
+	}
+	if _, err := FromGo(map[string]any{"ok": []any{1, make(chan int)}}); !strings.HasPrefix(err.Error(), `value["ok"][1]: `) {
+		t.Errorf("error should name where the value was: %v", err)
+	}
+}
+
+func TestToGo(t *testing.T) {
+	grimoire := &Grimoire{Name: "Point", IsData: true, Fields: []string{"x", "y"}}
+	point := &Instance{Grimoire: grimoire, Env: NewEnvironment()}
+	point.Env.Set("x", &Integer{Value: 1})
+
+	key := &String{Value: "point"}
+	hash := &Hash{Pairs: map[HashKey]HashPair{key.HashKey(): {Key: key, Value: point}}}
+	input := &Array{Elements: []Object{
+		NONE, TRUE, &Integer{Value: 2}, &Float{Value: 2.5}, &String{Value: "s"},
+		&Tuple{Elements: []Object{&Integer{Value: 3}}}, hash,
+		&Record{RecordType: &RecordType{Name: "P", Fields: []string{"a"}}, Values: []Object{FALSE}},
+	}}
+	got, err := ToGo(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		nil, true, int64(2), 2.5, "s", []any{int64(3)},
+		map[string]any{"point": map[string]any{"x": int64(1), "y": nil}},
+		map[string]any{"a": false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToGo = %#v, want %#v", got, want)
+	}
+
+	back, err := FromGo(got)
+	if err != nil || back.Inspect() != `[, true, 2, 2.5, "s", [3], {"point": {"x": 1, "y": }}, {"a": false}]` {
+		t.Errorf("round trip gave %v, %v", back.Inspect(), err)
+	}
+
+	intKey := &Integer{Value: 1}
+	for _, tt := range []struct {
+		input   Object
+		message string
+	}{
+		{&Hash{Pairs: map[HashKey]HashPair{intKey.HashKey(): {Key: intKey, Value: NONE}}}, "value has a non-string key 1"},
+		{&Array{Elements: []Object{&Instance{Grimoire: &Grimoire{Name: "Plain"}}}}, "value[0] is an instance of Plain, which is not a @data grimoire"},
+		{&Function{}, "value: cannot convert FUNCTION to a Go value"},
+	} {
+		if _, err := ToGo(tt.input); err == nil || err.Error() != tt.message {
+			t.Errorf("ToGo(%s): expected error %q, got %v", tt.input.Type(), tt.message, err)
+		}
+	}
+}
+
+type testAddress struct {
+	City string `carrion:"city"`
+}
+
+type testBase struct {
+	ID int64
+}
+
+type testUser struct {
+	testBase
+	Name    string          `carrion:"name"`
+	Tags    []string        `carrion:"tags"`
+	Home    *testAddress    `carrion:"home"`
+	Scores  map[string]int8 `carrion:"scores"`
+	Timeout time.Duration   `carrion:"timeout"`
+	Raw     Object          `carrion:"raw"`
+	Extra   any             `carrion:"extra"`
+	Skipped string          `carrion:"-"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	want := testUser{
+		testBase: testBase{ID: 7},
+		Name:     "Ada",
+		Tags:     []string{"a", "b"},
+		Home:     &testAddress{City: "Oslo"},
+		Scores:   map[string]int8{"math": 9},
+		Timeout:  1500 * time.Millisecond,
+		Raw:      &Integer{Value: 1},
+		Extra:    []any{int64(1), "x"},
+	}
+	obj, err := FromGo(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := testUser{Skipped: "kept"}
+	if err := Unmarshal(obj, &got); err != nil {
+		t.Fatal(err)
+	}
+	want.Skipped = "kept"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip gave %+v, want %+v", got, want)
+	}
+
+	record := &Record{RecordType: &RecordType{Name: "Address", Fields: []string{"city"}}, Values: []Object{&String{Value: "Rome"}}}
+	var address testAddress
+	if err := Unmarshal(record, &address); err != nil || address.City != "Rome" {
+		t.Errorf("expected a record to fill a struct, got %+v, %v", address, err)
+	}
+	var pair [2]float64
+	if err := Unmarshal(&Tuple{Elements: []Object{&Integer{Value: 1}, &Float{Value: 2.5}}}, &pair); err != nil || pair != [2]float64{1, 2.5} {
+		t.Errorf("expected a tuple to fill an array, got %v, %v", pair, err)
+	}
+	ptr := new(int)
+	if err := Unmarshal(NONE, &ptr); err != nil || ptr != nil {
+		t.Errorf("expected None to clear a pointer, got %v, %v", ptr, err)
+	}
+	huge, _ := new(big.Int).SetString("123456789012345678901", 10)
+	var i *big.Int
+	if err := Unmarshal(NewInt(huge), &i); err != nil || i.Cmp(huge) != 0 {
+		t.Errorf("expected a big integer to fill a *big.Int, got %v, %v", i, err)
+	}
+
+	var n uint8
+	for _, tt := range []struct {
+		input   Object
+		target  any
+		message string
+	}{
+		{&Integer{Value: 300}, &n, "value: 300 does not fit in Go uint8"},
+		{NewInt(new(big.Int).Lsh(big.NewInt(1), 64)), new(int64), "value: 18446744073709551616 does not fit in Go int64"},
+		{&String{Value: "x"}, &address, "value: cannot unmarshal STRING into Go object.testAddress"},
+		{&Array{Elements: []Object{&Float{Value: 1.5}}}, new([]int), "value[0]: cannot unmarshal FLOAT into Go int"},
+		{&Array{}, new([1]int), "value: cannot unmarshal 0 elements into Go [1]int"},
+		{&Integer{Value: 1}, n, "object.Unmarshal needs a non-nil pointer, got uint8"},
+	} {
+		if err := Unmarshal(tt.input, tt.target); err == nil || err.Error() != tt.message {
+			t.Errorf("Unmarshal(%s): expected error %q, got %v", tt.input.Inspect(), tt.message, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package object
+
+import (
+	"strconv"
+	"strings"
+)
+
+const PIPELINE_OBJ = "PIPELINE"
+
+// Pipeline is a chain of commands, each reading what the one before it
+// writes, as built by sh() and |. Nothing runs until it is sent somewhere,
+// and every step of building gives a new Pipeline, so one can be reused as
+// the start of several others.
+type Pipeline struct {
+	Stages    [][]string // each command with its arguments, in order
+	InputFile string     // file the first command reads, if not ""
+	Input     *string    // text the first command reads, if not nil
+}
+
+func (p *Pipeline) Type() ObjectType { return PIPELINE_OBJ }
+func (p *Pipeline) Inspect() string {
+	stages := make([]string, len(p.Stages))
+	for i, stage := range p.Stages {
+		stages[i] = StageString(stage)
+	}
+	out := "<pipeline: " + strings.Join(stages, " | ")
+	if p.InputFile != "" {
+		out += " < " + strconv.Quote(p.InputFile)
+	}
+	return out + ">"
+}
+
+// StageString writes a command and its arguments as they would be typed,
+// quoting any that are empty or contain spaces or quotes.
+func StageString(stage []string) string {
+	words := make([]string, len(stage))
+	for i, word := range stage {
+		if word == "" || strings.ContainsAny(word, " \t\n'\"\\") {
+			word = strconv.Quote(word)
+		}
+		words[i] = word
+	}
+	return strings.Join(words, " ")
+}
+
+// Then returns a pipeline that runs p and then next, reading from p. Input
+// given to next is dropped, since it reads from p instead.
+func (p *Pipeline) Then(next *Pipeline) *Pipeline {
+	joined := *p
+	joined.Stages = append(append([][]string{}, p.Stages...), next.Stages...)
+	return &joined
+}
+
+// WithInputFile returns a copy of p whose first command reads path.
+func (p *Pipeline) WithInputFile(path string) *Pipeline {
+	copied := *p
+	copied.InputFile, copied.Input = path, nil
+	return &copied
+}
+
+// WithInput returns a copy of p whose first command reads text.
+func (p *Pipeline) WithInput(text string) *Pipeline {
+	copied := *p
+	copied.InputFile, copied.Input = "", &text
+	return &copied
+}
@@ -0,0 +1,78 @@
+package object
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+	"strings"
+)
+
+const (
+	RECORD_TYPE_OBJ = "RECORD_TYPE"
+	RECORD_OBJ      = "RECORD"
+)
+
+// RecordType is the constructor declared by `record Name(field, ...)`.
+type RecordType struct {
+	Name   string
+	Fields []string
+}
+
+func (rt *RecordType) Type() ObjectType { return RECORD_TYPE_OBJ }
+func (rt *RecordType) Inspect() string {
+	return "<record " + rt.Name + "(" + strings.Join(rt.Fields, ", ") + ")>"
+}
+
+// Record is an immutable value built by a RecordType. Records with the same
+// type and equal fields are equal and hash alike.
+type Record struct {
+	RecordType *RecordType
+	Values     []Object
+}
+
+func (r *Record) Type() ObjectType { return RECORD_OBJ }
+func (r *Record) Inspect() string {
+	var out bytes.Buffer
+	out.WriteString(r.RecordType.Name)
+	out.WriteString("(")
+	for i, field := range r.RecordType.Fields {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(field)
+		out.WriteString("=")
+		out.WriteString(r.Values[i].Inspect())
+	}
+	out.WriteString(")")
+	return out.String()
+}
+
+// Get returns the value of a field by name.
+func (r *Record) Get(field string) (Object, bool) {
+	for i, name := range r.RecordType.Fields {
+		if name == field {
+			return r.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+func (r *Record) HashKey() HashKey {
+	return HashKey{Type: r.Type(), Value: hashFields(r.RecordType.Name, r.Values)}
+}
+
+// hashFields combines a type name and field values into one hash. Values
+// that are not hashable themselves contribute their printed form.
+func hashFields(name string, values []Object) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	for _, value := range values {
+		if key, ok := HashKeyOf(value); ok {
+			h.Write([]byte(key.Type))
+			binary.Write(h, binary.LittleEndian, key.Value)
+		} else {
+			h.Write([]byte(value.Inspect()))
+		}
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,167 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	BLOOM_FILTER_OBJ = "BLOOM_FILTER"
+	HYPERLOGLOG_OBJ  = "HYPERLOGLOG"
+)
+
+// SketchHash spreads the hash key of obj over all 64 bits, for the
+// probabilistic structures below. It reports false if obj cannot be a hash
+// key.
+func SketchHash(obj Object) (uint64, bool) {
+	key, ok := HashKeyOf(obj)
+	if !ok {
+		return 0, false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key.Type))
+	// The splitmix64 finalizer, since the keys of small integers are the
+	// integers themselves.
+	x := key.Value ^ h.Sum64()
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31), true
+}
+
+// BloomFilter tells whether a value may have been added to it in a fixed,
+// small amount of memory. It never misses a value that was added, but once
+// Capacity values are in it, it wrongly claims about ErrorRate of the
+// others too.
+type BloomFilter struct {
+	Capacity  int
+	ErrorRate float64
+	Count     int // values added that it did not already seem to hold
+	bits      []uint64
+	size      uint64 // number of bits
+	hashes    int    // bits set for each value
+}
+
+// NewBloomFilter sizes a filter for capacity values at errorRate.
+func NewBloomFilter(capacity int, errorRate float64) *BloomFilter {
+	size := math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+	hashes := int(math.Round(size / float64(capacity) * math.Ln2))
+	return &BloomFilter{
+		Capacity:  capacity,
+		ErrorRate: errorRate,
+		bits:      make([]uint64, (uint64(size)+63)/64),
+		size:      uint64(size),
+		hashes:    max(hashes, 1),
+	}
+}
+
+func (b *BloomFilter) Type() ObjectType { return BLOOM_FILTER_OBJ }
+func (b *BloomFilter) Inspect() string {
+	return fmt.Sprintf("<bloom filter: %d added, capacity %d>", b.Count, b.Capacity)
+}
+
+// positions calls fn with each bit of the value hashed to h, by double
+// hashing with the two halves of h.
+func (b *BloomFilter) positions(h uint64, fn func(word int, mask uint64)) {
+	h1, h2 := h&0xffffffff, h>>32|1
+	for i := 0; i < b.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % b.size
+		fn(int(bit/64), 1<<(bit%64))
+	}
+}
+
+// Add adds the value hashed to h, reporting whether the filter did not
+// already seem to hold it.
+func (b *BloomFilter) Add(h uint64) bool {
+	added := false
+	b.positions(h, func(word int, mask uint64) {
+		if b.bits[word]&mask == 0 {
+			added = true
+			b.bits[word] |= mask
+		}
+	})
+	if added {
+		b.Count++
+	}
+	return added
+}
+
+// MayContain reports whether the value hashed to h may have been added.
+func (b *BloomFilter) MayContain(h uint64) bool {
+	found := true
+	b.positions(h, func(word int, mask uint64) {
+		if b.bits[word]&mask == 0 {
+			found = false
+		}
+	})
+	return found
+}
+
+// Merge adds every value of other, which must have been made with the same
+// capacity and error rate.
+func (b *BloomFilter) Merge(other *BloomFilter) error {
+	if b.size != other.size || b.hashes != other.hashes {
+		return errors.New("bloom filters must have the same capacity and error rate to merge")
+	}
+	for i := range b.bits {
+		b.bits[i] |= other.bits[i]
+	}
+	b.Count += other.Count
+	return nil
+}
+
+// HyperLogLog estimates how many distinct values were added to it, within
+// about 1.04/sqrt(2^Precision) of the true count, in 2^Precision bytes.
+type HyperLogLog struct {
+	Precision int
+	registers []uint8
+}
+
+// NewHyperLogLog makes an empty HyperLogLog with 2^precision registers.
+func NewHyperLogLog(precision int) *HyperLogLog {
+	return &HyperLogLog{Precision: precision, registers: make([]uint8, 1<<precision)}
+}
+
+func (h *HyperLogLog) Type() ObjectType { return HYPERLOGLOG_OBJ }
+func (h *HyperLogLog) Inspect() string {
+	return fmt.Sprintf("<hyperloglog: about %d distinct>", h.Count())
+}
+
+// Add adds the value hashed to x.
+func (h *HyperLogLog) Add(x uint64) {
+	register := x >> (64 - h.Precision)
+	rank := uint8(bits.LeadingZeros64(x<<h.Precision|1<<(h.Precision-1)) + 1)
+	h.registers[register] = max(h.registers[register], rank)
+}
+
+// Count estimates the number of distinct values added.
+func (h *HyperLogLog) Count() uint64 {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate while many registers are empty.
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(estimate))
+}
+
+// Merge makes h count the values of other as well, which must have the
+// same precision.
+func (h *HyperLogLog) Merge(other *HyperLogLog) error {
+	if h.Precision != other.Precision {
+		return errors.New("hyperloglogs must have the same precision to merge")
+	}
+	for i, r := range other.registers {
+		h.registers[i] = max(h.registers[i], r)
+	}
+	return nil
+}
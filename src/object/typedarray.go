@@ -0,0 +1,43 @@
+package object
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	INT_ARRAY_OBJ   = "INT_ARRAY"
+	FLOAT_ARRAY_OBJ = "FLOAT_ARRAY"
+)
+
+// IntArray is an array of integers stored as a Go slice rather than one
+// object per element, for large numeric data. Unlike an Array it is never
+// modified in place, so slices of it may share its Values.
+type IntArray struct {
+	Values []int64
+}
+
+func (a *IntArray) Type() ObjectType { return INT_ARRAY_OBJ }
+
+func (a *IntArray) Inspect() string {
+	parts := make([]string, len(a.Values))
+	for i, v := range a.Values {
+		parts[i] = strconv.FormatInt(v, 10)
+	}
+	return "intarray([" + strings.Join(parts, ", ") + "])"
+}
+
+// FloatArray is the float counterpart of IntArray.
+type FloatArray struct {
+	Values []float64
+}
+
+func (a *FloatArray) Type() ObjectType { return FLOAT_ARRAY_OBJ }
+
+func (a *FloatArray) Inspect() string {
+	parts := make([]string, len(a.Values))
+	for i, v := range a.Values {
+		parts[i] = (&Float{Value: v}).Inspect()
+	}
+	return "floatarray([" + strings.Join(parts, ", ") + "])"
+}
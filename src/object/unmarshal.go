@@ -0,0 +1,283 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	bigIntType   = reflect.TypeOf(big.Int{})
+	objectType   = reflect.TypeOf((*Object)(nil)).Elem()
+)
+
+// Unmarshal stores obj in the Go value that v points to, converting it to
+// the type found there. It is the inverse of FromGo:
+//
+//   - a Hash, Record or @data instance fills a struct field by field, using
+//     the names FromGo gives them, and a map with any key type
+//   - an Array or Tuple fills a slice or an array of the same length
+//   - Integer, Float, String and Boolean fill Go numbers, strings and bools;
+//     an Integer that does not fit the Go type is an error
+//   - an Integer or BigInteger fills a big.Int
+//   - Bytes or a String fills a []byte, and Bytes a string
+//   - a String fills a time.Time in RFC 3339 format, and a number a
+//     time.Duration as seconds
+//   - None sets the Go zero value
+//   - an interface{} gets what ToGo returns, and a field of type Object gets
+//     obj itself
+//
+// Keys of obj that name no struct field are ignored, and fields with no key
+// keep their value.
+func Unmarshal(obj Object, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("object.Unmarshal needs a non-nil pointer, got %T", v)
+	}
+	return unmarshal(obj, rv.Elem(), "value")
+}
+
+func unmarshal(obj Object, rv reflect.Value, path string) error {
+	if rv.Type() == objectType || (rv.Kind() == reflect.Interface && rv.NumMethod() > 0) {
+		if obj == nil || !reflect.TypeOf(obj).AssignableTo(rv.Type()) {
+			return mismatch(obj, rv, path)
+		}
+		rv.Set(reflect.ValueOf(obj))
+		return nil
+	}
+	if obj == nil || obj.Type() == NONE_OBJ {
+		rv.SetZero()
+		return nil
+	}
+
+	switch rv.Type() {
+	case timeType:
+		str, ok := obj.(*String)
+		if !ok {
+			return mismatch(obj, rv, path)
+		}
+		t, err := time.Parse(time.RFC3339Nano, str.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		seconds, ok := number(obj)
+		if !ok {
+			return mismatch(obj, rv, path)
+		}
+		rv.SetInt(int64(seconds * float64(time.Second)))
+		return nil
+	case bigIntType:
+		switch obj := obj.(type) {
+		case *Integer:
+			rv.Set(reflect.ValueOf(big.NewInt(obj.Value)).Elem())
+		case *BigInteger:
+			rv.Set(reflect.ValueOf(new(big.Int).Set(obj.Value)).Elem())
+		default:
+			return mismatch(obj, rv, path)
+		}
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		value, err := toGo(obj, path)
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(value))
+	case reflect.Pointer:
+		target := reflect.New(rv.Type().Elem())
+		if err := unmarshal(obj, target.Elem(), path); err != nil {
+			return err
+		}
+		rv.Set(target)
+	case reflect.Bool:
+		b, ok := obj.(*Boolean)
+		if !ok {
+			return mismatch(obj, rv, path)
+		}
+		rv.SetBool(b.Value)
+	case reflect.String:
+		switch obj := obj.(type) {
+		case *String:
+			rv.SetString(obj.Value)
+		case *Bytes:
+			rv.SetString(string(obj.Value))
+		default:
+			return mismatch(obj, rv, path)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := obj.(*BigInteger); ok {
+			return fmt.Errorf("%s: %s does not fit in Go %s", path, n.Inspect(), rv.Type())
+		}
+		n, ok := obj.(*Integer)
+		if !ok {
+			return mismatch(obj, rv, path)
+		}
+		if rv.OverflowInt(n.Value) {
+			return fmt.Errorf("%s: %d does not fit in Go %s", path, n.Value, rv.Type())
+		}
+		rv.SetInt(n.Value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n, ok := obj.(*BigInteger); ok {
+			if !n.Value.IsUint64() || rv.OverflowUint(n.Value.Uint64()) {
+				return fmt.Errorf("%s: %s does not fit in Go %s", path, n.Inspect(), rv.Type())
+			}
+			rv.SetUint(n.Value.Uint64())
+			return nil
+		}
+		n, ok := obj.(*Integer)
+		if !ok {
+			return mismatch(obj, rv, path)
+		}
+		if n.Value < 0 || rv.OverflowUint(uint64(n.Value)) {
+			return fmt.Errorf("%s: %d does not fit in Go %s", path, n.Value, rv.Type())
+		}
+		rv.SetUint(uint64(n.Value))
+	case reflect.Float32, reflect.Float64:
+		f, ok := number(obj)
+		if !ok {
+			return mismatch(obj, rv, path)
+		}
+		if rv.Kind() == reflect.Float32 && math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+			return fmt.Errorf("%s: %v does not fit in Go float32", path, f)
+		}
+		rv.SetFloat(f)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			switch obj := obj.(type) {
+			case *String:
+				rv.SetBytes([]byte(obj.Value))
+				return nil
+			case *Bytes:
+				rv.SetBytes(append([]byte(nil), obj.Value...))
+				return nil
+			}
+		}
+		elements, ok := elementsOf(obj)
+		if !ok {
+			return mismatch(obj, rv, path)
+		}
+		slice := reflect.MakeSlice(rv.Type(), len(elements), len(elements))
+		for i, element := range elements {
+			if err := unmarshal(element, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+	case reflect.Array:
+		elements, ok := elementsOf(obj)
+		if !ok {
+			return mismatch(obj, rv, path)
+		}
+		if len(elements) != rv.Len() {
+			return fmt.Errorf("%s: cannot unmarshal %d elements into Go %s", path, len(elements), rv.Type())
+		}
+		for i, element := range elements {
+			if err := unmarshal(element, rv.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch(obj, rv, path)
+		}
+		m := reflect.MakeMapWithSize(rv.Type(), len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			key := reflect.New(rv.Type().Key()).Elem()
+			if err := unmarshal(pair.Key, key, path); err != nil {
+				return err
+			}
+			value := reflect.New(rv.Type().Elem()).Elem()
+			if err := unmarshal(pair.Value, value, fmt.Sprintf("%s[%s]", path, pair.Key.Inspect())); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		rv.Set(m)
+	case reflect.Struct:
+		fields, ok := fieldsOf(obj)
+		if !ok {
+			return mismatch(obj, rv, path)
+		}
+		for _, field := range structFields(rv.Type()) {
+			value, ok := fields[field.name]
+			if !ok {
+				continue
+			}
+			if err := unmarshal(value, rv.FieldByIndex(field.index), path+"."+field.name); err != nil {
+				return err
+			}
+		}
+	default:
+		return mismatch(obj, rv, path)
+	}
+	return nil
+}
+
+func mismatch(obj Object, rv reflect.Value, path string) error {
+	typ := ObjectType("nil")
+	if obj != nil {
+		typ = obj.Type()
+	}
+	return fmt.Errorf("%s: cannot unmarshal %s into Go %s", path, typ, rv.Type())
+}
+
+func number(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value), true
+	case *BigInteger:
+		f, _ := obj.Value.Float64()
+		return f, true
+	case *Float:
+		return obj.Value, true
+	}
+	return 0, false
+}
+
+func elementsOf(obj Object) ([]Object, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.Elements, true
+	case *Tuple:
+		return obj.Elements, true
+	}
+	return nil, false
+}
+
+// fieldsOf returns the members of a Hash with string keys, a Record or a
+// @data instance by name.
+func fieldsOf(obj Object) (map[string]Object, bool) {
+	fields := make(map[string]Object)
+	switch obj := obj.(type) {
+	case *Hash:
+		for _, pair := range obj.Pairs {
+			if key, ok := pair.Key.(*String); ok {
+				fields[key.Value] = pair.Value
+			}
+		}
+	case *Record:
+		for i, name := range obj.RecordType.Fields {
+			fields[name] = obj.Values[i]
+		}
+	case *Instance:
+		if !obj.Grimoire.IsData {
+			return nil, false
+		}
+		for i, value := range obj.FieldValues() {
+			fields[obj.Grimoire.Fields[i]] = value
+		}
+	default:
+		return nil, false
+	}
+	return fields, true
+}
@@ -0,0 +1,63 @@
+package object
+
+import (
+	"fmt"
+	"weak"
+)
+
+const WEAKREF_OBJ = "WEAKREF"
+
+// WeakRef refers to an object without keeping it alive. Once nothing else
+// holds the referent, Go's collector may reclaim it and the reference goes
+// dead.
+type WeakRef struct {
+	Kind   ObjectType    // Type of the referent, kept for Inspect once it is gone
+	target func() Object // returns nil after the referent has been collected
+}
+
+// NewWeakRef makes a weak reference to obj. Only heap objects with identity
+// can be referenced weakly; values such as integers and strings cannot.
+func NewWeakRef(obj Object) (*WeakRef, bool) {
+	var target func() Object
+	switch obj := obj.(type) {
+	case *Instance:
+		target = weakTarget(obj)
+	case *Array:
+		target = weakTarget(obj)
+	case *Hash:
+		target = weakTarget(obj)
+	case *Tuple:
+		target = weakTarget(obj)
+	case *Function:
+		target = weakTarget(obj)
+	case *Grimoire:
+		target = weakTarget(obj)
+	default:
+		return nil, false
+	}
+	return &WeakRef{Kind: obj.Type(), target: target}, true
+}
+
+func weakTarget[T any, P interface {
+	*T
+	Object
+}](obj P) func() Object {
+	ptr := weak.Make((*T)(obj))
+	return func() Object {
+		if v := ptr.Value(); v != nil {
+			return P(v)
+		}
+		return nil
+	}
+}
+
+// Get returns the referent, or nil if it has been collected.
+func (w *WeakRef) Get() Object { return w.target() }
+
+func (w *WeakRef) Type() ObjectType { return WEAKREF_OBJ }
+func (w *WeakRef) Inspect() string {
+	if w.Get() == nil {
+		return fmt.Sprintf("<weakref to %s (dead)>", w.Kind)
+	}
+	return fmt.Sprintf("<weakref to %s>", w.Kind)
+}
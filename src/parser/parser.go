@@ -1,9 +1,12 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/javanhut/Carrion/src/ast"
 	"github.com/javanhut/Carrion/src/lexer"
@@ -41,6 +44,7 @@ var precedences = map[token.TokenType]int{
 	token.PLUS:            SUM,
 	token.MINUS:           SUM,
 	token.SLASH:           PRODUCT,
+	token.FLOORDIV:        PRODUCT,
 	token.ASTERISK:        PRODUCT,
 	token.MOD:             PRODUCT,
 	token.EXPONENT:        PRODUCT,
@@ -66,16 +70,35 @@ type (
 	postfixParseFn func(ast.Expression) ast.Expression
 )
 
+// ParseError is a syntax error and where it was found. Expected and Found
+// name the token types involved when a particular token was required.
+type ParseError struct {
+	Position token.Position
+	Message  string
+	Expected string
+	Found    string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Position, e.Message)
+}
+
 type Parser struct {
 	l                 *lexer.Lexer
 	currToken         token.Token
 	peekToken         token.Token
 	errors            []string
+	diagnostics       []ParseError
+	panicking         bool // an error was reported and the parser has not yet synchronized
 	contextStack      []string
+	loopLabels        []string
 	prefixParseFns    map[token.TokenType]prefixParseFn
 	infixParseFns     map[token.TokenType]infixParseFn
 	postfixParseFns   map[token.TokenType]postfixParseFn
 	statementParseFns map[token.TokenType]func() ast.Statement
+	pastPragmas       bool   // a statement other than a pragma has been parsed
+	strict            bool   // the file started with `pragma strict`
+	version           string // the language version the file is parsed as
 }
 
 func (p *Parser) isInsideGrimoire() bool {
@@ -86,7 +109,7 @@ func (p *Parser) isInsideGrimoire() bool {
 }
 
 func New(l *lexer.Lexer, fileName ...string) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: []string{}, version: token.CurrentVersion}
 	p.nextToken()
 	p.nextToken()
 
@@ -112,6 +135,7 @@ func New(l *lexer.Lexer, fileName ...string) *Parser {
 	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
 	p.registerPrefix(token.UNDERSCORE, func() ast.Expression { return nil })
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.BYTES, p.parseBytesLiteral)
 	p.registerPrefix(token.LBRACK, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	p.registerPrefix(token.NONE, p.parseNoneLiteral)
@@ -140,6 +164,7 @@ func New(l *lexer.Lexer, fileName ...string) *Parser {
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.FLOORDIV, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.MOD, p.parseInfixExpression)
 	p.registerInfix(token.EXPONENT, p.parseInfixExpression)
@@ -209,11 +234,90 @@ func (p *Parser) parseCommaExpression(left ast.Expression) ast.Expression {
 }
 
 func (p *Parser) parseStopStatement() ast.Statement {
-	return &ast.StopStatement{Token: p.currToken}
+	stmt := &ast.StopStatement{Token: p.currToken}
+	stmt.Label = p.parseLoopControlLabel()
+	return stmt
 }
 
 func (p *Parser) parseSkipStatement() ast.Statement {
-	return &ast.SkipStatement{Token: p.currToken}
+	stmt := &ast.SkipStatement{Token: p.currToken}
+	stmt.Label = p.parseLoopControlLabel()
+	return stmt
+}
+
+var scopeKeywords = map[string]token.TokenType{
+	"global": token.GLOBAL,
+	"outer":  token.OUTER,
+}
+
+func (p *Parser) parseScopeStatement() ast.Statement {
+	stmt := &ast.ScopeStatement{Token: p.currToken}
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal})
+		if !p.peekTokenIs(token.COMMA) {
+			return stmt
+		}
+		p.nextToken()
+	}
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.currToken}
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal})
+		if !p.peekTokenIs(token.COMMA) {
+			return stmt
+		}
+		p.nextToken()
+	}
+}
+
+// parseLoopControlLabel reads the optional loop label following `stop` or
+// `skip`, checking that it names an enclosing loop.
+func (p *Parser) parseLoopControlLabel() *ast.Identifier {
+	if !p.peekTokenIs(token.IDENT) {
+		return nil
+	}
+	keyword := p.currToken.Literal
+	p.nextToken()
+	label := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	for _, name := range p.loopLabels {
+		if name == label.Value {
+			return label
+		}
+	}
+	p.addError(fmt.Sprintf("%s: no enclosing loop labeled '%s'", keyword, label.Value))
+	return label
+}
+
+// parseLoopLabel reads an optional `as label` suffix on a loop header.
+func (p *Parser) parseLoopLabel() *ast.Identifier {
+	if !p.peekTokenIs(token.AS) {
+		return nil
+	}
+	p.nextToken()
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	return &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+}
+
+// pushLoopLabel makes label visible to stop/skip statements in the loop body
+// and returns a func that removes it again.
+func (p *Parser) pushLoopLabel(label *ast.Identifier) func() {
+	if label == nil {
+		return func() {}
+	}
+	p.loopLabels = append(p.loopLabels, label.Value)
+	return func() {
+		p.loopLabels = p.loopLabels[:len(p.loopLabels)-1]
+	}
 }
 
 func (p *Parser) parseCheckStatement() ast.Statement {
@@ -263,7 +367,10 @@ func (p *Parser) parseFStringLiteral() ast.Expression {
 	for i < len(raw) {
 		ch := raw[i]
 
-		if ch == '{' {
+		if (ch == '{' || ch == '}') && i+1 < len(raw) && raw[i+1] == ch {
+			builder.WriteByte(ch)
+			i += 2
+		} else if ch == '{' {
 			if builder.Len() > 0 {
 				fslit.Parts = append(fslit.Parts, &ast.FStringText{Value: builder.String()})
 				builder.Reset()
@@ -271,13 +378,13 @@ func (p *Parser) parseFStringLiteral() ast.Expression {
 
 			end := findMatchingBrace(raw, i+1)
 			if end < 0 {
-				p.errors = append(p.errors, "Unclosed brace in f-string")
+				p.addError("Unclosed brace in f-string")
 				return fslit
 			}
-			exprStr := raw[i+1 : end]
+			exprStr, spec := splitFormatSpec(raw[i+1 : end])
 
 			expr := p.parseFStringExpression(exprStr)
-			fslit.Parts = append(fslit.Parts, &ast.FStringExpr{Expr: expr})
+			fslit.Parts = append(fslit.Parts, &ast.FStringExpr{Expr: expr, Spec: spec})
 
 			i = end + 1
 		} else {
@@ -293,15 +400,62 @@ func (p *Parser) parseFStringLiteral() ast.Expression {
 	return fslit
 }
 
+// findMatchingBrace finds the } that closes the f-string field starting at
+// start, passing over brackets, braces and strings inside it.
 func findMatchingBrace(s string, start int) int {
+	depth := 0
+	var quote byte
 	for i := start; i < len(s); i++ {
-		if s[i] == '}' {
+		ch := s[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '{' || ch == '(' || ch == '[':
+			depth++
+		case ch == '}' && depth == 0:
 			return i
+		case ch == '}' || ch == ')' || ch == ']':
+			depth--
 		}
 	}
 	return -1
 }
 
+// splitFormatSpec splits the inside of an f-string field at the colon that
+// starts its format spec, one outside brackets, braces and quotes so that
+// slices such as {xs[1:3]} and hashes such as { {"a": 1}["a"] } are left
+// whole.
+func splitFormatSpec(field string) (string, string) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(field); i++ {
+		ch := field[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '{' || ch == '(' || ch == '[':
+			depth++
+		case ch == '}' || ch == ')' || ch == ']':
+			depth--
+		case ch == ':' && depth == 0:
+			return field[:i], field[i+1:]
+		}
+	}
+	return field, ""
+}
+
 func (p *Parser) parseFStringExpression(exprStr string) ast.Expression {
 	l := lexer.New(
 		exprStr,
@@ -310,12 +464,13 @@ func (p *Parser) parseFStringExpression(exprStr string) ast.Expression {
 
 	program := subParser.ParseProgram()
 
-	if len(program.Statements) == 1 {
-		if es, ok := program.Statements[0].(*ast.ExpressionStatement); ok {
+	if len(program.Statements) == 1 && len(subParser.Errors()) == 0 {
+		if es, ok := program.Statements[0].(*ast.ExpressionStatement); ok && es.Expression != nil {
 			return es.Expression
 		}
 	}
 
+	p.addError(fmt.Sprintf("invalid expression in f-string: {%s}", exprStr))
 	return nil
 }
 
@@ -368,11 +523,11 @@ func (p *Parser) parseArcaneGrimoire() ast.Statement {
 func (p *Parser) parseArcaneMethod() *ast.ArcaneSpell {
 	p.nextToken()
 	if !p.expectPeek(token.ARCANESPELL) {
-		p.errors = append(p.errors, "expected 'arcanespell' after '@'")
+		p.addError("expected 'arcanespell' after '@'")
 		return nil
 	}
 	if p.currToken.Literal != "arcanespell" {
-		p.errors = append(p.errors, "expected 'arcanespell' after '@', got "+p.currToken.Literal)
+		p.addError("expected 'arcanespell' after '@', got "+p.currToken.Literal)
 		return nil
 	}
 
@@ -381,7 +536,7 @@ func (p *Parser) parseArcaneMethod() *ast.ArcaneSpell {
 	}
 
 	if !p.peekTokenIs(token.SPELL) && !p.peekTokenIs(token.INIT) {
-		p.errors = append(p.errors, "expected 'spell' or 'init' after '@arcanespell'")
+		p.addError("expected 'spell' or 'init' after '@arcanespell'")
 		return nil
 	}
 	p.nextToken()
@@ -390,7 +545,7 @@ func (p *Parser) parseArcaneMethod() *ast.ArcaneSpell {
 	if p.currToken.Type == token.SPELL {
 
 		if !p.expectPeek(token.IDENT) {
-			p.errors = append(p.errors, "expected method name after 'spell'")
+			p.addError("expected method name after 'spell'")
 			return nil
 		}
 		arcMethod.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
@@ -403,8 +558,24 @@ func (p *Parser) parseArcaneMethod() *ast.ArcaneSpell {
 		arcMethod.Parameters = p.parseFunctionParameters()
 	}
 
-	if p.peekTokenIs(token.COLON) {
+	if !p.peekTokenIs(token.COLON) {
+		return arcMethod
+	}
+	p.nextToken()
+	// The body, usually just ignore, belongs to the method rather than
+	// to the module around the grimoire.
+	if p.peekTokenIs(token.NEWLINE) {
+		p.nextToken()
+		if p.peekTokenIs(token.INDENT) {
+			p.nextToken()
+			arcMethod.Body = p.parseBlockStatement()
+		}
+	} else {
 		p.nextToken()
+		arcMethod.Body = &ast.BlockStatement{
+			Token:      p.currToken,
+			Statements: []ast.Statement{p.parseStatement()},
+		}
 	}
 
 	return arcMethod
@@ -412,9 +583,19 @@ func (p *Parser) parseArcaneMethod() *ast.ArcaneSpell {
 
 func (p *Parser) parseRaiseStatement() ast.Statement {
 	stmt := &ast.RaiseStatement{Token: p.currToken}
+	if p.peekTokenIs(token.NEWLINE) || p.peekTokenIs(token.SEMICOLON) ||
+		p.peekTokenIs(token.DEDENT) || p.peekTokenIs(token.EOF) {
+		return stmt
+	}
 
 	p.nextToken()
 	stmt.Error = p.parseExpression(LOWEST)
+	// `from` is only a keyword here, so it can still name a variable.
+	if p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "from" {
+		p.nextToken()
+		p.nextToken()
+		stmt.Cause = p.parseExpression(LOWEST)
+	}
 
 	if p.peekTokenIs(token.NEWLINE) || p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -459,7 +640,7 @@ func (p *Parser) parseAttemptStatement() ast.Statement {
 			if !p.expectPeek(token.RPAREN) {
 				return nil
 			}
-		} else {
+		} else if !p.peekTokenIs(token.COLON) && !p.peekTokenIs(token.AS) {
 			p.nextToken()
 			ensnareClause.Condition = p.parseExpression(LOWEST)
 		}
@@ -529,12 +710,12 @@ func (p *Parser) parseAttemptStatement() ast.Statement {
 }
 
 func (p *Parser) parseEnsnareStatement() ast.Statement {
-	p.errors = append(p.errors, "Unexpected 'ensnare' outside of 'attempt' block")
+	p.addError("Unexpected 'ensnare' outside of 'attempt' block")
 	return nil
 }
 
 func (p *Parser) parseResolveStatement() ast.Statement {
-	p.errors = append(p.errors, "Unexpected 'resolve' outside of 'attempt' block")
+	p.addError("Unexpected 'resolve' outside of 'attempt' block")
 	return nil
 }
 
@@ -565,7 +746,7 @@ func (p *Parser) parseMatchStatement() ast.Statement {
 		caseClause := &ast.CaseClause{Token: p.currToken}
 
 		p.nextToken()
-		caseClause.Condition = p.parseExpression(LOWEST)
+		caseClause.Condition = p.parseCasePattern()
 
 		if !p.expectPeek(token.COLON) {
 			return nil
@@ -630,6 +811,37 @@ func (p *Parser) parseMatchStatement() ast.Statement {
 	return stmt
 }
 
+// comparisonPatterns are the operators that can start a case condition.
+var comparisonPatterns = map[token.TokenType]bool{
+	token.LT: true, token.GT: true, token.LE: true, token.GE: true, token.EQ: true, token.NOT_EQ: true,
+}
+
+// parseCasePattern parses the condition of a case: a value, a range such
+// as 1..10, or a comparison such as > 100.
+func (p *Parser) parseCasePattern() ast.Expression {
+	if comparisonPatterns[p.currToken.Type] {
+		pattern := &ast.ComparisonPattern{Token: p.currToken, Operator: p.currToken.Literal}
+		p.nextToken()
+		pattern.Value = p.parseExpression(LOWEST)
+		if pattern.Value == nil {
+			return nil
+		}
+		return pattern
+	}
+	start := p.parseExpression(LOWEST)
+	if !p.peekTokenIs(token.DOTDOT) {
+		return start
+	}
+	p.nextToken()
+	pattern := &ast.RangePattern{Token: p.currToken, Start: start}
+	p.nextToken()
+	pattern.End = p.parseExpression(LOWEST)
+	if pattern.End == nil {
+		return nil
+	}
+	return pattern
+}
+
 func (p *Parser) skipNewlines() {
 	for p.peekTokenIs(token.NEWLINE) {
 		p.nextToken()
@@ -653,7 +865,10 @@ func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
 		Left:  left,
 	}
 
-	if !p.expectPeek(token.IDENT) {
+	// init is a keyword, but names a member in super.init(...).
+	if p.peekTokenIs(token.INIT) {
+		p.nextToken()
+	} else if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
@@ -682,6 +897,9 @@ func (p *Parser) parseParenExpression() ast.Expression {
 
 		for p.peekTokenIs(token.COMMA) {
 			p.nextToken()
+			if p.peekTokenIs(token.RPAREN) {
+				break
+			}
 			p.nextToken()
 			nextExpr := p.parseExpression(LOWEST)
 			if nextExpr != nil {
@@ -756,7 +974,7 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 	value, err := strconv.ParseFloat(p.currToken.Literal, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as float", p.currToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(msg)
 		return nil
 	}
 	lit.Value = value
@@ -826,6 +1044,9 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	list = append(list, p.parseExpression(LOWEST))
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+		if p.peekTokenIs(end) {
+			break
+		}
 		p.nextToken()
 		list = append(list, p.parseExpression(LOWEST))
 	}
@@ -861,23 +1082,109 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// ParseErrors returns the errors found so far with their positions, in the
+// same order as Errors.
+func (p *Parser) ParseErrors() []ParseError {
+	return p.diagnostics
+}
+
+// addError records a parse error at the current token.
+func (p *Parser) addError(msg string) {
+	p.report(ParseError{Position: p.currToken.Position, Message: msg})
+}
+
+// report records a parse error unless one has already been reported for the
+// statement being parsed: what follows the first error in a statement is
+// usually a consequence of it, so it is dropped until synchronize.
+func (p *Parser) report(err ParseError) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	p.diagnostics = append(p.diagnostics, err)
+	p.errors = append(p.errors, err.Message)
+}
+
+// statementKeywords are the tokens that can only begin a statement, so
+// synchronize can resume parsing at them.
+var statementKeywords = map[token.TokenType]bool{
+	token.IF:       true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.GRIMOIRE: true,
+	token.RECORD:   true,
+	token.EXTEND:   true,
+	token.ARCANE:   true,
+	token.SPELL:    true,
+	token.RETURN:   true,
+	token.IMPORT:   true,
+	token.MATCH:    true,
+	token.ATTEMPT:  true,
+	token.RAISE:    true,
+}
+
+// synchronize recovers from an error in the statement just parsed by
+// skipping to where the next statement starts: the end of the line, the end
+// of the block or a statement keyword. If the broken statement introduced an
+// indented block, that block is parsed and thrown away, so errors inside it
+// are still reported and its dedent does not end the enclosing block early.
+// It leaves the parser on the last token to skip, like parseStatement.
+func (p *Parser) synchronize() {
+	if !p.panicking {
+		return
+	}
+	p.panicking = false
+	for !p.currTokenIs(token.NEWLINE) && !p.currTokenIs(token.EOF) &&
+		!p.peekTokenIs(token.NEWLINE) && !p.peekTokenIs(token.DEDENT) &&
+		!p.peekTokenIs(token.EOF) && !statementKeywords[p.peekToken.Type] {
+		p.nextToken()
+	}
+	if p.peekTokenIs(token.NEWLINE) {
+		p.nextToken()
+	}
+	if p.currTokenIs(token.NEWLINE) && p.peekTokenIs(token.INDENT) {
+		p.nextToken()
+		p.parseBlockStatement()
+	}
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
 }
 
+func (p *Parser) parseBytesLiteral() ast.Expression {
+	return &ast.BytesLiteral{Token: p.currToken, Value: []byte(p.currToken.Literal)}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	value := (p.currToken.Type == token.TRUE)
 	return &ast.Boolean{Token: p.currToken, Value: value}
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.report(ParseError{
+		Position: p.peekToken.Position,
+		Message:  fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type),
+		Expected: string(t),
+		Found:    string(p.peekToken.Type),
+	})
 }
 
 func (p *Parser) nextToken() {
 	p.currToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.peekToken = token.InVersion(p.l.NextToken(), p.version)
+}
+
+// SetVersion makes the parser read the file as version of the language,
+// such as the one its carrion.mod names, instead of the current one. A
+// carrion_version pragma in the file overrides it.
+func (p *Parser) SetVersion(version string) {
+	if version == "" {
+		version = token.CurrentVersion
+	}
+	p.version = version
+	p.currToken = token.InVersion(p.currToken, version)
+	p.peekToken = token.InVersion(p.peekToken, version)
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -904,22 +1211,48 @@ func (p *Parser) ParseProgram() *ast.Program {
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
+		p.synchronize()
 		p.nextToken()
 	}
 	return program
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	// pragma is only a keyword in front of a pragma's name, so it stays
+	// usable as a name.
+	if p.currToken.Type == token.IDENT && p.currToken.Literal == "pragma" && p.peekTokenIs(token.IDENT) {
+		return p.parsePragmaStatement()
+	}
+	p.pastPragmas = true
+
 	switch p.currToken.Type {
+	case token.VAR:
+		return p.parseVarStatement()
+	case token.IDENT:
+		// lazy is only a keyword in front of import, so it stays usable as
+		// a name.
+		if p.currToken.Literal == "lazy" && p.peekTokenIs(token.IMPORT) {
+			return p.parseLazyImportStatement()
+		}
+		// global and outer are only keywords in front of the names they
+		// declare, so scripts can still use them as names.
+		if scope, ok := scopeKeywords[p.currToken.Literal]; ok && p.peekTokenIs(token.IDENT) {
+			p.currToken.Type = scope
+			return p.parseScopeStatement()
+		}
 	case token.IF:
 		return p.parseIfStatement()
 	case token.ELSE:
-		p.errors = append(p.errors, "Unexpected 'else' without matching 'if'")
+		p.addError("Unexpected 'else' without matching 'if'")
 		return nil
 	case token.WHILE:
 		return p.parseWhileStatement()
 	case token.GRIMOIRE:
 		return p.parseGrimoireDefinition()
+	case token.RECORD:
+		return p.parseRecordDefinition()
+	case token.EXTEND:
+		return p.parseExtendStatement()
 	case token.SPELL, token.INIT:
 
 		return p.parseFunctionDefinition()
@@ -933,6 +1266,8 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseMatchStatement()
 	case token.ATTEMPT:
 		return p.parseAttemptStatement()
+	case token.AUTOCLOSE:
+		return p.parseAutocloseStatement()
 	case token.RESOLVE:
 		return p.parseResolveStatement()
 	case token.RAISE:
@@ -947,6 +1282,12 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseStopStatement()
 	case token.CHECK:
 		return p.parseCheckStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
+	case token.AT:
+		if p.peekTokenIs(token.IDENT) {
+			return p.parseDecorator()
+		}
 	}
 	leftExpr := p.parseExpression(LOWEST)
 	if p.peekTokenIs(token.COLON) || p.peekTokenIs(token.ASSIGN) ||
@@ -974,14 +1315,17 @@ func (p *Parser) finishAssignmentStatement(leftExpr ast.Expression) ast.Statemen
 	if _, ok := leftExpr.(*ast.Identifier); ok {
 		if p.peekTokenIs(token.COLON) {
 			p.nextToken()
-			if !p.expectPeek(token.IDENT) {
+			if typeHint = p.parseTypeHint(); typeHint == nil {
 				return nil
 			}
-			typeHint = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
 		}
 	}
 
 	if !p.peekTokenIs(token.ASSIGN) {
+		if typeHint != nil && p.isInsideGrimoire() {
+			// A field declared in a grimoire body without a default.
+			return &ast.AssignStatement{Token: p.currToken, Name: leftExpr, TypeHint: typeHint, Strict: p.strict}
+		}
 		return nil
 	}
 	p.nextToken()
@@ -991,6 +1335,7 @@ func (p *Parser) finishAssignmentStatement(leftExpr ast.Expression) ast.Statemen
 		Name:     leftExpr,
 		Operator: p.currToken.Literal,
 		TypeHint: typeHint,
+		Strict:   p.strict,
 	}
 
 	p.nextToken()
@@ -998,13 +1343,86 @@ func (p *Parser) finishAssignmentStatement(leftExpr ast.Expression) ast.Statemen
 	return stmt
 }
 
+// parsePragmaStatement parses `pragma strict` or `pragma carrion_version
+// "1.0"`, which must come before any other statement of the file.
+func (p *Parser) parsePragmaStatement() ast.Statement {
+	stmt := &ast.PragmaStatement{Token: p.currToken}
+	p.nextToken()
+	stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	if p.pastPragmas {
+		p.addError(fmt.Sprintf("pragma %s must come before any other statement", stmt.Name.Value))
+		return nil
+	}
+	switch stmt.Name.Value {
+	case "strict":
+		p.strict = true
+	case "carrion_version":
+		if !p.expectPeek(token.STRING) {
+			p.addError("expected a version string after 'pragma carrion_version'")
+			return nil
+		}
+		stmt.Value = &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
+		if !token.KnownVersion(p.currToken.Literal) {
+			p.addError(fmt.Sprintf("unknown carrion_version %q; known versions are %s",
+				p.currToken.Literal, strings.Join(token.Versions, ", ")))
+			return nil
+		}
+		p.SetVersion(p.currToken.Literal)
+	default:
+		p.addError(fmt.Sprintf("unknown pragma '%s'", stmt.Name.Value))
+		return nil
+	}
+	return stmt
+}
+
+// parseVarStatement parses `var name = value`, or `var name: type = value`,
+// which declares name in the current scope.
+func (p *Parser) parseVarStatement() ast.Statement {
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	name := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	if !p.peekTokenIs(token.COLON) && !p.peekTokenIs(token.ASSIGN) {
+		p.addError(fmt.Sprintf("expected '=' after 'var %s'", name.Value))
+		return nil
+	}
+	stmt, ok := p.finishAssignmentStatement(name).(*ast.AssignStatement)
+	if !ok || stmt.Value == nil {
+		p.addError(fmt.Sprintf("expected '=' after 'var %s'", name.Value))
+		return nil
+	}
+	stmt.Declare = true
+	return stmt
+}
+
+// parseTypeHint parses the type after a `:`, either a name or `[name]` for
+// an array whose elements have that type.
+func (p *Parser) parseTypeHint() ast.Expression {
+	if p.peekTokenIs(token.LBRACK) {
+		p.nextToken()
+		hint := &ast.ArrayLiteral{Token: p.currToken}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		hint.Elements = []ast.Expression{&ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}}
+		if !p.expectPeek(token.RBRACK) {
+			return nil
+		}
+		return hint
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	return &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+}
+
 func (p *Parser) parseAssignmentStatement() *ast.AssignStatement {
 	stmt := &ast.AssignStatement{Token: p.currToken}
 
 	if p.currToken.Type == token.IDENT || p.currToken.Type == token.SELF {
 		stmt.Name = p.parseExpression(LOWEST)
 	} else {
-		p.errors = append(p.errors, "Invalid assignment target")
+		p.addError("Invalid assignment target")
 		return nil
 	}
 
@@ -1055,8 +1473,7 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 		p.nextToken()
 		return true
 	}
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.peekError(t)
 	return false
 }
 
@@ -1068,6 +1485,12 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	}
 
 	leftExp := prefix()
+	// The indent opening a block is not part of the statement after it,
+	// which may start with a token such as ( that would otherwise continue
+	// it.
+	if p.currTokenIs(token.INDENT) {
+		return leftExp
+	}
 	for !p.peekTokenIs(token.NEWLINE) &&
 		!p.peekTokenIs(token.SEMICOLON) &&
 		!p.peekTokenIs(token.EOF) &&
@@ -1097,6 +1520,15 @@ func (p *Parser) peekPrecedence() int {
 	return LOWEST
 }
 
+// Precedence reports how tightly the infix operator t binds, so tools that
+// print Carrion source can reproduce the parser's grouping.
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 func (p *Parser) currPrecedence() int {
 	if p, ok := precedences[p.currToken.Type]; ok {
 		return p
@@ -1105,8 +1537,18 @@ func (p *Parser) currPrecedence() int {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
+	if t == token.ILLEGAL {
+		// The lexer gives an ILLEGAL token the character it could not
+		// read, or a description of a literal it could not.
+		if lit := p.currToken.Literal; utf8.RuneCountInString(lit) > 1 {
+			p.addError(lit)
+		} else {
+			p.addError(fmt.Sprintf("unexpected character %q", lit))
+		}
+		return
+	}
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(msg)
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
@@ -1120,9 +1562,16 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.currToken}
 
 	value, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		// Too large for an int64, so the literal is a big integer.
+		if n, ok := new(big.Int).SetString(p.currToken.Literal, 0); ok {
+			lit.Big = n
+			return lit
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.currToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(msg)
 		return nil
 	}
 
@@ -1154,7 +1603,7 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	expression.Right = p.parseExpression(precedence)
 	if expression.Right == nil {
 		msg := fmt.Sprintf("no right-hand expression for infix operator %q", expression.Operator)
-		p.errors = append(p.errors, msg)
+		p.addError(msg)
 		return nil
 	}
 	return expression
@@ -1295,6 +1744,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
+		p.synchronize()
 		p.nextToken()
 	}
 
@@ -1332,6 +1782,9 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+		if p.peekTokenIs(token.RPAREN) {
+			break
+		}
 		p.nextToken()
 		args = append(args, p.parseExpression(LOWEST))
 	}
@@ -1381,10 +1834,12 @@ func (p *Parser) parseForStatement() ast.Statement {
 
 	p.nextToken()
 	stmt.Iterable = p.parseExpression(LOWEST)
+	stmt.Label = p.parseLoopLabel()
 
 	if !p.expectPeek(token.COLON) {
 		return nil
 	}
+	popLabel := p.pushLoopLabel(stmt.Label)
 
 	if p.peekTokenIs(token.NEWLINE) {
 		p.nextToken()
@@ -1399,33 +1854,52 @@ func (p *Parser) parseForStatement() ast.Statement {
 			Statements: []ast.Statement{p.parseStatement()},
 		}
 	}
+	popLabel()
 
 	if p.peekTokenIs(token.ELSE) {
-		p.nextToken()
-		if !p.expectPeek(token.COLON) {
+		stmt.Alternative = p.parseLoopElse()
+		if stmt.Alternative == nil {
 			return nil
 		}
-		if p.peekTokenIs(token.NEWLINE) {
-			p.nextToken()
-			if !p.expectPeek(token.INDENT) {
-				return nil
-			}
-			stmt.Alternative = p.parseBlockStatement()
-		} else {
-			p.nextToken()
-			stmt.Alternative = &ast.BlockStatement{
-				Token:      p.currToken,
-				Statements: []ast.Statement{p.parseStatement()},
-			}
-		}
 	}
 
 	return stmt
 }
 
+// parseLoopElse parses the `else:` block of a for or while loop, which runs
+// when the loop finishes without hitting `stop`.
+func (p *Parser) parseLoopElse() *ast.BlockStatement {
+	p.nextToken()
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+	if p.peekTokenIs(token.NEWLINE) {
+		p.nextToken()
+		if !p.expectPeek(token.INDENT) {
+			return nil
+		}
+		return p.parseBlockStatement()
+	}
+	p.nextToken()
+	return &ast.BlockStatement{
+		Token:      p.currToken,
+		Statements: []ast.Statement{p.parseStatement()},
+	}
+}
+
 func (p *Parser) parseFunctionDefinition() ast.Statement {
 	stmt := &ast.FunctionDefinition{Token: p.currToken}
 
+	// Loops around a spell's definition are not around its calls, so their
+	// labels cannot be used inside it.
+	p.contextStack = append(p.contextStack, "spell")
+	loopLabels := p.loopLabels
+	p.loopLabels = nil
+	defer func() {
+		p.contextStack = p.contextStack[:len(p.contextStack)-1]
+		p.loopLabels = loopLabels
+	}()
+
 	if p.currTokenIs(token.SPELL) {
 		p.nextToken()
 	}
@@ -1442,19 +1916,19 @@ func (p *Parser) parseFunctionDefinition() ast.Statement {
 		}
 	} else {
 
-		p.errors = append(p.errors, "Expected function name or 'init' after 'spell'")
+		p.addError("Expected function name or 'init' after 'spell'")
 		return nil
 	}
 
 	if !p.expectPeek(token.LPAREN) {
-		p.errors = append(p.errors, "Expected '(' after function name")
+		p.addError("Expected '(' after function name")
 		return nil
 	}
 
 	stmt.Parameters = p.parseFunctionParameters()
 
 	if !p.expectPeek(token.COLON) {
-		p.errors = append(p.errors, "Expected ':' after parameter list")
+		p.addError("Expected ':' after parameter list")
 		return nil
 	}
 
@@ -1512,47 +1986,49 @@ func (p *Parser) parseFunctionParameters() []*ast.Parameter {
 	}
 
 	p.nextToken()
+	parameters = append(parameters, p.parseParameter())
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if p.peekTokenIs(token.RPAREN) {
+			break
+		}
+		p.nextToken()
+		parameters = append(parameters, p.parseParameter())
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return parameters
+}
+
+// parseParameter parses one parameter starting at its name: an optional
+// `: type` hint, then an optional default written `=value`, or `=once value`
+// to evaluate it a single time when the spell is defined.
+func (p *Parser) parseParameter() *ast.Parameter {
 	param := &ast.Parameter{
 		Name: &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal},
 	}
 	if p.peekTokenIs(token.COLON) {
 		p.nextToken()
-		if !p.expectPeek(token.IDENT) {
-			return nil
+		if param.TypeHint = p.parseTypeHint(); param.TypeHint == nil {
+			return param
 		}
-		param.TypeHint = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
 	}
 	if p.peekTokenIs(token.ASSIGN) {
 		p.nextToken()
 		p.nextToken()
-		param.DefaultValue = p.parseExpression(LOWEST)
-	}
-	parameters = append(parameters, param)
-
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
-		param := &ast.Parameter{
-			Name: &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal},
-		}
-		if p.peekTokenIs(token.COLON) {
+		// `once` is only a marker when an expression follows it, so a
+		// default that is simply a variable named once still works.
+		if p.currTokenIs(token.IDENT) && p.currToken.Literal == "once" &&
+			!p.peekTokenIs(token.COMMA) && !p.peekTokenIs(token.RPAREN) {
+			param.Once = true
 			p.nextToken()
-			if !p.expectPeek(token.IDENT) {
-				return nil
-			}
-			param.TypeHint = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
-		}
-		if p.peekTokenIs(token.ASSIGN) {
-			p.nextToken()
-			p.nextToken()
-			param.DefaultValue = p.parseExpression(LOWEST)
 		}
-		parameters = append(parameters, param)
-	}
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+		param.DefaultValue = p.parseExpression(LOWEST)
 	}
-	return parameters
+	param.Required = p.strict && param.DefaultValue == nil
+	return param
 }
 
 func (p *Parser) parseWhileStatement() ast.Statement {
@@ -1570,6 +2046,7 @@ func (p *Parser) parseWhileStatement() ast.Statement {
 		p.nextToken()
 		stmt.Condition = p.parseExpression(LOWEST)
 	}
+	stmt.Label = p.parseLoopLabel()
 
 	if !p.expectPeek(token.COLON) {
 		return nil
@@ -1579,13 +2056,79 @@ func (p *Parser) parseWhileStatement() ast.Statement {
 		p.nextToken()
 	}
 
+	popLabel := p.pushLoopLabel(stmt.Label)
 	if p.peekTokenIs(token.INDENT) {
 		p.nextToken()
 		stmt.Body = p.parseBlockStatement()
 	} else {
 		stmt.Body = p.parseBlockStatement()
 	}
+	popLabel()
+
+	if p.peekTokenIs(token.ELSE) {
+		stmt.Alternative = p.parseLoopElse()
+		if stmt.Alternative == nil {
+			return nil
+		}
+	}
+
+	return stmt
+}
+
+// parseAutocloseStatement parses `autoclose resource as name:` and its
+// block, where `as name` is optional.
+func (p *Parser) parseAutocloseStatement() ast.Statement {
+	stmt := &ast.AutocloseStatement{Token: p.currToken}
+	p.nextToken()
+	stmt.Resource = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.AS) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Alias = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	}
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+	for p.peekTokenIs(token.NEWLINE) {
+		p.nextToken()
+	}
+	if p.peekTokenIs(token.INDENT) {
+		p.nextToken()
+	}
+	stmt.Body = p.parseBlockStatement()
+	return stmt
+}
+
+func (p *Parser) parseRecordDefinition() ast.Statement {
+	stmt := &ast.RecordDefinition{Token: p.currToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
 
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return stmt
+	}
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Fields = append(stmt.Fields, &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal})
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
 	return stmt
 }
 
@@ -1652,8 +2195,10 @@ func (p *Parser) parseGrimoireDefinition() ast.Statement {
 					} else {
 						stmt.Methods = append(stmt.Methods, fnDef)
 					}
-				} else {
-					continue
+				} else if field, ok := s.(*ast.AssignStatement); ok {
+					if _, ok := field.Name.(*ast.Identifier); ok {
+						stmt.Fields = append(stmt.Fields, field)
+					}
 				}
 			}
 		}
@@ -1662,7 +2207,7 @@ func (p *Parser) parseGrimoireDefinition() ast.Statement {
 			p.nextToken()
 			fnStmt := p.parseFunctionDefinition()
 			if fnStmt == nil {
-				p.errors = append(p.errors, "Invalid function definition in single-line grimoire")
+				p.addError("Invalid function definition in single-line grimoire")
 				return stmt
 			}
 			fnDef := fnStmt.(*ast.FunctionDefinition)
@@ -1677,11 +2222,116 @@ func (p *Parser) parseGrimoireDefinition() ast.Statement {
 	return stmt
 }
 
+// parseDecorator parses a decorator line such as `@data` together with the
+// grimoire it applies to.
+// parseDecorator parses the `@decorator` lines above a spell, each an
+// expression giving a spell to pass the new one to, or `@data` above a
+// grimoire.
+func (p *Parser) parseDecorator() ast.Statement {
+	var decorators []ast.Expression
+	for {
+		p.nextToken()
+		decorator := p.parseExpression(LOWEST)
+		if decorator == nil {
+			return nil
+		}
+		p.skipNewlines()
+		if ident, ok := decorator.(*ast.Identifier); ok && ident.Value == "data" &&
+			len(decorators) == 0 && p.peekTokenIs(token.GRIMOIRE) {
+			p.nextToken()
+			stmt, ok := p.parseGrimoireDefinition().(*ast.GrimoireDefinition)
+			if !ok {
+				return nil
+			}
+			stmt.Data = true
+			return stmt
+		}
+		decorators = append(decorators, decorator)
+		if !p.peekTokenIs(token.AT) {
+			break
+		}
+		p.nextToken()
+	}
+	if p.isInsideGrimoire() {
+		p.addError("decorators can only be used on spells outside a grimoire")
+		return nil
+	}
+	if !p.expectPeek(token.SPELL) {
+		return nil
+	}
+	stmt, ok := p.parseFunctionDefinition().(*ast.FunctionDefinition)
+	if !ok {
+		return nil
+	}
+	stmt.Decorators = decorators
+	return stmt
+}
+
+// parseExtendStatement parses `extend Name:` and the indented spells it
+// adds to the grimoire Name.
+func (p *Parser) parseExtendStatement() ast.Statement {
+	stmt := &ast.ExtendStatement{Token: p.currToken}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+	if !p.expectPeek(token.NEWLINE) || !p.expectPeek(token.INDENT) {
+		return nil
+	}
+	p.nextToken()
+
+	p.contextStack = append(p.contextStack, "grim")
+	defer func() {
+		p.contextStack = p.contextStack[:len(p.contextStack)-1]
+	}()
+	for _, s := range p.parseBlockStatement().Statements {
+		if exprStmt, ok := s.(*ast.ExpressionStatement); ok {
+			if exprStmt.Expression == nil {
+				continue
+			}
+			if strLit, ok := exprStmt.Expression.(*ast.StringLiteral); ok && strLit.Token.Type == token.DOCSTRING {
+				if stmt.DocString == nil && len(stmt.Methods) == 0 {
+					stmt.DocString = strLit
+				}
+				continue
+			}
+		}
+		fnDef, ok := s.(*ast.FunctionDefinition)
+		switch {
+		case !ok:
+			p.addError(fmt.Sprintf("extend %s can only add spells", stmt.Name.Value))
+			return nil
+		case fnDef.Name.Value == "init":
+			p.addError(fmt.Sprintf("extend %s cannot define init", stmt.Name.Value))
+			return nil
+		}
+		stmt.Methods = append(stmt.Methods, fnDef)
+	}
+	return stmt
+}
+
+func (p *Parser) parseLazyImportStatement() ast.Statement {
+	p.nextToken()
+	stmt, ok := p.parseImportStatement().(*ast.ImportStatement)
+	if !ok {
+		return nil
+	}
+	if len(stmt.Exposed) > 0 {
+		p.addError("lazy import cannot expose names; use 'as' and look them up on the module")
+		return nil
+	}
+	stmt.Lazy = true
+	return stmt
+}
+
 func (p *Parser) parseImportStatement() ast.Statement {
 	stmt := &ast.ImportStatement{Token: p.currToken}
 
 	if !p.expectPeek(token.STRING) {
-		p.errors = append(p.errors, "expected file path string after 'import'")
+		p.addError("expected file path string after 'import'")
 		return nil
 	}
 	stmt.FilePath = &ast.StringLiteral{
@@ -1692,13 +2342,29 @@ func (p *Parser) parseImportStatement() ast.Statement {
 	if p.peekTokenIs(token.AS) {
 		p.nextToken()
 		if !p.expectPeek(token.IDENT) {
-			p.errors = append(p.errors, "expected alias name after 'as'")
+			p.addError("expected alias name after 'as'")
 			return nil
 		}
 		stmt.Alias = &ast.Identifier{
 			Token: p.currToken,
 			Value: p.currToken.Literal,
 		}
+	} else if p.peekTokenIs(token.EXPOSE) {
+		p.nextToken()
+		for {
+			if !p.expectPeek(token.IDENT) {
+				p.addError("expected a name to expose")
+				return nil
+			}
+			stmt.Exposed = append(stmt.Exposed, &ast.Identifier{
+				Token: p.currToken,
+				Value: p.currToken.Literal,
+			})
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
 	}
 
 	return stmt
@@ -0,0 +1,99 @@
+package repl
+
+import (
+	"strings"
+)
+
+// needsMoreInput reports whether the buffered REPL input is an unfinished
+// statement. Input is unfinished while a bracket or triple-quoted string is
+// still open or the last line ends with a '\' continuing it, and once a
+// block has been opened with a trailing ':' it stays unfinished until the
+// user enters an empty line.
+func needsMoreInput(source string, blankLine bool) bool {
+	depth, inString := scanOpenDelimiters(source)
+	if depth > 0 || inString {
+		return true
+	}
+	lines := strings.Split(strings.TrimSuffix(source, "\n"), "\n")
+	if strings.HasSuffix(codePart(lines[len(lines)-1]), "\\") {
+		return true
+	}
+	if opensBlock(source) {
+		return !blankLine
+	}
+	return false
+}
+
+// opensBlock reports whether any line of source ends with ':' once comments
+// are stripped, i.e. whether it contains a block header.
+func opensBlock(source string) bool {
+	for _, line := range strings.Split(source, "\n") {
+		if strings.HasSuffix(codePart(line), ":") {
+			return true
+		}
+	}
+	return false
+}
+
+// codePart returns a line without its trailing comment and whitespace.
+func codePart(line string) string {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '/' && i+1 < len(line) && line[i+1] == '/':
+			return strings.TrimSpace(line[:i])
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
+// scanOpenDelimiters returns how many brackets are left open in source and
+// whether it ends inside a triple-quoted string.
+func scanOpenDelimiters(source string) (int, bool) {
+	depth := 0
+	quote := byte(0)
+	triple := false
+	for i := 0; i < len(source); i++ {
+		c := source[i]
+		if quote != 0 {
+			switch {
+			case c == '\\':
+				i++
+			case triple && strings.HasPrefix(source[i:], strings.Repeat(string(quote), 3)):
+				quote, triple = 0, false
+				i += 2
+			case !triple && (c == quote || c == '\n'):
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'':
+			quote = c
+			if strings.HasPrefix(source[i:], strings.Repeat(string(c), 3)) {
+				triple = true
+				i += 2
+			}
+		case '/':
+			if i+1 < len(source) && source[i+1] == '/' {
+				for i < len(source) && source[i] != '\n' {
+					i++
+				}
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		}
+	}
+	return depth, quote != 0 && triple
+}
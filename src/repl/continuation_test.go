@@ -0,0 +1,31 @@
+package repl
+
+import "testing"
+
+func TestNeedsMoreInput(t *testing.T) {
+	tests := []struct {
+		source   string
+		blank    bool
+		expected bool
+	}{
+		{"x = 1\n", false, false},
+		{"for i in range(3):\n", false, true},
+		{"for i in range(3):\n    print(i)\n", false, true},
+		{"for i in range(3):\n    print(i)\n", true, false},
+		{"x = [1,\n", false, true},
+		{"x = [1,\n2]\n", false, false},
+		{"print(\"a:\")\n", false, false},
+		{"x = 1 // note:\n", false, false},
+		{"s = \"\"\"start\n", false, true},
+		{"s = \"\"\"start\nend\"\"\"\n", false, false},
+		{"print(\"(\")\n", false, false},
+		{"x = 1 + \\\n", false, true},
+		{"x = 1 + \\\n2\n", false, false},
+		{"x = \"\\\\\"\n", false, false},
+	}
+	for _, tt := range tests {
+		if got := needsMoreInput(tt.source, tt.blank); got != tt.expected {
+			t.Errorf("needsMoreInput(%q, %v) = %v, want %v", tt.source, tt.blank, got, tt.expected)
+		}
+	}
+}
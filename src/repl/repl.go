@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/javanhut/Carrion/src/errfmt"
 	"github.com/javanhut/Carrion/src/evaluator"
 	"github.com/javanhut/Carrion/src/lexer"
 	"github.com/javanhut/Carrion/src/object"
@@ -46,11 +48,35 @@ const ODINS_EYE = `
 
   `
 
+// Prompt is shown when the REPL is ready for a new statement.
+var Prompt = ">>> "
+
+// Color makes the REPL show errors in red.
+var Color bool
+
+// ErrorFormat, if set, renders the errors the REPL shows in place of Color.
+var ErrorFormat errfmt.Formatter
+
 func Start(in io.Reader, out io.Writer, env *object.Environment) {
 	line := liner.NewLiner()
+	line.SetCtrlCAborts(true)
 	evaluator.LineReader = line
 
+	historyPath := historyFile()
+	if historyPath != "" {
+		if f, err := os.Open(historyPath); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+	}
+
 	defer func() {
+		if historyPath != "" {
+			if f, err := os.Create(historyPath); err == nil {
+				line.WriteHistory(f)
+				f.Close()
+			}
+		}
 		line.Close()
 		evaluator.LineReader = nil
 	}()
@@ -65,11 +91,6 @@ func Start(in io.Reader, out io.Writer, env *object.Environment) {
 	// 	return nil
 	// })
 
-	// Optional: Load history from a file
-	// if f, err := os.Open(historyFile); err == nil {
-	// 	line.ReadHistory(f)
-	// 	f.Close()
-	// }
 	if len(os.Args) > 1 {
 		filePath := os.Args[1]
 		if strings.HasSuffix(filePath, ".crl") {
@@ -86,25 +107,28 @@ func Start(in io.Reader, out io.Writer, env *object.Environment) {
 	}
 
 	var inputBuffer strings.Builder
-	isMultiline := false
-	currentIndentLevel := 0
-	baseIndentLevel := 0
-	inIfBlock := false
+	var history session
 
 	fmt.Fprintln(out, "Welcome to the Carrion Programming Language REPL!")
 	fmt.Fprintln(out, "Type 'exit' or 'quit' to exit, 'clear' to clear the screen.")
+	fmt.Fprintln(out, "Ctrl-C cancels the current input; an empty line ends a block.")
+	fmt.Fprintln(out, "':save file.crl' saves what you have run so far as a script.")
 	fmt.Fprintln(out, "Type any commands you like may Mimir guide your hand.")
 
 	for {
-		var prompt string
-		if !isMultiline {
-			prompt = ">>> "
-		} else {
+		prompt := Prompt
+		if inputBuffer.Len() > 0 {
 			prompt = "... "
 		}
 
 		// Get input from the user
 		input, err := line.Prompt(prompt)
+		if err == liner.ErrPromptAborted {
+			// Ctrl-C throws away the statement being typed.
+			inputBuffer.Reset()
+			fmt.Fprintln(out, "^C")
+			continue
+		}
 		if err != nil {
 			if err == io.EOF {
 				fmt.Fprintln(out, "\nFarewell, May the All Father bless your travels!")
@@ -117,7 +141,7 @@ func Start(in io.Reader, out io.Writer, env *object.Environment) {
 		trimmedLine := strings.TrimSpace(input)
 
 		// Handle special commands only at the primary prompt
-		if !isMultiline {
+		if inputBuffer.Len() == 0 {
 			switch trimmedLine {
 			case "exit", "quit":
 				fmt.Fprintln(out, "Farewell, May the All Father bless your travels!")
@@ -128,79 +152,67 @@ func Start(in io.Reader, out io.Writer, env *object.Environment) {
 			case "":
 				continue
 			}
+			if arg, ok := strings.CutPrefix(trimmedLine, ":save"); ok {
+				history.saveCommand(arg, out)
+				continue
+			}
 		}
 
-		// Add input to history if not empty
 		if trimmedLine != "" {
 			line.AppendHistory(input)
+			inputBuffer.WriteString(input)
+			inputBuffer.WriteString("\n")
 		}
 
-		// Count leading spaces to determine indentation level
-		indentSpaces := len(input) - len(strings.TrimLeft(input, " "))
-		currentIndentLevel = indentSpaces / 4 // Assuming 4 spaces per indent level
-
-		// Handle empty lines
-		if trimmedLine == "" {
-			if isMultiline {
-				inputBuffer.WriteString(input)
-				inputBuffer.WriteString("\n")
-			}
+		if needsMoreInput(inputBuffer.String(), trimmedLine == "") {
 			continue
 		}
 
-		// Check if this is the start of an if block
-		if strings.HasPrefix(trimmedLine, "if ") && strings.HasSuffix(trimmedLine, ":") {
-			inIfBlock = true
-			isMultiline = true
-			baseIndentLevel = currentIndentLevel
+		evaluated, complete := tryParseAndEval(inputBuffer.String(), out, env)
+		if !complete {
+			// The parser wants more even though the input looked finished.
+			continue
 		}
-
-		// Check for otherwise or else clauses
-		if inIfBlock && currentIndentLevel <= baseIndentLevel &&
-			(strings.HasPrefix(trimmedLine, "otherwise") || strings.HasPrefix(trimmedLine, "else")) {
-			isMultiline = true
+		if evaluated != nil {
+			history.record(inputBuffer.String(), evaluated)
 		}
+		printResult(out, evaluated)
+		inputBuffer.Reset()
+	}
+}
 
-		// Append the line to our input buffer
-		inputBuffer.WriteString(input)
-		inputBuffer.WriteString("\n")
-
-		// Determine if we should evaluate
-		shouldEvaluate := false
-
-		// Check for a complete block
-		if isMultiline {
-			if currentIndentLevel <= baseIndentLevel && !strings.HasSuffix(trimmedLine, ":") &&
-				!strings.HasPrefix(trimmedLine, "otherwise") &&
-				!strings.HasPrefix(trimmedLine, "else") {
-				shouldEvaluate = true
-				inIfBlock = false
-			}
-		} else if !strings.HasSuffix(trimmedLine, ":") {
-			shouldEvaluate = true
+func printResult(out io.Writer, evaluated object.Object) {
+	if evaluated == nil || evaluated.Type() == object.NONE_OBJ {
+		return
+	}
+	switch evaluated.Type() {
+	case object.ERROR_OBJ, object.CUSTOM_ERROR_OBJ, object.INTERNAL_ERROR_OBJ:
+		if ErrorFormat != nil {
+			fmt.Fprintf(out, "%s\n", ErrorFormat.Runtime(evaluated))
+			return
 		}
+		fmt.Fprintf(out, "%s\n", red(evaluated.Inspect()))
+	default:
+		fmt.Fprintf(out, "%s\n", evaluated.Inspect())
+	}
+}
 
-		if shouldEvaluate {
-			input := inputBuffer.String()
-			if strings.TrimSpace(input) == "" {
-				inputBuffer.Reset()
-				continue
-			}
+// red colors s for a terminal if Color is set.
+func red(s string) string {
+	if !Color {
+		return s
+	}
+	return "\033[31m" + s + "\033[0m"
+}
 
-			evaluated, complete := tryParseAndEval(input, out, env)
-			if complete {
-				if evaluated != nil && evaluated.Type() != object.NONE_OBJ {
-					fmt.Fprintf(out, "%s\n", evaluated.Inspect())
-				}
-				inputBuffer.Reset()
-				isMultiline = false
-				baseIndentLevel = 0
-				inIfBlock = false
-			} else {
-				isMultiline = true
-			}
-		}
+// historyFile returns where REPL history is kept between sessions, or ""
+// if there is no home directory to keep it in.
+func historyFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
 	}
+	return filepath.Join(home, ".carrion_history")
 }
 
 func tryParseAndEval(input string, out io.Writer, env *object.Environment) (object.Object, bool) {
@@ -212,13 +224,14 @@ func tryParseAndEval(input string, out io.Writer, env *object.Environment) (obje
 		if isIncompleteParse(p.Errors()) {
 			return nil, false
 		}
-		printParserErrors(out, p.Errors())
+		printParserErrors(out, p.ParseErrors())
 		return nil, true
 	}
 
-	evaluated := evaluator.Eval(program, env)
+	evaluated := evaluator.SafeEval(program, env)
 	if evaluated == nil {
-		return nil, true
+		// Report success as None; nil means the input did not parse.
+		return object.NONE, true
 	}
 
 	if returnValue, ok := evaluated.(*object.ReturnValue); ok {
@@ -239,12 +252,16 @@ func isIncompleteParse(errs []string) bool {
 	return false
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+func printParserErrors(out io.Writer, errors []parser.ParseError) {
 	// fmt.Fprint(out, ODINS_EYE)
 	io.WriteString(out, "Sorry Friend! Odin's eye sees all and you seem to have errors.\n")
 	io.WriteString(out, "Parser Errors:\n")
-	for _, msg := range errors {
-		fmt.Fprintf(out, "\t%s\n", msg)
+	if ErrorFormat != nil {
+		fmt.Fprintf(out, "%s\n", ErrorFormat.Syntax(errors))
+		return
+	}
+	for _, e := range errors {
+		fmt.Fprintf(out, "\t%s\n", red(e.Message))
 	}
 }
 
@@ -264,11 +281,11 @@ func ProcessFile(filePath string, out io.Writer, env *object.Environment) error
 	program := p.ParseProgram()
 
 	if len(p.Errors()) > 0 {
-		printParserErrors(out, p.Errors())
+		printParserErrors(out, p.ParseErrors())
 		return fmt.Errorf("file %s contains syntax errors", filePath)
 	}
 
-	evaluated := evaluator.Eval(program, env)
+	evaluated := evaluator.SafeEval(program, env)
 	if evaluated != nil && evaluated.Type() != object.NONE_OBJ {
 		fmt.Fprintf(out, "%s\n", evaluated.Inspect())
 	}
@@ -0,0 +1,104 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+// session records the inputs of a REPL session that ran without error, so
+// that they can be saved as a script with :save.
+type session struct {
+	inputs []string
+}
+
+func (s *session) record(input string, result object.Object) {
+	if isFailure(result) {
+		return
+	}
+	s.inputs = append(s.inputs, strings.TrimRight(input, "\n"))
+}
+
+// save writes the recorded inputs to path, separated by blank lines so that
+// each block is closed just as it was at the prompt.
+func (s *session) save(path string) error {
+	var out strings.Builder
+	for i, input := range s.inputs {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString(input)
+		out.WriteString("\n")
+	}
+	return os.WriteFile(path, []byte(out.String()), 0644)
+}
+
+// saveCommand handles `:save path`.
+func (s *session) saveCommand(arg string, out io.Writer) {
+	path := strings.TrimSpace(arg)
+	if path == "" {
+		fmt.Fprintln(out, "usage: :save file.crl")
+		return
+	}
+	if err := s.save(path); err != nil {
+		fmt.Fprintf(out, "Error saving session: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "Saved %d inputs to %s\n", len(s.inputs), path)
+}
+
+// Replay feeds a script to env line by line as if it were typed at the
+// REPL, echoing each line after its prompt and printing the value of each
+// input the way the REPL does. It returns the number of inputs that failed.
+func Replay(r io.Reader, out io.Writer, env *object.Environment) (int, error) {
+	var buffer strings.Builder
+	failed := 0
+	run := func() {
+		evaluated, _ := tryParseAndEval(buffer.String(), out, env)
+		printResult(out, evaluated)
+		if isFailure(evaluated) {
+			failed++
+		}
+		buffer.Reset()
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		input := scanner.Text()
+		trimmed := strings.TrimSpace(input)
+		if buffer.Len() == 0 {
+			if trimmed == "" {
+				continue
+			}
+			fmt.Fprintf(out, ">>> %s\n", input)
+		} else {
+			fmt.Fprintf(out, "... %s\n", input)
+		}
+		if trimmed != "" {
+			buffer.WriteString(input)
+			buffer.WriteString("\n")
+		}
+		if !needsMoreInput(buffer.String(), trimmed == "") {
+			run()
+		}
+	}
+	if buffer.Len() > 0 {
+		run()
+	}
+	return failed, scanner.Err()
+}
+
+func isFailure(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.CUSTOM_ERROR_OBJ, object.INTERNAL_ERROR_OBJ:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/Carrion/src/object"
+)
+
+func TestSessionSaveAndReplay(t *testing.T) {
+	env := object.NewEnvironment()
+	var out bytes.Buffer
+	var history session
+	for _, input := range []string{
+		"x = 2\n",
+		"spell double(n):\n    return n * 2\n",
+		"missing + 1\n",
+		"double(x)\n",
+	} {
+		evaluated, _ := tryParseAndEval(input, &out, env)
+		history.record(input, evaluated)
+	}
+
+	path := filepath.Join(t.TempDir(), "session.crl")
+	history.saveCommand(path, &out)
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "x = 2\n\nspell double(n):\n    return n * 2\n\ndouble(x)\n"
+	if string(saved) != want {
+		t.Fatalf("saved session =\n%q\nwant\n%q", saved, want)
+	}
+	if !strings.Contains(out.String(), "Saved 3 inputs to "+path) {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+
+	out.Reset()
+	failed, err := Replay(bytes.NewReader(saved), &out, object.NewEnvironment())
+	if err != nil || failed != 0 {
+		t.Fatalf("Replay = %d, %v", failed, err)
+	}
+	wantOut := ">>> x = 2\n>>> spell double(n):\n...     return n * 2\n... \n" +
+		"spell(n) {\n\nreturn (n * 2)\n\n}\n>>> double(x)\n4\n"
+	if out.String() != wantOut {
+		t.Errorf("replay output =\n%q\nwant\n%q", out.String(), wantOut)
+	}
+}
+
+func TestReplayCountsFailures(t *testing.T) {
+	var out bytes.Buffer
+	failed, err := Replay(strings.NewReader("y = 1\nnope()\ny + 1"), &out, object.NewEnvironment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 1 {
+		t.Errorf("failed = %d, want 1", failed)
+	}
+	if !strings.HasSuffix(out.String(), ">>> y + 1\n2\n") {
+		t.Errorf("replay should carry on after an error:\n%s", out.String())
+	}
+}
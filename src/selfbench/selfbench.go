@@ -0,0 +1,205 @@
+// Package selfbench implements `carrion selfbench`, which measures the
+// interpreter itself. It runs a fixed set of Carrion programs, each
+// exercising one part of the evaluator, and reports timings as JSON so that
+// runs before and after a change to the interpreter can be compared.
+package selfbench
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/evaluator"
+	"github.com/javanhut/Carrion/src/lexer"
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/parser"
+)
+
+// Each benchmark ends by checking its result, so an interpreter change that
+// makes a benchmark faster by making it wrong fails instead.
+//
+//go:embed benchmarks/*.crl
+var benchmarkFS embed.FS
+
+// DefaultRuns is how many times each benchmark is run.
+const DefaultRuns = 10
+
+// Benchmark is one program in the suite.
+type Benchmark struct {
+	Name   string
+	Source string
+}
+
+// Result is what was measured for one benchmark. Times are per run of the
+// program, not counting parsing or loading the standard library; NsPerRun
+// is the median, which is what Compare uses.
+type Result struct {
+	Name         string `json:"name"`
+	Runs         int    `json:"runs"`
+	NsPerRun     int64  `json:"ns_per_run"`
+	MinNs        int64  `json:"min_ns"`
+	MaxNs        int64  `json:"max_ns"`
+	BytesPerRun  uint64 `json:"bytes_per_run"`
+	AllocsPerRun uint64 `json:"allocs_per_run"`
+}
+
+// Report is the output of a selfbench run.
+type Report struct {
+	GoVersion  string   `json:"go_version"`
+	OS         string   `json:"os"`
+	Arch       string   `json:"arch"`
+	Benchmarks []Result `json:"benchmarks"`
+}
+
+// Benchmarks returns the suite, ordered by name.
+func Benchmarks() []Benchmark {
+	files, _ := fs.Glob(benchmarkFS, "benchmarks/*.crl")
+	sort.Strings(files)
+	benchmarks := make([]Benchmark, 0, len(files))
+	for _, file := range files {
+		src, _ := benchmarkFS.ReadFile(file)
+		benchmarks = append(benchmarks, Benchmark{
+			Name:   strings.TrimSuffix(path.Base(file), ".crl"),
+			Source: string(src),
+		})
+	}
+	return benchmarks
+}
+
+// Run runs the benchmarks named in names, or all of them if names is empty,
+// runs times each.
+func Run(names []string, runs int) (Report, error) {
+	if runs < 1 {
+		return Report{}, fmt.Errorf("runs must be at least 1, got %d", runs)
+	}
+	var selected []Benchmark
+	for _, b := range Benchmarks() {
+		if len(names) == 0 || slices.Contains(names, b.Name) {
+			selected = append(selected, b)
+		}
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(selected, func(b Benchmark) bool { return b.Name == name }) {
+			return Report{}, fmt.Errorf("unknown benchmark %q", name)
+		}
+	}
+
+	report := Report{GoVersion: runtime.Version(), OS: runtime.GOOS, Arch: runtime.GOARCH}
+	for _, b := range selected {
+		result, err := b.Measure(runs)
+		if err != nil {
+			return Report{}, err
+		}
+		report.Benchmarks = append(report.Benchmarks, result)
+	}
+	return report, nil
+}
+
+// Measure runs the benchmark runs times, each in a fresh interpreter.
+func (b Benchmark) Measure(runs int) (Result, error) {
+	name := b.Name + ".crl"
+	p := parser.New(lexer.New(b.Source, name))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return Result{}, fmt.Errorf("%s: %s", name, strings.Join(p.Errors(), "\n"))
+	}
+
+	times := make([]time.Duration, runs)
+	var bytes, allocs uint64
+	for i := range times {
+		env := object.NewEnvironment()
+		env.SetContext(evaluator.NewEvalContext(name))
+		if err := evaluator.LoadMuninStdlib(env); err != nil {
+			return Result{}, fmt.Errorf("failed to load stdlib: %v", err)
+		}
+
+		elapsed, allocated, mallocs, result := timeEval(program, env)
+		if isFailure(result) {
+			return Result{}, fmt.Errorf("%s: %s", name, result.Inspect())
+		}
+		times[i] = elapsed
+		bytes += allocated
+		allocs += mallocs
+	}
+
+	slices.Sort(times)
+	return Result{
+		Name:         b.Name,
+		Runs:         runs,
+		NsPerRun:     int64(times[runs/2]),
+		MinNs:        int64(times[0]),
+		MaxNs:        int64(times[runs-1]),
+		BytesPerRun:  bytes / uint64(runs),
+		AllocsPerRun: allocs / uint64(runs),
+	}, nil
+}
+
+// timeEval evaluates program, reporting how long it took and how much it
+// allocated. A collection beforehand keeps garbage from earlier runs from
+// being collected on this run's time.
+func timeEval(program *ast.Program, env *object.Environment) (time.Duration, uint64, uint64, object.Object) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	result := evaluator.SafeEval(program, env)
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return elapsed, after.TotalAlloc - before.TotalAlloc, after.Mallocs - before.Mallocs, result
+}
+
+func isFailure(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.CUSTOM_ERROR_OBJ, object.INTERNAL_ERROR_OBJ:
+		return true
+	}
+	return false
+}
+
+// Regression is a benchmark that got slower than a baseline allows.
+type Regression struct {
+	Name     string
+	Baseline int64 // ns per run
+	Current  int64
+}
+
+// Change is how much slower the benchmark got, in percent.
+func (r Regression) Change() float64 {
+	return 100 * float64(r.Current-r.Baseline) / float64(r.Baseline)
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s: %s -> %s per run (+%.1f%%)", r.Name,
+		time.Duration(r.Baseline), time.Duration(r.Current), r.Change())
+}
+
+// Compare returns the benchmarks in current whose median time is more than
+// threshold percent above the same benchmark's in baseline. Benchmarks that
+// only one of the reports has are ignored.
+func Compare(baseline, current Report, threshold float64) []Regression {
+	previous := make(map[string]int64, len(baseline.Benchmarks))
+	for _, r := range baseline.Benchmarks {
+		previous[r.Name] = r.NsPerRun
+	}
+	var regressions []Regression
+	for _, r := range current.Benchmarks {
+		before, ok := previous[r.Name]
+		if !ok || before <= 0 {
+			continue
+		}
+		if float64(r.NsPerRun) > float64(before)*(1+threshold/100) {
+			regressions = append(regressions, Regression{Name: r.Name, Baseline: before, Current: r.NsPerRun})
+		}
+	}
+	return regressions
+}
@@ -0,0 +1,56 @@
+package selfbench
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSuiteRuns(t *testing.T) {
+	report, err := Run(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range report.Benchmarks {
+		names = append(names, r.Name)
+		if r.Runs != 1 || r.NsPerRun <= 0 || r.MinNs > r.NsPerRun || r.NsPerRun > r.MaxNs {
+			t.Errorf("implausible result %+v", r)
+		}
+	}
+	if got := strings.Join(names, " "); got != "dispatch fib hashes inventory logs strings" {
+		t.Errorf("ran %s", got)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	if _, err := Run([]string{"fib", "nope"}, 1); err == nil || !strings.Contains(err.Error(), `unknown benchmark "nope"`) {
+		t.Errorf("expected an unknown benchmark error, got %v", err)
+	}
+	if _, err := Run(nil, 0); err == nil {
+		t.Error("expected an error for zero runs")
+	}
+	wrong := Benchmark{Name: "wrong", Source: "result = 1 + 1\ncheck(result == 3)"}
+	if _, err := wrong.Measure(1); err == nil || !strings.HasPrefix(err.Error(), "wrong.crl: ") {
+		t.Errorf("expected a failed check to be reported, got %v", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	baseline := Report{Benchmarks: []Result{
+		{Name: "fib", NsPerRun: 1000},
+		{Name: "strings", NsPerRun: 1000},
+		{Name: "gone", NsPerRun: 1000},
+	}}
+	current := Report{Benchmarks: []Result{
+		{Name: "fib", NsPerRun: 1100},
+		{Name: "strings", NsPerRun: 1101},
+		{Name: "new", NsPerRun: 5000},
+	}}
+	regressions := Compare(baseline, current, 10)
+	if len(regressions) != 1 || regressions[0].Name != "strings" {
+		t.Fatalf("expected only strings to regress, got %v", regressions)
+	}
+	if got := regressions[0].String(); got != "strings: 1µs -> 1.101µs per run (+10.1%)" {
+		t.Errorf("got %q", got)
+	}
+}
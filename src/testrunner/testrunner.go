@@ -0,0 +1,189 @@
+// Package testrunner implements `carrion test`. It discovers test_*.crl
+// files, runs every top-level spell whose name starts with test_ and reports
+// each test as passed or failed. A test fails when a check assertion fails
+// or an error is raised and not ensnared.
+package testrunner
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Carrion/src/ast"
+	"github.com/javanhut/Carrion/src/evaluator"
+	"github.com/javanhut/Carrion/src/lexer"
+	"github.com/javanhut/Carrion/src/mod"
+	"github.com/javanhut/Carrion/src/object"
+	"github.com/javanhut/Carrion/src/parser"
+)
+
+// ModuleTest is the name reported when a test file fails before any of its
+// tests could run, e.g. because it does not parse.
+const ModuleTest = "<module>"
+
+// Result is the outcome of a single test spell.
+type Result struct {
+	File     string
+	Name     string
+	Passed   bool
+	Duration time.Duration
+	Failure  string // the error with its stack trace, empty if Passed
+}
+
+// Summary aggregates the results of a run.
+type Summary struct {
+	Results  []Result
+	Files    int
+	Passed   int
+	Failed   int
+	Duration time.Duration
+}
+
+// Discover returns the test files under paths. Directories are searched
+// recursively for test_*.crl files; files named directly are always
+// included.
+func Discover(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			base := filepath.Base(name)
+			if name == path || (strings.HasPrefix(base, "test_") && filepath.Ext(base) == ".crl") {
+				files = append(files, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Run runs the tests in every file found under paths, writing progress,
+// program output and a final summary to w.
+func Run(paths []string, w io.Writer) (Summary, error) {
+	files, err := Discover(paths)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	var summary Summary
+	start := time.Now()
+	for _, file := range files {
+		fmt.Fprintf(w, "=== %s\n", file)
+		for _, r := range RunFile(file, w) {
+			summary.Results = append(summary.Results, r)
+			if r.Passed {
+				summary.Passed++
+			} else {
+				summary.Failed++
+			}
+		}
+	}
+	summary.Files = len(files)
+	summary.Duration = time.Since(start)
+
+	total := summary.Passed + summary.Failed
+	if summary.Failed > 0 {
+		fmt.Fprintf(w, "FAIL: %d of %d tests failed (%d files, %s)\n",
+			summary.Failed, total, summary.Files, formatDuration(summary.Duration))
+	} else {
+		fmt.Fprintf(w, "ok: %d tests passed (%d files, %s)\n",
+			total, summary.Files, formatDuration(summary.Duration))
+	}
+	return summary, nil
+}
+
+// RunFile evaluates a test file in a fresh interpreter with the Munin
+// standard library loaded, then calls each of its test spells in the order
+// they are defined. Each result is reported to w as soon as the test
+// finishes, interleaved with anything the tests print.
+func RunFile(file string, w io.Writer) []Result {
+	fail := func(msg string) []Result {
+		r := Result{File: file, Name: ModuleTest, Failure: msg}
+		report(w, r)
+		return []Result{r}
+	}
+
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return fail(err.Error())
+	}
+	p := parser.New(lexer.New(string(src), file))
+	p.SetVersion(mod.LanguageVersion(file))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return fail(strings.Join(p.Errors(), "\n"))
+	}
+
+	env := object.NewEnvironment()
+	ctx := evaluator.NewEvalContext(file)
+	ctx.SetStdout(w)
+	env.SetContext(ctx)
+	if err := evaluator.LoadMuninStdlib(env); err != nil {
+		return fail(fmt.Sprintf("failed to load stdlib: %v", err))
+	}
+	if result := evaluator.SafeEval(program, env); isFailure(result) {
+		return fail(result.Inspect())
+	}
+
+	var results []Result
+	for _, stmt := range program.Statements {
+		def, ok := stmt.(*ast.FunctionDefinition)
+		if !ok || !strings.HasPrefix(def.Name.Value, "test_") {
+			continue
+		}
+		call := &ast.CallExpression{Token: def.Token, Function: def.Name}
+
+		started := time.Now()
+		result := evaluator.SafeEval(call, env)
+		r := Result{File: file, Name: def.Name.Value, Passed: true, Duration: time.Since(started)}
+		if isFailure(result) {
+			r.Passed = false
+			r.Failure = result.Inspect()
+		}
+		report(w, r)
+		results = append(results, r)
+	}
+	return results
+}
+
+func report(w io.Writer, r Result) {
+	if r.Passed {
+		fmt.Fprintf(w, "--- PASS: %s (%s)\n", r.Name, formatDuration(r.Duration))
+		return
+	}
+	fmt.Fprintf(w, "--- FAIL: %s (%s)\n", r.Name, formatDuration(r.Duration))
+	for _, line := range strings.Split(strings.TrimRight(r.Failure, "\n"), "\n") {
+		if line == "" {
+			fmt.Fprintln(w)
+			continue
+		}
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+}
+
+func isFailure(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.CUSTOM_ERROR_OBJ, object.INTERNAL_ERROR_OBJ:
+		return true
+	}
+	return false
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
@@ -0,0 +1,130 @@
+package testrunner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "test_a.crl"), "")
+	writeFile(t, filepath.Join(dir, "nested", "test_b.crl"), "")
+	writeFile(t, filepath.Join(dir, "helpers.crl"), "")
+	writeFile(t, filepath.Join(dir, "test_notes.txt"), "")
+	explicit := filepath.Join(dir, "checks.crl")
+	writeFile(t, explicit, "")
+
+	files, err := Discover([]string{dir, explicit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, "nested", "test_b.crl"),
+		filepath.Join(dir, "test_a.crl"),
+		explicit,
+	}
+	if strings.Join(files, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Discover = %v, want %v", files, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "test_math.crl"), `spell add(a, b):
+    return a + b
+
+spell test_add():
+    check(add(1, 2) == 3)
+
+spell test_wrong_sum():
+    print("checking")
+    check(add(2, 2) == 5, "2 + 2 should be 5")
+
+spell test_raises():
+    raise ValueError("bad value")
+
+spell test_ensnared():
+    attempt:
+        raise ValueError("caught")
+    ensnare (ValueError):
+        ignore
+
+spell helper():
+    check(False)`)
+	writeFile(t, filepath.Join(dir, "test_broken.crl"), `spell test_never_runs():
+    check(True)
+
+x = missing`)
+
+	var out bytes.Buffer
+	summary, err := Run([]string{dir}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Files != 2 || summary.Passed != 2 || summary.Failed != 3 {
+		t.Fatalf("summary = %d files, %d passed, %d failed; want 2, 2, 3\n%s",
+			summary.Files, summary.Passed, summary.Failed, out.String())
+	}
+
+	var names []string
+	for _, r := range summary.Results {
+		names = append(names, r.Name)
+	}
+	wantNames := "<module> test_add test_wrong_sum test_raises test_ensnared"
+	if got := strings.Join(names, " "); got != wantNames {
+		t.Errorf("ran %q, want %q", got, wantNames)
+	}
+
+	output := out.String()
+	for _, want := range []string{
+		"--- PASS: test_add (",
+		"checking",
+		"--- FAIL: test_wrong_sum (",
+		"    Assertion Check Failed: : 2 + 2 should be 5 at " + filepath.Join(dir, "test_math.crl") + ":9:5",
+		"    Stack trace (most recent call last):",
+		"--- FAIL: test_raises (",
+		"    ValueError: bad value",
+		"--- FAIL: <module> (",
+		"identifier not found: missing",
+		"FAIL: 3 of 5 tests failed (2 files, ",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output is missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Index(output, "checking") > strings.Index(output, "--- FAIL: test_wrong_sum") {
+		t.Errorf("test output should come before its result:\n%s", output)
+	}
+}
+
+func TestRunAllPassing(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "test_ok.crl"), `spell test_truth():
+    check(1 < 2)`)
+
+	var out bytes.Buffer
+	summary, err := Run([]string{dir}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Failed != 0 || summary.Passed != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if !strings.Contains(out.String(), "ok: 1 tests passed (1 files, ") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
@@ -37,6 +37,7 @@ const (
 	INT       TokenType = "INT"
 	FLOAT     TokenType = "FLOAT"
 	STRING    TokenType = "STRING"
+	BYTES     TokenType = "BYTES"
 	DOCSTRING TokenType = "DOCSTRING"
 
 	// Operators
@@ -45,6 +46,7 @@ const (
 	MINUS           TokenType = "-"
 	ASTERISK        TokenType = "*"
 	SLASH           TokenType = "/"
+	FLOORDIV        TokenType = "~/"
 	MOD             TokenType = "%"
 	EXPONENT        TokenType = "**"
 	INCREMENT       TokenType = "+="
@@ -71,6 +73,7 @@ const (
 	COLON     TokenType = ":"
 	PIPE      TokenType = "|"
 	DOT       TokenType = "."
+	DOTDOT    TokenType = ".."
 	LSHIFT    TokenType = "<<"
 	RSHIFT    TokenType = ">>"
 	XOR       TokenType = "^"
@@ -110,11 +113,18 @@ const (
 	ENSNARE     TokenType = "ENSNARE"
 	RAISE       TokenType = "RAISE"
 	AS          TokenType = "AS"
+	EXPOSE      TokenType = "EXPOSE"
+	EXPORT      TokenType = "EXPORT"
 	ARCANE      TokenType = "ARCANE"
 	ARCANESPELL TokenType = "ARCANESPELL"
 	SUPER       TokenType = "SUPER"
 	FSTRING     TokenType = "FSTRING"
 	CHECK       TokenType = "CHECK"
+	GLOBAL      TokenType = "GLOBAL"
+	OUTER       TokenType = "OUTER"
+	RECORD      TokenType = "RECORD"
+	EXTEND      TokenType = "EXTEND"
+	AUTOCLOSE   TokenType = "AUTOCLOSE"
 	NONE        TokenType = "NONE"
 	AND         TokenType = "AND"
 	OR          TokenType = "OR"
@@ -150,10 +160,15 @@ var keywords = map[string]TokenType{
 	"ensnare":     ENSNARE,
 	"raise":       RAISE,
 	"as":          AS,
+	"expose":      EXPOSE,
+	"export":      EXPORT,
 	"arcane":      ARCANE,
 	"arcanespell": ARCANESPELL,
 	"super":       SUPER,
 	"check":       CHECK,
+	"record":      RECORD,
+	"extend":      EXTEND,
+	"autoclose":   AUTOCLOSE,
 
 	//"range":     RANGE,
 	"None": NONE,
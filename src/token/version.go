@@ -0,0 +1,44 @@
+package token
+
+import "slices"
+
+// Versions lists the versions of the language, oldest first; the last is
+// the current one. A file can ask to be parsed as an older one, with
+// `pragma carrion_version "1.0"` or a carrion_version line in its
+// carrion.mod, so that it keeps working after a change that would break it,
+// such as a new keyword taking a name it uses.
+var Versions = []string{"1.0", "1.1", "1.2"}
+
+// CurrentVersion is the version files are parsed as unless they ask for
+// another.
+var CurrentVersion = Versions[len(Versions)-1]
+
+// keywordVersions gives the version each keyword added after 1.0 first
+// appeared in. Files for an earlier version can use it as a name.
+var keywordVersions = map[string]string{
+	"expose": "1.1",
+	"record": "1.1",
+	"extend": "1.1",
+	"export": "1.1",
+
+	"autoclose": "1.2",
+}
+
+// KnownVersion reports whether version is one of Versions.
+func KnownVersion(version string) bool {
+	return slices.Contains(Versions, version)
+}
+
+// InVersion gives tok as a file for version reads it: a keyword added in a
+// later version is a plain name there.
+func InVersion(tok Token, version string) Token {
+	keyword, ok := keywords[tok.Literal]
+	if !ok || (tok.Type != IDENT && tok.Type != keyword) {
+		return tok
+	}
+	tok.Type = keyword
+	if since, ok := keywordVersions[tok.Literal]; ok && slices.Index(Versions, version) < slices.Index(Versions, since) {
+		tok.Type = IDENT
+	}
+	return tok
+}
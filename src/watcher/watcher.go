@@ -0,0 +1,62 @@
+// Package watcher reports changes to a set of files. It polls their
+// modification times and sizes rather than using OS notifications, so it
+// behaves the same on every platform and also notices files that are
+// replaced or deleted by an editor's save.
+package watcher
+
+import (
+	"os"
+	"time"
+)
+
+// DefaultInterval is how often Wait checks the files.
+const DefaultInterval = 250 * time.Millisecond
+
+type stamp struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+// Snapshot records the state of a set of files at one moment.
+type Snapshot map[string]stamp
+
+// Take records the current state of files. A file that does not exist is
+// recorded as missing, so creating it later counts as a change.
+func Take(files []string) Snapshot {
+	s := make(Snapshot, len(files))
+	for _, file := range files {
+		s[file] = current(file)
+	}
+	return s
+}
+
+func current(file string) stamp {
+	info, err := os.Stat(file)
+	if err != nil {
+		return stamp{}
+	}
+	return stamp{exists: true, modTime: info.ModTime(), size: info.Size()}
+}
+
+// Changed returns the files whose state differs from the snapshot.
+func (s Snapshot) Changed() []string {
+	var changed []string
+	for file, was := range s {
+		if now := current(file); now != was {
+			changed = append(changed, file)
+		}
+	}
+	return changed
+}
+
+// Wait blocks until at least one file in the snapshot changes, checking
+// every interval, and returns the files that changed.
+func (s Snapshot) Wait(interval time.Duration) []string {
+	for {
+		time.Sleep(interval)
+		if changed := s.Changed(); len(changed) > 0 {
+			return changed
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChanged(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "main.crl")
+	lib := filepath.Join(dir, "lib.crl")
+	missing := filepath.Join(dir, "later.crl")
+	if err := os.WriteFile(script, []byte("print(1)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lib, []byte("x = 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snap := Take([]string{script, lib, missing})
+	if changed := snap.Changed(); len(changed) != 0 {
+		t.Fatalf("nothing changed yet, got %v", changed)
+	}
+
+	if err := os.WriteFile(lib, []byte("x = 22"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if changed := snap.Changed(); len(changed) != 1 || changed[0] != lib {
+		t.Errorf("expected %s to change, got %v", lib, changed)
+	}
+
+	snap = Take([]string{script, lib, missing})
+	if err := os.WriteFile(missing, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(script); err != nil {
+		t.Fatal(err)
+	}
+	if changed := snap.Changed(); len(changed) != 2 {
+		t.Errorf("expected creation and removal to count as changes, got %v", changed)
+	}
+}
+
+func TestWait(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "main.crl")
+	if err := os.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	snap := Take([]string{file})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		os.WriteFile(file, []byte("ab"), 0644)
+	}()
+	changed := snap.Wait(5 * time.Millisecond)
+	if len(changed) != 1 || changed[0] != file {
+		t.Errorf("Wait returned %v", changed)
+	}
+}